@@ -0,0 +1,124 @@
+// Medical Appointment Booking System - Access Log Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package access
+
+import (
+	"log/slog"
+
+	"bookings/auth"
+	"bookings/database"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logged resources
+const (
+	ResourcePatient      = "patient"
+	ResourceMedicalNotes = "medical_notes"
+	ResourceDocument     = "document"
+	ResourceVisitNote    = "visit_note"
+	ResourcePrescription = "prescription"
+	ResourceReferral     = "referral"
+	ResourceChart        = "chart"
+	ResourceFormResponse = "form_response"
+)
+
+// Resources lists every logged resource
+var Resources = []string{ResourcePatient, ResourceMedicalNotes, ResourceDocument, ResourceVisitNote, ResourcePrescription, ResourceReferral, ResourceChart, ResourceFormResponse}
+
+// Patients records that the caller read the given patient records. Failures are logged
+// rather than returned so access logging never fails the read itself.
+func Patients(c *gin.Context, patientIDs ...int) {
+	entries := make([]models.AccessEntry, 0, len(patientIDs))
+	for _, id := range patientIDs {
+		entries = append(entries, models.AccessEntry{PatientID: id})
+	}
+	record(c, ResourcePatient, entries)
+}
+
+// MedicalNotes records that the caller read the medical notes of the given appointments.
+// Appointments without notes are skipped.
+func MedicalNotes(c *gin.Context, appointments ...models.Appointment) {
+	entries := []models.AccessEntry{}
+	for _, appointment := range appointments {
+		if appointment.MedicalNotes != nil {
+			entries = append(entries, models.AccessEntry{PatientID: appointment.PatientID, AppointmentID: &appointment.ID})
+		}
+	}
+	record(c, ResourceMedicalNotes, entries)
+}
+
+// Documents records that the caller downloaded the given patient documents
+func Documents(c *gin.Context, documents ...models.Document) {
+	entries := make([]models.AccessEntry, 0, len(documents))
+	for _, document := range documents {
+		entries = append(entries, models.AccessEntry{PatientID: document.PatientID, AppointmentID: document.AppointmentID})
+	}
+	record(c, ResourceDocument, entries)
+}
+
+// Prescriptions records that the caller read the given prescriptions
+func Prescriptions(c *gin.Context, prescriptions ...models.Prescription) {
+	entries := make([]models.AccessEntry, 0, len(prescriptions))
+	for _, prescription := range prescriptions {
+		entries = append(entries, models.AccessEntry{PatientID: prescription.PatientID, AppointmentID: &prescription.AppointmentID})
+	}
+	record(c, ResourcePrescription, entries)
+}
+
+// Referrals records that the caller read the given referrals
+func Referrals(c *gin.Context, referrals ...models.Referral) {
+	entries := make([]models.AccessEntry, 0, len(referrals))
+	for _, referral := range referrals {
+		entries = append(entries, models.AccessEntry{PatientID: referral.PatientID})
+	}
+	record(c, ResourceReferral, entries)
+}
+
+// Chart records that the caller read part of a patient's chart: their allergies,
+// medications or conditions
+func Chart(c *gin.Context, patientID int) {
+	record(c, ResourceChart, []models.AccessEntry{{PatientID: patientID}})
+}
+
+// FormResponses records that the caller read the intake form responses of an appointment
+func FormResponses(c *gin.Context, appointment *models.Appointment) {
+	record(c, ResourceFormResponse, []models.AccessEntry{{PatientID: appointment.PatientID, AppointmentID: &appointment.ID}})
+}
+
+// VisitNote records that the caller read the visit note, and its amendments, of an appointment
+func VisitNote(c *gin.Context, appointment *models.Appointment) {
+	record(c, ResourceVisitNote, []models.AccessEntry{{PatientID: appointment.PatientID, AppointmentID: &appointment.ID}})
+}
+
+func record(c *gin.Context, resource string, entries []models.AccessEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	var actor, apiKey *int
+	if id, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		actor = &id
+	}
+	if id, ok := auth.APIKeyIDFromContext(c.Request.Context()); ok {
+		apiKey = &id
+	}
+	endpoint := c.Request.Method + " " + c.FullPath()
+	if err := database.InsertAccessEntries(c.Request.Context(), actor, apiKey, resource, endpoint, entries); err != nil {
+		slog.ErrorContext(c.Request.Context(), "access: recording reads", "count", len(entries), "resource", resource, "endpoint", endpoint, "error", err)
+	}
+}
@@ -15,6 +15,7 @@
 // along with this program.  If not, see <https://www.gnu.org/licenses/>.
 
 import 'dart:convert';
+import 'dart:typed_data';
 import 'package:http/http.dart' as http;
 
 /// ApiClient class to handle HTTP requests to the Medical Appointment Booking API
@@ -25,6 +26,7 @@ import 'package:http/http.dart' as http;
 /// Example usage:
 /// ```dart
 /// final apiClient = ApiClient();
+/// await apiClient.login('admin@clinic.example', 'secret');
 ///
 /// // Get all clinics
 /// List<Map<String, dynamic>> clinics = await apiClient.getClinics();
@@ -34,7 +36,7 @@ import 'package:http/http.dart' as http;
 ///   'first_name': 'John',
 ///   'last_name': 'Doe',
 ///   'date_of_birth': '1990-05-15',
-///   'phone': '+1234567890',
+///   'phone': '+14155552671',
 ///   'email': 'john.doe@email.com',
 ///   'address': '123 Main St, City, State 12345',
 ///   'medical_record_number': 'MRN001'
@@ -44,14 +46,79 @@ import 'package:http/http.dart' as http;
 class ApiClient {
   final String baseUrl;
 
-  ApiClient({this.baseUrl = 'http://localhost:8080/api'});
+  ApiClient({this.baseUrl = 'http://localhost:8080/api/v1'});
+
+  /// The current access token, sent as a Bearer token on every request.
+  String? accessToken;
+
+  /// The current refresh token, exchanged by [refresh] for a new access token.
+  String? refreshToken;
+
+  Map<String, String> _headers({bool jsonBody = false}) {
+    return {
+      if (jsonBody) 'Content-Type': 'application/json',
+      if (accessToken != null) 'Authorization': 'Bearer $accessToken',
+    };
+  }
+
+  /// Authentication endpoints
+
+  /// Logs in with an email and password and stores the returned tokens on the client.
+  ///
+  /// Access tokens expire after 15 minutes; call [refresh] to get a new one.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.login('admin@clinic.example', 'secret');
+  /// ```
+  Future<Map<String, dynamic>> login(String email, String password) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/auth/login'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({'email': email, 'password': password}),
+    );
+    if (response.statusCode == 200) {
+      return _storeTokens(json.decode(response.body));
+    } else {
+      throw Exception('Failed to log in');
+    }
+  }
+
+  /// Exchanges the stored refresh token for a new access token.
+  ///
+  /// Refresh tokens are single use, so the replacement returned by the server is stored too.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.refresh();
+  /// ```
+  Future<Map<String, dynamic>> refresh() async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/auth/refresh'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({'refresh_token': refreshToken}),
+    );
+    if (response.statusCode == 200) {
+      return _storeTokens(json.decode(response.body));
+    } else {
+      throw Exception('Failed to refresh session');
+    }
+  }
+
+  Map<String, dynamic> _storeTokens(Map<String, dynamic> tokens) {
+    accessToken = tokens['access_token'];
+    refreshToken = tokens['refresh_token'];
+    return tokens;
+  }
 
   /// Clinics endpoints
 
-  /// Retrieves all clinics from the system.
+  /// Retrieves clinics from the system.
   ///
   /// Returns a list of clinic objects with their details.
   ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  ///
   /// Example:
   /// ```dart
   /// List<Map<String, dynamic>> clinics = await apiClient.getClinics();
@@ -59,15 +126,36 @@ class ApiClient {
   ///   print('Clinic: ${clinic['name']} - ${clinic['address']}');
   /// }
   /// ```
-  Future<List<Map<String, dynamic>>> getClinics() async {
-    final response = await http.get(Uri.parse('$baseUrl/clinics'));
+  Future<List<Map<String, dynamic>>> getClinics({int limit = 50, int offset = 0}) async {
+    final response = await http.get(Uri.parse('$baseUrl/clinics?limit=$limit&offset=$offset'), headers: _headers());
     if (response.statusCode == 200) {
-      return List<Map<String, dynamic>>.from(json.decode(response.body));
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
     } else {
       throw Exception('Failed to load clinics');
     }
   }
 
+  /// Retrieves the clinics within [radiusKm] kilometres of a point, nearest first.
+  ///
+  /// Each clinic carries its `distance_km`; clinics without coordinates are left out.
+  ///
+  /// Example:
+  /// ```dart
+  /// List<Map<String, dynamic>> clinics = await apiClient.getNearbyClinics(51.5072, -0.1276, radiusKm: 10);
+  /// print('Nearest: ${clinics.first['name']} (${clinics.first['distance_km']} km)');
+  /// ```
+  Future<List<Map<String, dynamic>>> getNearbyClinics(double lat, double lng,
+      {double radiusKm = 25, int limit = 50, int offset = 0}) async {
+    final response = await http.get(
+        Uri.parse('$baseUrl/clinics/nearby?lat=$lat&lng=$lng&radius_km=$radiusKm&limit=$limit&offset=$offset'),
+        headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load nearby clinics');
+    }
+  }
+
   /// Retrieves a specific clinic by its ID.
   ///
   /// [id] - The unique identifier of the clinic.
@@ -81,7 +169,7 @@ class ApiClient {
   /// print('Phone: ${clinic['phone']}');
   /// ```
   Future<Map<String, dynamic>> getClinic(int id) async {
-    final response = await http.get(Uri.parse('$baseUrl/clinics/$id'));
+    final response = await http.get(Uri.parse('$baseUrl/clinics/$id'), headers: _headers());
     if (response.statusCode == 200) {
       return json.decode(response.body);
     } else {
@@ -93,14 +181,14 @@ class ApiClient {
   ///
   /// [clinic] - A map containing clinic information.
   ///
-  /// Required fields: name, address, phone, email
+  /// Required fields: name
   ///
   /// Example:
   /// ```dart
   /// Map<String, dynamic> newClinic = {
   ///   'name': 'Downtown Medical Center',
   ///   'address': '456 Health St, Downtown, NY 10001',
-  ///   'phone': '+1-212-555-0123',
+  ///   'phone': '+12125550123',
   ///   'email': 'info@downtownmedical.com'
   /// };
   /// Map<String, dynamic> createdClinic = await apiClient.createClinic(newClinic);
@@ -109,7 +197,7 @@ class ApiClient {
   Future<Map<String, dynamic>> createClinic(Map<String, dynamic> clinic) async {
     final response = await http.post(
       Uri.parse('$baseUrl/clinics'),
-      headers: {'Content-Type': 'application/json'},
+      headers: _headers(jsonBody: true),
       body: json.encode(clinic),
     );
     if (response.statusCode == 201) {
@@ -129,7 +217,7 @@ class ApiClient {
   /// Map<String, dynamic> updatedClinic = {
   ///   'name': 'Downtown Medical Center - Updated',
   ///   'address': '456 Health St, Downtown, NY 10001',
-  ///   'phone': '+1-212-555-0124', // Changed phone number
+  ///   'phone': '+12125550124', // Changed phone number
   ///   'email': 'info@downtownmedical.com'
   /// };
   /// Map<String, dynamic> result = await apiClient.updateClinic(1, updatedClinic);
@@ -138,7 +226,7 @@ class ApiClient {
       int id, Map<String, dynamic> clinic) async {
     final response = await http.put(
       Uri.parse('$baseUrl/clinics/$id'),
-      headers: {'Content-Type': 'application/json'},
+      headers: _headers(jsonBody: true),
       body: json.encode(clinic),
     );
     if (response.statusCode == 200) {
@@ -148,6 +236,25 @@ class ApiClient {
     }
   }
 
+  /// Updates only the given fields of a clinic; the rest are kept.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.patchClinic(1, {'phone': '+12125550124'});
+  /// ```
+  Future<Map<String, dynamic>> patchClinic(int id, Map<String, dynamic> changes) async {
+    final response = await http.patch(
+      Uri.parse('$baseUrl/clinics/$id'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(changes),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update clinic');
+    }
+  }
+
   /// Deletes a clinic from the system.
   ///
   /// [id] - The unique identifier of the clinic to delete.
@@ -158,18 +265,221 @@ class ApiClient {
   /// print('Clinic deleted successfully');
   /// ```
   Future<void> deleteClinic(int id) async {
-    final response = await http.delete(Uri.parse('$baseUrl/clinics/$id'));
+    final response = await http.delete(Uri.parse('$baseUrl/clinics/$id'), headers: _headers());
     if (response.statusCode != 204) {
       throw Exception('Failed to delete clinic');
     }
   }
 
+  /// Restores a deleted clinic (admins only).
+  ///
+  /// Deleting only marks the clinic as deleted; this makes it visible again.
+  ///
+  /// [id] - The unique identifier of the deleted clinic.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> clinic = await apiClient.restoreClinic(1);
+  /// ```
+  Future<Map<String, dynamic>> restoreClinic(int id) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/clinics/$id/restore'),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to restore clinic');
+    }
+  }
+
+  /// Retrieves a clinic's weekly opening hours.
+  ///
+  /// An empty list means the clinic is open whenever its employees work.
+  ///
+  /// Example:
+  /// ```dart
+  /// List<Map<String, dynamic>> hours = await apiClient.getClinicHours(1);
+  /// for (var window in hours) {
+  ///   print('Day ${window['weekday']}: ${window['open_time']}-${window['close_time']}');
+  /// }
+  /// ```
+  Future<List<Map<String, dynamic>>> getClinicHours(int clinicId) async {
+    final response = await http.get(Uri.parse('$baseUrl/clinics/$clinicId/hours'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load clinic hours');
+    }
+  }
+
+  /// Adds an opening window to a clinic's week (admins only).
+  ///
+  /// [hours] - weekday (1 = Monday ... 7 = Sunday), open_time and close_time as "HH:MM".
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.createClinicHours(1, {'weekday': 1, 'open_time': '08:00', 'close_time': '18:00'});
+  /// ```
+  Future<Map<String, dynamic>> createClinicHours(int clinicId, Map<String, dynamic> hours) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/clinics/$clinicId/hours'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(hours),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to create clinic hours');
+    }
+  }
+
+  /// Replaces one of a clinic's opening windows (admins only).
+  Future<Map<String, dynamic>> updateClinicHours(int clinicId, int hoursId, Map<String, dynamic> hours) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/clinics/$clinicId/hours/$hoursId'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(hours),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update clinic hours');
+    }
+  }
+
+  /// Removes one of a clinic's opening windows (admins only).
+  Future<void> deleteClinicHours(int clinicId, int hoursId) async {
+    final response = await http.delete(Uri.parse('$baseUrl/clinics/$clinicId/hours/$hoursId'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to delete clinic hours');
+    }
+  }
+
+  /// Retrieves the dates a clinic is closed.
+  ///
+  /// [from] and [to] are "YYYY-MM-DD" dates; the server defaults to the next year.
+  ///
+  /// Example:
+  /// ```dart
+  /// List<Map<String, dynamic>> holidays = await apiClient.getClinicHolidays(1, from: '2026-12-01', to: '2026-12-31');
+  /// ```
+  Future<List<Map<String, dynamic>>> getClinicHolidays(int clinicId, {String? from, String? to}) async {
+    final query = {if (from != null) 'from': from, if (to != null) 'to': to};
+    final response = await http.get(
+      Uri.parse('$baseUrl/clinics/$clinicId/holidays').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load clinic holidays');
+    }
+  }
+
+  /// Closes a clinic on a date, or renames the holiday already on it (admins only).
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.setClinicHoliday(1, '2026-12-25', 'Christmas Day');
+  /// ```
+  Future<Map<String, dynamic>> setClinicHoliday(int clinicId, String date, String name) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/clinics/$clinicId/holidays/$date'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({'name': name}),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to set clinic holiday');
+    }
+  }
+
+  /// Reopens a clinic on a date (admins only).
+  Future<void> deleteClinicHoliday(int clinicId, String date) async {
+    final response = await http.delete(Uri.parse('$baseUrl/clinics/$clinicId/holidays/$date'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to delete clinic holiday');
+    }
+  }
+
+  /// Retrieves the settings of a clinic's embeddable booking widget.
+  Future<Map<String, dynamic>> getClinicWidget(int clinicId) async {
+    final response = await http.get(Uri.parse('$baseUrl/clinics/$clinicId/widget'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load clinic widget');
+    }
+  }
+
+  /// Sets up or replaces a clinic's booking widget (admins only).
+  ///
+  /// The `slug` names the clinic in the widget's public URL, `/public/clinics/<slug>/config`;
+  /// `allowed_origins` lists the sites that may load it, or any site when empty.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.setClinicWidget(1, {
+  ///   'slug': 'city-clinic',
+  ///   'enabled': true,
+  ///   'primary_color': '#0a7cff',
+  ///   'allowed_origins': ['https://cityclinic.example'],
+  /// });
+  /// ```
+  Future<Map<String, dynamic>> setClinicWidget(int clinicId, Map<String, dynamic> widget) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/clinics/$clinicId/widget'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(widget),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to set clinic widget');
+    }
+  }
+
+  /// Takes a clinic's booking widget down (admins only).
+  Future<void> deleteClinicWidget(int clinicId) async {
+    final response = await http.delete(Uri.parse('$baseUrl/clinics/$clinicId/widget'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to delete clinic widget');
+    }
+  }
+
+  /// Issues the secret URL of a clinic's waiting room display, revoking any earlier one
+  /// (admins only).
+  ///
+  /// Returns the `display_url`: a Server-Sent Events stream the display opens with an
+  /// EventSource, no login needed. Each `board` event carries the clinic's `now_serving`
+  /// and `queue` (patients by initials) and `served_today`.
+  Future<String> createWaitingRoomDisplay(int clinicId) async {
+    final response = await http.post(Uri.parse('$baseUrl/clinics/$clinicId/waiting-room-display'), headers: _headers());
+    if (response.statusCode == 201) {
+      return json.decode(response.body)['display_url'];
+    } else {
+      throw Exception('Failed to create waiting room display');
+    }
+  }
+
+  /// Revokes a clinic's waiting room display URL (admins only).
+  Future<void> deleteWaitingRoomDisplay(int clinicId) async {
+    final response = await http.delete(Uri.parse('$baseUrl/clinics/$clinicId/waiting-room-display'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to revoke waiting room display');
+    }
+  }
+
   /// Patients endpoints
 
-  /// Retrieves all patients from the system.
+  /// Retrieves patients from the system.
   ///
   /// Returns a list of patient objects with their medical information.
   ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  ///
   /// Example:
   /// ```dart
   /// List<Map<String, dynamic>> patients = await apiClient.getPatients();
@@ -177,10 +487,10 @@ class ApiClient {
   ///   print('${patient['first_name']} ${patient['last_name']} - ${patient['medical_record_number']}');
   /// }
   /// ```
-  Future<List<Map<String, dynamic>>> getPatients() async {
-    final response = await http.get(Uri.parse('$baseUrl/patients'));
+  Future<List<Map<String, dynamic>>> getPatients({int limit = 50, int offset = 0}) async {
+    final response = await http.get(Uri.parse('$baseUrl/patients?limit=$limit&offset=$offset'), headers: _headers());
     if (response.statusCode == 200) {
-      return List<Map<String, dynamic>>.from(json.decode(response.body));
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
     } else {
       throw Exception('Failed to load patients');
     }
@@ -196,9 +506,28 @@ class ApiClient {
   /// print('Patient: ${patient['first_name']} ${patient['last_name']}');
   /// print('DOB: ${patient['date_of_birth']}');
   /// print('Medical Record: ${patient['medical_record_number']}');
+  /// print('Missed appointments: ${patient['no_show_count']}');
   /// ```
   Future<Map<String, dynamic>> getPatient(int id) async {
-    final response = await http.get(Uri.parse('$baseUrl/patients/$id'));
+    final response = await http.get(Uri.parse('$baseUrl/patients/$id'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load patient');
+    }
+  }
+
+  /// Retrieves the patient with the given medical record number.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> patient = await apiClient.getPatientByMrn('MRN00004218');
+  /// print('Patient: ${patient['first_name']} ${patient['last_name']}');
+  /// ```
+  Future<Map<String, dynamic>> getPatientByMrn(String medicalRecordNumber) async {
+    final response = await http.get(
+        Uri.parse('$baseUrl/patients/mrn/${Uri.encodeComponent(medicalRecordNumber)}'),
+        headers: _headers());
     if (response.statusCode == 200) {
       return json.decode(response.body);
     } else {
@@ -210,7 +539,10 @@ class ApiClient {
   ///
   /// [patient] - A map containing patient information.
   ///
-  /// Required fields: first_name, last_name, date_of_birth, phone, email, address, medical_record_number
+  /// Required fields: first_name, last_name
+  ///
+  /// Without a medical_record_number one is generated; give clinic_id to use
+  /// that clinic's prefix.
   ///
   /// Example:
   /// ```dart
@@ -218,7 +550,7 @@ class ApiClient {
   ///   'first_name': 'Jane',
   ///   'last_name': 'Smith',
   ///   'date_of_birth': '1985-03-20',
-  ///   'phone': '+1-555-123-4567',
+  ///   'phone': '+15551234567',
   ///   'email': 'jane.smith@email.com',
   ///   'address': '789 Oak Ave, Springfield, IL 62701',
   ///   'medical_record_number': 'MRN002'
@@ -230,7 +562,7 @@ class ApiClient {
       Map<String, dynamic> patient) async {
     final response = await http.post(
       Uri.parse('$baseUrl/patients'),
-      headers: {'Content-Type': 'application/json'},
+      headers: _headers(jsonBody: true),
       body: json.encode(patient),
     );
     if (response.statusCode == 201) {
@@ -240,10 +572,60 @@ class ApiClient {
     }
   }
 
+  /// Creates patients from a CSV file (admins only), returning the per-row report.
+  ///
+  /// [csv] - The file's contents, with a header row naming the columns
+  /// (`first_name`, `last_name`, `email`, `phone`, `date_of_birth`, ...).
+  /// [dryRun] - Only validate the rows, inserting nothing.
+  ///
+  /// Example:
+  /// ```dart
+  /// final report = await apiClient.importPatients(csvText, dryRun: true);
+  /// for (var row in report['rows'].where((r) => r['status'] == 'invalid')) {
+  ///   print('Line ${row['row']}: ${row['errors']}');
+  /// }
+  /// ```
+  Future<Map<String, dynamic>> importPatients(String csv, {bool dryRun = false}) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/patients/import').replace(queryParameters: {'dry_run': '$dryRun'}),
+      headers: {..._headers(), 'Content-Type': 'text/csv; charset=utf-8'},
+      body: csv,
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to import patients');
+    }
+  }
+
+  /// Downloads every patient as a CSV file, or an Excel workbook when [format] is `xlsx`.
+  /// Admins only.
+  ///
+  /// Example:
+  /// ```dart
+  /// Uint8List csv = await apiClient.exportPatients();
+  /// ```
+  Future<Uint8List> exportPatients({String format = 'csv', bool includeDeleted = false}) async {
+    final response = await http.get(
+      Uri.parse('$baseUrl/patients/export')
+          .replace(queryParameters: {'format': format, 'include_deleted': '$includeDeleted'}),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return response.bodyBytes;
+    } else {
+      throw Exception('Failed to export patients');
+    }
+  }
+
   /// Updates an existing patient's information.
   ///
   /// [id] - The unique identifier of the patient to update.
-  /// [patient] - A map containing updated patient information.
+  /// [patient] - A map containing updated patient information, including the `version`
+  /// it was read at (sent as `If-Match`).
+  ///
+  /// Throws if someone else changed the patient since it was read (412); reload it and
+  /// apply the change again.
   ///
   /// Example:
   /// ```dart
@@ -251,10 +633,11 @@ class ApiClient {
   ///   'first_name': 'Jane',
   ///   'last_name': 'Johnson', // Changed last name
   ///   'date_of_birth': '1985-03-20',
-  ///   'phone': '+1-555-123-4567',
+  ///   'phone': '+15551234567',
   ///   'email': 'jane.johnson@email.com', // Updated email
   ///   'address': '789 Oak Ave, Springfield, IL 62701',
-  ///   'medical_record_number': 'MRN002'
+  ///   'medical_record_number': 'MRN002',
+  ///   'version': patient['version'] // From getPatient
   /// };
   /// Map<String, dynamic> result = await apiClient.updatePatient(1, updatedPatient);
   /// ```
@@ -262,11 +645,36 @@ class ApiClient {
       int id, Map<String, dynamic> patient) async {
     final response = await http.put(
       Uri.parse('$baseUrl/patients/$id'),
-      headers: {'Content-Type': 'application/json'},
+      headers: {..._headers(jsonBody: true), 'If-Match': '"${patient['version']}"'},
       body: json.encode(patient),
     );
     if (response.statusCode == 200) {
       return json.decode(response.body);
+    } else if (response.statusCode == 412) {
+      throw Exception('Patient was changed by someone else; reload it and try again');
+    } else {
+      throw Exception('Failed to update patient');
+    }
+  }
+
+  /// Updates only the given fields of a patient; the rest are kept.
+  ///
+  /// [version] - The version the changes were made against, from getPatient.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.patchPatient(1, patient['version'], {'phone': '+12125550199'});
+  /// ```
+  Future<Map<String, dynamic>> patchPatient(int id, int version, Map<String, dynamic> changes) async {
+    final response = await http.patch(
+      Uri.parse('$baseUrl/patients/$id'),
+      headers: {..._headers(jsonBody: true), 'If-Match': '"$version"'},
+      body: json.encode(changes),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else if (response.statusCode == 412) {
+      throw Exception('Patient was changed by someone else; reload it and try again');
     } else {
       throw Exception('Failed to update patient');
     }
@@ -282,68 +690,442 @@ class ApiClient {
   /// print('Patient deleted successfully');
   /// ```
   Future<void> deletePatient(int id) async {
-    final response = await http.delete(Uri.parse('$baseUrl/patients/$id'));
+    final response = await http.delete(Uri.parse('$baseUrl/patients/$id'), headers: _headers());
     if (response.statusCode != 204) {
       throw Exception('Failed to delete patient');
     }
   }
 
-  /// Employees endpoints
-
-  /// Retrieves all employees from the system.
+  /// Restores a deleted patient (admins only).
   ///
-  /// Returns a list of employee objects with their roles and specializations.
+  /// Deleting only marks the patient as deleted; this makes it visible again.
+  ///
+  /// [id] - The unique identifier of the deleted patient.
   ///
   /// Example:
   /// ```dart
-  /// List<Map<String, dynamic>> employees = await apiClient.getEmployees();
-  /// for (var employee in employees) {
-  ///   print('Dr. ${employee['first_name']} ${employee['last_name']} - ${employee['specialization']}');
-  /// }
+  /// Map<String, dynamic> patient = await apiClient.restorePatient(1);
   /// ```
-  Future<List<Map<String, dynamic>>> getEmployees() async {
-    final response = await http.get(Uri.parse('$baseUrl/employees'));
+  Future<Map<String, dynamic>> restorePatient(int id) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/patients/$id/restore'),
+      headers: _headers(),
+    );
     if (response.statusCode == 200) {
-      return List<Map<String, dynamic>>.from(json.decode(response.body));
+      return json.decode(response.body);
     } else {
-      throw Exception('Failed to load employees');
+      throw Exception('Failed to restore patient');
     }
   }
 
-  /// Retrieves a specific employee by their ID.
+  /// Downloads everything stored about a patient, for a GDPR access request (admins only):
+  /// a JSON document, or a ZIP archive with the patient's files when [format] is `zip`.
   ///
-  /// [id] - The unique identifier of the employee.
+  /// Example:
+  /// ```dart
+  /// Uint8List package = await apiClient.exportPatientData(1, format: 'zip');
+  /// ```
+  Future<Uint8List> exportPatientData(int id, {String format = 'json'}) async {
+    final response = await http.get(
+      Uri.parse('$baseUrl/patients/$id/export').replace(queryParameters: {'format': format}),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return response.bodyBytes;
+    } else {
+      throw Exception('Failed to export patient data: ${response.body}');
+    }
+  }
+
+  /// Erases a patient's personal details on request (admins only). Appointments and the
+  /// rest of the medical record are kept; a patient with upcoming appointments is refused.
   ///
   /// Example:
   /// ```dart
-  /// Map<String, dynamic> employee = await apiClient.getEmployee(1);
-  /// print('Employee: ${employee['first_name']} ${employee['last_name']}');
-  /// print('Role: ${employee['role']}');
-  /// print('Specialization: ${employee['specialization']}');
+  /// Map<String, dynamic> patient = await apiClient.erasePatient(1);
+  /// print('Erased at ${patient['erased_at']}');
   /// ```
-  Future<Map<String, dynamic>> getEmployee(int id) async {
-    final response = await http.get(Uri.parse('$baseUrl/employees/$id'));
+  Future<Map<String, dynamic>> erasePatient(int id) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/patients/$id/erase'),
+      headers: _headers(),
+    );
     if (response.statusCode == 200) {
       return json.decode(response.body);
     } else {
-      throw Exception('Failed to load employee');
+      throw Exception('Failed to erase patient: ${response.body}');
     }
   }
 
-  /// Creates a new employee record in the system.
-  ///
-  /// [employee] - A map containing employee information.
+  /// Retrieves pairs of patients who look like the same person: the older record
+  /// (`patient`), the newer one (`duplicate`) and what they share (`matched_on`:
+  /// `name_and_date_of_birth`, `phone` or `email`).
   ///
-  /// Required fields: first_name, last_name, role, specialization, phone, email
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
   ///
   /// Example:
   /// ```dart
-  /// Map<String, dynamic> newEmployee = {
-  ///   'first_name': 'Dr. Sarah',
-  ///   'last_name': 'Williams',
+  /// final pairs = await apiClient.getDuplicatePatients();
+  /// for (var pair in pairs) {
+  ///   print('${pair['patient']['id']} and ${pair['duplicate']['id']}: ${pair['matched_on'].join(', ')}');
+  /// }
+  /// ```
+  Future<List<Map<String, dynamic>>> getDuplicatePatients({int limit = 50, int offset = 0}) async {
+    final response = await http.get(
+      Uri.parse('$baseUrl/patients/duplicates').replace(queryParameters: {'limit': '$limit', 'offset': '$offset'}),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load duplicate patients');
+    }
+  }
+
+  /// Merges the duplicate patient [otherId] into patient [id] (admins only). The
+  /// duplicate's appointments, waiting list entries, documents and the rest of its
+  /// record move to [id], and the duplicate is soft-deleted.
+  ///
+  /// Returns the kept `patient`, the `merged` one and the ids of what `moved`.
+  ///
+  /// Example:
+  /// ```dart
+  /// final result = await apiClient.mergePatients(12, 48);
+  /// print('Moved ${result['moved']['appointment_ids'].length} appointments');
+  /// ```
+  Future<Map<String, dynamic>> mergePatients(int id, int otherId) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/patients/$id/merge/$otherId'),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to merge patients: ${response.body}');
+    }
+  }
+
+  /// Lists the links between a patient and their guardians and dependents, either way.
+  Future<List<Map<String, dynamic>>> getPatientRelationships(int patientId) async {
+    final response = await http.get(Uri.parse('$baseUrl/patients/$patientId/relationships'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load relationships');
+    }
+  }
+
+  /// Links [dependentId] to patient [guardianId] as their guardian: [relationship] is
+  /// 'PARENT', 'LEGAL_GUARDIAN' or 'CAREGIVER'.
+  Future<Map<String, dynamic>> addDependent(int guardianId, int dependentId, String relationship) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/patients/$guardianId/dependents'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({'dependent_id': dependentId, 'relationship': relationship}),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to add dependent: ${response.body}');
+    }
+  }
+
+  /// Unlinks a dependent from their guardian.
+  Future<void> removeDependent(int guardianId, int dependentId) async {
+    final response = await http.delete(
+      Uri.parse('$baseUrl/patients/$guardianId/dependents/$dependentId'),
+      headers: _headers(),
+    );
+    if (response.statusCode != 200) {
+      throw Exception('Failed to remove dependent: ${response.body}');
+    }
+  }
+
+  /// Retrieves how a patient wants to be notified.
+  ///
+  /// [id] - The unique identifier of the patient.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> prefs = await apiClient.getNotificationPreferences(1);
+  /// print('Channel: ${prefs['channel']}');
+  /// ```
+  Future<Map<String, dynamic>> getNotificationPreferences(int id) async {
+    final response = await http.get(
+      Uri.parse('$baseUrl/patients/$id/notification-preferences'),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load notification preferences');
+    }
+  }
+
+  /// Replaces a patient's notification preferences.
+  ///
+  /// [id] - The unique identifier of the patient.
+  /// [prefs] - channel (ALL, EMAIL, SMS or NONE) and optionally preferred_language,
+  /// quiet_hours_start and quiet_hours_end (HH:MM) and reminder_lead_minutes.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.updateNotificationPreferences(1, {
+  ///   'channel': 'SMS',
+  ///   'quiet_hours_start': '21:00',
+  ///   'quiet_hours_end': '08:00',
+  /// });
+  /// ```
+  Future<Map<String, dynamic>> updateNotificationPreferences(
+      int id, Map<String, dynamic> prefs) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/patients/$id/notification-preferences'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(prefs),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update notification preferences');
+    }
+  }
+
+  /// Patient documents endpoints
+
+  /// Lists a patient's documents, latest first.
+  ///
+  /// [appointmentId] and [category] (REFERRAL, LAB_RESULT, IMAGING or OTHER) narrow the list.
+  ///
+  /// Example:
+  /// ```dart
+  /// List<Map<String, dynamic>> labs = await apiClient.getPatientDocuments(1, category: 'LAB_RESULT');
+  /// ```
+  Future<List<Map<String, dynamic>>> getPatientDocuments(int patientId,
+      {int? appointmentId, String? category, int limit = 50, int offset = 0}) async {
+    final query = {
+      'limit': '$limit',
+      'offset': '$offset',
+      if (appointmentId != null) 'appointment_id': '$appointmentId',
+      if (category != null) 'category': category,
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/patients/$patientId/documents').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load documents');
+    }
+  }
+
+  /// Uploads a document for a patient.
+  ///
+  /// [bytes] is the file, a PDF, JPEG, PNG or WebP image of up to 20 MiB, and [filename] its
+  /// name. [category] is REFERRAL, LAB_RESULT, IMAGING or OTHER.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> document = await apiClient.uploadPatientDocument(
+  ///     1, pdfBytes, 'referral.pdf', 'REFERRAL', appointmentId: 42);
+  /// ```
+  Future<Map<String, dynamic>> uploadPatientDocument(
+      int patientId, Uint8List bytes, String filename, String category,
+      {int? appointmentId, String? description}) async {
+    final request = http.MultipartRequest('POST', Uri.parse('$baseUrl/patients/$patientId/documents'))
+      ..headers.addAll(_headers())
+      ..fields['category'] = category
+      ..files.add(http.MultipartFile.fromBytes('file', bytes, filename: filename));
+    if (appointmentId != null) request.fields['appointment_id'] = '$appointmentId';
+    if (description != null) request.fields['description'] = description;
+    final response = await http.Response.fromStream(await request.send());
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to upload document: ${response.body}');
+    }
+  }
+
+  /// Returns a signed URL a document can be downloaded from for the next five minutes.
+  ///
+  /// Example:
+  /// ```dart
+  /// String url = await apiClient.getDocumentDownloadUrl(7);
+  /// ```
+  Future<String> getDocumentDownloadUrl(int id) async {
+    final response = await http.get(Uri.parse('$baseUrl/documents/$id/download'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body)['url'];
+    } else {
+      throw Exception('Failed to get document download URL');
+    }
+  }
+
+  /// Deletes a document. Uploaders may delete their own within 24 hours; otherwise admins only.
+  Future<void> deleteDocument(int id) async {
+    final response = await http.delete(Uri.parse('$baseUrl/documents/$id'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to delete document');
+    }
+  }
+
+  /// Patient chart endpoints
+  ///
+  /// [section] is `allergies`, `medications` or `conditions`.
+
+  /// Lists a section of a patient's chart, active entries first.
+  ///
+  /// Example:
+  /// ```dart
+  /// List<Map<String, dynamic>> allergies = await apiClient.getChartEntries(1, 'allergies');
+  /// ```
+  Future<List<Map<String, dynamic>>> getChartEntries(int patientId, String section) async {
+    final response = await http.get(Uri.parse('$baseUrl/patients/$patientId/$section'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load $section');
+    }
+  }
+
+  /// Records an allergy, medication or condition on a patient's chart.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.createChartEntry(1, 'allergies', {
+  ///   'substance': 'Penicillin',
+  ///   'code_system': 'SNOMED_CT',
+  ///   'code': '91936005',
+  ///   'criticality': 'HIGH',
+  /// });
+  /// ```
+  Future<Map<String, dynamic>> createChartEntry(int patientId, String section, Map<String, dynamic> entry) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/patients/$patientId/$section'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(entry),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to record chart entry: ${response.body}');
+    }
+  }
+
+  /// Replaces an entry of a patient's chart.
+  Future<Map<String, dynamic>> updateChartEntry(int patientId, String section, int entryId, Map<String, dynamic> entry) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/patients/$patientId/$section/$entryId'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(entry),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update chart entry: ${response.body}');
+    }
+  }
+
+  /// Deletes an entry recorded in error from a patient's chart.
+  Future<void> deleteChartEntry(int patientId, String section, int entryId) async {
+    final response = await http.delete(Uri.parse('$baseUrl/patients/$patientId/$section/$entryId'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to delete chart entry');
+    }
+  }
+
+  /// Employees endpoints
+
+  /// Retrieves employees from the system.
+  ///
+  /// Returns a list of employee objects with their roles and specializations.
+  ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  ///
+  /// Example:
+  /// ```dart
+  /// List<Map<String, dynamic>> employees = await apiClient.getEmployees();
+  /// for (var employee in employees) {
+  ///   print('Dr. ${employee['first_name']} ${employee['last_name']} - ${employee['specialization']}');
+  /// }
+  /// ```
+  Future<List<Map<String, dynamic>>> getEmployees({int limit = 50, int offset = 0}) async {
+    final response = await http.get(Uri.parse('$baseUrl/employees?limit=$limit&offset=$offset'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load employees');
+    }
+  }
+
+  /// Downloads an employee's appointments as an iCalendar (.ics) document.
+  ///
+  /// [id] - The unique identifier of the employee.
+  ///
+  /// Example:
+  /// ```dart
+  /// String ics = await apiClient.getEmployeeCalendar(1);
+  /// ```
+  Future<String> getEmployeeCalendar(int id) async {
+    final response = await http.get(Uri.parse('$baseUrl/employees/$id/calendar.ics'), headers: _headers());
+    if (response.statusCode == 200) {
+      return response.body;
+    } else {
+      throw Exception('Failed to load employee calendar');
+    }
+  }
+
+  /// Issues a calendar subscription URL for an employee, revoking any earlier one.
+  ///
+  /// Returns the `feed_url` to add to Google, Apple or Outlook calendars.
+  ///
+  /// Example:
+  /// ```dart
+  /// String url = await apiClient.createEmployeeCalendarFeed(1);
+  /// ```
+  Future<String> createEmployeeCalendarFeed(int id) async {
+    final response = await http.post(Uri.parse('$baseUrl/employees/$id/calendar-feed'), headers: _headers());
+    if (response.statusCode == 201) {
+      return json.decode(response.body)['feed_url'];
+    } else {
+      throw Exception('Failed to create calendar feed');
+    }
+  }
+
+  /// Retrieves a specific employee by their ID.
+  ///
+  /// [id] - The unique identifier of the employee.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> employee = await apiClient.getEmployee(1);
+  /// print('Employee: ${employee['first_name']} ${employee['last_name']}');
+  /// print('Role: ${employee['role']}');
+  /// print('Specialization: ${employee['specialization']}');
+  /// ```
+  Future<Map<String, dynamic>> getEmployee(int id) async {
+    final response = await http.get(Uri.parse('$baseUrl/employees/$id'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load employee');
+    }
+  }
+
+  /// Creates a new employee record in the system.
+  ///
+  /// [employee] - A map containing employee information.
+  ///
+  /// Required fields: clinic_id, first_name, last_name
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> newEmployee = {
+  ///   'first_name': 'Dr. Sarah',
+  ///   'last_name': 'Williams',
   ///   'role': 'doctor',
   ///   'specialization': 'cardiology',
-  ///   'phone': '+1-555-987-6543',
+  ///   'phone': '+15559876543',
   ///   'email': 'sarah.williams@clinic.com'
   /// };
   /// Map<String, dynamic> createdEmployee = await apiClient.createEmployee(newEmployee);
@@ -353,7 +1135,7 @@ class ApiClient {
       Map<String, dynamic> employee) async {
     final response = await http.post(
       Uri.parse('$baseUrl/employees'),
-      headers: {'Content-Type': 'application/json'},
+      headers: _headers(jsonBody: true),
       body: json.encode(employee),
     );
     if (response.statusCode == 201) {
@@ -375,7 +1157,7 @@ class ApiClient {
   ///   'last_name': 'Williams',
   ///   'role': 'doctor',
   ///   'specialization': 'interventional cardiology', // Updated specialization
-  ///   'phone': '+1-555-987-6543',
+  ///   'phone': '+15559876543',
   ///   'email': 'sarah.williams@clinic.com'
   /// };
   /// Map<String, dynamic> result = await apiClient.updateEmployee(1, updatedEmployee);
@@ -384,7 +1166,7 @@ class ApiClient {
       int id, Map<String, dynamic> employee) async {
     final response = await http.put(
       Uri.parse('$baseUrl/employees/$id'),
-      headers: {'Content-Type': 'application/json'},
+      headers: _headers(jsonBody: true),
       body: json.encode(employee),
     );
     if (response.statusCode == 200) {
@@ -394,6 +1176,25 @@ class ApiClient {
     }
   }
 
+  /// Updates only the given fields of an employee; the rest are kept.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.patchEmployee(2, {'specialty': 'Cardiology'});
+  /// ```
+  Future<Map<String, dynamic>> patchEmployee(int id, Map<String, dynamic> changes) async {
+    final response = await http.patch(
+      Uri.parse('$baseUrl/employees/$id'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(changes),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update employee');
+    }
+  }
+
   /// Deletes an employee record from the system.
   ///
   /// [id] - The unique identifier of the employee to delete.
@@ -404,29 +1205,53 @@ class ApiClient {
   /// print('Employee deleted successfully');
   /// ```
   Future<void> deleteEmployee(int id) async {
-    final response = await http.delete(Uri.parse('$baseUrl/employees/$id'));
+    final response = await http.delete(Uri.parse('$baseUrl/employees/$id'), headers: _headers());
     if (response.statusCode != 204) {
       throw Exception('Failed to delete employee');
     }
   }
 
+  /// Restores a deleted employee (admins only).
+  ///
+  /// Deleting only marks the employee as deleted; this makes it visible again.
+  ///
+  /// [id] - The unique identifier of the deleted employee.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> employee = await apiClient.restoreEmployee(1);
+  /// ```
+  Future<Map<String, dynamic>> restoreEmployee(int id) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/employees/$id/restore'),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to restore employee');
+    }
+  }
+
   /// Services endpoints
 
-  /// Retrieves all medical services offered by the clinic.
+  /// Retrieves medical services offered by the clinic.
   ///
   /// Returns a list of service objects with pricing and duration information.
   ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  ///
   /// Example:
   /// ```dart
   /// List<Map<String, dynamic>> services = await apiClient.getServices();
   /// for (var service in services) {
-  ///   print('${service['name']}: \$${service['price']} (${service['duration_minutes']} min)');
+  ///   print('${service['name']}: ${service['price']['amount']} ${service['price']['currency']} (${service['duration_minutes']} min)');
   /// }
   /// ```
-  Future<List<Map<String, dynamic>>> getServices() async {
-    final response = await http.get(Uri.parse('$baseUrl/services'));
+  Future<List<Map<String, dynamic>>> getServices({int limit = 50, int offset = 0}) async {
+    final response = await http.get(Uri.parse('$baseUrl/services?limit=$limit&offset=$offset'), headers: _headers());
     if (response.statusCode == 200) {
-      return List<Map<String, dynamic>>.from(json.decode(response.body));
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
     } else {
       throw Exception('Failed to load services');
     }
@@ -442,10 +1267,10 @@ class ApiClient {
   /// print('Service: ${service['name']}');
   /// print('Description: ${service['description']}');
   /// print('Duration: ${service['duration_minutes']} minutes');
-  /// print('Price: \$${service['price']}');
+  /// print('Price: ${service['price']['amount']} ${service['price']['currency']} (minor units)');
   /// ```
   Future<Map<String, dynamic>> getService(int id) async {
-    final response = await http.get(Uri.parse('$baseUrl/services/$id'));
+    final response = await http.get(Uri.parse('$baseUrl/services/$id'), headers: _headers());
     if (response.statusCode == 200) {
       return json.decode(response.body);
     } else {
@@ -457,7 +1282,10 @@ class ApiClient {
   ///
   /// [service] - A map containing service information.
   ///
-  /// Required fields: name, description, duration_minutes, price
+  /// Required fields: name, duration_minutes, price
+  ///
+  /// Optional buffer_before_minutes and buffer_after_minutes keep the employee free for
+  /// preparation and clean-up around each appointment.
   ///
   /// Example:
   /// ```dart
@@ -465,7 +1293,7 @@ class ApiClient {
   ///   'name': 'General Consultation',
   ///   'description': 'Comprehensive health check and consultation with physician',
   ///   'duration_minutes': 30,
-  ///   'price': 150.00
+  ///   'price': {'amount': 15000, 'currency': 'USD'} // in cents
   /// };
   /// Map<String, dynamic> createdService = await apiClient.createService(newService);
   /// print('Created service with ID: ${createdService['id']}');
@@ -474,7 +1302,7 @@ class ApiClient {
       Map<String, dynamic> service) async {
     final response = await http.post(
       Uri.parse('$baseUrl/services'),
-      headers: {'Content-Type': 'application/json'},
+      headers: _headers(jsonBody: true),
       body: json.encode(service),
     );
     if (response.statusCode == 201) {
@@ -495,7 +1323,7 @@ class ApiClient {
   ///   'name': 'General Consultation',
   ///   'description': 'Comprehensive health check and consultation with physician',
   ///   'duration_minutes': 45, // Extended duration
-  ///   'price': 175.00 // Price increase
+  ///   'price': {'amount': 17500, 'currency': 'USD'} // Price increase
   /// };
   /// Map<String, dynamic> result = await apiClient.updateService(1, updatedService);
   /// ```
@@ -503,7 +1331,7 @@ class ApiClient {
       int id, Map<String, dynamic> service) async {
     final response = await http.put(
       Uri.parse('$baseUrl/services/$id'),
-      headers: {'Content-Type': 'application/json'},
+      headers: _headers(jsonBody: true),
       body: json.encode(service),
     );
     if (response.statusCode == 200) {
@@ -523,37 +1351,172 @@ class ApiClient {
   /// print('Service deleted successfully');
   /// ```
   Future<void> deleteService(int id) async {
-    final response = await http.delete(Uri.parse('$baseUrl/services/$id'));
+    final response = await http.delete(Uri.parse('$baseUrl/services/$id'), headers: _headers());
     if (response.statusCode != 204) {
       throw Exception('Failed to delete service');
     }
   }
 
-  /// Appointments endpoints
+  /// Retrieves the rooms and equipment a service needs.
+  Future<List<Map<String, dynamic>>> getServiceResources(int serviceId) async {
+    final response = await http.get(Uri.parse('$baseUrl/services/$serviceId/resources'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load service resources');
+    }
+  }
 
-  /// Retrieves all appointments from the system.
-  ///
-  /// Returns a list of appointment objects with patient, employee, and service details.
+  /// Requires a resource for a service (admins only). Each booking of the service then takes
+  /// one free resource of that resource's kind at the appointment's clinic.
+  Future<void> assignServiceResource(int serviceId, int resourceId) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/services/$serviceId/resources'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({'resource_id': resourceId}),
+    );
+    if (response.statusCode != 201 && response.statusCode != 200) {
+      throw Exception('Failed to assign resource');
+    }
+  }
+
+  /// Stops requiring a resource for a service (admins only).
+  Future<void> unassignServiceResource(int serviceId, int resourceId) async {
+    final response = await http.delete(Uri.parse('$baseUrl/services/$serviceId/resources/$resourceId'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to unassign resource');
+    }
+  }
+
+  /// Resources endpoints
+
+  /// Retrieves rooms and equipment, optionally only those at [clinicId].
   ///
   /// Example:
   /// ```dart
-  /// List<Map<String, dynamic>> appointments = await apiClient.getAppointments();
-  /// for (var appointment in appointments) {
-  ///   print('Appointment on ${appointment['appointment_date']} - Status: ${appointment['status']}');
-  /// }
+  /// List<Map<String, dynamic>> rooms = (await apiClient.getResources(clinicId: 1))
+  ///     .where((r) => r['kind'] == 'ROOM')
+  ///     .toList();
   /// ```
-  Future<List<Map<String, dynamic>>> getAppointments() async {
-    final response = await http.get(Uri.parse('$baseUrl/appointments'));
+  Future<List<Map<String, dynamic>>> getResources({int? clinicId}) async {
+    final query = {if (clinicId != null) 'clinic_id': clinicId.toString()};
+    final response = await http.get(
+      Uri.parse('$baseUrl/resources').replace(queryParameters: query),
+      headers: _headers(),
+    );
     if (response.statusCode == 200) {
       return List<Map<String, dynamic>>.from(json.decode(response.body));
     } else {
-      throw Exception('Failed to load appointments');
+      throw Exception('Failed to load resources');
     }
   }
 
-  /// Retrieves a specific appointment by its ID.
+  /// Creates a room or piece of equipment at a clinic (admins only).
+  ///
+  /// Required fields: clinic_id, name, kind ("ROOM" or "EQUIPMENT")
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.createResource({'clinic_id': 1, 'name': 'Procedure Room 2', 'kind': 'ROOM', 'active': true});
+  /// ```
+  Future<Map<String, dynamic>> createResource(Map<String, dynamic> resource) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/resources'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(resource),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to create resource');
+    }
+  }
+
+  /// Replaces a resource (admins only); set 'active' to false to stop it being booked.
+  Future<Map<String, dynamic>> updateResource(int id, Map<String, dynamic> resource) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/resources/$id'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(resource),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update resource');
+    }
+  }
+
+  /// Deletes a resource that has never been booked (admins only).
+  Future<void> deleteResource(int id) async {
+    final response = await http.delete(Uri.parse('$baseUrl/resources/$id'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to delete resource');
+    }
+  }
+
+  /// Appointments endpoints
+
+  /// Retrieves appointments from the system, latest first.
+  ///
+  /// Returns a list of appointment objects.
+  ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  /// The optional filters narrow the list: [from]/[to] keep appointments overlapping
+  /// that range, and [statuses] keeps any of the given statuses. [expand] embeds
+  /// summaries of the related records (`patient`, `employee`, `service`, `clinic`) in
+  /// each appointment, saving a request per record.
+  ///
+  /// Example:
+  /// ```dart
+  /// // Today's remaining schedule for employee 3
+  /// final now = DateTime.now();
+  /// List<Map<String, dynamic>> appointments = await apiClient.getAppointments(
+  ///   from: now,
+  ///   to: DateTime(now.year, now.month, now.day + 1),
+  ///   employeeId: 3,
+  ///   statuses: ['SCHEDULED', 'CONFIRMED'],
+  /// );
+  /// for (var appointment in appointments) {
+  ///   print('Appointment at ${appointment['start_datetime']} - Status: ${appointment['status']}');
+  /// }
+  /// ```
+  Future<List<Map<String, dynamic>>> getAppointments({
+    int limit = 50,
+    int offset = 0,
+    DateTime? from,
+    DateTime? to,
+    int? employeeId,
+    int? patientId,
+    int? clinicId,
+    List<String>? statuses,
+    List<String>? expand,
+  }) async {
+    final query = {
+      'limit': '$limit',
+      'offset': '$offset',
+      if (from != null) 'from': from.toUtc().toIso8601String(),
+      if (to != null) 'to': to.toUtc().toIso8601String(),
+      if (employeeId != null) 'employee_id': '$employeeId',
+      if (patientId != null) 'patient_id': '$patientId',
+      if (clinicId != null) 'clinic_id': '$clinicId',
+      if (statuses != null && statuses.isNotEmpty) 'status': statuses.join(','),
+      if (expand != null && expand.isNotEmpty) 'expand': expand.join(','),
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/appointments').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load appointments');
+    }
+  }
+
+  /// Retrieves a specific appointment by its ID.
   ///
   /// [id] - The unique identifier of the appointment.
+  /// [expand] - Related records to embed: `patient`, `employee`, `service`, `clinic`.
   ///
   /// Example:
   /// ```dart
@@ -565,8 +1528,12 @@ class ApiClient {
   /// print('Service ID: ${appointment['service_id']}');
   /// print('Notes: ${appointment['notes']}');
   /// ```
-  Future<Map<String, dynamic>> getAppointment(int id) async {
-    final response = await http.get(Uri.parse('$baseUrl/appointments/$id'));
+  Future<Map<String, dynamic>> getAppointment(int id, {List<String>? expand}) async {
+    final uri = Uri.parse('$baseUrl/appointments/$id');
+    final response = await http.get(
+      expand == null || expand.isEmpty ? uri : uri.replace(queryParameters: {'expand': expand.join(',')}),
+      headers: _headers(),
+    );
     if (response.statusCode == 200) {
       return json.decode(response.body);
     } else {
@@ -574,11 +1541,68 @@ class ApiClient {
     }
   }
 
+  /// Downloads an appointment's invoice, or its receipt once paid, as a PDF.
+  ///
+  /// [id] - The unique identifier of the appointment.
+  ///
+  /// Example:
+  /// ```dart
+  /// Uint8List pdf = await apiClient.getAppointmentInvoice(1);
+  /// ```
+  Future<Uint8List> getAppointmentInvoice(int id) async {
+    final response = await http.get(Uri.parse('$baseUrl/appointments/$id/invoice.pdf'), headers: _headers());
+    if (response.statusCode == 200) {
+      return response.bodyBytes;
+    } else {
+      throw Exception('Failed to load invoice');
+    }
+  }
+
+  /// Downloads the appointments matching the filters as a CSV file, or an Excel workbook
+  /// when [format] is `xlsx`. Admins only.
+  ///
+  /// [from], [to] - Keep appointments overlapping this range.
+  /// [status] - One or more comma-separated statuses.
+  ///
+  /// Example:
+  /// ```dart
+  /// Uint8List file = await apiClient.exportAppointments(
+  ///     from: DateTime.utc(2026, 1, 1), to: DateTime.utc(2026, 2, 1), format: 'xlsx');
+  /// ```
+  Future<Uint8List> exportAppointments({
+    String format = 'csv',
+    DateTime? from,
+    DateTime? to,
+    int? employeeId,
+    int? patientId,
+    int? clinicId,
+    String? status,
+  }) async {
+    final queryParameters = {
+      'format': format,
+      if (from != null) 'from': from.toUtc().toIso8601String(),
+      if (to != null) 'to': to.toUtc().toIso8601String(),
+      if (employeeId != null) 'employee_id': '$employeeId',
+      if (patientId != null) 'patient_id': '$patientId',
+      if (clinicId != null) 'clinic_id': '$clinicId',
+      if (status != null) 'status': status,
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/appointments/export').replace(queryParameters: queryParameters),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return response.bodyBytes;
+    } else {
+      throw Exception('Failed to export appointments');
+    }
+  }
+
   /// Creates a new appointment in the system.
   ///
   /// [appointment] - A map containing appointment information.
   ///
-  /// Required fields: patient_id, employee_id, service_id, clinic_id, appointment_date, status
+  /// Required fields: patient_id, employee_id, service_id, clinic_id, start_datetime, end_datetime, status, payment_status
   /// Optional fields: notes, payment_status
   ///
   /// Example:
@@ -600,7 +1624,7 @@ class ApiClient {
       Map<String, dynamic> appointment) async {
     final response = await http.post(
       Uri.parse('$baseUrl/appointments'),
-      headers: {'Content-Type': 'application/json'},
+      headers: _headers(jsonBody: true),
       body: json.encode(appointment),
     );
     if (response.statusCode == 201) {
@@ -613,7 +1637,11 @@ class ApiClient {
   /// Updates an existing appointment's information.
   ///
   /// [id] - The unique identifier of the appointment to update.
-  /// [appointment] - A map containing updated appointment information.
+  /// [appointment] - A map containing updated appointment information, including the
+  /// `version` it was read at (sent as `If-Match`).
+  ///
+  /// Throws if someone else changed the appointment since it was read (412); reload it and
+  /// apply the change again.
   ///
   /// Example:
   /// ```dart
@@ -625,7 +1653,8 @@ class ApiClient {
   ///   'appointment_date': '2025-10-25T11:00:00Z', // Changed time
   ///   'status': 'confirmed',  // Updated status
   ///   'notes': 'Follow-up consultation for hypertension - Confirmed',
-  ///   'payment_status': 'paid' // Payment completed
+  ///   'payment_status': 'paid', // Payment completed
+  ///   'version': appointment['version'] // From getAppointment
   /// };
   /// Map<String, dynamic> result = await apiClient.updateAppointment(1, updatedAppointment);
   /// ```
@@ -633,155 +1662,1512 @@ class ApiClient {
       int id, Map<String, dynamic> appointment) async {
     final response = await http.put(
       Uri.parse('$baseUrl/appointments/$id'),
-      headers: {'Content-Type': 'application/json'},
+      headers: {..._headers(jsonBody: true), 'If-Match': '"${appointment['version']}"'},
       body: json.encode(appointment),
     );
     if (response.statusCode == 200) {
       return json.decode(response.body);
+    } else if (response.statusCode == 412) {
+      throw Exception('Appointment was changed by someone else; reload it and try again');
     } else {
       throw Exception('Failed to update appointment');
     }
   }
 
-  /// Deletes an appointment from the system.
+  /// Updates only the given fields of an appointment; the rest are kept.
   ///
-  /// [id] - The unique identifier of the appointment to delete.
+  /// [version] - The version the changes were made against, from getAppointment.
   ///
   /// Example:
   /// ```dart
-  /// await apiClient.deleteAppointment(1);
-  /// print('Appointment cancelled and deleted successfully');
+  /// await apiClient.patchAppointment(1, appointment['version'], {'status': 'CONFIRMED'});
   /// ```
-  Future<void> deleteAppointment(int id) async {
-    final response = await http.delete(Uri.parse('$baseUrl/appointments/$id'));
-    if (response.statusCode != 204) {
-      throw Exception('Failed to delete appointment');
+  Future<Map<String, dynamic>> patchAppointment(int id, int version, Map<String, dynamic> changes) async {
+    final response = await http.patch(
+      Uri.parse('$baseUrl/appointments/$id'),
+      headers: {..._headers(jsonBody: true), 'If-Match': '"$version"'},
+      body: json.encode(changes),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else if (response.statusCode == 412) {
+      throw Exception('Appointment was changed by someone else; reload it and try again');
+    } else {
+      throw Exception('Failed to update appointment');
     }
   }
 
-  /// Waiting List endpoints
+  /// Moves an appointment to a new start time, keeping its length and everything else.
+  ///
+  /// [id] - The unique identifier of the appointment to move.
+  /// [startDatetime] - The new start time.
+  /// [employeeId] - Optionally book it with another employee.
+  /// [reason] - Why it was moved, kept in the reschedule history.
+  /// [notify] - Whether to tell the patient about the new time.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> moved = await apiClient.rescheduleAppointment(
+  ///     1, DateTime.utc(2025, 10, 27, 14),
+  ///     reason: 'Patient asked for an afternoon slot', notify: true);
+  /// ```
+  Future<Map<String, dynamic>> rescheduleAppointment(int id, DateTime startDatetime,
+      {int? employeeId, String? reason, bool notify = false}) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/appointments/$id/reschedule'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({
+        'start_datetime': startDatetime.toUtc().toIso8601String(),
+        if (employeeId != null) 'employee_id': employeeId,
+        if (reason != null) 'reason': reason,
+        'notify': notify,
+      }),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to reschedule appointment');
+    }
+  }
 
-  /// Retrieves all items from the waiting list.
+  /// Cancels one scheduled or confirmed appointment. For an occurrence of a series only
+  /// that occurrence is cancelled.
   ///
-  /// Returns a list of waiting list items with patient requests for services.
+  /// [id] - The unique identifier of the appointment to cancel.
+  /// [reason] - Optionally, why it was cancelled.
   ///
   /// Example:
   /// ```dart
-  /// List<Map<String, dynamic>> waitingList = await apiClient.getWaitingList();
-  /// for (var item in waitingList) {
-  ///   print('Patient ${item['patient_id']} waiting for service ${item['service_id']}');
-  ///   print('Priority: ${item['priority']} - Requested: ${item['requested_date']}');
-  /// }
+  /// Map<String, dynamic> cancelled = await apiClient.cancelAppointment(1, reason: 'Patient is unwell');
   /// ```
-  Future<List<Map<String, dynamic>>> getWaitingList() async {
-    final response = await http.get(Uri.parse('$baseUrl/waiting-list'));
+  Future<Map<String, dynamic>> cancelAppointment(int id, {String? reason}) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/appointments/$id/cancel'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({if (reason != null) 'reason': reason}),
+    );
     if (response.statusCode == 200) {
-      return List<Map<String, dynamic>>.from(json.decode(response.body));
+      return json.decode(response.body);
     } else {
-      throw Exception('Failed to load waiting list');
+      throw Exception('Failed to cancel appointment');
     }
   }
 
-  /// Retrieves a specific waiting list item by its ID.
+  /// Checks in the patient of a scheduled or confirmed appointment on arrival.
   ///
-  /// [id] - The unique identifier of the waiting list item.
+  /// Returns the updated appointment with its checked_in_at.
   ///
   /// Example:
   /// ```dart
-  /// Map<String, dynamic> item = await apiClient.getWaitingListItem(1);
-  /// print('Patient ID: ${item['patient_id']}');
-  /// print('Service ID: ${item['service_id']}');
-  /// print('Priority: ${item['priority']}');
-  /// print('Requested Date: ${item['requested_date']}');
-  /// print('Notes: ${item['notes']}');
+  /// Map<String, dynamic> appointment = await apiClient.checkInAppointment(1);
   /// ```
-  Future<Map<String, dynamic>> getWaitingListItem(int id) async {
-    final response = await http.get(Uri.parse('$baseUrl/waiting-list/$id'));
+  Future<Map<String, dynamic>> checkInAppointment(int id) async {
+    final response = await http.post(Uri.parse('$baseUrl/appointments/$id/check-in'), headers: _headers());
     if (response.statusCode == 200) {
       return json.decode(response.body);
     } else {
-      throw Exception('Failed to load waiting list item');
+      throw Exception('Failed to check in appointment');
     }
   }
 
-  /// Adds a patient to the waiting list for a service.
+  /// Checks out the patient of an appointment that is in progress or checked in,
+  /// completing it.
   ///
-  /// [item] - A map containing waiting list item information.
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> appointment = await apiClient.checkOutAppointment(1);
+  /// ```
+  Future<Map<String, dynamic>> checkOutAppointment(int id) async {
+    final response = await http.post(Uri.parse('$baseUrl/appointments/$id/check-out'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to check out appointment');
+    }
+  }
+
+  /// Checks a patient in at a self-service kiosk with the token scanned from the QR code
+  /// in their confirmation email. No login is needed.
   ///
-  /// Required fields: patient_id, service_id, clinic_id, requested_date, priority
-  /// Optional fields: notes
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> welcome = await apiClient.kioskCheckIn(scannedToken);
+  /// print('Welcome, ${welcome['patient_first_name']}');
+  /// ```
+  Future<Map<String, dynamic>> kioskCheckIn(String token) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/check-in/qr'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({'token': token}),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to check in');
+    }
+  }
+
+  /// Retrieves a clinic's live queue for today: checked-in patients waiting, in order of
+  /// appointment time and arrival, those in progress and the day's wait-time metrics.
   ///
   /// Example:
   /// ```dart
-  /// Map<String, dynamic> newWaitingItem = {
-  ///   'patient_id': 3,        // ID of the patient
-  ///   'service_id': 2,        // ID of the requested service
-  ///   'clinic_id': 1,         // ID of the clinic
-  ///   'requested_date': '2025-10-20T09:00:00Z', // When they want the appointment
-  ///   'priority': 'high',     // high, medium, low
-  ///   'notes': 'Patient prefers morning appointments, has mobility issues'
-  /// };
-  /// Map<String, dynamic> createdItem = await apiClient.createWaitingListItem(newWaitingItem);
-  /// print('Added to waiting list with ID: ${createdItem['id']}');
+  /// Map<String, dynamic> queue = await apiClient.getClinicQueue(1);
+  /// print('Longest wait: ${queue['metrics']['longest_wait_minutes']} min');
   /// ```
-  Future<Map<String, dynamic>> createWaitingListItem(
-      Map<String, dynamic> item) async {
+  Future<Map<String, dynamic>> getClinicQueue(int clinicId) async {
+    final response = await http.get(
+      Uri.parse('$baseUrl/appointments/queue').replace(queryParameters: {'clinic_id': '$clinicId'}),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load clinic queue');
+    }
+  }
+
+  /// Books a recurring series of appointments from an RRULE, e.g. weekly for 8 weeks.
+  ///
+  /// [series] - patient_id, employee_id, service_id, clinic_id, start_datetime and
+  /// recurrence, with optional appointment_type, booking_channel, notes, payment_amount and
+  /// skip_conflicts.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> series = await apiClient.createAppointmentSeries({
+  ///   'patient_id': 1,
+  ///   'employee_id': 1,
+  ///   'service_id': 1,
+  ///   'clinic_id': 1,
+  ///   'start_datetime': '2025-10-27T09:00:00Z',
+  ///   'recurrence': 'FREQ=WEEKLY;COUNT=8',
+  ///   'skip_conflicts': true,
+  /// });
+  /// print('Booked ${series['occurrences'].length}, skipped ${series['skipped']?.length ?? 0}');
+  /// ```
+  Future<Map<String, dynamic>> createAppointmentSeries(Map<String, dynamic> series) async {
     final response = await http.post(
-      Uri.parse('$baseUrl/waiting-list'),
-      headers: {'Content-Type': 'application/json'},
-      body: json.encode(item),
+      Uri.parse('$baseUrl/appointments/series'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(series),
     );
     if (response.statusCode == 201) {
       return json.decode(response.body);
     } else {
-      throw Exception('Failed to create waiting list item');
+      throw Exception('Failed to book appointment series');
     }
   }
 
-  /// Updates a waiting list item's information.
+  /// Retrieves a series with all its occurrences, earliest first.
   ///
-  /// [id] - The unique identifier of the waiting list item to update.
-  /// [item] - A map containing updated waiting list item information.
+  /// [seriesId] - The unique identifier of the series.
+  Future<Map<String, dynamic>> getAppointmentSeries(int seriesId) async {
+    final response = await http.get(Uri.parse('$baseUrl/appointments/series/$seriesId'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load appointment series');
+    }
+  }
+
+  /// Changes every upcoming occurrence of a series.
+  ///
+  /// [seriesId] - The unique identifier of the series.
+  /// [changes] - Any of employee_id, start_time ('HH:MM' in the employee's timezone),
+  /// appointment_type and notes, plus notify to message the patient.
   ///
   /// Example:
   /// ```dart
-  /// Map<String, dynamic> updatedItem = {
-  ///   'patient_id': 3,
-  ///   'service_id': 2,
-  ///   'clinic_id': 1,
-  ///   'requested_date': '2025-10-22T14:00:00Z', // Changed preferred time
-  ///   'priority': 'urgent',    // Increased priority
-  ///   'notes': 'Patient prefers afternoon appointments now, urgent medical need'
-  /// };
-  /// Map<String, dynamic> result = await apiClient.updateWaitingListItem(1, updatedItem);
+  /// await apiClient.updateAppointmentSeries(3, {'start_time': '14:30', 'notify': true});
   /// ```
-  Future<Map<String, dynamic>> updateWaitingListItem(
-      int id, Map<String, dynamic> item) async {
+  Future<Map<String, dynamic>> updateAppointmentSeries(int seriesId, Map<String, dynamic> changes) async {
     final response = await http.put(
-      Uri.parse('$baseUrl/waiting-list/$id'),
-      headers: {'Content-Type': 'application/json'},
-      body: json.encode(item),
+      Uri.parse('$baseUrl/appointments/series/$seriesId'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(changes),
     );
     if (response.statusCode == 200) {
       return json.decode(response.body);
     } else {
-      throw Exception('Failed to update waiting list item');
+      throw Exception('Failed to update appointment series');
     }
   }
 
-  /// Removes an item from the waiting list.
+  /// Cancels every upcoming occurrence of a series; past occurrences are kept.
   ///
-  /// [id] - The unique identifier of the waiting list item to delete.
+  /// [seriesId] - The unique identifier of the series.
+  /// [reason] - Optionally, why it was cancelled.
+  Future<Map<String, dynamic>> cancelAppointmentSeries(int seriesId, {String? reason}) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/appointments/series/$seriesId/cancel'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({if (reason != null) 'reason': reason}),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to cancel appointment series');
+    }
+  }
+
+  /// Deletes an appointment from the system.
+  ///
+  /// [id] - The unique identifier of the appointment to delete.
   ///
   /// Example:
   /// ```dart
-  /// await apiClient.deleteWaitingListItem(1);
-  /// print('Removed from waiting list successfully');
+  /// await apiClient.deleteAppointment(1);
+  /// print('Appointment cancelled and deleted successfully');
   /// ```
-  Future<void> deleteWaitingListItem(int id) async {
-    final response = await http.delete(Uri.parse('$baseUrl/waiting-list/$id'));
+  Future<void> deleteAppointment(int id) async {
+    final response = await http.delete(Uri.parse('$baseUrl/appointments/$id'), headers: _headers());
     if (response.statusCode != 204) {
-      throw Exception('Failed to delete waiting list item');
+      throw Exception('Failed to delete appointment');
+    }
+  }
+
+  /// Retrieves an appointment's SOAP visit note (clinicians only).
+  Future<Map<String, dynamic>> getVisitNote(int appointmentId) async {
+    final response = await http.get(Uri.parse('$baseUrl/appointments/$appointmentId/visit-note'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load visit note');
+    }
+  }
+
+  /// Writes the caller's draft visit note of a completed appointment.
+  ///
+  /// [sections] holds subjective, objective, assessment and plan.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.saveVisitNote(1, {
+  ///   'subjective': 'Headache for three days',
+  ///   'assessment': 'Tension headache',
+  ///   'plan': 'Ibuprofen 400 mg as needed',
+  /// });
+  /// ```
+  Future<Map<String, dynamic>> saveVisitNote(int appointmentId, Map<String, dynamic> sections) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/appointments/$appointmentId/visit-note'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(sections),
+    );
+    if (response.statusCode == 200 || response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to save visit note: ${response.body}');
+    }
+  }
+
+  /// Signs the caller's draft visit note; it can only be amended afterwards.
+  Future<Map<String, dynamic>> signVisitNote(int appointmentId) async {
+    final response = await http.post(Uri.parse('$baseUrl/appointments/$appointmentId/visit-note/sign'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to sign visit note: ${response.body}');
+    }
+  }
+
+  /// Amends a signed visit note. [changes] holds the sections to replace.
+  Future<Map<String, dynamic>> amendVisitNote(int appointmentId, String reason, Map<String, dynamic> changes) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/appointments/$appointmentId/visit-note/amendments'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({...changes, 'reason': reason}),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to amend visit note: ${response.body}');
+    }
+  }
+
+  /// Lists the amendments of a signed visit note, oldest first.
+  Future<List<Map<String, dynamic>>> getVisitNoteAmendments(int appointmentId) async {
+    final response = await http.get(Uri.parse('$baseUrl/appointments/$appointmentId/visit-note/amendments'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load visit note amendments');
+    }
+  }
+
+  /// Prescriptions endpoints
+
+  /// Prescribes a medication at a completed appointment.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> prescription = await apiClient.createPrescription(42, {
+  ///   'medication': 'Amoxicillin 500 mg',
+  ///   'dosage': '1 capsule every 8 hours',
+  ///   'duration_days': 7,
+  ///   'refills': 1,
+  /// });
+  /// ```
+  Future<Map<String, dynamic>> createPrescription(int appointmentId, Map<String, dynamic> prescription) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/appointments/$appointmentId/prescriptions'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(prescription),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to create prescription: ${response.body}');
+    }
+  }
+
+  /// Lists the prescriptions written at an appointment, latest first.
+  Future<List<Map<String, dynamic>>> getAppointmentPrescriptions(int appointmentId,
+      {String? status, int limit = 50, int offset = 0}) async {
+    return _getPrescriptions('$baseUrl/appointments/$appointmentId/prescriptions', status, limit, offset);
+  }
+
+  /// Lists a patient's prescriptions, latest first.
+  ///
+  /// [status] (ACTIVE, EXPIRED or CANCELLED) narrows the list.
+  ///
+  /// Example:
+  /// ```dart
+  /// List<Map<String, dynamic>> active = await apiClient.getPatientPrescriptions(1, status: 'ACTIVE');
+  /// ```
+  Future<List<Map<String, dynamic>>> getPatientPrescriptions(int patientId,
+      {String? status, int limit = 50, int offset = 0}) async {
+    return _getPrescriptions('$baseUrl/patients/$patientId/prescriptions', status, limit, offset);
+  }
+
+  Future<List<Map<String, dynamic>>> _getPrescriptions(String url, String? status, int limit, int offset) async {
+    final query = {
+      'limit': '$limit',
+      'offset': '$offset',
+      if (status != null) 'status': status,
+    };
+    final response = await http.get(Uri.parse(url).replace(queryParameters: query), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load prescriptions');
+    }
+  }
+
+  /// Retrieves a prescription.
+  Future<Map<String, dynamic>> getPrescription(int id) async {
+    final response = await http.get(Uri.parse('$baseUrl/prescriptions/$id'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load prescription');
+    }
+  }
+
+  /// Downloads a prescription as a printable PDF.
+  Future<Uint8List> getPrescriptionPdf(int id) async {
+    final response = await http.get(Uri.parse('$baseUrl/prescriptions/$id/pdf'), headers: _headers());
+    if (response.statusCode == 200) {
+      return response.bodyBytes;
+    } else {
+      throw Exception('Failed to load prescription PDF');
+    }
+  }
+
+  /// Cancels an active prescription.
+  Future<Map<String, dynamic>> cancelPrescription(int id, String reason) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/prescriptions/$id/cancel'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({'reason': reason}),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to cancel prescription: ${response.body}');
+    }
+  }
+
+  /// Referrals endpoints
+
+  /// Lists referrals, latest first.
+  ///
+  /// Example:
+  /// ```dart
+  /// List<Map<String, dynamic>> waiting = await apiClient.getReferrals(targetEmployeeId: 3, status: 'SENT');
+  /// ```
+  Future<List<Map<String, dynamic>>> getReferrals({
+    int? patientId,
+    int? sourceEmployeeId,
+    int? targetEmployeeId,
+    int? targetClinicId,
+    String? status,
+    int limit = 50,
+    int offset = 0,
+  }) async {
+    final query = {
+      'limit': '$limit',
+      'offset': '$offset',
+      if (patientId != null) 'patient_id': '$patientId',
+      if (sourceEmployeeId != null) 'source_employee_id': '$sourceEmployeeId',
+      if (targetEmployeeId != null) 'target_employee_id': '$targetEmployeeId',
+      if (targetClinicId != null) 'target_clinic_id': '$targetClinicId',
+      if (status != null) 'status': status,
+    };
+    final response = await http.get(Uri.parse('$baseUrl/referrals').replace(queryParameters: query), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load referrals');
+    }
+  }
+
+  /// Retrieves a referral.
+  Future<Map<String, dynamic>> getReferral(int id) async {
+    final response = await http.get(Uri.parse('$baseUrl/referrals/$id'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load referral');
+    }
+  }
+
+  /// Refers a patient to another employee, clinic or specialty.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> referral = await apiClient.createReferral({
+  ///   'patient_id': 7,
+  ///   'source_employee_id': 3,
+  ///   'target_specialty': 'Dermatology',
+  ///   'referral_reason': 'Changing mole on the left shoulder',
+  ///   'urgency_level': 'HIGH',
+  /// });
+  /// ```
+  Future<Map<String, dynamic>> createReferral(Map<String, dynamic> referral) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/referrals'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(referral),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to create referral: ${response.body}');
+    }
+  }
+
+  /// Accepts a referral, putting the patient on the waiting list for [serviceId].
+  Future<Map<String, dynamic>> acceptReferral(int id, int serviceId, {String? requestedDate}) async {
+    return _referralAction(id, 'accept', {
+      'service_id': serviceId,
+      if (requestedDate != null) 'requested_date': requestedDate,
+    });
+  }
+
+  /// Declines a referral.
+  Future<Map<String, dynamic>> declineReferral(int id, String reason) async {
+    return _referralAction(id, 'decline', {'reason': reason});
+  }
+
+  /// Records the appointment booked for an accepted referral.
+  Future<Map<String, dynamic>> bookReferral(int id, int appointmentId) async {
+    return _referralAction(id, 'book', {'appointment_id': appointmentId});
+  }
+
+  Future<Map<String, dynamic>> _referralAction(int id, String action, Map<String, dynamic> body) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/referrals/$id/$action'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(body),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to $action referral: ${response.body}');
+    }
+  }
+
+  /// Retrieves the rooms and equipment picked for an appointment.
+  Future<List<Map<String, dynamic>>> getAppointmentResources(int id) async {
+    final response = await http.get(Uri.parse('$baseUrl/appointments/$id/resources'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load appointment resources');
+    }
+  }
+
+  /// Replaces the resources picked for an appointment, e.g. to move it to another room.
+  ///
+  /// Each resource must be active, at the appointment's clinic and free for its time.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.setAppointmentResources(42, [4, 9]);
+  /// ```
+  Future<List<Map<String, dynamic>>> setAppointmentResources(int id, List<int> resourceIds) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/appointments/$id/resources'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({'resource_ids': resourceIds}),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to update appointment resources');
+    }
+  }
+
+  /// The address of the live schedule WebSocket for [clinicId] and/or [employeeId].
+  ///
+  /// Each message is a JSON object whose 'type' is `appointment.created`,
+  /// `appointment.updated`, `appointment.cancelled`, `appointment.deleted`, `heartbeat`, or
+  /// `resync` (reload the schedule). The access token is carried in the address, since a
+  /// WebSocket cannot send headers; open a new socket after refreshing it.
+  ///
+  /// Example (with package:web_socket_channel):
+  /// ```dart
+  /// final channel = WebSocketChannel.connect(apiClient.scheduleSocketUri(clinicId: 1));
+  /// channel.stream.listen((message) => print(json.decode(message)['type']));
+  /// ```
+  Uri scheduleSocketUri({int? clinicId, int? employeeId}) {
+    final uri = Uri.parse('$baseUrl/ws/schedule');
+    return uri.replace(
+      scheme: uri.scheme == 'https' ? 'wss' : 'ws',
+      queryParameters: {
+        if (clinicId != null) 'clinic_id': '$clinicId',
+        if (employeeId != null) 'employee_id': '$employeeId',
+        if (accessToken != null) 'access_token': accessToken!,
+      },
+    );
+  }
+
+  /// Intake form endpoints
+
+  /// Lists the intake form templates by name; [active] narrows the list.
+  Future<List<Map<String, dynamic>>> getFormTemplates({bool? active}) async {
+    final query = {if (active != null) 'active': '$active'};
+    final response = await http.get(Uri.parse('$baseUrl/forms').replace(queryParameters: query), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load forms');
+    }
+  }
+
+  /// Creates an intake form template, asked before appointments of its services and
+  /// appointment types.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> form = await apiClient.createFormTemplate({
+  ///   'name': 'New patient questionnaire',
+  ///   'appointment_types': ['INITIAL_CONSULTATION'],
+  ///   'active': true,
+  ///   'schema': {
+  ///     'type': 'object',
+  ///     'required': ['smoker'],
+  ///     'properties': {'smoker': {'type': 'boolean', 'title': 'Do you smoke?'}},
+  ///   },
+  /// });
+  /// ```
+  Future<Map<String, dynamic>> createFormTemplate(Map<String, dynamic> form) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/forms'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(form),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to create form: ${response.body}');
+    }
+  }
+
+  /// Replaces an intake form template; earlier responses keep the questions they answered.
+  Future<Map<String, dynamic>> updateFormTemplate(int id, Map<String, dynamic> form) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/forms/$id'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(form),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update form: ${response.body}');
+    }
+  }
+
+  /// Deletes an intake form template no patient has answered.
+  Future<void> deleteFormTemplate(int id) async {
+    final response = await http.delete(Uri.parse('$baseUrl/forms/$id'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to delete form: ${response.body}');
+    }
+  }
+
+  /// Lists the intake forms of an appointment, each with the patient's 'response' or null.
+  Future<List<Map<String, dynamic>>> getAppointmentForms(int appointmentId) async {
+    final response = await http.get(Uri.parse('$baseUrl/appointments/$appointmentId/forms'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load appointment forms');
+    }
+  }
+
+  /// Consent endpoints
+
+  /// Lists the consent document versions, latest in effect first; [kind] narrows the list.
+  Future<List<Map<String, dynamic>>> getConsentDocuments({String? kind}) async {
+    final query = {if (kind != null) 'kind': kind};
+    final response =
+        await http.get(Uri.parse('$baseUrl/consent-documents').replace(queryParameters: query), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load consent documents');
+    }
+  }
+
+  /// Publishes a new version of a consent document (admin only). It becomes the current
+  /// version of its kind at 'effective_at', now unless given.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> document = await apiClient.createConsentDocument({
+  ///   'kind': 'TREATMENT',
+  ///   'version': '2026-01',
+  ///   'title': 'Consent to treatment',
+  ///   'body': 'I agree to ...',
+  /// });
+  /// ```
+  Future<Map<String, dynamic>> createConsentDocument(Map<String, dynamic> document) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/consent-documents'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(document),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to create consent document: ${response.body}');
+    }
+  }
+
+  /// Lists a patient's consents, latest first, withdrawn ones included.
+  Future<List<Map<String, dynamic>>> getPatientConsents(int patientId) async {
+    final response = await http.get(Uri.parse('$baseUrl/patients/$patientId/consents'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load consents');
+    }
+  }
+
+  /// Records a patient's agreement to the current version of a consent document, given
+  /// at the clinic: [captureMethod] is 'IN_PERSON', 'PAPER' or 'VERBAL'.
+  Future<Map<String, dynamic>> recordConsent(int patientId, int documentId, String captureMethod) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/patients/$patientId/consents'),
+      headers: _headers(jsonBody: true),
+      body: json.encode({'consent_document_id': documentId, 'capture_method': captureMethod}),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to record consent: ${response.body}');
+    }
+  }
+
+  /// Records that a patient withdrew one of their consents.
+  Future<Map<String, dynamic>> withdrawConsent(int patientId, int consentId) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/patients/$patientId/consents/$consentId/withdraw'),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to withdraw consent: ${response.body}');
+    }
+  }
+
+  /// Lists the active patients without a current consent of [kind], or without consent to
+  /// the version [documentId].
+  Future<List<Map<String, dynamic>>> getPatientsMissingConsent(
+      {String? kind, int? documentId, int limit = 50, int offset = 0}) async {
+    final query = {
+      if (kind != null) 'kind': kind,
+      if (documentId != null) 'document_id': '$documentId',
+      'limit': '$limit',
+      'offset': '$offset',
+    };
+    final response =
+        await http.get(Uri.parse('$baseUrl/consents/missing').replace(queryParameters: query), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load patients missing consent: ${response.body}');
+    }
+  }
+
+  /// Waiting List endpoints
+
+  /// Retrieves items from the waiting list.
+  ///
+  /// Returns a list of waiting list items with patient requests for services.
+  ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  ///
+  /// Example:
+  /// ```dart
+  /// List<Map<String, dynamic>> waitingList = await apiClient.getWaitingList();
+  /// for (var item in waitingList) {
+  ///   print('Patient ${item['patient_id']} waiting for service ${item['service_id']}');
+  ///   print('Priority: ${item['priority']} - Requested: ${item['requested_date']}');
+  /// }
+  /// ```
+  Future<List<Map<String, dynamic>>> getWaitingList({int limit = 50, int offset = 0}) async {
+    final response = await http.get(Uri.parse('$baseUrl/waiting-list?limit=$limit&offset=$offset'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load waiting list');
+    }
+  }
+
+  /// Retrieves a specific waiting list item by its ID.
+  ///
+  /// [id] - The unique identifier of the waiting list item.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> item = await apiClient.getWaitingListItem(1);
+  /// print('Patient ID: ${item['patient_id']}');
+  /// print('Service ID: ${item['service_id']}');
+  /// print('Priority: ${item['priority']}');
+  /// print('Requested Date: ${item['requested_date']}');
+  /// print('Notes: ${item['notes']}');
+  /// ```
+  Future<Map<String, dynamic>> getWaitingListItem(int id) async {
+    final response = await http.get(Uri.parse('$baseUrl/waiting-list/$id'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load waiting list item');
+    }
+  }
+
+  /// Adds a patient to the waiting list for a service.
+  ///
+  /// [item] - A map containing waiting list item information.
+  ///
+  /// Required fields: patient_id, service_id, urgency_level, status
+  /// Optional fields: notes
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> newWaitingItem = {
+  ///   'patient_id': 3,        // ID of the patient
+  ///   'service_id': 2,        // ID of the requested service
+  ///   'clinic_id': 1,         // ID of the clinic
+  ///   'requested_date': '2025-10-20T09:00:00Z', // When they want the appointment
+  ///   'priority': 'high',     // high, medium, low
+  ///   'notes': 'Patient prefers morning appointments, has mobility issues'
+  /// };
+  /// Map<String, dynamic> createdItem = await apiClient.createWaitingListItem(newWaitingItem);
+  /// print('Added to waiting list with ID: ${createdItem['id']}');
+  /// ```
+  Future<Map<String, dynamic>> createWaitingListItem(
+      Map<String, dynamic> item) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/waiting-list'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(item),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to create waiting list item');
+    }
+  }
+
+  /// Updates a waiting list item's information.
+  ///
+  /// [id] - The unique identifier of the waiting list item to update.
+  /// [item] - A map containing updated waiting list item information.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> updatedItem = {
+  ///   'patient_id': 3,
+  ///   'service_id': 2,
+  ///   'clinic_id': 1,
+  ///   'requested_date': '2025-10-22T14:00:00Z', // Changed preferred time
+  ///   'priority': 'urgent',    // Increased priority
+  ///   'notes': 'Patient prefers afternoon appointments now, urgent medical need'
+  /// };
+  /// Map<String, dynamic> result = await apiClient.updateWaitingListItem(1, updatedItem);
+  /// ```
+  Future<Map<String, dynamic>> updateWaitingListItem(
+      int id, Map<String, dynamic> item) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/waiting-list/$id'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(item),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update waiting list item');
+    }
+  }
+
+  /// Removes an item from the waiting list.
+  ///
+  /// [id] - The unique identifier of the waiting list item to delete.
+  ///
+  /// Example:
+  /// ```dart
+  /// await apiClient.deleteWaitingListItem(1);
+  /// print('Removed from waiting list successfully');
+  /// ```
+  Future<void> deleteWaitingListItem(int id) async {
+    final response = await http.delete(Uri.parse('$baseUrl/waiting-list/$id'), headers: _headers());
+    if (response.statusCode != 204) {
+      throw Exception('Failed to delete waiting list item');
+    }
+  }
+
+  /// Offers a waiting list entry the earliest free slot for its service.
+  ///
+  /// The slot is held for the patient and the entry is marked CONTACTED. Returns the
+  /// offer with its `hold`; book it by passing `hold['hold_token']` to [createAppointment].
+  ///
+  /// [id] - The unique identifier of the waiting list item.
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> offer = await apiClient.offerWaitingListSlot(1);
+  /// print('Held until ${offer['hold']['expires_at']}');
+  /// ```
+  Future<Map<String, dynamic>> offerWaitingListSlot(int id) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/waiting-list/$id/offer'),
+      headers: _headers(),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to offer a slot');
+    }
+  }
+
+  /// Retrieves SLA statistics for URGENT waiting list entries added between [from] and [to].
+  ///
+  /// Example:
+  /// ```dart
+  /// Map<String, dynamic> sla = await apiClient.getWaitingListSlaStats(from: DateTime.utc(2026, 1, 1));
+  /// print('${sla['breached']} of ${sla['urgent']} urgent entries waited over ${sla['sla_minutes']} minutes');
+  /// ```
+  Future<Map<String, dynamic>> getWaitingListSlaStats({DateTime? from, DateTime? to}) async {
+    final query = {
+      if (from != null) 'from': from.toUtc().toIso8601String(),
+      if (to != null) 'to': to.toUtc().toIso8601String(),
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/waiting-list/sla').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load waiting list SLA statistics');
+    }
+  }
+
+  /// Audit log endpoints
+
+  /// Retrieves recorded mutations, latest first. Admins only.
+  ///
+  /// Each entry names the [entity] (its table, e.g. `appointments`), the record id, the
+  /// action, the user who made the change (`actor_user_id`, null for background jobs), the
+  /// record afterwards (`snapshot`) and the fields that changed (`changes`).
+  ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  /// [entityId] requires [entity]; [from]/[to] bound when the change was recorded.
+  ///
+  /// Example:
+  /// ```dart
+  /// final history = await apiClient.getAuditLog(entity: 'patients', entityId: 12);
+  /// for (var entry in history) {
+  ///   print('${entry['created_at']} ${entry['action']} by ${entry['actor_user_id']}: ${entry['changes']}');
+  /// }
+  /// ```
+  Future<List<Map<String, dynamic>>> getAuditLog({
+    int limit = 50,
+    int offset = 0,
+    String? entity,
+    int? entityId,
+    int? actorUserId,
+    DateTime? from,
+    DateTime? to,
+  }) async {
+    final query = {
+      'limit': '$limit',
+      'offset': '$offset',
+      if (entity != null) 'entity': entity,
+      if (entityId != null) 'id': '$entityId',
+      if (actorUserId != null) 'actor_user_id': '$actorUserId',
+      if (from != null) 'from': from.toUtc().toIso8601String(),
+      if (to != null) 'to': to.toUtc().toIso8601String(),
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/audit').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load audit log');
+    }
+  }
+
+  /// Retrieves reads of patient records and medical notes, latest first. Admins only.
+  ///
+  /// Each entry names the reading user (`actor_user_id`), the `patient_id`, the
+  /// `resource` read (`patient` or `medical_notes`, with its `appointment_id`), the
+  /// `endpoint` and when it happened.
+  ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  ///
+  /// Example:
+  /// ```dart
+  /// // Who viewed patient 12's chart this month?
+  /// final now = DateTime.now();
+  /// final reads = await apiClient.getAccessLog(patientId: 12, from: DateTime(now.year, now.month));
+  /// for (var entry in reads) {
+  ///   print('${entry['created_at']} user ${entry['actor_user_id']} read ${entry['resource']}');
+  /// }
+  /// ```
+  Future<List<Map<String, dynamic>>> getAccessLog({
+    int limit = 50,
+    int offset = 0,
+    int? patientId,
+    int? actorUserId,
+    String? resource,
+    DateTime? from,
+    DateTime? to,
+  }) async {
+    final query = {
+      'limit': '$limit',
+      'offset': '$offset',
+      if (patientId != null) 'patient_id': '$patientId',
+      if (actorUserId != null) 'actor_user_id': '$actorUserId',
+      if (resource != null) 'resource': resource,
+      if (from != null) 'from': from.toUtc().toIso8601String(),
+      if (to != null) 'to': to.toUtc().toIso8601String(),
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/access-log').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load access log');
+    }
+  }
+
+  /// Retrieves the retention policy of each data class (`appointments`,
+  /// `notifications`, `audit_log`): its `days`, `action` (`archive`, `cold` or
+  /// `purge`), the current `cutoff` and how many rows are `expired` now. `days`,
+  /// `cutoff` and `expired` are null for a class kept forever. Admins only.
+  ///
+  /// Example:
+  /// ```dart
+  /// final policies = await apiClient.getRetentionPolicies();
+  /// for (var p in policies.where((p) => p['days'] != null)) {
+  ///   print('${p['data_class']}: ${p['expired']} rows to ${p['action']}');
+  /// }
+  /// ```
+  Future<List<Map<String, dynamic>>> getRetentionPolicies() async {
+    final response = await http.get(
+      Uri.parse('$baseUrl/retention/policies'),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load retention policies');
+    }
+  }
+
+  /// Retrieves the retention runs that expired rows, latest first, optionally of
+  /// one [dataClass]. Each run has its `action`, `cutoff`, `row_count`, the range
+  /// of ids it expired (`first_id`, `last_id`) and, for cold storage, the object
+  /// store keys it wrote (`locations`). Admins only.
+  ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  Future<List<Map<String, dynamic>>> getRetentionRuns({
+    int limit = 50,
+    int offset = 0,
+    String? dataClass,
+  }) async {
+    final query = {
+      'limit': '$limit',
+      'offset': '$offset',
+      if (dataClass != null) 'data_class': dataClass,
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/retention/runs').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load retention runs');
+    }
+  }
+
+  /// Report endpoints
+
+  /// Retrieves a clinic's appointments for a day, grouped by employee, with each
+  /// patient's name, the service, the rooms and the status.
+  ///
+  /// [date] - The day as `YYYY-MM-DD`; today when left out.
+  /// [tz] - The timezone to take the day in; by default the clinic employees' own.
+  ///
+  /// Example:
+  /// ```dart
+  /// final report = await apiClient.getDailySchedule(1, date: '2026-03-02');
+  /// for (var employee in report['employees']) {
+  ///   print(employee['employee_name']);
+  ///   for (var a in employee['appointments']) {
+  ///     print('  ${a['start']} ${a['patient_name']} ${a['service']} ${a['rooms'].join(', ')}');
+  ///   }
+  /// }
+  /// ```
+  Future<Map<String, dynamic>> getDailySchedule(int clinicId, {String? date, String? tz}) async {
+    final query = {
+      'clinic_id': '$clinicId',
+      if (date != null) 'date': date,
+      if (tz != null) 'tz': tz,
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/reports/daily-schedule').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load daily schedule');
+    }
+  }
+
+  /// Retrieves available, booked and completed hours per employee between [from] and
+  /// [to] (`YYYY-MM-DD`, inclusive, at most 31 days; the current month by default).
+  /// Admins only.
+  ///
+  /// Example:
+  /// ```dart
+  /// final report = await apiClient.getUtilization(from: '2026-03-01', to: '2026-03-31', clinicId: 1);
+  /// for (var e in report['employees']) {
+  ///   print('${e['employee_name']}: ${e['booked_hours']} of ${e['available_hours']} h (${e['booked_percent']}%)');
+  /// }
+  /// ```
+  Future<Map<String, dynamic>> getUtilization({String? from, String? to, int? clinicId, int? employeeId}) async {
+    final query = {
+      if (from != null) 'from': from,
+      if (to != null) 'to': to,
+      if (clinicId != null) 'clinic_id': '$clinicId',
+      if (employeeId != null) 'employee_id': '$employeeId',
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/reports/utilization').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load utilization');
+    }
+  }
+
+  /// Retrieves payment amounts between [from] and [to] (`YYYY-MM-DD`, inclusive; the
+  /// current month by default) per [interval] (`day`, `week` or `month`), clinic, service
+  /// and payment status, with paid, pending and refunded totals per currency. Admins only.
+  ///
+  /// Example:
+  /// ```dart
+  /// final report = await apiClient.getRevenue(from: '2026-01-01', to: '2026-03-31', interval: 'month');
+  /// for (var t in report['totals']) {
+  ///   print('${t['currency']}: paid ${t['paid']['amount']}, pending ${t['pending']['amount']}');
+  /// }
+  /// ```
+  Future<Map<String, dynamic>> getRevenue({
+    String? from,
+    String? to,
+    String interval = 'day',
+    String? tz,
+    int? clinicId,
+    int? serviceId,
+  }) async {
+    final query = {
+      'interval': interval,
+      if (from != null) 'from': from,
+      if (to != null) 'to': to,
+      if (tz != null) 'tz': tz,
+      if (clinicId != null) 'clinic_id': '$clinicId',
+      if (serviceId != null) 'service_id': '$serviceId',
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/reports/revenue').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load revenue');
+    }
+  }
+
+  /// Retrieves no-show and late cancellation rates of the appointments that started
+  /// between [from] and [to] (`YYYY-MM-DD`, inclusive; the current month by default),
+  /// overall and `by_service`, `by_weekday`, `by_employee` and `by_patient_segment`.
+  /// [lateNotice] is how close to the start a cancellation counts as late (e.g. `48h`).
+  /// Admins only.
+  ///
+  /// Example:
+  /// ```dart
+  /// final report = await apiClient.getNoShowReport(from: '2026-01-01', to: '2026-03-31');
+  /// for (var s in report['by_service']) {
+  ///   print('${s['service_name']}: ${s['no_show_percent']}% no-shows');
+  /// }
+  /// ```
+  Future<Map<String, dynamic>> getNoShowReport({
+    String? from,
+    String? to,
+    String? tz,
+    String? lateNotice,
+    int? clinicId,
+  }) async {
+    final query = {
+      if (from != null) 'from': from,
+      if (to != null) 'to': to,
+      if (tz != null) 'tz': tz,
+      if (lateNotice != null) 'late_notice': lateNotice,
+      if (clinicId != null) 'clinic_id': '$clinicId',
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/reports/no-shows').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load no-show report');
+    }
+  }
+
+  /// Retrieves the outcomes and turnaround times of the referrals sent between [from] and
+  /// [to] (`YYYY-MM-DD`, inclusive; the current month by default), overall and `by_urgency`
+  /// and `by_specialty`. Admins only.
+  ///
+  /// Example:
+  /// ```dart
+  /// final report = await apiClient.getReferralReport(from: '2026-01-01', to: '2026-03-31');
+  /// print('Median time to booking: ${report['overall']['median_booking_hours']} hours');
+  /// ```
+  Future<Map<String, dynamic>> getReferralReport({String? from, String? to, String? tz, int? targetClinicId}) async {
+    final query = {
+      if (from != null) 'from': from,
+      if (to != null) 'to': to,
+      if (tz != null) 'tz': tz,
+      if (targetClinicId != null) 'target_clinic_id': '$targetClinicId',
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/reports/referrals').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load referral report');
+    }
+  }
+
+  /// Notification log endpoints
+
+  /// Retrieves the notification delivery log, latest first.
+  ///
+  /// Each entry has the `channel`, `recipient`, `template`, `status` (`PENDING`,
+  /// `SENT` or `FAILED`), the `provider_message_id` once sent and the `last_error`
+  /// and `next_attempt_at` of a failed attempt.
+  ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  ///
+  /// Example:
+  /// ```dart
+  /// // Did appointment 42's reminders go out?
+  /// final sent = await apiClient.getNotifications(appointmentId: 42);
+  /// for (var n in sent) {
+  ///   print('${n['template']} by ${n['channel']}: ${n['status']}');
+  /// }
+  /// ```
+  Future<List<Map<String, dynamic>>> getNotifications({
+    int limit = 50,
+    int offset = 0,
+    int? appointmentId,
+    int? patientId,
+    String? status,
+  }) async {
+    final query = {
+      'limit': '$limit',
+      'offset': '$offset',
+      if (appointmentId != null) 'appointment_id': '$appointmentId',
+      if (patientId != null) 'patient_id': '$patientId',
+      if (status != null) 'status': status,
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/notifications').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load notifications');
+    }
+  }
+
+  /// Queues a failed notification for delivery again.
+  ///
+  /// [id] - The unique identifier of the notification; only `FAILED` ones can be retried.
+  Future<void> retryNotification(int id) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/notifications/$id/retry'),
+      headers: _headers(),
+    );
+    if (response.statusCode != 202) {
+      throw Exception('Failed to retry notification');
+    }
+  }
+
+  /// FHIR endpoints
+
+  /// Searches a FHIR resource type (`Patient`, `Practitioner`, `Appointment`,
+  /// `Schedule` or `Slot`), returning the searchset Bundle.
+  ///
+  /// The resources are in the Bundle's `entry` list, each under `resource`; follow
+  /// the `next` link in `link` for the following page.
+  ///
+  /// Example:
+  /// ```dart
+  /// final bundle = await apiClient.searchFhir('Slot', {
+  ///   'schedule': 'Schedule/3',
+  ///   'service-type': '2',
+  ///   'start': 'ge2025-03-10',
+  /// });
+  /// for (var entry in bundle['entry']) {
+  ///   print(entry['resource']['start']);
+  /// }
+  /// ```
+  Future<Map<String, dynamic>> searchFhir(String resourceType, [Map<String, String> params = const {}]) async {
+    final response = await http.get(
+      Uri.parse('$baseUrl/fhir/$resourceType').replace(queryParameters: params),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to search $resourceType');
+    }
+  }
+
+  /// Reads one FHIR resource by its id: a `public_id` for patients and appointments,
+  /// the employee's id for practitioners and schedules.
+  Future<Map<String, dynamic>> readFhir(String resourceType, String id) async {
+    final response = await http.get(Uri.parse('$baseUrl/fhir/$resourceType/$id'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to load $resourceType');
+    }
+  }
+
+  /// API key endpoints
+
+  /// Retrieves the organization's API keys (admins only). The keys themselves are not included.
+  Future<List<Map<String, dynamic>>> getApiKeys() async {
+    final response = await http.get(Uri.parse('$baseUrl/admin/api-keys'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load API keys');
+    }
+  }
+
+  /// Issues an API key for an integration (admins only).
+  ///
+  /// Required fields: name, scopes (route group to "read" or "write"); optional expires_at.
+  ///
+  /// The response's 'key' is only returned here, so hand it over right away.
+  ///
+  /// Example:
+  /// ```dart
+  /// final key = await apiClient.createApiKey({
+  ///   'name': 'CRM sync',
+  ///   'scopes': {'appointments': 'write', 'patients': 'read'},
+  /// });
+  /// saveKey(key['key']);
+  /// ```
+  Future<Map<String, dynamic>> createApiKey(Map<String, dynamic> apiKey) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/admin/api-keys'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(apiKey),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to create API key');
+    }
+  }
+
+  /// Replaces a key's name, scopes and expiry (admins only); the key itself is kept.
+  Future<Map<String, dynamic>> updateApiKey(int id, Map<String, dynamic> apiKey) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/admin/api-keys/$id'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(apiKey),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update API key');
+    }
+  }
+
+  /// Issues a new key in place of the old one, which stops working at once (admins only).
+  /// The response's 'key' is only returned here.
+  Future<Map<String, dynamic>> rotateApiKey(int id) async {
+    final response = await http.post(Uri.parse('$baseUrl/admin/api-keys/$id/rotate'), headers: _headers());
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to rotate API key');
+    }
+  }
+
+  /// Revokes a key for good (admins only).
+  Future<void> revokeApiKey(int id) async {
+    final response = await http.delete(Uri.parse('$baseUrl/admin/api-keys/$id'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to revoke API key');
+    }
+  }
+
+  /// Webhook endpoints
+
+  /// Retrieves the webhook subscriptions (admins only). Secrets are not included.
+  Future<List<Map<String, dynamic>>> getWebhooks() async {
+    final response = await http.get(Uri.parse('$baseUrl/webhooks'), headers: _headers());
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body));
+    } else {
+      throw Exception('Failed to load webhooks');
+    }
+  }
+
+  /// Subscribes an endpoint to webhook events (admins only).
+  ///
+  /// Required fields: url, events (any of "appointment.created", "appointment.cancelled",
+  /// "patient.created", "waitlist.matched")
+  ///
+  /// The response's 'secret' verifies the X-Webhook-Signature of every delivery and is
+  /// only returned here, so store it right away.
+  ///
+  /// Example:
+  /// ```dart
+  /// final subscription = await apiClient.createWebhook({
+  ///   'url': 'https://example.com/hooks',
+  ///   'events': ['appointment.created', 'appointment.cancelled'],
+  /// });
+  /// saveSecret(subscription['secret']);
+  /// ```
+  Future<Map<String, dynamic>> createWebhook(Map<String, dynamic> subscription) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/webhooks'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(subscription),
+    );
+    if (response.statusCode == 201) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to create webhook');
+    }
+  }
+
+  /// Replaces a subscription's url, events and description (admins only); set 'active'
+  /// to false to pause deliveries. The secret is kept.
+  Future<Map<String, dynamic>> updateWebhook(int id, Map<String, dynamic> subscription) async {
+    final response = await http.put(
+      Uri.parse('$baseUrl/webhooks/$id'),
+      headers: _headers(jsonBody: true),
+      body: json.encode(subscription),
+    );
+    if (response.statusCode == 200) {
+      return json.decode(response.body);
+    } else {
+      throw Exception('Failed to update webhook');
+    }
+  }
+
+  /// Deletes a subscription and its delivery log (admins only).
+  Future<void> deleteWebhook(int id) async {
+    final response = await http.delete(Uri.parse('$baseUrl/webhooks/$id'), headers: _headers());
+    if (response.statusCode != 200) {
+      throw Exception('Failed to delete webhook');
+    }
+  }
+
+  /// Retrieves a subscription's deliveries, latest first, optionally only those with
+  /// [status] "PENDING", "DELIVERED" or "FAILED".
+  ///
+  /// Results are paginated: [limit] items (at most 200) starting at [offset].
+  Future<List<Map<String, dynamic>>> getWebhookDeliveries(
+    int webhookId, {
+    String? status,
+    int limit = 50,
+    int offset = 0,
+  }) async {
+    final query = {
+      'limit': '$limit',
+      'offset': '$offset',
+      if (status != null) 'status': status,
+    };
+    final response = await http.get(
+      Uri.parse('$baseUrl/webhooks/$webhookId/deliveries').replace(queryParameters: query),
+      headers: _headers(),
+    );
+    if (response.statusCode == 200) {
+      return List<Map<String, dynamic>>.from(json.decode(response.body)['data']);
+    } else {
+      throw Exception('Failed to load webhook deliveries');
+    }
+  }
+
+  /// Queues a FAILED delivery again with a fresh set of attempts.
+  Future<void> retryWebhookDelivery(int webhookId, int deliveryId) async {
+    final response = await http.post(
+      Uri.parse('$baseUrl/webhooks/$webhookId/deliveries/$deliveryId/retry'),
+      headers: _headers(),
+    );
+    if (response.statusCode != 202) {
+      throw Exception('Failed to retry webhook delivery');
     }
   }
 }
@@ -0,0 +1,213 @@
+// Medical Appointment Booking System - API Error Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package apierr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"bookings/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Error codes in the response envelope
+const (
+	CodeValidation           = "validation_error"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeGone                 = "gone"
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeUnprocessable        = "unprocessable"
+	CodeUnavailable          = "unavailable"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+)
+
+// RequestIDHeader carries the request id in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is where Middleware stores the request id in the gin context
+const requestIDKey = "request_id"
+
+// Error is an error with the HTTP status and code it is reported with. Handlers attach it
+// with c.Error and return; Middleware writes the response.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details any
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithDetails attaches structured data to the response, e.g. the appointment in the way of a booking
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+// Validation reports a malformed request: bad JSON, parameters or field values (400)
+func Validation(message string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Message: message}
+}
+
+// Unauthorized reports a missing or invalid credential (401)
+func Unauthorized(message string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: message}
+}
+
+// Forbidden reports a caller who may not perform the action (403)
+func Forbidden(message string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: CodeForbidden, Message: message}
+}
+
+// NotFound reports a missing resource (404)
+func NotFound(message string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: message}
+}
+
+// Conflict reports a clash with existing data, such as a double booking (409)
+func Conflict(message string) *Error {
+	return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: message}
+}
+
+// Gone reports a resource that existed but has expired (410)
+func Gone(message string) *Error {
+	return &Error{Status: http.StatusGone, Code: CodeGone, Message: message}
+}
+
+// PreconditionFailed reports an If-Match that names an outdated version of the resource (412)
+func PreconditionFailed(message string) *Error {
+	return &Error{Status: http.StatusPreconditionFailed, Code: CodePreconditionFailed, Message: message}
+}
+
+// PreconditionRequired reports a conditional update sent without If-Match (428)
+func PreconditionRequired(message string) *Error {
+	return &Error{Status: http.StatusPreconditionRequired, Code: CodePreconditionRequired, Message: message}
+}
+
+// Unprocessable reports a well-formed request that breaks a business rule (422)
+func Unprocessable(message string) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Code: CodeUnprocessable, Message: message}
+}
+
+// TooManyRequests reports a client that has used up its rate limit (429)
+func TooManyRequests(message string) *Error {
+	return &Error{Status: http.StatusTooManyRequests, Code: CodeRateLimited, Message: message}
+}
+
+// Unavailable reports a feature that is not configured on this server, or a request that ran
+// out of time (503)
+func Unavailable(message string) *Error {
+	return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: message}
+}
+
+// Abort attaches err and stops the remaining handlers, for middleware that rejects a request
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// envelope is the body of every error response
+type envelope struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id"`
+}
+
+// validRequestID accepts client-supplied ids that are safe to echo back and log
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Middleware gives every request an id (taken from X-Request-ID when the client sends a
+// sensible one), attaches it to the request context for logging, and turns the last error a handler attached into the error envelope.
+// *Error values keep their status; pgx.ErrNoRows becomes a 404; a request or query
+// timeout becomes a 503; anything else is logged and reported as a 500 without its
+// internal message.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		var apiErr *Error
+		switch {
+		case errors.As(err, &apiErr):
+		case errors.Is(err, pgx.ErrNoRows):
+			apiErr = NotFound("Not found")
+		case isTimeout(err):
+			slog.WarnContext(c.Request.Context(), "request timed out", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+			apiErr = Unavailable("The request took too long; try again")
+		default:
+			slog.ErrorContext(c.Request.Context(), "request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+			apiErr = &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "Internal server error"}
+		}
+		c.JSON(apiErr.Status, envelope{Code: apiErr.Code, Message: apiErr.Message, Details: apiErr.Details, RequestID: id})
+	}
+}
+
+// RequestID returns the id Middleware assigned to the request
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// isTimeout reports whether a request's context expired or PostgreSQL cancelled a
+// statement for running past its statement_timeout
+func isTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &pgErr) && pgErr.Code == "57014")
+}
+
+// Lookup reports a failed fetch of a single record: pgx.ErrNoRows becomes NotFound(message),
+// any other error is returned unchanged so it is reported as a 500
+func Lookup(err error, message string) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NotFound(message)
+	}
+	return err
+}
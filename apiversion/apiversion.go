@@ -0,0 +1,111 @@
+// Medical Appointment Booking System - API Version Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package apiversion
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/apierr"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header names sent with versioned responses
+const (
+	VersionHeader     = "X-API-Version"
+	DeprecationHeader = "Deprecation"
+	SunsetHeader      = "Sunset"
+	LinkHeader        = "Link"
+)
+
+// Version is one revision of the API, mounted under /api/<Name>. A breaking change to a
+// response shape ships as a new Version while the old one keeps serving existing clients.
+type Version struct {
+	// Name is the path segment, e.g. "v1"
+	Name string
+	// Register mounts the version's routes on its group
+	Register func(*gin.RouterGroup)
+	// Deprecated is when the version was superseded; zero while it is current
+	Deprecated time.Time
+	// Sunset is when the version stops answering; zero until a date is announced
+	Sunset time.Time
+}
+
+// Mount registers each version under base. Responses name the version that served them, and
+// those of deprecated versions point at the last version in the list as their successor.
+func Mount(base *gin.RouterGroup, versions ...Version) {
+	if len(versions) == 0 {
+		return
+	}
+	latest := versions[len(versions)-1]
+	for _, v := range versions {
+		group := base.Group("/" + v.Name)
+		group.Use(headers(v.Name, group.BasePath(), base.BasePath()+"/"+latest.Name, v.Deprecated, v.Sunset))
+		v.Register(group)
+	}
+}
+
+// MountLegacy serves v at base itself, for clients written before versioning. The routes
+// behave exactly like v's but every response is marked deprecated in favour of v.
+func MountLegacy(base *gin.RouterGroup, v Version, deprecated, sunset time.Time) {
+	group := base.Group("")
+	group.Use(headers(v.Name, base.BasePath(), base.BasePath()+"/"+v.Name, deprecated, sunset))
+	v.Register(group)
+}
+
+// headers tags responses with the version and, once deprecated, the RFC 9745 Deprecation,
+// RFC 8594 Sunset and successor-version Link headers. After the sunset requests get a 410.
+func headers(name, prefix, successor string, deprecated, sunset time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(VersionHeader, name)
+		if deprecated.IsZero() {
+			c.Next()
+			return
+		}
+		successorPath := successor + strings.TrimPrefix(c.Request.URL.Path, prefix)
+		c.Header(DeprecationHeader, "@"+strconv.FormatInt(deprecated.Unix(), 10))
+		c.Header(LinkHeader, fmt.Sprintf("<%s>; rel=\"successor-version\"", successorPath))
+		if !sunset.IsZero() {
+			c.Header(SunsetHeader, sunset.UTC().Format(http.TimeFormat))
+			if !time.Now().Before(sunset) {
+				apierr.Abort(c, apierr.Gone("This API version has been retired; use "+successorPath))
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// LegacySunset reads the LEGACY_API_SUNSET environment variable (YYYY-MM-DD, UTC), the date
+// the unversioned /api paths stop answering. Unset means no date has been announced.
+func LegacySunset() (time.Time, error) {
+	raw := os.Getenv("LEGACY_API_SUNSET")
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := timeutil.ParseDate(raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid LEGACY_API_SUNSET %q", raw)
+	}
+	return t, nil
+}
@@ -0,0 +1,226 @@
+// Medical Appointment Booking System - Audit Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"time"
+
+	"bookings/auth"
+	"bookings/database"
+	"bookings/phi"
+)
+
+// Audited entities, named after their tables
+const (
+	EntityClinics       = "clinics"
+	EntityPatients      = "patients"
+	EntityEmployees     = "employees"
+	EntityServices      = "services"
+	EntityAppointments  = "appointments"
+	EntityWaitingList   = "waiting_list"
+	EntityUsers         = "users"
+	EntityDayOverrides  = "day_overrides"
+	EntityTimeOff       = "time_off"
+	EntitySlotHolds     = "slot_holds"
+	EntityPaymentLinks  = "payment_links"
+	EntityCalendarFeeds = "calendar_feeds"
+	EntityCardPayments  = "card_payments"
+	// EntityEmployeeServices is keyed by employee; its snapshot lists the assigned service ids
+	EntityEmployeeServices = "employee_services"
+	// EntityAppointmentSeries snapshots a series without its occurrences, which are audited as appointments
+	EntityAppointmentSeries = "appointment_series"
+	// Clinic opening hours are audited one window at a time
+	EntityClinicHours    = "clinic_hours"
+	EntityClinicHolidays = "clinic_holidays"
+	// EntityClinicWidgets is keyed by clinic
+	EntityClinicWidgets = "clinic_widgets"
+	// EntityServiceResources is keyed by service and EntityAppointmentResources by appointment;
+	// their snapshots list the resource ids
+	EntityResources            = "resources"
+	EntityServiceResources     = "service_resources"
+	EntityAppointmentResources = "appointment_resources"
+	// EntityWebhooks snapshots never include the signing secret
+	EntityWebhooks = "webhook_subscriptions"
+	// EntityWaitingRoomDisplays snapshots the clinic a display URL was issued for, never its token
+	EntityWaitingRoomDisplays = "waiting_room_displays"
+	// EntityNotificationPreferences is keyed by patient
+	EntityNotificationPreferences = "notification_preferences"
+	// EntityAPIKeys snapshots a key's name, prefix and scopes, never the key
+	EntityAPIKeys = "api_keys"
+	// EntityDocuments snapshots a patient document's metadata, never its filename or description
+	EntityDocuments = "patient_documents"
+	// EntityVisitNotes is keyed by note; amendments are audited as updates of their note
+	EntityVisitNotes    = "visit_notes"
+	EntityPrescriptions = "prescriptions"
+	EntityReferrals     = "referrals"
+	// The patient chart is audited one entry at a time
+	EntityAllergies     = "patient_allergies"
+	EntityMedications   = "patient_medications"
+	EntityConditions    = "patient_conditions"
+	EntityFormTemplates = "form_templates"
+	// EntityFormResponses snapshots a response with its answers redacted like the PHI columns
+	EntityFormResponses    = "form_responses"
+	EntityConsentDocuments = "consent_documents"
+	// EntityPatientConsents is keyed by consent; withdrawals are audited as updates
+	EntityPatientConsents = "patient_consents"
+	// EntityPatientRelationships is keyed by relationship; unlinking is audited as a delete
+	EntityPatientRelationships = "patient_relationships"
+)
+
+// Entities lists every audited entity
+var Entities = []string{
+	EntityClinics, EntityPatients, EntityEmployees, EntityServices, EntityAppointments, EntityWaitingList, EntityUsers,
+	EntityDayOverrides, EntityTimeOff, EntitySlotHolds, EntityPaymentLinks, EntityCalendarFeeds, EntityEmployeeServices,
+	EntityCardPayments, EntityAppointmentSeries, EntityClinicHours, EntityClinicHolidays, EntityClinicWidgets,
+	EntityResources, EntityServiceResources, EntityAppointmentResources, EntityWebhooks,
+	EntityWaitingRoomDisplays, EntityNotificationPreferences, EntityAPIKeys, EntityDocuments,
+	EntityVisitNotes, EntityPrescriptions, EntityReferrals, EntityAllergies, EntityMedications, EntityConditions,
+	EntityFormTemplates, EntityFormResponses, EntityConsentDocuments, EntityPatientConsents, EntityPatientRelationships,
+}
+
+// Audit actions
+const (
+	ActionCreate  = "CREATE"
+	ActionUpdate  = "UPDATE"
+	ActionDelete  = "DELETE"
+	ActionRestore = "RESTORE"
+	// ActionErase records a patient's personal details being erased on request
+	ActionErase = "ERASE"
+	// ActionExport records everything held about a patient being handed over on request
+	ActionExport = "EXPORT"
+	// ActionMerge records a duplicate patient being merged into another, on both patients
+	ActionMerge = "MERGE"
+)
+
+// FieldChange is one field whose value differs between two points in time
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// Record stores a snapshot of an entity after a mutation together with the fields it changed
+// since the previous entry and the user or API key whose request made it; ctx carries
+// neither for background jobs. Call it with the context of the database.WithTx that makes
+// the change and return its error from there, so the entry and the domain event it queues
+// are committed with the change or not at all.
+func Record(ctx context.Context, entity string, entityID int, action string, snapshot any) error {
+	var data []byte
+	var after map[string]any
+	if snapshot != nil {
+		var err error
+		if data, err = json.Marshal(snapshot); err != nil {
+			return fmt.Errorf("audit: encoding %s %d snapshot: %w", entity, entityID, err)
+		}
+		if err := json.Unmarshal(data, &after); err != nil {
+			return fmt.Errorf("audit: encoding %s %d snapshot: %w", entity, entityID, err)
+		}
+		if redactPHI(after) {
+			if data, err = json.Marshal(after); err != nil {
+				return fmt.Errorf("audit: encoding %s %d snapshot: %w", entity, entityID, err)
+			}
+		}
+	}
+	before, err := StateAt(ctx, entity, entityID, time.Now())
+	if err != nil {
+		return fmt.Errorf("audit: loading previous state of %s %d: %w", entity, entityID, err)
+	}
+	changes, err := json.Marshal(Diff(before, after))
+	if err != nil {
+		return fmt.Errorf("audit: encoding %s %d changes: %w", entity, entityID, err)
+	}
+
+	var actor, apiKey *int
+	if id, ok := auth.UserIDFromContext(ctx); ok {
+		actor = &id
+	}
+	if id, ok := auth.APIKeyIDFromContext(ctx); ok {
+		apiKey = &id
+	}
+	if err := database.InsertAuditEntry(ctx, entity, entityID, action, actor, apiKey, data, changes); err != nil {
+		return fmt.Errorf("audit: recording %s of %s %d: %w", action, entity, entityID, err)
+	}
+	return nil
+}
+
+// redactPHI replaces the values of encrypted fields with keyed hashes, so the audit log
+// holds no plaintext PHI but still shows when a value changed. Structured values, such as
+// form answers, are hashed as their JSON. It reports whether any field was replaced.
+func redactPHI(state map[string]any) bool {
+	redacted := false
+	for _, field := range phi.Fields {
+		value, ok := state[field].(string)
+		if !ok && state[field] != nil {
+			// decoded from JSON, so it always encodes again
+			encoded, _ := json.Marshal(state[field])
+			value, ok = string(encoded), true
+		}
+		if !ok || value == "" {
+			continue
+		}
+		index, err := phi.Index(value)
+		if err != nil {
+			index = "unavailable"
+		}
+		state[field] = "redacted:" + index
+		redacted = true
+	}
+	return redacted
+}
+
+// StateAt returns the entity's recorded fields as of the given time, or nil if it did
+// not exist then
+func StateAt(ctx context.Context, entity string, entityID int, at time.Time) (map[string]any, error) {
+	action, snapshot, found, err := database.GetAuditSnapshotAt(ctx, entity, entityID, at)
+	if err != nil || !found || action == ActionDelete || snapshot == nil {
+		return nil, err
+	}
+	var state map[string]any
+	if err := json.Unmarshal(snapshot, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Diff computes the field-level changes between two states, ordered by field name.
+// A nil state means the entity did not exist, so every field of the other side changes.
+func Diff(from, to map[string]any) []FieldChange {
+	fields := make([]string, 0, len(from)+len(to))
+	for field := range from {
+		fields = append(fields, field)
+	}
+	for field := range to {
+		if _, ok := from[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		before, after := from[field], to[field]
+		if !reflect.DeepEqual(before, after) {
+			changes = append(changes, FieldChange{Field: field, From: before, To: after})
+		}
+	}
+	return changes
+}
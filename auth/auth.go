@@ -0,0 +1,159 @@
+// Medical Appointment Booking System - Auth Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"bookings/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Token lifetimes. Access tokens are short-lived JWTs; refresh tokens are opaque, stored
+// hashed and rotated on every use.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// minSecretLength is the shortest JWT_SECRET accepted, in bytes
+const minSecretLength = 32
+
+// ErrInvalidToken is returned for access tokens that are malformed, expired or wrongly signed
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims are the contents of an access token
+type Claims struct {
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	PatientID *int   `json:"patient_id,omitempty"`
+	// OrganizationID is the tenant the user belongs to
+	OrganizationID int `json:"org_id,omitempty"`
+	// APIKeyID and Scopes are set instead of a user and role when the caller authenticated
+	// with an API key; they never appear in a token
+	APIKeyID int               `json:"-"`
+	Scopes   map[string]string `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// UserID returns the id of the user the token was issued to
+func (c *Claims) UserID() int {
+	id, _ := strconv.Atoi(c.Subject)
+	return id
+}
+
+// secret returns the JWT signing key from the JWT_SECRET environment variable
+func secret() []byte {
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// CheckConfig reports whether JWT_SECRET is set to a usable key
+func CheckConfig() error {
+	if n := len(secret()); n < minSecretLength {
+		return fmt.Errorf("JWT_SECRET must be at least %d bytes (got %d)", minSecretLength, n)
+	}
+	return nil
+}
+
+// HashPassword hashes a password with bcrypt
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches a bcrypt hash
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// IssueAccessToken signs an access token for the user
+func IssueAccessToken(user *models.User, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(AccessTokenTTL)
+	claims := Claims{
+		Email:          user.Email,
+		Role:           user.Role,
+		PatientID:      user.PatientID,
+		OrganizationID: user.OrganizationID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ParseAccessToken verifies an access token's signature and expiry and returns its claims
+func ParseAccessToken(token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		return secret(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// NewRefreshToken generates a refresh token, returning it with the hash to store
+func NewRefreshToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
+}
+
+// APIKeyPrefix starts every API key, telling it apart from an access token
+const APIKeyPrefix = "bk_"
+
+// apiKeyShownLength is how much of a key is kept in the clear to identify it
+const apiKeyShownLength = len(APIKeyPrefix) + 8
+
+// NewAPIKey generates an API key, returning it with the prefix shown to identify it and the
+// hash to store
+func NewAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + hex.EncodeToString(b)
+	return key, key[:apiKeyShownLength], HashRefreshToken(key), nil
+}
+
+// HashRefreshToken returns the stored form of a refresh token
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,50 @@
+// Medical Appointment Booking System - Auth Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"bookings/database"
+	"bookings/models"
+)
+
+// Bootstrap creates the first admin account from BOOTSTRAP_ADMIN_EMAIL and BOOTSTRAP_ADMIN_PASSWORD
+// when the users table is empty, so a fresh deployment has someone who can log in
+func Bootstrap(ctx context.Context) error {
+	email, password := os.Getenv("BOOTSTRAP_ADMIN_EMAIL"), os.Getenv("BOOTSTRAP_ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return nil
+	}
+	n, err := database.CountUsers(ctx)
+	if err != nil || n > 0 {
+		return err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user := models.User{Email: email, PasswordHash: hash, Role: RoleAdmin, Active: true}
+	if err := database.CreateUser(ctx, &user); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "created bootstrap user", "email", email)
+	return nil
+}
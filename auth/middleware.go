@@ -0,0 +1,127 @@
+// Medical Appointment Booking System - Auth Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"bookings/apierr"
+	"bookings/database"
+	"bookings/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// claimsKey is where Middleware stores the caller's claims on the Gin context
+const claimsKey = "auth.claims"
+
+// claimsContextKey is where Middleware stores the caller's claims on the request context, for
+// code below the handlers that only sees a context.Context
+type claimsContextKey struct{}
+
+// Middleware rejects requests without a valid "Authorization: Bearer <access token>" header.
+// Browsers cannot set headers when opening a WebSocket, so a WebSocket handshake may pass
+// the token as the access_token query parameter instead. An API key is accepted in place of
+// the access token. The request then works for the caller's organization; a token for
+// another organization than the subdomain the request was made to answers 403.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if header == "" && strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			token, ok = c.Query("access_token"), true
+		}
+		if !ok || token == "" {
+			apierr.Abort(c, apierr.Unauthorized("Authentication required"))
+			return
+		}
+		var claims *Claims
+		var err error
+		if strings.HasPrefix(token, APIKeyPrefix) {
+			claims, err = apiKeyClaims(c.Request.Context(), token)
+		} else {
+			claims, err = ParseAccessToken(token)
+		}
+		if errors.Is(err, ErrInvalidToken) || errors.Is(err, database.ErrAPIKeyInvalid) {
+			apierr.Abort(c, apierr.Unauthorized(err.Error()))
+			return
+		}
+		if err != nil {
+			apierr.Abort(c, err)
+			return
+		}
+		ctx := c.Request.Context()
+		if claims.OrganizationID != 0 && claims.OrganizationID != database.OrganizationFromContext(ctx) {
+			if tenant.FromSubdomain(ctx) {
+				apierr.Abort(c, apierr.Forbidden("Signed in to another organization"))
+				return
+			}
+			ctx = database.WithOrganization(ctx, claims.OrganizationID)
+		}
+		c.Set(claimsKey, claims)
+		c.Request = c.Request.WithContext(context.WithValue(ctx, claimsContextKey{}, claims))
+		c.Next()
+	}
+}
+
+// apiKeyClaims authenticates an API key, giving it its key's organization and scopes and no
+// role, so routes restricted by role stay closed to it
+func apiKeyClaims(ctx context.Context, key string) (*Claims, error) {
+	apiKey, err := database.UseAPIKey(ctx, HashRefreshToken(key))
+	if err != nil {
+		return nil, err
+	}
+	return &Claims{OrganizationID: apiKey.OrganizationID, APIKeyID: apiKey.ID, Scopes: apiKey.Scopes}, nil
+}
+
+// UserIDFromContext returns the id of the authenticated user a request context belongs to;
+// false for contexts outside an authenticated request, such as background jobs, and for
+// requests made with an API key
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	if !ok || claims.APIKeyID != 0 {
+		return 0, false
+	}
+	return claims.UserID(), true
+}
+
+// APIKeyIDFromContext returns the id of the API key a request context was authenticated with
+func APIKeyIDFromContext(ctx context.Context) (int, bool) {
+	claims, ok := ctx.Value(claimsContextKey{}).(*Claims)
+	if !ok || claims.APIKeyID == 0 {
+		return 0, false
+	}
+	return claims.APIKeyID, true
+}
+
+// CurrentUser returns the claims of the authenticated caller
+func CurrentUser(c *gin.Context) (*Claims, bool) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
+
+// OrganizationID returns the organization the request works for, which every record it
+// reads or writes belongs to: the caller's, or the subdomain's before authentication
+func OrganizationID(c *gin.Context) int {
+	return database.OrganizationFromContext(c.Request.Context())
+}
@@ -0,0 +1,236 @@
+// Medical Appointment Booking System - Auth Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package auth
+
+import (
+	"net/http"
+	"slices"
+
+	"bookings/apierr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles, matching the user_role enum
+const (
+	RoleAdmin        = "ADMIN"
+	RoleClinician    = "CLINICIAN"
+	RoleReceptionist = "RECEPTIONIST"
+	RolePatient      = "PATIENT"
+)
+
+// Access is the kind of operation a request performs: GET reads, DELETE deletes and every
+// other method writes
+type Access int
+
+const (
+	Read Access = iota
+	Write
+	Delete
+)
+
+// Route groups covered by the permission matrix
+const (
+	Clinics      = "clinics"
+	Patients     = "patients"
+	Employees    = "employees"
+	Services     = "services"
+	Appointments = "appointments"
+	WaitingList  = "waiting-list"
+	PaymentLinks = "payment-links"
+	Payments     = "payments"
+	Scheduling   = "scheduling"
+	SlotHolds    = "slot-holds"
+	TimeOff      = "time-off"
+	AuditLog     = "audit-log"
+	AccessLog    = "access-log"
+	Users        = "users"
+	Resources    = "resources"
+	Webhooks     = "webhooks"
+	// Notifications is the delivery log of emails and SMS
+	Notifications = "notifications"
+	Reports       = "reports"
+	// APIKeys is the management of API keys, never open to a key itself
+	APIKeys = "api-keys"
+	// Documents are the files attached to patients
+	Documents = "documents"
+	// VisitNotes are clinicians' structured notes of visits, never open to an API key
+	VisitNotes = "visit-notes"
+	// Prescriptions are medications prescribed at completed appointments
+	Prescriptions = "prescriptions"
+	// Referrals send patients to other employees, clinics or specialties
+	Referrals = "referrals"
+	// Chart is the patient's allergies, medications and conditions
+	Chart = "chart"
+	// Forms are the intake form templates patients answer before their appointments
+	Forms = "forms"
+	// FormResponses are the patients' answers to intake forms
+	FormResponses = "form-responses"
+	// Consents are the consent documents and the patients' agreements to them
+	Consents = "consents"
+	// Retention is the data retention policies and the report of what they archived or purged
+	Retention = "retention"
+)
+
+// API key scopes: read allows GET, write allows every method
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// ScopeGroups are the route groups an API key may be scoped to. Keys cannot manage users or
+// other keys, so a leaked one cannot grant itself more, nor touch visit notes, which are
+// written and signed by a clinician in person.
+func ScopeGroups() []string {
+	groups := make([]string, 0, len(permissions))
+	for group := range permissions {
+		if !userOnly(group) {
+			groups = append(groups, group)
+		}
+	}
+	slices.Sort(groups)
+	return groups
+}
+
+var (
+	staff       = []string{RoleAdmin, RoleClinician, RoleReceptionist}
+	frontDesk   = []string{RoleAdmin, RoleReceptionist}
+	clinicians  = []string{RoleClinician}
+	adminsOnly  = []string{RoleAdmin}
+	adminAccess = map[Access][]string{Read: adminsOnly, Write: adminsOnly, Delete: adminsOnly}
+)
+
+// permissions is the permission matrix: the roles allowed each kind of access to each route
+// group. Patients have no access to staff routes at all.
+var permissions = map[string]map[Access][]string{
+	Clinics:      {Read: staff, Write: adminsOnly, Delete: adminsOnly},
+	Patients:     {Read: staff, Write: staff, Delete: adminsOnly},
+	Employees:    {Read: staff, Write: adminsOnly, Delete: adminsOnly},
+	Services:     {Read: staff, Write: adminsOnly, Delete: adminsOnly},
+	Appointments: {Read: staff, Write: staff, Delete: frontDesk},
+	WaitingList:  {Read: staff, Write: staff, Delete: frontDesk},
+	PaymentLinks: {Read: frontDesk, Write: frontDesk},
+	Payments:     {Read: frontDesk, Write: frontDesk},
+	Scheduling:   {Read: staff, Write: frontDesk, Delete: frontDesk},
+	SlotHolds:    {Read: staff, Write: staff, Delete: staff},
+	TimeOff:      {Read: staff, Write: staff},
+	AuditLog:     adminAccess,
+	AccessLog:    adminAccess,
+	Users:        adminAccess,
+	Resources:    {Read: staff, Write: adminsOnly, Delete: adminsOnly},
+	Webhooks:     adminAccess,
+	// Staff can check whether a message went out; the front desk can send a failed one again
+	Notifications: {Read: staff, Write: frontDesk},
+	Reports:       {Read: staff},
+	APIKeys:       adminAccess,
+	// Staff may delete documents; the handler keeps that to their own recent uploads unless admin
+	Documents: {Read: staff, Write: staff, Delete: staff},
+	// Notes are read and written by clinicians only, like medical_notes
+	VisitNotes: {Read: clinicians, Write: clinicians},
+	// Any staff member can look up and print a prescription; only clinicians write or cancel one
+	Prescriptions: {Read: staff, Write: clinicians},
+	Referrals:     {Read: staff, Write: staff},
+	// Anyone at the clinic can check a patient's allergies; clinicians keep the chart
+	Chart: {Read: staff, Write: clinicians, Delete: clinicians},
+	Forms: {Read: staff, Write: adminsOnly, Delete: adminsOnly},
+	// Patients answer forms in the portal; at the clinic the answers are for clinicians
+	FormResponses: {Read: clinicians},
+	// Staff record consent given at the desk; publishing a document version is for admins
+	Consents: {Read: staff, Write: staff},
+	// Policies come from the server configuration; the endpoints only report on them
+	Retention: adminAccess,
+}
+
+// Allowed reports whether the permission matrix lets role perform access on a route group
+func Allowed(role, group string, access Access) bool {
+	return slices.Contains(permissions[group][access], role)
+}
+
+// allowed checks the permission matrix for a user, and the key's scopes for an API key
+func (c *Claims) allowed(group string, access Access) bool {
+	if c.APIKeyID == 0 {
+		return Allowed(c.Role, group, access)
+	}
+	if userOnly(group) {
+		return false
+	}
+	switch c.Scopes[group] {
+	case ScopeWrite:
+		return true
+	case ScopeRead:
+		return access == Read
+	}
+	return false
+}
+
+// userOnly reports whether a route group is closed to API keys whatever their scopes
+func userOnly(group string) bool {
+	return group == Users || group == APIKeys || group == VisitNotes
+}
+
+func accessFor(method string) Access {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return Read
+	case http.MethodDelete:
+		return Delete
+	default:
+		return Write
+	}
+}
+
+// Authorize enforces the permission matrix for a route group. It must run after Middleware.
+func Authorize(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := CurrentUser(c)
+		if !ok || !claims.allowed(group, accessFor(c.Request.Method)) {
+			forbid(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole restricts routes to the given roles whatever the method, for actions the
+// matrix's read/write/delete split does not capture
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasRole(c, roles...) {
+			forbid(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// HasRole reports whether the authenticated caller has one of the roles, for checks on
+// individual fields inside a handler
+func HasRole(c *gin.Context, roles ...string) bool {
+	claims, ok := CurrentUser(c)
+	return ok && slices.Contains(roles, claims.Role)
+}
+
+// CanRead reports whether the authenticated caller may read a route group, for records of
+// one group included in the responses of another
+func CanRead(c *gin.Context, group string) bool {
+	claims, ok := CurrentUser(c)
+	return ok && claims.allowed(group, Read)
+}
+
+func forbid(c *gin.Context) {
+	apierr.Abort(c, apierr.Forbidden("Your role does not permit this action"))
+}
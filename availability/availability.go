@@ -0,0 +1,242 @@
+// Medical Appointment Booking System - Availability Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package availability
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"bookings/database"
+	"bookings/models"
+	"bookings/timeutil"
+)
+
+// Interval is a half-open span of time [Start, End)
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Duration returns the length of the interval
+func (i Interval) Duration() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+// Location returns the employee's timezone, falling back to UTC when it is unset or unknown
+func Location(employee *models.Employee) *time.Location {
+	return timeutil.LoadLocation(employee.Timezone)
+}
+
+// WorkingWindows returns the intervals (in UTC) the employee works on a local calendar
+// date: the weekly templates, replaced by a day override when one exists, limited to the
+// clinic's opening hours, minus approved time off.
+// Overnight windows starting on the date run past local midnight; the part of the previous
+// night's shift that spills into this date belongs to the previous date.
+func WorkingWindows(ctx context.Context, employee *models.Employee, date time.Time) ([]Interval, error) {
+	override, err := database.GetDayOverride(ctx, employee.ID, date.Format(timeutil.DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	return WindowsWithOverride(ctx, employee, date, override)
+}
+
+// WindowsWithOverride is WorkingWindows with the given override (nil for none) in place of
+// the stored one, so a proposed override can be checked before it is saved
+func WindowsWithOverride(ctx context.Context, employee *models.Employee, date time.Time, override *models.DayOverride) ([]Interval, error) {
+	loc := Location(employee)
+
+	var windows []Interval
+	switch {
+	case override != nil && override.IsClosed:
+		return nil, nil
+	case override != nil && override.StartTime != nil && override.EndTime != nil:
+		w, err := localWindow(date, *override.StartTime, *override.EndTime, loc)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	default:
+		templates, err := database.GetWorkTemplates(ctx, employee.ID, timeutil.ISOWeekday(date))
+		if err != nil {
+			return nil, err
+		}
+		if windows, err = TemplateWindows(templates, date, loc); err != nil {
+			return nil, err
+		}
+	}
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	open, err := ClinicWindows(ctx, employee.ClinicID, date, loc)
+	if err != nil {
+		return nil, err
+	}
+	if windows = Intersect(windows, open); len(windows) == 0 {
+		return nil, nil
+	}
+	timeOff, err := database.GetApprovedTimeOff(ctx, employee.ID, windows[0].Start, windows[len(windows)-1].End)
+	if err != nil {
+		return nil, err
+	}
+	busy := make([]Interval, 0, len(timeOff))
+	for _, t := range timeOff {
+		busy = append(busy, Interval{Start: t.StartDatetime, End: t.EndDatetime})
+	}
+	return Subtract(windows, busy), nil
+}
+
+// Outside returns the appointments that do not fit entirely inside one of the windows
+func Outside(appointments []models.Appointment, windows []Interval) []models.Appointment {
+	var outside []models.Appointment
+	for _, a := range appointments {
+		fits := false
+		for _, w := range windows {
+			if !a.StartDatetime.Before(w.Start) && !a.EndDatetime.After(w.End) {
+				fits = true
+				break
+			}
+		}
+		if !fits {
+			outside = append(outside, a)
+		}
+	}
+	return outside
+}
+
+// BookingIntervals converts appointments into the intervals they occupy
+func BookingIntervals(appointments []models.Appointment) []Interval {
+	busy := make([]Interval, 0, len(appointments))
+	for _, a := range appointments {
+		busy = append(busy, Interval{Start: a.StartDatetime.UTC(), End: a.EndDatetime.UTC()})
+	}
+	return busy
+}
+
+// Merge sorts intervals and joins the ones that overlap or touch
+func Merge(intervals []Interval) []Interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+	sorted := append([]Interval(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	merged := []Interval{sorted[0]}
+	for _, next := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if !next.Start.After(last.End) {
+			if next.End.After(last.End) {
+				last.End = next.End
+			}
+			continue
+		}
+		merged = append(merged, next)
+	}
+	return merged
+}
+
+// Subtract removes every busy interval from the given windows
+func Subtract(windows, busy []Interval) []Interval {
+	busy = Merge(busy)
+	var free []Interval
+	for _, w := range Merge(windows) {
+		cursor := w.Start
+		for _, b := range busy {
+			if !b.End.After(cursor) || !b.Start.Before(w.End) {
+				continue
+			}
+			if b.Start.After(cursor) {
+				free = append(free, Interval{Start: cursor, End: b.Start})
+			}
+			if b.End.After(cursor) {
+				cursor = b.End
+			}
+		}
+		if cursor.Before(w.End) {
+			free = append(free, Interval{Start: cursor, End: w.End})
+		}
+	}
+	return free
+}
+
+// Intersect returns the parts of the windows that also lie inside one of the other intervals
+func Intersect(windows, other []Interval) []Interval {
+	windows, other = Merge(windows), Merge(other)
+	var both []Interval
+	for i, j := 0, 0; i < len(windows) && j < len(other); {
+		start, end := windows[i].Start, windows[i].End
+		if other[j].Start.After(start) {
+			start = other[j].Start
+		}
+		if other[j].End.Before(end) {
+			end = other[j].End
+		}
+		if start.Before(end) {
+			both = append(both, Interval{Start: start, End: end})
+		}
+		if windows[i].End.Before(other[j].End) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return both
+}
+
+// TemplateWindows expands weekly work templates into the intervals (in UTC) they cover on a
+// local calendar date, leaving out templates for other weekdays and inactive ones. Each
+// window is worked out from its wall-clock times on that very date, never by shifting
+// another day's window by whole days, so a 09:00 start stays at 09:00 local time on both
+// sides of a DST change. A window that runs through the change is an hour shorter or
+// longer, and a time the change skips is read as the same time after it (see
+// timeutil.WallClock).
+func TemplateWindows(templates []models.WorkTemplate, date time.Time, loc *time.Location) ([]Interval, error) {
+	weekday := timeutil.ISOWeekday(date)
+	var windows []Interval
+	for _, t := range templates {
+		if t.Weekday != weekday || !t.IsActive || t.StartTime == nil || t.EndTime == nil {
+			continue
+		}
+		w, err := localWindow(date, *t.StartTime, *t.EndTime, loc)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// localWindow builds a UTC interval from "HH:MM" wall-clock times on a local date. An end
+// at or before the start is an overnight window (e.g. a 20:00-08:00 sleep lab shift) and
+// finishes on the following day.
+func localWindow(date time.Time, start, end string, loc *time.Location) (Interval, error) {
+	s, err := timeutil.WallClockString(date, start, loc)
+	if err != nil {
+		return Interval{}, err
+	}
+	e, err := timeutil.WallClockString(date, end, loc)
+	if err != nil {
+		return Interval{}, err
+	}
+	if !e.After(s) {
+		if e, err = timeutil.WallClockString(date.AddDate(0, 0, 1), end, loc); err != nil {
+			return Interval{}, err
+		}
+	}
+	return Interval{Start: s.UTC(), End: e.UTC()}, nil
+}
@@ -0,0 +1,67 @@
+// Medical Appointment Booking System - Availability Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package availability
+
+import (
+	"context"
+	"time"
+
+	"bookings/database"
+	"bookings/timeutil"
+)
+
+// ClinicWindows returns the intervals (in UTC) a clinic is open from the local day before a
+// date through the day after, so windows running across midnight on either side are covered.
+// A clinic without opening hours is open all day; a holiday closes its whole date.
+func ClinicWindows(ctx context.Context, clinicID int, date time.Time, loc *time.Location) ([]Interval, error) {
+	hours, err := database.ListClinicHours(ctx, clinicID)
+	if err != nil {
+		return nil, err
+	}
+	first, last := date.AddDate(0, 0, -1), date.AddDate(0, 0, 1)
+	holidays, err := database.ListClinicHolidays(ctx, clinicID, first.Format(timeutil.DateLayout), last.Format(timeutil.DateLayout))
+	if err != nil {
+		return nil, err
+	}
+	closed := make(map[string]bool, len(holidays))
+	for _, h := range holidays {
+		closed[h.Date] = true
+	}
+
+	var open []Interval
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if closed[day.Format(timeutil.DateLayout)] {
+			continue
+		}
+		if len(hours) == 0 {
+			start, end := timeutil.DayBounds(day, loc)
+			open = append(open, Interval{Start: start, End: end})
+			continue
+		}
+		for _, h := range hours {
+			if h.Weekday != timeutil.ISOWeekday(day) {
+				continue
+			}
+			w, err := localWindow(day, h.OpenTime, h.CloseTime, loc)
+			if err != nil {
+				return nil, err
+			}
+			open = append(open, w)
+		}
+	}
+	return Merge(open), nil
+}
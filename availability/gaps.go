@@ -0,0 +1,120 @@
+// Medical Appointment Booking System - Availability Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package availability
+
+import (
+	"fmt"
+	"time"
+
+	"bookings/models"
+)
+
+// Gap suggestion actions
+const (
+	ActionOfferWaitlist  = "OFFER_WAITLIST"
+	ActionExtendPrevious = "EXTEND_PREVIOUS"
+	ActionExtendNext     = "EXTEND_NEXT"
+)
+
+// DefaultStandardDuration is used when an employee offers no services
+const DefaultStandardDuration = 30 * time.Minute
+
+// maxWaitlistSuggestions caps how many waiting list patients are suggested per gap
+const maxWaitlistSuggestions = 3
+
+// Gap is a free stretch of working time too short for a standard service
+type Gap struct {
+	Interval
+	Minutes     int          `json:"minutes"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+// Suggestion is one way to make use of a gap
+type Suggestion struct {
+	Action        string `json:"action"`
+	Description   string `json:"description"`
+	AppointmentID *int   `json:"appointment_id,omitempty"`
+	WaitingListID *int   `json:"waiting_list_id,omitempty"`
+	PatientID     *int   `json:"patient_id,omitempty"`
+	ServiceID     *int   `json:"service_id,omitempty"`
+	Minutes       int    `json:"minutes"`
+}
+
+// StandardDuration returns the median of the given service durations (in minutes)
+func StandardDuration(durations []int) time.Duration {
+	if len(durations) == 0 {
+		return DefaultStandardDuration
+	}
+	return time.Duration(durations[len(durations)/2]) * time.Minute
+}
+
+// FindGaps lists the free intervals inside the working windows that are shorter than
+// the standard duration, with suggestions to fill them from the waiting list or by
+// extending the neighbouring appointments. Candidates are expected most urgent first.
+func FindGaps(windows []Interval, bookings []models.Appointment, standard time.Duration, candidates []models.WaitingListCandidate) []Gap {
+	gaps := []Gap{}
+	for _, free := range Subtract(windows, BookingIntervals(bookings)) {
+		if free.Duration() >= standard {
+			continue
+		}
+		gap := Gap{Interval: free, Minutes: int(free.Duration().Minutes()), Suggestions: []Suggestion{}}
+
+		offered := 0
+		for _, c := range candidates {
+			if offered == maxWaitlistSuggestions {
+				break
+			}
+			if c.DurationMinutes > gap.Minutes {
+				continue
+			}
+			gap.Suggestions = append(gap.Suggestions, Suggestion{
+				Action:        ActionOfferWaitlist,
+				Description:   fmt.Sprintf("Offer the slot to waiting list patient %d (%s urgency, %d-minute service)", c.PatientID, c.UrgencyLevel, c.DurationMinutes),
+				WaitingListID: intPtr(c.WaitingListID),
+				PatientID:     intPtr(c.PatientID),
+				ServiceID:     intPtr(c.ServiceID),
+				Minutes:       c.DurationMinutes,
+			})
+			offered++
+		}
+
+		for _, b := range bookings {
+			switch {
+			case b.EndDatetime.Equal(free.Start):
+				gap.Suggestions = append(gap.Suggestions, Suggestion{
+					Action:        ActionExtendPrevious,
+					Description:   fmt.Sprintf("Extend appointment %d by %d minutes", b.ID, gap.Minutes),
+					AppointmentID: intPtr(b.ID),
+					Minutes:       gap.Minutes,
+				})
+			case b.StartDatetime.Equal(free.End):
+				gap.Suggestions = append(gap.Suggestions, Suggestion{
+					Action:        ActionExtendNext,
+					Description:   fmt.Sprintf("Start appointment %d %d minutes earlier", b.ID, gap.Minutes),
+					AppointmentID: intPtr(b.ID),
+					Minutes:       gap.Minutes,
+				})
+			}
+		}
+		gaps = append(gaps, gap)
+	}
+	return gaps
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,211 @@
+// Medical Appointment Booking System - Availability Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package availability
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bookings/models"
+	"bookings/timeutil"
+)
+
+// RuleViolation explains why a start time cannot be booked
+type RuleViolation struct {
+	Message string
+}
+
+func (v *RuleViolation) Error() string {
+	return v.Message
+}
+
+// CheckBookingWindow enforces the minimum lead times and maximum advance windows of the
+// service and the clinic (nil when it does not apply), and the service's same-day cutoff,
+// for a booking starting at start, made at now. The cutoff hour and the advance window's
+// calendar days are evaluated in loc, the employee's timezone.
+func CheckBookingWindow(service *models.Service, clinic *models.Clinic, start, now time.Time, loc *time.Location) error {
+	if !start.After(now) {
+		return &RuleViolation{Message: "appointments cannot start in the past"}
+	}
+
+	if lead := time.Duration(service.MinLeadMinutes) * time.Minute; start.Sub(now) < lead {
+		return &RuleViolation{Message: fmt.Sprintf("%s must be booked at least %s in advance", service.Name, FormatLead(lead))}
+	}
+	if clinic != nil {
+		if lead := time.Duration(clinic.MinLeadMinutes) * time.Minute; start.Sub(now) < lead {
+			return &RuleViolation{Message: fmt.Sprintf("appointments at %s must be booked at least %s in advance", clinic.Name, FormatLead(lead))}
+		}
+	}
+
+	// The last bookable date is counted in whole local days, so "90 days out" includes all of the 90th day
+	days := timeutil.LocalDate(start, loc).Sub(timeutil.LocalDate(now, loc)) / (24 * time.Hour)
+	if service.MaxAdvanceDays != nil && int(days) > *service.MaxAdvanceDays {
+		return &RuleViolation{Message: fmt.Sprintf("%s cannot be booked more than %d days in advance", service.Name, *service.MaxAdvanceDays)}
+	}
+	if clinic != nil && clinic.MaxAdvanceDays != nil && int(days) > *clinic.MaxAdvanceDays {
+		return &RuleViolation{Message: fmt.Sprintf("appointments at %s cannot be booked more than %d days in advance", clinic.Name, *clinic.MaxAdvanceDays)}
+	}
+
+	if service.SameDayCutoffHour != nil {
+		localStart, localNow := start.In(loc), now.In(loc)
+		sameDay := localStart.Year() == localNow.Year() && localStart.YearDay() == localNow.YearDay()
+		if sameDay && localNow.Hour() >= *service.SameDayCutoffHour {
+			if *service.SameDayCutoffHour == 0 {
+				return &RuleViolation{Message: fmt.Sprintf("%s cannot be booked for the same day", service.Name)}
+			}
+			return &RuleViolation{Message: fmt.Sprintf("same-day bookings for %s close at %02d:00", service.Name, *service.SameDayCutoffHour)}
+		}
+	}
+	return nil
+}
+
+// AdultAge is the age from which patients are adults: parents and legal guardians act for
+// their dependents until then
+const AdultAge = 18
+
+// CheckPatientAge enforces the service's age limits for a patient born on dateOfBirth (nil
+// when unknown) booked to start at start. The age is taken on the local date in loc, the
+// employee's timezone; a patient whose date of birth is unknown cannot book a service with
+// limits.
+func CheckPatientAge(service *models.Service, dateOfBirth *string, start time.Time, loc *time.Location) error {
+	if service.MinAgeYears == nil && service.MaxAgeYears == nil {
+		return nil
+	}
+	if dateOfBirth == nil {
+		return &RuleViolation{Message: fmt.Sprintf("%s can only be booked once the patient's date of birth is known", service.Name)}
+	}
+	age, err := timeutil.AgeOn(*dateOfBirth, timeutil.LocalDate(start, loc))
+	if err != nil {
+		return err
+	}
+	switch {
+	case service.MinAgeYears != nil && age < *service.MinAgeYears:
+		return &RuleViolation{Message: fmt.Sprintf("%s is for patients aged %d and over", service.Name, *service.MinAgeYears)}
+	case service.MaxAgeYears != nil && age > *service.MaxAgeYears:
+		return &RuleViolation{Message: fmt.Sprintf("%s is for patients aged up to %d", service.Name, *service.MaxAgeYears)}
+	}
+	return nil
+}
+
+// MaxBookingSpan caps how long a single multi-day booking (e.g. an inpatient observation) may run
+const MaxBookingSpan = 7 * 24 * time.Hour
+
+// CheckSpan validates an appointment's start and end. Bookings that cross local midnight in
+// loc are only allowed for services flagged allows_multi_day, and never beyond MaxBookingSpan.
+func CheckSpan(service *models.Service, start, end time.Time, loc *time.Location) error {
+	if !end.After(start) {
+		return &RuleViolation{Message: "appointments must end after they start"}
+	}
+	if end.Sub(start) > MaxBookingSpan {
+		return &RuleViolation{Message: fmt.Sprintf("appointments cannot run longer than %d days", int(MaxBookingSpan.Hours()/24))}
+	}
+	// An appointment ending exactly at local midnight still belongs to its start day
+	lastInstant := end.Add(-time.Nanosecond)
+	if !service.AllowsMultiDay && !timeutil.LocalDate(start, loc).Equal(timeutil.LocalDate(lastInstant, loc)) {
+		return &RuleViolation{Message: fmt.Sprintf("%s cannot run past midnight", service.Name)}
+	}
+	return nil
+}
+
+// ReserveReleased reports whether the employee's follow-up reserve has been released to general
+// availability for a booking starting at start, i.e. the local day is FollowUpReleaseDays or fewer away
+func ReserveReleased(employee *models.Employee, start, now time.Time, loc *time.Location) bool {
+	if employee.FollowUpReservePercent == 0 {
+		return true
+	}
+	daysOut := int(timeutil.LocalDate(start, loc).Sub(timeutil.LocalDate(now, loc)).Hours() / 24)
+	return daysOut <= employee.FollowUpReleaseDays
+}
+
+// CheckFollowUpReserve keeps FollowUpReservePercent of the employee's working time on a day free
+// for FOLLOW_UP bookings until the reserve is released. windows are that day's working windows and
+// bookings the employee's other active appointments overlapping them; time outside the windows
+// does not count against the reserve.
+func CheckFollowUpReserve(employee *models.Employee, appointment *models.Appointment, windows []Interval, bookings []models.Appointment, now time.Time, loc *time.Location) error {
+	if isFollowUp(appointment) || ReserveReleased(employee, appointment.StartDatetime, now, loc) {
+		return nil
+	}
+
+	var capacity time.Duration
+	for _, w := range windows {
+		capacity += w.Duration()
+	}
+	if capacity == 0 {
+		return nil
+	}
+
+	used := overlapWithin(Interval{Start: appointment.StartDatetime, End: appointment.EndDatetime}, windows)
+	for i := range bookings {
+		if !isFollowUp(&bookings[i]) {
+			used += overlapWithin(Interval{Start: bookings[i].StartDatetime, End: bookings[i].EndDatetime}, windows)
+		}
+	}
+
+	general := capacity * time.Duration(100-employee.FollowUpReservePercent) / 100
+	if used > general {
+		return &RuleViolation{Message: fmt.Sprintf("the remaining time on %s is reserved for follow-up appointments until %d days before",
+			appointment.StartDatetime.In(loc).Format(timeutil.DateLayout), employee.FollowUpReleaseDays)}
+	}
+	return nil
+}
+
+func isFollowUp(appointment *models.Appointment) bool {
+	return appointment.AppointmentType != nil && *appointment.AppointmentType == "FOLLOW_UP"
+}
+
+// overlapWithin returns how much of iv falls inside the (non-overlapping) windows
+func overlapWithin(iv Interval, windows []Interval) time.Duration {
+	var total time.Duration
+	for _, w := range windows {
+		start, end := w.Start, w.End
+		if iv.Start.After(start) {
+			start = iv.Start
+		}
+		if iv.End.Before(end) {
+			end = iv.End
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// FormatLead renders a lead time or notice period in whole hours, or minutes when it is not
+// a whole number of hours
+func FormatLead(lead time.Duration) string {
+	if lead%time.Hour == 0 {
+		return fmt.Sprintf("%d hours", int(lead.Hours()))
+	}
+	return fmt.Sprintf("%d minutes", int(lead.Minutes()))
+}
+
+// WithinWorkingHours reports whether [start, end) lies inside one of the employee's working
+// windows on the local date it starts
+func WithinWorkingHours(ctx context.Context, employee *models.Employee, start, end time.Time) (bool, error) {
+	windows, err := WorkingWindows(ctx, employee, timeutil.LocalDate(start, Location(employee)))
+	if err != nil {
+		return false, err
+	}
+	for _, w := range windows {
+		if !start.Before(w.Start) && !end.After(w.End) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,93 @@
+// Medical Appointment Booking System - Availability Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package availability
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"bookings/models"
+	"bookings/timeutil"
+)
+
+// SearchDays is how many days ahead of from Search looks for free slots
+const SearchDays = 60
+
+// Opening is a free slot with the employee it would be booked with
+type Opening struct {
+	EmployeeID   int    `json:"employee_id"`
+	EmployeeName string `json:"employee_name"`
+	ClinicID     int    `json:"clinic_id"`
+	Timezone     string `json:"timezone"`
+	Interval
+}
+
+// Search returns the earliest limit free slots for a service across employees, soonest first
+// (ties go to the lower employee id). Slots are FreeSlots', so they honor the employees'
+// templates, overrides, time off, bookings and holds, the resources the service needs and the
+// service's booking rules; none starts before from. Days are searched in order for every employee at once, and the search
+// stops as soon as no later day could hold an earlier slot, or after SearchDays.
+func Search(ctx context.Context, employees []models.Employee, service *models.Service, from time.Time, limit int, appointmentType *string, now time.Time) ([]Opening, error) {
+	openings := []Opening{}
+	if limit <= 0 || len(employees) == 0 {
+		return openings, nil
+	}
+
+	for day := 0; day <= SearchDays; day++ {
+		// The soonest any employee's next local day begins; no later slot can start earlier
+		var nextDay time.Time
+		for i := range employees {
+			employee := &employees[i]
+			loc := Location(employee)
+			date := timeutil.LocalDate(from, loc).AddDate(0, 0, day)
+			slots, err := FreeSlots(ctx, employee, service, date, appointmentType, now)
+			if err != nil {
+				return nil, err
+			}
+			for _, slot := range slots {
+				if !slot.Start.Before(from) {
+					openings = append(openings, Opening{
+						EmployeeID:   employee.ID,
+						EmployeeName: employee.FirstName + " " + employee.LastName,
+						ClinicID:     employee.ClinicID,
+						Timezone:     loc.String(),
+						Interval:     slot,
+					})
+				}
+			}
+			if start, _ := timeutil.DayBounds(date.AddDate(0, 0, 1), loc); nextDay.IsZero() || start.Before(nextDay) {
+				nextDay = start
+			}
+		}
+
+		slices.SortStableFunc(openings, func(a, b Opening) int {
+			if c := a.Start.Compare(b.Start); c != 0 {
+				return c
+			}
+			return cmp.Compare(a.EmployeeID, b.EmployeeID)
+		})
+		if len(openings) >= limit && !openings[limit-1].Start.After(nextDay) {
+			return openings[:limit], nil
+		}
+	}
+	if len(openings) > limit {
+		openings = openings[:limit]
+	}
+	return openings, nil
+}
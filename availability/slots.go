@@ -0,0 +1,142 @@
+// Medical Appointment Booking System - Availability Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package availability
+
+import (
+	"context"
+	"time"
+
+	"bookings/database"
+	"bookings/models"
+)
+
+// FreeSlots lists the bookable slots for a service with an employee on a local calendar date.
+// Working windows (templates, overrides, time off) are reduced by existing bookings and live
+// slot holds, each widened by its service's buffers, cut into back-to-back slots of the
+// service's duration plus its own buffers, and filtered by the rooms and equipment the service
+// needs, the service's and the employee's clinic's booking rules and the employee's follow-up
+// reserve for the given appointment type.
+func FreeSlots(ctx context.Context, employee *models.Employee, service *models.Service, date time.Time, appointmentType *string, now time.Time) ([]Interval, error) {
+	windows, err := WorkingWindows(ctx, employee, date)
+	if err != nil || len(windows) == 0 {
+		return nil, err
+	}
+	from, to := windows[0].Start, windows[len(windows)-1].End
+
+	blocks, err := database.GetEmployeeBlocks(ctx, employee.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	clinic, err := database.GetClinic(ctx, employee.ClinicID)
+	if err != nil {
+		return nil, err
+	}
+	before, after := Buffers(service)
+	required, resourceBookings, err := resourceUse(ctx, service, employee.ClinicID, from.Add(-before), to.Add(after), 0)
+	if err != nil {
+		return nil, err
+	}
+	var bookings []models.Appointment
+	busy := make([]Interval, 0, len(blocks))
+	for _, b := range blocks {
+		busy = append(busy, Interval{Start: b.Start.UTC(), End: b.End.UTC()})
+		if b.Appointment != nil {
+			bookings = append(bookings, *b.Appointment)
+		}
+	}
+
+	loc := Location(employee)
+	duration := time.Duration(service.DurationMinutes) * time.Minute
+	slots := []Interval{}
+	for _, padded := range Slice(Subtract(windows, busy), before+duration+after) {
+		slot := Interval{Start: padded.Start.Add(before), End: padded.End.Add(-after)}
+		candidate := &models.Appointment{StartDatetime: slot.Start, EndDatetime: slot.End, AppointmentType: appointmentType}
+		if !resourcesFree(required, resourceBookings, padded) ||
+			CheckSpan(service, slot.Start, slot.End, loc) != nil ||
+			CheckBookingWindow(service, clinic, slot.Start, now, loc) != nil ||
+			CheckFollowUpReserve(employee, candidate, windows, bookings, now, loc) != nil {
+			continue
+		}
+		slots = append(slots, slot)
+	}
+	return slots, nil
+}
+
+// CheckResources reports whether the resources a service needs at a clinic are free from start
+// to end, buffers included, returning *database.ResourceUnavailableError when a kind is all
+// taken. Appointment excludeID (0 for none) does not count as taking its resources.
+func CheckResources(ctx context.Context, service *models.Service, clinicID int, start, end time.Time, excludeID int) error {
+	before, after := Buffers(service)
+	required, bookings, err := resourceUse(ctx, service, clinicID, start.Add(-before), end.Add(after), excludeID)
+	if err != nil || len(required) == 0 {
+		return err
+	}
+	_, err = database.PickResources(required, bookings)
+	return err
+}
+
+// resourceUse loads the resources a service needs at a clinic and their bookings overlapping [from, to)
+func resourceUse(ctx context.Context, service *models.Service, clinicID int, from, to time.Time, excludeID int) ([]models.Resource, []database.ResourceBooking, error) {
+	required, err := database.GetRequiredResources(ctx, service.ID, clinicID)
+	if err != nil || len(required) == 0 {
+		return nil, nil, err
+	}
+	ids := make([]int, 0, len(required))
+	for _, r := range required {
+		ids = append(ids, r.ID)
+	}
+	bookings, err := database.GetResourceBookings(ctx, ids, from, to, excludeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return required, bookings, nil
+}
+
+// resourcesFree reports whether one resource of every required kind is free throughout span
+func resourcesFree(required []models.Resource, bookings []database.ResourceBooking, span Interval) bool {
+	if len(required) == 0 {
+		return true
+	}
+	var overlapping []database.ResourceBooking
+	for _, b := range bookings {
+		if b.Start.Before(span.End) && b.End.After(span.Start) {
+			overlapping = append(overlapping, b)
+		}
+	}
+	_, err := database.PickResources(required, overlapping)
+	return err == nil
+}
+
+// Buffers returns the preparation time before and the clean-up time after the service's
+// appointments, during which the employee cannot be booked
+func Buffers(service *models.Service) (before, after time.Duration) {
+	return time.Duration(service.BufferBeforeMinutes) * time.Minute, time.Duration(service.BufferAfterMinutes) * time.Minute
+}
+
+// Slice cuts free intervals into consecutive slots of the given duration, dropping any remainder
+func Slice(free []Interval, duration time.Duration) []Interval {
+	if duration <= 0 {
+		return nil
+	}
+	var slots []Interval
+	for _, f := range free {
+		for start := f.Start; !start.Add(duration).After(f.End); start = start.Add(duration) {
+			slots = append(slots, Interval{Start: start, End: start.Add(duration)})
+		}
+	}
+	return slots
+}
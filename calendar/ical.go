@@ -0,0 +1,143 @@
+// Medical Appointment Booking System - Calendar Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package calendar
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of an iCalendar feed
+const ContentType = "text/calendar; charset=utf-8"
+
+// prodID identifies this application in generated feeds
+const prodID = "-//TSCSoftware//Medical Appointment Booking System//EN"
+
+// Event is one appointment in a feed
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Updated     time.Time
+	Summary     string
+	Location    string
+	Description string
+	// Status is TENTATIVE, CONFIRMED or CANCELLED
+	Status string
+}
+
+// Feed renders events as an RFC 5545 calendar. Times are written in UTC, which every
+// calendar client converts to the viewer's zone.
+func Feed(name string, events []Event, now time.Time) string {
+	var b strings.Builder
+	line := func(property, value string) {
+		writeFolded(&b, property+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", prodID)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", escapeText(name))
+	for _, e := range events {
+		stamp := e.Updated
+		if stamp.IsZero() {
+			stamp = now
+		}
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("DTSTAMP", formatTime(stamp))
+		line("DTSTART", formatTime(e.Start))
+		line("DTEND", formatTime(e.End))
+		line("SUMMARY", escapeText(e.Summary))
+		if e.Location != "" {
+			line("LOCATION", escapeText(e.Location))
+		}
+		if e.Description != "" {
+			line("DESCRIPTION", escapeText(e.Description))
+		}
+		if e.Status != "" {
+			line("STATUS", e.Status)
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return b.String()
+}
+
+// EventStatus maps an appointment status to the iCalendar event status
+func EventStatus(appointmentStatus string) string {
+	switch appointmentStatus {
+	case "SCHEDULED":
+		return "TENTATIVE"
+	case "CANCELLED", "NO_SHOW":
+		return "CANCELLED"
+	default:
+		return "CONFIRMED"
+	}
+}
+
+// NewFeedToken generates the secret part of a feed URL and the hash stored for it
+func NewFeedToken() (token, hash string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, HashFeedToken(token), nil
+}
+
+// HashFeedToken returns the stored form of a feed token
+func HashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escapeText escapes a TEXT value (RFC 5545 section 3.3.11)
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeFolded writes a content line, folding it so no line exceeds 75 octets and never
+// splitting a UTF-8 character (RFC 5545 section 3.1)
+func writeFolded(b *strings.Builder, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8Start(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with a space, which counts towards their length
+		limit = 74
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+func utf8Start(c byte) bool {
+	return c&0xC0 != 0x80
+}
@@ -0,0 +1,371 @@
+// Medical Appointment Booking System - Config Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package config loads the server settings from an optional YAML file and the environment.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Config is the server configuration. Settings come from the defaults, then the YAML file,
+// then the environment, each overriding the last.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Database  Database  `yaml:"database"`
+	Log       Log       `yaml:"log"`
+	Features  Features  `yaml:"features"`
+	Invoices  Invoices  `yaml:"invoices"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Retention Retention `yaml:"retention"`
+}
+
+// Server configures the HTTP listener
+type Server struct {
+	// Addr is the address to listen on, host:port (LISTEN_ADDR)
+	Addr string `yaml:"addr"`
+	// CORSOrigins are the browser origins allowed to call the API; "*" allows any
+	// (CORS_ALLOWED_ORIGINS, comma-separated)
+	CORSOrigins []string `yaml:"cors_origins"`
+	// TrustedProxies are the addresses or CIDR ranges of the reverse proxies whose
+	// X-Forwarded-For is believed; with none, a client is known by the address it connects
+	// from (TRUSTED_PROXIES, comma-separated)
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// Database sizes the connection pool; zero leaves pgx's default (DB_MAX_CONNS, DB_MIN_CONNS)
+type Database struct {
+	MaxConns int32 `yaml:"max_conns"`
+	MinConns int32 `yaml:"min_conns"`
+}
+
+// Log configures the structured logger (LOG_LEVEL, LOG_FORMAT)
+type Log struct {
+	// Level is debug, info, warn or error
+	Level string `yaml:"level"`
+	// Format is json or text
+	Format string `yaml:"format"`
+}
+
+// Features switches optional route groups on or off (FEATURE_<NAME>, e.g.
+// FEATURE_PATIENT_PORTAL=false). All are on by default.
+type Features struct {
+	PublicBooking bool `yaml:"public_booking"`
+	PatientPortal bool `yaml:"patient_portal"`
+	CalendarFeeds bool `yaml:"calendar_feeds"`
+	LegacyAPI     bool `yaml:"legacy_api"`
+}
+
+// Invoices configures the invoice and receipt PDFs
+type Invoices struct {
+	// TaxRate is the percentage of tax included in appointment prices (INVOICE_TAX_RATE)
+	TaxRate float64 `yaml:"tax_rate"`
+	// EmailReceipts emails patients their receipt when a card payment completes
+	// (INVOICE_EMAIL_RECEIPTS)
+	EmailReceipts bool `yaml:"email_receipts"`
+}
+
+// RateLimit sets how many requests a client may make: Burst at once, then PerMinute a minute.
+// Authenticated clients are counted per user or API key; the open endpoints (login, public
+// booking, the portal's sign-up and token-addressed links) per address, with the stricter
+// Public limits. A PerMinute of 0 turns that limit off.
+type RateLimit struct {
+	// PerMinute and Burst limit authenticated clients (RATE_LIMIT_PER_MINUTE, RATE_LIMIT_BURST)
+	PerMinute int `yaml:"per_minute"`
+	Burst     int `yaml:"burst"`
+	// PublicPerMinute and PublicBurst limit the open endpoints (RATE_LIMIT_PUBLIC_PER_MINUTE,
+	// RATE_LIMIT_PUBLIC_BURST)
+	PublicPerMinute int `yaml:"public_per_minute"`
+	PublicBurst     int `yaml:"public_burst"`
+	// HoldsPerMinute and HoldsBurst further limit placing slot holds in the online booking
+	// flow, per address (RATE_LIMIT_HOLDS_PER_MINUTE, RATE_LIMIT_HOLDS_BURST)
+	HoldsPerMinute int `yaml:"holds_per_minute"`
+	HoldsBurst     int `yaml:"holds_burst"`
+}
+
+// Retention actions: what the retention sweep does with rows past their class's period
+const (
+	// RetentionArchive moves rows into the class's archive table
+	RetentionArchive = "archive"
+	// RetentionCold moves rows to the object store as JSON Lines files
+	RetentionCold = "cold"
+	// RetentionPurge deletes rows
+	RetentionPurge = "purge"
+)
+
+// RetentionActions lists the valid retention actions
+var RetentionActions = []string{RetentionArchive, RetentionCold, RetentionPurge}
+
+// RetentionClasses names the classes of data with a retention policy
+var RetentionClasses = []string{"appointments", "notifications", "audit_log"}
+
+// Retention sets how long each class of data is kept before the retention sweep archives or
+// purges it (RETENTION_<CLASS>_DAYS and RETENTION_<CLASS>_ACTION, e.g.
+// RETENTION_AUDIT_LOG_DAYS=2555). Every class is kept forever by default.
+type Retention struct {
+	Appointments  RetentionPolicy `yaml:"appointments"`
+	Notifications RetentionPolicy `yaml:"notifications"`
+	AuditLog      RetentionPolicy `yaml:"audit_log"`
+}
+
+// RetentionPolicy is how long one class of data is kept and what happens to it after that
+type RetentionPolicy struct {
+	// Days is how long rows are kept; 0 keeps them forever
+	Days int `yaml:"days"`
+	// Action is archive, cold or purge
+	Action string `yaml:"action"`
+}
+
+// Policies returns the policy of each class by its name: appointments, notifications and
+// audit_log
+func (r Retention) Policies() map[string]RetentionPolicy {
+	return map[string]RetentionPolicy{
+		"appointments":  r.Appointments,
+		"notifications": r.Notifications,
+		"audit_log":     r.AuditLog,
+	}
+}
+
+// policyFields returns the settings of each class by its name, for applyEnv to override
+func (r *Retention) policyFields() map[string]*RetentionPolicy {
+	return map[string]*RetentionPolicy{
+		"appointments":  &r.Appointments,
+		"notifications": &r.Notifications,
+		"audit_log":     &r.AuditLog,
+	}
+}
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	return Config{
+		Server: Server{Addr: ":8080", CORSOrigins: []string{"*"}},
+		Log:    Log{Level: "info", Format: "json"},
+		Features: Features{
+			PublicBooking: true,
+			PatientPortal: true,
+			CalendarFeeds: true,
+			LegacyAPI:     true,
+		},
+		Invoices:  Invoices{EmailReceipts: true},
+		RateLimit: RateLimit{PerMinute: 600, Burst: 100, PublicPerMinute: 60, PublicBurst: 20, HoldsPerMinute: 2, HoldsBurst: 5},
+		Retention: Retention{
+			Appointments:  RetentionPolicy{Action: RetentionArchive},
+			Notifications: RetentionPolicy{Action: RetentionArchive},
+			AuditLog:      RetentionPolicy{Action: RetentionArchive},
+		},
+	}
+}
+
+// Load reads the configuration: the defaults, overridden by the YAML file at path when it
+// is not empty, overridden by the environment. The result is validated.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.UnmarshalWithOptions(data, &cfg, yaml.Strict()); err != nil {
+			return Config{}, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides the settings whose environment variable is set
+func (cfg *Config) applyEnv() error {
+	if raw := os.Getenv("LISTEN_ADDR"); raw != "" {
+		cfg.Server.Addr = raw
+	}
+	if raw := os.Getenv("CORS_ALLOWED_ORIGINS"); raw != "" {
+		cfg.Server.CORSOrigins = nil
+		for _, origin := range strings.Split(raw, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.Server.CORSOrigins = append(cfg.Server.CORSOrigins, origin)
+			}
+		}
+	}
+	if raw := os.Getenv("TRUSTED_PROXIES"); raw != "" {
+		cfg.Server.TrustedProxies = nil
+		for _, proxy := range strings.Split(raw, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.Server.TrustedProxies = append(cfg.Server.TrustedProxies, proxy)
+			}
+		}
+	}
+	for name, dst := range map[string]*int{
+		"RATE_LIMIT_PER_MINUTE":        &cfg.RateLimit.PerMinute,
+		"RATE_LIMIT_BURST":             &cfg.RateLimit.Burst,
+		"RATE_LIMIT_PUBLIC_PER_MINUTE": &cfg.RateLimit.PublicPerMinute,
+		"RATE_LIMIT_PUBLIC_BURST":      &cfg.RateLimit.PublicBurst,
+		"RATE_LIMIT_HOLDS_PER_MINUTE":  &cfg.RateLimit.HoldsPerMinute,
+		"RATE_LIMIT_HOLDS_BURST":       &cfg.RateLimit.HoldsBurst,
+	} {
+		if raw := os.Getenv(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", name, raw)
+			}
+			*dst = n
+		}
+	}
+	for name, dst := range map[string]*int32{
+		"DB_MAX_CONNS": &cfg.Database.MaxConns,
+		"DB_MIN_CONNS": &cfg.Database.MinConns,
+	} {
+		if raw := os.Getenv(name); raw != "" {
+			n, err := strconv.ParseInt(raw, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", name, raw)
+			}
+			*dst = int32(n)
+		}
+	}
+	if raw := os.Getenv("INVOICE_TAX_RATE"); raw != "" {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid INVOICE_TAX_RATE %q", raw)
+		}
+		cfg.Invoices.TaxRate = rate
+	}
+	for class, policy := range cfg.Retention.policyFields() {
+		prefix := "RETENTION_" + strings.ToUpper(class)
+		if raw := os.Getenv(prefix + "_DAYS"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s_DAYS %q", prefix, raw)
+			}
+			policy.Days = n
+		}
+		if raw := os.Getenv(prefix + "_ACTION"); raw != "" {
+			policy.Action = raw
+		}
+	}
+	if raw := os.Getenv("LOG_LEVEL"); raw != "" {
+		cfg.Log.Level = raw
+	}
+	if raw := os.Getenv("LOG_FORMAT"); raw != "" {
+		cfg.Log.Format = raw
+	}
+	for name, dst := range map[string]*bool{
+		"FEATURE_PUBLIC_BOOKING": &cfg.Features.PublicBooking,
+		"FEATURE_PATIENT_PORTAL": &cfg.Features.PatientPortal,
+		"FEATURE_CALENDAR_FEEDS": &cfg.Features.CalendarFeeds,
+		"FEATURE_LEGACY_API":     &cfg.Features.LegacyAPI,
+		"INVOICE_EMAIL_RECEIPTS": &cfg.Invoices.EmailReceipts,
+	} {
+		if raw := os.Getenv(name); raw != "" {
+			on, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", name, raw)
+			}
+			*dst = on
+		}
+	}
+	return nil
+}
+
+// Validate reports the first setting that is out of range
+func (cfg Config) Validate() error {
+	if _, port, err := net.SplitHostPort(cfg.Server.Addr); err != nil || port == "" {
+		return fmt.Errorf("invalid listen address %q", cfg.Server.Addr)
+	}
+
+	if len(cfg.Server.CORSOrigins) == 0 {
+		return errors.New("no CORS origins configured; use \"*\" to allow any")
+	}
+	for _, origin := range cfg.Server.CORSOrigins {
+		if origin == "*" {
+			if len(cfg.Server.CORSOrigins) > 1 {
+				return errors.New("CORS origin \"*\" cannot be combined with other origins")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("invalid CORS origin %q; expected scheme://host[:port]", origin)
+		}
+	}
+
+	if cfg.Database.MaxConns < 0 || cfg.Database.MinConns < 0 {
+		return errors.New("database pool sizes cannot be negative")
+	}
+	if cfg.Database.MaxConns > 0 && cfg.Database.MinConns > cfg.Database.MaxConns {
+		return fmt.Errorf("database min_conns %d exceeds max_conns %d", cfg.Database.MinConns, cfg.Database.MaxConns)
+	}
+
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("invalid trusted proxy %q; expected an IP address or CIDR range", proxy)
+			}
+		}
+	}
+
+	if cfg.RateLimit.PerMinute < 0 || cfg.RateLimit.Burst < 0 || cfg.RateLimit.PublicPerMinute < 0 || cfg.RateLimit.PublicBurst < 0 ||
+		cfg.RateLimit.HoldsPerMinute < 0 || cfg.RateLimit.HoldsBurst < 0 {
+		return errors.New("rate limits cannot be negative")
+	}
+
+	if cfg.Invoices.TaxRate < 0 || cfg.Invoices.TaxRate >= 100 {
+		return fmt.Errorf("invoice tax rate %g is not a percentage below 100", cfg.Invoices.TaxRate)
+	}
+
+	policies := cfg.Retention.Policies()
+	for _, class := range RetentionClasses {
+		policy := policies[class]
+		if policy.Days < 0 {
+			return fmt.Errorf("retention period of %s cannot be negative", class)
+		}
+		if !slices.Contains(RetentionActions, policy.Action) {
+			return fmt.Errorf("invalid retention action %q for %s; expected one of %s", policy.Action, class, strings.Join(RetentionActions, ", "))
+		}
+	}
+
+	if _, err := cfg.Log.SlogLevel(); err != nil {
+		return err
+	}
+	if format := strings.ToLower(cfg.Log.Format); format != "json" && format != "text" {
+		return fmt.Errorf("invalid log format %q", cfg.Log.Format)
+	}
+	return nil
+}
+
+// SlogLevel parses Level
+func (l Log) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", l.Level)
+	}
+	return level, nil
+}
@@ -0,0 +1,101 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"bookings/models"
+)
+
+// Access log operations
+
+// InsertAccessEntries records reads of one resource made by a single request, one row per
+// entry. The actor, Resource and Endpoint are shared; only PatientID and AppointmentID are
+// taken from each entry.
+func InsertAccessEntries(ctx context.Context, actorUserID, actorAPIKeyID *int, resource, endpoint string, entries []models.AccessEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	patientIDs := make([]int, len(entries))
+	appointmentIDs := make([]*int, len(entries))
+	for i, entry := range entries {
+		patientIDs[i], appointmentIDs[i] = entry.PatientID, entry.AppointmentID
+	}
+	_, err := conn(ctx).Exec(ctx,
+		`INSERT INTO access_log (actor_user_id, actor_api_key_id, resource, endpoint, patient_id, appointment_id)
+		SELECT $1, $2, $3, $4, patient_id, appointment_id FROM unnest($5::int[], $6::int[]) AS t(patient_id, appointment_id)`,
+		actorUserID, actorAPIKeyID, resource, endpoint, patientIDs, appointmentIDs)
+	return err
+}
+
+// AccessFilter narrows GetAccessEntries; nil fields match everything
+type AccessFilter struct {
+	PatientID   *int
+	ActorUserID *int
+	Resource    *string
+	From        *time.Time // entries recorded at or after From
+	To          *time.Time // entries recorded before To
+}
+
+// accessFilterWhere applies an AccessFilter passed as $1-$5
+const accessFilterWhere = ` WHERE ($1::int IS NULL OR patient_id = $1)
+	AND ($2::int IS NULL OR actor_user_id = $2)
+	AND ($3::text IS NULL OR resource = $3)
+	AND ($4::timestamptz IS NULL OR created_at >= $4)
+	AND ($5::timestamptz IS NULL OR created_at < $5)`
+
+func (f AccessFilter) args() []any {
+	var from, to *time.Time
+	if f.From != nil {
+		utc := f.From.UTC()
+		from = &utc
+	}
+	if f.To != nil {
+		utc := f.To.UTC()
+		to = &utc
+	}
+	return []any{f.PatientID, f.ActorUserID, f.Resource, from, to}
+}
+
+// GetAccessEntries returns one page of the access log entries matching the filter, latest
+// first, with the total number that match
+func GetAccessEntries(ctx context.Context, filter AccessFilter, page Page) ([]models.AccessEntry, int, error) {
+	args := filter.args()
+	total, err := count(ctx, "SELECT COUNT(*) FROM access_log"+accessFilterWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT id, actor_user_id, actor_api_key_id, patient_id, appointment_id, resource, endpoint, created_at FROM access_log"+accessFilterWhere+" ORDER BY created_at DESC, id DESC LIMIT $6 OFFSET $7",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []models.AccessEntry{}
+	for rows.Next() {
+		var entry models.AccessEntry
+		if err := rows.Scan(&entry.ID, &entry.ActorUserID, &entry.ActorAPIKeyID, &entry.PatientID, &entry.AppointmentID, &entry.Resource, &entry.Endpoint, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
@@ -0,0 +1,138 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrAPIKeyInvalid is returned for API keys that are unknown, revoked or expired
+var ErrAPIKeyInvalid = errors.New("invalid, revoked or expired API key")
+
+const apiKeyColumns = "id, name, prefix, scopes, organization_id, created_by, created_at, expires_at, last_used_at, rotated_at, revoked_at"
+
+func scanAPIKey(row pgx.Row, key *models.APIKey) error {
+	return row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Scopes, &key.OrganizationID, &key.CreatedBy,
+		&key.CreatedAt, &key.ExpiresAt, &key.LastUsedAt, &key.RotatedAt, &key.RevokedAt)
+}
+
+// API key operations
+
+// GetAPIKeys lists an organization's API keys, revoked ones included, without their hashes
+func GetAPIKeys(ctx context.Context, organizationID int) ([]models.APIKey, error) {
+	rows, err := conn(ctx).Query(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE organization_id = $1 ORDER BY id", organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func GetAPIKey(ctx context.Context, id int) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := scanAPIKey(conn(ctx).QueryRow(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1", id), &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateAPIKey stores a new key under the hash of its secret
+func CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error {
+	key.OrganizationID = organizationOrDefault(key.OrganizationID)
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO api_keys (name, prefix, key_hash, scopes, organization_id, created_by, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at",
+		key.Name, key.Prefix, hash, key.Scopes, key.OrganizationID, key.CreatedBy, key.ExpiresAt).Scan(&key.CreatedAt)
+}
+
+// UpdateAPIKey changes a live key's name, scopes and expiry; pgx.ErrNoRows means there is no
+// such key or it is revoked
+func UpdateAPIKey(ctx context.Context, id int, key *models.APIKey) error {
+	tag, err := conn(ctx).Exec(ctx,
+		"UPDATE api_keys SET name = $1, scopes = $2, expires_at = $3 WHERE id = $4 AND revoked_at IS NULL",
+		key.Name, key.Scopes, key.ExpiresAt, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// RotateAPIKey replaces a live key's secret, so the old one stops working at once;
+// pgx.ErrNoRows means there is no such key or it is revoked
+func RotateAPIKey(ctx context.Context, id int, prefix, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := scanAPIKey(conn(ctx).QueryRow(ctx,
+		"UPDATE api_keys SET prefix = $1, key_hash = $2, rotated_at = CURRENT_TIMESTAMP WHERE id = $3 AND revoked_at IS NULL RETURNING "+apiKeyColumns,
+		prefix, hash, id), &key)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey stops a key from working for good; pgx.ErrNoRows means there is no such key or
+// it is already revoked
+func RevokeAPIKey(ctx context.Context, id int) error {
+	tag, err := conn(ctx).Exec(ctx, "UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// apiKeyUseInterval is how stale last_used_at may get, so a busy key is not written on every request
+const apiKeyUseInterval = time.Minute
+
+// UseAPIKey looks up the live key with the given hash and notes that it was used, returning
+// ErrAPIKeyInvalid when there is none
+func UseAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := scanAPIKey(conn(ctx).QueryRow(ctx,
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)", hash), &key)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > apiKeyUseInterval {
+		if _, err := conn(ctx).Exec(ctx, "UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1", key.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &key, nil
+}
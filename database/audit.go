@@ -0,0 +1,136 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// eventOutbox is set by EnableEventOutbox
+var eventOutbox atomic.Bool
+
+// EnableEventOutbox makes every audit entry also queue a domain event in event_outbox. It is
+// only turned on when an event bus is configured, so nothing queues events nobody relays.
+func EnableEventOutbox() {
+	eventOutbox.Store(true)
+}
+
+// Audit log operations
+func InsertAuditEntry(ctx context.Context, entity string, entityID int, action string, actorUserID, actorAPIKeyID *int, snapshot, changes []byte) error {
+	const insert = "INSERT INTO audit_log (entity, entity_id, action, actor_user_id, actor_api_key_id, snapshot, changes) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+	query := insert
+	if eventOutbox.Load() {
+		// One statement, so the entry and its event are stored together or not at all
+		query = "WITH entry AS (" + insert + " RETURNING id) INSERT INTO event_outbox (audit_log_id) SELECT id FROM entry"
+	}
+	_, err := conn(ctx).Exec(ctx, query, entity, entityID, action, actorUserID, actorAPIKeyID, snapshot, changes)
+	return err
+}
+
+// AuditFilter narrows GetAuditEntries; nil fields match everything
+type AuditFilter struct {
+	Entity      *string
+	EntityID    *int
+	ActorUserID *int
+	From        *time.Time // entries recorded at or after From
+	To          *time.Time // entries recorded before To
+	// ActorAPIKeyID keeps the changes made with one API key
+	ActorAPIKeyID *int
+}
+
+// auditFilterWhere applies an AuditFilter passed as $1-$6
+const auditFilterWhere = ` WHERE ($1::text IS NULL OR entity = $1)
+	AND ($2::int IS NULL OR entity_id = $2)
+	AND ($3::int IS NULL OR actor_user_id = $3)
+	AND ($4::timestamptz IS NULL OR created_at >= $4)
+	AND ($5::timestamptz IS NULL OR created_at < $5)
+	AND ($6::int IS NULL OR actor_api_key_id = $6)`
+
+func (f AuditFilter) args() []any {
+	var from, to *time.Time
+	if f.From != nil {
+		utc := f.From.UTC()
+		from = &utc
+	}
+	if f.To != nil {
+		utc := f.To.UTC()
+		to = &utc
+	}
+	return []any{f.Entity, f.EntityID, f.ActorUserID, from, to, f.ActorAPIKeyID}
+}
+
+// GetAuditEntries returns one page of the audit entries matching the filter, latest first,
+// with the total number that match
+func GetAuditEntries(ctx context.Context, filter AuditFilter, page Page) ([]models.AuditEntry, int, error) {
+	args := filter.args()
+	total, err := count(ctx, "SELECT COUNT(*) FROM audit_log"+auditFilterWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT id, entity, entity_id, action, actor_user_id, actor_api_key_id, snapshot, changes, created_at FROM audit_log"+auditFilterWhere+" ORDER BY created_at DESC, id DESC LIMIT $7 OFFSET $8",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := rows.Scan(&entry.ID, &entry.Entity, &entry.EntityID, &entry.Action, &entry.ActorUserID, &entry.ActorAPIKeyID, &entry.Snapshot, &entry.Changes, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+// GetAuditSnapshotAt returns the action and snapshot of the latest audit entry for an
+// entity recorded at or before the given time; found is false when there is none
+func GetAuditSnapshotAt(ctx context.Context, entity string, entityID int, at time.Time) (action string, snapshot []byte, found bool, err error) {
+	err = conn(ctx).QueryRow(ctx,
+		"SELECT action, snapshot FROM audit_log WHERE entity = $1 AND entity_id = $2 AND created_at <= $3 ORDER BY created_at DESC, id DESC LIMIT 1",
+		entity, entityID, at.UTC()).Scan(&action, &snapshot)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, false, nil
+	}
+	if err != nil {
+		return "", nil, false, err
+	}
+	return action, snapshot, true, nil
+}
+
+// RedactAuditFields removes fields from every audit entry of an entity: from the snapshots
+// and from the changes, so the log keeps when the entity changed but no longer what those
+// fields held
+func RedactAuditFields(ctx context.Context, entity string, entityID int, fields []string) error {
+	_, err := conn(ctx).Exec(ctx,
+		`UPDATE audit_log SET snapshot = snapshot - $3::text[],
+			changes = CASE WHEN changes IS NULL THEN NULL ELSE (
+				SELECT COALESCE(jsonb_agg(change ORDER BY n), '[]'::jsonb) FROM jsonb_array_elements(changes) WITH ORDINALITY AS c(change, n)
+				WHERE NOT (change->>'field' = ANY($3::text[]))) END
+		WHERE entity = $1 AND entity_id = $2`, entity, entityID, fields)
+	return err
+}
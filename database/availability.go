@@ -0,0 +1,246 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Schedule queries used by the availability engine
+
+// GetWorkTemplates returns the employee's active templates for an ISO weekday (1 = Monday)
+func GetWorkTemplates(ctx context.Context, employeeID, weekday int) ([]models.WorkTemplate, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT id, employee_id, weekday, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'), slot_granularity_minutes, is_active FROM work_templates WHERE employee_id = $1 AND weekday = $2 AND is_active ORDER BY start_time",
+		employeeID, weekday)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []models.WorkTemplate
+	for rows.Next() {
+		var t models.WorkTemplate
+		if err := rows.Scan(&t.ID, &t.EmployeeID, &t.Weekday, &t.StartTime, &t.EndTime, &t.SlotGranularityMinutes, &t.IsActive); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// GetDayOverride returns the override for an employee on a date ("YYYY-MM-DD"), or nil if there is none
+func GetDayOverride(ctx context.Context, employeeID int, date string) (*models.DayOverride, error) {
+	var o models.DayOverride
+	err := conn(ctx).QueryRow(ctx,
+		"SELECT "+dayOverrideColumns+" FROM day_overrides WHERE employee_id = $1 AND date = $2::date",
+		employeeID, date).
+		Scan(&o.ID, &o.EmployeeID, &o.Date, &o.IsClosed, &o.StartTime, &o.EndTime, &o.Reason)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// dayOverrideColumns formats dates and times as the "YYYY-MM-DD" and "HH:MM" strings the model uses
+const dayOverrideColumns = "id, employee_id, to_char(date, 'YYYY-MM-DD'), is_closed, to_char(start_time, 'HH24:MI'), to_char(end_time, 'HH24:MI'), reason"
+
+// ListDayOverrides returns the employee's overrides between two dates (inclusive, "YYYY-MM-DD")
+func ListDayOverrides(ctx context.Context, employeeID int, from, to string) ([]models.DayOverride, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+dayOverrideColumns+" FROM day_overrides WHERE employee_id = $1 AND date BETWEEN $2::date AND $3::date ORDER BY date",
+		employeeID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := []models.DayOverride{}
+	for rows.Next() {
+		var o models.DayOverride
+		if err := rows.Scan(&o.ID, &o.EmployeeID, &o.Date, &o.IsClosed, &o.StartTime, &o.EndTime, &o.Reason); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+// UpsertDayOverride creates or replaces the override for the employee and date
+func UpsertDayOverride(ctx context.Context, o *models.DayOverride) error {
+	return conn(ctx).QueryRow(ctx,
+		`INSERT INTO day_overrides (employee_id, date, is_closed, start_time, end_time, reason) VALUES ($1, $2::date, $3, $4::time, $5::time, $6)
+		ON CONFLICT (employee_id, date) DO UPDATE SET is_closed = EXCLUDED.is_closed, start_time = EXCLUDED.start_time, end_time = EXCLUDED.end_time, reason = EXCLUDED.reason
+		RETURNING id`,
+		o.EmployeeID, o.Date, o.IsClosed, o.StartTime, o.EndTime, o.Reason).Scan(&o.ID)
+}
+
+// DeleteDayOverride removes the override for the employee and date and returns its id,
+// reporting pgx.ErrNoRows when there is none
+func DeleteDayOverride(ctx context.Context, employeeID int, date string) (int, error) {
+	var id int
+	err := conn(ctx).QueryRow(ctx,
+		"DELETE FROM day_overrides WHERE employee_id = $1 AND date = $2::date RETURNING id", employeeID, date).Scan(&id)
+	return id, err
+}
+
+// GetApprovedTimeOff returns approved time off overlapping [from, to)
+func GetApprovedTimeOff(ctx context.Context, employeeID int, from, to time.Time) ([]models.TimeOff, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+timeOffColumns+" FROM time_off WHERE employee_id = $1 AND status = 'APPROVED' AND start_datetime < $3 AND end_datetime > $2 ORDER BY start_datetime",
+		employeeID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return collectTimeOff(rows)
+}
+
+// GetEmployeeBookings returns the employee's appointments overlapping [from, to) that still occupy time
+func GetEmployeeBookings(ctx context.Context, employeeID int, from, to time.Time) ([]models.Appointment, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+appointmentColumns+" FROM appointments WHERE employee_id = $1 AND status NOT IN ('CANCELLED', 'NO_SHOW') AND start_datetime < $3 AND end_datetime > $2 ORDER BY start_datetime",
+		employeeID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return collectAppointments(rows)
+}
+
+// Block is time an employee is kept busy by an active appointment or an unexpired slot hold,
+// widened by its service's buffer before and after
+type Block struct {
+	Start       time.Time
+	End         time.Time
+	Appointment *models.Appointment // nil for a hold
+	HoldToken   string              // set for a hold
+}
+
+// bufferedStart and bufferedEnd widen the span of a row aliased a by the buffers of the
+// service aliased s
+const (
+	bufferedStart = "a.start_datetime - make_interval(mins => s.buffer_before_minutes)"
+	bufferedEnd   = "a.end_datetime + make_interval(mins => s.buffer_after_minutes)"
+)
+
+// GetEmployeeBlocks returns the employee's blocks overlapping [from, to): appointments first,
+// then holds, each in start order
+func GetEmployeeBlocks(ctx context.Context, employeeID int, from, to time.Time) ([]Block, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prefixed("a", appointmentColumns)+", "+bufferedStart+", "+bufferedEnd+
+			" FROM appointments a JOIN services s ON s.id = a.service_id WHERE a.employee_id = $1 AND a.status NOT IN ('CANCELLED', 'NO_SHOW') AND "+
+			bufferedStart+" < $3 AND "+bufferedEnd+" > $2 ORDER BY a.start_datetime",
+		employeeID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	var blocks []Block
+	for rows.Next() {
+		b := Block{Appointment: &models.Appointment{}}
+		if err := scanAppointment(extraColumns{rows, []any{&b.Start, &b.End}}, b.Appointment); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = conn(ctx).Query(ctx,
+		"SELECT a.hold_token, "+bufferedStart+", "+bufferedEnd+
+			" FROM slot_holds a JOIN services s ON s.id = a.service_id WHERE a.employee_id = $1 AND a.expires_at > CURRENT_TIMESTAMP AND "+
+			bufferedStart+" < $3 AND "+bufferedEnd+" > $2 ORDER BY a.start_datetime",
+		employeeID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b Block
+		if err := rows.Scan(&b.HoldToken, &b.Start, &b.End); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks, rows.Err()
+}
+
+// extraColumns scans a row whose columns run on past the ones its scan helper knows about
+// into dest
+type extraColumns struct {
+	pgx.Row
+	dest []any
+}
+
+func (r extraColumns) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.dest...)...)
+}
+
+// GetOfferedServiceDurations returns the durations of the active services an employee offers.
+// Employees without employee_services rows are treated as offering every active service.
+func GetOfferedServiceDurations(ctx context.Context, employeeID int) ([]int, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT s.duration_minutes FROM services s
+		WHERE s.active AND (
+			NOT EXISTS (SELECT 1 FROM employee_services WHERE employee_id = $1)
+			OR s.id IN (SELECT service_id FROM employee_services WHERE employee_id = $1)
+		) ORDER BY s.duration_minutes`, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
+
+// GetWaitingListCandidates returns active waiting list entries the employee could serve,
+// most urgent first
+func GetWaitingListCandidates(ctx context.Context, employeeID int) ([]models.WaitingListCandidate, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT w.id, w.patient_id, w.service_id, s.duration_minutes, w.urgency_level, to_char(w.requested_date, 'YYYY-MM-DD')
+		FROM waiting_list w JOIN services s ON s.id = w.service_id
+		WHERE w.status = 'ACTIVE' AND s.active
+		AND (w.preferred_employee_id IS NULL OR w.preferred_employee_id = $1)
+		AND (
+			NOT EXISTS (SELECT 1 FROM employee_services WHERE employee_id = $1)
+			OR w.service_id IN (SELECT service_id FROM employee_services WHERE employee_id = $1)
+		)
+		ORDER BY w.urgency_level DESC, w.created_at`, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []models.WaitingListCandidate
+	for rows.Next() {
+		var c models.WaitingListCandidate
+		if err := rows.Scan(&c.WaitingListID, &c.PatientID, &c.ServiceID, &c.DurationMinutes, &c.UrgencyLevel, &c.RequestedDate); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
@@ -0,0 +1,55 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+)
+
+// Calendar feed owners
+const (
+	FeedOwnerEmployee = "EMPLOYEE"
+	FeedOwnerPatient  = "PATIENT"
+)
+
+// SetCalendarFeed stores the token hash of an owner's calendar feed, replacing (and so
+// revoking) any earlier feed URL
+func SetCalendarFeed(ctx context.Context, ownerType string, ownerID int, tokenHash string) (int, error) {
+	var id int
+	err := conn(ctx).QueryRow(ctx,
+		`INSERT INTO calendar_feeds (owner_type, owner_id, token_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (owner_type, owner_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = CURRENT_TIMESTAMP
+		RETURNING id`,
+		ownerType, ownerID, tokenHash).Scan(&id)
+	return id, err
+}
+
+// DeleteCalendarFeed revokes an owner's calendar feed and returns its id, reporting
+// pgx.ErrNoRows when there was none
+func DeleteCalendarFeed(ctx context.Context, ownerType string, ownerID int) (int, error) {
+	var id int
+	err := conn(ctx).QueryRow(ctx,
+		"DELETE FROM calendar_feeds WHERE owner_type = $1 AND owner_id = $2 RETURNING id", ownerType, ownerID).Scan(&id)
+	return id, err
+}
+
+// GetCalendarFeedOwner looks up whose calendar a feed token hash belongs to
+func GetCalendarFeedOwner(ctx context.Context, tokenHash string) (ownerType string, ownerID int, err error) {
+	err = conn(ctx).QueryRow(ctx,
+		"SELECT owner_type, owner_id FROM calendar_feeds WHERE token_hash = $1", tokenHash).Scan(&ownerType, &ownerID)
+	return ownerType, ownerID, err
+}
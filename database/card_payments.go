@@ -0,0 +1,153 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const cardPaymentColumns = "id, appointment_id, payment_intent_id, amount_minor, currency, status, refund_id, created_at, updated_at"
+
+// scanCardPayment scans a row selected with cardPaymentColumns
+func scanCardPayment(row pgx.Row) (*models.CardPayment, error) {
+	var p models.CardPayment
+	err := row.Scan(&p.ID, &p.AppointmentID, &p.PaymentIntentID, &p.Amount.Amount, &p.Amount.Currency, &p.Status,
+		&p.RefundID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreateCardPayment records a newly created PaymentIntent as a PENDING payment
+func CreateCardPayment(ctx context.Context, p *models.CardPayment) error {
+	p.Status = "PENDING"
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO card_payments (appointment_id, payment_intent_id, amount_minor, currency, status) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at",
+		p.AppointmentID, p.PaymentIntentID, p.Amount.Amount, p.Amount.Currency, p.Status).
+		Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+}
+
+// GetCardPayments lists an appointment's card payments, oldest first
+func GetCardPayments(ctx context.Context, appointmentID int) ([]models.CardPayment, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+cardPaymentColumns+" FROM card_payments WHERE appointment_id = $1 ORDER BY id", appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []models.CardPayment{}
+	for rows.Next() {
+		p, err := scanCardPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, *p)
+	}
+	return payments, rows.Err()
+}
+
+// GetLatestCardPayment returns an appointment's most recent card payment in one of the given
+// statuses, or pgx.ErrNoRows when there is none
+func GetLatestCardPayment(ctx context.Context, appointmentID int, statuses ...string) (*models.CardPayment, error) {
+	return scanCardPayment(conn(ctx).QueryRow(ctx,
+		"SELECT "+cardPaymentColumns+" FROM card_payments WHERE appointment_id = $1 AND status = ANY($2) ORDER BY id DESC LIMIT 1",
+		appointmentID, statuses))
+}
+
+// MarkCardPaymentSucceeded settles a pending or failed payment and marks its appointment PAID.
+// It reports pgx.ErrNoRows when the PaymentIntent is unknown or already settled.
+func MarkCardPaymentSucceeded(ctx context.Context, paymentIntentID string) (*models.CardPayment, error) {
+	var payment *models.CardPayment
+	err := WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		payment, err = scanCardPayment(conn(ctx).QueryRow(ctx,
+			`UPDATE card_payments SET status = 'SUCCEEDED', updated_at = CURRENT_TIMESTAMP
+			WHERE payment_intent_id = $1 AND status IN ('PENDING', 'FAILED')
+			RETURNING `+cardPaymentColumns, paymentIntentID))
+		if err != nil {
+			return err
+		}
+		_, err = conn(ctx).Exec(ctx,
+			"UPDATE appointments SET payment_status = 'PAID', updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND payment_status = 'PENDING'",
+			payment.AppointmentID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// MarkCardPaymentFailed records that the patient's payment attempt was declined; the
+// PaymentIntent stays usable for another attempt
+func MarkCardPaymentFailed(ctx context.Context, paymentIntentID string) error {
+	_, err := conn(ctx).Exec(ctx,
+		"UPDATE card_payments SET status = 'FAILED', updated_at = CURRENT_TIMESTAMP WHERE payment_intent_id = $1 AND status = 'PENDING'",
+		paymentIntentID)
+	return err
+}
+
+// SetCardPaymentRefundPending records a refund issued for a succeeded payment that the
+// provider has not completed yet
+func SetCardPaymentRefundPending(ctx context.Context, id int, refundID string) error {
+	_, err := conn(ctx).Exec(ctx,
+		"UPDATE card_payments SET status = 'REFUND_PENDING', refund_id = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'SUCCEEDED'",
+		id, refundID)
+	return err
+}
+
+// MarkCardPaymentRefunded marks a succeeded payment refunded, and its appointment REFUNDED.
+// refundID is kept from SetCardPaymentRefundPending when nil. It reports pgx.ErrNoRows when
+// the PaymentIntent is unknown or was never paid.
+func MarkCardPaymentRefunded(ctx context.Context, paymentIntentID string, refundID *string) (*models.CardPayment, error) {
+	var payment *models.CardPayment
+	err := WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		payment, err = scanCardPayment(conn(ctx).QueryRow(ctx,
+			`UPDATE card_payments SET status = 'REFUNDED', refund_id = COALESCE($2, refund_id), updated_at = CURRENT_TIMESTAMP
+			WHERE payment_intent_id = $1 AND status IN ('SUCCEEDED', 'REFUND_PENDING')
+			RETURNING `+cardPaymentColumns, paymentIntentID, refundID))
+		if err != nil {
+			return err
+		}
+		_, err = conn(ctx).Exec(ctx,
+			"UPDATE appointments SET payment_status = 'REFUNDED', updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND payment_status = 'PAID'",
+			payment.AppointmentID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// RecordStripeEvent remembers a webhook event and reports whether it is new. Run it in the
+// transaction that applies the event, so an event whose handling fails is retried.
+func RecordStripeEvent(ctx context.Context, eventID, eventType string) (bool, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		"INSERT INTO stripe_events (event_id, type) VALUES ($1, $2) ON CONFLICT (organization_id, event_id) DO NOTHING", eventID, eventType)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,190 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The chart columns format dates as the "YYYY-MM-DD" strings the models use
+const (
+	allergyColumns = "id, patient_id, substance, code_system, code, reaction, criticality, status, " +
+		"to_char(onset_date, 'YYYY-MM-DD'), recorded_by, created_at, updated_at"
+	medicationColumns = "id, patient_id, name, code_system, code, dosage, status, " +
+		"to_char(started_on, 'YYYY-MM-DD'), to_char(stopped_on, 'YYYY-MM-DD'), recorded_by, created_at, updated_at"
+	conditionColumns = "id, patient_id, name, code_system, code, status, " +
+		"to_char(onset_date, 'YYYY-MM-DD'), to_char(resolved_on, 'YYYY-MM-DD'), recorded_by, created_at, updated_at"
+)
+
+func scanAllergy(row pgx.Row, a *models.Allergy) error {
+	return row.Scan(&a.ID, &a.PatientID, &a.Substance, &a.CodeSystem, &a.Code, &a.Reaction, &a.Criticality, &a.Status,
+		&a.OnsetDate, &a.RecordedBy, &a.CreatedAt, &a.UpdatedAt)
+}
+
+func scanMedication(row pgx.Row, m *models.Medication) error {
+	return row.Scan(&m.ID, &m.PatientID, &m.Name, &m.CodeSystem, &m.Code, &m.Dosage, &m.Status,
+		&m.StartedOn, &m.StoppedOn, &m.RecordedBy, &m.CreatedAt, &m.UpdatedAt)
+}
+
+func scanCondition(row pgx.Row, c *models.Condition) error {
+	return row.Scan(&c.ID, &c.PatientID, &c.Name, &c.CodeSystem, &c.Code, &c.Status,
+		&c.OnsetDate, &c.ResolvedOn, &c.RecordedBy, &c.CreatedAt, &c.UpdatedAt)
+}
+
+// collectChart scans every row of a chart query with scan and closes the rows
+func collectChart[T any](rows pgx.Rows, scan func(pgx.Row, *T) error) ([]T, error) {
+	defer rows.Close()
+	entries := []T{}
+	for rows.Next() {
+		var entry T
+		if err := scan(rows, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Allergy operations
+
+// ListAllergies returns a patient's allergies, active ones first and critical ones first
+// among those
+func ListAllergies(ctx context.Context, patientID int) ([]models.Allergy, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+allergyColumns+` FROM patient_allergies WHERE patient_id = $1
+		ORDER BY status = 'ACTIVE' DESC, criticality = 'HIGH' DESC, substance, id`, patientID)
+	if err != nil {
+		return nil, err
+	}
+	return collectChart(rows, scanAllergy)
+}
+
+// ListCriticalAllergies returns a patient's ACTIVE allergies of HIGH criticality
+func ListCriticalAllergies(ctx context.Context, patientID int) ([]models.Allergy, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+allergyColumns+` FROM patient_allergies WHERE patient_id = $1 AND status = 'ACTIVE' AND criticality = 'HIGH'
+		ORDER BY substance, id`, patientID)
+	if err != nil {
+		return nil, err
+	}
+	return collectChart(rows, scanAllergy)
+}
+
+// CreateAllergy adds an allergy to a patient's chart and fills it in as stored
+func CreateAllergy(ctx context.Context, a *models.Allergy) error {
+	return scanAllergy(conn(ctx).QueryRow(ctx,
+		`INSERT INTO patient_allergies (patient_id, substance, code_system, code, reaction, criticality, status, onset_date, recorded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8::date, $9) RETURNING `+allergyColumns,
+		a.PatientID, a.Substance, a.CodeSystem, a.Code, a.Reaction, a.Criticality, a.Status, a.OnsetDate, a.RecordedBy), a)
+}
+
+// UpdateAllergy replaces an allergy on the patient's chart, reporting pgx.ErrNoRows when the
+// patient has no such allergy
+func UpdateAllergy(ctx context.Context, a *models.Allergy) error {
+	return scanAllergy(conn(ctx).QueryRow(ctx,
+		`UPDATE patient_allergies SET substance = $3, code_system = $4, code = $5, reaction = $6, criticality = $7, status = $8,
+		onset_date = $9::date, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND patient_id = $2 RETURNING `+allergyColumns,
+		a.ID, a.PatientID, a.Substance, a.CodeSystem, a.Code, a.Reaction, a.Criticality, a.Status, a.OnsetDate), a)
+}
+
+// DeleteAllergy removes an allergy from the patient's chart, reporting pgx.ErrNoRows when
+// the patient has no such allergy
+func DeleteAllergy(ctx context.Context, patientID, id int) error {
+	return conn(ctx).QueryRow(ctx,
+		"DELETE FROM patient_allergies WHERE id = $1 AND patient_id = $2 RETURNING id", id, patientID).Scan(&id)
+}
+
+// Medication operations
+
+// ListMedications returns a patient's medications, active ones first
+func ListMedications(ctx context.Context, patientID int) ([]models.Medication, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+medicationColumns+` FROM patient_medications WHERE patient_id = $1
+		ORDER BY status = 'ACTIVE' DESC, name, id`, patientID)
+	if err != nil {
+		return nil, err
+	}
+	return collectChart(rows, scanMedication)
+}
+
+// CreateMedication adds a medication to a patient's chart and fills it in as stored
+func CreateMedication(ctx context.Context, m *models.Medication) error {
+	return scanMedication(conn(ctx).QueryRow(ctx,
+		`INSERT INTO patient_medications (patient_id, name, code_system, code, dosage, status, started_on, stopped_on, recorded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::date, $8::date, $9) RETURNING `+medicationColumns,
+		m.PatientID, m.Name, m.CodeSystem, m.Code, m.Dosage, m.Status, m.StartedOn, m.StoppedOn, m.RecordedBy), m)
+}
+
+// UpdateMedication replaces a medication on the patient's chart, reporting pgx.ErrNoRows
+// when the patient has no such medication
+func UpdateMedication(ctx context.Context, m *models.Medication) error {
+	return scanMedication(conn(ctx).QueryRow(ctx,
+		`UPDATE patient_medications SET name = $3, code_system = $4, code = $5, dosage = $6, status = $7,
+		started_on = $8::date, stopped_on = $9::date, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND patient_id = $2 RETURNING `+medicationColumns,
+		m.ID, m.PatientID, m.Name, m.CodeSystem, m.Code, m.Dosage, m.Status, m.StartedOn, m.StoppedOn), m)
+}
+
+// DeleteMedication removes a medication from the patient's chart, reporting pgx.ErrNoRows
+// when the patient has no such medication
+func DeleteMedication(ctx context.Context, patientID, id int) error {
+	return conn(ctx).QueryRow(ctx,
+		"DELETE FROM patient_medications WHERE id = $1 AND patient_id = $2 RETURNING id", id, patientID).Scan(&id)
+}
+
+// Condition operations
+
+// ListConditions returns a patient's conditions, active ones first, latest onset first
+func ListConditions(ctx context.Context, patientID int) ([]models.Condition, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+conditionColumns+` FROM patient_conditions WHERE patient_id = $1
+		ORDER BY status = 'ACTIVE' DESC, onset_date DESC NULLS LAST, name, id`, patientID)
+	if err != nil {
+		return nil, err
+	}
+	return collectChart(rows, scanCondition)
+}
+
+// CreateCondition adds a condition to a patient's chart and fills it in as stored
+func CreateCondition(ctx context.Context, c *models.Condition) error {
+	return scanCondition(conn(ctx).QueryRow(ctx,
+		`INSERT INTO patient_conditions (patient_id, name, code_system, code, status, onset_date, resolved_on, recorded_by)
+		VALUES ($1, $2, $3, $4, $5, $6::date, $7::date, $8) RETURNING `+conditionColumns,
+		c.PatientID, c.Name, c.CodeSystem, c.Code, c.Status, c.OnsetDate, c.ResolvedOn, c.RecordedBy), c)
+}
+
+// UpdateCondition replaces a condition on the patient's chart, reporting pgx.ErrNoRows when
+// the patient has no such condition
+func UpdateCondition(ctx context.Context, c *models.Condition) error {
+	return scanCondition(conn(ctx).QueryRow(ctx,
+		`UPDATE patient_conditions SET name = $3, code_system = $4, code = $5, status = $6,
+		onset_date = $7::date, resolved_on = $8::date, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND patient_id = $2 RETURNING `+conditionColumns,
+		c.ID, c.PatientID, c.Name, c.CodeSystem, c.Code, c.Status, c.OnsetDate, c.ResolvedOn), c)
+}
+
+// DeleteCondition removes a condition from the patient's chart, reporting pgx.ErrNoRows when
+// the patient has no such condition
+func DeleteCondition(ctx context.Context, patientID, id int) error {
+	return conn(ctx).QueryRow(ctx,
+		"DELETE FROM patient_conditions WHERE id = $1 AND patient_id = $2 RETURNING id", id, patientID).Scan(&id)
+}
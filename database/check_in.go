@@ -0,0 +1,57 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CheckInAppointment records that the patient of an appointment at the given version
+// arrived at now and returns the updated appointment. It returns ErrStaleVersion when the
+// appointment has changed since and pgx.ErrNoRows when there is no such appointment.
+func CheckInAppointment(ctx context.Context, id, version int, now time.Time) (*models.Appointment, error) {
+	return stampAppointment(ctx, id,
+		"UPDATE appointments SET checked_in_at = $3, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND version = $2 RETURNING "+appointmentColumns,
+		version, now.UTC())
+}
+
+// CheckOutAppointment completes an appointment at the given version, recording now as the
+// time the patient left, and returns the updated appointment. Errors are as for
+// CheckInAppointment.
+func CheckOutAppointment(ctx context.Context, id, version int, now time.Time) (*models.Appointment, error) {
+	return stampAppointment(ctx, id,
+		"UPDATE appointments SET status = 'COMPLETED', checked_out_at = $3, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND version = $2 RETURNING "+appointmentColumns,
+		version, now.UTC())
+}
+
+func stampAppointment(ctx context.Context, id int, sql string, version int, now time.Time) (*models.Appointment, error) {
+	var appointment models.Appointment
+	err := scanAppointment(conn(ctx).QueryRow(ctx, sql, id, version, now), &appointment)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, versionError(ctx, "appointments", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
@@ -0,0 +1,107 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+
+	"bookings/models"
+)
+
+// clinicHoursColumns formats times as the "HH:MM" strings the model uses
+const clinicHoursColumns = "id, clinic_id, weekday, to_char(open_time, 'HH24:MI'), to_char(close_time, 'HH24:MI')"
+
+// ListClinicHours returns all of a clinic's opening hours, in weekly order
+func ListClinicHours(ctx context.Context, clinicID int) ([]models.ClinicHours, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+clinicHoursColumns+" FROM clinic_hours WHERE clinic_id = $1 ORDER BY weekday, open_time", clinicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := []models.ClinicHours{}
+	for rows.Next() {
+		var h models.ClinicHours
+		if err := rows.Scan(&h.ID, &h.ClinicID, &h.Weekday, &h.OpenTime, &h.CloseTime); err != nil {
+			return nil, err
+		}
+		hours = append(hours, h)
+	}
+	return hours, rows.Err()
+}
+
+// CreateClinicHours adds an opening window
+func CreateClinicHours(ctx context.Context, h *models.ClinicHours) error {
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO clinic_hours (clinic_id, weekday, open_time, close_time) VALUES ($1, $2, $3::time, $4::time) RETURNING id",
+		h.ClinicID, h.Weekday, h.OpenTime, h.CloseTime).Scan(&h.ID)
+}
+
+// UpdateClinicHours replaces an opening window of the clinic, reporting pgx.ErrNoRows when
+// the clinic has no such window
+func UpdateClinicHours(ctx context.Context, h *models.ClinicHours) error {
+	return conn(ctx).QueryRow(ctx,
+		"UPDATE clinic_hours SET weekday = $3, open_time = $4::time, close_time = $5::time WHERE id = $1 AND clinic_id = $2 RETURNING id",
+		h.ID, h.ClinicID, h.Weekday, h.OpenTime, h.CloseTime).Scan(&h.ID)
+}
+
+// DeleteClinicHours removes an opening window of the clinic, reporting pgx.ErrNoRows when
+// the clinic has no such window
+func DeleteClinicHours(ctx context.Context, clinicID, id int) error {
+	return conn(ctx).QueryRow(ctx,
+		"DELETE FROM clinic_hours WHERE id = $1 AND clinic_id = $2 RETURNING id", id, clinicID).Scan(&id)
+}
+
+// ListClinicHolidays returns the clinic's holidays between two dates (inclusive, "YYYY-MM-DD")
+func ListClinicHolidays(ctx context.Context, clinicID int, from, to string) ([]models.ClinicHoliday, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT id, clinic_id, to_char(date, 'YYYY-MM-DD'), name FROM clinic_holidays WHERE clinic_id = $1 AND date BETWEEN $2::date AND $3::date ORDER BY date",
+		clinicID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := []models.ClinicHoliday{}
+	for rows.Next() {
+		var h models.ClinicHoliday
+		if err := rows.Scan(&h.ID, &h.ClinicID, &h.Date, &h.Name); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, h)
+	}
+	return holidays, rows.Err()
+}
+
+// UpsertClinicHoliday creates or renames the clinic's holiday on its date
+func UpsertClinicHoliday(ctx context.Context, h *models.ClinicHoliday) error {
+	return conn(ctx).QueryRow(ctx,
+		`INSERT INTO clinic_holidays (clinic_id, date, name) VALUES ($1, $2::date, $3)
+		ON CONFLICT (clinic_id, date) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id`,
+		h.ClinicID, h.Date, h.Name).Scan(&h.ID)
+}
+
+// DeleteClinicHoliday removes the clinic's holiday on a date and returns its id, reporting
+// pgx.ErrNoRows when there is none
+func DeleteClinicHoliday(ctx context.Context, clinicID int, date string) (int, error) {
+	var id int
+	err := conn(ctx).QueryRow(ctx,
+		"DELETE FROM clinic_holidays WHERE clinic_id = $1 AND date = $2::date RETURNING id", clinicID, date).Scan(&id)
+	return id, err
+}
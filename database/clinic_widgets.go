@@ -0,0 +1,94 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrWidgetSlugTaken is returned when another clinic's widget already has the slug
+var ErrWidgetSlugTaken = errors.New("widget slug is already taken")
+
+const clinicWidgetColumns = "clinic_id, slug, enabled, logo_url, primary_color, welcome_message, allowed_origins, created_at, updated_at"
+
+// scanClinicWidget scans a row selected with clinicWidgetColumns
+func scanClinicWidget(row pgx.Row, w *models.ClinicWidget) error {
+	return row.Scan(&w.ClinicID, &w.Slug, &w.Enabled, &w.LogoURL, &w.PrimaryColor, &w.WelcomeMessage,
+		&w.AllowedOrigins, &w.CreatedAt, &w.UpdatedAt)
+}
+
+// GetClinicWidget returns the clinic's widget settings, or pgx.ErrNoRows when it has none
+func GetClinicWidget(ctx context.Context, clinicID int) (*models.ClinicWidget, error) {
+	var w models.ClinicWidget
+	err := scanClinicWidget(conn(ctx).QueryRow(ctx,
+		"SELECT "+clinicWidgetColumns+" FROM clinic_widgets WHERE clinic_id = $1", clinicID), &w)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// GetClinicWidgetBySlug returns the widget settings with the slug, or pgx.ErrNoRows when
+// there are none
+func GetClinicWidgetBySlug(ctx context.Context, slug string) (*models.ClinicWidget, error) {
+	var w models.ClinicWidget
+	err := scanClinicWidget(conn(ctx).QueryRow(ctx,
+		"SELECT "+clinicWidgetColumns+" FROM clinic_widgets WHERE slug = $1", slug), &w)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// UpsertClinicWidget creates or replaces the clinic's widget settings, returning
+// ErrWidgetSlugTaken when another clinic has the slug
+func UpsertClinicWidget(ctx context.Context, w *models.ClinicWidget) error {
+	if w.AllowedOrigins == nil {
+		w.AllowedOrigins = []string{}
+	}
+	err := conn(ctx).QueryRow(ctx,
+		`INSERT INTO clinic_widgets (clinic_id, slug, enabled, logo_url, primary_color, welcome_message, allowed_origins)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (clinic_id) DO UPDATE SET slug = EXCLUDED.slug, enabled = EXCLUDED.enabled, logo_url = EXCLUDED.logo_url,
+			primary_color = EXCLUDED.primary_color, welcome_message = EXCLUDED.welcome_message,
+			allowed_origins = EXCLUDED.allowed_origins, updated_at = CURRENT_TIMESTAMP
+		RETURNING created_at, updated_at`,
+		w.ClinicID, w.Slug, w.Enabled, w.LogoURL, w.PrimaryColor, w.WelcomeMessage, w.AllowedOrigins).Scan(&w.CreatedAt, &w.UpdatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrWidgetSlugTaken
+	}
+	return err
+}
+
+// DeleteClinicWidget removes the clinic's widget settings, reporting pgx.ErrNoRows when it has none
+func DeleteClinicWidget(ctx context.Context, clinicID int) error {
+	tag, err := conn(ctx).Exec(ctx, "DELETE FROM clinic_widgets WHERE clinic_id = $1", clinicID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Consent errors
+var (
+	ErrConsentVersionTaken = errors.New("a consent document of this kind already has this version")
+	ErrConsentWithdrawn    = errors.New("consent has already been withdrawn")
+	// ErrConsentSuperseded is returned when consenting to a version a newer one has replaced
+	ErrConsentSuperseded = errors.New("this consent document has been replaced by a newer version")
+)
+
+const (
+	consentDocumentColumns = "id, kind, version, title, body, effective_at, created_by, created_at"
+	// patientConsentColumns select from patient_consents pc joined to consent_documents d
+	patientConsentColumns = "pc.id, pc.patient_id, pc.consent_document_id, d.kind, d.version, pc.capture_method, pc.captured_at, " +
+		"pc.recorded_by, pc.withdrawn_at, pc.withdrawn_by, pc.withdrawn_at IS NULL AND d.id = (" + currentConsentDocument + ")"
+	// currentConsentDocument is the id of the current version of d's kind: the latest in effect
+	currentConsentDocument = "SELECT cd.id FROM consent_documents cd WHERE cd.kind = d.kind AND cd.effective_at <= CURRENT_TIMESTAMP " +
+		"ORDER BY cd.effective_at DESC, cd.id DESC LIMIT 1"
+)
+
+func scanConsentDocument(row pgx.Row, d *models.ConsentDocument) error {
+	return row.Scan(&d.ID, &d.Kind, &d.Version, &d.Title, &d.Body, &d.EffectiveAt, &d.CreatedBy, &d.CreatedAt)
+}
+
+func collectConsentDocuments(rows pgx.Rows) ([]models.ConsentDocument, error) {
+	defer rows.Close()
+	documents := []models.ConsentDocument{}
+	for rows.Next() {
+		var d models.ConsentDocument
+		if err := scanConsentDocument(rows, &d); err != nil {
+			return nil, err
+		}
+		documents = append(documents, d)
+	}
+	return documents, rows.Err()
+}
+
+func scanPatientConsent(row pgx.Row, c *models.PatientConsent) error {
+	return row.Scan(&c.ID, &c.PatientID, &c.ConsentDocumentID, &c.Kind, &c.Version, &c.CaptureMethod, &c.CapturedAt,
+		&c.RecordedBy, &c.WithdrawnAt, &c.WithdrawnBy, &c.Current)
+}
+
+// Consent document operations
+
+// GetConsentDocuments returns the consent documents, optionally of one kind, latest in effect first
+func GetConsentDocuments(ctx context.Context, kind *string) ([]models.ConsentDocument, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+consentDocumentColumns+" FROM consent_documents WHERE ($1::text IS NULL OR kind = $1) ORDER BY effective_at DESC, id DESC", kind)
+	if err != nil {
+		return nil, err
+	}
+	return collectConsentDocuments(rows)
+}
+
+func GetConsentDocument(ctx context.Context, id int) (*models.ConsentDocument, error) {
+	var d models.ConsentDocument
+	if err := scanConsentDocument(conn(ctx).QueryRow(ctx, "SELECT "+consentDocumentColumns+" FROM consent_documents WHERE id = $1", id), &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// GetCurrentConsentDocuments returns the current version of each kind that has one in effect,
+// optionally only of the given kinds
+func GetCurrentConsentDocuments(ctx context.Context, kinds []string) ([]models.ConsentDocument, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT DISTINCT ON (kind) "+consentDocumentColumns+` FROM consent_documents
+		WHERE effective_at <= CURRENT_TIMESTAMP AND ($1::text[] IS NULL OR kind = ANY($1))
+		ORDER BY kind, effective_at DESC, id DESC`, kinds)
+	if err != nil {
+		return nil, err
+	}
+	return collectConsentDocuments(rows)
+}
+
+// CreateConsentDocument publishes a consent document version, in effect from EffectiveAt or
+// now when that is zero, and fills it in as stored. A version the kind already has gives
+// ErrConsentVersionTaken.
+func CreateConsentDocument(ctx context.Context, d *models.ConsentDocument) error {
+	var effectiveAt *time.Time
+	if !d.EffectiveAt.IsZero() {
+		effectiveAt = &d.EffectiveAt
+	}
+	err := scanConsentDocument(conn(ctx).QueryRow(ctx,
+		`INSERT INTO consent_documents (kind, version, title, body, effective_at, created_by)
+		VALUES ($1, $2, $3, $4, COALESCE($5, CURRENT_TIMESTAMP), $6) RETURNING `+consentDocumentColumns,
+		d.Kind, d.Version, d.Title, d.Body, effectiveAt, d.CreatedBy), d)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrConsentVersionTaken
+	}
+	return err
+}
+
+// Patient consent operations
+
+// GetPatientConsents returns a patient's consents, latest first, withdrawn ones included
+func GetPatientConsents(ctx context.Context, patientID int) ([]models.PatientConsent, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+patientConsentColumns+` FROM patient_consents pc JOIN consent_documents d ON d.id = pc.consent_document_id
+		WHERE pc.patient_id = $1 ORDER BY pc.captured_at DESC, pc.id DESC`, patientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	consents := []models.PatientConsent{}
+	for rows.Next() {
+		var c models.PatientConsent
+		if err := scanPatientConsent(rows, &c); err != nil {
+			return nil, err
+		}
+		consents = append(consents, c)
+	}
+	return consents, rows.Err()
+}
+
+// RecordConsent stores a patient's consent to a document and fills it in as stored. The
+// document must be its kind's current version or one not yet in effect; an older one gives
+// ErrConsentSuperseded, and one that does not exist pgx.ErrNoRows.
+func RecordConsent(ctx context.Context, c *models.PatientConsent) error {
+	err := scanPatientConsent(conn(ctx).QueryRow(ctx,
+		`WITH pc AS (
+			INSERT INTO patient_consents (patient_id, consent_document_id, capture_method, recorded_by)
+			SELECT $1, d.id, $3, $4 FROM consent_documents d
+			WHERE d.id = $2 AND NOT EXISTS (
+				SELECT 1 FROM consent_documents n WHERE n.kind = d.kind AND n.effective_at <= CURRENT_TIMESTAMP AND n.effective_at > d.effective_at
+			)
+			RETURNING *
+		)
+		SELECT `+patientConsentColumns+" FROM pc JOIN consent_documents d ON d.id = pc.consent_document_id",
+		c.PatientID, c.ConsentDocumentID, c.CaptureMethod, c.RecordedBy), c)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, err := GetConsentDocument(ctx, c.ConsentDocumentID); err != nil {
+			return err
+		}
+		return ErrConsentSuperseded
+	}
+	return err
+}
+
+// WithdrawConsent withdraws one of a patient's consents and returns it as withdrawn. It gives
+// ErrConsentWithdrawn when it already was, and pgx.ErrNoRows when the patient has no such consent.
+func WithdrawConsent(ctx context.Context, patientID, id int, withdrawnBy *int, at time.Time) (*models.PatientConsent, error) {
+	var c models.PatientConsent
+	err := scanPatientConsent(conn(ctx).QueryRow(ctx,
+		`WITH pc AS (
+			UPDATE patient_consents SET withdrawn_at = $3, withdrawn_by = $4
+			WHERE id = $1 AND patient_id = $2 AND withdrawn_at IS NULL RETURNING *
+		)
+		SELECT `+patientConsentColumns+" FROM pc JOIN consent_documents d ON d.id = pc.consent_document_id",
+		id, patientID, at, withdrawnBy), &c)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		if err := conn(ctx).QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM patient_consents WHERE id = $1 AND patient_id = $2)", id, patientID).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrConsentWithdrawn
+		}
+		return nil, pgx.ErrNoRows
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// MissingConsents returns the current versions of the given kinds the patient has not agreed
+// to, or has withdrawn their agreement to. Kinds without a version in effect are not required.
+func MissingConsents(ctx context.Context, patientID int, kinds []string) ([]models.ConsentDocument, error) {
+	if len(kinds) == 0 {
+		return []models.ConsentDocument{}, nil
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+consentDocumentColumns+" FROM ("+
+			"SELECT DISTINCT ON (kind) "+consentDocumentColumns+` FROM consent_documents
+			WHERE effective_at <= CURRENT_TIMESTAMP AND kind = ANY($2)
+			ORDER BY kind, effective_at DESC, id DESC
+		) d
+		WHERE NOT EXISTS (SELECT 1 FROM patient_consents WHERE patient_id = $1 AND consent_document_id = d.id AND withdrawn_at IS NULL)
+		ORDER BY kind`, patientID, kinds)
+	if err != nil {
+		return nil, err
+	}
+	return collectConsentDocuments(rows)
+}
+
+// missingConsentWhere matches the active patients of p without a consent in force to the
+// document passed as $1
+const missingConsentWhere = ` WHERE p.active AND p.deleted_at IS NULL AND NOT EXISTS (
+		SELECT 1 FROM patient_consents WHERE patient_id = p.id AND consent_document_id = $1 AND withdrawn_at IS NULL
+	)`
+
+// PatientMissingConsent is a patient who has not agreed to a consent document, with the latest
+// version of its kind they agreed to, if any
+type PatientMissingConsent struct {
+	models.PatientSummary
+	LastVersion     *string    `json:"last_version"`
+	LastConsentedAt *time.Time `json:"last_consented_at"`
+}
+
+// GetPatientsMissingConsent returns one page of the active patients who have not agreed to a
+// consent document, or have withdrawn their agreement, by name, with the total number of them
+func GetPatientsMissingConsent(ctx context.Context, document *models.ConsentDocument, page Page) ([]PatientMissingConsent, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM patients p"+missingConsentWhere, document.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT p.id, p.public_id::text, p.first_name, p.last_name, COALESCE(p.email, ''), COALESCE(p.phone, ''), last.version, last.captured_at
+		FROM patients p LEFT JOIN LATERAL (
+			SELECT d.version, pc.captured_at FROM patient_consents pc JOIN consent_documents d ON d.id = pc.consent_document_id
+			WHERE pc.patient_id = p.id AND d.kind = $2 AND pc.withdrawn_at IS NULL
+			ORDER BY d.effective_at DESC, pc.captured_at DESC LIMIT 1
+		) last ON TRUE`+missingConsentWhere+`
+		ORDER BY p.last_name, p.first_name, p.id LIMIT $3 OFFSET $4`,
+		document.ID, document.Kind, page.limit(), page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	patients := []PatientMissingConsent{}
+	for rows.Next() {
+		var p PatientMissingConsent
+		if err := rows.Scan(&p.ID, &p.PublicID, &p.FirstName, &p.LastName, &p.Email, &p.Phone, &p.LastVersion, &p.LastConsentedAt); err != nil {
+			return nil, 0, err
+		}
+		patients = append(patients, p)
+	}
+	return patients, total, rows.Err()
+}
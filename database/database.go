@@ -18,31 +18,76 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"strconv"
+	"time"
 
+	"bookings/config"
 	"bookings/models"
+	"bookings/money"
+	"bookings/phi"
+	"bookings/timeutil"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var DB *pgxpool.Pool
 
-// InitDB initializes the database connection
-func InitDB() {
+// InitDB initializes the database connection with the configured pool size. Every connection
+// gets QueryTimeout as its statement_timeout, and is set to the organization of the context
+// it is taken from the pool for. Sessions run in UTC and timestamps are read
+// back in UTC whatever the host's local timezone, so conversion to local time only happens
+// where the employee's or clinic's timezone is known. Notices the server sends, such as the
+// counts data migrations report, are logged.
+func InitDB(pool config.Database) error {
 	connString := os.Getenv("DATABASE_URL")
 	if connString == "" {
-		log.Fatal("DATABASE_URL environment variable is not set. Please set it to your PostgreSQL connection string.")
+		return errors.New("DATABASE_URL environment variable is not set. Please set it to your PostgreSQL connection string")
+	}
+	timeout, err := QueryTimeout()
+	if err != nil {
+		return err
+	}
+	poolConfig, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+	poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(timeout.Milliseconds(), 10)
+	poolConfig.ConnConfig.RuntimeParams["timezone"] = "UTC"
+	poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		conn.TypeMap().RegisterType(&pgtype.Type{Name: "timestamptz", OID: pgtype.TimestamptzOID, Codec: &pgtype.TimestamptzCodec{ScanLocation: time.UTC}})
+		return nil
+	}
+	poolConfig.ConnConfig.OnNotice = func(_ *pgconn.PgConn, notice *pgconn.Notice) {
+		slog.Info("database notice", "message", notice.Message)
+	}
+	poolConfig.PrepareConn = prepareConn
+	poolConfig.BeforeClose = forgetConn
+	if pool.MaxConns > 0 {
+		poolConfig.MaxConns = pool.MaxConns
+	}
+	if pool.MinConns > 0 {
+		poolConfig.MinConns = pool.MinConns
 	}
 
-	var err error
-	DB, err = pgxpool.New(context.Background(), connString)
+	DB, err = pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
-		log.Fatalf("Unable to connect to database: %v\n", err)
+		return fmt.Errorf("unable to connect to database: %w", err)
 	}
 
-	fmt.Println("Connected to PostgreSQL database!")
+	slog.Info("connected to PostgreSQL database")
+	return nil
+}
+
+// Ping checks the database answers
+func Ping(ctx context.Context) error {
+	return DB.Ping(ctx)
 }
 
 // CloseDB closes the database connection
@@ -52,501 +97,910 @@ func CloseDB() {
 	}
 }
 
+// DefaultOrganizationID is the organization existing data and single-tenant deployments
+// belong to, and that rows created without one are put in
+const DefaultOrganizationID = 1
+
+// organizationOrDefault returns id, or DefaultOrganizationID when it is unset
+func organizationOrDefault(id int) int {
+	if id == 0 {
+		return DefaultOrganizationID
+	}
+	return id
+}
+
 // Clinic CRUD operations
-func GetClinics() ([]models.Clinic, error) {
-	rows, err := DB.Query(context.Background(), "SELECT id, name, address, phone, email, active FROM clinics ORDER BY id")
+const clinicColumns = "id, name, address, city, phone, email, active, sms_reminders_enabled, email_reminders_enabled, high_risk_extra_reminders, min_lead_minutes, max_advance_days, organization_id, deleted_at, latitude, longitude, phone_display, mrn_prefix, timezone"
+
+// scanClinic scans a row selected with clinicColumns
+func scanClinic(row pgx.Row, clinic *models.Clinic) error {
+	return row.Scan(&clinic.ID, &clinic.Name, &clinic.Address, &clinic.City, &clinic.Phone, &clinic.Email, &clinic.Active,
+		&clinic.SMSRemindersEnabled, &clinic.EmailRemindersEnabled, &clinic.HighRiskExtraReminders, &clinic.MinLeadMinutes, &clinic.MaxAdvanceDays,
+		&clinic.OrganizationID, &clinic.DeletedAt, &clinic.Latitude, &clinic.Longitude, &clinic.PhoneDisplay, &clinic.MRNPrefix, &clinic.Timezone)
+}
+
+// GetClinics lists an organization's clinics, leaving out soft-deleted ones unless
+// includeDeleted is set
+func GetClinics(ctx context.Context, page Page, includeDeleted bool, organizationID int) ([]models.Clinic, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM clinics WHERE organization_id = $2 AND ($1 OR deleted_at IS NULL)", includeDeleted, organizationID)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+clinicColumns+" FROM clinics WHERE organization_id = $4 AND ($3 OR deleted_at IS NULL) ORDER BY id LIMIT $1 OFFSET $2",
+		page.limit(), page.Offset, includeDeleted, organizationID)
+	if err != nil {
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var clinics []models.Clinic
 	for rows.Next() {
 		var clinic models.Clinic
-		err := rows.Scan(&clinic.ID, &clinic.Name, &clinic.Address, &clinic.Phone, &clinic.Email, &clinic.Active)
-		if err != nil {
+		if err := scanClinic(rows, &clinic); err != nil {
+			return nil, 0, err
+		}
+		clinics = append(clinics, clinic)
+	}
+	return clinics, total, nil
+}
+
+// NearbyClinic is a clinic with its distance from the point searched from
+type NearbyClinic struct {
+	models.Clinic
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// nearbyClinicsWhere keeps the clinics of organization $4 within $3 km of the point ($1, $2).
+// earth_box is a cheap indexed prefilter; earth_distance is the exact check.
+const nearbyClinicsWhere = ` WHERE organization_id = $4 AND deleted_at IS NULL AND latitude IS NOT NULL AND longitude IS NOT NULL
+	AND earth_box(ll_to_earth($1, $2), $3::float8 * 1000) @> ll_to_earth(latitude, longitude)
+	AND earth_distance(ll_to_earth($1, $2), ll_to_earth(latitude, longitude)) <= $3::float8 * 1000`
+
+// GetNearbyClinics returns one page of an organization's clinics within radiusKm of a point,
+// nearest first, with the total number in range. Clinics without coordinates are left out.
+func GetNearbyClinics(ctx context.Context, latitude, longitude, radiusKm float64, organizationID int, page Page) ([]NearbyClinic, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM clinics"+nearbyClinicsWhere, latitude, longitude, radiusKm, organizationID)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+clinicColumns+", earth_distance(ll_to_earth($1, $2), ll_to_earth(latitude, longitude)) / 1000 AS distance_km FROM clinics"+
+			nearbyClinicsWhere+" ORDER BY distance_km, id LIMIT $5 OFFSET $6",
+		latitude, longitude, radiusKm, organizationID, page.limit(), page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	clinics := []NearbyClinic{}
+	for rows.Next() {
+		var clinic NearbyClinic
+		if err := scanClinic(extraColumns{rows, []any{&clinic.DistanceKm}}, &clinic.Clinic); err != nil {
+			return nil, 0, err
+		}
+		clinics = append(clinics, clinic)
+	}
+	return clinics, total, rows.Err()
+}
+
+// GetBookableClinics lists the active clinics patients can book online, by name
+func GetBookableClinics(ctx context.Context) ([]models.Clinic, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+clinicColumns+" FROM clinics WHERE active AND deleted_at IS NULL ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clinics := []models.Clinic{}
+	for rows.Next() {
+		var clinic models.Clinic
+		if err := scanClinic(rows, &clinic); err != nil {
 			return nil, err
 		}
 		clinics = append(clinics, clinic)
 	}
-	return clinics, nil
+	return clinics, rows.Err()
 }
 
-func GetClinic(id int) (*models.Clinic, error) {
+// GetClinic loads a clinic whether or not it is soft-deleted, so existing appointments can
+// still show it
+func GetClinic(ctx context.Context, id int) (*models.Clinic, error) {
 	var clinic models.Clinic
-	err := DB.QueryRow(context.Background(),
-		"SELECT id, name, address, phone, email, active FROM clinics WHERE id = $1", id).
-		Scan(&clinic.ID, &clinic.Name, &clinic.Address, &clinic.Phone, &clinic.Email, &clinic.Active)
+	err := scanClinic(conn(ctx).QueryRow(ctx,
+		"SELECT "+clinicColumns+" FROM clinics WHERE id = $1", id), &clinic)
 	if err != nil {
 		return nil, err
 	}
 	return &clinic, nil
 }
 
-func CreateClinic(clinic *models.Clinic) error {
-	return DB.QueryRow(context.Background(),
-		"INSERT INTO clinics (name, address, phone, email, active) VALUES ($1, $2, $3, $4, $5) RETURNING id",
-		clinic.Name, clinic.Address, clinic.Phone, clinic.Email, clinic.Active).Scan(&clinic.ID)
+// CreateClinic inserts a clinic into its organization, the default one when it has none
+func CreateClinic(ctx context.Context, clinic *models.Clinic) error {
+	clinic.OrganizationID = organizationOrDefault(clinic.OrganizationID)
+	if err := normalizePhone(&clinic.Phone, &clinic.PhoneDisplay); err != nil {
+		return err
+	}
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO clinics (name, address, phone, email, active, sms_reminders_enabled, email_reminders_enabled, high_risk_extra_reminders, min_lead_minutes, max_advance_days, organization_id, city, latitude, longitude, phone_display, mrn_prefix, timezone) VALUES ($1, $2, $3, $4, $5, COALESCE($6, TRUE), COALESCE($7, TRUE), $8, $9, $10, $11, $12, $13, $14, $15, $16, $17) RETURNING id, sms_reminders_enabled, email_reminders_enabled, high_risk_extra_reminders",
+		clinic.Name, clinic.Address, clinic.Phone, clinic.Email, clinic.Active,
+		clinic.SMSRemindersEnabled, clinic.EmailRemindersEnabled, clinic.HighRiskExtraReminders, clinic.MinLeadMinutes, clinic.MaxAdvanceDays, clinic.OrganizationID, clinic.City, clinic.Latitude, clinic.Longitude, clinic.PhoneDisplay, clinic.MRNPrefix, clinic.Timezone).Scan(&clinic.ID, &clinic.SMSRemindersEnabled, &clinic.EmailRemindersEnabled, &clinic.HighRiskExtraReminders)
 }
 
-func UpdateClinic(id int, clinic *models.Clinic) error {
-	_, err := DB.Exec(context.Background(),
-		"UPDATE clinics SET name = $1, address = $2, phone = $3, email = $4, active = $5 WHERE id = $6",
-		clinic.Name, clinic.Address, clinic.Phone, clinic.Email, clinic.Active, id)
+func UpdateClinic(ctx context.Context, id int, clinic *models.Clinic) error {
+	if err := normalizePhone(&clinic.Phone, &clinic.PhoneDisplay); err != nil {
+		return err
+	}
+	_, err := conn(ctx).Exec(ctx,
+		"UPDATE clinics SET name = $1, address = $2, phone = $3, email = $4, active = $5, sms_reminders_enabled = COALESCE($6, TRUE), email_reminders_enabled = COALESCE($7, TRUE), high_risk_extra_reminders = $8, min_lead_minutes = $9, max_advance_days = $10, city = $12, latitude = $13, longitude = $14, phone_display = $15, mrn_prefix = $16, timezone = $17 WHERE id = $11",
+		clinic.Name, clinic.Address, clinic.Phone, clinic.Email, clinic.Active,
+		clinic.SMSRemindersEnabled, clinic.EmailRemindersEnabled, clinic.HighRiskExtraReminders, clinic.MinLeadMinutes, clinic.MaxAdvanceDays, id, clinic.City, clinic.Latitude, clinic.Longitude, clinic.PhoneDisplay, clinic.MRNPrefix, clinic.Timezone)
 	return err
 }
 
-func DeleteClinic(id int) error {
-	_, err := DB.Exec(context.Background(), "DELETE FROM clinics WHERE id = $1", id)
-	return err
+// DeleteClinic soft-deletes a clinic; pgx.ErrNoRows means there is no such clinic or it is
+// already deleted
+func DeleteClinic(ctx context.Context, id int) error {
+	return softDelete(ctx, "clinics", id)
+}
+
+// RestoreClinic undoes DeleteClinic; pgx.ErrNoRows means there is no deleted clinic with the id
+func RestoreClinic(ctx context.Context, id int) error {
+	return restore(ctx, "clinics", id)
 }
 
 // Patient CRUD operations
-func GetPatients() ([]models.Patient, error) {
-	rows, err := DB.Query(context.Background(),
-		"SELECT id, first_name, last_name, email, phone, date_of_birth, medical_record_number, insurance_provider, insurance_id, emergency_contact_name, emergency_contact_phone, active, created_at FROM patients ORDER BY id")
+const patientColumns = "id, public_id::text, first_name, last_name, email, phone, date_of_birth, medical_record_number, insurance_provider, insurance_id, emergency_contact_name, emergency_contact_phone, active, created_at, deleted_at, no_show_count, version, erased_at, merged_into_id, phone_display"
+
+// patientTargets are the scan destinations for patientColumns
+func patientTargets(patient *models.Patient) []any {
+	return []any{&patient.ID, &patient.PublicID, &patient.FirstName, &patient.LastName, &patient.Email, &patient.Phone,
+		&patient.DateOfBirth, &patient.MedicalRecordNumber, &patient.InsuranceProvider, &patient.InsuranceID,
+		&patient.EmergencyContactName, &patient.EmergencyContactPhone, &patient.Active, &patient.CreatedAt, &patient.DeletedAt, &patient.NoShowCount, &patient.Version,
+		&patient.ErasedAt, &patient.MergedIntoID, &patient.PhoneDisplay}
+}
+
+// scanPatient scans a row selected with patientColumns, decrypting its sensitive columns
+func scanPatient(row pgx.Row, patient *models.Patient) error {
+	if err := row.Scan(patientTargets(patient)...); err != nil {
+		return err
+	}
+	if err := openPatient(patient); err != nil {
+		return err
+	}
+	setPatientAge(patient, time.Now())
+	return nil
+}
+
+// setPatientAge works out the patient's age on now from their date of birth, leaving it nil
+// when that is unknown
+func setPatientAge(patient *models.Patient, now time.Time) {
+	patient.Age = nil
+	if patient.DateOfBirth == nil {
+		return
+	}
+	if age, err := timeutil.AgeOn(*patient.DateOfBirth, now); err == nil {
+		patient.Age = &age
+	}
+}
+
+// GetPatients lists patients, leaving out soft-deleted ones unless includeDeleted is set
+func GetPatients(ctx context.Context, page Page, includeDeleted bool) ([]models.Patient, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM patients WHERE $1 OR deleted_at IS NULL", includeDeleted)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+patientColumns+" FROM patients WHERE $3 OR deleted_at IS NULL ORDER BY id LIMIT $1 OFFSET $2",
+		page.limit(), page.Offset, includeDeleted)
+	if err != nil {
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var patients []models.Patient
 	for rows.Next() {
 		var patient models.Patient
-		err := rows.Scan(&patient.ID, &patient.FirstName, &patient.LastName, &patient.Email, &patient.Phone,
-			&patient.DateOfBirth, &patient.MedicalRecordNumber, &patient.InsuranceProvider, &patient.InsuranceID,
-			&patient.EmergencyContactName, &patient.EmergencyContactPhone, &patient.Active, &patient.CreatedAt)
-		if err != nil {
-			return nil, err
+		if err := scanPatient(rows, &patient); err != nil {
+			return nil, 0, err
 		}
 		patients = append(patients, patient)
 	}
-	return patients, nil
+	return patients, total, nil
 }
 
-func GetPatient(id int) (*models.Patient, error) {
+func GetPatient(ctx context.Context, id int) (*models.Patient, error) {
 	var patient models.Patient
-	err := DB.QueryRow(context.Background(),
-		"SELECT id, first_name, last_name, email, phone, date_of_birth, medical_record_number, insurance_provider, insurance_id, emergency_contact_name, emergency_contact_phone, active, created_at FROM patients WHERE id = $1", id).
-		Scan(&patient.ID, &patient.FirstName, &patient.LastName, &patient.Email, &patient.Phone,
-			&patient.DateOfBirth, &patient.MedicalRecordNumber, &patient.InsuranceProvider, &patient.InsuranceID,
-			&patient.EmergencyContactName, &patient.EmergencyContactPhone, &patient.Active, &patient.CreatedAt)
+	err := scanPatient(conn(ctx).QueryRow(ctx,
+		"SELECT "+patientColumns+" FROM patients WHERE id = $1", id), &patient)
 	if err != nil {
 		return nil, err
 	}
 	return &patient, nil
 }
 
-func CreatePatient(patient *models.Patient) error {
-	return DB.QueryRow(context.Background(),
-		"INSERT INTO patients (first_name, last_name, email, phone, date_of_birth, medical_record_number, insurance_provider, insurance_id, emergency_contact_name, emergency_contact_phone, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id",
-		patient.FirstName, patient.LastName, patient.Email, patient.Phone, patient.DateOfBirth,
-		patient.MedicalRecordNumber, patient.InsuranceProvider, patient.InsuranceID,
-		patient.EmergencyContactName, patient.EmergencyContactPhone, patient.Active).Scan(&patient.ID)
+// GetPatientByPublicID looks a patient up by the opaque identifier used in public contexts
+func GetPatientByPublicID(ctx context.Context, publicID string) (*models.Patient, error) {
+	var patient models.Patient
+	err := scanPatient(conn(ctx).QueryRow(ctx,
+		"SELECT "+patientColumns+" FROM patients WHERE public_id = $1::uuid", publicID), &patient)
+	if err != nil {
+		return nil, err
+	}
+	return &patient, nil
 }
 
-func UpdatePatient(id int, patient *models.Patient) error {
-	_, err := DB.Exec(context.Background(),
-		"UPDATE patients SET first_name = $1, last_name = $2, email = $3, phone = $4, date_of_birth = $5, medical_record_number = $6, insurance_provider = $7, insurance_id = $8, emergency_contact_name = $9, emergency_contact_phone = $10, active = $11 WHERE id = $12",
-		patient.FirstName, patient.LastName, patient.Email, patient.Phone, patient.DateOfBirth,
-		patient.MedicalRecordNumber, patient.InsuranceProvider, patient.InsuranceID,
-		patient.EmergencyContactName, patient.EmergencyContactPhone, patient.Active, id)
-	return err
+// GetPatientByEmail looks a patient up by email, compared case-insensitively, whether or not
+// it is soft-deleted
+func GetPatientByEmail(ctx context.Context, email string) (*models.Patient, error) {
+	var patient models.Patient
+	err := scanPatient(conn(ctx).QueryRow(ctx,
+		"SELECT "+patientColumns+" FROM patients WHERE lower(email) = lower($1)", email), &patient)
+	if err != nil {
+		return nil, err
+	}
+	return &patient, nil
 }
 
-func DeletePatient(id int) error {
-	_, err := DB.Exec(context.Background(), "DELETE FROM patients WHERE id = $1", id)
-	return err
+// GetPatientByMRN looks a patient up by medical record number, whether or not it is
+// soft-deleted. Numbers are encrypted, so the lookup goes through their keyed hash.
+func GetPatientByMRN(ctx context.Context, medicalRecordNumber string) (*models.Patient, error) {
+	index, err := phi.Index(medicalRecordNumber)
+	if err != nil {
+		return nil, err
+	}
+	var patient models.Patient
+	err = scanPatient(conn(ctx).QueryRow(ctx,
+		"SELECT "+patientColumns+" FROM patients WHERE medical_record_number_index = $1", index), &patient)
+	if err != nil {
+		return nil, err
+	}
+	return &patient, nil
+}
+
+// CreatePatient inserts a patient, setting its ID, PublicID and Version. A patient without a
+// medical record number is given a generated one; ErrMRNTaken means the one given belongs to
+// another patient.
+func CreatePatient(ctx context.Context, patient *models.Patient) error {
+	if err := normalizePatientPhones(patient); err != nil {
+		return err
+	}
+	if patient.MedicalRecordNumber == "" {
+		return createWithGeneratedMRN(ctx, patient)
+	}
+	return insertPatient(ctx, patient)
+}
+
+// insertPatient inserts a patient whose phone numbers are already normalized
+func insertPatient(ctx context.Context, patient *models.Patient) error {
+	sealed, err := sealPatient(patient)
+	if err != nil {
+		return err
+	}
+	err = conn(ctx).QueryRow(ctx,
+		"INSERT INTO patients (first_name, last_name, email, phone, date_of_birth, medical_record_number, medical_record_number_index, insurance_provider, insurance_id, emergency_contact_name, emergency_contact_phone, active, phone_display) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, public_id::text, version",
+		patient.FirstName, patient.LastName, patient.Email, patient.Phone, sealed.dateOfBirth,
+		sealed.medicalRecordNumber, sealed.mrnIndex, patient.InsuranceProvider, sealed.insuranceID,
+		patient.EmergencyContactName, patient.EmergencyContactPhone, patient.Active, patient.PhoneDisplay).Scan(&patient.ID, &patient.PublicID, &patient.Version)
+	if err != nil {
+		return mrnWriteError(err)
+	}
+	setPatientAge(patient, time.Now())
+	return nil
+}
+
+// UpdatePatient replaces a patient provided it is still at patient.Version, which is then
+// set to the new version. It returns ErrStaleVersion when the patient has changed since,
+// pgx.ErrNoRows when there is no such patient and ErrMRNTaken when another patient has the
+// medical record number.
+func UpdatePatient(ctx context.Context, id int, patient *models.Patient) error {
+	if err := normalizePatientPhones(patient); err != nil {
+		return err
+	}
+	sealed, err := sealPatient(patient)
+	if err != nil {
+		return err
+	}
+	err = conn(ctx).QueryRow(ctx,
+		"UPDATE patients SET first_name = $1, last_name = $2, email = $3, phone = $4, date_of_birth = $5, medical_record_number = $6, medical_record_number_index = $7, insurance_provider = $8, insurance_id = $9, emergency_contact_name = $10, emergency_contact_phone = $11, active = $12, phone_display = $15, version = version + 1 WHERE id = $13 AND version = $14 RETURNING version",
+		patient.FirstName, patient.LastName, patient.Email, patient.Phone, sealed.dateOfBirth,
+		sealed.medicalRecordNumber, sealed.mrnIndex, patient.InsuranceProvider, sealed.insuranceID,
+		patient.EmergencyContactName, patient.EmergencyContactPhone, patient.Active, id, patient.Version, patient.PhoneDisplay).Scan(&patient.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return versionError(ctx, "patients", id)
+	}
+	return mrnWriteError(err)
+}
+
+// DeletePatient soft-deletes a patient, keeping their appointments; pgx.ErrNoRows means there
+// is no such patient or they are already deleted
+func DeletePatient(ctx context.Context, id int) error {
+	return softDelete(ctx, "patients", id)
+}
+
+// RestorePatient undoes DeletePatient; pgx.ErrNoRows means there is no deleted patient with
+// the id, ErrPatientErased that the patient was erased rather than deleted and
+// ErrPatientMerged that it was merged into another patient
+func RestorePatient(ctx context.Context, id int) error {
+	var erased, merged bool
+	if err := conn(ctx).QueryRow(ctx, "SELECT erased_at IS NOT NULL, merged_into_id IS NOT NULL FROM patients WHERE id = $1", id).Scan(&erased, &merged); err != nil {
+		return err
+	}
+	if erased {
+		return ErrPatientErased
+	}
+	if merged {
+		return ErrPatientMerged
+	}
+	return restore(ctx, "patients", id)
 }
 
 // Employee CRUD operations
-func GetEmployees() ([]models.Employee, error) {
-	rows, err := DB.Query(context.Background(),
-		"SELECT id, clinic_id, first_name, last_name, email, phone, license_number, specialty, timezone, active, created_at FROM employees ORDER BY id")
+const employeeColumns = "id, clinic_id, first_name, last_name, email, phone, license_number, specialty, timezone, follow_up_reserve_percent, follow_up_release_days, active, created_at, deleted_at, bio, photo_url, languages, accepted_insurance, phone_display"
+
+// scanEmployee scans a row selected with employeeColumns
+func scanEmployee(row pgx.Row, employee *models.Employee) error {
+	return row.Scan(&employee.ID, &employee.ClinicID, &employee.FirstName, &employee.LastName,
+		&employee.Email, &employee.Phone, &employee.LicenseNumber, &employee.Specialty,
+		&employee.Timezone, &employee.FollowUpReservePercent, &employee.FollowUpReleaseDays,
+		&employee.Active, &employee.CreatedAt, &employee.DeletedAt,
+		&employee.Bio, &employee.PhotoURL, &employee.Languages, &employee.AcceptedInsurance, &employee.PhoneDisplay)
+}
+
+// GetEmployees lists employees, leaving out soft-deleted ones unless includeDeleted is set
+func GetEmployees(ctx context.Context, page Page, includeDeleted bool) ([]models.Employee, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM employees WHERE $1 OR deleted_at IS NULL", includeDeleted)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+employeeColumns+" FROM employees WHERE $3 OR deleted_at IS NULL ORDER BY id LIMIT $1 OFFSET $2",
+		page.limit(), page.Offset, includeDeleted)
+	if err != nil {
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var employees []models.Employee
 	for rows.Next() {
 		var employee models.Employee
-		err := rows.Scan(&employee.ID, &employee.ClinicID, &employee.FirstName, &employee.LastName,
-			&employee.Email, &employee.Phone, &employee.LicenseNumber, &employee.Specialty,
-			&employee.Timezone, &employee.Active, &employee.CreatedAt)
-		if err != nil {
-			return nil, err
+		if err := scanEmployee(rows, &employee); err != nil {
+			return nil, 0, err
 		}
 		employees = append(employees, employee)
 	}
-	return employees, nil
+	return employees, total, nil
 }
 
-func GetEmployee(id int) (*models.Employee, error) {
+func GetEmployee(ctx context.Context, id int) (*models.Employee, error) {
 	var employee models.Employee
-	err := DB.QueryRow(context.Background(),
-		"SELECT id, clinic_id, first_name, last_name, email, phone, license_number, specialty, timezone, active, created_at FROM employees WHERE id = $1", id).
-		Scan(&employee.ID, &employee.ClinicID, &employee.FirstName, &employee.LastName,
-			&employee.Email, &employee.Phone, &employee.LicenseNumber, &employee.Specialty,
-			&employee.Timezone, &employee.Active, &employee.CreatedAt)
+	err := scanEmployee(conn(ctx).QueryRow(ctx,
+		"SELECT "+employeeColumns+" FROM employees WHERE id = $1", id), &employee)
 	if err != nil {
 		return nil, err
 	}
 	return &employee, nil
 }
 
-func CreateEmployee(employee *models.Employee) error {
-	return DB.QueryRow(context.Background(),
-		"INSERT INTO employees (clinic_id, first_name, last_name, email, phone, license_number, specialty, timezone, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",
+// CreateEmployee inserts an employee, setting its ID. An employee given no timezone works in
+// their clinic's, if it has one.
+func CreateEmployee(ctx context.Context, employee *models.Employee) error {
+	if err := normalizePhone(&employee.Phone, &employee.PhoneDisplay); err != nil {
+		return err
+	}
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO employees (clinic_id, first_name, last_name, email, phone, license_number, specialty, timezone, follow_up_reserve_percent, follow_up_release_days, active, bio, photo_url, languages, accepted_insurance, phone_display) VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE(NULLIF($8, ''), (SELECT timezone FROM clinics WHERE id = $1), ''), $9, $10, $11, $12, $13, COALESCE($14::text[], '{}'), COALESCE($15::text[], '{}'), $16) RETURNING id, timezone",
 		employee.ClinicID, employee.FirstName, employee.LastName, employee.Email, employee.Phone,
-		employee.LicenseNumber, employee.Specialty, employee.Timezone, employee.Active).Scan(&employee.ID)
+		employee.LicenseNumber, employee.Specialty, employee.Timezone,
+		employee.FollowUpReservePercent, employee.FollowUpReleaseDays, employee.Active,
+		employee.Bio, employee.PhotoURL, employee.Languages, employee.AcceptedInsurance, employee.PhoneDisplay).Scan(&employee.ID, &employee.Timezone)
 }
 
-func UpdateEmployee(id int, employee *models.Employee) error {
-	_, err := DB.Exec(context.Background(),
-		"UPDATE employees SET clinic_id = $1, first_name = $2, last_name = $3, email = $4, phone = $5, license_number = $6, specialty = $7, timezone = $8, active = $9 WHERE id = $10",
+func UpdateEmployee(ctx context.Context, id int, employee *models.Employee) error {
+	if err := normalizePhone(&employee.Phone, &employee.PhoneDisplay); err != nil {
+		return err
+	}
+	_, err := conn(ctx).Exec(ctx,
+		"UPDATE employees SET clinic_id = $1, first_name = $2, last_name = $3, email = $4, phone = $5, license_number = $6, specialty = $7, timezone = $8, follow_up_reserve_percent = $9, follow_up_release_days = $10, active = $11, bio = $13, photo_url = $14, languages = COALESCE($15::text[], '{}'), accepted_insurance = COALESCE($16::text[], '{}'), phone_display = $17 WHERE id = $12",
 		employee.ClinicID, employee.FirstName, employee.LastName, employee.Email, employee.Phone,
-		employee.LicenseNumber, employee.Specialty, employee.Timezone, employee.Active, id)
+		employee.LicenseNumber, employee.Specialty, employee.Timezone,
+		employee.FollowUpReservePercent, employee.FollowUpReleaseDays, employee.Active, id,
+		employee.Bio, employee.PhotoURL, employee.Languages, employee.AcceptedInsurance, employee.PhoneDisplay)
 	return err
 }
 
-func DeleteEmployee(id int) error {
-	_, err := DB.Exec(context.Background(), "DELETE FROM employees WHERE id = $1", id)
-	return err
+// DeleteEmployee soft-deletes an employee, keeping their appointments; pgx.ErrNoRows means
+// there is no such employee or they are already deleted
+func DeleteEmployee(ctx context.Context, id int) error {
+	return softDelete(ctx, "employees", id)
+}
+
+// RestoreEmployee undoes DeleteEmployee; pgx.ErrNoRows means there is no deleted employee with the id
+func RestoreEmployee(ctx context.Context, id int) error {
+	return restore(ctx, "employees", id)
 }
 
 // Service CRUD operations
-func GetServices() ([]models.Service, error) {
-	rows, err := DB.Query(context.Background(),
-		"SELECT id, name, description, duration_minutes, price, specialty_required, active FROM services ORDER BY id")
+const serviceColumns = "id, name, description, duration_minutes, price_minor, currency, specialty_required, min_lead_minutes, same_day_cutoff_hour, max_advance_days, allows_multi_day, prepayment_window_minutes, buffer_before_minutes, buffer_after_minutes, required_consents, min_age_years, max_age_years, active"
+
+// scanService scans a row selected with serviceColumns
+func scanService(row pgx.Row, service *models.Service) error {
+	return row.Scan(&service.ID, &service.Name, &service.Description, &service.DurationMinutes,
+		&service.Price.Amount, &service.Price.Currency, &service.SpecialtyRequired, &service.MinLeadMinutes, &service.SameDayCutoffHour, &service.MaxAdvanceDays, &service.AllowsMultiDay,
+		&service.PrepaymentWindowMinutes, &service.BufferBeforeMinutes, &service.BufferAfterMinutes, &service.RequiredConsents,
+		&service.MinAgeYears, &service.MaxAgeYears, &service.Active)
+}
+
+func GetServices(ctx context.Context, page Page) ([]models.Service, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM services")
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+serviceColumns+" FROM services ORDER BY id LIMIT $1 OFFSET $2", page.limit(), page.Offset)
+	if err != nil {
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var services []models.Service
 	for rows.Next() {
 		var service models.Service
-		err := rows.Scan(&service.ID, &service.Name, &service.Description, &service.DurationMinutes,
-			&service.Price, &service.SpecialtyRequired, &service.Active)
-		if err != nil {
-			return nil, err
+		if err := scanService(rows, &service); err != nil {
+			return nil, 0, err
 		}
 		services = append(services, service)
 	}
-	return services, nil
+	return services, total, nil
 }
 
-func GetService(id int) (*models.Service, error) {
+func GetService(ctx context.Context, id int) (*models.Service, error) {
 	var service models.Service
-	err := DB.QueryRow(context.Background(),
-		"SELECT id, name, description, duration_minutes, price, specialty_required, active FROM services WHERE id = $1", id).
-		Scan(&service.ID, &service.Name, &service.Description, &service.DurationMinutes,
-			&service.Price, &service.SpecialtyRequired, &service.Active)
+	err := scanService(conn(ctx).QueryRow(ctx,
+		"SELECT "+serviceColumns+" FROM services WHERE id = $1", id), &service)
 	if err != nil {
 		return nil, err
 	}
 	return &service, nil
 }
 
-func CreateService(service *models.Service) error {
-	return DB.QueryRow(context.Background(),
-		"INSERT INTO services (name, description, duration_minutes, price, specialty_required, active) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
-		service.Name, service.Description, service.DurationMinutes, service.Price, service.SpecialtyRequired, service.Active).Scan(&service.ID)
+func CreateService(ctx context.Context, service *models.Service) error {
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO services (name, description, duration_minutes, price_minor, currency, specialty_required, min_lead_minutes, same_day_cutoff_hour, max_advance_days, allows_multi_day, prepayment_window_minutes, buffer_before_minutes, buffer_after_minutes, required_consents, min_age_years, max_age_years, active) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, COALESCE($14::text[], '{}'), $15, $16, $17) RETURNING id",
+		service.Name, service.Description, service.DurationMinutes, service.Price.Amount, service.Price.Currency, service.SpecialtyRequired,
+		service.MinLeadMinutes, service.SameDayCutoffHour, service.MaxAdvanceDays, service.AllowsMultiDay, service.PrepaymentWindowMinutes,
+		service.BufferBeforeMinutes, service.BufferAfterMinutes, service.RequiredConsents, service.MinAgeYears, service.MaxAgeYears, service.Active).Scan(&service.ID)
 }
 
-func UpdateService(id int, service *models.Service) error {
-	_, err := DB.Exec(context.Background(),
-		"UPDATE services SET name = $1, description = $2, duration_minutes = $3, price = $4, specialty_required = $5, active = $6 WHERE id = $7",
-		service.Name, service.Description, service.DurationMinutes, service.Price, service.SpecialtyRequired, service.Active, id)
+func UpdateService(ctx context.Context, id int, service *models.Service) error {
+	_, err := conn(ctx).Exec(ctx,
+		"UPDATE services SET name = $1, description = $2, duration_minutes = $3, price_minor = $4, currency = $5, specialty_required = $6, min_lead_minutes = $7, same_day_cutoff_hour = $8, max_advance_days = $9, allows_multi_day = $10, prepayment_window_minutes = $11, buffer_before_minutes = $12, buffer_after_minutes = $13, required_consents = COALESCE($14::text[], '{}'), min_age_years = $15, max_age_years = $16, active = $17 WHERE id = $18",
+		service.Name, service.Description, service.DurationMinutes, service.Price.Amount, service.Price.Currency, service.SpecialtyRequired,
+		service.MinLeadMinutes, service.SameDayCutoffHour, service.MaxAdvanceDays, service.AllowsMultiDay, service.PrepaymentWindowMinutes,
+		service.BufferBeforeMinutes, service.BufferAfterMinutes, service.RequiredConsents, service.MinAgeYears, service.MaxAgeYears, service.Active, id)
 	return err
 }
 
-func DeleteService(id int) error {
-	_, err := DB.Exec(context.Background(), "DELETE FROM services WHERE id = $1", id)
+func DeleteService(ctx context.Context, id int) error {
+	_, err := conn(ctx).Exec(ctx, "DELETE FROM services WHERE id = $1", id)
 	return err
 }
 
 // Appointment CRUD operations
-func GetAppointments() ([]models.Appointment, error) {
-	rows, err := DB.Query(context.Background(),
-		"SELECT id, patient_id, employee_id, service_id, clinic_id, start_datetime, end_datetime, status, appointment_type, notes, medical_notes, cancellation_reason, payment_status, payment_amount, created_at, updated_at FROM appointments ORDER BY start_datetime DESC")
+const appointmentColumns = "id, public_id::text, patient_id, employee_id, service_id, clinic_id, start_datetime, end_datetime, status, appointment_type, booking_channel, notes, medical_notes, cancellation_reason, payment_status, payment_amount_minor, payment_currency, series_id, created_at, updated_at, version, checked_in_at, started_at, checked_out_at, cancelled_at"
+
+// scanAppointment scans a row selected with appointmentColumns, decrypting its medical notes
+func scanAppointment(row pgx.Row, appointment *models.Appointment) error {
+	var amount *int64
+	var currency *string
+	err := row.Scan(&appointment.ID, &appointment.PublicID, &appointment.PatientID, &appointment.EmployeeID, &appointment.ServiceID,
+		&appointment.ClinicID, &appointment.StartDatetime, &appointment.EndDatetime, &appointment.Status,
+		&appointment.AppointmentType, &appointment.BookingChannel, &appointment.Notes, &appointment.MedicalNotes,
+		&appointment.CancellationReason, &appointment.PaymentStatus, &amount, &currency,
+		&appointment.SeriesID, &appointment.CreatedAt, &appointment.UpdatedAt, &appointment.Version,
+		&appointment.CheckedInAt, &appointment.StartedAt, &appointment.CheckedOutAt, &appointment.CancelledAt)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	appointment.PaymentAmount = nil
+	if amount != nil && currency != nil {
+		appointment.PaymentAmount = &money.Money{Amount: *amount, Currency: *currency}
 	}
+	appointment.MedicalNotes, err = phi.DecryptPtr(appointment.MedicalNotes)
+	return err
+}
+
+func collectAppointments(rows pgx.Rows) ([]models.Appointment, error) {
 	defer rows.Close()
 
 	var appointments []models.Appointment
 	for rows.Next() {
 		var appointment models.Appointment
-		err := rows.Scan(&appointment.ID, &appointment.PatientID, &appointment.EmployeeID, &appointment.ServiceID,
-			&appointment.ClinicID, &appointment.StartDatetime, &appointment.EndDatetime, &appointment.Status,
-			&appointment.AppointmentType, &appointment.Notes, &appointment.MedicalNotes, &appointment.CancellationReason,
-			&appointment.PaymentStatus, &appointment.PaymentAmount, &appointment.CreatedAt, &appointment.UpdatedAt)
-		if err != nil {
+		if err := scanAppointment(rows, &appointment); err != nil {
 			return nil, err
 		}
 		appointments = append(appointments, appointment)
 	}
-	return appointments, nil
+	return appointments, rows.Err()
+}
+
+// AppointmentFilter narrows GetAppointments; nil fields and an empty Statuses match everything
+type AppointmentFilter struct {
+	From       *time.Time // appointments ending after From
+	To         *time.Time // appointments starting before To
+	EmployeeID *int
+	PatientID  *int
+	ClinicID   *int
+	Statuses   []string
+}
+
+// appointmentFilterWhere applies an AppointmentFilter passed as $1-$6. From/To select
+// appointments overlapping the range, so bookings running across its edges are included.
+const appointmentFilterWhere = ` WHERE ($1::timestamptz IS NULL OR end_datetime > $1)
+	AND ($2::timestamptz IS NULL OR start_datetime < $2)
+	AND ($3::int IS NULL OR employee_id = $3)
+	AND ($4::int IS NULL OR patient_id = $4)
+	AND ($5::int IS NULL OR clinic_id = $5)
+	AND ($6::text[] IS NULL OR status::text = ANY($6))`
+
+func (f AppointmentFilter) args() []any {
+	var from, to *time.Time
+	if f.From != nil {
+		utc := f.From.UTC()
+		from = &utc
+	}
+	if f.To != nil {
+		utc := f.To.UTC()
+		to = &utc
+	}
+	var statuses []string
+	if len(f.Statuses) > 0 {
+		statuses = f.Statuses
+	}
+	return []any{from, to, f.EmployeeID, f.PatientID, f.ClinicID, statuses}
+}
+
+// GetAppointments returns one page of the appointments matching the filter, latest first,
+// with the total number that match
+func GetAppointments(ctx context.Context, filter AppointmentFilter, page Page) ([]models.Appointment, int, error) {
+	args := filter.args()
+	total, err := count(ctx, "SELECT COUNT(*) FROM appointments"+appointmentFilterWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+appointmentColumns+" FROM appointments"+appointmentFilterWhere+" ORDER BY start_datetime DESC, id LIMIT $7 OFFSET $8",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	appointments, err := collectAppointments(rows)
+	return appointments, total, err
 }
 
-func GetAppointment(id int) (*models.Appointment, error) {
+func GetAppointment(ctx context.Context, id int) (*models.Appointment, error) {
 	var appointment models.Appointment
-	err := DB.QueryRow(context.Background(),
-		"SELECT id, patient_id, employee_id, service_id, clinic_id, start_datetime, end_datetime, status, appointment_type, notes, medical_notes, cancellation_reason, payment_status, payment_amount, created_at, updated_at FROM appointments WHERE id = $1", id).
-		Scan(&appointment.ID, &appointment.PatientID, &appointment.EmployeeID, &appointment.ServiceID,
-			&appointment.ClinicID, &appointment.StartDatetime, &appointment.EndDatetime, &appointment.Status,
-			&appointment.AppointmentType, &appointment.Notes, &appointment.MedicalNotes, &appointment.CancellationReason,
-			&appointment.PaymentStatus, &appointment.PaymentAmount, &appointment.CreatedAt, &appointment.UpdatedAt)
+	err := scanAppointment(conn(ctx).QueryRow(ctx,
+		"SELECT "+appointmentColumns+" FROM appointments WHERE id = $1", id), &appointment)
 	if err != nil {
 		return nil, err
 	}
 	return &appointment, nil
 }
 
-func CreateAppointment(appointment *models.Appointment) error {
-	return DB.QueryRow(context.Background(),
-		"INSERT INTO appointments (patient_id, employee_id, service_id, clinic_id, start_datetime, end_datetime, status, appointment_type, notes, payment_status, payment_amount) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id",
+// CreateAppointment inserts an appointment and gives it the resources its service needs,
+// returning *ResourceUnavailableError when one of them is taken
+func CreateAppointment(ctx context.Context, appointment *models.Appointment) error {
+	return WithTx(ctx, func(ctx context.Context) error {
+		if err := insertAppointment(ctx, conn(ctx), appointment); err != nil {
+			return err
+		}
+		return assignResources(ctx, conn(ctx), appointment)
+	})
+}
+
+// LockEmployeeSchedule locks the employee's row until the surrounding transaction ends. Every
+// write that books an employee's time checks availability and saves the booking under this
+// lock, inside one WithTx, so two bookings racing for the same slot are checked one after the
+// other rather than both passing. Slot holds take the same lock.
+func LockEmployeeSchedule(ctx context.Context, employeeID int) error {
+	return lockEmployee(ctx, conn(ctx), employeeID)
+}
+
+func lockEmployee(ctx context.Context, q dbtx, employeeID int) error {
+	_, err := q.Exec(ctx, "SELECT 1 FROM employees WHERE id = $1 FOR UPDATE", employeeID)
+	return err
+}
+
+// rowQuerier is satisfied by both the pool and a transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// paymentAmountArgs splits an appointment's payment_amount into its amount and currency
+// columns, both NULL when there is no amount
+func paymentAmountArgs(appointment *models.Appointment) (*int64, *string) {
+	if appointment.PaymentAmount == nil {
+		return nil, nil
+	}
+	return &appointment.PaymentAmount.Amount, &appointment.PaymentAmount.Currency
+}
+
+func insertAppointment(ctx context.Context, q rowQuerier, appointment *models.Appointment) error {
+	amount, currency := paymentAmountArgs(appointment)
+	err := q.QueryRow(ctx,
+		"INSERT INTO appointments (patient_id, employee_id, service_id, clinic_id, start_datetime, end_datetime, status, appointment_type, booking_channel, notes, payment_status, payment_amount_minor, payment_currency, series_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id, public_id::text, version",
 		appointment.PatientID, appointment.EmployeeID, appointment.ServiceID, appointment.ClinicID,
-		appointment.StartDatetime.UTC(), appointment.EndDatetime.UTC(), appointment.Status, appointment.AppointmentType,
-		appointment.Notes, appointment.PaymentStatus, appointment.PaymentAmount).Scan(&appointment.ID)
+		timeutil.ToUTC(appointment.StartDatetime), timeutil.ToUTC(appointment.EndDatetime), appointment.Status, appointment.AppointmentType,
+		appointment.BookingChannel, appointment.Notes, appointment.PaymentStatus, amount, currency, appointment.SeriesID).Scan(&appointment.ID, &appointment.PublicID, &appointment.Version)
+	return overlapError(err)
+}
+
+// UpdateAppointment replaces an appointment provided it is still at appointment.Version,
+// which is then set to the new version, and reassigns its resources for the new details. A
+// change to or from NO_SHOW is counted on the patients, and the first change to IN_PROGRESS
+// or COMPLETED stamps started_at or checked_out_at. It returns ErrStaleVersion when the
+// appointment has changed since, pgx.ErrNoRows when there is no such appointment and
+// *ResourceUnavailableError when a resource it needs is taken.
+func UpdateAppointment(ctx context.Context, id int, appointment *models.Appointment) error {
+	medicalNotes, err := phi.EncryptPtr(appointment.MedicalNotes)
+	if err != nil {
+		return err
+	}
+	return WithTx(ctx, func(ctx context.Context) error {
+		var previousPatientID int
+		var previousStatus string
+		err := conn(ctx).QueryRow(ctx, "SELECT patient_id, status FROM appointments WHERE id = $1", id).
+			Scan(&previousPatientID, &previousStatus)
+		if err != nil {
+			return err
+		}
+		if err := updateAppointment(ctx, id, appointment, medicalNotes); err != nil {
+			return err
+		}
+		if previousStatus == "NO_SHOW" || appointment.Status == "NO_SHOW" {
+			if err := recountNoShows(ctx, conn(ctx), []int{previousPatientID, appointment.PatientID}); err != nil {
+				return err
+			}
+		}
+		appointment.ID = id
+		return assignResources(ctx, conn(ctx), appointment)
+	})
 }
 
-func UpdateAppointment(id int, appointment *models.Appointment) error {
-	_, err := DB.Exec(context.Background(),
-		"UPDATE appointments SET patient_id = $1, employee_id = $2, service_id = $3, clinic_id = $4, start_datetime = $5, end_datetime = $6, status = $7, appointment_type = $8, notes = $9, medical_notes = $10, cancellation_reason = $11, payment_status = $12, payment_amount = $13, updated_at = CURRENT_TIMESTAMP WHERE id = $14",
+func updateAppointment(ctx context.Context, id int, appointment *models.Appointment, medicalNotes *string) error {
+	amount, currency := paymentAmountArgs(appointment)
+	err := conn(ctx).QueryRow(ctx,
+		"UPDATE appointments SET patient_id = $1, employee_id = $2, service_id = $3, clinic_id = $4, start_datetime = $5, end_datetime = $6, status = $7, appointment_type = $8, booking_channel = $9, notes = $10, medical_notes = $11, cancellation_reason = $12, payment_status = $13, payment_amount_minor = $14, payment_currency = $15, started_at = CASE WHEN $7 = 'IN_PROGRESS' THEN COALESCE(started_at, CURRENT_TIMESTAMP) ELSE started_at END, checked_out_at = CASE WHEN $7 = 'COMPLETED' THEN COALESCE(checked_out_at, CURRENT_TIMESTAMP) ELSE checked_out_at END, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $16 AND version = $17 RETURNING version",
 		appointment.PatientID, appointment.EmployeeID, appointment.ServiceID, appointment.ClinicID,
-		appointment.StartDatetime.UTC(), appointment.EndDatetime.UTC(), appointment.Status, appointment.AppointmentType,
-		appointment.Notes, appointment.MedicalNotes, appointment.CancellationReason,
-		appointment.PaymentStatus, appointment.PaymentAmount, id)
-	return err
+		timeutil.ToUTC(appointment.StartDatetime), timeutil.ToUTC(appointment.EndDatetime), appointment.Status, appointment.AppointmentType,
+		appointment.BookingChannel, appointment.Notes, medicalNotes, appointment.CancellationReason,
+		appointment.PaymentStatus, amount, currency, id, appointment.Version).Scan(&appointment.Version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return versionError(ctx, "appointments", id)
+	}
+	return overlapError(err)
 }
 
-func DeleteAppointment(id int) error {
-	_, err := DB.Exec(context.Background(), "DELETE FROM appointments WHERE id = $1", id)
+// ErrAppointmentConflict is returned when a write would double-book an employee
+var ErrAppointmentConflict = errors.New("employee already has an appointment at this time")
+
+// overlapError maps a violation of the appointments_no_overlap constraint to ErrAppointmentConflict
+func overlapError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23P01" && pgErr.ConstraintName == "appointments_no_overlap" {
+		return ErrAppointmentConflict
+	}
 	return err
 }
 
-// Waiting List CRUD operations
-func GetWaitingList() ([]models.WaitingList, error) {
-	rows, err := DB.Query(context.Background(),
-		"SELECT id, patient_id, service_id, preferred_employee_id, requested_date, urgency_level, notes, status, created_at FROM waiting_list ORDER BY created_at DESC")
+// GetAppointmentByPublicID looks an appointment up by the opaque identifier used in public contexts
+func GetAppointmentByPublicID(ctx context.Context, publicID string) (*models.Appointment, error) {
+	var appointment models.Appointment
+	err := scanAppointment(conn(ctx).QueryRow(ctx,
+		"SELECT "+appointmentColumns+" FROM appointments WHERE public_id = $1::uuid", publicID), &appointment)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return &appointment, nil
+}
+
+// DeleteAppointment removes an appointment, taking a deleted no-show off its patient's count
+func DeleteAppointment(ctx context.Context, id int) error {
+	return WithTx(ctx, func(ctx context.Context) error {
+		var noted bool
+		if err := conn(ctx).QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM visit_notes WHERE appointment_id = $1)", id).Scan(&noted); err != nil {
+			return err
+		}
+		if noted {
+			return ErrAppointmentHasVisitNote
+		}
+		var patientID int
+		var status string
+		err := conn(ctx).QueryRow(ctx, "DELETE FROM appointments WHERE id = $1 RETURNING patient_id, status", id).
+			Scan(&patientID, &status)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil || status != "NO_SHOW" {
+			return err
+		}
+		return recountNoShows(ctx, conn(ctx), []int{patientID})
+	})
+}
+
+// GetPatientAppointments returns all of a patient's appointments, latest first
+func GetPatientAppointments(ctx context.Context, patientID int) ([]models.Appointment, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+appointmentColumns+" FROM appointments WHERE patient_id = $1 ORDER BY start_datetime DESC", patientID)
+	if err != nil {
+		return nil, err
+	}
+	return collectAppointments(rows)
+}
+
+// CancelAppointment cancels a SCHEDULED or CONFIRMED appointment with the given reason and
+// withdraws any pending payment links that cover it. It reports false when the appointment
+// was not in a cancellable state.
+func CancelAppointment(ctx context.Context, id int, reason string) (bool, error) {
+	cancelled := false
+	err := pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx,
+			"UPDATE appointments SET status = 'CANCELLED', cancellation_reason = $2, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND status IN ('SCHEDULED', 'CONFIRMED')",
+			id, reason)
+		if err != nil || tag.RowsAffected() == 0 {
+			return err
+		}
+		cancelled = true
+		_, err = tx.Exec(ctx,
+			"UPDATE payment_links SET status = 'CANCELLED' WHERE status = 'PENDING' AND id IN (SELECT payment_link_id FROM payment_link_items WHERE appointment_id = $1)", id)
+		return err
+	})
+	return cancelled, err
+}
+
+// ConfirmAppointment marks a SCHEDULED appointment CONFIRMED. It reports false when the
+// appointment was not scheduled.
+func ConfirmAppointment(ctx context.Context, id int) (bool, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		"UPDATE appointments SET status = 'CONFIRMED', updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $1 AND status = 'SCHEDULED'", id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetAppointmentsForDay returns the appointments overlapping [dayStart, dayEnd), including
+// overnight bookings that started the day before or run into the next day, optionally
+// narrowed to one clinic and/or employee
+func GetAppointmentsForDay(ctx context.Context, dayStart, dayEnd time.Time, clinicID, employeeID *int) ([]models.Appointment, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+appointmentColumns+" FROM appointments WHERE start_datetime < $2 AND end_datetime > $1 AND ($3::int IS NULL OR clinic_id = $3) AND ($4::int IS NULL OR employee_id = $4) ORDER BY employee_id, start_datetime",
+		dayStart.UTC(), dayEnd.UTC(), clinicID, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	return collectAppointments(rows)
+}
+
+// GetPatientAttendance counts a patient's attended and missed appointments that started before the given time
+func GetPatientAttendance(ctx context.Context, patientID int, before time.Time) (attended, noShows int, err error) {
+	err = conn(ctx).QueryRow(ctx,
+		"SELECT COUNT(*) FILTER (WHERE status = 'COMPLETED'), COUNT(*) FILTER (WHERE status = 'NO_SHOW') FROM appointments WHERE patient_id = $1 AND start_datetime < $2",
+		patientID, before.UTC()).Scan(&attended, &noShows)
+	return attended, noShows, err
+}
 
-	var waitingList []models.WaitingList
+// Waiting List CRUD operations
+const waitingListColumns = "id, patient_id, service_id, preferred_employee_id, to_char(requested_date, 'YYYY-MM-DD'), urgency_level, notes, status, created_at, escalated_at"
+
+func scanWaitingListItem(row pgx.Row, item *models.WaitingList) error {
+	return row.Scan(&item.ID, &item.PatientID, &item.ServiceID, &item.PreferredEmployeeID,
+		&item.RequestedDate, &item.UrgencyLevel, &item.Notes, &item.Status, &item.CreatedAt, &item.EscalatedAt)
+}
+
+// collectWaitingList scans every row of a query selecting waitingListColumns and closes the rows
+func collectWaitingList(rows pgx.Rows) ([]models.WaitingList, error) {
+	defer rows.Close()
+	waitingList := []models.WaitingList{}
 	for rows.Next() {
 		var item models.WaitingList
-		err := rows.Scan(&item.ID, &item.PatientID, &item.ServiceID, &item.PreferredEmployeeID,
-			&item.RequestedDate, &item.UrgencyLevel, &item.Notes, &item.Status, &item.CreatedAt)
-		if err != nil {
+		if err := scanWaitingListItem(rows, &item); err != nil {
 			return nil, err
 		}
 		waitingList = append(waitingList, item)
 	}
-	return waitingList, nil
+	return waitingList, rows.Err()
 }
 
-func GetWaitingListItem(id int) (*models.WaitingList, error) {
-	var item models.WaitingList
-	err := DB.QueryRow(context.Background(),
-		"SELECT id, patient_id, service_id, preferred_employee_id, requested_date, urgency_level, notes, status, created_at FROM waiting_list WHERE id = $1", id).
-		Scan(&item.ID, &item.PatientID, &item.ServiceID, &item.PreferredEmployeeID,
-			&item.RequestedDate, &item.UrgencyLevel, &item.Notes, &item.Status, &item.CreatedAt)
+func GetWaitingList(ctx context.Context, page Page) ([]models.WaitingList, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM waiting_list")
 	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+waitingListColumns+" FROM waiting_list ORDER BY created_at DESC, id LIMIT $1 OFFSET $2", page.limit(), page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	waitingList, err := collectWaitingList(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return waitingList, total, nil
+}
+
+func GetWaitingListItem(ctx context.Context, id int) (*models.WaitingList, error) {
+	var item models.WaitingList
+	if err := scanWaitingListItem(conn(ctx).QueryRow(ctx, "SELECT "+waitingListColumns+" FROM waiting_list WHERE id = $1", id), &item); err != nil {
 		return nil, err
 	}
 	return &item, nil
 }
 
-func CreateWaitingListItem(item *models.WaitingList) error {
-	return DB.QueryRow(context.Background(),
-		"INSERT INTO waiting_list (patient_id, service_id, preferred_employee_id, requested_date, urgency_level, notes, status) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id",
+func CreateWaitingListItem(ctx context.Context, item *models.WaitingList) error {
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO waiting_list (patient_id, service_id, preferred_employee_id, requested_date, urgency_level, notes, status) VALUES ($1, $2, $3, $4::date, $5, $6, $7) RETURNING id",
 		item.PatientID, item.ServiceID, item.PreferredEmployeeID, item.RequestedDate,
 		item.UrgencyLevel, item.Notes, item.Status).Scan(&item.ID)
 }
 
-func UpdateWaitingListItem(id int, item *models.WaitingList) error {
-	_, err := DB.Exec(context.Background(),
-		"UPDATE waiting_list SET patient_id = $1, service_id = $2, preferred_employee_id = $3, requested_date = $4, urgency_level = $5, notes = $6, status = $7 WHERE id = $8",
+func UpdateWaitingListItem(ctx context.Context, id int, item *models.WaitingList) error {
+	_, err := conn(ctx).Exec(ctx,
+		"UPDATE waiting_list SET patient_id = $1, service_id = $2, preferred_employee_id = $3, requested_date = $4::date, urgency_level = $5, notes = $6, status = $7 WHERE id = $8",
 		item.PatientID, item.ServiceID, item.PreferredEmployeeID, item.RequestedDate,
 		item.UrgencyLevel, item.Notes, item.Status, id)
 	return err
 }
 
-func DeleteWaitingListItem(id int) error {
-	_, err := DB.Exec(context.Background(), "DELETE FROM waiting_list WHERE id = $1", id)
+// MarkWaitingListContacted moves an ACTIVE or CONTACTED entry to CONTACTED after a slot has
+// been offered, returning the updated entry. pgx.ErrNoRows means the entry is in another state.
+func MarkWaitingListContacted(ctx context.Context, id int) (*models.WaitingList, error) {
+	var item models.WaitingList
+	err := scanWaitingListItem(conn(ctx).QueryRow(ctx,
+		"UPDATE waiting_list SET status = 'CONTACTED' WHERE id = $1 AND status IN ('ACTIVE', 'CONTACTED') RETURNING "+waitingListColumns, id), &item)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func DeleteWaitingListItem(ctx context.Context, id int) error {
+	_, err := conn(ctx).Exec(ctx, "DELETE FROM waiting_list WHERE id = $1", id)
 	return err
 }
 
-// CreateTables creates all necessary database tables and indexes
-func CreateTables() error {
-	statements := []string{
-		// Drop existing tables if they exist (in reverse order due to foreign keys)
-		`DROP TABLE IF EXISTS waiting_list CASCADE`,
-		`DROP TABLE IF EXISTS appointments CASCADE`,
-		`DROP TABLE IF EXISTS slot_holds CASCADE`,
-		`DROP TABLE IF EXISTS time_off CASCADE`,
-		`DROP TABLE IF EXISTS day_overrides CASCADE`,
-		`DROP TABLE IF EXISTS work_templates CASCADE`,
-		`DROP TABLE IF EXISTS employee_services CASCADE`,
-		`DROP TABLE IF EXISTS services CASCADE`,
-		`DROP TABLE IF EXISTS employees CASCADE`,
-		`DROP TABLE IF EXISTS patients CASCADE`,
-		`DROP TABLE IF EXISTS clinics CASCADE`,
-
-		// Drop existing types if they exist
-		`DROP TYPE IF EXISTS appointment_status CASCADE`,
-		`DROP TYPE IF EXISTS appointment_type CASCADE`,
-		`DROP TYPE IF EXISTS payment_status CASCADE`,
-		`DROP TYPE IF EXISTS urgency_level CASCADE`,
-		`DROP TYPE IF EXISTS waiting_list_status CASCADE`,
-
-		// Create enum types
-		`CREATE TYPE appointment_status AS ENUM ('SCHEDULED', 'CONFIRMED', 'IN_PROGRESS', 'COMPLETED', 'CANCELLED', 'NO_SHOW')`,
-		`CREATE TYPE appointment_type AS ENUM ('INITIAL_CONSULTATION', 'FOLLOW_UP', 'PROCEDURE', 'EMERGENCY')`,
-		`CREATE TYPE payment_status AS ENUM ('PENDING', 'PAID', 'REFUNDED')`,
-		`CREATE TYPE urgency_level AS ENUM ('LOW', 'MEDIUM', 'HIGH', 'URGENT')`,
-		`CREATE TYPE waiting_list_status AS ENUM ('ACTIVE', 'CONTACTED', 'SCHEDULED', 'EXPIRED')`,
-
-		// Create tables
-		`CREATE TABLE IF NOT EXISTS clinics (
-			id SERIAL PRIMARY KEY,
-			name TEXT NOT NULL UNIQUE,
-			address TEXT,
-			phone TEXT,
-			email TEXT,
-			active BOOLEAN DEFAULT TRUE
-		)`,
-		`CREATE TABLE IF NOT EXISTS patients (
-			id SERIAL PRIMARY KEY,
-			first_name TEXT NOT NULL,
-			last_name TEXT NOT NULL,
-			email TEXT UNIQUE,
-			phone TEXT,
-			date_of_birth TEXT,
-			medical_record_number TEXT UNIQUE,
-			insurance_provider TEXT,
-			insurance_id TEXT,
-			emergency_contact_name TEXT,
-			emergency_contact_phone TEXT,
-			active BOOLEAN DEFAULT TRUE,
-			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS employees (
-			id SERIAL PRIMARY KEY,
-			clinic_id INTEGER NOT NULL REFERENCES clinics(id),
-			first_name TEXT NOT NULL,
-			last_name TEXT NOT NULL,
-			email TEXT UNIQUE,
-			phone TEXT,
-			license_number TEXT UNIQUE,
-			specialty TEXT,
-			timezone TEXT DEFAULT 'Asia/Colombo',
-			active BOOLEAN DEFAULT TRUE,
-			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS services (
-			id SERIAL PRIMARY KEY,
-			name TEXT NOT NULL UNIQUE,
-			description TEXT,
-			duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
-			price DECIMAL,
-			specialty_required TEXT,
-			active BOOLEAN DEFAULT TRUE
-		)`,
-		`CREATE TABLE IF NOT EXISTS employee_services (
-			employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
-			service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
-			UNIQUE (employee_id, service_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS work_templates (
-			id SERIAL PRIMARY KEY,
-			employee_id INTEGER NOT NULL REFERENCES employees(id),
-			weekday INTEGER NOT NULL CHECK (weekday >= 1 AND weekday <= 7),
-			start_time TIME,
-			end_time TIME,
-			slot_granularity_minutes INTEGER DEFAULT 15,
-			is_active BOOLEAN DEFAULT TRUE
-		)`,
-		`CREATE TABLE IF NOT EXISTS day_overrides (
-			id SERIAL PRIMARY KEY,
-			employee_id INTEGER NOT NULL REFERENCES employees(id),
-			date DATE NOT NULL,
-			is_closed BOOLEAN DEFAULT FALSE,
-			start_time TIME,
-			end_time TIME,
-			reason TEXT,
-			UNIQUE (employee_id, date)
-		)`,
-		`CREATE TABLE IF NOT EXISTS time_off (
-			id SERIAL PRIMARY KEY,
-			employee_id INTEGER NOT NULL REFERENCES employees(id),
-			start_datetime TIMESTAMPTZ NOT NULL,
-			end_datetime TIMESTAMPTZ NOT NULL,
-			reason TEXT,
-			approved BOOLEAN DEFAULT FALSE
-		)`,
-		`CREATE TABLE IF NOT EXISTS slot_holds (
-			id SERIAL PRIMARY KEY,
-			employee_id INTEGER NOT NULL REFERENCES employees(id),
-			service_id INTEGER NOT NULL REFERENCES services(id),
-			start_datetime TIMESTAMPTZ NOT NULL,
-			end_datetime TIMESTAMPTZ NOT NULL,
-			patient_id INTEGER REFERENCES patients(id),
-			hold_token TEXT NOT NULL UNIQUE,
-			expires_at TIMESTAMPTZ NOT NULL,
-			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS appointments (
-			id SERIAL PRIMARY KEY,
-			patient_id INTEGER NOT NULL REFERENCES patients(id),
-			employee_id INTEGER NOT NULL REFERENCES employees(id),
-			service_id INTEGER NOT NULL REFERENCES services(id),
-			clinic_id INTEGER NOT NULL REFERENCES clinics(id),
-			start_datetime TIMESTAMPTZ NOT NULL,
-			end_datetime TIMESTAMPTZ NOT NULL,
-			status appointment_status DEFAULT 'SCHEDULED',
-			appointment_type appointment_type,
-			notes TEXT,
-			medical_notes TEXT,
-			cancellation_reason TEXT,
-			payment_status payment_status DEFAULT 'PENDING',
-			payment_amount DECIMAL,
-			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS waiting_list (
-			id SERIAL PRIMARY KEY,
-			patient_id INTEGER NOT NULL REFERENCES patients(id),
-			service_id INTEGER NOT NULL REFERENCES services(id),
-			preferred_employee_id INTEGER REFERENCES employees(id),
-			requested_date TEXT,
-			urgency_level urgency_level DEFAULT 'MEDIUM',
-			notes TEXT,
-			status waiting_list_status DEFAULT 'ACTIVE',
-			created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
-		)`,
-
-		// Create indexes
-		`CREATE INDEX IF NOT EXISTS idx_appointments_patient_id ON appointments(patient_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_appointments_employee_id ON appointments(employee_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_appointments_datetime ON appointments(start_datetime)`,
-		`CREATE INDEX IF NOT EXISTS idx_appointments_status ON appointments(status)`,
-		`CREATE INDEX IF NOT EXISTS idx_slot_holds_datetime ON slot_holds(start_datetime, end_datetime)`,
-		`CREATE INDEX IF NOT EXISTS idx_time_off_datetime ON time_off(start_datetime, end_datetime)`,
-	}
-
-	for _, stmt := range statements {
-		_, err := DB.Exec(context.Background(), stmt)
-		if err != nil {
-			return fmt.Errorf("failed to execute statement: %s, error: %v", stmt, err)
-		}
+// WaitingListSLAStats counts how the URGENT waiting list entries created in a period fared
+// against the SLA
+type WaitingListSLAStats struct {
+	// Urgent is every URGENT entry created in the period
+	Urgent int `json:"urgent"`
+	// Breached are those that waited past the SLA: escalated ones, and ones still waiting
+	// past it that the escalation job has not reached yet
+	Breached int `json:"breached"`
+	// OpenBreached are the breached entries still ACTIVE or CONTACTED
+	OpenBreached int `json:"open_breached"`
+	Scheduled    int `json:"scheduled"`
+	Expired      int `json:"expired"`
+}
+
+// GetWaitingListSLAStats counts the URGENT entries created in [from, to) (either bound may be
+// nil) against an SLA breached by entries created at or before cutoff that are still waiting
+func GetWaitingListSLAStats(ctx context.Context, from, to *time.Time, cutoff time.Time) (*WaitingListSLAStats, error) {
+	var stats WaitingListSLAStats
+	err := conn(ctx).QueryRow(ctx,
+		`WITH urgent AS (
+			SELECT status, escalated_at IS NOT NULL OR (status IN ('ACTIVE', 'CONTACTED') AND created_at <= $3) AS breached
+			FROM waiting_list
+			WHERE urgency_level = 'URGENT' AND ($1::timestamptz IS NULL OR created_at >= $1) AND ($2::timestamptz IS NULL OR created_at < $2)
+		)
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE breached), COUNT(*) FILTER (WHERE breached AND status IN ('ACTIVE', 'CONTACTED')),
+			COUNT(*) FILTER (WHERE status = 'SCHEDULED'), COUNT(*) FILTER (WHERE status = 'EXPIRED')
+		FROM urgent`,
+		from, to, cutoff.UTC()).Scan(&stats.Urgent, &stats.Breached, &stats.OpenBreached, &stats.Scheduled, &stats.Expired)
+	if err != nil {
+		return nil, err
 	}
-
-	fmt.Println("All tables and indexes created successfully!")
-	return nil
+	return &stats, nil
 }
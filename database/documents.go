@@ -0,0 +1,127 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+
+	"bookings/models"
+	"bookings/phi"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const documentColumns = "id, patient_id, appointment_id, category, filename, content_type, size_bytes, sha256, description, storage_key, uploaded_by, created_at, deleted_at, deleted_by"
+
+// scanDocument scans a row selected with documentColumns and decrypts its filename and
+// description
+func scanDocument(row pgx.Row, document *models.Document) error {
+	if err := row.Scan(&document.ID, &document.PatientID, &document.AppointmentID, &document.Category, &document.Filename,
+		&document.ContentType, &document.SizeBytes, &document.SHA256, &document.Description, &document.StorageKey,
+		&document.UploadedBy, &document.CreatedAt, &document.DeletedAt, &document.DeletedBy); err != nil {
+		return err
+	}
+	var err error
+	if document.Filename, err = phi.Decrypt(document.Filename); err != nil {
+		return err
+	}
+	document.Description, err = phi.DecryptPtr(document.Description)
+	return err
+}
+
+// DocumentFilter narrows a patient's documents; nil fields match everything
+type DocumentFilter struct {
+	AppointmentID *int
+	Category      *string
+}
+
+// documentFilterWhere keeps patient $1's documents that are not deleted and match a
+// DocumentFilter passed as $2-$3
+const documentFilterWhere = ` WHERE patient_id = $1 AND deleted_at IS NULL
+	AND ($2::int IS NULL OR appointment_id = $2)
+	AND ($3::text IS NULL OR category = $3)`
+
+func (f DocumentFilter) args(patientID int) []any {
+	return []any{patientID, f.AppointmentID, f.Category}
+}
+
+// Patient document operations
+
+// GetDocuments returns one page of a patient's documents matching the filter, latest first,
+// with the total number that match. Deleted documents are left out.
+func GetDocuments(ctx context.Context, patientID int, filter DocumentFilter, page Page) ([]models.Document, int, error) {
+	args := filter.args(patientID)
+	total, err := count(ctx, "SELECT COUNT(*) FROM patient_documents"+documentFilterWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+documentColumns+" FROM patient_documents"+documentFilterWhere+" ORDER BY created_at DESC, id DESC LIMIT $4 OFFSET $5",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	documents := []models.Document{}
+	for rows.Next() {
+		var document models.Document
+		if err := scanDocument(rows, &document); err != nil {
+			return nil, 0, err
+		}
+		documents = append(documents, document)
+	}
+	return documents, total, rows.Err()
+}
+
+// GetDocument returns a document, deleted or not
+func GetDocument(ctx context.Context, id int) (*models.Document, error) {
+	var document models.Document
+	if err := scanDocument(conn(ctx).QueryRow(ctx, "SELECT "+documentColumns+" FROM patient_documents WHERE id = $1", id), &document); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// CreateDocument records a file already stored under document.StorageKey
+func CreateDocument(ctx context.Context, document *models.Document) error {
+	filename, err := phi.Encrypt(document.Filename)
+	if err != nil {
+		return err
+	}
+	description, err := phi.EncryptPtr(document.Description)
+	if err != nil {
+		return err
+	}
+	return conn(ctx).QueryRow(ctx,
+		`INSERT INTO patient_documents (patient_id, appointment_id, category, filename, content_type, size_bytes, sha256, description, storage_key, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at`,
+		document.PatientID, document.AppointmentID, document.Category, filename, document.ContentType, document.SizeBytes,
+		document.SHA256, description, document.StorageKey, document.UploadedBy).Scan(&document.ID, &document.CreatedAt)
+}
+
+// DeleteDocument marks a document deleted by deletedBy, keeping the row; pgx.ErrNoRows means
+// there is no such document or it is already deleted
+func DeleteDocument(ctx context.Context, id int, deletedBy *int) (*models.Document, error) {
+	var document models.Document
+	if err := scanDocument(conn(ctx).QueryRow(ctx,
+		"UPDATE patient_documents SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2 WHERE id = $1 AND deleted_at IS NULL RETURNING "+documentColumns,
+		id, deletedBy), &document); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
@@ -0,0 +1,215 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"strings"
+
+	"bookings/models"
+)
+
+// Employee-service assignment operations
+
+// AssignService records that the employee offers the service, reporting whether it was newly added
+func AssignService(ctx context.Context, employeeID, serviceID int) (bool, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		"INSERT INTO employee_services (employee_id, service_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		employeeID, serviceID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// UnassignService removes an assignment, reporting whether one existed
+func UnassignService(ctx context.Context, employeeID, serviceID int) (bool, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		"DELETE FROM employee_services WHERE employee_id = $1 AND service_id = $2", employeeID, serviceID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetEmployeeServices returns the services explicitly assigned to an employee
+func GetEmployeeServices(ctx context.Context, employeeID int) ([]models.Service, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prefixed("s", serviceColumns)+" FROM services s JOIN employee_services es ON es.service_id = s.id WHERE es.employee_id = $1 ORDER BY s.id",
+		employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := []models.Service{}
+	for rows.Next() {
+		var service models.Service
+		if err := scanService(rows, &service); err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	return services, rows.Err()
+}
+
+// GetServiceEmployees returns the employees explicitly assigned to a service
+func GetServiceEmployees(ctx context.Context, serviceID int) ([]models.Employee, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prefixed("e", employeeColumns)+" FROM employees e JOIN employee_services es ON es.employee_id = e.id WHERE es.service_id = $1 AND e.deleted_at IS NULL ORDER BY e.id",
+		serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	employees := []models.Employee{}
+	for rows.Next() {
+		var employee models.Employee
+		if err := scanEmployee(rows, &employee); err != nil {
+			return nil, err
+		}
+		employees = append(employees, employee)
+	}
+	return employees, rows.Err()
+}
+
+// GetBookableEmployees lists the active employees who offer a service, following the same
+// rule as EmployeeOffersService
+func GetBookableEmployees(ctx context.Context, serviceID int) ([]models.Employee, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT `+employeeColumns+` FROM employees e
+		WHERE active AND deleted_at IS NULL AND (NOT EXISTS (SELECT 1 FROM employee_services WHERE employee_id = e.id)
+			OR EXISTS (SELECT 1 FROM employee_services WHERE employee_id = e.id AND service_id = $1))
+		ORDER BY id`,
+		serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	employees := []models.Employee{}
+	for rows.Next() {
+		var employee models.Employee
+		if err := scanEmployee(rows, &employee); err != nil {
+			return nil, err
+		}
+		employees = append(employees, employee)
+	}
+	return employees, rows.Err()
+}
+
+// ProviderFilter narrows the provider directory. Specialty and City are compared
+// case-insensitively; Language and Insurance must match one of the provider's entries exactly.
+type ProviderFilter struct {
+	Specialty *string
+	City      *string
+	ServiceID *int
+	Language  *string
+	Insurance *string
+}
+
+// providerFilterWhere keeps active employees of active clinics matching a ProviderFilter
+// passed as $1-$5
+const providerFilterWhere = ` WHERE e.active AND e.deleted_at IS NULL AND c.active AND c.deleted_at IS NULL
+	AND ($1::text IS NULL OR lower(e.specialty) = lower($1))
+	AND ($2::text IS NULL OR lower(c.city) = lower($2))
+	AND ($3::int IS NULL OR (EXISTS (SELECT 1 FROM services WHERE id = $3 AND active)
+		AND (NOT EXISTS (SELECT 1 FROM employee_services WHERE employee_id = e.id)
+			OR EXISTS (SELECT 1 FROM employee_services WHERE employee_id = e.id AND service_id = $3))))
+	AND ($4::text IS NULL OR e.languages @> ARRAY[$4::text])
+	AND ($5::text IS NULL OR e.accepted_insurance @> ARRAY[$5::text])`
+
+func (f ProviderFilter) args() []any {
+	return []any{f.Specialty, f.City, f.ServiceID, f.Language, f.Insurance}
+}
+
+// GetProviders returns one page of the employees listed in the provider directory that match
+// the filter, by name, with the total number that match
+func GetProviders(ctx context.Context, filter ProviderFilter, page Page) ([]models.Employee, int, error) {
+	const from = " FROM employees e JOIN clinics c ON c.id = e.clinic_id"
+	args := filter.args()
+	total, err := count(ctx, "SELECT COUNT(*)"+from+providerFilterWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prefixed("e", employeeColumns)+from+providerFilterWhere+" ORDER BY e.last_name, e.first_name, e.id LIMIT $6 OFFSET $7",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	employees := []models.Employee{}
+	for rows.Next() {
+		var employee models.Employee
+		if err := scanEmployee(rows, &employee); err != nil {
+			return nil, 0, err
+		}
+		employees = append(employees, employee)
+	}
+	return employees, total, rows.Err()
+}
+
+// GetBookableServices lists, by name, the active services at least one active employee of the
+// clinic can be booked for
+func GetBookableServices(ctx context.Context, clinicID int) ([]models.Service, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT `+serviceColumns+` FROM services s
+		WHERE active AND EXISTS (
+			SELECT 1 FROM employees e
+			WHERE e.clinic_id = $1 AND e.active AND e.deleted_at IS NULL AND (NOT EXISTS (SELECT 1 FROM employee_services WHERE employee_id = e.id)
+				OR EXISTS (SELECT 1 FROM employee_services WHERE employee_id = e.id AND service_id = s.id))
+		)
+		ORDER BY name, id`,
+		clinicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	services := []models.Service{}
+	for rows.Next() {
+		var service models.Service
+		if err := scanService(rows, &service); err != nil {
+			return nil, err
+		}
+		services = append(services, service)
+	}
+	return services, rows.Err()
+}
+
+// EmployeeOffersService reports whether the employee may be booked for the service. As with
+// GetOfferedServiceDurations, an employee with no assignments at all offers every service.
+func EmployeeOffersService(ctx context.Context, employeeID, serviceID int) (bool, error) {
+	var offers bool
+	err := conn(ctx).QueryRow(ctx,
+		`SELECT NOT EXISTS (SELECT 1 FROM employee_services WHERE employee_id = $1)
+			OR EXISTS (SELECT 1 FROM employee_services WHERE employee_id = $1 AND service_id = $2)`,
+		employeeID, serviceID).Scan(&offers)
+	return offers, err
+}
+
+// prefixed qualifies each column in a comma-separated column list with a table alias
+func prefixed(alias, columns string) string {
+	parts := strings.Split(columns, ", ")
+	for i, p := range parts {
+		parts[i] = alias + "." + p
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,74 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+
+	"bookings/models"
+)
+
+// eventOutboxLockID keys the advisory lock held while relaying the event outbox, so only
+// one instance publishes at a time and events leave in the order they were queued
+const eventOutboxLockID = 7_310_000_002
+
+// RelayEventOutbox hands up to limit queued events, oldest first, to publish and removes
+// them from the outbox once it returns nil. When publish fails nothing is removed, so the
+// same events are offered again next time. It returns how many events were relayed, 0 when
+// another instance is relaying.
+func RelayEventOutbox(ctx context.Context, limit int, publish func(ctx context.Context, events []models.AuditEntry) error) (int, error) {
+	relayed := 0
+	err := WithTx(ctx, func(ctx context.Context) error {
+		var locked bool
+		if err := conn(ctx).QueryRow(ctx, "SELECT pg_try_advisory_xact_lock($1)", eventOutboxLockID).Scan(&locked); err != nil || !locked {
+			return err
+		}
+
+		rows, err := conn(ctx).Query(ctx,
+			`SELECT o.id, a.id, a.entity, a.entity_id, a.action, a.actor_user_id, a.snapshot, a.changes, a.created_at
+			FROM event_outbox o JOIN audit_log a ON a.id = o.audit_log_id
+			ORDER BY o.id LIMIT $1`, limit)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		var events []models.AuditEntry
+		for rows.Next() {
+			var id int64
+			var e models.AuditEntry
+			if err := rows.Scan(&id, &e.ID, &e.Entity, &e.EntityID, &e.Action, &e.ActorUserID, &e.Snapshot, &e.Changes, &e.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			events = append(events, e)
+		}
+		if err := rows.Err(); err != nil || len(events) == 0 {
+			return err
+		}
+
+		if err := publish(ctx, events); err != nil {
+			return err
+		}
+		if _, err := conn(ctx).Exec(ctx, "DELETE FROM event_outbox WHERE id = ANY($1)", ids); err != nil {
+			return err
+		}
+		relayed = len(events)
+		return nil
+	})
+	return relayed, err
+}
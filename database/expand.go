@@ -0,0 +1,132 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"strings"
+
+	"bookings/models"
+)
+
+// Expand picks the records an appointment refers to that are embedded in it
+type Expand struct {
+	Patient  bool
+	Employee bool
+	Service  bool
+	Clinic   bool
+}
+
+// ExpandedAppointment is an appointment with summaries of the records picked by an Expand;
+// the others are nil
+type ExpandedAppointment struct {
+	models.Appointment
+	Patient  *models.PatientSummary  `json:"patient,omitempty"`
+	Employee *models.EmployeeSummary `json:"employee,omitempty"`
+	Service  *models.ServiceSummary  `json:"service,omitempty"`
+	Clinic   *models.ClinicSummary   `json:"clinic,omitempty"`
+}
+
+// joins returns the columns and joins that add the picked records to a query over
+// appointments aliased a
+func (x Expand) joins() (columns, joins string) {
+	var cols, from []string
+	if x.Patient {
+		cols = append(cols, "p.id, p.public_id::text, p.first_name, p.last_name, COALESCE(p.email, ''), COALESCE(p.phone, '')")
+		from = append(from, " JOIN patients p ON p.id = a.patient_id")
+	}
+	if x.Employee {
+		cols = append(cols, "e.id, e.first_name, e.last_name, COALESCE(e.specialty, '')")
+		from = append(from, " JOIN employees e ON e.id = a.employee_id")
+	}
+	if x.Service {
+		cols = append(cols, "s.id, s.name, s.duration_minutes")
+		from = append(from, " JOIN services s ON s.id = a.service_id")
+	}
+	if x.Clinic {
+		cols = append(cols, "c.id, c.name, COALESCE(c.address, '')")
+		from = append(from, " JOIN clinics c ON c.id = a.clinic_id")
+	}
+	if len(cols) > 0 {
+		columns = ", " + strings.Join(cols, ", ")
+	}
+	return columns, strings.Join(from, "")
+}
+
+// targets returns the scan destinations for the columns added by joins
+func (x Expand) targets(a *ExpandedAppointment) []any {
+	var dest []any
+	if x.Patient {
+		a.Patient = &models.PatientSummary{}
+		dest = append(dest, &a.Patient.ID, &a.Patient.PublicID, &a.Patient.FirstName, &a.Patient.LastName, &a.Patient.Email, &a.Patient.Phone)
+	}
+	if x.Employee {
+		a.Employee = &models.EmployeeSummary{}
+		dest = append(dest, &a.Employee.ID, &a.Employee.FirstName, &a.Employee.LastName, &a.Employee.Specialty)
+	}
+	if x.Service {
+		a.Service = &models.ServiceSummary{}
+		dest = append(dest, &a.Service.ID, &a.Service.Name, &a.Service.DurationMinutes)
+	}
+	if x.Clinic {
+		a.Clinic = &models.ClinicSummary{}
+		dest = append(dest, &a.Clinic.ID, &a.Clinic.Name, &a.Clinic.Address)
+	}
+	return dest
+}
+
+// GetExpandedAppointments is GetAppointments with the records picked by expand joined in
+// by the same query
+func GetExpandedAppointments(ctx context.Context, filter AppointmentFilter, page Page, expand Expand) ([]ExpandedAppointment, int, error) {
+	args := filter.args()
+	total, err := count(ctx, "SELECT COUNT(*) FROM appointments"+appointmentFilterWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	columns, joins := expand.joins()
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prefixed("a", appointmentColumns)+columns+
+			" FROM (SELECT * FROM appointments"+appointmentFilterWhere+" ORDER BY start_datetime DESC, id LIMIT $7 OFFSET $8) a"+joins+
+			" ORDER BY a.start_datetime DESC, a.id",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var appointments []ExpandedAppointment
+	for rows.Next() {
+		var appointment ExpandedAppointment
+		if err := scanAppointment(extraColumns{rows, expand.targets(&appointment)}, &appointment.Appointment); err != nil {
+			return nil, 0, err
+		}
+		appointments = append(appointments, appointment)
+	}
+	return appointments, total, rows.Err()
+}
+
+// GetExpandedAppointment is GetAppointment with the records picked by expand joined in
+func GetExpandedAppointment(ctx context.Context, id int, expand Expand) (*ExpandedAppointment, error) {
+	columns, joins := expand.joins()
+	var appointment ExpandedAppointment
+	row := conn(ctx).QueryRow(ctx,
+		"SELECT "+prefixed("a", appointmentColumns)+columns+" FROM appointments a"+joins+" WHERE a.id = $1", id)
+	if err := scanAppointment(extraColumns{row, expand.targets(&appointment)}, &appointment.Appointment); err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
@@ -0,0 +1,99 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"bookings/models"
+)
+
+// AppointmentExport is an appointment with the names of its patient, employee, service
+// and clinic, as written to a spreadsheet export
+type AppointmentExport struct {
+	models.Appointment
+	PatientName  string
+	EmployeeName string
+	ServiceName  string
+	ClinicName   string
+}
+
+// ExportCursor marks the last appointment an export has read; the zero value starts from
+// the beginning
+type ExportCursor struct {
+	Start time.Time
+	ID    int
+}
+
+// ExportAppointments returns up to limit appointments matching the filter that come after
+// the cursor, ordered by start time and id. Keyset paging keeps every chunk of a large export
+// as cheap as the first.
+func ExportAppointments(ctx context.Context, filter AppointmentFilter, after ExportCursor, limit int) ([]AppointmentExport, error) {
+	var startAfter *time.Time
+	if after.ID != 0 {
+		startAfter = &after.Start
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prefixed("a", appointmentColumns)+`, p.first_name || ' ' || p.last_name, e.first_name || ' ' || e.last_name, s.name, c.name
+		FROM (SELECT * FROM appointments`+appointmentFilterWhere+`
+			AND ($7::timestamptz IS NULL OR (start_datetime, id) > ($7, $8))
+			ORDER BY start_datetime, id LIMIT $9) a
+		JOIN patients p ON p.id = a.patient_id
+		JOIN employees e ON e.id = a.employee_id
+		JOIN services s ON s.id = a.service_id
+		JOIN clinics c ON c.id = a.clinic_id
+		ORDER BY a.start_datetime, a.id`,
+		append(filter.args(), startAfter, after.ID, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var appointments []AppointmentExport
+	for rows.Next() {
+		var export AppointmentExport
+		extra := []any{&export.PatientName, &export.EmployeeName, &export.ServiceName, &export.ClinicName}
+		if err := scanAppointment(extraColumns{rows, extra}, &export.Appointment); err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, export)
+	}
+	return appointments, rows.Err()
+}
+
+// ExportPatients returns up to limit patients with ids above afterID, in id order, leaving
+// out soft-deleted ones unless includeDeleted is set
+func ExportPatients(ctx context.Context, includeDeleted bool, afterID, limit int) ([]models.Patient, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+patientColumns+" FROM patients WHERE ($1 OR deleted_at IS NULL) AND id > $2 ORDER BY id LIMIT $3",
+		includeDeleted, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var patients []models.Patient
+	for rows.Next() {
+		var patient models.Patient
+		if err := scanPatient(rows, &patient); err != nil {
+			return nil, err
+		}
+		patients = append(patients, patient)
+	}
+	return patients, rows.Err()
+}
@@ -0,0 +1,193 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"bookings/models"
+	"bookings/phi"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrFormTemplateAnswered is returned when deleting a form template patients have answered
+var ErrFormTemplateAnswered = errors.New("form has responses and cannot be deleted; deactivate it instead")
+
+const (
+	formTemplateColumns = "id, name, description, schema, clinic_id, service_ids, appointment_types, active, created_at, updated_at"
+	formResponseColumns = "id, form_template_id, appointment_id, patient_id, schema, answers, submitted_at"
+)
+
+func scanFormTemplate(row pgx.Row, f *models.FormTemplate) error {
+	return row.Scan(&f.ID, &f.Name, &f.Description, &f.Schema, &f.ClinicID, &f.ServiceIDs, &f.AppointmentTypes,
+		&f.Active, &f.CreatedAt, &f.UpdatedAt)
+}
+
+func collectFormTemplates(rows pgx.Rows) ([]models.FormTemplate, error) {
+	defer rows.Close()
+	templates := []models.FormTemplate{}
+	for rows.Next() {
+		var f models.FormTemplate
+		if err := scanFormTemplate(rows, &f); err != nil {
+			return nil, err
+		}
+		templates = append(templates, f)
+	}
+	return templates, rows.Err()
+}
+
+func scanFormResponse(row pgx.Row, r *models.FormResponse) error {
+	var answers string
+	if err := row.Scan(&r.ID, &r.FormTemplateID, &r.AppointmentID, &r.PatientID, &r.Schema, &answers, &r.SubmittedAt); err != nil {
+		return err
+	}
+	answers, err := phi.Decrypt(answers)
+	r.Answers = json.RawMessage(answers)
+	return err
+}
+
+// Form template operations
+
+// GetFormTemplates returns the form templates by name, optionally only the active or inactive ones
+func GetFormTemplates(ctx context.Context, active *bool) ([]models.FormTemplate, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+formTemplateColumns+" FROM form_templates WHERE ($1::boolean IS NULL OR active = $1) ORDER BY name, id", active)
+	if err != nil {
+		return nil, err
+	}
+	return collectFormTemplates(rows)
+}
+
+func GetFormTemplate(ctx context.Context, id int) (*models.FormTemplate, error) {
+	var f models.FormTemplate
+	if err := scanFormTemplate(conn(ctx).QueryRow(ctx, "SELECT "+formTemplateColumns+" FROM form_templates WHERE id = $1", id), &f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// CreateFormTemplate stores a form template and fills it in as stored
+func CreateFormTemplate(ctx context.Context, f *models.FormTemplate) error {
+	return scanFormTemplate(conn(ctx).QueryRow(ctx,
+		`INSERT INTO form_templates (name, description, schema, clinic_id, service_ids, appointment_types, active)
+		VALUES ($1, $2, $3, $4, COALESCE($5::int[], '{}'), COALESCE($6::text[], '{}'), $7) RETURNING `+formTemplateColumns,
+		f.Name, f.Description, f.Schema, f.ClinicID, f.ServiceIDs, f.AppointmentTypes, f.Active), f)
+}
+
+// UpdateFormTemplate replaces a form template, reporting pgx.ErrNoRows when there is no such
+// template. Responses already given keep the schema they were answered against.
+func UpdateFormTemplate(ctx context.Context, id int, f *models.FormTemplate) error {
+	return scanFormTemplate(conn(ctx).QueryRow(ctx,
+		`UPDATE form_templates SET name = $1, description = $2, schema = $3, clinic_id = $4, service_ids = COALESCE($5::int[], '{}'),
+		appointment_types = COALESCE($6::text[], '{}'), active = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $8 RETURNING `+formTemplateColumns,
+		f.Name, f.Description, f.Schema, f.ClinicID, f.ServiceIDs, f.AppointmentTypes, f.Active, id), f)
+}
+
+// DeleteFormTemplate removes a form template no patient has answered, returning
+// ErrFormTemplateAnswered for one that has been and pgx.ErrNoRows when there is no such template
+func DeleteFormTemplate(ctx context.Context, id int) error {
+	err := conn(ctx).QueryRow(ctx, "DELETE FROM form_templates WHERE id = $1 RETURNING id", id).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrFormTemplateAnswered
+	}
+	return err
+}
+
+// formAsked matches the templates asked before an appointment passed as $1-$3: its clinic,
+// service and appointment type
+const formAsked = "(active AND (clinic_id IS NULL OR clinic_id = $1) AND ($2 = ANY(service_ids) OR $3::text = ANY(appointment_types)))"
+
+// AppointmentForm is a form asked before an appointment, or answered for it, with the
+// patient's response when there is one
+type AppointmentForm struct {
+	Template models.FormTemplate `json:"form"`
+	// Asked reports whether the form is still asked before the appointment; a form answered
+	// and since deactivated or detached is not
+	Asked    bool                 `json:"asked"`
+	Response *models.FormResponse `json:"response"`
+}
+
+// GetAppointmentForms returns the forms of an appointment by name: the active templates for
+// its clinic, or for every clinic, that list its service or its appointment type, and any
+// other template the patient has already answered for it
+func GetAppointmentForms(ctx context.Context, appointment *models.Appointment) ([]AppointmentForm, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+formTemplateColumns+", COALESCE("+formAsked+", false) FROM form_templates t WHERE "+formAsked+`
+		OR EXISTS (SELECT 1 FROM form_responses r WHERE r.form_template_id = t.id AND r.appointment_id = $4)
+		ORDER BY name, id`,
+		appointment.ClinicID, appointment.ServiceID, appointment.AppointmentType, appointment.ID)
+	if err != nil {
+		return nil, err
+	}
+	forms := []AppointmentForm{}
+	for rows.Next() {
+		var form AppointmentForm
+		f := &form.Template
+		if err := rows.Scan(&f.ID, &f.Name, &f.Description, &f.Schema, &f.ClinicID, &f.ServiceIDs, &f.AppointmentTypes,
+			&f.Active, &f.CreatedAt, &f.UpdatedAt, &form.Asked); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		forms = append(forms, form)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = conn(ctx).Query(ctx,
+		"SELECT "+formResponseColumns+" FROM form_responses WHERE appointment_id = $1", appointment.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.FormResponse
+		if err := scanFormResponse(rows, &r); err != nil {
+			return nil, err
+		}
+		for i := range forms {
+			if forms[i].Template.ID == r.FormTemplateID {
+				forms[i].Response = &r
+			}
+		}
+	}
+	return forms, rows.Err()
+}
+
+// Form response operations
+
+// SaveFormResponse stores a patient's answers to a form for an appointment, replacing any
+// they gave before, and fills the response in as stored
+func SaveFormResponse(ctx context.Context, r *models.FormResponse) error {
+	answers, err := phi.Encrypt(string(r.Answers))
+	if err != nil {
+		return err
+	}
+	return scanFormResponse(conn(ctx).QueryRow(ctx,
+		`INSERT INTO form_responses (form_template_id, appointment_id, patient_id, schema, answers) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (form_template_id, appointment_id) DO UPDATE SET schema = EXCLUDED.schema, answers = EXCLUDED.answers,
+		submitted_at = CURRENT_TIMESTAMP
+		RETURNING `+formResponseColumns,
+		r.FormTemplateID, r.AppointmentID, r.PatientID, r.Schema, answers), r)
+}
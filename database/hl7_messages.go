@@ -0,0 +1,111 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"bookings/phi"
+)
+
+// EnqueueHL7Message queues an HL7 message about an appointment. build is given the
+// message's control id (its row id) and returns the message, which is stored encrypted.
+func EnqueueHL7Message(ctx context.Context, appointmentID int, triggerEvent string, build func(controlID string) (string, error)) error {
+	var id int
+	if err := conn(ctx).QueryRow(ctx, "SELECT nextval(pg_get_serial_sequence('hl7_messages', 'id'))").Scan(&id); err != nil {
+		return err
+	}
+	message, err := build(strconv.Itoa(id))
+	if err != nil {
+		return err
+	}
+	sealed, err := phi.Encrypt(message)
+	if err != nil {
+		return err
+	}
+	_, err = conn(ctx).Exec(ctx,
+		"INSERT INTO hl7_messages (id, appointment_id, trigger_event, message) VALUES ($1, $2, $3, $4)",
+		id, appointmentID, triggerEvent, sealed)
+	return err
+}
+
+// DueHL7Message is an HL7 message claimed for another attempt
+type DueHL7Message struct {
+	ID            int
+	AppointmentID *int
+	TriggerEvent  string
+	// Attempts is the number of attempts made before this one
+	Attempts int
+	Message  string
+}
+
+// ClaimHL7Messages claims up to limit PENDING messages due at now, oldest first, moving their
+// next attempt to leaseUntil so no other worker picks them up while they are being sent. A
+// message waits while an earlier one about the same appointment is still pending, so the
+// receiver sees an appointment's changes in the order they happened.
+func ClaimHL7Messages(ctx context.Context, now, leaseUntil time.Time, limit int) ([]DueHL7Message, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`WITH due AS (
+			SELECT id FROM hl7_messages m
+			WHERE status = 'PENDING' AND next_attempt_at <= $1
+				AND NOT EXISTS (SELECT 1 FROM hl7_messages e
+					WHERE e.appointment_id = m.appointment_id AND e.status = 'PENDING' AND e.id < m.id)
+			ORDER BY id LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE hl7_messages m SET next_attempt_at = $2 FROM due WHERE m.id = due.id
+		RETURNING m.id, m.appointment_id, m.trigger_event, m.attempts, m.message`,
+		now.UTC(), leaseUntil.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DueHL7Message
+	for rows.Next() {
+		var d DueHL7Message
+		if err := rows.Scan(&d.ID, &d.AppointmentID, &d.TriggerEvent, &d.Attempts, &d.Message); err != nil {
+			return nil, err
+		}
+		if d.Message, err = phi.Decrypt(d.Message); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// RecordHL7Attempt moves a message on after an attempt at attemptedAt: SENT when errMessage
+// is nil, PENDING until retryAt when it failed and is to be retried, or FAILED when it
+// failed and retryAt is nil
+func RecordHL7Attempt(ctx context.Context, id int, attemptedAt time.Time, errMessage *string, retryAt *time.Time) error {
+	status := "FAILED"
+	var sentAt *time.Time
+	nextAttemptAt := attemptedAt
+	switch {
+	case errMessage == nil:
+		status, sentAt = "SENT", &attemptedAt
+	case retryAt != nil:
+		status, nextAttemptAt = "PENDING", *retryAt
+	}
+	_, err := conn(ctx).Exec(ctx,
+		"UPDATE hl7_messages SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_error = $4, sent_at = $5 WHERE id = $1",
+		id, status, nextAttemptAt.UTC(), errMessage, sentAt)
+	return err
+}
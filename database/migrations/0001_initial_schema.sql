@@ -0,0 +1,253 @@
+-- Initial schema. Written to be safe on databases created by the old drop-and-recreate
+-- startup: types are only created when missing and tables/indexes use IF NOT EXISTS.
+
+-- btree_gist lets the appointment overlap constraint combine employee_id equality with range overlap
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- Create enum types
+DO $$ BEGIN
+    CREATE TYPE appointment_status AS ENUM ('SCHEDULED', 'CONFIRMED', 'IN_PROGRESS', 'COMPLETED', 'CANCELLED', 'NO_SHOW');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+DO $$ BEGIN
+    CREATE TYPE appointment_type AS ENUM ('INITIAL_CONSULTATION', 'FOLLOW_UP', 'PROCEDURE', 'EMERGENCY');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+DO $$ BEGIN
+    CREATE TYPE payment_status AS ENUM ('PENDING', 'PAID', 'REFUNDED');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+DO $$ BEGIN
+    CREATE TYPE urgency_level AS ENUM ('LOW', 'MEDIUM', 'HIGH', 'URGENT');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+DO $$ BEGIN
+    CREATE TYPE waiting_list_status AS ENUM ('ACTIVE', 'CONTACTED', 'SCHEDULED', 'EXPIRED');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+DO $$ BEGIN
+    CREATE TYPE payment_link_status AS ENUM ('PENDING', 'PAID', 'EXPIRED', 'CANCELLED');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+DO $$ BEGIN
+    CREATE TYPE booking_channel AS ENUM ('PHONE', 'WALK_IN', 'WEB', 'PORTAL');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+DO $$ BEGIN
+    CREATE TYPE time_off_status AS ENUM ('PENDING', 'APPROVED', 'REJECTED');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+DO $$ BEGIN
+    CREATE TYPE user_role AS ENUM ('ADMIN', 'CLINICIAN', 'RECEPTIONIST', 'PATIENT');
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
+-- Create tables
+CREATE TABLE IF NOT EXISTS clinics (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    address TEXT,
+    phone TEXT,
+    email TEXT,
+    active BOOLEAN DEFAULT TRUE,
+    sms_reminders_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    email_reminders_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    high_risk_extra_reminders BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS patients (
+    id SERIAL PRIMARY KEY,
+    public_id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    first_name TEXT NOT NULL,
+    last_name TEXT NOT NULL,
+    email TEXT UNIQUE,
+    phone TEXT,
+    date_of_birth TEXT,
+    medical_record_number TEXT UNIQUE,
+    insurance_provider TEXT,
+    insurance_id TEXT,
+    emergency_contact_name TEXT,
+    emergency_contact_phone TEXT,
+    active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS employees (
+    id SERIAL PRIMARY KEY,
+    clinic_id INTEGER NOT NULL REFERENCES clinics(id),
+    first_name TEXT NOT NULL,
+    last_name TEXT NOT NULL,
+    email TEXT UNIQUE,
+    phone TEXT,
+    license_number TEXT UNIQUE,
+    specialty TEXT,
+    timezone TEXT DEFAULT 'Asia/Colombo',
+    follow_up_reserve_percent INTEGER NOT NULL DEFAULT 0 CHECK (follow_up_reserve_percent >= 0 AND follow_up_reserve_percent <= 100),
+    follow_up_release_days INTEGER NOT NULL DEFAULT 0 CHECK (follow_up_release_days >= 0),
+    active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS services (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+    price DECIMAL,
+    specialty_required TEXT,
+    min_lead_minutes INTEGER NOT NULL DEFAULT 0 CHECK (min_lead_minutes >= 0),
+    same_day_cutoff_hour INTEGER CHECK (same_day_cutoff_hour >= 0 AND same_day_cutoff_hour <= 24),
+    allows_multi_day BOOLEAN NOT NULL DEFAULT FALSE,
+    prepayment_window_minutes INTEGER CHECK (prepayment_window_minutes > 0),
+    active BOOLEAN DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS employee_services (
+    employee_id INTEGER NOT NULL REFERENCES employees(id) ON DELETE CASCADE,
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    UNIQUE (employee_id, service_id)
+);
+
+CREATE TABLE IF NOT EXISTS work_templates (
+    id SERIAL PRIMARY KEY,
+    employee_id INTEGER NOT NULL REFERENCES employees(id),
+    weekday INTEGER NOT NULL CHECK (weekday >= 1 AND weekday <= 7),
+    start_time TIME,
+    end_time TIME,
+    slot_granularity_minutes INTEGER DEFAULT 15,
+    is_active BOOLEAN DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS day_overrides (
+    id SERIAL PRIMARY KEY,
+    employee_id INTEGER NOT NULL REFERENCES employees(id),
+    date DATE NOT NULL,
+    is_closed BOOLEAN DEFAULT FALSE,
+    start_time TIME,
+    end_time TIME,
+    reason TEXT,
+    UNIQUE (employee_id, date)
+);
+
+CREATE TABLE IF NOT EXISTS time_off (
+    id SERIAL PRIMARY KEY,
+    employee_id INTEGER NOT NULL REFERENCES employees(id),
+    start_datetime TIMESTAMPTZ NOT NULL,
+    end_datetime TIMESTAMPTZ NOT NULL,
+    reason TEXT,
+    status time_off_status NOT NULL DEFAULT 'PENDING',
+    decided_at TIMESTAMPTZ,
+    decision_note TEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_datetime > start_datetime)
+);
+
+CREATE TABLE IF NOT EXISTS slot_holds (
+    id SERIAL PRIMARY KEY,
+    employee_id INTEGER NOT NULL REFERENCES employees(id),
+    service_id INTEGER NOT NULL REFERENCES services(id),
+    start_datetime TIMESTAMPTZ NOT NULL,
+    end_datetime TIMESTAMPTZ NOT NULL,
+    patient_id INTEGER REFERENCES patients(id),
+    hold_token TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS appointments (
+    id SERIAL PRIMARY KEY,
+    public_id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    employee_id INTEGER NOT NULL REFERENCES employees(id),
+    service_id INTEGER NOT NULL REFERENCES services(id),
+    clinic_id INTEGER NOT NULL REFERENCES clinics(id),
+    start_datetime TIMESTAMPTZ NOT NULL,
+    end_datetime TIMESTAMPTZ NOT NULL,
+    status appointment_status DEFAULT 'SCHEDULED',
+    appointment_type appointment_type,
+    booking_channel booking_channel,
+    notes TEXT,
+    medical_notes TEXT,
+    cancellation_reason TEXT,
+    payment_status payment_status DEFAULT 'PENDING',
+    payment_amount DECIMAL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_datetime > start_datetime),
+    CONSTRAINT appointments_no_overlap EXCLUDE USING gist (
+        employee_id WITH =,
+        tstzrange(start_datetime, end_datetime) WITH &&
+    ) WHERE (status NOT IN ('CANCELLED', 'NO_SHOW'))
+);
+
+CREATE TABLE IF NOT EXISTS waiting_list (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    service_id INTEGER NOT NULL REFERENCES services(id),
+    preferred_employee_id INTEGER REFERENCES employees(id),
+    requested_date TEXT,
+    urgency_level urgency_level DEFAULT 'MEDIUM',
+    notes TEXT,
+    status waiting_list_status DEFAULT 'ACTIVE',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS payment_links (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    appointment_id INTEGER REFERENCES appointments(id),
+    token TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    amount DECIMAL NOT NULL,
+    status payment_link_status DEFAULT 'PENDING',
+    expires_at TIMESTAMPTZ NOT NULL,
+    paid_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS payment_link_items (
+    payment_link_id INTEGER NOT NULL REFERENCES payment_links(id) ON DELETE CASCADE,
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id),
+    amount DECIMAL NOT NULL,
+    UNIQUE (payment_link_id, appointment_id)
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    entity TEXT NOT NULL,
+    entity_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    snapshot JSONB,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    role user_role NOT NULL,
+    patient_id INTEGER UNIQUE REFERENCES patients(id) ON DELETE CASCADE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((role = 'PATIENT') = (patient_id IS NOT NULL))
+);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes
+CREATE INDEX IF NOT EXISTS idx_appointments_patient_id ON appointments(patient_id);
+CREATE INDEX IF NOT EXISTS idx_appointments_employee_id ON appointments(employee_id);
+CREATE INDEX IF NOT EXISTS idx_appointments_datetime ON appointments(start_datetime);
+CREATE INDEX IF NOT EXISTS idx_appointments_status ON appointments(status);
+CREATE INDEX IF NOT EXISTS idx_slot_holds_datetime ON slot_holds(start_datetime, end_datetime);
+CREATE INDEX IF NOT EXISTS idx_time_off_datetime ON time_off(start_datetime, end_datetime);
+CREATE INDEX IF NOT EXISTS idx_payment_links_patient_id ON payment_links(patient_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
@@ -0,0 +1,3 @@
+-- Supports filtering GET /api/appointments by clinic_id; the other filter columns are
+-- already indexed.
+CREATE INDEX IF NOT EXISTS idx_appointments_clinic_id ON appointments(clinic_id);
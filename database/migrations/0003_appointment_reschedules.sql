@@ -0,0 +1,16 @@
+-- History of appointment moves made through POST /api/appointments/:id/reschedule
+CREATE TABLE IF NOT EXISTS appointment_reschedules (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    old_start_datetime TIMESTAMPTZ NOT NULL,
+    old_end_datetime TIMESTAMPTZ NOT NULL,
+    old_employee_id INTEGER NOT NULL REFERENCES employees(id),
+    new_start_datetime TIMESTAMPTZ NOT NULL,
+    new_end_datetime TIMESTAMPTZ NOT NULL,
+    new_employee_id INTEGER NOT NULL REFERENCES employees(id),
+    reason TEXT,
+    rescheduled_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_reschedules_appointment_id ON appointment_reschedules(appointment_id);
@@ -0,0 +1,9 @@
+-- Reminders the reminder worker has sent, so each one goes out once. A reminder is keyed by
+-- its send time, so moving an appointment schedules fresh reminders for the new time.
+CREATE TABLE IF NOT EXISTS sent_reminders (
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    channel VARCHAR(10) NOT NULL,
+    scheduled_at TIMESTAMPTZ NOT NULL,
+    sent_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (appointment_id, channel, scheduled_at)
+);
@@ -0,0 +1,10 @@
+-- Secret feed URLs that let calendar apps subscribe to an employee's or patient's
+-- appointments without logging in. Only a hash of each token is stored.
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    id SERIAL PRIMARY KEY,
+    owner_type VARCHAR(10) NOT NULL CHECK (owner_type IN ('EMPLOYEE', 'PATIENT')),
+    owner_id INTEGER NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner_type, owner_id)
+);
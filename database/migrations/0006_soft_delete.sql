@@ -0,0 +1,9 @@
+-- Patients, employees and clinics are marked deleted rather than removed, so their
+-- appointments and history stay intact and a deletion can be undone.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- A deleted clinic no longer reserves its name
+ALTER TABLE clinics DROP CONSTRAINT IF EXISTS clinics_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS clinics_name_active_key ON clinics (name) WHERE deleted_at IS NULL;
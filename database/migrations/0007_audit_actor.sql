@@ -0,0 +1,6 @@
+-- Who made each audited change and what it changed, for compliance review. actor_user_id is
+-- NULL for changes made by background jobs.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS actor_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS changes JSONB;
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_user_id, created_at);
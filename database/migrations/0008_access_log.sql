@@ -0,0 +1,14 @@
+-- Reads of patient records and appointment medical notes, for "who viewed this chart"
+-- investigations. appointment_id is set for medical notes reads.
+CREATE TABLE IF NOT EXISTS access_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    appointment_id INTEGER REFERENCES appointments(id) ON DELETE SET NULL,
+    resource TEXT NOT NULL,
+    endpoint TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_log_patient ON access_log(patient_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_access_log_actor ON access_log(actor_user_id, created_at);
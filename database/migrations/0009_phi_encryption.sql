@@ -0,0 +1,6 @@
+-- date_of_birth, medical_record_number, insurance_id and medical_notes are encrypted by the
+-- application, so the medical record number's uniqueness moves to a keyed hash of it.
+-- Existing plaintext values stay readable; run the server with -encrypt-phi to seal them.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS medical_record_number_index TEXT;
+ALTER TABLE patients DROP CONSTRAINT IF EXISTS patients_medical_record_number_key;
+CREATE UNIQUE INDEX IF NOT EXISTS patients_medical_record_number_index_key ON patients(medical_record_number_index);
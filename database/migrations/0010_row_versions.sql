@@ -0,0 +1,4 @@
+-- Row versions for optimistic concurrency: every change to a patient or appointment bumps
+-- its version, and updates made from a stale copy are refused.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
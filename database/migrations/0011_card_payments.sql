@@ -0,0 +1,22 @@
+-- Card payments taken through Stripe, one row per PaymentIntent, and the webhook events
+-- already applied so a redelivered event is not applied twice.
+CREATE TABLE IF NOT EXISTS card_payments (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    payment_intent_id VARCHAR(255) UNIQUE NOT NULL,
+    amount DECIMAL NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING'
+        CHECK (status IN ('PENDING', 'SUCCEEDED', 'FAILED', 'REFUND_PENDING', 'REFUNDED')),
+    refund_id VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_payments_appointment ON card_payments(appointment_id);
+
+CREATE TABLE IF NOT EXISTS stripe_events (
+    event_id VARCHAR(255) PRIMARY KEY,
+    type VARCHAR(100) NOT NULL,
+    received_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
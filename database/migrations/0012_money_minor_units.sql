@@ -0,0 +1,29 @@
+-- Money is stored as whole minor units of a currency (e.g. cents) next to its ISO 4217
+-- code instead of a DECIMAL in an implied currency, so amounts add up exactly. Existing
+-- prices and payments had no currency of their own and are taken to be US dollars.
+ALTER TABLE services ADD COLUMN IF NOT EXISTS price_minor BIGINT NOT NULL DEFAULT 0 CHECK (price_minor >= 0);
+ALTER TABLE services ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+UPDATE services SET price_minor = ROUND(price * 100) WHERE price IS NOT NULL;
+ALTER TABLE services DROP COLUMN IF EXISTS price;
+
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS payment_amount_minor BIGINT CHECK (payment_amount_minor >= 0);
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS payment_currency CHAR(3);
+UPDATE appointments SET payment_amount_minor = ROUND(payment_amount * 100), payment_currency = 'USD'
+    WHERE payment_amount IS NOT NULL;
+ALTER TABLE appointments DROP COLUMN IF EXISTS payment_amount;
+ALTER TABLE appointments ADD CONSTRAINT appointments_payment_currency_check
+    CHECK ((payment_amount_minor IS NULL) = (payment_currency IS NULL));
+
+ALTER TABLE payment_links ADD COLUMN IF NOT EXISTS amount_minor BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE payment_links ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+UPDATE payment_links SET amount_minor = ROUND(amount * 100);
+ALTER TABLE payment_links DROP COLUMN IF EXISTS amount;
+
+ALTER TABLE payment_link_items ADD COLUMN IF NOT EXISTS amount_minor BIGINT NOT NULL DEFAULT 0;
+UPDATE payment_link_items SET amount_minor = ROUND(amount * 100);
+ALTER TABLE payment_link_items DROP COLUMN IF EXISTS amount;
+
+ALTER TABLE card_payments ADD COLUMN IF NOT EXISTS amount_minor BIGINT NOT NULL DEFAULT 0;
+UPDATE card_payments SET amount_minor = ROUND(amount * 100), currency = UPPER(currency);
+ALTER TABLE card_payments DROP COLUMN IF EXISTS amount;
+ALTER TABLE card_payments ALTER COLUMN currency TYPE CHAR(3);
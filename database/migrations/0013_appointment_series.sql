@@ -0,0 +1,17 @@
+-- Appointment series: repeating bookings such as weekly physiotherapy for 8 weeks, generated
+-- from a recurrence rule. Every occurrence is an ordinary appointment pointing at its series.
+CREATE TABLE IF NOT EXISTS appointment_series (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    employee_id INTEGER NOT NULL REFERENCES employees(id),
+    service_id INTEGER NOT NULL REFERENCES services(id),
+    clinic_id INTEGER NOT NULL REFERENCES clinics(id),
+    recurrence TEXT NOT NULL,
+    start_datetime TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'CANCELLED')),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS series_id INTEGER REFERENCES appointment_series(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_appointments_series ON appointments(series_id) WHERE series_id IS NOT NULL;
@@ -0,0 +1,20 @@
+-- Clinic opening hours and closures. Availability is the intersection of an employee's hours
+-- with their clinic's. A clinic without any opening hours is open whenever its employees work;
+-- once hours are set, weekdays without a row are closed. A holiday closes the whole date.
+CREATE TABLE IF NOT EXISTS clinic_hours (
+    id SERIAL PRIMARY KEY,
+    clinic_id INTEGER NOT NULL REFERENCES clinics(id),
+    weekday INTEGER NOT NULL CHECK (weekday >= 1 AND weekday <= 7),
+    open_time TIME NOT NULL,
+    close_time TIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_clinic_hours_clinic ON clinic_hours(clinic_id, weekday);
+
+CREATE TABLE IF NOT EXISTS clinic_holidays (
+    id SERIAL PRIMARY KEY,
+    clinic_id INTEGER NOT NULL REFERENCES clinics(id),
+    date DATE NOT NULL,
+    name TEXT NOT NULL,
+    UNIQUE (clinic_id, date)
+);
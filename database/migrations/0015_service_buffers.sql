@@ -0,0 +1,4 @@
+-- Preparation and clean-up time around a service's appointments (e.g. room cleaning after a
+-- procedure). Buffers keep the employee busy without being part of the patient's booking.
+ALTER TABLE services ADD COLUMN IF NOT EXISTS buffer_before_minutes INTEGER NOT NULL DEFAULT 0 CHECK (buffer_before_minutes >= 0);
+ALTER TABLE services ADD COLUMN IF NOT EXISTS buffer_after_minutes INTEGER NOT NULL DEFAULT 0 CHECK (buffer_after_minutes >= 0);
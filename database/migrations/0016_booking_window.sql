@@ -0,0 +1,6 @@
+-- How far ahead bookings must and may be made. Services already carry a minimum lead time;
+-- clinics get one too, and both can cap how many days out a booking may be. The stricter
+-- of the clinic's and the service's rules applies.
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS min_lead_minutes INTEGER NOT NULL DEFAULT 0 CHECK (min_lead_minutes >= 0);
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS max_advance_days INTEGER CHECK (max_advance_days > 0);
+ALTER TABLE services ADD COLUMN IF NOT EXISTS max_advance_days INTEGER CHECK (max_advance_days > 0);
@@ -0,0 +1,26 @@
+-- Rooms and equipment. A service that needs resources maps to the ones it can use; a booking
+-- takes one free resource of every kind its service maps to at the booking's clinic, and a
+-- resource is never given to two active appointments whose buffered times overlap.
+CREATE TABLE IF NOT EXISTS resources (
+    id SERIAL PRIMARY KEY,
+    clinic_id INTEGER NOT NULL REFERENCES clinics(id),
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('ROOM', 'EQUIPMENT')),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (clinic_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS service_resources (
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    resource_id INTEGER NOT NULL REFERENCES resources(id) ON DELETE CASCADE,
+    PRIMARY KEY (service_id, resource_id)
+);
+
+CREATE TABLE IF NOT EXISTS appointment_resources (
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    resource_id INTEGER NOT NULL REFERENCES resources(id),
+    PRIMARY KEY (appointment_id, resource_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_resources_resource ON appointment_resources(resource_id);
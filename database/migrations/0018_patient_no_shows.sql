@@ -0,0 +1,7 @@
+-- How many of a patient's appointments were missed, kept on the patient record so staff can
+-- spot repeat no-shows (e.g. to ask for a deposit) without counting appointments each time.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS no_show_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE patients p SET no_show_count = missed.n
+FROM (SELECT patient_id, COUNT(*) AS n FROM appointments WHERE status = 'NO_SHOW' GROUP BY patient_id) missed
+WHERE p.id = missed.patient_id;
@@ -0,0 +1,5 @@
+-- When an URGENT waiting list entry had waited past the SLA and staff were alerted. Set once,
+-- so each entry escalates at most once, and kept afterwards for the breach statistics.
+ALTER TABLE waiting_list ADD COLUMN IF NOT EXISTS escalated_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_waiting_list_status_created ON waiting_list(status, created_at);
@@ -0,0 +1,9 @@
+-- Outbox of domain events for the event bus (EVENT_BUS). When a bus is configured every
+-- audit entry queues an event in the same statement, so an event is stored exactly when
+-- its audit entry is; the relay publishes queued events in order and deletes them once
+-- the broker has accepted them. The event itself is the audit entry.
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    audit_log_id BIGINT NOT NULL REFERENCES audit_log(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,40 @@
+-- Every change to an appointment is announced on the schedule_changes channel for the live
+-- schedule WebSocket. NOTIFY is delivered when the transaction commits, to every server
+-- listening, so dashboards connected to any instance see changes made through all of them
+-- and by background jobs. The payload stays far below the 8000 byte limit.
+CREATE OR REPLACE FUNCTION notify_schedule_change() RETURNS trigger AS $$
+DECLARE
+    appt appointments;
+    change TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        appt := OLD;
+        change := 'deleted';
+    ELSE
+        appt := NEW;
+        IF TG_OP = 'INSERT' THEN
+            change := 'created';
+        ELSIF NEW.status = 'CANCELLED' AND OLD.status IS DISTINCT FROM 'CANCELLED' THEN
+            change := 'cancelled';
+        ELSE
+            change := 'updated';
+        END IF;
+    END IF;
+    PERFORM pg_notify('schedule_changes', json_build_object(
+        'type', 'appointment.' || change,
+        'appointment_id', appt.id,
+        'clinic_id', appt.clinic_id,
+        'employee_id', appt.employee_id,
+        'patient_id', appt.patient_id,
+        'status', appt.status,
+        'start_datetime', appt.start_datetime,
+        'end_datetime', appt.end_datetime
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS appointments_schedule_notify ON appointments;
+CREATE TRIGGER appointments_schedule_notify
+    AFTER INSERT OR UPDATE OR DELETE ON appointments
+    FOR EACH ROW EXECUTE FUNCTION notify_schedule_change();
@@ -0,0 +1,9 @@
+-- Secret URLs of the waiting room displays. A clinic has at most one; issuing a new one
+-- replaces (and so revokes) the previous URL. Only a hash of the token is stored, as for
+-- calendar feeds.
+CREATE TABLE IF NOT EXISTS waiting_room_displays (
+    id SERIAL PRIMARY KEY,
+    clinic_id INTEGER NOT NULL UNIQUE REFERENCES clinics(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
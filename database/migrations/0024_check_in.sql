@@ -0,0 +1,6 @@
+-- Front desk check-in and check-out. checked_in_at is when the patient arrived, started_at
+-- when the appointment went IN_PROGRESS and checked_out_at when it was completed; the
+-- clinic queue and its wait times are computed from them.
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS checked_in_at TIMESTAMPTZ;
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS checked_out_at TIMESTAMPTZ;
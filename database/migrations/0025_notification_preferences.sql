@@ -0,0 +1,15 @@
+-- How each patient wants to be notified. Patients without a row get the defaults: every
+-- channel they have contact details for, no quiet hours and the configured reminder lead
+-- times. Quiet hours are a local time range in the appointment employee's timezone and may
+-- run past midnight (e.g. 21:00 to 07:00).
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    patient_id INTEGER PRIMARY KEY REFERENCES patients(id) ON DELETE CASCADE,
+    channel VARCHAR(10) NOT NULL DEFAULT 'ALL' CHECK (channel IN ('ALL', 'EMAIL', 'SMS', 'NONE')),
+    preferred_language VARCHAR(35),
+    quiet_hours_start TIME,
+    quiet_hours_end TIME,
+    reminder_lead_minutes INTEGER CHECK (reminder_lead_minutes > 0),
+    unsubscribed_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((quiet_hours_start IS NULL) = (quiet_hours_end IS NULL))
+);
@@ -0,0 +1,25 @@
+-- Delivery log of every outbound email and SMS. A message is recorded PENDING before it is
+-- sent and ends SENT, with the provider's message id, or FAILED. A transient failure stays
+-- PENDING with its next attempt scheduled, and the retry job sends it again with backoff.
+-- The message itself is kept (encrypted like the PHI columns) only until then.
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER REFERENCES appointments(id) ON DELETE SET NULL,
+    patient_id INTEGER REFERENCES patients(id) ON DELETE SET NULL,
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('EMAIL', 'SMS')),
+    recipient TEXT NOT NULL,
+    template VARCHAR(50) NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'SENT', 'FAILED')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    provider_message_id TEXT,
+    last_error TEXT,
+    payload TEXT,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_due ON notifications(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_notifications_appointment ON notifications(appointment_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_patient ON notifications(patient_id, created_at);
@@ -0,0 +1,20 @@
+-- Outbox of HL7v2 SIU messages exported to a legacy HIS: one per appointment booked
+-- (S12), rescheduled (S13) or cancelled (S15). A message is PENDING until the receiver
+-- acknowledges it, SENT once it has, and FAILED when it was rejected or ran out of attempts.
+-- Messages of one appointment are sent in order. The message is encrypted like the PHI
+-- columns.
+CREATE TABLE IF NOT EXISTS hl7_messages (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER REFERENCES appointments(id) ON DELETE SET NULL,
+    trigger_event VARCHAR(3) NOT NULL CHECK (trigger_event IN ('S12', 'S13', 'S15')),
+    message TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'SENT', 'FAILED')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error TEXT,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_hl7_messages_due ON hl7_messages(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS idx_hl7_messages_appointment ON hl7_messages(appointment_id, id);
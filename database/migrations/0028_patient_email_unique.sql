@@ -0,0 +1,4 @@
+-- Patients without an email are stored with an empty one, which the plain UNIQUE constraint
+-- let only one patient have. Emails stay unique, but only when there is one.
+ALTER TABLE patients DROP CONSTRAINT IF EXISTS patients_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS patients_email_key ON patients(email) WHERE email <> '';
@@ -0,0 +1,23 @@
+-- When an appointment was cancelled, for telling late cancellations from timely ones. A
+-- trigger stamps it, so every way of cancelling (staff, the portal, series and the unpaid
+-- booking sweep) records it, and clears it when a cancellation is undone. Appointments
+-- cancelled before this migration take their last update time, the closest record there is.
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+
+UPDATE appointments SET cancelled_at = updated_at WHERE status = 'CANCELLED' AND cancelled_at IS NULL;
+
+CREATE OR REPLACE FUNCTION stamp_cancelled_at() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status <> 'CANCELLED' THEN
+        NEW.cancelled_at := NULL;
+    ELSIF TG_OP = 'INSERT' OR OLD.status IS DISTINCT FROM 'CANCELLED' THEN
+        NEW.cancelled_at := CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS appointments_cancelled_at ON appointments;
+CREATE TRIGGER appointments_cancelled_at
+    BEFORE INSERT OR UPDATE OF status ON appointments
+    FOR EACH ROW EXECUTE FUNCTION stamp_cancelled_at();
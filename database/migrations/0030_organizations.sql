@@ -0,0 +1,18 @@
+-- Organizations are the tenants of a shared deployment: each clinic group is one, and its
+-- clinics and user accounts belong to it. Everything that exists already, and every
+-- single-tenant deployment, belongs to the default organization, which keeps id 1.
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    slug TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO organizations (id, name, slug) VALUES (1, 'Default', 'default') ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('organizations', 'id'), (SELECT MAX(id) FROM organizations));
+
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+
+CREATE INDEX IF NOT EXISTS idx_clinics_organization ON clinics(organization_id);
+CREATE INDEX IF NOT EXISTS idx_users_organization ON users(organization_id);
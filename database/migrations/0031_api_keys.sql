@@ -0,0 +1,22 @@
+-- Keys third-party integrations call the API with instead of a user login. Only a hash of
+-- each key is stored; prefix is its first characters, for telling keys apart. scopes maps
+-- route groups to "read" or "write". Revoked keys are kept so the audit and access logs
+-- can still name the key behind an entry.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes JSONB NOT NULL,
+    organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id),
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    rotated_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS actor_api_key_id INTEGER REFERENCES api_keys(id);
+ALTER TABLE access_log ADD COLUMN IF NOT EXISTS actor_api_key_id INTEGER REFERENCES api_keys(id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_api_key ON audit_log(actor_api_key_id, created_at);
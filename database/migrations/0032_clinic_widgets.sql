@@ -0,0 +1,14 @@
+-- Settings of the booking widget a clinic embeds on its website. The slug addresses the
+-- clinic in public URLs; allowed_origins lists the sites that may load the widget, and an
+-- empty list lets any site load it.
+CREATE TABLE IF NOT EXISTS clinic_widgets (
+    clinic_id INTEGER PRIMARY KEY REFERENCES clinics(id) ON DELETE CASCADE,
+    slug TEXT NOT NULL UNIQUE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    logo_url TEXT,
+    primary_color TEXT,
+    welcome_message TEXT,
+    allowed_origins TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,13 @@
+-- Profile fields for the public provider directory. Languages are BCP 47 tags such as "en"
+-- or "pt-BR"; accepted insurance lists the provider names patients search by. Clinics gain
+-- the city patients filter on.
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS bio TEXT NOT NULL DEFAULT '';
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS photo_url TEXT;
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS languages TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS accepted_insurance TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS city TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_employees_specialty ON employees(lower(specialty)) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_employees_languages ON employees USING GIN (languages);
+CREATE INDEX IF NOT EXISTS idx_employees_accepted_insurance ON employees USING GIN (accepted_insurance);
+CREATE INDEX IF NOT EXISTS idx_clinics_city ON clinics(lower(city)) WHERE deleted_at IS NULL;
@@ -0,0 +1,11 @@
+-- Clinic coordinates for distance search. Distances use the earthdistance extension (on top
+-- of cube), which treats the Earth as a sphere: plenty for finding nearby clinics. The GiST
+-- index on ll_to_earth serves the earth_box prefilter of the nearby search.
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90);
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180);
+
+CREATE INDEX IF NOT EXISTS idx_clinics_location ON clinics USING GIST (ll_to_earth(latitude, longitude))
+    WHERE latitude IS NOT NULL AND longitude IS NOT NULL AND deleted_at IS NULL;
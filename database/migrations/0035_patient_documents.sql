@@ -0,0 +1,24 @@
+-- Files attached to patients: referral letters, lab results, scans. The file itself is in the
+-- object store under storage_key, a random name that says nothing about the patient; the row
+-- keeps what is needed to list and serve it, with filename and description encrypted like the
+-- PHI columns. Deleting a document removes the file but keeps the row, marked deleted, as the
+-- record that it existed and who removed it.
+CREATE TABLE IF NOT EXISTS patient_documents (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    appointment_id INTEGER REFERENCES appointments(id) ON DELETE SET NULL,
+    category VARCHAR(20) NOT NULL CHECK (category IN ('REFERRAL', 'LAB_RESULT', 'IMAGING', 'OTHER')),
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    sha256 TEXT NOT NULL,
+    description TEXT,
+    storage_key TEXT NOT NULL UNIQUE,
+    uploaded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ,
+    deleted_by INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_patient_documents_patient ON patient_documents(patient_id, created_at) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_patient_documents_appointment ON patient_documents(appointment_id) WHERE appointment_id IS NOT NULL;
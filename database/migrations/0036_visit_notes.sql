@@ -0,0 +1,32 @@
+-- Structured (SOAP) notes of a visit, at most one per appointment, written by a clinician.
+-- A note is a draft its author may edit until they sign it; from then on it is locked and
+-- only changed by amendments, each of which keeps the sections it replaced, who made it and
+-- why. The sections are encrypted like the other PHI columns. Appointments with a note
+-- cannot be deleted, so the record of the visit stays.
+CREATE TABLE IF NOT EXISTS visit_notes (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER NOT NULL UNIQUE REFERENCES appointments(id),
+    subjective TEXT,
+    objective TEXT,
+    assessment TEXT,
+    plan TEXT,
+    author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    signed_at TIMESTAMPTZ,
+    amended_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS visit_note_amendments (
+    id SERIAL PRIMARY KEY,
+    visit_note_id INTEGER NOT NULL REFERENCES visit_notes(id),
+    reason TEXT NOT NULL,
+    subjective TEXT,
+    objective TEXT,
+    assessment TEXT,
+    plan TEXT,
+    author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_visit_note_amendments_note ON visit_note_amendments(visit_note_id, created_at);
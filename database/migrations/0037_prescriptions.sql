@@ -0,0 +1,25 @@
+-- Medications prescribed at completed appointments, by the appointment's employee. Each of
+-- the 1 + refills fills lasts duration_days, so a prescription is valid from starts_on until
+-- expires_on, when the expiry job marks it EXPIRED; it can be CANCELLED before then.
+CREATE TABLE IF NOT EXISTS prescriptions (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id),
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    prescriber_id INTEGER NOT NULL REFERENCES employees(id),
+    medication TEXT NOT NULL,
+    dosage TEXT NOT NULL,
+    duration_days INTEGER NOT NULL CHECK (duration_days > 0),
+    refills INTEGER NOT NULL DEFAULT 0 CHECK (refills >= 0),
+    instructions TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'EXPIRED', 'CANCELLED')),
+    starts_on DATE NOT NULL,
+    expires_on DATE NOT NULL,
+    cancelled_at TIMESTAMPTZ,
+    cancellation_reason TEXT,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_prescriptions_patient ON prescriptions(patient_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_prescriptions_appointment ON prescriptions(appointment_id);
+CREATE INDEX IF NOT EXISTS idx_prescriptions_expiry ON prescriptions(expires_on) WHERE status = 'ACTIVE';
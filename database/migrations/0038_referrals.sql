@@ -0,0 +1,27 @@
+-- Referrals of patients from one of the clinics' employees to another employee, clinic or
+-- specialty. A referral is SENT, then ACCEPTED, which puts the patient on the waiting list
+-- (waiting_list_id), or DECLINED; an accepted one is BOOKED once its appointment is. The
+-- timestamps of each step give the turnaround report.
+CREATE TABLE IF NOT EXISTS referrals (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    source_employee_id INTEGER NOT NULL REFERENCES employees(id),
+    target_clinic_id INTEGER REFERENCES clinics(id),
+    target_employee_id INTEGER REFERENCES employees(id),
+    target_specialty VARCHAR(100),
+    referral_reason TEXT NOT NULL,
+    urgency_level urgency_level NOT NULL DEFAULT 'MEDIUM',
+    status VARCHAR(20) NOT NULL DEFAULT 'SENT' CHECK (status IN ('SENT', 'ACCEPTED', 'BOOKED', 'DECLINED')),
+    decline_reason TEXT,
+    waiting_list_id INTEGER REFERENCES waiting_list(id) ON DELETE SET NULL,
+    appointment_id INTEGER REFERENCES appointments(id) ON DELETE SET NULL,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    responded_at TIMESTAMPTZ,
+    booked_at TIMESTAMPTZ,
+    CHECK (target_employee_id IS NOT NULL OR target_specialty IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_referrals_patient ON referrals(patient_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_referrals_status ON referrals(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_referrals_target_employee ON referrals(target_employee_id) WHERE target_employee_id IS NOT NULL;
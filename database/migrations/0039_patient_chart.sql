@@ -0,0 +1,53 @@
+-- The structured part of the patient chart: allergies, medications and conditions, each
+-- coded in a terminology (code_system and code) where possible. HIGH criticality allergies
+-- that are ACTIVE are shown on the patient's appointments.
+CREATE TABLE IF NOT EXISTS patient_allergies (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    substance TEXT NOT NULL,
+    code_system VARCHAR(20) CHECK (code_system IN ('SNOMED_CT', 'ICD_10', 'RXNORM')),
+    code VARCHAR(50),
+    reaction TEXT,
+    criticality VARCHAR(20) NOT NULL DEFAULT 'UNABLE_TO_ASSESS' CHECK (criticality IN ('LOW', 'HIGH', 'UNABLE_TO_ASSESS')),
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'INACTIVE', 'RESOLVED')),
+    onset_date DATE,
+    recorded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((code_system IS NULL) = (code IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS patient_medications (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    name TEXT NOT NULL,
+    code_system VARCHAR(20) CHECK (code_system IN ('SNOMED_CT', 'ICD_10', 'RXNORM')),
+    code VARCHAR(50),
+    dosage TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'STOPPED')),
+    started_on DATE,
+    stopped_on DATE,
+    recorded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((code_system IS NULL) = (code IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS patient_conditions (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    name TEXT NOT NULL,
+    code_system VARCHAR(20) CHECK (code_system IN ('SNOMED_CT', 'ICD_10', 'RXNORM')),
+    code VARCHAR(50),
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'RESOLVED')),
+    onset_date DATE,
+    resolved_on DATE,
+    recorded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK ((code_system IS NULL) = (code IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_patient_allergies_patient ON patient_allergies(patient_id);
+CREATE INDEX IF NOT EXISTS idx_patient_medications_patient ON patient_medications(patient_id);
+CREATE INDEX IF NOT EXISTS idx_patient_conditions_patient ON patient_conditions(patient_id);
@@ -0,0 +1,29 @@
+-- Intake forms. A template is a JSON Schema asked of patients before appointments of the
+-- services and appointment types it lists, at one clinic or at all of them. Patients answer
+-- in the portal before arrival; each response keeps the schema it was answered against, so
+-- later edits of the template leave it readable. Answers are encrypted like the PHI columns.
+CREATE TABLE IF NOT EXISTS form_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    description TEXT,
+    schema JSONB NOT NULL,
+    clinic_id INTEGER REFERENCES clinics(id),
+    service_ids INTEGER[] NOT NULL DEFAULT '{}',
+    appointment_types TEXT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS form_responses (
+    id SERIAL PRIMARY KEY,
+    form_template_id INTEGER NOT NULL REFERENCES form_templates(id),
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    schema JSONB NOT NULL,
+    answers TEXT NOT NULL,
+    submitted_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (form_template_id, appointment_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_form_responses_appointment ON form_responses(appointment_id);
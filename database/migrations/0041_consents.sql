@@ -0,0 +1,31 @@
+-- Patient consent. Consent documents are versioned per kind and immutable; the current
+-- version of a kind is the latest one in effect. A patient's consent records which version
+-- they agreed to, when and how, and stays in the history when withdrawn. Services may
+-- require the current version of some kinds before they can be booked.
+CREATE TABLE IF NOT EXISTS consent_documents (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('PRIVACY_POLICY', 'TREATMENT', 'DATA_SHARING')),
+    version VARCHAR(50) NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    body TEXT NOT NULL,
+    effective_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (kind, version)
+);
+
+CREATE TABLE IF NOT EXISTS patient_consents (
+    id SERIAL PRIMARY KEY,
+    patient_id INTEGER NOT NULL REFERENCES patients(id),
+    consent_document_id INTEGER NOT NULL REFERENCES consent_documents(id),
+    capture_method VARCHAR(20) NOT NULL CHECK (capture_method IN ('PORTAL', 'ONLINE', 'IN_PERSON', 'PAPER', 'VERBAL')),
+    captured_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    recorded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    withdrawn_at TIMESTAMPTZ,
+    withdrawn_by INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_patient_consents_patient ON patient_consents(patient_id);
+CREATE INDEX IF NOT EXISTS idx_patient_consents_document ON patient_consents(consent_document_id) WHERE withdrawn_at IS NULL;
+
+ALTER TABLE services ADD COLUMN IF NOT EXISTS required_consents TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,4 @@
+-- Patients erased on request (GDPR right to erasure) keep their row, so appointments and the
+-- rest of the medical and financial record stay intact, but lose every personal detail.
+-- erased_at marks them so they are never restored or erased again.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS erased_at TIMESTAMPTZ;
@@ -0,0 +1,36 @@
+-- Data retention. Rows of each class past its configured period are moved by the retention
+-- sweep into the class's archive table (as JSON, so later schema changes leave them
+-- readable), to the object store, or deleted; retention_runs reports what each run did.
+CREATE TABLE IF NOT EXISTS appointments_archive (
+    id INTEGER PRIMARY KEY,
+    data JSONB NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notifications_archive (
+    id INTEGER PRIMARY KEY,
+    data JSONB NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS audit_log_archive (
+    id BIGINT PRIMARY KEY,
+    data JSONB NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id SERIAL PRIMARY KEY,
+    data_class VARCHAR(20) NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('archive', 'cold', 'purge')),
+    cutoff TIMESTAMPTZ NOT NULL,
+    row_count INTEGER NOT NULL,
+    first_id BIGINT NOT NULL,
+    last_id BIGINT NOT NULL,
+    locations TEXT[] NOT NULL DEFAULT '{}',
+    ran_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_ran_at ON retention_runs(ran_at);
+CREATE INDEX IF NOT EXISTS idx_appointments_end_datetime ON appointments(end_datetime);
+CREATE INDEX IF NOT EXISTS idx_notifications_created_at ON notifications(created_at);
@@ -0,0 +1,8 @@
+-- Duplicate patients merged into another patient: their appointments and the rest of their
+-- record move to the kept patient, and the duplicate stays soft-deleted with merged_into_id
+-- pointing at it, so it is never restored or merged again.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS merged_into_id INTEGER REFERENCES patients(id);
+
+-- Duplicate detection compares names case-insensitively and phones as stored
+CREATE INDEX IF NOT EXISTS idx_patients_name ON patients(lower(btrim(last_name)), lower(btrim(first_name))) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_patients_phone ON patients(phone) WHERE deleted_at IS NULL AND phone <> '';
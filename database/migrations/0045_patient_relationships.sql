@@ -0,0 +1,20 @@
+-- Guardians and their dependents. A guardian patient can book, cancel and answer forms for
+-- their dependents in the portal and when booking online: parents and legal guardians until
+-- the dependent turns 18, caregivers of adults with no age limit. Services can be limited to
+-- patients of an age, e.g. pediatric services to those under 18, checked on the day of the
+-- appointment.
+CREATE TABLE IF NOT EXISTS patient_relationships (
+    id SERIAL PRIMARY KEY,
+    guardian_id INTEGER NOT NULL REFERENCES patients(id),
+    dependent_id INTEGER NOT NULL REFERENCES patients(id),
+    relationship VARCHAR(20) NOT NULL CHECK (relationship IN ('PARENT', 'LEGAL_GUARDIAN', 'CAREGIVER')),
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (guardian_id, dependent_id),
+    CHECK (guardian_id <> dependent_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_patient_relationships_dependent ON patient_relationships(dependent_id);
+
+ALTER TABLE services ADD COLUMN IF NOT EXISTS min_age_years INTEGER CHECK (min_age_years >= 0);
+ALTER TABLE services ADD COLUMN IF NOT EXISTS max_age_years INTEGER CHECK (max_age_years >= 0);
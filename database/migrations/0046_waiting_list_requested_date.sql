@@ -0,0 +1,19 @@
+-- A waiting list entry's requested date was free text, so it could not be compared or
+-- searched as a date. It becomes a DATE; values that are not a valid YYYY-MM-DD date are
+-- dropped, leaving those entries open to any day. Patients' dates of birth stay TEXT: they are
+-- encrypted by the application (see 0009), which checks them as dates instead.
+CREATE OR REPLACE FUNCTION pg_temp.requested_date_or_null(value TEXT) RETURNS DATE AS $$
+BEGIN
+    IF value !~ '^\d{4}-\d{2}-\d{2}$' THEN
+        RETURN NULL;
+    END IF;
+    RETURN value::date;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE waiting_list ALTER COLUMN requested_date TYPE DATE
+    USING pg_temp.requested_date_or_null(requested_date);
+
+CREATE INDEX IF NOT EXISTS idx_waiting_list_requested_date ON waiting_list(requested_date) WHERE requested_date IS NOT NULL;
@@ -0,0 +1,7 @@
+-- Phone numbers are stored in E.164 so SMS providers accept them and duplicate patients are
+-- found by phone whatever way the number was typed. The number as it was entered, e.g.
+-- "(415) 555-2671", is kept alongside for showing to people. Numbers stored before this
+-- migration were already checked as E.164 and have no separate display form.
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS phone_display TEXT;
+ALTER TABLE employees ADD COLUMN IF NOT EXISTS phone_display TEXT;
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS phone_display TEXT;
@@ -0,0 +1,9 @@
+-- Patients created without a medical record number get one made up of a prefix, the next
+-- value of patient_mrn_seq and a check digit. The prefix is the registering clinic's
+-- mrn_prefix, or the deployment-wide MRN_PREFIX for clinics without one. Numbers are
+-- encrypted, so the sequence rather than the stored values says which one comes next, and
+-- uniqueness is still enforced on medical_record_number_index.
+CREATE SEQUENCE IF NOT EXISTS patient_mrn_seq;
+
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS mrn_prefix VARCHAR(10)
+    CHECK (mrn_prefix ~ '^[A-Z][A-Z0-9]*$');
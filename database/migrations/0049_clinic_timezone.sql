@@ -0,0 +1,4 @@
+-- A clinic's timezone is the one its day is counted in for reports and schedules, and the one
+-- employees added to it work in unless they are given their own. Clinics without one keep
+-- using the timezone most of their employees work in.
+ALTER TABLE clinics ADD COLUMN IF NOT EXISTS timezone TEXT;
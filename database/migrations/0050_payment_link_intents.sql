@@ -0,0 +1,3 @@
+-- A payment link is paid through a Stripe PaymentIntent of its own; the signed webhook for that
+-- intent is what settles the link.
+ALTER TABLE payment_links ADD COLUMN IF NOT EXISTS payment_intent_id TEXT UNIQUE;
@@ -0,0 +1,132 @@
+-- Every record belongs to an organization, and row-level security keeps each database session
+-- to the records of the one it works for: the server sets app.organization_id on every
+-- connection it hands out, and rows of other organizations can be neither read nor written.
+-- A session that sets nothing works for the default organization. Migrations set
+-- app.all_organizations so data changes they make reach every organization's rows.
+--
+-- The policies are forced on the table owner too, but superusers and roles with BYPASSRLS
+-- skip them, so the server must connect as an ordinary role to isolate organizations.
+CREATE OR REPLACE FUNCTION current_organization_id() RETURNS INTEGER AS $$
+    SELECT COALESCE(NULLIF(current_setting('app.organization_id', true), '')::INTEGER, 1)
+$$ LANGUAGE sql STABLE;
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'clinics', 'patients', 'employees', 'services', 'employee_services', 'work_templates',
+        'day_overrides', 'time_off', 'slot_holds', 'appointments', 'waiting_list', 'payment_links',
+        'payment_link_items', 'audit_log', 'users', 'refresh_tokens', 'appointment_reschedules',
+        'sent_reminders', 'calendar_feeds', 'access_log', 'card_payments', 'stripe_events',
+        'appointment_series', 'clinic_hours', 'clinic_holidays', 'resources', 'service_resources',
+        'appointment_resources', 'webhook_subscriptions', 'webhook_events', 'webhook_deliveries',
+        'webhook_delivery_attempts', 'event_outbox', 'waiting_room_displays',
+        'notification_preferences', 'notifications', 'hl7_messages', 'api_keys', 'clinic_widgets',
+        'patient_documents', 'visit_notes', 'visit_note_amendments', 'prescriptions', 'referrals',
+        'patient_allergies', 'patient_medications', 'patient_conditions', 'form_templates',
+        'form_responses', 'consent_documents', 'patient_consents', 'appointments_archive',
+        'notifications_archive', 'audit_log_archive', 'retention_runs', 'patient_relationships'
+    ] LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id)', t);
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN organization_id SET DEFAULT current_organization_id()', t);
+        EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON %I (organization_id)', 'idx_' || t || '_organization', t);
+    END LOOP;
+END $$;
+
+-- Until now only clinics, users and API keys had an organization. What hangs off them follows
+-- them; patients and services were shared and stay in the default organization.
+UPDATE employees e SET organization_id = c.organization_id FROM clinics c WHERE c.id = e.clinic_id;
+UPDATE appointments a SET organization_id = c.organization_id FROM clinics c WHERE c.id = a.clinic_id;
+UPDATE appointment_series s SET organization_id = c.organization_id FROM clinics c WHERE c.id = s.clinic_id;
+UPDATE clinic_hours h SET organization_id = c.organization_id FROM clinics c WHERE c.id = h.clinic_id;
+UPDATE clinic_holidays h SET organization_id = c.organization_id FROM clinics c WHERE c.id = h.clinic_id;
+UPDATE resources r SET organization_id = c.organization_id FROM clinics c WHERE c.id = r.clinic_id;
+UPDATE waiting_room_displays d SET organization_id = c.organization_id FROM clinics c WHERE c.id = d.clinic_id;
+UPDATE clinic_widgets w SET organization_id = c.organization_id FROM clinics c WHERE c.id = w.clinic_id;
+UPDATE form_templates f SET organization_id = c.organization_id FROM clinics c WHERE c.id = f.clinic_id;
+UPDATE work_templates w SET organization_id = e.organization_id FROM employees e WHERE e.id = w.employee_id;
+UPDATE day_overrides d SET organization_id = e.organization_id FROM employees e WHERE e.id = d.employee_id;
+UPDATE time_off t SET organization_id = e.organization_id FROM employees e WHERE e.id = t.employee_id;
+UPDATE employee_services s SET organization_id = e.organization_id FROM employees e WHERE e.id = s.employee_id;
+UPDATE slot_holds h SET organization_id = e.organization_id FROM employees e WHERE e.id = h.employee_id;
+UPDATE appointment_reschedules r SET organization_id = a.organization_id FROM appointments a WHERE a.id = r.appointment_id;
+UPDATE sent_reminders r SET organization_id = a.organization_id FROM appointments a WHERE a.id = r.appointment_id;
+UPDATE card_payments p SET organization_id = a.organization_id FROM appointments a WHERE a.id = p.appointment_id;
+UPDATE appointment_resources r SET organization_id = a.organization_id FROM appointments a WHERE a.id = r.appointment_id;
+UPDATE visit_notes n SET organization_id = a.organization_id FROM appointments a WHERE a.id = n.appointment_id;
+UPDATE refresh_tokens t SET organization_id = u.organization_id FROM users u WHERE u.id = t.user_id;
+
+-- Names, emails and numbers only have to be unique within an organization
+DROP INDEX IF EXISTS clinics_name_active_key;
+CREATE UNIQUE INDEX IF NOT EXISTS clinics_name_active_key ON clinics (organization_id, name) WHERE deleted_at IS NULL;
+DROP INDEX IF EXISTS patients_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS patients_email_key ON patients (organization_id, email) WHERE email <> '';
+DROP INDEX IF EXISTS patients_medical_record_number_index_key;
+CREATE UNIQUE INDEX IF NOT EXISTS patients_medical_record_number_index_key ON patients (organization_id, medical_record_number_index);
+ALTER TABLE employees DROP CONSTRAINT IF EXISTS employees_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS employees_email_key ON employees (organization_id, email);
+ALTER TABLE employees DROP CONSTRAINT IF EXISTS employees_license_number_key;
+CREATE UNIQUE INDEX IF NOT EXISTS employees_license_number_key ON employees (organization_id, license_number);
+ALTER TABLE services DROP CONSTRAINT IF EXISTS services_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS services_name_key ON services (organization_id, name);
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_key ON users (organization_id, email);
+ALTER TABLE consent_documents DROP CONSTRAINT IF EXISTS consent_documents_kind_version_key;
+CREATE UNIQUE INDEX IF NOT EXISTS consent_documents_kind_version_key ON consent_documents (organization_id, kind, version);
+ALTER TABLE clinic_widgets DROP CONSTRAINT IF EXISTS clinic_widgets_slug_key;
+CREATE UNIQUE INDEX IF NOT EXISTS clinic_widgets_slug_key ON clinic_widgets (organization_id, slug);
+-- Stripe sends every event to each organization's webhook endpoint, and each applies it once
+ALTER TABLE stripe_events DROP CONSTRAINT IF EXISTS stripe_events_pkey;
+ALTER TABLE stripe_events ADD PRIMARY KEY (organization_id, event_id);
+
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOR t IN SELECT c.relname FROM pg_class c
+        JOIN pg_attribute a ON a.attrelid = c.oid AND a.attname = 'organization_id' AND NOT a.attisdropped
+        WHERE c.relkind = 'r' AND c.relnamespace = current_schema()::regnamespace
+    LOOP
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+        EXECUTE format('DROP POLICY IF EXISTS organization_isolation ON %I', t);
+        EXECUTE format($p$CREATE POLICY organization_isolation ON %I
+            USING (organization_id = current_organization_id() OR current_setting('app.all_organizations', true) = 'on')
+            WITH CHECK (organization_id = current_organization_id() OR current_setting('app.all_organizations', true) = 'on')$p$, t);
+    END LOOP;
+END $$;
+
+-- Live schedule subscribers only get the changes of their own organization
+CREATE OR REPLACE FUNCTION notify_schedule_change() RETURNS trigger AS $$
+DECLARE
+    appt appointments;
+    change TEXT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        appt := OLD;
+        change := 'deleted';
+    ELSE
+        appt := NEW;
+        IF TG_OP = 'INSERT' THEN
+            change := 'created';
+        ELSIF NEW.status = 'CANCELLED' AND OLD.status IS DISTINCT FROM 'CANCELLED' THEN
+            change := 'cancelled';
+        ELSE
+            change := 'updated';
+        END IF;
+    END IF;
+    PERFORM pg_notify('schedule_changes', json_build_object(
+        'type', 'appointment.' || change,
+        'organization_id', appt.organization_id,
+        'appointment_id', appt.id,
+        'clinic_id', appt.clinic_id,
+        'employee_id', appt.employee_id,
+        'patient_id', appt.patient_id,
+        'status', appt.status,
+        'start_datetime', appt.start_datetime,
+        'end_datetime', appt.end_datetime
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,6 @@
+-- The reminder sweep also sends the escalation to the clinic of an appointment still
+-- unconfirmed EscalationLeadTime before it starts. Claims record which kind of notification
+-- went out, so an escalation and a reminder due at the same time are each sent once.
+ALTER TABLE sent_reminders ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'REMINDER';
+ALTER TABLE sent_reminders DROP CONSTRAINT IF EXISTS sent_reminders_pkey;
+ALTER TABLE sent_reminders ADD PRIMARY KEY (appointment_id, kind, channel, scheduled_at);
@@ -0,0 +1,49 @@
+-- Phone numbers stored before 0047 only had to look like E.164 (a plus and 7 to 15 digits),
+-- and the oldest rows were not checked at all. Numbers that are E.164 once spaces, dashes,
+-- dots, slashes and parentheses are taken out, or once a leading 00 is read as the plus, are
+-- rewritten in E.164 like phone.Normalize does. Numbers that cannot be read that way (national
+-- numbers, too short or too long, North American numbers without 10 digits) are cleared, so
+-- saving the record again does not fail on a number nobody changed; the number as it was
+-- stored stays in phone_display for staff to correct. Emergency contact numbers have no display
+-- column and are set to NULL. Each table's counts are reported as notices.
+CREATE FUNCTION pg_temp.e164(number TEXT) RETURNS TEXT LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE WHEN n ~ '^\+[1-9][0-9]{7,14}$' AND (n !~ '^\+1' OR length(n) = 12) THEN n END
+    FROM (SELECT regexp_replace(regexp_replace(btrim(number), '[ ./()-]', '', 'g'), '^00', '+') AS n) cleaned
+$$;
+
+DO $$
+DECLARE
+    t TEXT;
+    normalized BIGINT;
+    cleared BIGINT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['patients', 'employees', 'clinics'] LOOP
+        EXECUTE format(
+            'UPDATE %I SET phone_display = COALESCE(phone_display, phone), phone = pg_temp.e164(phone)
+            WHERE phone <> '''' AND pg_temp.e164(phone) IS NOT NULL AND pg_temp.e164(phone) <> phone', t);
+        GET DIAGNOSTICS normalized = ROW_COUNT;
+        EXECUTE format(
+            'UPDATE %I SET phone_display = COALESCE(phone_display, phone), phone = ''''
+            WHERE phone <> '''' AND pg_temp.e164(phone) IS NULL', t);
+        GET DIAGNOSTICS cleared = ROW_COUNT;
+        IF normalized > 0 OR cleared > 0 THEN
+            RAISE NOTICE '%: % phone numbers rewritten in E.164, % unreadable ones cleared and kept in phone_display',
+                t, normalized, cleared;
+        END IF;
+    END LOOP;
+
+    UPDATE patients SET emergency_contact_phone = pg_temp.e164(emergency_contact_phone)
+    WHERE emergency_contact_phone <> '' AND pg_temp.e164(emergency_contact_phone) IS NOT NULL
+        AND pg_temp.e164(emergency_contact_phone) <> emergency_contact_phone;
+    GET DIAGNOSTICS normalized = ROW_COUNT;
+    UPDATE patients SET emergency_contact_phone = NULL
+    WHERE emergency_contact_phone IS NOT NULL AND pg_temp.e164(emergency_contact_phone) IS NULL;
+    GET DIAGNOSTICS cleared = ROW_COUNT;
+    IF normalized > 0 OR cleared > 0 THEN
+        RAISE NOTICE 'patients: % emergency contact numbers rewritten in E.164, % unreadable ones set to NULL',
+            normalized, cleared;
+    END IF;
+END
+$$;
+
+DROP FUNCTION pg_temp.e164(TEXT);
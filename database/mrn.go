@@ -0,0 +1,207 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DefaultMRNPrefix starts generated medical record numbers when neither the clinic nor
+// MRN_PREFIX sets a prefix
+const DefaultMRNPrefix = "MRN"
+
+// mrnAttempts bounds how many generated numbers are tried for one patient when the ones
+// drawn are already taken, e.g. by numbers entered by hand or imported
+const mrnAttempts = 5
+
+// mrnIndexConstraint is the unique index medical record numbers are enforced by
+const mrnIndexConstraint = "patients_medical_record_number_index_key"
+
+var mrnPrefixPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{0,9}$`)
+
+// ErrMRNTaken is returned when a patient is given a medical record number another patient
+// already has
+var ErrMRNTaken = errors.New("medical record number already belongs to a patient")
+
+// ValidMRNPrefix reports whether prefix can start generated medical record numbers: up to
+// 10 upper-case letters and digits, starting with a letter
+func ValidMRNPrefix(prefix string) bool {
+	return mrnPrefixPattern.MatchString(prefix)
+}
+
+// MRNPrefix reads the prefix of generated medical record numbers from MRN_PREFIX
+func MRNPrefix() (string, error) {
+	raw := os.Getenv("MRN_PREFIX")
+	if raw == "" {
+		return DefaultMRNPrefix, nil
+	}
+	if !ValidMRNPrefix(raw) {
+		return "", fmt.Errorf("invalid MRN_PREFIX %q", raw)
+	}
+	return raw, nil
+}
+
+// FormatMRN writes a generated medical record number: the prefix, the sequence number in at
+// least seven digits and a Luhn check digit over those digits, so a mistyped number is
+// almost always noticed
+func FormatMRN(prefix string, n int64) string {
+	digits := fmt.Sprintf("%07d", n)
+	return prefix + digits + strconv.Itoa(luhnCheckDigit(digits))
+}
+
+// luhnCheckDigit is the digit that makes digits followed by it pass the Luhn check
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// Counting from the check digit, every second digit is doubled
+		if (len(digits)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+// mrnPrefixFor is the prefix of numbers generated for patients registered at the clinic:
+// its own mrn_prefix, else MRN_PREFIX. clinicID may be nil.
+func mrnPrefixFor(ctx context.Context, clinicID *int) (string, error) {
+	if clinicID != nil {
+		var prefix *string
+		err := conn(ctx).QueryRow(ctx, "SELECT mrn_prefix FROM clinics WHERE id = $1", *clinicID).Scan(&prefix)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return "", err
+		}
+		if prefix != nil {
+			return *prefix, nil
+		}
+	}
+	return MRNPrefix()
+}
+
+// nextMRNs draws n generated medical record numbers with the given prefix
+func nextMRNs(ctx context.Context, prefix string, n int) ([]string, error) {
+	rows, err := conn(ctx).Query(ctx, "SELECT nextval('patient_mrn_seq') FROM generate_series(1, $1)", n)
+	if err != nil {
+		return nil, err
+	}
+	values, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, err
+	}
+	mrns := make([]string, len(values))
+	for i, value := range values {
+		mrns[i] = FormatMRN(prefix, value)
+	}
+	return mrns, nil
+}
+
+// createWithGeneratedMRN inserts a patient that came without a medical record number under a
+// generated one. Each attempt runs in its own savepoint, so when the number drawn is already
+// taken the insert is tried again with the next one rather than failing the caller's
+// transaction.
+func createWithGeneratedMRN(ctx context.Context, patient *models.Patient) error {
+	prefix, err := mrnPrefixFor(ctx, patient.ClinicID)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		mrns, err := nextMRNs(ctx, prefix, 1)
+		if err != nil {
+			return err
+		}
+		patient.MedicalRecordNumber = mrns[0]
+		err = WithTx(ctx, func(ctx context.Context) error {
+			return insertPatient(ctx, patient)
+		})
+		if !errors.Is(err, ErrMRNTaken) || attempt == mrnAttempts {
+			if err != nil {
+				patient.MedicalRecordNumber = ""
+			}
+			return err
+		}
+	}
+}
+
+// generateMRNs gives the patients without a medical record number generated ones, skipping
+// numbers that are already taken. The numbers are drawn together for CreatePatients.
+func generateMRNs(ctx context.Context, patients []models.Patient) error {
+	byPrefix := map[string][]*models.Patient{}
+	var prefixes []string
+	for i := range patients {
+		patient := &patients[i]
+		if patient.MedicalRecordNumber != "" {
+			continue
+		}
+		prefix, err := mrnPrefixFor(ctx, patient.ClinicID)
+		if err != nil {
+			return err
+		}
+		if _, ok := byPrefix[prefix]; !ok {
+			prefixes = append(prefixes, prefix)
+		}
+		byPrefix[prefix] = append(byPrefix[prefix], patient)
+	}
+	for _, prefix := range prefixes {
+		pending := byPrefix[prefix]
+		for attempt := 1; len(pending) > 0; attempt++ {
+			if attempt > mrnAttempts {
+				return ErrMRNTaken
+			}
+			mrns, err := nextMRNs(ctx, prefix, len(pending))
+			if err != nil {
+				return err
+			}
+			_, taken, err := TakenPatientKeys(ctx, nil, mrns)
+			if err != nil {
+				return err
+			}
+			var retry []*models.Patient
+			for i, patient := range pending {
+				if taken[mrns[i]] {
+					retry = append(retry, patient)
+					continue
+				}
+				patient.MedicalRecordNumber = mrns[i]
+			}
+			pending = retry
+		}
+	}
+	return nil
+}
+
+// mrnWriteError maps a violation of the medical record number's unique index to ErrMRNTaken
+func mrnWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == mrnIndexConstraint {
+		return ErrMRNTaken
+	}
+	return err
+}
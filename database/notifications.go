@@ -0,0 +1,179 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"bookings/models"
+	"bookings/phi"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const notificationColumns = "id, appointment_id, patient_id, channel, recipient, template, subject, status, attempts, next_attempt_at, provider_message_id, last_error, sent_at, created_at"
+
+func scanNotification(row pgx.Row, n *models.Notification) error {
+	if err := row.Scan(&n.ID, &n.AppointmentID, &n.PatientID, &n.Channel, &n.Recipient, &n.Template, &n.Subject, &n.Status,
+		&n.Attempts, &n.NextAttemptAt, &n.ProviderMessageID, &n.LastError, &n.SentAt, &n.CreatedAt); err != nil {
+		return err
+	}
+	// Only a pending notification has another attempt coming
+	if n.Status != "PENDING" {
+		n.NextAttemptAt = nil
+	}
+	return nil
+}
+
+// CreateNotification logs a message about to be sent as PENDING, keeping payload (the
+// message, encrypted) for retries. Its first attempt is leased until leaseUntil, so the
+// retry job leaves it alone while it is being sent.
+func CreateNotification(ctx context.Context, n *models.Notification, payload string, leaseUntil time.Time) error {
+	sealed, err := phi.Encrypt(payload)
+	if err != nil {
+		return err
+	}
+	return scanNotification(conn(ctx).QueryRow(ctx,
+		`INSERT INTO notifications (appointment_id, patient_id, channel, recipient, template, subject, next_attempt_at, payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING `+notificationColumns,
+		n.AppointmentID, n.PatientID, n.Channel, n.Recipient, n.Template, n.Subject, leaseUntil.UTC(), sealed), n)
+}
+
+// RecordNotificationAttempt moves a notification on after an attempt at attemptedAt: SENT
+// with the provider's message id when errMessage is nil, PENDING until retryAt when it failed
+// and is to be retried, or FAILED when it failed and retryAt is nil. The message is dropped
+// once it is sent.
+func RecordNotificationAttempt(ctx context.Context, id int, attemptedAt time.Time, providerMessageID, errMessage *string, retryAt *time.Time) error {
+	status := "FAILED"
+	var sentAt *time.Time
+	nextAttemptAt := attemptedAt
+	switch {
+	case errMessage == nil:
+		status, sentAt = "SENT", &attemptedAt
+	case retryAt != nil:
+		status, nextAttemptAt = "PENDING", *retryAt
+	}
+	_, err := conn(ctx).Exec(ctx,
+		`UPDATE notifications SET status = $2, attempts = attempts + 1, next_attempt_at = $3,
+			provider_message_id = $4, last_error = $5, sent_at = $6,
+			payload = CASE WHEN $2 = 'SENT' THEN NULL ELSE payload END
+		WHERE id = $1`,
+		id, status, nextAttemptAt.UTC(), providerMessageID, errMessage, sentAt)
+	return err
+}
+
+// NotificationFilter narrows GetNotifications; nil fields match everything
+type NotificationFilter struct {
+	AppointmentID *int
+	PatientID     *int
+	Status        *string
+}
+
+// notificationFilterWhere applies a NotificationFilter passed as $1-$3
+const notificationFilterWhere = ` WHERE ($1::int IS NULL OR appointment_id = $1)
+	AND ($2::int IS NULL OR patient_id = $2)
+	AND ($3::text IS NULL OR status = $3)`
+
+// GetNotifications returns one page of the delivery log entries matching the filter, latest
+// first, with the total number that match
+func GetNotifications(ctx context.Context, filter NotificationFilter, page Page) ([]models.Notification, int, error) {
+	args := []any{filter.AppointmentID, filter.PatientID, filter.Status}
+	total, err := count(ctx, "SELECT COUNT(*) FROM notifications"+notificationFilterWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+notificationColumns+" FROM notifications"+notificationFilterWhere+" ORDER BY created_at DESC, id DESC LIMIT $4 OFFSET $5",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, 0, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, total, rows.Err()
+}
+
+// GetNotification returns one delivery log entry
+func GetNotification(ctx context.Context, id int) (*models.Notification, error) {
+	var n models.Notification
+	err := scanNotification(conn(ctx).QueryRow(ctx, "SELECT "+notificationColumns+" FROM notifications WHERE id = $1", id), &n)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// RetryNotification queues a FAILED notification again with a fresh set of attempts,
+// reporting whether it was FAILED
+func RetryNotification(ctx context.Context, id int) (bool, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		"UPDATE notifications SET status = 'PENDING', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'FAILED' AND payload IS NOT NULL",
+		id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DueNotification is a notification claimed for another attempt, with the message to send
+type DueNotification struct {
+	ID int
+	// Attempts is the number of attempts made before this one
+	Attempts int
+	Payload  string
+}
+
+// ClaimNotifications claims up to limit PENDING notifications due at now, moving their next
+// attempt to leaseUntil so no other worker picks them up while they are being sent
+func ClaimNotifications(ctx context.Context, now, leaseUntil time.Time, limit int) ([]DueNotification, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`WITH due AS (
+			SELECT id FROM notifications
+			WHERE status = 'PENDING' AND next_attempt_at <= $1 AND payload IS NOT NULL
+			ORDER BY next_attempt_at, id LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE notifications n SET next_attempt_at = $2 FROM due WHERE n.id = due.id
+		RETURNING n.id, n.attempts, n.payload`,
+		now.UTC(), leaseUntil.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DueNotification
+	for rows.Next() {
+		var d DueNotification
+		if err := rows.Scan(&d.ID, &d.Attempts, &d.Payload); err != nil {
+			return nil, err
+		}
+		if d.Payload, err = phi.Decrypt(d.Payload); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
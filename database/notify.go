@@ -0,0 +1,49 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+)
+
+// ScheduleChannel is the NOTIFY channel every appointment change is announced on
+const ScheduleChannel = "schedule_changes"
+
+// ListenScheduleChanges holds a pool connection listening on ScheduleChannel and hands each
+// notification's JSON payload to handle, until ctx is done or the connection fails. ready
+// is called once listening has started, so nothing committed after it is missed.
+func ListenScheduleChanges(ctx context.Context, ready func(), handle func(payload []byte)) error {
+	pooled, err := DB.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A connection that was listening must not go back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+ScheduleChannel); err != nil {
+		return err
+	}
+	ready()
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handle([]byte(notification.Payload))
+	}
+}
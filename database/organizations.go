@@ -0,0 +1,101 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type organizationContextKey struct{}
+
+// WithOrganization returns a context whose database calls work for an organization: every
+// row they read or write belongs to it, which row-level security enforces
+func WithOrganization(ctx context.Context, organizationID int) context.Context {
+	return context.WithValue(ctx, organizationContextKey{}, organizationID)
+}
+
+// OrganizationFromContext returns the organization database calls made with ctx work for,
+// the default one when ctx names none
+func OrganizationFromContext(ctx context.Context) int {
+	if id, ok := ctx.Value(organizationContextKey{}).(int); ok && id != 0 {
+		return id
+	}
+	return DefaultOrganizationID
+}
+
+// sessionOrganizations remembers the app.organization_id each pooled connection was last set
+// to, so it is only set again when a connection is handed to another organization
+var sessionOrganizations sync.Map
+
+// prepareConn sets app.organization_id on a connection taken from the pool to the
+// organization of the context it is taken for. Transactions begun on the connection keep it.
+func prepareConn(ctx context.Context, conn *pgx.Conn) (bool, error) {
+	id := OrganizationFromContext(ctx)
+	if last, ok := sessionOrganizations.Load(conn); ok && last.(int) == id {
+		return true, nil
+	}
+	if _, err := conn.Exec(ctx, "SELECT set_config('app.organization_id', $1, false)", strconv.Itoa(id)); err != nil {
+		sessionOrganizations.Delete(conn)
+		return false, err
+	}
+	sessionOrganizations.Store(conn, id)
+	return true, nil
+}
+
+// forgetConn drops what prepareConn remembered about a connection the pool closes
+func forgetConn(conn *pgx.Conn) {
+	sessionOrganizations.Delete(conn)
+}
+
+// Organization operations. Organizations are not themselves scoped to one.
+
+const organizationColumns = "id, name, slug, created_at"
+
+func GetOrganization(ctx context.Context, id int) (*models.Organization, error) {
+	var org models.Organization
+	err := conn(ctx).QueryRow(ctx, "SELECT "+organizationColumns+" FROM organizations WHERE id = $1", id).
+		Scan(&org.ID, &org.Name, &org.Slug, &org.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func GetOrganizationBySlug(ctx context.Context, slug string) (*models.Organization, error) {
+	var org models.Organization
+	err := conn(ctx).QueryRow(ctx, "SELECT "+organizationColumns+" FROM organizations WHERE slug = $1", slug).
+		Scan(&org.ID, &org.Name, &org.Slug, &org.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetOrganizationIDs lists every organization's id, for work done for each of them in turn
+func GetOrganizationIDs(ctx context.Context) ([]int, error) {
+	rows, err := conn(ctx).Query(ctx, "SELECT id FROM organizations ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[int])
+}
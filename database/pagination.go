@@ -0,0 +1,40 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import "context"
+
+// Page selects a window of a list query. The zero Page returns every row.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// limit is the LIMIT argument; NULL means no limit in PostgreSQL
+func (p Page) limit() *int {
+	if p.Limit <= 0 {
+		return nil
+	}
+	return &p.Limit
+}
+
+// count runs a SELECT COUNT(*) query
+func count(ctx context.Context, query string, args ...any) (int, error) {
+	var n int
+	err := conn(ctx).QueryRow(ctx, query, args...).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,230 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Erasure errors
+var (
+	ErrPatientErased = errors.New("patient has already been erased")
+	// ErrPatientHasUpcoming is returned when erasing a patient who still has appointments to attend
+	ErrPatientHasUpcoming = errors.New("patient has upcoming appointments")
+)
+
+// PatientData is everything stored about a patient, as handed to them on request (GDPR
+// right of access). Notifications are listed without their messages, which are only kept
+// until sent.
+type PatientData struct {
+	Patient                 *models.Patient                 `json:"patient"`
+	NotificationPreferences *models.NotificationPreferences `json:"notification_preferences"`
+	Appointments            []models.Appointment            `json:"appointments"`
+	VisitNotes              []VisitNoteWithAmendments       `json:"visit_notes"`
+	Prescriptions           []models.Prescription           `json:"prescriptions"`
+	Referrals               []models.Referral               `json:"referrals"`
+	Allergies               []models.Allergy                `json:"allergies"`
+	Medications             []models.Medication             `json:"medications"`
+	Conditions              []models.Condition              `json:"conditions"`
+	FormResponses           []models.FormResponse           `json:"form_responses"`
+	Consents                []models.PatientConsent         `json:"consents"`
+	Relationships           []models.PatientRelationship    `json:"relationships"`
+	Documents               []models.Document               `json:"documents"`
+	WaitingList             []models.WaitingList            `json:"waiting_list"`
+	Notifications           []models.Notification           `json:"notifications"`
+}
+
+// VisitNoteWithAmendments is a visit note and the amendments made to it since it was signed
+type VisitNoteWithAmendments struct {
+	models.VisitNote
+	Amendments []models.VisitNoteAmendment `json:"amendments"`
+}
+
+// GetPatientData gathers everything stored about a patient; pgx.ErrNoRows means there is no
+// such patient
+func GetPatientData(ctx context.Context, patientID int) (*PatientData, error) {
+	var data PatientData
+	var err error
+	if data.Patient, err = GetPatient(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.NotificationPreferences, err = GetNotificationPreferences(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.Appointments, err = GetPatientAppointments(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.VisitNotes, err = getPatientVisitNotes(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.Prescriptions, _, err = GetPrescriptions(ctx, PrescriptionFilter{PatientID: &patientID}, Page{}); err != nil {
+		return nil, err
+	}
+	if data.Referrals, _, err = GetReferrals(ctx, ReferralFilter{PatientID: &patientID}, Page{}); err != nil {
+		return nil, err
+	}
+	if data.Allergies, err = ListAllergies(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.Medications, err = ListMedications(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.Conditions, err = ListConditions(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.FormResponses, err = getPatientFormResponses(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.Consents, err = GetPatientConsents(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.Relationships, err = GetPatientRelationships(ctx, patientID); err != nil {
+		return nil, err
+	}
+	if data.Documents, _, err = GetDocuments(ctx, patientID, DocumentFilter{}, Page{}); err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+waitingListColumns+" FROM waiting_list WHERE patient_id = $1 ORDER BY created_at DESC, id DESC", patientID)
+	if err != nil {
+		return nil, err
+	}
+	if data.WaitingList, err = collectWaitingList(rows); err != nil {
+		return nil, err
+	}
+	if data.Notifications, _, err = GetNotifications(ctx, NotificationFilter{PatientID: &patientID}, Page{}); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// getPatientVisitNotes returns the visit notes of a patient's appointments, latest first,
+// each with its amendments
+func getPatientVisitNotes(ctx context.Context, patientID int) ([]VisitNoteWithAmendments, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+visitNoteColumns+" FROM visit_notes WHERE appointment_id IN (SELECT id FROM appointments WHERE patient_id = $1) ORDER BY created_at DESC, id DESC",
+		patientID)
+	if err != nil {
+		return nil, err
+	}
+	notes := []VisitNoteWithAmendments{}
+	for rows.Next() {
+		var note VisitNoteWithAmendments
+		if err := scanVisitNote(rows, &note.VisitNote); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range notes {
+		if notes[i].Amendments, err = GetVisitNoteAmendments(ctx, notes[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return notes, nil
+}
+
+// getPatientFormResponses returns a patient's intake form answers, latest first
+func getPatientFormResponses(ctx context.Context, patientID int) ([]models.FormResponse, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+formResponseColumns+" FROM form_responses WHERE patient_id = $1 ORDER BY submitted_at DESC, id DESC", patientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	responses := []models.FormResponse{}
+	for rows.Next() {
+		var r models.FormResponse
+		if err := scanFormResponse(rows, &r); err != nil {
+			return nil, err
+		}
+		responses = append(responses, r)
+	}
+	return responses, rows.Err()
+}
+
+// ErasePatient erases a patient's personal details on request (GDPR right to erasure),
+// keeping the record of their care: appointments, visit notes, prescriptions, referrals,
+// the chart, form answers, consents, documents and payments stay, attached to a patient
+// now named "Erased Patient" with no contact, identity or insurance details. The patient is
+// soft-deleted and deactivated; their portal login, notification preferences, calendar feed
+// and slot holds are deleted, their waiting list entries expired and stripped of notes,
+// pending payment links cancelled and the addresses notifications went to cleared.
+//
+// It returns the id of the deleted login, if there was one. pgx.ErrNoRows means there is no
+// such patient, ErrPatientErased that they were erased before and ErrPatientHasUpcoming that
+// appointments of theirs are still scheduled, confirmed or in progress and have to be
+// cancelled or completed first.
+func ErasePatient(ctx context.Context, patientID int, at time.Time) (userID *int, err error) {
+	err = WithTx(ctx, func(ctx context.Context) error {
+		var erased, upcoming bool
+		if err := conn(ctx).QueryRow(ctx,
+			`SELECT erased_at IS NOT NULL, EXISTS (SELECT 1 FROM appointments WHERE patient_id = p.id
+				AND status IN ('SCHEDULED', 'CONFIRMED', 'IN_PROGRESS') AND end_datetime > $2)
+			FROM patients p WHERE id = $1 FOR UPDATE`, patientID, at).Scan(&erased, &upcoming); err != nil {
+			return err
+		}
+		if erased {
+			return ErrPatientErased
+		}
+		if upcoming {
+			return ErrPatientHasUpcoming
+		}
+
+		if _, err := conn(ctx).Exec(ctx,
+			`UPDATE patients SET first_name = 'Erased', last_name = 'Patient', email = '', phone = '', phone_display = NULL, date_of_birth = NULL,
+				medical_record_number = '', medical_record_number_index = NULL, insurance_provider = NULL, insurance_id = NULL,
+				emergency_contact_name = NULL, emergency_contact_phone = NULL, active = FALSE,
+				deleted_at = COALESCE(deleted_at, $2), erased_at = $2, version = version + 1
+			WHERE id = $1`, patientID, at); err != nil {
+			return err
+		}
+		var id int
+		err := conn(ctx).QueryRow(ctx, "DELETE FROM users WHERE patient_id = $1 RETURNING id", patientID).Scan(&id)
+		if err == nil {
+			userID = &id
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		for _, statement := range []string{
+			"DELETE FROM notification_preferences WHERE patient_id = $1",
+			"DELETE FROM calendar_feeds WHERE owner_type = 'PATIENT' AND owner_id = $1",
+			"DELETE FROM slot_holds WHERE patient_id = $1",
+			"DELETE FROM patient_relationships WHERE $1 IN (guardian_id, dependent_id)",
+			"UPDATE waiting_list SET notes = NULL, status = CASE WHEN status IN ('ACTIVE', 'CONTACTED') THEN 'EXPIRED' ELSE status END WHERE patient_id = $1",
+			"UPDATE payment_links SET status = 'CANCELLED' WHERE patient_id = $1 AND status = 'PENDING'",
+			"UPDATE notifications SET recipient = '', payload = NULL WHERE patient_id = $1",
+		} {
+			if _, err := conn(ctx).Exec(ctx, statement, patientID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return userID, err
+}
@@ -0,0 +1,102 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"strings"
+
+	"bookings/models"
+	"bookings/phi"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TakenPatientKeys reports which of the given emails and medical record numbers already
+// belong to a patient, deleted ones included. Emails are compared case-insensitively and
+// returned in lower case.
+func TakenPatientKeys(ctx context.Context, emails, medicalRecordNumbers []string) (takenEmails, takenMRNs map[string]bool, err error) {
+	takenEmails, takenMRNs = map[string]bool{}, map[string]bool{}
+	lowered := make([]string, len(emails))
+	for i, email := range emails {
+		lowered[i] = strings.ToLower(email)
+	}
+	rows, err := conn(ctx).Query(ctx, "SELECT lower(email) FROM patients WHERE lower(email) = ANY($1)", lowered)
+	if err != nil {
+		return nil, nil, err
+	}
+	emailsFound, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, email := range emailsFound {
+		takenEmails[email] = true
+	}
+
+	// Medical record numbers are encrypted, so they are matched through their keyed hashes
+	byIndex := make(map[string]string, len(medicalRecordNumbers))
+	indexes := make([]string, 0, len(medicalRecordNumbers))
+	for _, mrn := range medicalRecordNumbers {
+		index, err := phi.Index(mrn)
+		if err != nil {
+			return nil, nil, err
+		}
+		byIndex[index] = mrn
+		indexes = append(indexes, index)
+	}
+	rows, err = conn(ctx).Query(ctx, "SELECT medical_record_number_index FROM patients WHERE medical_record_number_index = ANY($1)", indexes)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexesFound, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, index := range indexesFound {
+		takenMRNs[byIndex[index]] = true
+	}
+	return takenEmails, takenMRNs, nil
+}
+
+// CreatePatients inserts patients in one round trip, setting each one's ID, PublicID and
+// Version and generating medical record numbers for those without as CreatePatient does.
+// Run it inside WithTx so a failed insert leaves none of them behind.
+func CreatePatients(ctx context.Context, patients []models.Patient) error {
+	if err := generateMRNs(ctx, patients); err != nil {
+		return err
+	}
+	batch := &pgx.Batch{}
+	for i := range patients {
+		patient := &patients[i]
+		if err := normalizePatientPhones(patient); err != nil {
+			return err
+		}
+		sealed, err := sealPatient(patient)
+		if err != nil {
+			return err
+		}
+		batch.Queue(
+			"INSERT INTO patients (first_name, last_name, email, phone, date_of_birth, medical_record_number, medical_record_number_index, insurance_provider, insurance_id, emergency_contact_name, emergency_contact_phone, active, phone_display) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id, public_id::text, version",
+			patient.FirstName, patient.LastName, patient.Email, patient.Phone, sealed.dateOfBirth,
+			sealed.medicalRecordNumber, sealed.mrnIndex, patient.InsuranceProvider, sealed.insuranceID,
+			patient.EmergencyContactName, patient.EmergencyContactPhone, patient.Active, patient.PhoneDisplay,
+		).QueryRow(func(row pgx.Row) error {
+			return row.Scan(&patient.ID, &patient.PublicID, &patient.Version)
+		})
+	}
+	return mrnWriteError(conn(ctx).SendBatch(ctx, batch).Close())
+}
@@ -0,0 +1,258 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+	"bookings/phi"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Merge errors
+var (
+	ErrPatientMerged = errors.New("patient has already been merged into another patient")
+	// ErrMergeIntoDeleted is returned when the patient to keep is soft-deleted
+	ErrMergeIntoDeleted = errors.New("patients cannot be merged into a deleted patient")
+)
+
+// Reasons two patients are taken for duplicates
+const (
+	MatchNameAndDateOfBirth = "name_and_date_of_birth"
+	MatchPhone              = "phone"
+	MatchEmail              = "email"
+)
+
+// PatientDuplicate is a pair of patients that look like the same person: the older record
+// (Patient) and the newer one (Duplicate), with what they have in common
+type PatientDuplicate struct {
+	Patient   models.Patient `json:"patient"`
+	Duplicate models.Patient `json:"duplicate"`
+	MatchedOn []string       `json:"matched_on"`
+}
+
+// duplicateCandidates selects pairs of live patients with the same name, phone or email,
+// the older one first, each with what matched and, for names, both encrypted dates of birth.
+// A guardian and their dependents, and dependents of the same guardian, often share a phone,
+// so their phones are not compared.
+const duplicateCandidates = `WITH live AS (SELECT id, first_name, last_name, phone, email, date_of_birth FROM patients WHERE deleted_at IS NULL)
+	SELECT a.id, b.id, 'name', a.date_of_birth, b.date_of_birth FROM live a JOIN live b
+		ON lower(btrim(a.last_name)) = lower(btrim(b.last_name)) AND lower(btrim(a.first_name)) = lower(btrim(b.first_name)) AND a.id < b.id
+	UNION ALL
+	SELECT a.id, b.id, 'phone', NULL, NULL FROM live a JOIN live b ON a.phone = b.phone AND a.id < b.id WHERE a.phone <> ''
+		AND NOT EXISTS (SELECT 1 FROM patient_relationships r WHERE (r.guardian_id, r.dependent_id) IN ((a.id, b.id), (b.id, a.id)))
+		AND NOT EXISTS (SELECT 1 FROM patient_relationships ra JOIN patient_relationships rb ON rb.guardian_id = ra.guardian_id
+			WHERE ra.dependent_id = a.id AND rb.dependent_id = b.id)
+	UNION ALL
+	SELECT a.id, b.id, 'email', NULL, NULL FROM live a JOIN live b ON lower(a.email) = lower(b.email) AND a.id < b.id WHERE a.email <> ''
+	ORDER BY 1, 2, 3`
+
+// FindDuplicatePatients lists pairs of patients who are likely the same person: same name
+// and date of birth, same phone, or same email, ignoring case. Dates of birth are
+// encrypted, so patients sharing a name are compared here rather than in the query.
+// Deleted patients are left out. Pairs come ordered by the older patient's id.
+func FindDuplicatePatients(ctx context.Context, page Page) ([]PatientDuplicate, int, error) {
+	type pair struct{ patient, duplicate int }
+	rows, err := conn(ctx).Query(ctx, duplicateCandidates)
+	if err != nil {
+		return nil, 0, err
+	}
+	var pairs []pair
+	matches := map[pair][]string{}
+	for rows.Next() {
+		var p pair
+		var match string
+		var birthA, birthB *string
+		if err := rows.Scan(&p.patient, &p.duplicate, &match, &birthA, &birthB); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		if match == "name" {
+			if birthA, err = phi.DecryptPtr(birthA); err != nil {
+				rows.Close()
+				return nil, 0, err
+			}
+			if birthB, err = phi.DecryptPtr(birthB); err != nil {
+				rows.Close()
+				return nil, 0, err
+			}
+			if birthA == nil || birthB == nil || *birthA != *birthB {
+				continue
+			}
+			match = MatchNameAndDateOfBirth
+		}
+		if _, seen := matches[p]; !seen {
+			pairs = append(pairs, p)
+		}
+		matches[p] = append(matches[p], match)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	total := len(pairs)
+	pairs = pairs[min(page.Offset, total):]
+	if limit := page.limit(); limit != nil {
+		pairs = pairs[:min(*limit, len(pairs))]
+	}
+
+	ids := make([]int, 0, 2*len(pairs))
+	for _, p := range pairs {
+		ids = append(ids, p.patient, p.duplicate)
+	}
+	rows, err = conn(ctx).Query(ctx, "SELECT "+patientColumns+" FROM patients WHERE id = ANY($1)", ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	patients := map[int]models.Patient{}
+	for rows.Next() {
+		var patient models.Patient
+		if err := scanPatient(rows, &patient); err != nil {
+			return nil, 0, err
+		}
+		patients[patient.ID] = patient
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	duplicates := make([]PatientDuplicate, len(pairs))
+	for i, p := range pairs {
+		duplicates[i] = PatientDuplicate{Patient: patients[p.patient], Duplicate: patients[p.duplicate], MatchedOn: matches[p]}
+	}
+	return duplicates, total, nil
+}
+
+// PatientMerge lists the records a merge moved from the duplicate to the kept patient that
+// are audited one by one
+type PatientMerge struct {
+	AppointmentIDs []int `json:"appointment_ids"`
+	WaitingListIDs []int `json:"waiting_list_ids"`
+	DocumentIDs    []int `json:"document_ids"`
+}
+
+// mergedTables are the rest of a patient's record a merge moves to the kept patient. The
+// access log stays with the duplicate, as the record of whose data was read.
+var mergedTables = []string{
+	"appointment_series", "prescriptions", "referrals", "patient_allergies", "patient_medications",
+	"patient_conditions", "form_responses", "patient_consents", "payment_links", "notifications", "slot_holds",
+}
+
+// MergePatients merges the duplicate patient into the kept one: appointments (their versions
+// bumped), waiting list entries, documents and the rest of the duplicate's record move to
+// the kept patient, as do its portal account, notification preferences and calendar feed
+// unless the kept patient has its own, and its guardians and dependents unless they are
+// already the kept patient's or are the kept patient. The kept patient's no-show count is recounted, and
+// the duplicate is soft-deleted with merged_into_id set; its details are left as they were.
+//
+// pgx.ErrNoRows means either patient does not exist, ErrPatientErased that either was
+// erased, ErrPatientMerged that the duplicate was merged before and ErrMergeIntoDeleted
+// that the kept patient is deleted.
+func MergePatients(ctx context.Context, keepID, duplicateID int, at time.Time) (*PatientMerge, error) {
+	var merge PatientMerge
+	err := WithTx(ctx, func(ctx context.Context) error {
+		rows, err := conn(ctx).Query(ctx,
+			`SELECT id, deleted_at IS NOT NULL, erased_at IS NOT NULL, merged_into_id IS NOT NULL
+			FROM patients WHERE id IN ($1, $2) ORDER BY id FOR UPDATE`, keepID, duplicateID)
+		if err != nil {
+			return err
+		}
+		type state struct{ deleted, erased, merged bool }
+		states := map[int]state{}
+		for rows.Next() {
+			var id int
+			var s state
+			if err := rows.Scan(&id, &s.deleted, &s.erased, &s.merged); err != nil {
+				rows.Close()
+				return err
+			}
+			states[id] = s
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		keep, keepFound := states[keepID]
+		duplicate, duplicateFound := states[duplicateID]
+		switch {
+		case !keepFound || !duplicateFound:
+			return pgx.ErrNoRows
+		case keep.erased || duplicate.erased:
+			return ErrPatientErased
+		case duplicate.merged:
+			return ErrPatientMerged
+		case keep.deleted:
+			return ErrMergeIntoDeleted
+		}
+
+		for _, move := range []struct {
+			query string
+			ids   *[]int
+		}{
+			{"UPDATE appointments SET patient_id = $1, version = version + 1 WHERE patient_id = $2 RETURNING id", &merge.AppointmentIDs},
+			{"UPDATE waiting_list SET patient_id = $1 WHERE patient_id = $2 RETURNING id", &merge.WaitingListIDs},
+			{"UPDATE patient_documents SET patient_id = $1 WHERE patient_id = $2 RETURNING id", &merge.DocumentIDs},
+		} {
+			rows, err := conn(ctx).Query(ctx, move.query, keepID, duplicateID)
+			if err != nil {
+				return err
+			}
+			if *move.ids, err = pgx.CollectRows(rows, pgx.RowTo[int]); err != nil {
+				return err
+			}
+		}
+		statements := []string{
+			"UPDATE users SET patient_id = $1 WHERE patient_id = $2 AND NOT EXISTS (SELECT 1 FROM users WHERE patient_id = $1)",
+			"UPDATE notification_preferences SET patient_id = $1 WHERE patient_id = $2 AND NOT EXISTS (SELECT 1 FROM notification_preferences WHERE patient_id = $1)",
+			`UPDATE calendar_feeds SET owner_id = $1 WHERE owner_type = 'PATIENT' AND owner_id = $2
+				AND NOT EXISTS (SELECT 1 FROM calendar_feeds WHERE owner_type = 'PATIENT' AND owner_id = $1)`,
+			`UPDATE patient_relationships r SET guardian_id = $1 WHERE guardian_id = $2 AND dependent_id <> $1
+				AND NOT EXISTS (SELECT 1 FROM patient_relationships o WHERE (o.guardian_id, o.dependent_id) IN (($1, r.dependent_id), (r.dependent_id, $1)))`,
+			`UPDATE patient_relationships r SET dependent_id = $1 WHERE dependent_id = $2 AND guardian_id <> $1
+				AND NOT EXISTS (SELECT 1 FROM patient_relationships o WHERE (o.guardian_id, o.dependent_id) IN ((r.guardian_id, $1), ($1, r.guardian_id)))`,
+			"DELETE FROM patient_relationships WHERE $2 IN (guardian_id, dependent_id)",
+		}
+		for _, table := range mergedTables {
+			statements = append(statements, "UPDATE "+table+" SET patient_id = $1 WHERE patient_id = $2")
+		}
+		for _, statement := range statements {
+			if _, err := conn(ctx).Exec(ctx, statement, keepID, duplicateID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := conn(ctx).Exec(ctx,
+			`UPDATE patients SET no_show_count = (SELECT COUNT(*) FROM appointments WHERE patient_id = $1 AND status = 'NO_SHOW'),
+				version = version + 1 WHERE id = $1`, keepID); err != nil {
+			return err
+		}
+		_, err = conn(ctx).Exec(ctx,
+			`UPDATE patients SET merged_into_id = $1, deleted_at = COALESCE(deleted_at, $3), no_show_count = 0, version = version + 1
+			WHERE id = $2`, keepID, duplicateID, at)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &merge, nil
+}
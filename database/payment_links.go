@@ -0,0 +1,172 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+	"bookings/money"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNothingOutstanding is returned when a payment link would cover no unpaid appointments
+var ErrNothingOutstanding = errors.New("no outstanding balance")
+
+// ErrPaymentLinkNotPayable is returned when a link can no longer be paid: it is paid or
+// withdrawn, it had expired when the payment was made, or the payment was for another amount
+var ErrPaymentLinkNotPayable = errors.New("payment link is not payable")
+
+// ErrMixedCurrencies is returned when a payment link would cover appointments priced in
+// different currencies, which cannot be charged as one amount
+var ErrMixedCurrencies = errors.New("outstanding appointments are in different currencies")
+
+const outstandingAppointmentsQuery = `SELECT id, payment_amount_minor, payment_currency FROM appointments
+	WHERE patient_id = $1 AND payment_status = 'PENDING' AND status <> 'CANCELLED'
+	AND payment_amount_minor IS NOT NULL AND payment_amount_minor > 0`
+
+// Payment link operations
+func CreatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	return pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		query := outstandingAppointmentsQuery
+		args := []any{link.PatientID}
+		if link.AppointmentID != nil {
+			query += " AND id = $2"
+			args = append(args, *link.AppointmentID)
+		}
+
+		rows, err := tx.Query(ctx, query+" ORDER BY id FOR UPDATE", args...)
+		if err != nil {
+			return err
+		}
+		type item struct {
+			appointmentID int
+			amount        money.Money
+		}
+		var items []item
+		for rows.Next() {
+			var it item
+			if err := rows.Scan(&it.appointmentID, &it.amount.Amount, &it.amount.Currency); err != nil {
+				rows.Close()
+				return err
+			}
+			items = append(items, it)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return ErrNothingOutstanding
+		}
+
+		link.Amount = money.Money{Currency: items[0].amount.Currency}
+		for _, it := range items {
+			if link.Amount, err = link.Amount.Add(it.amount); err != nil {
+				return ErrMixedCurrencies
+			}
+		}
+		link.Status = "PENDING"
+
+		err = tx.QueryRow(ctx,
+			"INSERT INTO payment_links (patient_id, appointment_id, token, url, amount_minor, currency, status, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at",
+			link.PatientID, link.AppointmentID, link.Token, link.URL, link.Amount.Amount, link.Amount.Currency, link.Status, link.ExpiresAt.UTC()).
+			Scan(&link.ID, &link.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		for _, it := range items {
+			_, err := tx.Exec(ctx,
+				"INSERT INTO payment_link_items (payment_link_id, appointment_id, amount_minor) VALUES ($1, $2, $3)",
+				link.ID, it.appointmentID, it.amount.Amount)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func GetPaymentLinkByToken(ctx context.Context, token string) (*models.PaymentLink, error) {
+	var link models.PaymentLink
+	err := conn(ctx).QueryRow(ctx,
+		`SELECT l.id, l.patient_id, l.appointment_id, l.token, l.url, l.amount_minor, l.currency, l.status, l.expires_at, l.paid_at, l.created_at,
+			l.payment_intent_id, p.public_id::text, a.public_id::text
+		FROM payment_links l
+		JOIN patients p ON p.id = l.patient_id
+		LEFT JOIN appointments a ON a.id = l.appointment_id
+		WHERE l.token = $1`, token).
+		Scan(&link.ID, &link.PatientID, &link.AppointmentID, &link.Token, &link.URL, &link.Amount.Amount, &link.Amount.Currency,
+			&link.Status, &link.ExpiresAt, &link.PaidAt, &link.CreatedAt, &link.PaymentIntentID, &link.PatientPublicID, &link.AppointmentPublicID)
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// SetPaymentLinkIntent records the Stripe PaymentIntent a pending link is paid with
+func SetPaymentLinkIntent(ctx context.Context, id int, paymentIntentID string) error {
+	tag, err := conn(ctx).Exec(ctx,
+		"UPDATE payment_links SET payment_intent_id = $2 WHERE id = $1 AND status = 'PENDING'", id, paymentIntentID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPaymentLinkNotPayable
+	}
+	return nil
+}
+
+// MarkPaymentLinkPaid settles the pending link paid with a PaymentIntent and marks every
+// appointment it covers as PAID. The payment must have been made before the link expired and
+// be for exactly the link's amount, or ErrPaymentLinkNotPayable is returned. An intent that
+// paid no link returns pgx.ErrNoRows.
+func MarkPaymentLinkPaid(ctx context.Context, paymentIntentID string, paid money.Money, paidAt time.Time) (*models.PaymentLink, error) {
+	var token string
+	err := pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		var id int
+		var status string
+		var amount money.Money
+		var expiresAt time.Time
+		err := tx.QueryRow(ctx,
+			"SELECT id, token, status, amount_minor, currency, expires_at FROM payment_links WHERE payment_intent_id = $1 FOR UPDATE", paymentIntentID).
+			Scan(&id, &token, &status, &amount.Amount, &amount.Currency, &expiresAt)
+		if err != nil {
+			return err
+		}
+		if status != "PENDING" || !paidAt.Before(expiresAt) || paid != amount {
+			return ErrPaymentLinkNotPayable
+		}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE payment_links SET status = 'PAID', paid_at = $2 WHERE id = $1", id, paidAt.UTC())
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			"UPDATE appointments SET payment_status = 'PAID', updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id IN (SELECT appointment_id FROM payment_link_items WHERE payment_link_id = $1)", id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetPaymentLinkByToken(ctx, token)
+}
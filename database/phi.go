@@ -0,0 +1,155 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+
+	"bookings/models"
+	"bookings/phi"
+)
+
+// Sensitive columns are encrypted by the phi package before they are written and decrypted
+// as rows are scanned, so callers only ever see plaintext. The medical record number is also
+// stored as a keyed hash, which carries its uniqueness constraint.
+
+// sealedPatient holds a patient's encrypted columns as they are written
+type sealedPatient struct {
+	dateOfBirth         *string
+	medicalRecordNumber string
+	mrnIndex            *string
+	insuranceID         *string
+}
+
+func sealPatient(patient *models.Patient) (sealedPatient, error) {
+	var sealed sealedPatient
+	var err error
+	if sealed.dateOfBirth, err = phi.EncryptPtr(patient.DateOfBirth); err != nil {
+		return sealed, err
+	}
+	if sealed.medicalRecordNumber, err = phi.Encrypt(patient.MedicalRecordNumber); err != nil {
+		return sealed, err
+	}
+	if patient.MedicalRecordNumber != "" {
+		index, err := phi.Index(patient.MedicalRecordNumber)
+		if err != nil {
+			return sealed, err
+		}
+		sealed.mrnIndex = &index
+	}
+	sealed.insuranceID, err = phi.EncryptPtr(patient.InsuranceID)
+	return sealed, err
+}
+
+// openPatient decrypts a scanned patient's encrypted columns in place
+func openPatient(patient *models.Patient) error {
+	var err error
+	if patient.DateOfBirth, err = phi.DecryptPtr(patient.DateOfBirth); err != nil {
+		return err
+	}
+	if patient.MedicalRecordNumber, err = phi.Decrypt(patient.MedicalRecordNumber); err != nil {
+		return err
+	}
+	patient.InsuranceID, err = phi.DecryptPtr(patient.InsuranceID)
+	return err
+}
+
+// EncryptStoredPHI rewrites sensitive values still stored in plaintext, or sealed under a
+// retired master key, with the active key, and fills in missing medical record number
+// hashes, in the organization ctx works for. It returns how many patients and appointments
+// were rewritten.
+func EncryptStoredPHI(ctx context.Context) (patients, appointments int, err error) {
+	rows, err := conn(ctx).Query(ctx, "SELECT "+patientColumns+", medical_record_number_index FROM patients")
+	if err != nil {
+		return 0, 0, err
+	}
+	var stale []models.Patient
+	for rows.Next() {
+		var patient models.Patient
+		var index *string
+		if err := rows.Scan(append(patientTargets(&patient), &index)...); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		current := phi.Current(patient.MedicalRecordNumber) && currentPtr(patient.DateOfBirth) && currentPtr(patient.InsuranceID) &&
+			(patient.MedicalRecordNumber == "" || index != nil)
+		if current {
+			continue
+		}
+		if err := openPatient(&patient); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		stale = append(stale, patient)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+	for i := range stale {
+		sealed, err := sealPatient(&stale[i])
+		if err != nil {
+			return patients, 0, err
+		}
+		if _, err := conn(ctx).Exec(ctx,
+			"UPDATE patients SET date_of_birth = $1, medical_record_number = $2, medical_record_number_index = $3, insurance_id = $4 WHERE id = $5",
+			sealed.dateOfBirth, sealed.medicalRecordNumber, sealed.mrnIndex, sealed.insuranceID, stale[i].ID); err != nil {
+			return patients, 0, err
+		}
+		patients++
+	}
+
+	rows, err = conn(ctx).Query(ctx, "SELECT id, medical_notes FROM appointments WHERE medical_notes IS NOT NULL")
+	if err != nil {
+		return patients, 0, err
+	}
+	staleNotes := map[int]string{}
+	for rows.Next() {
+		var id int
+		var notes string
+		if err := rows.Scan(&id, &notes); err != nil {
+			rows.Close()
+			return patients, 0, err
+		}
+		if !phi.Current(notes) {
+			staleNotes[id] = notes
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return patients, 0, err
+	}
+	for id, notes := range staleNotes {
+		plaintext, err := phi.Decrypt(notes)
+		if err != nil {
+			return patients, appointments, err
+		}
+		sealed, err := phi.Encrypt(plaintext)
+		if err != nil {
+			return patients, appointments, err
+		}
+		if _, err := conn(ctx).Exec(ctx, "UPDATE appointments SET medical_notes = $1 WHERE id = $2", sealed, id); err != nil {
+			return patients, appointments, err
+		}
+		appointments++
+	}
+	return patients, appointments, nil
+}
+
+func currentPtr(stored *string) bool {
+	return stored == nil || phi.Current(*stored)
+}
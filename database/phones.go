@@ -0,0 +1,61 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"bookings/models"
+	"bookings/phone"
+)
+
+// normalizePhone rewrites a phone number in E.164 before it is stored and sets display to
+// the number as it was entered. A number sent back already in E.164 keeps the display it
+// has while that is still the same number, so saving a record unchanged keeps its formatting.
+func normalizePhone(number *string, display **string) error {
+	if *number == "" {
+		*display = nil
+		return nil
+	}
+	region := phone.DefaultRegion()
+	normalized, err := phone.Normalize(*number, region)
+	if err != nil {
+		return err
+	}
+	if *number != normalized || *display == nil {
+		entered := *number
+		*display = &entered
+	} else if shown, err := phone.Normalize(**display, region); err != nil || shown != normalized {
+		*display = &normalized
+	}
+	*number = normalized
+	return nil
+}
+
+// normalizePatientPhones rewrites a patient's phone numbers in E.164
+func normalizePatientPhones(patient *models.Patient) error {
+	if err := normalizePhone(&patient.Phone, &patient.PhoneDisplay); err != nil {
+		return err
+	}
+	if patient.EmergencyContactPhone == nil || *patient.EmergencyContactPhone == "" {
+		return nil
+	}
+	normalized, err := phone.Normalize(*patient.EmergencyContactPhone, phone.DefaultRegion())
+	if err != nil {
+		return err
+	}
+	patient.EmergencyContactPhone = &normalized
+	return nil
+}
@@ -0,0 +1,76 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const notificationPreferencesColumns = "patient_id, channel, preferred_language, to_char(quiet_hours_start, 'HH24:MI'), to_char(quiet_hours_end, 'HH24:MI'), reminder_lead_minutes, unsubscribed_at, updated_at"
+
+func scanNotificationPreferences(row pgx.Row, p *models.NotificationPreferences) error {
+	return row.Scan(&p.PatientID, &p.Channel, &p.PreferredLanguage, &p.QuietHoursStart, &p.QuietHoursEnd,
+		&p.ReminderLeadMinutes, &p.UnsubscribedAt, &p.UpdatedAt)
+}
+
+// GetNotificationPreferences returns a patient's notification preferences, or the defaults
+// (channel ALL and nothing else set) when they have none stored
+func GetNotificationPreferences(ctx context.Context, patientID int) (*models.NotificationPreferences, error) {
+	prefs := models.NotificationPreferences{PatientID: patientID, Channel: "ALL"}
+	err := scanNotificationPreferences(conn(ctx).QueryRow(ctx,
+		"SELECT "+notificationPreferencesColumns+" FROM notification_preferences WHERE patient_id = $1", patientID), &prefs)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// SetNotificationPreferences stores a patient's notification preferences and fills in the
+// server-set fields. The unsubscribe time is kept only while the channel stays NONE.
+func SetNotificationPreferences(ctx context.Context, prefs *models.NotificationPreferences) error {
+	return scanNotificationPreferences(conn(ctx).QueryRow(ctx,
+		`INSERT INTO notification_preferences (patient_id, channel, preferred_language, quiet_hours_start, quiet_hours_end, reminder_lead_minutes)
+		VALUES ($1, $2, $3, $4::time, $5::time, $6)
+		ON CONFLICT (patient_id) DO UPDATE SET channel = EXCLUDED.channel, preferred_language = EXCLUDED.preferred_language,
+			quiet_hours_start = EXCLUDED.quiet_hours_start, quiet_hours_end = EXCLUDED.quiet_hours_end,
+			reminder_lead_minutes = EXCLUDED.reminder_lead_minutes,
+			unsubscribed_at = CASE WHEN EXCLUDED.channel = 'NONE' THEN notification_preferences.unsubscribed_at END,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING `+notificationPreferencesColumns,
+		prefs.PatientID, prefs.Channel, prefs.PreferredLanguage, prefs.QuietHoursStart, prefs.QuietHoursEnd, prefs.ReminderLeadMinutes), prefs)
+}
+
+// UnsubscribePatient turns off every notification to a patient (channel NONE), recording
+// now as when they unsubscribed, and returns the resulting preferences
+func UnsubscribePatient(ctx context.Context, patientID int, now time.Time) (*models.NotificationPreferences, error) {
+	var prefs models.NotificationPreferences
+	err := scanNotificationPreferences(conn(ctx).QueryRow(ctx,
+		`INSERT INTO notification_preferences (patient_id, channel, unsubscribed_at) VALUES ($1, 'NONE', $2)
+		ON CONFLICT (patient_id) DO UPDATE SET channel = 'NONE', unsubscribed_at = EXCLUDED.unsubscribed_at, updated_at = CURRENT_TIMESTAMP
+		RETURNING `+notificationPreferencesColumns,
+		patientID, now.UTC()), &prefs)
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
@@ -0,0 +1,128 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrPrescriptionNotActive is returned when cancelling a prescription that has expired or was
+// already cancelled
+var ErrPrescriptionNotActive = errors.New("prescription is no longer active")
+
+const prescriptionColumns = "id, appointment_id, patient_id, prescriber_id, medication, dosage, duration_days, refills, instructions, status, " +
+	"to_char(starts_on, 'YYYY-MM-DD'), to_char(expires_on, 'YYYY-MM-DD'), cancelled_at, cancellation_reason, created_by, created_at"
+
+func scanPrescription(row pgx.Row, p *models.Prescription) error {
+	return row.Scan(&p.ID, &p.AppointmentID, &p.PatientID, &p.PrescriberID, &p.Medication, &p.Dosage, &p.DurationDays, &p.Refills,
+		&p.Instructions, &p.Status, &p.StartsOn, &p.ExpiresOn, &p.CancelledAt, &p.CancellationReason, &p.CreatedBy, &p.CreatedAt)
+}
+
+func collectPrescriptions(rows pgx.Rows) ([]models.Prescription, error) {
+	defer rows.Close()
+	prescriptions := []models.Prescription{}
+	for rows.Next() {
+		var p models.Prescription
+		if err := scanPrescription(rows, &p); err != nil {
+			return nil, err
+		}
+		prescriptions = append(prescriptions, p)
+	}
+	return prescriptions, rows.Err()
+}
+
+// PrescriptionFilter narrows a prescription listing; nil fields match everything
+type PrescriptionFilter struct {
+	PatientID     *int
+	AppointmentID *int
+	Status        *string
+}
+
+// prescriptionFilterWhere applies a PrescriptionFilter passed as $1-$3
+const prescriptionFilterWhere = ` WHERE ($1::int IS NULL OR patient_id = $1)
+	AND ($2::int IS NULL OR appointment_id = $2)
+	AND ($3::text IS NULL OR status = $3)`
+
+func (f PrescriptionFilter) args() []any {
+	return []any{f.PatientID, f.AppointmentID, f.Status}
+}
+
+// Prescription operations
+
+// GetPrescriptions returns one page of the prescriptions matching the filter, latest first,
+// with the total number that match
+func GetPrescriptions(ctx context.Context, filter PrescriptionFilter, page Page) ([]models.Prescription, int, error) {
+	args := filter.args()
+	total, err := count(ctx, "SELECT COUNT(*) FROM prescriptions"+prescriptionFilterWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prescriptionColumns+" FROM prescriptions"+prescriptionFilterWhere+" ORDER BY created_at DESC, id DESC LIMIT $4 OFFSET $5",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	prescriptions, err := collectPrescriptions(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return prescriptions, total, nil
+}
+
+func GetPrescription(ctx context.Context, id int) (*models.Prescription, error) {
+	var p models.Prescription
+	if err := scanPrescription(conn(ctx).QueryRow(ctx, "SELECT "+prescriptionColumns+" FROM prescriptions WHERE id = $1", id), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreatePrescription stores an ACTIVE prescription starting on p.StartsOn and fills in its
+// id, expiry and creation time
+func CreatePrescription(ctx context.Context, p *models.Prescription) error {
+	return scanPrescription(conn(ctx).QueryRow(ctx,
+		`INSERT INTO prescriptions (appointment_id, patient_id, prescriber_id, medication, dosage, duration_days, refills, instructions, starts_on, expires_on, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::date, $9::date + $6::int * (1 + $7::int), $10)
+		RETURNING `+prescriptionColumns,
+		p.AppointmentID, p.PatientID, p.PrescriberID, p.Medication, p.Dosage, p.DurationDays, p.Refills, p.Instructions,
+		p.StartsOn, p.CreatedBy), p)
+}
+
+// CancelPrescription cancels an ACTIVE prescription with the given reason
+func CancelPrescription(ctx context.Context, id int, reason string) (*models.Prescription, error) {
+	var p models.Prescription
+	err := scanPrescription(conn(ctx).QueryRow(ctx,
+		`UPDATE prescriptions SET status = 'CANCELLED', cancelled_at = CURRENT_TIMESTAMP, cancellation_reason = $2
+		WHERE id = $1 AND status = 'ACTIVE' RETURNING `+prescriptionColumns,
+		id, reason), &p)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, getErr := GetPrescription(ctx, id); getErr != nil {
+			return nil, pgx.ErrNoRows
+		}
+		return nil, ErrPrescriptionNotActive
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
@@ -0,0 +1,224 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+	"bookings/phi"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Referral errors
+var (
+	ErrReferralNotSent     = errors.New("referral has already been accepted or declined")
+	ErrReferralNotAccepted = errors.New("referral has not been accepted, or is already booked")
+)
+
+const referralColumns = "id, patient_id, source_employee_id, target_clinic_id, target_employee_id, target_specialty, referral_reason, " +
+	"urgency_level, status, decline_reason, waiting_list_id, appointment_id, created_by, created_at, responded_at, booked_at"
+
+func scanReferral(row pgx.Row, r *models.Referral) error {
+	if err := row.Scan(&r.ID, &r.PatientID, &r.SourceEmployeeID, &r.TargetClinicID, &r.TargetEmployeeID, &r.TargetSpecialty,
+		&r.ReferralReason, &r.UrgencyLevel, &r.Status, &r.DeclineReason, &r.WaitingListID, &r.AppointmentID, &r.CreatedBy,
+		&r.CreatedAt, &r.RespondedAt, &r.BookedAt); err != nil {
+		return err
+	}
+	var err error
+	r.ReferralReason, err = phi.Decrypt(r.ReferralReason)
+	return err
+}
+
+func collectReferrals(rows pgx.Rows) ([]models.Referral, error) {
+	defer rows.Close()
+	referrals := []models.Referral{}
+	for rows.Next() {
+		var r models.Referral
+		if err := scanReferral(rows, &r); err != nil {
+			return nil, err
+		}
+		referrals = append(referrals, r)
+	}
+	return referrals, rows.Err()
+}
+
+// ReferralFilter narrows a referral listing; nil fields match everything
+type ReferralFilter struct {
+	PatientID        *int
+	SourceEmployeeID *int
+	TargetEmployeeID *int
+	TargetClinicID   *int
+	Status           *string
+}
+
+// referralFilterWhere applies a ReferralFilter passed as $1-$5
+const referralFilterWhere = ` WHERE ($1::int IS NULL OR patient_id = $1)
+	AND ($2::int IS NULL OR source_employee_id = $2)
+	AND ($3::int IS NULL OR target_employee_id = $3)
+	AND ($4::int IS NULL OR target_clinic_id = $4)
+	AND ($5::text IS NULL OR status = $5)`
+
+func (f ReferralFilter) args() []any {
+	return []any{f.PatientID, f.SourceEmployeeID, f.TargetEmployeeID, f.TargetClinicID, f.Status}
+}
+
+// Referral operations
+
+// GetReferrals returns one page of the referrals matching the filter, latest first, with the
+// total number that match
+func GetReferrals(ctx context.Context, filter ReferralFilter, page Page) ([]models.Referral, int, error) {
+	args := filter.args()
+	total, err := count(ctx, "SELECT COUNT(*) FROM referrals"+referralFilterWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+referralColumns+" FROM referrals"+referralFilterWhere+" ORDER BY created_at DESC, id DESC LIMIT $6 OFFSET $7",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	referrals, err := collectReferrals(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return referrals, total, nil
+}
+
+func GetReferral(ctx context.Context, id int) (*models.Referral, error) {
+	var r models.Referral
+	if err := scanReferral(conn(ctx).QueryRow(ctx, "SELECT "+referralColumns+" FROM referrals WHERE id = $1", id), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// lockReferral loads a referral for update; the caller must be in a transaction
+func lockReferral(ctx context.Context, id int) (*models.Referral, error) {
+	var r models.Referral
+	if err := scanReferral(conn(ctx).QueryRow(ctx, "SELECT "+referralColumns+" FROM referrals WHERE id = $1 FOR UPDATE", id), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateReferral stores a SENT referral and fills it in as stored
+func CreateReferral(ctx context.Context, r *models.Referral) error {
+	reason, err := phi.Encrypt(r.ReferralReason)
+	if err != nil {
+		return err
+	}
+	return scanReferral(conn(ctx).QueryRow(ctx,
+		`INSERT INTO referrals (patient_id, source_employee_id, target_clinic_id, target_employee_id, target_specialty, referral_reason, urgency_level, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING `+referralColumns,
+		r.PatientID, r.SourceEmployeeID, r.TargetClinicID, r.TargetEmployeeID, r.TargetSpecialty, reason, r.UrgencyLevel, r.CreatedBy), r)
+}
+
+// AcceptReferral accepts a SENT referral, putting its patient on the waiting list with entry,
+// which is filled in as created. A referral that is no longer SENT gives ErrReferralNotSent.
+func AcceptReferral(ctx context.Context, id int, entry *models.WaitingList, at time.Time) (*models.Referral, error) {
+	var r models.Referral
+	err := WithTx(ctx, func(ctx context.Context) error {
+		existing, err := lockReferral(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existing.Status != "SENT" {
+			return ErrReferralNotSent
+		}
+		entry.PatientID = existing.PatientID
+		entry.PreferredEmployeeID = existing.TargetEmployeeID
+		entry.UrgencyLevel = existing.UrgencyLevel
+		entry.Status = "ACTIVE"
+		if err := scanWaitingListItem(conn(ctx).QueryRow(ctx,
+			`INSERT INTO waiting_list (patient_id, service_id, preferred_employee_id, requested_date, urgency_level, notes, status)
+			VALUES ($1, $2, $3, $4::date, $5, $6, $7) RETURNING `+waitingListColumns,
+			entry.PatientID, entry.ServiceID, entry.PreferredEmployeeID, entry.RequestedDate,
+			entry.UrgencyLevel, entry.Notes, entry.Status), entry); err != nil {
+			return err
+		}
+		return scanReferral(conn(ctx).QueryRow(ctx,
+			`UPDATE referrals SET status = 'ACCEPTED', waiting_list_id = $2, responded_at = $3
+			WHERE id = $1 RETURNING `+referralColumns,
+			id, entry.ID, at.UTC()), &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// DeclineReferral declines a SENT referral with the given reason. A referral that is no
+// longer SENT gives ErrReferralNotSent.
+func DeclineReferral(ctx context.Context, id int, reason string, at time.Time) (*models.Referral, error) {
+	var r models.Referral
+	err := scanReferral(conn(ctx).QueryRow(ctx,
+		`UPDATE referrals SET status = 'DECLINED', decline_reason = $2, responded_at = $3
+		WHERE id = $1 AND status = 'SENT' RETURNING `+referralColumns,
+		id, reason, at.UTC()), &r)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, getErr := GetReferral(ctx, id); getErr != nil {
+			return nil, pgx.ErrNoRows
+		}
+		return nil, ErrReferralNotSent
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// BookReferral marks an ACCEPTED referral BOOKED with its appointment and takes its patient
+// off the waiting list, marking the entry SCHEDULED. The entry is returned when it changed.
+// A referral that is not ACCEPTED gives ErrReferralNotAccepted.
+func BookReferral(ctx context.Context, id, appointmentID int, at time.Time) (*models.Referral, *models.WaitingList, error) {
+	var r models.Referral
+	var scheduled *models.WaitingList
+	err := WithTx(ctx, func(ctx context.Context) error {
+		existing, err := lockReferral(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existing.Status != "ACCEPTED" {
+			return ErrReferralNotAccepted
+		}
+		if existing.WaitingListID != nil {
+			var entry models.WaitingList
+			err := scanWaitingListItem(conn(ctx).QueryRow(ctx,
+				`UPDATE waiting_list SET status = 'SCHEDULED' WHERE id = $1 AND status IN ('ACTIVE', 'CONTACTED')
+				RETURNING `+waitingListColumns, *existing.WaitingListID), &entry)
+			switch {
+			case err == nil:
+				scheduled = &entry
+			case !errors.Is(err, pgx.ErrNoRows):
+				return err
+			}
+		}
+		return scanReferral(conn(ctx).QueryRow(ctx,
+			`UPDATE referrals SET status = 'BOOKED', appointment_id = $2, booked_at = $3
+			WHERE id = $1 RETURNING `+referralColumns,
+			id, appointmentID, at.UTC()), &r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &r, scheduled, nil
+}
@@ -0,0 +1,123 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Relationship errors
+var (
+	ErrRelationshipExists = errors.New("patients are already linked")
+	// ErrRelationshipCycle is returned when linking a guardian to their own guardian
+	ErrRelationshipCycle = errors.New("the dependent is already the guardian's guardian")
+)
+
+// relationshipColumns select from patient_relationships r joined to the guardian g and the
+// dependent d
+const relationshipColumns = "r.id, r.guardian_id, g.first_name || ' ' || g.last_name, r.dependent_id, d.first_name || ' ' || d.last_name, " +
+	"r.relationship, r.created_by, r.created_at"
+
+const relationshipJoins = " FROM patient_relationships r JOIN patients g ON g.id = r.guardian_id JOIN patients d ON d.id = r.dependent_id"
+
+func scanRelationship(row pgx.Row, r *models.PatientRelationship) error {
+	return row.Scan(&r.ID, &r.GuardianID, &r.GuardianName, &r.DependentID, &r.DependentName, &r.Relationship, &r.CreatedBy, &r.CreatedAt)
+}
+
+func collectRelationships(rows pgx.Rows) ([]models.PatientRelationship, error) {
+	defer rows.Close()
+	relationships := []models.PatientRelationship{}
+	for rows.Next() {
+		var r models.PatientRelationship
+		if err := scanRelationship(rows, &r); err != nil {
+			return nil, err
+		}
+		relationships = append(relationships, r)
+	}
+	return relationships, rows.Err()
+}
+
+// GetPatientRelationships returns the relationships a patient is in, as guardian or as
+// dependent, oldest first
+func GetPatientRelationships(ctx context.Context, patientID int) ([]models.PatientRelationship, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+relationshipColumns+relationshipJoins+" WHERE $1 IN (r.guardian_id, r.dependent_id) ORDER BY r.created_at, r.id", patientID)
+	if err != nil {
+		return nil, err
+	}
+	return collectRelationships(rows)
+}
+
+// GetDependents returns the relationships of a guardian to their dependents who are not
+// deleted, by the dependents' first names
+func GetDependents(ctx context.Context, guardianID int) ([]models.PatientRelationship, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+relationshipColumns+relationshipJoins+" WHERE r.guardian_id = $1 AND d.deleted_at IS NULL ORDER BY d.first_name, r.id", guardianID)
+	if err != nil {
+		return nil, err
+	}
+	return collectRelationships(rows)
+}
+
+// GetRelationship returns the relationship of a guardian to a dependent; pgx.ErrNoRows means
+// they are not linked
+func GetRelationship(ctx context.Context, guardianID, dependentID int) (*models.PatientRelationship, error) {
+	var r models.PatientRelationship
+	err := scanRelationship(conn(ctx).QueryRow(ctx,
+		"SELECT "+relationshipColumns+relationshipJoins+" WHERE r.guardian_id = $1 AND r.dependent_id = $2", guardianID, dependentID), &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateRelationship links a guardian to a dependent and fills the relationship in as
+// stored. Patients already linked give ErrRelationshipExists, and a dependent who is the
+// guardian's own guardian ErrRelationshipCycle.
+func CreateRelationship(ctx context.Context, r *models.PatientRelationship) error {
+	var id int
+	err := conn(ctx).QueryRow(ctx,
+		`INSERT INTO patient_relationships (guardian_id, dependent_id, relationship, created_by)
+		SELECT $1, $2, $3, $4 WHERE NOT EXISTS (SELECT 1 FROM patient_relationships WHERE guardian_id = $2 AND dependent_id = $1)
+		RETURNING id`,
+		r.GuardianID, r.DependentID, r.Relationship, r.CreatedBy).Scan(&id)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		return ErrRelationshipExists
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrRelationshipCycle
+	case err != nil:
+		return err
+	}
+	return scanRelationship(conn(ctx).QueryRow(ctx, "SELECT "+relationshipColumns+relationshipJoins+" WHERE r.id = $1", id), r)
+}
+
+// DeleteRelationship unlinks a guardian from a dependent, returning the relationship's id;
+// pgx.ErrNoRows means they were not linked
+func DeleteRelationship(ctx context.Context, guardianID, dependentID int) (int, error) {
+	var id int
+	err := conn(ctx).QueryRow(ctx,
+		"DELETE FROM patient_relationships WHERE guardian_id = $1 AND dependent_id = $2 RETURNING id", guardianID, dependentID).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,72 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"bookings/models"
+)
+
+// GetUpcomingActiveAppointments returns SCHEDULED and CONFIRMED appointments starting in (from, to]
+func GetUpcomingActiveAppointments(ctx context.Context, from, to time.Time) ([]models.Appointment, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+appointmentColumns+" FROM appointments WHERE status IN ('SCHEDULED', 'CONFIRMED') AND start_datetime > $1 AND start_datetime <= $2 ORDER BY start_datetime",
+		from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return collectAppointments(rows)
+}
+
+// ClaimReminder records a reminder or escalation (kind) as sent, reporting false when it
+// already was
+func ClaimReminder(ctx context.Context, appointmentID int, kind, channel string, scheduledAt time.Time) (bool, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		"INSERT INTO sent_reminders (appointment_id, kind, channel, scheduled_at) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING",
+		appointmentID, kind, channel, scheduledAt.UTC())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseReminder forgets a claimed reminder or escalation whose delivery failed, so the next
+// sweep retries it
+func ReleaseReminder(ctx context.Context, appointmentID int, kind, channel string, scheduledAt time.Time) error {
+	_, err := conn(ctx).Exec(ctx,
+		"DELETE FROM sent_reminders WHERE appointment_id = $1 AND kind = $2 AND channel = $3 AND scheduled_at = $4",
+		appointmentID, kind, channel, scheduledAt.UTC())
+	return err
+}
+
+// GetPendingAppointmentByPhone returns the soonest SCHEDULED or CONFIRMED appointment
+// starting after now of a patient with the given phone number (E.164, as stored), for
+// matching SMS replies. It returns pgx.ErrNoRows when there is none.
+func GetPendingAppointmentByPhone(ctx context.Context, phone string, now time.Time) (*models.Appointment, error) {
+	var appointment models.Appointment
+	err := scanAppointment(conn(ctx).QueryRow(ctx,
+		"SELECT "+prefixed("a", appointmentColumns)+` FROM appointments a JOIN patients p ON p.id = a.patient_id
+		WHERE p.phone = $1 AND p.deleted_at IS NULL AND a.status IN ('SCHEDULED', 'CONFIRMED') AND a.start_datetime > $2
+		ORDER BY a.start_datetime, a.id LIMIT 1`,
+		phone, now.UTC()), &appointment)
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
@@ -0,0 +1,337 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DailyScheduleEntry is one appointment on a clinic's daily schedule report
+type DailyScheduleEntry struct {
+	AppointmentID int        `json:"appointment_id"`
+	EmployeeID    int        `json:"-"`
+	EmployeeName  string     `json:"-"`
+	Start         time.Time  `json:"start"`
+	End           time.Time  `json:"end"`
+	PatientID     int        `json:"patient_id"`
+	PatientName   string     `json:"patient_name"`
+	ServiceName   string     `json:"service"`
+	Rooms         []string   `json:"rooms"`
+	Status        string     `json:"status"`
+	CheckedInAt   *time.Time `json:"checked_in_at"`
+}
+
+// GetDailySchedule returns the clinic's appointments overlapping [dayStart, dayEnd) with
+// their employee, patient, service and room names, ordered by employee name and then start
+// time
+func GetDailySchedule(ctx context.Context, clinicID int, dayStart, dayEnd time.Time) ([]DailyScheduleEntry, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT a.id, a.employee_id, e.first_name || ' ' || e.last_name, a.start_datetime, a.end_datetime,
+			a.patient_id, p.first_name || ' ' || p.last_name, s.name, COALESCE(rooms.names, '{}'), a.status::text, a.checked_in_at
+		FROM appointments a
+		JOIN employees e ON e.id = a.employee_id
+		JOIN patients p ON p.id = a.patient_id
+		JOIN services s ON s.id = a.service_id
+		LEFT JOIN LATERAL (
+			SELECT array_agg(r.name ORDER BY r.name) AS names
+			FROM appointment_resources ar JOIN resources r ON r.id = ar.resource_id
+			WHERE ar.appointment_id = a.id AND r.kind = 'ROOM'
+		) rooms ON TRUE
+		WHERE a.clinic_id = $1 AND a.start_datetime < $3 AND a.end_datetime > $2
+		ORDER BY e.last_name, e.first_name, a.employee_id, a.start_datetime, a.id`,
+		clinicID, dayStart, dayEnd)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []DailyScheduleEntry
+	for rows.Next() {
+		var e DailyScheduleEntry
+		if err := rows.Scan(&e.AppointmentID, &e.EmployeeID, &e.EmployeeName, &e.Start, &e.End,
+			&e.PatientID, &e.PatientName, &e.ServiceName, &e.Rooms, &e.Status, &e.CheckedInAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// GetClinicTimezone returns the clinic's timezone, else the one most of its active employees
+// work in, or "" when it has neither
+func GetClinicTimezone(ctx context.Context, clinicID int) (string, error) {
+	var timezone *string
+	err := conn(ctx).QueryRow(ctx,
+		`SELECT COALESCE(
+			(SELECT NULLIF(timezone, '') FROM clinics WHERE id = $1),
+			(SELECT timezone FROM employees WHERE clinic_id = $1 AND active AND deleted_at IS NULL
+			GROUP BY timezone ORDER BY COUNT(*) DESC, timezone LIMIT 1))`, clinicID).Scan(&timezone)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && timezone == nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return *timezone, nil
+}
+
+// GetActiveEmployees returns the active employees, optionally of one clinic or just one
+// employee, ordered by name
+func GetActiveEmployees(ctx context.Context, clinicID, employeeID *int) ([]models.Employee, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+employeeColumns+` FROM employees
+		WHERE active AND deleted_at IS NULL AND ($1::int IS NULL OR clinic_id = $1) AND ($2::int IS NULL OR id = $2)
+		ORDER BY last_name, first_name, id`, clinicID, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var employees []models.Employee
+	for rows.Next() {
+		var employee models.Employee
+		if err := scanEmployee(rows, &employee); err != nil {
+			return nil, err
+		}
+		employees = append(employees, employee)
+	}
+	return employees, rows.Err()
+}
+
+// BookedTime is how much of a range an employee's appointments take up
+type BookedTime struct {
+	// Booked counts every appointment that is not cancelled, no-shows included, since
+	// their time could not be given to anyone else
+	Booked    time.Duration
+	Completed time.Duration
+}
+
+// EmployeeRange is a span of time to total one employee's appointments over
+type EmployeeRange struct {
+	EmployeeID int
+	Start, End time.Time
+}
+
+// GetBookedTime totals, for each range, the time the employee's appointments spend inside it.
+// Employees without appointments in their range are left out of the map.
+func GetBookedTime(ctx context.Context, ranges []EmployeeRange) (map[int]BookedTime, error) {
+	ids := make([]int, len(ranges))
+	starts := make([]time.Time, len(ranges))
+	ends := make([]time.Time, len(ranges))
+	for i, r := range ranges {
+		ids[i], starts[i], ends[i] = r.EmployeeID, r.Start, r.End
+	}
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT r.employee_id,
+			COALESCE(SUM(EXTRACT(EPOCH FROM LEAST(a.end_datetime, r.range_end) - GREATEST(a.start_datetime, r.range_start)))
+				FILTER (WHERE a.status <> 'CANCELLED'), 0)::float8,
+			COALESCE(SUM(EXTRACT(EPOCH FROM LEAST(a.end_datetime, r.range_end) - GREATEST(a.start_datetime, r.range_start)))
+				FILTER (WHERE a.status = 'COMPLETED'), 0)::float8
+		FROM unnest($1::int[], $2::timestamptz[], $3::timestamptz[]) AS r(employee_id, range_start, range_end)
+		JOIN appointments a ON a.employee_id = r.employee_id AND a.start_datetime < r.range_end AND a.end_datetime > r.range_start
+		GROUP BY r.employee_id`, ids, starts, ends)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	booked := map[int]BookedTime{}
+	for rows.Next() {
+		var id int
+		var total, completed float64
+		if err := rows.Scan(&id, &total, &completed); err != nil {
+			return nil, err
+		}
+		booked[id] = BookedTime{
+			Booked:    time.Duration(total * float64(time.Second)),
+			Completed: time.Duration(completed * float64(time.Second)),
+		}
+	}
+	return booked, rows.Err()
+}
+
+// RevenueRow is the payment amounts of one period, clinic, service and payment status in
+// one currency
+type RevenueRow struct {
+	Period        time.Time
+	ClinicID      int
+	ClinicName    string
+	ServiceID     int
+	ServiceName   string
+	PaymentStatus string
+	Currency      string
+	AmountMinor   int64
+	Appointments  int
+}
+
+// RevenueFilter narrows GetRevenue; nil fields match everything
+type RevenueFilter struct {
+	ClinicID  *int
+	ServiceID *int
+}
+
+// GetRevenue totals the payment amounts of appointments starting in [from, to) per
+// interval ("day", "week" or "month", in loc), clinic, service, payment status and currency.
+// Cancelled appointments only count once money has changed hands.
+func GetRevenue(ctx context.Context, from, to time.Time, interval string, loc *time.Location, filter RevenueFilter) ([]RevenueRow, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT date_trunc($3, a.start_datetime AT TIME ZONE $4), a.clinic_id, c.name, a.service_id, s.name,
+			a.payment_status::text, a.payment_currency, SUM(a.payment_amount_minor)::bigint, COUNT(*)
+		FROM appointments a
+		JOIN clinics c ON c.id = a.clinic_id
+		JOIN services s ON s.id = a.service_id
+		WHERE a.start_datetime >= $1 AND a.start_datetime < $2
+			AND a.payment_amount_minor IS NOT NULL AND a.payment_currency IS NOT NULL
+			AND (a.status <> 'CANCELLED' OR a.payment_status <> 'PENDING')
+			AND ($5::int IS NULL OR a.clinic_id = $5) AND ($6::int IS NULL OR a.service_id = $6)
+		GROUP BY 1, 2, 3, 4, 5, 6, 7
+		ORDER BY 1, c.name, s.name, 6, 7`,
+		from, to, interval, loc.String(), filter.ClinicID, filter.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revenue []RevenueRow
+	for rows.Next() {
+		var r RevenueRow
+		if err := rows.Scan(&r.Period, &r.ClinicID, &r.ClinicName, &r.ServiceID, &r.ServiceName,
+			&r.PaymentStatus, &r.Currency, &r.AmountMinor, &r.Appointments); err != nil {
+			return nil, err
+		}
+		revenue = append(revenue, r)
+	}
+	return revenue, rows.Err()
+}
+
+// Patient segments, by the patient's appointments before the one counted
+const (
+	SegmentNew         = "new"           // no completed or missed appointment before
+	SegmentReturning   = "returning"     // completed an appointment before and never missed one
+	SegmentPriorNoShow = "prior_no_show" // missed an appointment before
+)
+
+// AttendanceRow counts the outcomes of the appointments sharing a service, employee,
+// weekday and patient segment
+type AttendanceRow struct {
+	ServiceID         int
+	ServiceName       string
+	EmployeeID        int
+	EmployeeName      string
+	Weekday           int // ISO, 1 = Monday
+	Segment           string
+	Appointments      int
+	NoShows           int
+	Cancellations     int
+	LateCancellations int
+}
+
+// AttendanceFilter narrows GetAttendance; nil fields match everything
+type AttendanceFilter struct {
+	ClinicID *int
+}
+
+// GetAttendance counts the outcomes of the appointments starting in [from, to) and before
+// now. A cancellation is late when it came less than lateNotice before the start. Weekdays
+// are taken in loc.
+func GetAttendance(ctx context.Context, from, to, now time.Time, lateNotice time.Duration, loc *time.Location, filter AttendanceFilter) ([]AttendanceRow, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT a.service_id, s.name, a.employee_id, e.first_name || ' ' || e.last_name,
+			EXTRACT(ISODOW FROM a.start_datetime AT TIME ZONE $4)::int,
+			CASE WHEN history.no_shows > 0 THEN 'prior_no_show' WHEN history.completed = 0 THEN 'new' ELSE 'returning' END,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE a.status = 'NO_SHOW'),
+			COUNT(*) FILTER (WHERE a.status = 'CANCELLED'),
+			COUNT(*) FILTER (WHERE a.status = 'CANCELLED' AND a.cancelled_at > a.start_datetime - make_interval(secs => $5))
+		FROM appointments a
+		JOIN services s ON s.id = a.service_id
+		JOIN employees e ON e.id = a.employee_id
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) FILTER (WHERE p.status = 'COMPLETED') AS completed, COUNT(*) FILTER (WHERE p.status = 'NO_SHOW') AS no_shows
+			FROM appointments p WHERE p.patient_id = a.patient_id AND p.start_datetime < a.start_datetime
+		) history
+		WHERE a.start_datetime >= $1 AND a.start_datetime < LEAST($2, $3)
+			AND ($6::int IS NULL OR a.clinic_id = $6)
+		GROUP BY 1, 2, 3, 4, 5, 6`,
+		from, to, now, loc.String(), lateNotice.Seconds(), filter.ClinicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attendance []AttendanceRow
+	for rows.Next() {
+		var r AttendanceRow
+		if err := rows.Scan(&r.ServiceID, &r.ServiceName, &r.EmployeeID, &r.EmployeeName, &r.Weekday, &r.Segment,
+			&r.Appointments, &r.NoShows, &r.Cancellations, &r.LateCancellations); err != nil {
+			return nil, err
+		}
+		attendance = append(attendance, r)
+	}
+	return attendance, rows.Err()
+}
+
+// ReferralTurnaroundRow is one referral of a turnaround report with how long it took to be
+// answered and booked
+type ReferralTurnaroundRow struct {
+	UrgencyLevel string
+	// Specialty is the target specialty, else the target employee's
+	Specialty string
+	Status    string
+	// ResponseHours runs from sending to accepting or declining and BookingHours from sending
+	// to booking; nil until then
+	ResponseHours *float64
+	BookingHours  *float64
+}
+
+// ReferralTurnaroundFilter narrows GetReferralTurnaround; nil fields match everything
+type ReferralTurnaroundFilter struct {
+	TargetClinicID *int
+}
+
+// GetReferralTurnaround returns the referrals sent in [from, to) with their turnaround times
+func GetReferralTurnaround(ctx context.Context, from, to time.Time, filter ReferralTurnaroundFilter) ([]ReferralTurnaroundRow, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT r.urgency_level::text, COALESCE(r.target_specialty, e.specialty, ''), r.status,
+			(EXTRACT(EPOCH FROM r.responded_at - r.created_at) / 3600)::float8,
+			(EXTRACT(EPOCH FROM r.booked_at - r.created_at) / 3600)::float8
+		FROM referrals r
+		LEFT JOIN employees e ON e.id = r.target_employee_id
+		WHERE r.created_at >= $1 AND r.created_at < $2
+			AND ($3::int IS NULL OR r.target_clinic_id = $3)`,
+		from, to, filter.TargetClinicID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var turnaround []ReferralTurnaroundRow
+	for rows.Next() {
+		var r ReferralTurnaroundRow
+		if err := rows.Scan(&r.UrgencyLevel, &r.Specialty, &r.Status, &r.ResponseHours, &r.BookingHours); err != nil {
+			return nil, err
+		}
+		turnaround = append(turnaround, r)
+	}
+	return turnaround, rows.Err()
+}
@@ -0,0 +1,274 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+
+	"bookings/models"
+)
+
+// ClinicRepo stores clinics
+type ClinicRepo interface {
+	List(ctx context.Context, page Page, includeDeleted bool, organizationID int) ([]models.Clinic, int, error)
+	Get(ctx context.Context, id int) (*models.Clinic, error)
+	Create(ctx context.Context, clinic *models.Clinic) error
+	Update(ctx context.Context, id int, clinic *models.Clinic) error
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) error
+	Nearby(ctx context.Context, latitude, longitude, radiusKm float64, organizationID int, page Page) ([]NearbyClinic, int, error)
+}
+
+// PatientRepo stores patients
+type PatientRepo interface {
+	List(ctx context.Context, page Page, includeDeleted bool) ([]models.Patient, int, error)
+	Get(ctx context.Context, id int) (*models.Patient, error)
+	Create(ctx context.Context, patient *models.Patient) error
+	Update(ctx context.Context, id int, patient *models.Patient) error
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) error
+}
+
+// EmployeeRepo stores employees and the services they are assigned
+type EmployeeRepo interface {
+	List(ctx context.Context, page Page, includeDeleted bool) ([]models.Employee, int, error)
+	Get(ctx context.Context, id int) (*models.Employee, error)
+	Create(ctx context.Context, employee *models.Employee) error
+	Update(ctx context.Context, id int, employee *models.Employee) error
+	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) error
+	Services(ctx context.Context, employeeID int) ([]models.Service, error)
+	AssignService(ctx context.Context, employeeID, serviceID int) (bool, error)
+	UnassignService(ctx context.Context, employeeID, serviceID int) (bool, error)
+}
+
+// ServiceRepo stores services
+type ServiceRepo interface {
+	List(ctx context.Context, page Page) ([]models.Service, int, error)
+	Get(ctx context.Context, id int) (*models.Service, error)
+	Create(ctx context.Context, service *models.Service) error
+	Update(ctx context.Context, id int, service *models.Service) error
+	Delete(ctx context.Context, id int) error
+	Employees(ctx context.Context, serviceID int) ([]models.Employee, error)
+	Resources(ctx context.Context, serviceID int) ([]models.Resource, error)
+	AssignResource(ctx context.Context, serviceID, resourceID int) (bool, error)
+	UnassignResource(ctx context.Context, serviceID, resourceID int) (bool, error)
+}
+
+// AppointmentRepo stores appointments
+type AppointmentRepo interface {
+	List(ctx context.Context, filter AppointmentFilter, page Page) ([]models.Appointment, int, error)
+	Get(ctx context.Context, id int) (*models.Appointment, error)
+	ListExpanded(ctx context.Context, filter AppointmentFilter, page Page, expand Expand) ([]ExpandedAppointment, int, error)
+	GetExpanded(ctx context.Context, id int, expand Expand) (*ExpandedAppointment, error)
+	Create(ctx context.Context, appointment *models.Appointment) error
+	CreateFromHold(ctx context.Context, appointment *models.Appointment, token string) error
+	Update(ctx context.Context, id int, appointment *models.Appointment) error
+	Delete(ctx context.Context, id int) error
+}
+
+// Repos bundles the repositories handlers are constructed with, so tests can swap any of
+// them for a fake
+type Repos struct {
+	Clinics      ClinicRepo
+	Patients     PatientRepo
+	Employees    EmployeeRepo
+	Services     ServiceRepo
+	Appointments AppointmentRepo
+}
+
+// NewRepos returns the PostgreSQL repositories, which run on the pool opened by InitDB
+func NewRepos() Repos {
+	return Repos{
+		Clinics:      pgClinics{},
+		Patients:     pgPatients{},
+		Employees:    pgEmployees{},
+		Services:     pgServices{},
+		Appointments: pgAppointments{},
+	}
+}
+
+// pgClinics is the ClinicRepo over the package functions
+type pgClinics struct{}
+
+func (pgClinics) List(ctx context.Context, page Page, includeDeleted bool, organizationID int) ([]models.Clinic, int, error) {
+	return GetClinics(ctx, page, includeDeleted, organizationID)
+}
+
+func (pgClinics) Get(ctx context.Context, id int) (*models.Clinic, error) {
+	return GetClinic(ctx, id)
+}
+
+func (pgClinics) Create(ctx context.Context, clinic *models.Clinic) error {
+	return CreateClinic(ctx, clinic)
+}
+
+func (pgClinics) Update(ctx context.Context, id int, clinic *models.Clinic) error {
+	return UpdateClinic(ctx, id, clinic)
+}
+
+func (pgClinics) Delete(ctx context.Context, id int) error {
+	return DeleteClinic(ctx, id)
+}
+
+func (pgClinics) Restore(ctx context.Context, id int) error {
+	return RestoreClinic(ctx, id)
+}
+
+func (pgClinics) Nearby(ctx context.Context, latitude, longitude, radiusKm float64, organizationID int, page Page) ([]NearbyClinic, int, error) {
+	return GetNearbyClinics(ctx, latitude, longitude, radiusKm, organizationID, page)
+}
+
+// pgPatients is the PatientRepo over the package functions
+type pgPatients struct{}
+
+func (pgPatients) List(ctx context.Context, page Page, includeDeleted bool) ([]models.Patient, int, error) {
+	return GetPatients(ctx, page, includeDeleted)
+}
+
+func (pgPatients) Get(ctx context.Context, id int) (*models.Patient, error) {
+	return GetPatient(ctx, id)
+}
+
+func (pgPatients) Create(ctx context.Context, patient *models.Patient) error {
+	return CreatePatient(ctx, patient)
+}
+
+func (pgPatients) Update(ctx context.Context, id int, patient *models.Patient) error {
+	return UpdatePatient(ctx, id, patient)
+}
+
+func (pgPatients) Delete(ctx context.Context, id int) error {
+	return DeletePatient(ctx, id)
+}
+
+func (pgPatients) Restore(ctx context.Context, id int) error {
+	return RestorePatient(ctx, id)
+}
+
+// pgEmployees is the EmployeeRepo over the package functions
+type pgEmployees struct{}
+
+func (pgEmployees) List(ctx context.Context, page Page, includeDeleted bool) ([]models.Employee, int, error) {
+	return GetEmployees(ctx, page, includeDeleted)
+}
+
+func (pgEmployees) Get(ctx context.Context, id int) (*models.Employee, error) {
+	return GetEmployee(ctx, id)
+}
+
+func (pgEmployees) Create(ctx context.Context, employee *models.Employee) error {
+	return CreateEmployee(ctx, employee)
+}
+
+func (pgEmployees) Update(ctx context.Context, id int, employee *models.Employee) error {
+	return UpdateEmployee(ctx, id, employee)
+}
+
+func (pgEmployees) Delete(ctx context.Context, id int) error {
+	return DeleteEmployee(ctx, id)
+}
+
+func (pgEmployees) Restore(ctx context.Context, id int) error {
+	return RestoreEmployee(ctx, id)
+}
+
+func (pgEmployees) Services(ctx context.Context, employeeID int) ([]models.Service, error) {
+	return GetEmployeeServices(ctx, employeeID)
+}
+
+func (pgEmployees) AssignService(ctx context.Context, employeeID, serviceID int) (bool, error) {
+	return AssignService(ctx, employeeID, serviceID)
+}
+
+func (pgEmployees) UnassignService(ctx context.Context, employeeID, serviceID int) (bool, error) {
+	return UnassignService(ctx, employeeID, serviceID)
+}
+
+// pgServices is the ServiceRepo over the package functions
+type pgServices struct{}
+
+func (pgServices) List(ctx context.Context, page Page) ([]models.Service, int, error) {
+	return GetServices(ctx, page)
+}
+
+func (pgServices) Get(ctx context.Context, id int) (*models.Service, error) {
+	return GetService(ctx, id)
+}
+
+func (pgServices) Create(ctx context.Context, service *models.Service) error {
+	return CreateService(ctx, service)
+}
+
+func (pgServices) Update(ctx context.Context, id int, service *models.Service) error {
+	return UpdateService(ctx, id, service)
+}
+
+func (pgServices) Delete(ctx context.Context, id int) error {
+	return DeleteService(ctx, id)
+}
+
+func (pgServices) Employees(ctx context.Context, serviceID int) ([]models.Employee, error) {
+	return GetServiceEmployees(ctx, serviceID)
+}
+
+func (pgServices) Resources(ctx context.Context, serviceID int) ([]models.Resource, error) {
+	return GetServiceResources(ctx, serviceID)
+}
+
+func (pgServices) AssignResource(ctx context.Context, serviceID, resourceID int) (bool, error) {
+	return AssignResource(ctx, serviceID, resourceID)
+}
+
+func (pgServices) UnassignResource(ctx context.Context, serviceID, resourceID int) (bool, error) {
+	return UnassignResource(ctx, serviceID, resourceID)
+}
+
+// pgAppointments is the AppointmentRepo over the package functions
+type pgAppointments struct{}
+
+func (pgAppointments) List(ctx context.Context, filter AppointmentFilter, page Page) ([]models.Appointment, int, error) {
+	return GetAppointments(ctx, filter, page)
+}
+
+func (pgAppointments) Get(ctx context.Context, id int) (*models.Appointment, error) {
+	return GetAppointment(ctx, id)
+}
+
+func (pgAppointments) ListExpanded(ctx context.Context, filter AppointmentFilter, page Page, expand Expand) ([]ExpandedAppointment, int, error) {
+	return GetExpandedAppointments(ctx, filter, page, expand)
+}
+
+func (pgAppointments) GetExpanded(ctx context.Context, id int, expand Expand) (*ExpandedAppointment, error) {
+	return GetExpandedAppointment(ctx, id, expand)
+}
+
+func (pgAppointments) Create(ctx context.Context, appointment *models.Appointment) error {
+	return CreateAppointment(ctx, appointment)
+}
+
+func (pgAppointments) CreateFromHold(ctx context.Context, appointment *models.Appointment, token string) error {
+	return CreateAppointmentFromHold(ctx, appointment, token)
+}
+
+func (pgAppointments) Update(ctx context.Context, id int, appointment *models.Appointment) error {
+	return UpdateAppointment(ctx, id, appointment)
+}
+
+func (pgAppointments) Delete(ctx context.Context, id int) error {
+	return DeleteAppointment(ctx, id)
+}
@@ -0,0 +1,93 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotReschedulable is returned when the appointment is no longer SCHEDULED or CONFIRMED
+var ErrNotReschedulable = errors.New("only scheduled or confirmed appointments can be rescheduled")
+
+const rescheduleColumns = "id, appointment_id, old_start_datetime, old_end_datetime, old_employee_id, new_start_datetime, new_end_datetime, new_employee_id, reason, rescheduled_by, created_at"
+
+// RescheduleAppointment moves an appointment to a new time and employee, leaving every other
+// field as it is, and records the previous slot in appointment_reschedules. userID is the
+// staff member making the change, when known.
+func RescheduleAppointment(ctx context.Context, id int, start, end time.Time, employeeID int, reason *string, userID *int) (*models.Appointment, error) {
+	var appointment models.Appointment
+	err := pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		var before models.Appointment
+		if err := scanAppointment(tx.QueryRow(ctx,
+			"SELECT "+appointmentColumns+" FROM appointments WHERE id = $1 FOR UPDATE", id), &before); err != nil {
+			return err
+		}
+		if before.Status != "SCHEDULED" && before.Status != "CONFIRMED" {
+			return ErrNotReschedulable
+		}
+
+		err := scanAppointment(tx.QueryRow(ctx,
+			"UPDATE appointments SET start_datetime = $1, end_datetime = $2, employee_id = $3, updated_at = CURRENT_TIMESTAMP, version = version + 1 WHERE id = $4 RETURNING "+appointmentColumns,
+			timeutil.ToUTC(start), timeutil.ToUTC(end), employeeID, id), &appointment)
+		if err != nil {
+			return overlapError(err)
+		}
+		if err := assignResources(ctx, tx, &appointment); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO appointment_reschedules (appointment_id, old_start_datetime, old_end_datetime, old_employee_id,
+				new_start_datetime, new_end_datetime, new_employee_id, reason, rescheduled_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			id, before.StartDatetime, before.EndDatetime, before.EmployeeID,
+			appointment.StartDatetime, appointment.EndDatetime, appointment.EmployeeID, reason, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+// GetAppointmentReschedules returns an appointment's reschedule history, oldest first
+func GetAppointmentReschedules(ctx context.Context, appointmentID int) ([]models.AppointmentReschedule, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+rescheduleColumns+" FROM appointment_reschedules WHERE appointment_id = $1 ORDER BY created_at, id", appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.AppointmentReschedule{}
+	for rows.Next() {
+		var r models.AppointmentReschedule
+		if err := rows.Scan(&r.ID, &r.AppointmentID, &r.OldStartDatetime, &r.OldEndDatetime, &r.OldEmployeeID,
+			&r.NewStartDatetime, &r.NewEndDatetime, &r.NewEmployeeID, &r.Reason, &r.RescheduledBy, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, r)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,381 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrResourceInUse is returned when deleting a resource that appointments have used
+var ErrResourceInUse = errors.New("resource has been booked; deactivate it instead")
+
+// ErrResourceNameTaken is returned when a clinic already has a resource with the name
+var ErrResourceNameTaken = errors.New("clinic already has a resource with this name")
+
+// ResourceUnavailableError is returned when a booking needs a resource that is taken: either
+// every resource of a kind its service needs, or the named one asked for
+type ResourceUnavailableError struct {
+	Kind string
+	Name string
+}
+
+func (e *ResourceUnavailableError) Error() string {
+	if e.Name != "" {
+		return e.Name + " is already booked at this time"
+	}
+	return fmt.Sprintf("no %s the service needs is free at this time", strings.ToLower(e.Kind))
+}
+
+const resourceColumns = "id, clinic_id, name, kind, active, created_at"
+
+func scanResource(row pgx.Row, r *models.Resource) error {
+	return row.Scan(&r.ID, &r.ClinicID, &r.Name, &r.Kind, &r.Active, &r.CreatedAt)
+}
+
+func collectResources(rows pgx.Rows) ([]models.Resource, error) {
+	defer rows.Close()
+
+	resources := []models.Resource{}
+	for rows.Next() {
+		var r models.Resource
+		if err := scanResource(rows, &r); err != nil {
+			return nil, err
+		}
+		resources = append(resources, r)
+	}
+	return resources, rows.Err()
+}
+
+// GetResources lists resources, optionally only those at one clinic
+func GetResources(ctx context.Context, clinicID *int) ([]models.Resource, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+resourceColumns+" FROM resources WHERE $1::int IS NULL OR clinic_id = $1 ORDER BY clinic_id, kind, name", clinicID)
+	if err != nil {
+		return nil, err
+	}
+	return collectResources(rows)
+}
+
+func GetResource(ctx context.Context, id int) (*models.Resource, error) {
+	var r models.Resource
+	if err := scanResource(conn(ctx).QueryRow(ctx, "SELECT "+resourceColumns+" FROM resources WHERE id = $1", id), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CreateResource stores a resource, returning ErrResourceNameTaken when its clinic already has
+// one with the name
+func CreateResource(ctx context.Context, r *models.Resource) error {
+	return resourceWriteError(conn(ctx).QueryRow(ctx,
+		"INSERT INTO resources (clinic_id, name, kind, active) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		r.ClinicID, r.Name, r.Kind, r.Active).Scan(&r.ID, &r.CreatedAt))
+}
+
+// UpdateResource renames, moves or (de)activates a resource, reporting pgx.ErrNoRows when
+// there is no such resource and ErrResourceNameTaken as CreateResource does
+func UpdateResource(ctx context.Context, id int, r *models.Resource) error {
+	return resourceWriteError(conn(ctx).QueryRow(ctx,
+		"UPDATE resources SET clinic_id = $1, name = $2, kind = $3, active = $4 WHERE id = $5 RETURNING id, created_at",
+		r.ClinicID, r.Name, r.Kind, r.Active, id).Scan(&r.ID, &r.CreatedAt))
+}
+
+// resourceWriteError maps the unique name violation to ErrResourceNameTaken
+func resourceWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrResourceNameTaken
+	}
+	return err
+}
+
+// DeleteResource removes a resource that has never been booked, returning ErrResourceInUse
+// for one that has and pgx.ErrNoRows when there is no such resource
+func DeleteResource(ctx context.Context, id int) error {
+	err := conn(ctx).QueryRow(ctx, "DELETE FROM resources WHERE id = $1 RETURNING id", id).Scan(&id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrResourceInUse
+	}
+	return err
+}
+
+// GetServiceResources returns the resources mapped to a service, at every clinic
+func GetServiceResources(ctx context.Context, serviceID int) ([]models.Resource, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prefixed("r", resourceColumns)+" FROM resources r JOIN service_resources sr ON sr.resource_id = r.id WHERE sr.service_id = $1 ORDER BY r.clinic_id, r.kind, r.name",
+		serviceID)
+	if err != nil {
+		return nil, err
+	}
+	return collectResources(rows)
+}
+
+// AssignResource maps a resource to a service, reporting whether it was newly added
+func AssignResource(ctx context.Context, serviceID, resourceID int) (bool, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		"INSERT INTO service_resources (service_id, resource_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", serviceID, resourceID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// UnassignResource removes a mapping, reporting whether one existed
+func UnassignResource(ctx context.Context, serviceID, resourceID int) (bool, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		"DELETE FROM service_resources WHERE service_id = $1 AND resource_id = $2", serviceID, resourceID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetAppointmentResources returns the resources an appointment has been given
+func GetAppointmentResources(ctx context.Context, appointmentID int) ([]models.Resource, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prefixed("r", resourceColumns)+" FROM resources r JOIN appointment_resources ar ON ar.resource_id = r.id WHERE ar.appointment_id = $1 ORDER BY r.kind, r.name",
+		appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	return collectResources(rows)
+}
+
+// ResourceBooking is the time, buffers included, an active appointment holds a resource
+type ResourceBooking struct {
+	ResourceID int
+	Start      time.Time
+	End        time.Time
+}
+
+// GetResourceBookings returns the bookings of the given resources whose buffered times overlap
+// [from, to), leaving out appointment excludeID (0 for none)
+func GetResourceBookings(ctx context.Context, resourceIDs []int, from, to time.Time, excludeID int) ([]ResourceBooking, error) {
+	return resourceBookings(ctx, conn(ctx), resourceIDs, from, to, excludeID)
+}
+
+func resourceBookings(ctx context.Context, q dbtx, resourceIDs []int, from, to time.Time, excludeID int) ([]ResourceBooking, error) {
+	rows, err := q.Query(ctx,
+		"SELECT ar.resource_id, "+bufferedStart+", "+bufferedEnd+
+			" FROM appointment_resources ar JOIN appointments a ON a.id = ar.appointment_id JOIN services s ON s.id = a.service_id"+
+			" WHERE ar.resource_id = ANY($1) AND a.id <> $4 AND a.status NOT IN ('CANCELLED', 'NO_SHOW') AND "+bufferedStart+" < $3 AND "+bufferedEnd+" > $2",
+		resourceIDs, from.UTC(), to.UTC(), excludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []ResourceBooking
+	for rows.Next() {
+		var b ResourceBooking
+		if err := rows.Scan(&b.ResourceID, &b.Start, &b.End); err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, b)
+	}
+	return bookings, rows.Err()
+}
+
+// GetRequiredResources returns the active resources at the clinic mapped to the service, by
+// kind. A booking needs one of each kind; a service with none needs no resources there.
+func GetRequiredResources(ctx context.Context, serviceID, clinicID int) ([]models.Resource, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+prefixed("r", resourceColumns)+" FROM resources r JOIN service_resources sr ON sr.resource_id = r.id WHERE sr.service_id = $1 AND r.clinic_id = $2 AND r.active ORDER BY r.kind, r.id",
+		serviceID, clinicID)
+	if err != nil {
+		return nil, err
+	}
+	return collectResources(rows)
+}
+
+// PickResources chooses one resource of every kind in required that has no booking among
+// bookings, and returns *ResourceUnavailableError naming the first kind with nothing free
+func PickResources(required []models.Resource, bookings []ResourceBooking) ([]models.Resource, error) {
+	busy := make(map[int]bool, len(bookings))
+	for _, b := range bookings {
+		busy[b.ResourceID] = true
+	}
+	var picked []models.Resource
+	for _, kind := range models.ResourceKinds {
+		needed, found := false, false
+		for _, r := range required {
+			if r.Kind != kind {
+				continue
+			}
+			needed = true
+			if !busy[r.ID] {
+				picked = append(picked, r)
+				found = true
+				break
+			}
+		}
+		if needed && !found {
+			return nil, &ResourceUnavailableError{Kind: kind}
+		}
+	}
+	return picked, nil
+}
+
+// ErrResourceNotUsable is returned when a resource chosen for an appointment is inactive or at
+// another clinic
+var ErrResourceNotUsable = errors.New("resources must be active and at the appointment's clinic")
+
+// SetAppointmentResources replaces an appointment's resources with the given ones, which must be
+// usable and free for its buffered time. A taken one is reported as *ResourceUnavailableError.
+func SetAppointmentResources(ctx context.Context, appointment *models.Appointment, resourceIDs []int) error {
+	return WithTx(ctx, func(ctx context.Context) error {
+		q := conn(ctx)
+		rows, err := q.Query(ctx,
+			"SELECT "+resourceColumns+" FROM resources WHERE id = ANY($1) AND clinic_id = $2 AND active ORDER BY id FOR UPDATE", resourceIDs, appointment.ClinicID)
+		if err != nil {
+			return err
+		}
+		chosen, err := collectResources(rows)
+		if err != nil {
+			return err
+		}
+		slices.Sort(resourceIDs)
+		if resourceIDs = slices.Compact(resourceIDs); len(chosen) != len(resourceIDs) {
+			return ErrResourceNotUsable
+		}
+
+		from, to, err := bufferedSpan(ctx, q, appointment)
+		if err != nil {
+			return err
+		}
+		bookings, err := resourceBookings(ctx, q, resourceIDs, from, to, appointment.ID)
+		if err != nil {
+			return err
+		}
+		for _, r := range chosen {
+			if slices.ContainsFunc(bookings, func(b ResourceBooking) bool { return b.ResourceID == r.ID }) {
+				return &ResourceUnavailableError{Kind: r.Kind, Name: r.Name}
+			}
+		}
+
+		if _, err := q.Exec(ctx, "DELETE FROM appointment_resources WHERE appointment_id = $1", appointment.ID); err != nil {
+			return err
+		}
+		for _, r := range chosen {
+			if _, err := q.Exec(ctx,
+				"INSERT INTO appointment_resources (appointment_id, resource_id) VALUES ($1, $2)", appointment.ID, r.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// bufferedSpan returns the time an appointment keeps its resources, its service's buffers included
+func bufferedSpan(ctx context.Context, q dbtx, appointment *models.Appointment) (from, to time.Time, err error) {
+	var before, after int
+	if err := q.QueryRow(ctx, "SELECT buffer_before_minutes, buffer_after_minutes FROM services WHERE id = $1", appointment.ServiceID).
+		Scan(&before, &after); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return appointment.StartDatetime.Add(-time.Duration(before) * time.Minute), appointment.EndDatetime.Add(time.Duration(after) * time.Minute), nil
+}
+
+// assignResources gives an active appointment the resources its service needs at its clinic
+// for its buffered time. Resources it already has, including ones chosen by hand, are kept
+// while they are still active, at its clinic and free; only the kinds they do not cover are
+// picked again. q must be a transaction: the resources are locked so concurrent bookings
+// queue for them.
+func assignResources(ctx context.Context, q dbtx, appointment *models.Appointment) error {
+	if appointment.Status == "CANCELLED" || appointment.Status == "NO_SHOW" {
+		return nil
+	}
+	from, to, err := bufferedSpan(ctx, q, appointment)
+	if err != nil {
+		return err
+	}
+
+	rows, err := q.Query(ctx, "DELETE FROM appointment_resources WHERE appointment_id = $1 RETURNING resource_id", appointment.ID)
+	if err != nil {
+		return err
+	}
+	currentIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+	rows, err = q.Query(ctx,
+		"SELECT "+resourceColumns+" FROM resources WHERE id = ANY($1) AND clinic_id = $2 AND active ORDER BY id FOR UPDATE", currentIDs, appointment.ClinicID)
+	if err != nil {
+		return err
+	}
+	current, err := collectResources(rows)
+	if err != nil {
+		return err
+	}
+	rows, err = q.Query(ctx,
+		"SELECT "+prefixed("r", resourceColumns)+" FROM resources r JOIN service_resources sr ON sr.resource_id = r.id WHERE sr.service_id = $1 AND r.clinic_id = $2 AND r.active ORDER BY r.kind, r.id FOR UPDATE OF r",
+		appointment.ServiceID, appointment.ClinicID)
+	if err != nil {
+		return err
+	}
+	required, err := collectResources(rows)
+	if err != nil {
+		return err
+	}
+	if len(current) == 0 && len(required) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(current)+len(required))
+	for _, r := range slices.Concat(current, required) {
+		ids = append(ids, r.ID)
+	}
+	bookings, err := resourceBookings(ctx, q, ids, from, to, appointment.ID)
+	if err != nil {
+		return err
+	}
+	busy := make(map[int]bool, len(bookings))
+	for _, b := range bookings {
+		busy[b.ResourceID] = true
+	}
+	var keep []models.Resource
+	covered := map[string]bool{}
+	for _, r := range current {
+		if !busy[r.ID] {
+			keep = append(keep, r)
+			covered[r.Kind] = true
+		}
+	}
+	missing := slices.DeleteFunc(required, func(r models.Resource) bool { return covered[r.Kind] })
+	picked, err := PickResources(missing, bookings)
+	if err != nil {
+		return err
+	}
+	for _, r := range slices.Concat(keep, picked) {
+		if _, err := q.Exec(ctx,
+			"INSERT INTO appointment_resources (appointment_id, resource_id) VALUES ($1, $2)", appointment.ID, r.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"bookings/models"
+)
+
+// retentionClass describes how to find the rows of a class of data past their retention
+// period and what to keep of them when they are archived
+type retentionClass struct {
+	table string
+	// where selects the expired rows of table, aliased t, given the cutoff as $1
+	where string
+	// row is the JSON kept of a row of t
+	row string
+}
+
+// retentionClasses are the classes of data with a retention policy, by name. Appointments
+// expire once they ended before the cutoff in a final status, unless a visit note,
+// prescription or payment link still refers to them; their reschedules, card payments,
+// resources and form answers go with them. Notifications expire once created before the
+// cutoff and no longer pending. Audit entries expire once recorded before the cutoff, except
+// the latest entry of each record, which later changes are compared against.
+var retentionClasses = map[string]retentionClass{
+	"appointments": {
+		table: "appointments",
+		where: `t.end_datetime < $1 AND t.status IN ('COMPLETED', 'CANCELLED', 'NO_SHOW')
+			AND NOT EXISTS (SELECT 1 FROM visit_notes WHERE appointment_id = t.id)
+			AND NOT EXISTS (SELECT 1 FROM prescriptions WHERE appointment_id = t.id)
+			AND NOT EXISTS (SELECT 1 FROM payment_links WHERE appointment_id = t.id)
+			AND NOT EXISTS (SELECT 1 FROM payment_link_items WHERE appointment_id = t.id)`,
+		row: `to_jsonb(t) || jsonb_build_object(
+			'reschedules', (SELECT COALESCE(jsonb_agg(to_jsonb(r) ORDER BY r.id), '[]') FROM appointment_reschedules r WHERE r.appointment_id = t.id),
+			'card_payments', (SELECT COALESCE(jsonb_agg(to_jsonb(p) ORDER BY p.id), '[]') FROM card_payments p WHERE p.appointment_id = t.id),
+			'resources', (SELECT COALESCE(jsonb_agg(to_jsonb(ar)), '[]') FROM appointment_resources ar WHERE ar.appointment_id = t.id),
+			'form_responses', (SELECT COALESCE(jsonb_agg(to_jsonb(f) ORDER BY f.id), '[]') FROM form_responses f WHERE f.appointment_id = t.id))`,
+	},
+	"notifications": {
+		table: "notifications",
+		where: "t.created_at < $1 AND t.status <> 'PENDING'",
+		row:   "to_jsonb(t)",
+	},
+	"audit_log": {
+		table: "audit_log",
+		where: `t.created_at < $1 AND EXISTS (SELECT 1 FROM audit_log later WHERE later.entity = t.entity
+			AND later.entity_id = t.entity_id AND (later.created_at, later.id) > (t.created_at, t.id))`,
+		row: "to_jsonb(t)",
+	},
+}
+
+// ExpiredBatch is the rows one retention statement handled: how many and the range of their ids
+type ExpiredBatch struct {
+	Rows    int
+	FirstID int64
+	LastID  int64
+}
+
+// lookupRetentionClass returns the named class, or an error for one that does not exist
+func lookupRetentionClass(name string) (retentionClass, error) {
+	class, ok := retentionClasses[name]
+	if !ok {
+		return class, fmt.Errorf("unknown retention class %q", name)
+	}
+	return class, nil
+}
+
+// expiredIDs selects, and locks, the ids of up to $2 expired rows of a class, oldest first.
+// Rows locked by another sweep are skipped.
+func (c retentionClass) expiredIDs() string {
+	return "SELECT t.id FROM " + c.table + " t WHERE " + c.where + " ORDER BY t.id LIMIT $2 FOR UPDATE SKIP LOCKED"
+}
+
+// CountExpired counts the rows of a class past cutoff
+func CountExpired(ctx context.Context, className string, cutoff time.Time) (int, error) {
+	class, err := lookupRetentionClass(className)
+	if err != nil {
+		return 0, err
+	}
+	return count(ctx, "SELECT COUNT(*) FROM "+class.table+" t WHERE "+class.where, cutoff)
+}
+
+// ArchiveExpired moves up to limit rows of a class past cutoff into its archive table
+func ArchiveExpired(ctx context.Context, className string, cutoff time.Time, limit int, at time.Time) (ExpiredBatch, error) {
+	class, err := lookupRetentionClass(className)
+	if err != nil {
+		return ExpiredBatch{}, err
+	}
+	var batch ExpiredBatch
+	err = conn(ctx).QueryRow(ctx,
+		`WITH expired AS (`+class.expiredIDs()+`),
+		kept AS (SELECT t.id, `+class.row+` AS data FROM `+class.table+` t WHERE t.id IN (SELECT id FROM expired)),
+		archived AS (INSERT INTO `+class.table+`_archive (id, data, archived_at) SELECT id, data, $3 FROM kept RETURNING id),
+		removed AS (DELETE FROM `+class.table+` WHERE id IN (SELECT id FROM archived) RETURNING id)
+		SELECT COUNT(*), COALESCE(MIN(id), 0), COALESCE(MAX(id), 0) FROM removed`,
+		cutoff, limit, at).Scan(&batch.Rows, &batch.FirstID, &batch.LastID)
+	return batch, err
+}
+
+// PurgeExpired deletes up to limit rows of a class past cutoff
+func PurgeExpired(ctx context.Context, className string, cutoff time.Time, limit int) (ExpiredBatch, error) {
+	class, err := lookupRetentionClass(className)
+	if err != nil {
+		return ExpiredBatch{}, err
+	}
+	var batch ExpiredBatch
+	err = conn(ctx).QueryRow(ctx,
+		`WITH expired AS (`+class.expiredIDs()+`),
+		removed AS (DELETE FROM `+class.table+` WHERE id IN (SELECT id FROM expired) RETURNING id)
+		SELECT COUNT(*), COALESCE(MIN(id), 0), COALESCE(MAX(id), 0) FROM removed`,
+		cutoff, limit).Scan(&batch.Rows, &batch.FirstID, &batch.LastID)
+	return batch, err
+}
+
+// MoveExpired takes up to limit rows of a class past cutoff out of the database: it hands
+// them, as JSON, to store and deletes them once store succeeds. The rows stay locked in
+// between, and stay in place if store fails.
+func MoveExpired(ctx context.Context, className string, cutoff time.Time, limit int,
+	store func(ctx context.Context, rows []json.RawMessage, batch ExpiredBatch) error) (ExpiredBatch, error) {
+	class, err := lookupRetentionClass(className)
+	if err != nil {
+		return ExpiredBatch{}, err
+	}
+	var batch ExpiredBatch
+	err = WithTx(ctx, func(ctx context.Context) error {
+		rows, err := conn(ctx).Query(ctx,
+			`WITH expired AS (`+class.expiredIDs()+`)
+			SELECT t.id, `+class.row+` FROM `+class.table+` t WHERE t.id IN (SELECT id FROM expired) ORDER BY t.id`,
+			cutoff, limit)
+		if err != nil {
+			return err
+		}
+		var ids []int64
+		var data []json.RawMessage
+		for rows.Next() {
+			var id int64
+			var row json.RawMessage
+			if err := rows.Scan(&id, &row); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+			data = append(data, row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || len(ids) == 0 {
+			return err
+		}
+
+		batch = ExpiredBatch{Rows: len(ids), FirstID: ids[0], LastID: ids[len(ids)-1]}
+		if err := store(ctx, data, batch); err != nil {
+			return err
+		}
+		_, err = conn(ctx).Exec(ctx, "DELETE FROM "+class.table+" WHERE id = ANY($1)", ids)
+		return err
+	})
+	if err != nil {
+		return ExpiredBatch{}, err
+	}
+	return batch, nil
+}
+
+// Retention run operations
+
+const retentionRunColumns = "id, data_class, action, cutoff, row_count, first_id, last_id, locations, ran_at"
+
+// RecordRetentionRun stores the report of a retention run and fills in its id and time
+func RecordRetentionRun(ctx context.Context, run *models.RetentionRun) error {
+	if run.Locations == nil {
+		run.Locations = []string{}
+	}
+	return conn(ctx).QueryRow(ctx,
+		`INSERT INTO retention_runs (data_class, action, cutoff, row_count, first_id, last_id, locations)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, ran_at`,
+		run.DataClass, run.Action, run.Cutoff, run.RowCount, run.FirstID, run.LastID, run.Locations).Scan(&run.ID, &run.RanAt)
+}
+
+// GetRetentionRuns lists retention runs, latest first, optionally of one class
+func GetRetentionRuns(ctx context.Context, className *string, page Page) ([]models.RetentionRun, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM retention_runs WHERE $1::text IS NULL OR data_class = $1", className)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+retentionRunColumns+" FROM retention_runs WHERE $1::text IS NULL OR data_class = $1 ORDER BY ran_at DESC, id DESC LIMIT $2 OFFSET $3",
+		className, page.limit(), page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	runs := []models.RetentionRun{}
+	for rows.Next() {
+		var run models.RetentionRun
+		if err := rows.Scan(&run.ID, &run.DataClass, &run.Action, &run.Cutoff, &run.RowCount, &run.FirstID, &run.LastID,
+			&run.Locations, &run.RanAt); err != nil {
+			return nil, 0, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, total, rows.Err()
+}
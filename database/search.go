@@ -0,0 +1,152 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"strings"
+
+	"bookings/models"
+	"bookings/phi"
+)
+
+// PatientSearch narrows SearchPatients; nil fields match everything. Names match
+// case-insensitively from their start; Name matches either the first or last name.
+type PatientSearch struct {
+	PublicID            *string
+	Name                *string
+	Family              *string
+	Given               *string
+	MedicalRecordNumber *string
+}
+
+// patientSearchWhere applies a PatientSearch passed as $1-$5, leaving out deleted patients
+const patientSearchWhere = ` WHERE deleted_at IS NULL
+	AND ($1::text IS NULL OR public_id::text = $1)
+	AND ($2::text IS NULL OR first_name ILIKE $2 || '%' OR last_name ILIKE $2 || '%')
+	AND ($3::text IS NULL OR last_name ILIKE $3 || '%')
+	AND ($4::text IS NULL OR first_name ILIKE $4 || '%')
+	AND ($5::text IS NULL OR medical_record_number_index = $5)`
+
+// SearchPatients returns one page of the patients matching the search, by id, with the total
+// number that match. The medical record number is matched through its keyed hash.
+func SearchPatients(ctx context.Context, search PatientSearch, page Page) ([]models.Patient, int, error) {
+	var mrnIndex *string
+	if search.MedicalRecordNumber != nil {
+		index, err := phi.Index(*search.MedicalRecordNumber)
+		if err != nil {
+			return nil, 0, err
+		}
+		mrnIndex = &index
+	}
+	args := []any{search.PublicID, likePrefix(search.Name), likePrefix(search.Family), likePrefix(search.Given), mrnIndex}
+	total, err := count(ctx, "SELECT COUNT(*) FROM patients"+patientSearchWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+patientColumns+" FROM patients"+patientSearchWhere+" ORDER BY id LIMIT $6 OFFSET $7",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	patients := []models.Patient{}
+	for rows.Next() {
+		var patient models.Patient
+		if err := scanPatient(rows, &patient); err != nil {
+			return nil, 0, err
+		}
+		patients = append(patients, patient)
+	}
+	return patients, total, rows.Err()
+}
+
+// EmployeeSearch narrows SearchEmployees like PatientSearch; License matches the license
+// number exactly
+type EmployeeSearch struct {
+	ID      *int
+	Name    *string
+	Family  *string
+	Given   *string
+	License *string
+}
+
+// employeeSearchWhere applies an EmployeeSearch passed as $1-$5, leaving out deleted employees
+const employeeSearchWhere = ` WHERE deleted_at IS NULL
+	AND ($1::int IS NULL OR id = $1)
+	AND ($2::text IS NULL OR first_name ILIKE $2 || '%' OR last_name ILIKE $2 || '%')
+	AND ($3::text IS NULL OR last_name ILIKE $3 || '%')
+	AND ($4::text IS NULL OR first_name ILIKE $4 || '%')
+	AND ($5::text IS NULL OR license_number = $5)`
+
+// SearchEmployees returns one page of the employees matching the search, by id, with the
+// total number that match
+func SearchEmployees(ctx context.Context, search EmployeeSearch, page Page) ([]models.Employee, int, error) {
+	args := []any{search.ID, likePrefix(search.Name), likePrefix(search.Family), likePrefix(search.Given), search.License}
+	total, err := count(ctx, "SELECT COUNT(*) FROM employees"+employeeSearchWhere, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+employeeColumns+" FROM employees"+employeeSearchWhere+" ORDER BY id LIMIT $6 OFFSET $7",
+		append(args, page.limit(), page.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	employees := []models.Employee{}
+	for rows.Next() {
+		var employee models.Employee
+		if err := scanEmployee(rows, &employee); err != nil {
+			return nil, 0, err
+		}
+		employees = append(employees, employee)
+	}
+	return employees, total, rows.Err()
+}
+
+// GetPatientPublicIDs maps patient ids to their public ids
+func GetPatientPublicIDs(ctx context.Context, ids []int) (map[int]string, error) {
+	rows, err := conn(ctx).Query(ctx, "SELECT id, public_id::text FROM patients WHERE id = ANY($1)", ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	publicIDs := make(map[int]string, len(ids))
+	for rows.Next() {
+		var id int
+		var publicID string
+		if err := rows.Scan(&id, &publicID); err != nil {
+			return nil, err
+		}
+		publicIDs[id] = publicID
+	}
+	return publicIDs, rows.Err()
+}
+
+// likePrefix escapes LIKE wildcards in a search term
+func likePrefix(term *string) *string {
+	if term == nil {
+		return nil
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(*term)
+	return &escaped
+}
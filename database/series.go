@@ -0,0 +1,113 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const seriesColumns = "id, patient_id, employee_id, service_id, clinic_id, recurrence, start_datetime, status, created_at, updated_at"
+
+// scanSeries scans a row selected with seriesColumns
+func scanSeries(row pgx.Row) (*models.AppointmentSeries, error) {
+	var s models.AppointmentSeries
+	err := row.Scan(&s.ID, &s.PatientID, &s.EmployeeID, &s.ServiceID, &s.ClinicID, &s.Recurrence,
+		&s.StartDatetime, &s.Status, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Appointment series operations
+
+// CreateAppointmentSeries records a new ACTIVE series; its occurrences are created with
+// CreateAppointment and the series' id
+func CreateAppointmentSeries(ctx context.Context, series *models.AppointmentSeries) error {
+	series.Status = "ACTIVE"
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO appointment_series (patient_id, employee_id, service_id, clinic_id, recurrence, start_datetime, status) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at",
+		series.PatientID, series.EmployeeID, series.ServiceID, series.ClinicID, series.Recurrence,
+		timeutil.ToUTC(series.StartDatetime), series.Status).
+		Scan(&series.ID, &series.CreatedAt, &series.UpdatedAt)
+}
+
+// GetAppointmentSeries returns a series with all its occurrences, earliest first
+func GetAppointmentSeries(ctx context.Context, id int) (*models.AppointmentSeries, error) {
+	series, err := scanSeries(conn(ctx).QueryRow(ctx,
+		"SELECT "+seriesColumns+" FROM appointment_series WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+appointmentColumns+" FROM appointments WHERE series_id = $1 ORDER BY start_datetime, id", id)
+	if err != nil {
+		return nil, err
+	}
+	series.Occurrences, err = collectAppointments(rows)
+	if err != nil {
+		return nil, err
+	}
+	if series.Occurrences == nil {
+		series.Occurrences = []models.Appointment{}
+	}
+	return series, nil
+}
+
+// SetAppointmentSeriesEmployee records the employee a series' occurrences were moved to
+func SetAppointmentSeriesEmployee(ctx context.Context, id, employeeID int) error {
+	_, err := conn(ctx).Exec(ctx,
+		"UPDATE appointment_series SET employee_id = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+		id, employeeID)
+	return err
+}
+
+// CancelAppointmentSeries cancels an ACTIVE series together with its scheduled and confirmed
+// occurrences that start after now, recording reason on each, and returns the cancelled
+// occurrences. Occurrences already past are left as they are. It reports pgx.ErrNoRows when
+// there is no active series with the id.
+func CancelAppointmentSeries(ctx context.Context, id int, reason *string, now time.Time) ([]models.Appointment, error) {
+	var cancelled []models.Appointment
+	err := WithTx(ctx, func(ctx context.Context) error {
+		var seriesID int
+		err := conn(ctx).QueryRow(ctx,
+			"UPDATE appointment_series SET status = 'CANCELLED', updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'ACTIVE' RETURNING id",
+			id).Scan(&seriesID)
+		if err != nil {
+			return err
+		}
+		rows, err := conn(ctx).Query(ctx,
+			`UPDATE appointments SET status = 'CANCELLED', cancellation_reason = $2, updated_at = CURRENT_TIMESTAMP, version = version + 1
+			WHERE series_id = $1 AND status IN ('SCHEDULED', 'CONFIRMED') AND start_datetime > $3
+			RETURNING `+appointmentColumns, seriesID, reason, now.UTC())
+		if err != nil {
+			return err
+		}
+		cancelled, err = collectAppointments(rows)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
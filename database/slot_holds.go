@@ -0,0 +1,181 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrSlotTaken is returned when a hold would overlap an appointment or another live hold
+var ErrSlotTaken = errors.New("slot is already booked or held")
+
+// ErrHoldNotFound is returned when a hold token is unknown or has expired
+var ErrHoldNotFound = errors.New("slot hold not found or expired")
+
+// ErrHoldMismatch is returned when an appointment does not match the hold it claims
+var ErrHoldMismatch = errors.New("appointment does not match the slot hold")
+
+// Slot hold operations
+
+// CreateSlotHold places a hold after checking, under a lock on the employee, that the range
+// is not already booked or held. Expired holds for the employee are purged on the way.
+func CreateSlotHold(ctx context.Context, hold *models.SlotHold) error {
+	return pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		if err := lockEmployee(ctx, tx, hold.EmployeeID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx,
+			"DELETE FROM slot_holds WHERE employee_id = $1 AND expires_at <= CURRENT_TIMESTAMP", hold.EmployeeID); err != nil {
+			return err
+		}
+
+		// Both the hold and whatever it might clash with are widened by their services' buffers
+		var taken bool
+		err := tx.QueryRow(ctx,
+			`WITH span AS (
+				SELECT $2::timestamptz - make_interval(mins => buffer_before_minutes) AS start_at,
+					$3::timestamptz + make_interval(mins => buffer_after_minutes) AS end_at
+				FROM services WHERE id = $4
+			)
+			SELECT EXISTS (
+				SELECT 1 FROM span, appointments a JOIN services s ON s.id = a.service_id
+				WHERE a.employee_id = $1 AND a.status NOT IN ('CANCELLED', 'NO_SHOW') AND `+bufferedStart+` < span.end_at AND `+bufferedEnd+` > span.start_at
+			) OR EXISTS (
+				SELECT 1 FROM span, slot_holds a JOIN services s ON s.id = a.service_id
+				WHERE a.employee_id = $1 AND `+bufferedStart+` < span.end_at AND `+bufferedEnd+` > span.start_at
+			)`, hold.EmployeeID, timeutil.ToUTC(hold.StartDatetime), timeutil.ToUTC(hold.EndDatetime), hold.ServiceID).Scan(&taken)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrSlotTaken
+		}
+
+		return tx.QueryRow(ctx,
+			"INSERT INTO slot_holds (employee_id, service_id, start_datetime, end_datetime, patient_id, hold_token, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at",
+			hold.EmployeeID, hold.ServiceID, timeutil.ToUTC(hold.StartDatetime), timeutil.ToUTC(hold.EndDatetime),
+			hold.PatientID, hold.HoldToken, timeutil.ToUTC(hold.ExpiresAt)).Scan(&hold.ID, &hold.CreatedAt)
+	})
+}
+
+// GetLiveSlotHold returns an unexpired hold by token
+func GetLiveSlotHold(ctx context.Context, token string) (*models.SlotHold, error) {
+	var h models.SlotHold
+	err := conn(ctx).QueryRow(ctx,
+		"SELECT id, employee_id, service_id, start_datetime, end_datetime, patient_id, hold_token, expires_at, created_at FROM slot_holds WHERE hold_token = $1 AND expires_at > CURRENT_TIMESTAMP", token).
+		Scan(&h.ID, &h.EmployeeID, &h.ServiceID, &h.StartDatetime, &h.EndDatetime, &h.PatientID, &h.HoldToken, &h.ExpiresAt, &h.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrHoldNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// SetSlotHoldPatient names the patient a live hold is for. It returns ErrHoldNotFound when the
+// token is unknown, has expired or already has a patient.
+func SetSlotHoldPatient(ctx context.Context, token string, patientID int) error {
+	tag, err := conn(ctx).Exec(ctx,
+		"UPDATE slot_holds SET patient_id = $2 WHERE hold_token = $1 AND expires_at > CURRENT_TIMESTAMP AND patient_id IS NULL", token, patientID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrHoldNotFound
+	}
+	return nil
+}
+
+// ReleaseSlotHold deletes a hold and returns its id, reporting ErrHoldNotFound when the token is unknown
+func ReleaseSlotHold(ctx context.Context, token string) (int, error) {
+	var id int
+	err := conn(ctx).QueryRow(ctx, "DELETE FROM slot_holds WHERE hold_token = $1 RETURNING id", token).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrHoldNotFound
+	}
+	return id, err
+}
+
+// CreateAppointmentFromHold consumes a live hold and inserts the appointment in one
+// transaction. Employee, service, times and patient left unset are taken from the hold;
+// any that are set must match it.
+func CreateAppointmentFromHold(ctx context.Context, appointment *models.Appointment, token string) error {
+	return pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		var hold models.SlotHold
+		err := tx.QueryRow(ctx,
+			"DELETE FROM slot_holds WHERE hold_token = $1 AND expires_at > CURRENT_TIMESTAMP RETURNING employee_id, service_id, start_datetime, end_datetime, patient_id", token).
+			Scan(&hold.EmployeeID, &hold.ServiceID, &hold.StartDatetime, &hold.EndDatetime, &hold.PatientID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrHoldNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := ApplySlotHold(appointment, &hold); err != nil {
+			return err
+		}
+		if err := insertAppointment(ctx, tx, appointment); err != nil {
+			return err
+		}
+		return assignResources(ctx, tx, appointment)
+	})
+}
+
+// ApplySlotHold fills the appointment's unset employee, service, times and patient from the
+// hold, returning ErrHoldMismatch when a field that is set disagrees with it
+func ApplySlotHold(appointment *models.Appointment, hold *models.SlotHold) error {
+	if appointment.EmployeeID == 0 {
+		appointment.EmployeeID = hold.EmployeeID
+	}
+	if appointment.ServiceID == 0 {
+		appointment.ServiceID = hold.ServiceID
+	}
+	if appointment.StartDatetime.IsZero() {
+		appointment.StartDatetime = hold.StartDatetime
+	}
+	if appointment.EndDatetime.IsZero() {
+		appointment.EndDatetime = hold.EndDatetime
+	}
+	if appointment.PatientID == 0 && hold.PatientID != nil {
+		appointment.PatientID = *hold.PatientID
+	}
+
+	if appointment.EmployeeID != hold.EmployeeID || appointment.ServiceID != hold.ServiceID ||
+		!appointment.StartDatetime.Equal(hold.StartDatetime) || !appointment.EndDatetime.Equal(hold.EndDatetime) ||
+		(hold.PatientID != nil && appointment.PatientID != *hold.PatientID) {
+		return ErrHoldMismatch
+	}
+	return nil
+}
+
+// DeleteExpiredSlotHolds removes holds whose expiry has passed and returns how many were removed
+func DeleteExpiredSlotHolds(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := conn(ctx).Exec(ctx, "DELETE FROM slot_holds WHERE expires_at <= $1", now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
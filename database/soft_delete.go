@@ -0,0 +1,61 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrRestoreConflict is returned when restoring a row would clash with a unique value, such as
+// a clinic name, taken since it was deleted
+var ErrRestoreConflict = errors.New("another record has since taken this one's unique values")
+
+// softDelete marks a row of a table with a deleted_at column as deleted. It returns
+// pgx.ErrNoRows when there is no such row or it is already deleted.
+func softDelete(ctx context.Context, table string, id int) error {
+	tag, err := conn(ctx).Exec(ctx,
+		"UPDATE "+table+" SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// restore clears deleted_at on a soft-deleted row, returning pgx.ErrNoRows when there is no
+// deleted row with the id
+func restore(ctx context.Context, table string, id int) error {
+	tag, err := conn(ctx).Exec(ctx,
+		"UPDATE "+table+" SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrRestoreConflict
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"bookings/models"
+)
+
+// MarkNoShows marks SCHEDULED and CONFIRMED appointments that ended at or before cutoff
+// without the patient having checked in as NO_SHOW, counts them on their patients and
+// returns them. Attended appointments have been checked in, taken in (IN_PROGRESS) or
+// COMPLETED by then.
+func MarkNoShows(ctx context.Context, cutoff time.Time) ([]models.Appointment, error) {
+	var marked []models.Appointment
+	err := WithTx(ctx, func(ctx context.Context) error {
+		rows, err := conn(ctx).Query(ctx,
+			`UPDATE appointments SET status = 'NO_SHOW', updated_at = CURRENT_TIMESTAMP, version = version + 1
+			WHERE status IN ('SCHEDULED', 'CONFIRMED') AND checked_in_at IS NULL AND end_datetime <= $1
+			RETURNING `+appointmentColumns, cutoff.UTC())
+		if err != nil {
+			return err
+		}
+		if marked, err = collectAppointments(rows); err != nil {
+			return err
+		}
+		patientIDs := make([]int, 0, len(marked))
+		for _, a := range marked {
+			patientIDs = append(patientIDs, a.PatientID)
+		}
+		return recountNoShows(ctx, conn(ctx), patientIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return marked, nil
+}
+
+// recountNoShows sets the no-show count of the given patients from their NO_SHOW appointments
+func recountNoShows(ctx context.Context, q dbtx, patientIDs []int) error {
+	if len(patientIDs) == 0 {
+		return nil
+	}
+	_, err := q.Exec(ctx,
+		`UPDATE patients p SET no_show_count = (SELECT COUNT(*) FROM appointments a WHERE a.patient_id = p.id AND a.status = 'NO_SHOW')
+		WHERE p.id = ANY($1)`, patientIDs)
+	return err
+}
+
+// ExpireWaitingList marks ACTIVE and CONTACTED waiting list entries whose requested date
+// is before today (YYYY-MM-DD), or that were created before createdBefore when it is set,
+// as EXPIRED and returns them
+func ExpireWaitingList(ctx context.Context, today string, createdBefore *time.Time) ([]models.WaitingList, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`UPDATE waiting_list SET status = 'EXPIRED'
+		WHERE status IN ('ACTIVE', 'CONTACTED')
+		AND (requested_date < $1::date OR created_at < $2)
+		RETURNING `+waitingListColumns, today, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	return collectWaitingList(rows)
+}
+
+// EscalateWaitingList stamps escalated_at with now on URGENT entries that are still waiting
+// (ACTIVE or CONTACTED), were created at or before cutoff and have not been escalated yet,
+// and returns them
+func EscalateWaitingList(ctx context.Context, cutoff, now time.Time) ([]models.WaitingList, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`UPDATE waiting_list SET escalated_at = $2
+		WHERE urgency_level = 'URGENT' AND status IN ('ACTIVE', 'CONTACTED') AND escalated_at IS NULL AND created_at <= $1
+		RETURNING `+waitingListColumns, cutoff.UTC(), now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	return collectWaitingList(rows)
+}
+
+// ExpirePrescriptions marks EXPIRED the ACTIVE prescriptions whose expiry date is before
+// today (YYYY-MM-DD) and returns them
+func ExpirePrescriptions(ctx context.Context, today string) ([]models.Prescription, error) {
+	rows, err := conn(ctx).Query(ctx,
+		"UPDATE prescriptions SET status = 'EXPIRED' WHERE status = 'ACTIVE' AND expires_on < $1::date RETURNING "+prescriptionColumns, today)
+	if err != nil {
+		return nil, err
+	}
+	return collectPrescriptions(rows)
+}
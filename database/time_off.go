@@ -0,0 +1,103 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrTimeOffNotPending is returned when approving or rejecting a request that was already decided
+var ErrTimeOffNotPending = errors.New("time off request has already been decided")
+
+// Time off operations
+const timeOffColumns = "id, employee_id, start_datetime, end_datetime, reason, status, decided_at, decision_note, created_at"
+
+// scanTimeOff scans a row selected with timeOffColumns
+func scanTimeOff(row pgx.Row, t *models.TimeOff) error {
+	return row.Scan(&t.ID, &t.EmployeeID, &t.StartDatetime, &t.EndDatetime, &t.Reason,
+		&t.Status, &t.DecidedAt, &t.DecisionNote, &t.CreatedAt)
+}
+
+func collectTimeOff(rows pgx.Rows) ([]models.TimeOff, error) {
+	defer rows.Close()
+
+	var timeOff []models.TimeOff
+	for rows.Next() {
+		var t models.TimeOff
+		if err := scanTimeOff(rows, &t); err != nil {
+			return nil, err
+		}
+		timeOff = append(timeOff, t)
+	}
+	return timeOff, rows.Err()
+}
+
+// ListTimeOff returns time off requests, newest first, optionally for one employee and/or status
+func ListTimeOff(ctx context.Context, employeeID *int, status *string, page Page) ([]models.TimeOff, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM time_off WHERE ($1::int IS NULL OR employee_id = $1) AND ($2::text IS NULL OR status::text = $2)", employeeID, status)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+timeOffColumns+" FROM time_off WHERE ($1::int IS NULL OR employee_id = $1) AND ($2::text IS NULL OR status::text = $2) ORDER BY start_datetime DESC, id LIMIT $3 OFFSET $4",
+		employeeID, status, page.limit(), page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	timeOff, err := collectTimeOff(rows)
+	return timeOff, total, err
+}
+
+func GetTimeOff(ctx context.Context, id int) (*models.TimeOff, error) {
+	var t models.TimeOff
+	if err := scanTimeOff(conn(ctx).QueryRow(ctx,
+		"SELECT "+timeOffColumns+" FROM time_off WHERE id = $1", id), &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateTimeOff files a new PENDING request
+func CreateTimeOff(ctx context.Context, t *models.TimeOff) error {
+	return scanTimeOff(conn(ctx).QueryRow(ctx,
+		"INSERT INTO time_off (employee_id, start_datetime, end_datetime, reason) VALUES ($1, $2, $3, $4) RETURNING "+timeOffColumns,
+		t.EmployeeID, timeutil.ToUTC(t.StartDatetime), timeutil.ToUTC(t.EndDatetime), t.Reason), t)
+}
+
+// DecideTimeOff moves a PENDING request to APPROVED or REJECTED
+func DecideTimeOff(ctx context.Context, id int, status string, note *string) (*models.TimeOff, error) {
+	var t models.TimeOff
+	err := scanTimeOff(conn(ctx).QueryRow(ctx,
+		"UPDATE time_off SET status = $2, decision_note = $3, decided_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'PENDING' RETURNING "+timeOffColumns,
+		id, status, note), &t)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if _, getErr := GetTimeOff(ctx, id); getErr != nil {
+			return nil, pgx.ErrNoRows
+		}
+		return nil, ErrTimeOffNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
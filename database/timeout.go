@@ -0,0 +1,40 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultQueryTimeout bounds a single statement when DB_QUERY_TIMEOUT is not set
+const DefaultQueryTimeout = 5 * time.Second
+
+// QueryTimeout reads the per-statement limit from DB_QUERY_TIMEOUT (e.g. "2s"). PostgreSQL
+// cancels any statement that runs longer, whatever context it was sent with.
+func QueryTimeout() (time.Duration, error) {
+	raw := os.Getenv("DB_QUERY_TIMEOUT")
+	if raw == "" {
+		return DefaultQueryTimeout, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < time.Millisecond {
+		return 0, fmt.Errorf("invalid DB_QUERY_TIMEOUT %q", raw)
+	}
+	return d, nil
+}
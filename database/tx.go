@@ -0,0 +1,56 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// dbtx runs queries; it is satisfied by the pool and by a transaction
+type dbtx interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+type txContextKey struct{}
+
+// conn returns the transaction WithTx put on ctx, or the pool outside one
+func conn(ctx context.Context) dbtx {
+	if tx, ok := ctx.Value(txContextKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return DB
+}
+
+// WithTx runs fn in a transaction that commits when fn returns nil and rolls back when it
+// returns an error. Every function in this package called with the context fn is given
+// joins the transaction, so several of them can be made atomic together; those that open
+// a transaction of their own use a savepoint inside it. Nested WithTx calls do the same.
+//
+// A failed statement aborts the whole transaction, so fn must return the errors it gets
+// rather than carry on.
+func WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		return fn(context.WithValue(ctx, txContextKey{}, tx))
+	})
+}
@@ -0,0 +1,70 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// UnpaidCancellationReason is recorded on appointments cancelled for missing prepayment
+const UnpaidCancellationReason = "Automatically cancelled: prepayment was not received in time"
+
+// CancelUnpaidAppointments cancels SCHEDULED appointments for services that require prepayment
+// once their payment window (counted from booking) has passed without payment, and withdraws
+// any pending payment links that cover them. It returns the cancelled appointments.
+func CancelUnpaidAppointments(ctx context.Context, now time.Time) ([]models.Appointment, error) {
+	var cancelled []models.Appointment
+	err := pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx,
+			`UPDATE appointments SET status = 'CANCELLED', cancellation_reason = $2, updated_at = $1, version = version + 1
+			WHERE id IN (
+				SELECT a.id FROM appointments a
+				JOIN services s ON s.id = a.service_id
+				WHERE a.status = 'SCHEDULED' AND a.payment_status = 'PENDING'
+				AND s.prepayment_window_minutes IS NOT NULL
+				AND a.created_at + make_interval(mins => s.prepayment_window_minutes) <= $1
+				FOR UPDATE OF a SKIP LOCKED
+			)
+			RETURNING `+appointmentColumns, now.UTC(), UnpaidCancellationReason)
+		if err != nil {
+			return err
+		}
+		if cancelled, err = collectAppointments(rows); err != nil {
+			return err
+		}
+		if len(cancelled) == 0 {
+			return nil
+		}
+
+		ids := make([]int, len(cancelled))
+		for i, a := range cancelled {
+			ids[i] = a.ID
+		}
+		_, err = tx.Exec(ctx,
+			"UPDATE payment_links SET status = 'CANCELLED' WHERE status = 'PENDING' AND id IN (SELECT payment_link_id FROM payment_link_items WHERE appointment_id = ANY($1))", ids)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
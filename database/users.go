@@ -0,0 +1,158 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrRefreshTokenInvalid is returned for refresh tokens that are unknown, expired, revoked or
+// belong to a deactivated user
+var ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+
+// User operations
+const userColumns = "id, email, password_hash, role, patient_id, active, created_at, organization_id"
+
+func scanUser(row pgx.Row, user *models.User) error {
+	return row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.PatientID, &user.Active, &user.CreatedAt, &user.OrganizationID)
+}
+
+func GetUser(ctx context.Context, id int) (*models.User, error) {
+	var user models.User
+	if err := scanUser(conn(ctx).QueryRow(ctx,
+		"SELECT "+userColumns+" FROM users WHERE id = $1", id), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUserByEmail looks a user up by email, case-insensitively
+func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := scanUser(conn(ctx).QueryRow(ctx,
+		"SELECT "+userColumns+" FROM users WHERE lower(email) = lower($1)", email), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUsers lists the user accounts of an organization
+func GetUsers(ctx context.Context, page Page, organizationID int) ([]models.User, int, error) {
+	total, err := count(ctx, "SELECT COUNT(*) FROM users WHERE organization_id = $1", organizationID)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx, "SELECT "+userColumns+" FROM users WHERE organization_id = $3 ORDER BY id LIMIT $1 OFFSET $2", page.limit(), page.Offset, organizationID)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+// CreateUser inserts a user into their organization, the default one when they have none
+func CreateUser(ctx context.Context, user *models.User) error {
+	user.OrganizationID = organizationOrDefault(user.OrganizationID)
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO users (email, password_hash, role, patient_id, active, organization_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at",
+		user.Email, user.PasswordHash, user.Role, user.PatientID, user.Active, user.OrganizationID).Scan(&user.ID, &user.CreatedAt)
+}
+
+// UpdateUser changes a user's email, role, linked patient, active flag and password hash. Deactivating a user
+// also revokes their refresh tokens so they are signed out once their access token expires.
+func UpdateUser(ctx context.Context, id int, user *models.User) error {
+	return pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx,
+			"UPDATE users SET email = $1, password_hash = $2, role = $3, patient_id = $4, active = $5 WHERE id = $6",
+			user.Email, user.PasswordHash, user.Role, user.PatientID, user.Active, id)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		if !user.Active {
+			_, err = tx.Exec(ctx,
+				"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL", id)
+		}
+		return err
+	})
+}
+
+func CountUsers(ctx context.Context) (int, error) {
+	var n int
+	err := conn(ctx).QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&n)
+	return n, err
+}
+
+// StoreRefreshToken records the hash of a newly issued refresh token
+func StoreRefreshToken(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := conn(ctx).Exec(ctx,
+		"INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+		userID, tokenHash, expiresAt.UTC())
+	return err
+}
+
+// RotateRefreshToken revokes a live refresh token and stores its replacement in one
+// transaction, returning the owning user. Each refresh token can be used only once.
+func RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*models.User, error) {
+	var user models.User
+	err := pgx.BeginFunc(ctx, conn(ctx), func(tx pgx.Tx) error {
+		var userID int
+		err := tx.QueryRow(ctx,
+			"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP RETURNING user_id",
+			oldHash).Scan(&userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := scanUser(tx.QueryRow(ctx,
+			"SELECT "+userColumns+" FROM users WHERE id = $1", userID), &user); err != nil {
+			return err
+		}
+		if !user.Active {
+			return ErrRefreshTokenInvalid
+		}
+
+		_, err = tx.Exec(ctx,
+			"INSERT INTO refresh_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+			userID, newHash, expiresAt.UTC())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
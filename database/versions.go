@@ -0,0 +1,40 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrStaleVersion is returned when an update names a version the record has moved past
+var ErrStaleVersion = errors.New("record was changed since it was read")
+
+// versionError explains why a versioned UPDATE matched no row: ErrStaleVersion when the row
+// exists and pgx.ErrNoRows when it does not
+func versionError(ctx context.Context, table string, id int) error {
+	var exists bool
+	if err := conn(ctx).QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrStaleVersion
+	}
+	return pgx.ErrNoRows
+}
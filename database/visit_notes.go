@@ -0,0 +1,237 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/models"
+	"bookings/phi"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Visit note errors
+var (
+	ErrVisitNoteSigned    = errors.New("visit note is signed; amend it instead")
+	ErrVisitNoteNotSigned = errors.New("visit note is not signed yet; edit it instead")
+	ErrVisitNoteNotAuthor = errors.New("visit note is another clinician's draft")
+	// ErrAppointmentHasVisitNote is returned when deleting an appointment with a visit note
+	ErrAppointmentHasVisitNote = errors.New("appointment has a visit note and cannot be deleted")
+)
+
+const visitNoteColumns = "id, appointment_id, subjective, objective, assessment, plan, author_id, signed_at, amended_at, created_at, updated_at"
+
+func scanVisitNote(row pgx.Row, note *models.VisitNote) error {
+	if err := row.Scan(&note.ID, &note.AppointmentID, &note.Subjective, &note.Objective, &note.Assessment, &note.Plan,
+		&note.AuthorID, &note.SignedAt, &note.AmendedAt, &note.CreatedAt, &note.UpdatedAt); err != nil {
+		return err
+	}
+	return openSections(&note.SOAPSections)
+}
+
+// sealSections returns the sections encrypted for writing
+func sealSections(sections models.SOAPSections) (models.SOAPSections, error) {
+	var sealed models.SOAPSections
+	var err error
+	for _, pair := range [][2]**string{
+		{&sealed.Subjective, &sections.Subjective},
+		{&sealed.Objective, &sections.Objective},
+		{&sealed.Assessment, &sections.Assessment},
+		{&sealed.Plan, &sections.Plan},
+	} {
+		if *pair[0], err = phi.EncryptPtr(*pair[1]); err != nil {
+			return sealed, err
+		}
+	}
+	return sealed, nil
+}
+
+// openSections decrypts scanned sections in place
+func openSections(sections *models.SOAPSections) error {
+	var err error
+	for _, section := range []**string{&sections.Subjective, &sections.Objective, &sections.Assessment, &sections.Plan} {
+		if *section, err = phi.DecryptPtr(*section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Visit note operations
+
+// GetVisitNote returns an appointment's visit note
+func GetVisitNote(ctx context.Context, appointmentID int) (*models.VisitNote, error) {
+	var note models.VisitNote
+	if err := scanVisitNote(conn(ctx).QueryRow(ctx,
+		"SELECT "+visitNoteColumns+" FROM visit_notes WHERE appointment_id = $1", appointmentID), &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// lockVisitNote loads an appointment's visit note for update; the caller must be in a transaction
+func lockVisitNote(ctx context.Context, appointmentID int) (*models.VisitNote, error) {
+	var note models.VisitNote
+	if err := scanVisitNote(conn(ctx).QueryRow(ctx,
+		"SELECT "+visitNoteColumns+" FROM visit_notes WHERE appointment_id = $1 FOR UPDATE", appointmentID), &note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// SaveVisitNoteDraft writes note.AuthorID's draft of the note of note.AppointmentID: it is
+// created if the appointment has none, and its sections replaced otherwise. A signed note
+// gives ErrVisitNoteSigned and another author's draft ErrVisitNoteNotAuthor. note is filled
+// in as saved.
+func SaveVisitNoteDraft(ctx context.Context, note *models.VisitNote) error {
+	sealed, err := sealSections(note.SOAPSections)
+	if err != nil {
+		return err
+	}
+	return WithTx(ctx, func(ctx context.Context) error {
+		existing, err := lockVisitNote(ctx, note.AppointmentID)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return scanVisitNote(conn(ctx).QueryRow(ctx,
+				`INSERT INTO visit_notes (appointment_id, subjective, objective, assessment, plan, author_id)
+				VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+visitNoteColumns,
+				note.AppointmentID, sealed.Subjective, sealed.Objective, sealed.Assessment, sealed.Plan, note.AuthorID), note)
+		case err != nil:
+			return err
+		case existing.SignedAt != nil:
+			return ErrVisitNoteSigned
+		case !sameAuthor(existing.AuthorID, note.AuthorID):
+			return ErrVisitNoteNotAuthor
+		}
+		return scanVisitNote(conn(ctx).QueryRow(ctx,
+			`UPDATE visit_notes SET subjective = $2, objective = $3, assessment = $4, plan = $5, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 RETURNING `+visitNoteColumns,
+			existing.ID, sealed.Subjective, sealed.Objective, sealed.Assessment, sealed.Plan), note)
+	})
+}
+
+// SignVisitNote signs and locks an appointment's draft note, which only its author may do.
+// pgx.ErrNoRows means the appointment has no note.
+func SignVisitNote(ctx context.Context, appointmentID int, authorID *int, at time.Time) (*models.VisitNote, error) {
+	var note models.VisitNote
+	err := WithTx(ctx, func(ctx context.Context) error {
+		existing, err := lockVisitNote(ctx, appointmentID)
+		switch {
+		case err != nil:
+			return err
+		case existing.SignedAt != nil:
+			return ErrVisitNoteSigned
+		case !sameAuthor(existing.AuthorID, authorID):
+			return ErrVisitNoteNotAuthor
+		}
+		return scanVisitNote(conn(ctx).QueryRow(ctx,
+			"UPDATE visit_notes SET signed_at = $2, updated_at = $2 WHERE id = $1 RETURNING "+visitNoteColumns,
+			existing.ID, at.UTC()), &note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// AmendVisitNote changes the sections of a signed note that changes gives (nil ones are
+// kept), recording the amendment with the sections it replaced. A draft gives
+// ErrVisitNoteNotSigned; pgx.ErrNoRows means the appointment has no note.
+func AmendVisitNote(ctx context.Context, appointmentID int, changes models.SOAPSections, amendment *models.VisitNoteAmendment) (*models.VisitNote, error) {
+	var note models.VisitNote
+	err := WithTx(ctx, func(ctx context.Context) error {
+		existing, err := lockVisitNote(ctx, appointmentID)
+		if err != nil {
+			return err
+		}
+		if existing.SignedAt == nil {
+			return ErrVisitNoteNotSigned
+		}
+
+		before, err := sealSections(existing.SOAPSections)
+		if err != nil {
+			return err
+		}
+		amendment.VisitNoteID = existing.ID
+		amendment.SOAPSections = existing.SOAPSections
+		if err := conn(ctx).QueryRow(ctx,
+			`INSERT INTO visit_note_amendments (visit_note_id, reason, subjective, objective, assessment, plan, author_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`,
+			existing.ID, amendment.Reason, before.Subjective, before.Objective, before.Assessment, before.Plan, amendment.AuthorID).
+			Scan(&amendment.ID, &amendment.CreatedAt); err != nil {
+			return err
+		}
+
+		after := existing.SOAPSections
+		for _, pair := range [][2]**string{
+			{&after.Subjective, &changes.Subjective},
+			{&after.Objective, &changes.Objective},
+			{&after.Assessment, &changes.Assessment},
+			{&after.Plan, &changes.Plan},
+		} {
+			if *pair[1] != nil {
+				*pair[0] = *pair[1]
+			}
+		}
+		sealed, err := sealSections(after)
+		if err != nil {
+			return err
+		}
+		return scanVisitNote(conn(ctx).QueryRow(ctx,
+			`UPDATE visit_notes SET subjective = $2, objective = $3, assessment = $4, plan = $5, amended_at = $6, updated_at = $6
+			WHERE id = $1 RETURNING `+visitNoteColumns,
+			existing.ID, sealed.Subjective, sealed.Objective, sealed.Assessment, sealed.Plan, amendment.CreatedAt), &note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// GetVisitNoteAmendments lists a note's amendments, oldest first
+func GetVisitNoteAmendments(ctx context.Context, visitNoteID int) ([]models.VisitNoteAmendment, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT id, visit_note_id, reason, subjective, objective, assessment, plan, author_id, created_at
+		FROM visit_note_amendments WHERE visit_note_id = $1 ORDER BY created_at, id`, visitNoteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	amendments := []models.VisitNoteAmendment{}
+	for rows.Next() {
+		var a models.VisitNoteAmendment
+		if err := rows.Scan(&a.ID, &a.VisitNoteID, &a.Reason, &a.Subjective, &a.Objective, &a.Assessment, &a.Plan,
+			&a.AuthorID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := openSections(&a.SOAPSections); err != nil {
+			return nil, err
+		}
+		amendments = append(amendments, a)
+	}
+	return amendments, rows.Err()
+}
+
+// sameAuthor reports whether two author ids are the same user; a note whose author's account
+// was removed belongs to nobody
+func sameAuthor(a, b *int) bool {
+	return a != nil && b != nil && *a == *b
+}
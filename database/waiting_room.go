@@ -0,0 +1,100 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// SetWaitingRoomDisplay stores the token hash of a clinic's waiting room display, replacing
+// (and so revoking) any earlier display URL
+func SetWaitingRoomDisplay(ctx context.Context, clinicID int, tokenHash string) (int, error) {
+	var id int
+	err := conn(ctx).QueryRow(ctx,
+		`INSERT INTO waiting_room_displays (clinic_id, token_hash) VALUES ($1, $2)
+		ON CONFLICT (clinic_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = CURRENT_TIMESTAMP
+		RETURNING id`,
+		clinicID, tokenHash).Scan(&id)
+	return id, err
+}
+
+// DeleteWaitingRoomDisplay revokes a clinic's waiting room display and returns its id,
+// reporting pgx.ErrNoRows when there was none
+func DeleteWaitingRoomDisplay(ctx context.Context, clinicID int) (int, error) {
+	var id int
+	err := conn(ctx).QueryRow(ctx,
+		"DELETE FROM waiting_room_displays WHERE clinic_id = $1 RETURNING id", clinicID).Scan(&id)
+	return id, err
+}
+
+// GetWaitingRoomDisplayClinic looks up which clinic a display token hash belongs to
+func GetWaitingRoomDisplayClinic(ctx context.Context, tokenHash string) (int, error) {
+	var clinicID int
+	err := conn(ctx).QueryRow(ctx,
+		"SELECT clinic_id FROM waiting_room_displays WHERE token_hash = $1", tokenHash).Scan(&clinicID)
+	return clinicID, err
+}
+
+// WaitingRoomAppointment is an appointment as the waiting room display and the clinic queue
+// need it
+type WaitingRoomAppointment struct {
+	ID               int
+	Status           string
+	StartDatetime    time.Time
+	EmployeeID       int
+	EmployeeName     string
+	PatientID        int
+	PatientFirstName string
+	PatientLastName  string
+	CheckedInAt      *time.Time
+	StartedAt        *time.Time
+	CheckedOutAt     *time.Time
+}
+
+// GetWaitingRoomAppointments returns the clinic's appointments on the current day, as of now
+// in each employee's timezone, that are still to be seen, being seen or were seen, in order
+// of start time and then of arrival
+func GetWaitingRoomAppointments(ctx context.Context, clinicID int, now time.Time) ([]WaitingRoomAppointment, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`SELECT a.id, a.status, a.start_datetime, a.employee_id, e.first_name || ' ' || e.last_name,
+			a.patient_id, p.first_name, p.last_name, a.checked_in_at, a.started_at, a.checked_out_at
+		FROM appointments a
+		JOIN employees e ON e.id = a.employee_id
+		JOIN patients p ON p.id = a.patient_id
+		WHERE a.clinic_id = $1
+		AND a.status IN ('SCHEDULED', 'CONFIRMED', 'IN_PROGRESS', 'COMPLETED')
+		AND (a.start_datetime AT TIME ZONE COALESCE(NULLIF(e.timezone, ''), 'UTC'))::date
+			= ($2::timestamptz AT TIME ZONE COALESCE(NULLIF(e.timezone, ''), 'UTC'))::date
+		ORDER BY a.start_datetime, a.checked_in_at NULLS LAST, a.id`,
+		clinicID, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var appointments []WaitingRoomAppointment
+	for rows.Next() {
+		var a WaitingRoomAppointment
+		if err := rows.Scan(&a.ID, &a.Status, &a.StartDatetime, &a.EmployeeID, &a.EmployeeName,
+			&a.PatientID, &a.PatientFirstName, &a.PatientLastName, &a.CheckedInAt, &a.StartedAt, &a.CheckedOutAt); err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, a)
+	}
+	return appointments, rows.Err()
+}
@@ -0,0 +1,261 @@
+// Medical Appointment Booking System - Database Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"bookings/models"
+	"bookings/phi"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const webhookSubscriptionColumns = "id, url, events, description, active, created_at"
+
+func scanWebhookSubscription(row pgx.Row, s *models.WebhookSubscription) error {
+	return row.Scan(&s.ID, &s.URL, &s.Events, &s.Description, &s.Active, &s.CreatedAt)
+}
+
+// GetWebhookSubscriptions lists the subscriptions, without their secrets
+func GetWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	rows, err := conn(ctx).Query(ctx, "SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []models.WebhookSubscription{}
+	for rows.Next() {
+		var s models.WebhookSubscription
+		if err := scanWebhookSubscription(rows, &s); err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	return subscriptions, rows.Err()
+}
+
+// GetWebhookSubscription returns a subscription without its secret
+func GetWebhookSubscription(ctx context.Context, id int) (*models.WebhookSubscription, error) {
+	var s models.WebhookSubscription
+	err := scanWebhookSubscription(conn(ctx).QueryRow(ctx,
+		"SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions WHERE id = $1", id), &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// CreateWebhookSubscription stores a subscription, encrypting its secret
+func CreateWebhookSubscription(ctx context.Context, s *models.WebhookSubscription) error {
+	secret, err := phi.Encrypt(s.Secret)
+	if err != nil {
+		return err
+	}
+	return conn(ctx).QueryRow(ctx,
+		"INSERT INTO webhook_subscriptions (url, events, description, secret, active) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at",
+		s.URL, s.Events, s.Description, secret, s.Active).Scan(&s.ID, &s.CreatedAt)
+}
+
+// UpdateWebhookSubscription changes a subscription's endpoint, events, description and
+// whether it is active, keeping its secret. It returns pgx.ErrNoRows when there is no such
+// subscription.
+func UpdateWebhookSubscription(ctx context.Context, id int, s *models.WebhookSubscription) error {
+	return conn(ctx).QueryRow(ctx,
+		"UPDATE webhook_subscriptions SET url = $1, events = $2, description = $3, active = $4 WHERE id = $5 RETURNING id, created_at",
+		s.URL, s.Events, s.Description, s.Active, id).Scan(&s.ID, &s.CreatedAt)
+}
+
+// DeleteWebhookSubscription removes a subscription and its delivery log, returning
+// pgx.ErrNoRows when there is no such subscription
+func DeleteWebhookSubscription(ctx context.Context, id int) error {
+	return conn(ctx).QueryRow(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1 RETURNING id", id).Scan(&id)
+}
+
+// EnqueueWebhookEvent stores an event with a PENDING delivery for every active subscription
+// to its type and returns how many deliveries were queued. Nothing is stored when nobody
+// subscribes to the type.
+func EnqueueWebhookEvent(ctx context.Context, eventType string, payload []byte) (int, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		`WITH subscribers AS (
+			SELECT id FROM webhook_subscriptions WHERE active AND $1 = ANY(events)
+		), event AS (
+			INSERT INTO webhook_events (event_type, payload) SELECT $1, $2 WHERE EXISTS (SELECT 1 FROM subscribers) RETURNING id
+		)
+		INSERT INTO webhook_deliveries (subscription_id, event_id) SELECT subscribers.id, event.id FROM subscribers, event`,
+		eventType, payload)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+const webhookDeliveryColumns = "d.id, d.subscription_id, d.event_id, e.event_type, e.payload, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at"
+
+const webhookDeliveryFrom = " FROM webhook_deliveries d JOIN webhook_events e ON e.id = d.event_id"
+
+func scanWebhookDelivery(row pgx.Row, d *models.WebhookDelivery) error {
+	if err := row.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.DeliveredAt, &d.CreatedAt); err != nil {
+		return err
+	}
+	// Only a pending delivery has another attempt coming
+	if d.Status != "PENDING" {
+		d.NextAttemptAt = nil
+	}
+	return nil
+}
+
+// GetWebhookDeliveries lists a subscription's deliveries, newest first, optionally only those
+// with the given status
+func GetWebhookDeliveries(ctx context.Context, subscriptionID int, status *string, page Page) ([]models.WebhookDelivery, int, error) {
+	const where = " WHERE d.subscription_id = $1 AND ($2::text IS NULL OR d.status = $2)"
+	total, err := count(ctx, "SELECT COUNT(*)"+webhookDeliveryFrom+where, subscriptionID, status)
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT "+webhookDeliveryColumns+webhookDeliveryFrom+where+" ORDER BY d.created_at DESC, d.id DESC LIMIT $3 OFFSET $4",
+		subscriptionID, status, page.limit(), page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := scanWebhookDelivery(rows, &d); err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// GetWebhookDelivery returns one of a subscription's deliveries with every attempt made, oldest first
+func GetWebhookDelivery(ctx context.Context, subscriptionID, id int) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := scanWebhookDelivery(conn(ctx).QueryRow(ctx,
+		"SELECT "+webhookDeliveryColumns+webhookDeliveryFrom+" WHERE d.id = $1 AND d.subscription_id = $2", id, subscriptionID), &d)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := conn(ctx).Query(ctx,
+		"SELECT attempted_at, status_code, error, duration_ms FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY attempted_at, id", id)
+	if err != nil {
+		return nil, err
+	}
+	if d.AttemptLog, err = pgx.CollectRows(rows, pgx.RowToStructByPos[models.WebhookAttempt]); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// RetryWebhookDelivery queues a FAILED delivery again with a fresh set of attempts, reporting
+// whether it was FAILED
+func RetryWebhookDelivery(ctx context.Context, subscriptionID, id int) (bool, error) {
+	tag, err := conn(ctx).Exec(ctx,
+		"UPDATE webhook_deliveries SET status = 'PENDING', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP WHERE id = $1 AND subscription_id = $2 AND status = 'FAILED'",
+		id, subscriptionID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DueWebhookDelivery is a delivery claimed for an attempt, with what is needed to send it
+type DueWebhookDelivery struct {
+	ID             int
+	EventID        int
+	EventType      string
+	Payload        json.RawMessage
+	EventCreatedAt time.Time
+	// Attempts is the number of attempts made before this one
+	Attempts int
+	URL      string
+	Secret   string
+}
+
+// ClaimWebhookDeliveries claims up to limit PENDING deliveries to active subscriptions that are
+// due at now, moving their next attempt to leaseUntil so no other dispatcher picks them up
+// while they are being sent
+func ClaimWebhookDeliveries(ctx context.Context, now, leaseUntil time.Time, limit int) ([]DueWebhookDelivery, error) {
+	rows, err := conn(ctx).Query(ctx,
+		`WITH due AS (
+			SELECT d.id FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = 'PENDING' AND d.next_attempt_at <= $1 AND s.active
+			ORDER BY d.next_attempt_at, d.id LIMIT $3
+			FOR UPDATE OF d SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries d SET next_attempt_at = $2 FROM due WHERE d.id = due.id
+			RETURNING d.id, d.event_id, d.subscription_id, d.attempts
+		)
+		SELECT c.id, c.event_id, e.event_type, e.payload, e.created_at, c.attempts, s.url, s.secret
+		FROM claimed c JOIN webhook_events e ON e.id = c.event_id JOIN webhook_subscriptions s ON s.id = c.subscription_id
+		ORDER BY c.id`,
+		now.UTC(), leaseUntil.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DueWebhookDelivery
+	for rows.Next() {
+		var d DueWebhookDelivery
+		if err := rows.Scan(&d.ID, &d.EventID, &d.EventType, &d.Payload, &d.EventCreatedAt, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+		if d.Secret, err = phi.Decrypt(d.Secret); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+// RecordWebhookAttempt logs an attempt of a delivery and moves it on: DELIVERED when it
+// succeeded, PENDING until retryAt when it failed and is to be retried, or FAILED when it
+// failed and retryAt is nil
+func RecordWebhookAttempt(ctx context.Context, deliveryID int, attempt models.WebhookAttempt, succeeded bool, retryAt *time.Time) error {
+	return WithTx(ctx, func(ctx context.Context) error {
+		_, err := conn(ctx).Exec(ctx,
+			"INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, status_code, error, duration_ms) VALUES ($1, $2, $3, $4, $5)",
+			deliveryID, attempt.AttemptedAt.UTC(), attempt.StatusCode, attempt.Error, attempt.DurationMS)
+		if err != nil {
+			return err
+		}
+
+		status := "FAILED"
+		var deliveredAt *time.Time
+		nextAttemptAt := attempt.AttemptedAt
+		switch {
+		case succeeded:
+			status, deliveredAt = "DELIVERED", &attempt.AttemptedAt
+		case retryAt != nil:
+			status, nextAttemptAt = "PENDING", *retryAt
+		}
+		_, err = conn(ctx).Exec(ctx,
+			`UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, next_attempt_at = $3,
+			last_status_code = $4, last_error = $5, delivered_at = $6 WHERE id = $1`,
+			deliveryID, status, nextAttemptAt.UTC(), attempt.StatusCode, attempt.Error, deliveredAt)
+		return err
+	})
+}
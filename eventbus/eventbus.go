@@ -0,0 +1,120 @@
+// Medical Appointment Booking System - Event Bus Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package eventbus publishes a domain event for every mutation to NATS or Kafka, for
+// clinics feeding other internal systems. Events go through a transactional outbox: each
+// is stored with its audit entry and relayed to the broker by a background job, so one
+// that cannot be published right away, or is pending when the process stops, is sent later
+// rather than lost.
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"bookings/models"
+)
+
+// DefaultTopic is the NATS subject prefix or Kafka topic used when EVENT_BUS_TOPIC is not set
+const DefaultTopic = "bookings"
+
+// DefaultNATSURL is the server used when EVENT_BUS=nats and EVENT_BUS_URL is not set
+const DefaultNATSURL = "nats://localhost:4222"
+
+// Timeout bounds connecting to the broker and each publish
+const Timeout = 10 * time.Second
+
+// Event is the message published for one mutation. It is the mutation's audit entry, so
+// Data holds the record afterwards with PHI fields redacted, as the audit log does, and ID
+// is the audit entry id, which consumers use to drop duplicates.
+type Event struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Entity      string          `json:"entity"`
+	EntityID    int             `json:"entity_id"`
+	Action      string          `json:"action"`
+	ActorUserID *int            `json:"actor_user_id"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	Data        json.RawMessage `json:"data"`
+	Changes     json.RawMessage `json:"changes"`
+}
+
+// NewEvent builds the event for an audit entry. Its type is "<entity>.<action>", e.g.
+// "appointments.update".
+func NewEvent(entry *models.AuditEntry) Event {
+	return Event{
+		ID:          entry.ID,
+		Type:        entry.Entity + "." + strings.ToLower(entry.Action),
+		Entity:      entry.Entity,
+		EntityID:    entry.EntityID,
+		Action:      entry.Action,
+		ActorUserID: entry.ActorUserID,
+		OccurredAt:  entry.CreatedAt.UTC(),
+		Data:        entry.Snapshot,
+		Changes:     entry.Changes,
+	}
+}
+
+// Publisher sends events to a broker. Publish returns nil only once the broker has
+// accepted every event, in order.
+type Publisher interface {
+	Publish(ctx context.Context, events []Event) error
+}
+
+// FromEnv reads EVENT_BUS ("nats" or "kafka"), EVENT_BUS_URL and EVENT_BUS_TOPIC. It returns
+// nil when EVENT_BUS is not set, which leaves event publishing off.
+func FromEnv() (Publisher, error) {
+	kind := os.Getenv("EVENT_BUS")
+	raw := os.Getenv("EVENT_BUS_URL")
+	topic := os.Getenv("EVENT_BUS_TOPIC")
+	if topic == "" {
+		topic = DefaultTopic
+	}
+	if strings.ContainsAny(topic, " \t\r\n*>") {
+		return nil, fmt.Errorf("invalid EVENT_BUS_TOPIC %q", topic)
+	}
+
+	switch kind {
+	case "":
+		return nil, nil
+	case "nats":
+		if raw == "" {
+			raw = DefaultNATSURL
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+			return nil, fmt.Errorf("invalid EVENT_BUS_URL %q: want nats://host:port or tls://host:port", raw)
+		}
+		return NewNATS(u, topic), nil
+	case "kafka":
+		if raw == "" {
+			return nil, errors.New("EVENT_BUS_URL must be set to the Kafka REST Proxy URL with EVENT_BUS=kafka")
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid EVENT_BUS_URL %q: want the http(s) URL of a Kafka REST Proxy", raw)
+		}
+		return NewKafka(u, topic), nil
+	default:
+		return nil, fmt.Errorf("invalid EVENT_BUS %q: want nats or kafka", kind)
+	}
+}
@@ -0,0 +1,102 @@
+// Medical Appointment Booking System - Event Bus Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Kafka publishes events to one topic through a Kafka REST Proxy (v2 API). Each record is
+// keyed "<entity>:<entity_id>", so a record's events land on one partition in order.
+type Kafka struct {
+	URL    *url.URL
+	Topic  string
+	Client *http.Client
+}
+
+// NewKafka returns a publisher to topic through the REST Proxy at u; credentials in u are
+// sent as basic auth
+func NewKafka(u *url.URL, topic string) *Kafka {
+	return &Kafka{URL: u, Topic: topic, Client: &http.Client{Timeout: Timeout}}
+}
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value Event  `json:"value"`
+}
+
+// kafkaResponse is the REST Proxy's answer: one offset per record, with an error for each
+// record the broker refused
+type kafkaResponse struct {
+	Offsets []struct {
+		Partition *int   `json:"partition"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+	Message string `json:"message"`
+}
+
+// Publish produces the events in one request
+func (k *Kafka) Publish(ctx context.Context, events []Event) error {
+	records := make([]kafkaRecord, len(events))
+	for i := range events {
+		records[i] = kafkaRecord{Key: events[i].Entity + ":" + strconv.Itoa(events[i].EntityID), Value: events[i]}
+	}
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return err
+	}
+
+	endpoint := *k.URL
+	endpoint.User = nil
+	endpoint = *endpoint.JoinPath("topics", k.Topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if user := k.URL.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result kafkaResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil && resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("Kafka REST Proxy: decoding response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Kafka REST Proxy answered %d: %s", resp.StatusCode, result.Message)
+	}
+	for _, offset := range result.Offsets {
+		if offset.Error != "" {
+			return fmt.Errorf("Kafka refused an event: %s", offset.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// Medical Appointment Booking System - Event Bus Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS publishes events to a NATS server over its text protocol, on the subject
+// "<prefix>.<entity>.<action>", e.g. "bookings.appointments.update". Core NATS only hands
+// messages to current subscribers; capture the subjects in a JetStream stream to keep them.
+type NATS struct {
+	URL    *url.URL
+	Prefix string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewNATS returns a publisher for the server at u (nats:// or tls://, with optional
+// user:password or token credentials). It connects on the first publish.
+func NewNATS(u *url.URL, prefix string) *NATS {
+	return &NATS{URL: u, Prefix: prefix}
+}
+
+// Publish sends the events and then a PING; the server's PONG confirms it has processed
+// them. The connection is kept for the next call and dropped after any error.
+func (n *NATS) Publish(ctx context.Context, events []Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if err := n.publish(ctx, events); err != nil {
+		n.close()
+		return err
+	}
+	return nil
+}
+
+func (n *NATS) publish(ctx context.Context, events []Event) error {
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return fmt.Errorf("connecting to NATS: %w", err)
+		}
+	}
+	deadline := time.Now().Add(Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	n.conn.SetDeadline(deadline)
+
+	w := bufio.NewWriter(n.conn)
+	for i := range events {
+		body, err := json.Marshal(events[i])
+		if err != nil {
+			return err
+		}
+		subject := n.Prefix + "." + events[i].Entity + "." + strings.ToLower(events[i].Action)
+		fmt.Fprintf(w, "PUB %s %d\r\n", subject, len(body))
+		w.Write(body)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("PING\r\n")
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return n.awaitPong()
+}
+
+// connect dials the server, reads its INFO, upgrades to TLS for tls:// URLs and sends
+// CONNECT with the URL's credentials
+func (n *NATS) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.URL.Host)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(Timeout))
+	reader := bufio.NewReader(conn)
+	line, err := readLine(reader)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+	if n.URL.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: n.URL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn, reader = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	options := map[string]any{"verbose": false, "pedantic": false, "name": "bookings", "lang": "go", "version": "1", "protocol": 1}
+	if user := n.URL.User; user != nil {
+		if password, ok := user.Password(); ok {
+			options["user"], options["pass"] = user.Username(), password
+		} else {
+			options["auth_token"] = user.Username()
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		conn.Close()
+		return err
+	}
+	n.conn, n.reader = conn, reader
+	return n.awaitPong()
+}
+
+// awaitPong reads server messages until the PONG answering our PING, answering the
+// server's own PINGs on the way
+func (n *NATS) awaitPong() error {
+	for {
+		line, err := readLine(n.reader)
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("NATS: " + strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+		// INFO updates and +OK need no answer
+	}
+}
+
+func (n *NATS) close() {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn, n.reader = nil, nil
+	}
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
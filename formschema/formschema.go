@@ -0,0 +1,254 @@
+// Medical Appointment Booking System - Intake Form Schema Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package formschema reads intake form templates, written in a subset of JSON Schema, and
+// checks patients' answers against them. A template is an object schema whose properties are
+// the questions: strings (optionally of format "date"), numbers, integers, booleans, or
+// arrays of strings for multiple choice. Questions may have a title and description, and be
+// limited with enum, minLength, maxLength, minimum, maximum, minItems and maxItems. Nested
+// objects and the other JSON Schema keywords are not supported.
+package formschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxQuestions caps how many properties one template may ask
+const MaxQuestions = 200
+
+// Question types
+const (
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+	TypeArray   = "array"
+)
+
+// FormatDate is the one string format checked: a calendar date as YYYY-MM-DD
+const FormatDate = "date"
+
+// Schema is a template or one of its questions
+type Schema struct {
+	// Dialect and ID are the $schema and $id keywords, accepted and ignored
+	Dialect     string             `json:"$schema,omitempty"`
+	ID          string             `json:"$id,omitempty"`
+	Type        string             `json:"type"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+}
+
+// Parse reads a template, rejecting keywords outside the supported subset so a template
+// never suggests a check that is not made
+func Parse(raw json.RawMessage) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	decoder.UseNumber()
+	var s Schema
+	if err := decoder.Decode(&s); err != nil {
+		return nil, fmt.Errorf("schema is not a supported JSON Schema: %w", err)
+	}
+	if s.Type != "object" {
+		return nil, errors.New(`schema must be of type "object"`)
+	}
+	if len(s.Properties) == 0 {
+		return nil, errors.New("schema must have at least one property")
+	}
+	if len(s.Properties) > MaxQuestions {
+		return nil, fmt.Errorf("schema may have at most %d properties", MaxQuestions)
+	}
+	for name, question := range s.Properties {
+		if question == nil {
+			return nil, fmt.Errorf("property %q must be a schema", name)
+		}
+		if err := question.check(false); err != nil {
+			return nil, fmt.Errorf("property %q: %w", name, err)
+		}
+	}
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			return nil, fmt.Errorf("required property %q is not defined", name)
+		}
+	}
+	return &s, nil
+}
+
+// check validates a question, or the items of a multiple choice question
+func (s *Schema) check(item bool) error {
+	switch s.Type {
+	case TypeString, TypeNumber, TypeInteger, TypeBoolean:
+	case TypeArray:
+		if item {
+			return errors.New("arrays of arrays are not supported")
+		}
+		if s.Items == nil || s.Items.Type != TypeString || len(s.Items.Enum) == 0 {
+			return errors.New(`arrays must have items of type "string" with an enum`)
+		}
+		if err := s.Items.check(true); err != nil {
+			return fmt.Errorf("items: %w", err)
+		}
+	case "object":
+		return errors.New("nested objects are not supported")
+	default:
+		return fmt.Errorf("unsupported type %q", s.Type)
+	}
+	if len(s.Properties) > 0 || len(s.Required) > 0 {
+		return errors.New("properties and required are only allowed on the form itself")
+	}
+	if s.Type != TypeArray && s.Items != nil {
+		return errors.New("items is only allowed on arrays")
+	}
+	if s.Format != "" && (s.Type != TypeString || s.Format != FormatDate) {
+		return errors.New(`only strings may have a format, and only "date"`)
+	}
+	for _, value := range s.Enum {
+		if problem := s.problem(value); problem != "" {
+			return fmt.Errorf("enum value %v %s", value, problem)
+		}
+	}
+	return nil
+}
+
+// Validate checks answers, a JSON object, against the template, returning a message per
+// invalid answer or nil when they are all valid. Answers to questions the template does not
+// ask are invalid too.
+func (s *Schema) Validate(answers json.RawMessage) map[string]string {
+	decoder := json.NewDecoder(bytes.NewReader(answers))
+	decoder.UseNumber()
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil || values == nil {
+		return map[string]string{"answers": "must be a JSON object"}
+	}
+	problems := map[string]string{}
+	for _, name := range s.Required {
+		if value, ok := values[name]; !ok || value == nil {
+			problems[name] = "is required"
+		}
+	}
+	for name, value := range values {
+		question, ok := s.Properties[name]
+		switch {
+		case !ok:
+			problems[name] = "is not a question on this form"
+		case value == nil:
+			// an unanswered optional question
+		default:
+			if problem := question.problem(value); problem != "" {
+				problems[name] = problem
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return problems
+}
+
+// problem describes why value is not a valid answer to the question, or returns "" when it is
+func (s *Schema) problem(value any) string {
+	switch s.Type {
+	case TypeString:
+		text, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		length := utf8.RuneCountInString(text)
+		if s.MinLength != nil && length < *s.MinLength {
+			return fmt.Sprintf("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fmt.Sprintf("must be at most %d characters", *s.MaxLength)
+		}
+		if s.Format == FormatDate {
+			if _, err := time.Parse(time.DateOnly, text); err != nil {
+				return "must be a date as YYYY-MM-DD"
+			}
+		}
+	case TypeNumber, TypeInteger:
+		number, ok := value.(json.Number)
+		if !ok {
+			return "must be a number"
+		}
+		f, err := number.Float64()
+		if err != nil {
+			return "must be a number"
+		}
+		if s.Type == TypeInteger && f != math.Trunc(f) {
+			return "must be a whole number"
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Sprintf("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Sprintf("must be at most %v", *s.Maximum)
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return "must be true or false"
+		}
+	case TypeArray:
+		choices, ok := value.([]any)
+		if !ok {
+			return "must be a list"
+		}
+		if s.MinItems != nil && len(choices) < *s.MinItems {
+			return fmt.Sprintf("must have at least %d choices", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(choices) > *s.MaxItems {
+			return fmt.Sprintf("must have at most %d choices", *s.MaxItems)
+		}
+		for _, choice := range choices {
+			if problem := s.Items.problem(choice); problem != "" {
+				return "choices " + problem
+			}
+		}
+		return ""
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(option any) bool { return same(option, value) }) {
+		return "must be one of the form's options"
+	}
+	return ""
+}
+
+// same compares two decoded JSON scalars, numbers by value
+func same(a, b any) bool {
+	x, xNumber := a.(json.Number)
+	y, yNumber := b.(json.Number)
+	if xNumber && yNumber {
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	}
+	return a == b
+}
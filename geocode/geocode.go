@@ -0,0 +1,97 @@
+// Medical Appointment Booking System - Geocoding Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package geocode turns clinic addresses into coordinates with a Nominatim-compatible search
+// service, such as OpenStreetMap's or a self-hosted one.
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultUserAgent identifies the server to the geocoding service when GEOCODER_USER_AGENT is
+// not set; public Nominatim refuses requests without one
+const DefaultUserAgent = "bookings-server"
+
+// Geocoder looks addresses up with a Nominatim-style /search endpoint
+type Geocoder struct {
+	URL       string
+	UserAgent string
+	Client    *http.Client
+}
+
+// FromEnv reads GEOCODER_URL and GEOCODER_USER_AGENT. It returns nil when no URL is set,
+// which leaves geocoding off.
+func FromEnv() *Geocoder {
+	g := &Geocoder{
+		URL:       os.Getenv("GEOCODER_URL"),
+		UserAgent: os.Getenv("GEOCODER_USER_AGENT"),
+		Client:    &http.Client{Timeout: 5 * time.Second},
+	}
+	if g.URL == "" {
+		return nil
+	}
+	if g.UserAgent == "" {
+		g.UserAgent = DefaultUserAgent
+	}
+	return g
+}
+
+// Geocode returns the coordinates of the best match for an address; found is false when the
+// service knows no such place
+func (g *Geocoder) Geocode(ctx context.Context, address string) (latitude, longitude float64, found bool, err error) {
+	query := url.Values{"q": {address}, "format": {"jsonv2"}, "limit": {"1"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	req.Header.Set("User-Agent", g.UserAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return 0, 0, false, fmt.Errorf("geocoder answered %s", resp.Status)
+	}
+	// Nominatim gives coordinates as strings
+	var places []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return 0, 0, false, err
+	}
+	if len(places) == 0 {
+		return 0, 0, false, nil
+	}
+	if latitude, err = strconv.ParseFloat(places[0].Lat, 64); err != nil {
+		return 0, 0, false, fmt.Errorf("geocoder latitude %q: %w", places[0].Lat, err)
+	}
+	if longitude, err = strconv.ParseFloat(places[0].Lon, 64); err != nil {
+		return 0, 0, false, fmt.Errorf("geocoder longitude %q: %w", places[0].Lon, err)
+	}
+	return latitude, longitude, true, nil
+}
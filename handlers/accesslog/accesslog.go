@@ -0,0 +1,75 @@
+// Medical Appointment Booking System - Access Log Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package accesslog
+
+import (
+	"slices"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the access log endpoint under /access-log
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.GET("/access-log", auth.Authorize(auth.AccessLog), GetAccessEntries)
+}
+
+// GetAccessEntries lists reads of patient records and medical notes, latest first, optionally
+// filtered by patient_id, actor_user_id, resource and a from/to range (RFC 3339)
+func GetAccessEntries(c *gin.Context) {
+	var filter database.AccessFilter
+	var ok bool
+	if filter.PatientID, ok = handlers.OptionalIntQuery(c, "patient_id"); !ok {
+		return
+	}
+	if filter.ActorUserID, ok = handlers.OptionalIntQuery(c, "actor_user_id"); !ok {
+		return
+	}
+	if resource := c.Query("resource"); resource != "" {
+		if !slices.Contains(access.Resources, resource) {
+			c.Error(apierr.Validation("Invalid resource"))
+			return
+		}
+		filter.Resource = &resource
+	}
+	if filter.From, ok = handlers.OptionalTimeQuery(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = handlers.OptionalTimeQuery(c, "to"); !ok {
+		return
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		c.Error(apierr.Validation("to must be after from"))
+		return
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := database.GetAccessEntries(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, entries, total, page)
+}
@@ -0,0 +1,223 @@
+// Medical Appointment Booking System - API Key Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package apikeys
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts API key management under /admin/api-keys
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/admin/api-keys", auth.Authorize(auth.APIKeys))
+	{
+		group.GET("", GetAPIKeys)
+		group.GET("/:id", GetAPIKey)
+		group.POST("", CreateAPIKey)
+		group.PUT("/:id", UpdateAPIKey)
+		group.POST("/:id/rotate", RotateAPIKey)
+		group.DELETE("/:id", RevokeAPIKey)
+	}
+}
+
+// apiKeyRequest is the body of a key create or update. Scopes maps each route group the key
+// may use to "read" or "write"; groups left out are closed to it.
+type apiKeyRequest struct {
+	Name      string            `json:"name" binding:"required,max=100"`
+	Scopes    map[string]string `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time        `json:"expires_at"`
+}
+
+// bind reads an apiKeyRequest into key, writing a 400 and returning false when it is invalid
+func (req *apiKeyRequest) bind(c *gin.Context, key *models.APIKey) bool {
+	if !handlers.BindJSON(c, req) {
+		return false
+	}
+	groups := auth.ScopeGroups()
+	for group, scope := range req.Scopes {
+		if !slices.Contains(groups, group) {
+			c.Error(apierr.Validation("Unknown scope group: " + group).WithDetails(gin.H{"groups": groups}))
+			return false
+		}
+		if scope != auth.ScopeRead && scope != auth.ScopeWrite {
+			c.Error(apierr.Validation("Scope of " + group + " must be read or write"))
+			return false
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.Error(apierr.Validation("expires_at must be in the future"))
+		return false
+	}
+	key.Name, key.Scopes, key.ExpiresAt = req.Name, req.Scopes, req.ExpiresAt
+	return true
+}
+
+// GetAPIKeys lists the organization's keys, revoked ones included; the keys themselves are
+// never shown again after they are issued
+func GetAPIKeys(c *gin.Context) {
+	keys, err := database.GetAPIKeys(c.Request.Context(), auth.OrganizationID(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func GetAPIKey(c *gin.Context) {
+	key, ok := organizationKey(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// CreateAPIKey issues a key. The key is only ever returned here and by RotateAPIKey.
+func CreateAPIKey(c *gin.Context) {
+	key := models.APIKey{OrganizationID: auth.OrganizationID(c)}
+	var req apiKeyRequest
+	if !req.bind(c, &key) {
+		return
+	}
+	if id, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		key.CreatedBy = &id
+	}
+	secret, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	key.Prefix = prefix
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateAPIKey(ctx, &key, hash); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAPIKeys, key.ID, audit.ActionCreate, key)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	key.Key = secret
+	c.JSON(http.StatusCreated, key)
+}
+
+// UpdateAPIKey replaces a live key's name, scopes and expiry; the key itself is unchanged
+func UpdateAPIKey(c *gin.Context) {
+	key, ok := organizationKey(c)
+	if !ok {
+		return
+	}
+	var req apiKeyRequest
+	if !req.bind(c, key) {
+		return
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateAPIKey(ctx, key.ID, key); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAPIKeys, key.ID, audit.ActionUpdate, key)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "API key not found or revoked"))
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// RotateAPIKey issues a new key in place of a live one, keeping its name and scopes. The old
+// key stops working at once.
+func RotateAPIKey(c *gin.Context) {
+	current, ok := organizationKey(c)
+	if !ok {
+		return
+	}
+	secret, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var key *models.APIKey
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if key, err = database.RotateAPIKey(ctx, current.ID, prefix, hash); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAPIKeys, key.ID, audit.ActionUpdate, key)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "API key not found or revoked"))
+		return
+	}
+	key.Key = secret
+	c.JSON(http.StatusOK, key)
+}
+
+// RevokeAPIKey stops a key from working for good. It stays listed, so the audit and access
+// log entries made with it can still be traced to it.
+func RevokeAPIKey(c *gin.Context) {
+	key, ok := organizationKey(c)
+	if !ok {
+		return
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.RevokeAPIKey(ctx, key.ID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAPIKeys, key.ID, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "API key not found or already revoked"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// organizationKey loads the key named by the id path parameter, writing a 404 when the
+// caller's organization has no such key
+func organizationKey(c *gin.Context) (*models.APIKey, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return nil, false
+	}
+	key, err := database.GetAPIKey(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "API key not found"))
+		return nil, false
+	}
+	if key.OrganizationID != auth.OrganizationID(c) {
+		c.Error(apierr.NotFound("API key not found"))
+		return nil, false
+	}
+	return key, true
+}
@@ -0,0 +1,782 @@
+// Medical Appointment Booking System - Appointment Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package appointments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/hl7"
+	"bookings/models"
+	"bookings/noshow"
+	"bookings/notifications"
+	"bookings/payments"
+	"bookings/timeutil"
+	"bookings/waitlist"
+	"bookings/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the appointment CRUD endpoints. Booking checks, the day schedule and
+// rescheduling still read the database directly.
+type Handler struct {
+	appointments database.AppointmentRepo
+	patients     database.PatientRepo
+	clinics      database.ClinicRepo
+	sender       notifications.Sender
+	stripe       *payments.Stripe
+}
+
+// New returns a Handler that stores appointments in the given repository, reads patients
+// and clinics from theirs, notifies through sender and takes card payments through stripe,
+// which may be nil
+func New(repos database.Repos, sender notifications.Sender, stripe *payments.Stripe) *Handler {
+	return &Handler{appointments: repos.Appointments, patients: repos.Patients, clinics: repos.Clinics, sender: sender, stripe: stripe}
+}
+
+// RegisterRoutes mounts the appointment endpoints under /appointments
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	h := New(deps.Repos, deps.Sender, deps.Stripe)
+	group := r.Group("/appointments", auth.Authorize(auth.Appointments))
+	{
+		group.GET("", h.GetAppointments)
+		group.GET("/schedule", GetDaySchedule)
+		group.GET("/queue", h.GetQueue)
+		group.GET("/export", auth.RequireRole(auth.RoleAdmin), ExportAppointments)
+		group.GET("/:id", h.GetAppointment)
+		group.POST("", h.CreateAppointment)
+		group.PUT("/:id", h.UpdateAppointment)
+		group.PATCH("/:id", h.PatchAppointment)
+		group.DELETE("/:id", h.DeleteAppointment)
+		group.POST("/:id/cancel", h.CancelAppointment)
+		group.POST("/:id/check-in", h.CheckIn)
+		group.POST("/:id/check-out", h.CheckOut)
+		group.GET("/:id/notifications/plan", h.GetNotificationPlan)
+		group.GET("/:id/invoice.pdf", GetInvoice(deps.Invoices.TaxRate))
+		group.POST("/:id/reschedule", RescheduleAppointment(deps.Sender))
+		group.GET("/:id/reschedules", GetRescheduleHistory)
+		group.GET("/:id/resources", GetAppointmentResources)
+		group.PUT("/:id/resources", SetAppointmentResources)
+		group.POST("/series", CreateSeries(deps.Sender))
+		group.GET("/series/:series_id", GetSeries)
+		group.PUT("/series/:series_id", UpdateSeries(deps.Sender))
+		group.POST("/series/:series_id/cancel", CancelSeries(deps.Sender, deps.Stripe))
+	}
+}
+
+// GetAppointments lists appointments, latest first, optionally filtered by a from/to range
+// (RFC 3339; appointments overlapping it), employee_id, patient_id, clinic_id and status
+// (one or more, comma-separated). expand embeds the related records named in it; with tz
+// the times are given in that timezone rather than UTC.
+func (h *Handler) GetAppointments(c *gin.Context) {
+	filter, ok := parseFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	expand, ok := parseExpand(c)
+	if !ok {
+		return
+	}
+	loc, ok := handlers.OptionalLocationQuery(c, "tz")
+	if !ok {
+		return
+	}
+
+	if expand != (database.Expand{}) {
+		expanded, total, err := h.appointments.ListExpanded(c.Request.Context(), filter, page, expand)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		for i := range expanded {
+			inZone(&expanded[i].Appointment, loc)
+		}
+		recordExpandedAccess(c, expand, expanded...)
+		handlers.RespondPage(c, expanded, total, page)
+		return
+	}
+	appointments, total, err := h.appointments.List(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range appointments {
+		inZone(&appointments[i], loc)
+	}
+	access.MedicalNotes(c, appointments...)
+	handlers.RespondPage(c, appointments, total, page)
+}
+
+// inZone gives the appointment's start and end in loc, which changes their UTC offset but
+// not the instants; a nil loc leaves them in UTC
+func inZone(appointment *models.Appointment, loc *time.Location) {
+	if loc == nil {
+		return
+	}
+	appointment.StartDatetime = appointment.StartDatetime.In(loc)
+	appointment.EndDatetime = appointment.EndDatetime.In(loc)
+}
+
+// parseFilter reads the from, to, employee_id, patient_id, clinic_id and status query
+// parameters, writing a 400 when one is malformed
+func parseFilter(c *gin.Context) (database.AppointmentFilter, bool) {
+	var filter database.AppointmentFilter
+	var ok bool
+	if filter.From, ok = handlers.OptionalTimeQuery(c, "from"); !ok {
+		return filter, false
+	}
+	if filter.To, ok = handlers.OptionalTimeQuery(c, "to"); !ok {
+		return filter, false
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		c.Error(apierr.Validation("to must be after from"))
+		return filter, false
+	}
+	if filter.EmployeeID, ok = handlers.OptionalIntQuery(c, "employee_id"); !ok {
+		return filter, false
+	}
+	if filter.PatientID, ok = handlers.OptionalIntQuery(c, "patient_id"); !ok {
+		return filter, false
+	}
+	if filter.ClinicID, ok = handlers.OptionalIntQuery(c, "clinic_id"); !ok {
+		return filter, false
+	}
+	if raw := c.Query("status"); raw != "" {
+		for _, status := range strings.Split(raw, ",") {
+			if !slices.Contains(models.AppointmentStatuses, status) {
+				c.Error(apierr.Validation("Invalid status: " + status))
+				return filter, false
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+	return filter, true
+}
+
+// GetAppointment returns one appointment; expand embeds the related records named in it
+// and tz gives its times in that timezone
+func (h *Handler) GetAppointment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	expand, ok := parseExpand(c)
+	if !ok {
+		return
+	}
+	loc, ok := handlers.OptionalLocationQuery(c, "tz")
+	if !ok {
+		return
+	}
+
+	if expand != (database.Expand{}) {
+		expanded, err := h.appointments.GetExpanded(c.Request.Context(), id, expand)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Appointment not found"))
+			return
+		}
+		allergies, ok := criticalAllergies(c, expanded.PatientID)
+		if !ok {
+			return
+		}
+		inZone(&expanded.Appointment, loc)
+		recordExpandedAccess(c, expand, *expanded)
+		handlers.SetETag(c, expanded.Version)
+		c.JSON(http.StatusOK, expandedDetail{ExpandedAppointment: *expanded, CriticalAllergies: allergies})
+		return
+	}
+	appointment, err := h.appointments.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	allergies, ok := criticalAllergies(c, appointment.PatientID)
+	if !ok {
+		return
+	}
+	inZone(appointment, loc)
+	access.MedicalNotes(c, *appointment)
+	handlers.SetETag(c, appointment.Version)
+	c.JSON(http.StatusOK, detail{Appointment: *appointment, CriticalAllergies: allergies})
+}
+
+// detail is an appointment as GET /appointments/:id returns it, with the patient's critical
+// allergies so whoever sees the patient is warned of them; they are null for an API key
+// not scoped to the chart
+type detail struct {
+	models.Appointment
+	CriticalAllergies []models.Allergy `json:"critical_allergies"`
+}
+
+// expandedDetail is detail with the related records asked for in expand
+type expandedDetail struct {
+	database.ExpandedAppointment
+	CriticalAllergies []models.Allergy `json:"critical_allergies"`
+}
+
+// criticalAllergies loads the patient's ACTIVE allergies of HIGH criticality, recording the
+// read in the access log when there are any. It returns nil when the caller may not read the
+// patient chart.
+func criticalAllergies(c *gin.Context, patientID int) ([]models.Allergy, bool) {
+	if !auth.CanRead(c, auth.Chart) {
+		return nil, true
+	}
+	allergies, err := database.ListCriticalAllergies(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	if len(allergies) > 0 {
+		access.Chart(c, patientID)
+	}
+	return allergies, true
+}
+
+// staleAppointment explains a 412 on an appointment update
+const staleAppointment = "Appointment was changed by someone else; reload it and try again"
+
+// errResponded aborts a transaction whose handler has already written the error response
+var errResponded = errors.New("error response written")
+
+// CreateAppointment books an appointment. With a hold_token the booking converts that live slot
+// hold: fields left out are taken from the hold and the hold is consumed in the same transaction.
+// A new patient may be registered with the booking by giving patient in place of patient_id;
+// both are created or neither is. The patient and employee are sent a confirmation email.
+// When card payments are configured and the booking has a payment_amount, the response
+// carries the Stripe checkout for it under payment.
+func (h *Handler) CreateAppointment(c *gin.Context) {
+	var req struct {
+		models.Appointment
+		HoldToken string          `json:"hold_token"`
+		Patient   *models.Patient `json:"patient"`
+	}
+	if !handlers.DecodeJSON(c, &req) {
+		return
+	}
+	appointment := req.Appointment
+	appointment.SeriesID = nil // occurrences are only booked through the series endpoints
+	if !checkMedicalNotes(c, nil, appointment.MedicalNotes) {
+		return
+	}
+	if req.Patient != nil {
+		if appointment.PatientID != 0 {
+			c.Error(apierr.Validation("Give either patient_id or patient, not both"))
+			return
+		}
+		if !handlers.Validate(c, req.Patient) {
+			return
+		}
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if req.Patient != nil {
+			// A generated medical record number takes the booking clinic's prefix
+			if req.Patient.ClinicID == nil && appointment.ClinicID != 0 {
+				clinicID := appointment.ClinicID
+				req.Patient.ClinicID = &clinicID
+			}
+			if err := h.patients.Create(ctx, req.Patient); err != nil {
+				if errors.Is(err, database.ErrMRNTaken) {
+					c.Error(apierr.Conflict("Medical record number already belongs to a patient"))
+					return errResponded
+				}
+				return err
+			}
+			if err := audit.Record(ctx, audit.EntityPatients, req.Patient.ID, audit.ActionCreate, req.Patient); err != nil {
+				return err
+			}
+			if err := webhooks.Publish(ctx, webhooks.EventPatientCreated, webhooks.PatientData(req.Patient)); err != nil {
+				return err
+			}
+			appointment.PatientID = req.Patient.ID
+		}
+
+		if req.HoldToken != "" {
+			hold, err := database.GetLiveSlotHold(ctx, req.HoldToken)
+			if err != nil {
+				return err
+			}
+			if err := database.ApplySlotHold(&appointment, hold); err != nil {
+				c.Error(apierr.Unprocessable(err.Error()))
+				return errResponded
+			}
+		}
+		if err := database.LockEmployeeSchedule(ctx, appointment.EmployeeID); err != nil {
+			return err
+		}
+		if !handlers.Validate(c, &appointment) || !validateBooking(ctx, c, &appointment, 0, req.HoldToken) {
+			return errResponded
+		}
+
+		var err error
+		if req.HoldToken != "" {
+			err = h.appointments.CreateFromHold(ctx, &appointment, req.HoldToken)
+		} else {
+			err = h.appointments.Create(ctx, &appointment)
+		}
+		if err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityAppointments, appointment.ID, audit.ActionCreate, appointment); err != nil {
+			return err
+		}
+		return webhooks.Publish(ctx, webhooks.EventAppointmentCreated, webhooks.AppointmentData(&appointment))
+	})
+	switch {
+	case errors.Is(err, errResponded):
+		return
+	case errors.Is(err, database.ErrHoldNotFound):
+		c.Error(apierr.Gone("Slot hold not found or expired"))
+		return
+	case errors.Is(err, database.ErrAppointmentConflict):
+		writeConflict(c, &appointment, 0)
+		return
+	case resourceTaken(err):
+		c.Error(resourceConflict(err))
+		return
+	case err != nil:
+		c.Error(err)
+		return
+	}
+
+	hl7.Publish(c.Request.Context(), hl7.TriggerBooked, &appointment)
+	notifications.SendAppointmentEmails(c.Request.Context(), h.sender, notifications.EventBooked, &appointment)
+	handlers.SetETag(c, appointment.Version)
+	c.JSON(http.StatusCreated, bookingResponse{Appointment: appointment, Payment: h.stripe.CheckoutForBooking(c.Request.Context(), &appointment)})
+}
+
+// bookingResponse is a new appointment with the checkout for paying it
+type bookingResponse struct {
+	models.Appointment
+	Payment *payments.Checkout `json:"payment,omitempty"`
+}
+
+// UpdateAppointment replaces an appointment and emails the patient and employee the new
+// details. If-Match must carry the ETag of the version being replaced; a stale one is
+// refused with a 412. Cancelling a scheduled or confirmed booking this way offers its slot
+// to the waiting list and refunds its card payment if it was cancelled in time.
+func (h *Handler) UpdateAppointment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	version, ok := handlers.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	var appointment models.Appointment
+	if !handlers.BindJSON(c, &appointment) {
+		return
+	}
+	existing, ok := h.currentAppointment(c, id, version)
+	if !ok {
+		return
+	}
+	h.saveAppointment(c, id, version, existing, &appointment)
+}
+
+// PatchAppointment updates only the fields given in the body, a JSON merge patch, and
+// otherwise behaves as UpdateAppointment
+func (h *Handler) PatchAppointment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	version, ok := handlers.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	existing, ok := h.currentAppointment(c, id, version)
+	if !ok {
+		return
+	}
+	var appointment models.Appointment
+	if !handlers.MergeJSON(c, existing, &appointment) {
+		return
+	}
+	h.saveAppointment(c, id, version, existing, &appointment)
+}
+
+// currentAppointment loads the appointment an update replaces, refusing it with a 412 unless
+// it is still at version
+func (h *Handler) currentAppointment(c *gin.Context, id, version int) (*models.Appointment, bool) {
+	existing, err := h.appointments.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return nil, false
+	}
+	if existing.Version != version {
+		c.Error(apierr.PreconditionFailed(staleAppointment))
+		return nil, false
+	}
+	return existing, true
+}
+
+// saveAppointment stores appointment over existing, read at version, and sends everything an
+// update of the booking is announced with
+func (h *Handler) saveAppointment(c *gin.Context, id, version int, existing, appointment *models.Appointment) {
+	if !checkMedicalNotes(c, existing.MedicalNotes, appointment.MedicalNotes) {
+		return
+	}
+
+	cancelled := appointment.Status == "CANCELLED" && (existing.Status == "SCHEDULED" || existing.Status == "CONFIRMED")
+	var updated *models.Appointment
+	// A move is checked and saved under the employee's schedule lock, so a booking made at
+	// the same time cannot take the slot in between
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if bookingMoved(existing, appointment) {
+			if err := database.LockEmployeeSchedule(ctx, appointment.EmployeeID); err != nil {
+				return err
+			}
+			if !validateBooking(ctx, c, appointment, id, "") {
+				return errResponded
+			}
+		}
+		appointment.Version = version
+		if err := h.appointments.Update(ctx, id, appointment); err != nil {
+			return err
+		}
+		var err error
+		if updated, err = h.appointments.Get(ctx, id); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityAppointments, id, audit.ActionUpdate, updated); err != nil {
+			return err
+		}
+		if cancelled {
+			return webhooks.Publish(ctx, webhooks.EventAppointmentCancelled, webhooks.AppointmentData(updated))
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errResponded) {
+			return
+		}
+		if errors.Is(err, database.ErrAppointmentConflict) {
+			writeConflict(c, appointment, id)
+			return
+		}
+		if resourceTaken(err) {
+			c.Error(resourceConflict(err))
+			return
+		}
+		if errors.Is(err, database.ErrStaleVersion) {
+			c.Error(apierr.PreconditionFailed(staleAppointment))
+			return
+		}
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	event := notifications.EventUpdated
+	if cancelled {
+		event = notifications.EventCancelled
+		hl7.Publish(c.Request.Context(), hl7.TriggerCancelled, updated)
+	} else if updated.Status != "CANCELLED" && bookingMoved(existing, updated) {
+		hl7.Publish(c.Request.Context(), hl7.TriggerRescheduled, updated)
+	}
+	notifications.SendAppointmentEmails(c.Request.Context(), h.sender, event, updated)
+	if cancelled {
+		waitlist.FillAfterCancellation(c.Request.Context(), h.sender, existing)
+		h.stripe.RefundAfterCancellation(c.Request.Context(), existing)
+	}
+	handlers.SetETag(c, appointment.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Appointment updated successfully"})
+}
+
+// CancelAppointment cancels one scheduled or confirmed appointment with an optional reason;
+// for an occurrence of a series the rest of the series stays booked. As with a cancellation
+// through UpdateAppointment, the patient and employee are emailed, the slot is offered to the
+// waiting list and a card payment is refunded if it was cancelled in time.
+func (h *Handler) CancelAppointment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	var req struct {
+		Reason *string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 && !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	existing, err := h.appointments.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	if existing.Status != "SCHEDULED" && existing.Status != "CONFIRMED" {
+		c.Error(apierr.Unprocessable("Only scheduled or confirmed appointments can be cancelled"))
+		return
+	}
+
+	cancelled := *existing
+	cancelled.Status = "CANCELLED"
+	cancelled.CancellationReason = req.Reason
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.appointments.Update(ctx, id, &cancelled); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityAppointments, id, audit.ActionUpdate, cancelled); err != nil {
+			return err
+		}
+		return webhooks.Publish(ctx, webhooks.EventAppointmentCancelled, webhooks.AppointmentData(&cancelled))
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrStaleVersion) {
+			c.Error(apierr.PreconditionFailed(staleAppointment))
+			return
+		}
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	hl7.Publish(c.Request.Context(), hl7.TriggerCancelled, &cancelled)
+	notifications.SendAppointmentEmails(c.Request.Context(), h.sender, notifications.EventCancelled, &cancelled)
+	waitlist.FillAfterCancellation(c.Request.Context(), h.sender, existing)
+	h.stripe.RefundAfterCancellation(c.Request.Context(), existing)
+	handlers.SetETag(c, cancelled.Version)
+	c.JSON(http.StatusOK, cancelled)
+}
+
+func (h *Handler) DeleteAppointment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.appointments.Delete(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAppointments, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrAppointmentHasVisitNote) {
+			c.Error(apierr.Conflict(err.Error()))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Appointment deleted successfully"})
+}
+
+// GetNotificationPlan shows which reminders and escalations an appointment will get,
+// and why any of them will be skipped
+func (h *Handler) GetNotificationPlan(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	appointment, err := h.appointments.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	patient, err := h.patients.Get(c.Request.Context(), appointment.PatientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	clinic, err := h.clinics.Get(c.Request.Context(), appointment.ClinicID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	employee, err := database.GetEmployee(c.Request.Context(), appointment.EmployeeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	prefs, err := database.GetNotificationPreferences(c.Request.Context(), appointment.PatientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	highRisk := false
+	if isUpcoming(appointment) {
+		risk, err := newRiskAssessor().assess(c.Request.Context(), appointment)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		highRisk = risk.Level == noshow.LevelHigh
+	}
+
+	c.JSON(http.StatusOK, notifications.BuildPlan(appointment, patient, prefs, clinic, employee.Timezone, highRisk, time.Now()))
+}
+
+// scheduleEntry is an appointment in the day schedule, with its no-show risk when it is still upcoming.
+// Overnight and multi-day bookings appear on every day they touch; Segment is the part that falls
+// on the requested day, and the Continues flags tell the calendar to draw it as a continuation.
+type scheduleEntry struct {
+	models.Appointment
+	Segment                  availability.Interval `json:"segment"`
+	ContinuesFromPreviousDay bool                  `json:"continues_from_previous_day"`
+	ContinuesToNextDay       bool                  `json:"continues_to_next_day"`
+	NoShowRisk               *noshow.Risk          `json:"no_show_risk"`
+}
+
+// GetDaySchedule lists a day's appointments (optionally for one clinic or employee)
+// together with a no-show risk score for each upcoming booking. The day is taken in the
+// tz query parameter, else the employee's timezone when employee_id is given, else the
+// clinic's when clinic_id is, else UTC. Times are given in it.
+func GetDaySchedule(c *gin.Context) {
+	day, err := timeutil.ParseDate(c.Query("date"))
+	if err != nil {
+		c.Error(apierr.Validation("date must be given as YYYY-MM-DD"))
+		return
+	}
+	clinicID, ok := handlers.OptionalIntQuery(c, "clinic_id")
+	if !ok {
+		return
+	}
+	employeeID, ok := handlers.OptionalIntQuery(c, "employee_id")
+	if !ok {
+		return
+	}
+
+	loc, ok := handlers.OptionalLocationQuery(c, "tz")
+	if !ok {
+		return
+	}
+	switch {
+	case loc != nil:
+	case employeeID != nil:
+		employee, err := database.GetEmployee(c.Request.Context(), *employeeID)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Employee not found"))
+			return
+		}
+		loc = availability.Location(employee)
+	case clinicID != nil:
+		timezone, err := database.GetClinicTimezone(c.Request.Context(), *clinicID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		loc = timeutil.LoadLocation(timezone)
+	default:
+		loc = time.UTC
+	}
+	dayStart, dayEnd := timeutil.DayBounds(day, loc)
+
+	appointments, err := database.GetAppointmentsForDay(c.Request.Context(), dayStart, dayEnd, clinicID, employeeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	assessor := newRiskAssessor()
+	schedule := make([]scheduleEntry, 0, len(appointments))
+	for i := range appointments {
+		entry := scheduleEntry{
+			Appointment:              appointments[i],
+			Segment:                  availability.Interval{Start: appointments[i].StartDatetime.In(loc), End: appointments[i].EndDatetime.In(loc)},
+			ContinuesFromPreviousDay: appointments[i].StartDatetime.Before(dayStart),
+			ContinuesToNextDay:       appointments[i].EndDatetime.After(dayEnd),
+		}
+		if entry.ContinuesFromPreviousDay {
+			entry.Segment.Start = dayStart.In(loc)
+		}
+		if entry.ContinuesToNextDay {
+			entry.Segment.End = dayEnd.In(loc)
+		}
+		inZone(&entry.Appointment, loc)
+		if isUpcoming(&appointments[i]) {
+			risk, err := assessor.assess(c.Request.Context(), &appointments[i])
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			entry.NoShowRisk = &risk
+		}
+		schedule = append(schedule, entry)
+	}
+	access.MedicalNotes(c, appointments...)
+	c.JSON(http.StatusOK, schedule)
+}
+
+func isUpcoming(appointment *models.Appointment) bool {
+	return (appointment.Status == "SCHEDULED" || appointment.Status == "CONFIRMED") &&
+		appointment.StartDatetime.After(time.Now())
+}
+
+// riskAssessor scores appointments, caching employee timezones across a schedule
+type riskAssessor struct {
+	locations map[int]*time.Location
+}
+
+func newRiskAssessor() *riskAssessor {
+	return &riskAssessor{locations: map[int]*time.Location{}}
+}
+
+func (a *riskAssessor) assess(ctx context.Context, appointment *models.Appointment) (noshow.Risk, error) {
+	attended, noShows, err := database.GetPatientAttendance(ctx, appointment.PatientID, appointment.StartDatetime)
+	if err != nil {
+		return noshow.Risk{}, err
+	}
+
+	loc, ok := a.locations[appointment.EmployeeID]
+	if !ok {
+		employee, err := database.GetEmployee(ctx, appointment.EmployeeID)
+		if err != nil {
+			return noshow.Risk{}, err
+		}
+		loc = timeutil.LoadLocation(employee.Timezone)
+		a.locations[appointment.EmployeeID] = loc
+	}
+
+	channel := ""
+	if appointment.BookingChannel != nil {
+		channel = *appointment.BookingChannel
+	}
+	return noshow.Score(noshow.Input{
+		PriorAttended:  attended,
+		PriorNoShows:   noShows,
+		LeadTime:       appointment.StartDatetime.Sub(appointment.CreatedAt),
+		LocalHour:      appointment.StartDatetime.In(loc).Hour(),
+		BookingChannel: channel,
+	}), nil
+}
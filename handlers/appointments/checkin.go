@@ -0,0 +1,212 @@
+// Medical Appointment Booking System - Appointment Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package appointments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/live"
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPublicRoutes mounts the kiosk check-in, which patients use without logging in;
+// the signed code from their confirmation email is the credential
+func RegisterPublicRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.POST("/check-in/qr", KioskCheckIn)
+}
+
+// errNotCheckInable refuses a check-in for an appointment that is not expected
+var errNotCheckInable = apierr.Unprocessable("Only scheduled or confirmed appointments can be checked in")
+
+// CheckIn records that the patient of a scheduled or confirmed appointment has arrived,
+// which puts them in the clinic's queue and keeps the appointment from being marked a
+// no-show
+func (h *Handler) CheckIn(c *gin.Context) {
+	h.stamp(c, func(ctx context.Context, existing *models.Appointment) (*models.Appointment, error) {
+		if existing.Status != "SCHEDULED" && existing.Status != "CONFIRMED" {
+			return nil, errNotCheckInable
+		}
+		if existing.CheckedInAt != nil {
+			return nil, apierr.Unprocessable("Appointment is already checked in")
+		}
+		return database.CheckInAppointment(ctx, existing.ID, existing.Version, time.Now())
+	})
+}
+
+// CheckOut completes an appointment that is in progress, or whose patient has checked in,
+// recording when the patient left
+func (h *Handler) CheckOut(c *gin.Context) {
+	h.stamp(c, func(ctx context.Context, existing *models.Appointment) (*models.Appointment, error) {
+		inProgress := existing.Status == "IN_PROGRESS"
+		checkedIn := existing.CheckedInAt != nil && (existing.Status == "SCHEDULED" || existing.Status == "CONFIRMED")
+		if !inProgress && !checkedIn {
+			return nil, apierr.Unprocessable("Only checked-in or in-progress appointments can be checked out")
+		}
+		return database.CheckOutAppointment(ctx, existing.ID, existing.Version, time.Now())
+	})
+}
+
+// stamp loads the appointment named in the path, applies a check-in or check-out to it and
+// responds with the result
+func (h *Handler) stamp(c *gin.Context, apply func(ctx context.Context, existing *models.Appointment) (*models.Appointment, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	existing, err := h.appointments.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	var appointment *models.Appointment
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if appointment, err = apply(ctx, existing); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAppointments, id, audit.ActionUpdate, appointment)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrStaleVersion) {
+			c.Error(apierr.PreconditionFailed(staleAppointment))
+			return
+		}
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	access.MedicalNotes(c, *appointment)
+	handlers.SetETag(c, appointment.Version)
+	c.JSON(http.StatusOK, appointment)
+}
+
+// GetQueue returns a clinic's live queue for the current day: the checked-in patients
+// waiting, in order of appointment time and then of arrival, those being seen and the day's
+// wait-time metrics. clinic_id is required.
+func (h *Handler) GetQueue(c *gin.Context) {
+	clinicID, ok := handlers.OptionalIntQuery(c, "clinic_id")
+	if !ok {
+		return
+	}
+	if clinicID == nil {
+		c.Error(apierr.Validation("clinic_id is required"))
+		return
+	}
+	if _, err := h.clinics.Get(c.Request.Context(), *clinicID); err != nil {
+		c.Error(apierr.Lookup(err, "Clinic not found"))
+		return
+	}
+
+	queue, err := live.BuildQueue(c.Request.Context(), *clinicID, time.Now())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, queue)
+}
+
+// kioskCheckInView is what the kiosk shows a patient who has checked in
+type kioskCheckInView struct {
+	PatientFirstName string    `json:"patient_first_name"`
+	EmployeeName     string    `json:"employee_name"`
+	StartDatetime    time.Time `json:"start_datetime"`
+	Timezone         string    `json:"timezone"`
+	CheckedInAt      time.Time `json:"checked_in_at"`
+}
+
+// KioskCheckIn checks a patient in with the QR code from their confirmation email. The
+// code must be validly signed and for a scheduled or confirmed appointment today, in the
+// employee's timezone. Scanning it again after checking in answers the same as the
+// first time.
+func KioskCheckIn(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+	publicID, err := auth.ParseSignedToken(auth.PurposeCheckIn, req.Token)
+	if err != nil {
+		c.Error(apierr.Unauthorized("Invalid check-in code"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	appointment, err := database.GetAppointmentByPublicID(ctx, publicID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	employee, err := database.GetEmployee(ctx, appointment.EmployeeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	patient, err := database.GetPatient(ctx, appointment.PatientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if appointment.Status != "SCHEDULED" && appointment.Status != "CONFIRMED" {
+		c.Error(errNotCheckInable)
+		return
+	}
+	now := time.Now()
+	loc := timeutil.LoadLocation(employee.Timezone)
+	if !timeutil.LocalDate(appointment.StartDatetime, loc).Equal(timeutil.LocalDate(now, loc)) {
+		c.Error(apierr.Unprocessable("This appointment is not today"))
+		return
+	}
+
+	if appointment.CheckedInAt == nil {
+		err = database.WithTx(ctx, func(ctx context.Context) error {
+			if appointment, err = database.CheckInAppointment(ctx, appointment.ID, appointment.Version, now); err != nil {
+				return err
+			}
+			return audit.Record(ctx, audit.EntityAppointments, appointment.ID, audit.ActionUpdate, appointment)
+		})
+		if err != nil {
+			if errors.Is(err, database.ErrStaleVersion) {
+				c.Error(apierr.PreconditionFailed(staleAppointment))
+				return
+			}
+			c.Error(apierr.Lookup(err, "Appointment not found"))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, kioskCheckInView{
+		PatientFirstName: patient.FirstName,
+		EmployeeName:     employee.FirstName + " " + employee.LastName,
+		StartDatetime:    appointment.StartDatetime.In(loc),
+		Timezone:         loc.String(),
+		CheckedInAt:      appointment.CheckedInAt.In(loc),
+	})
+}
@@ -0,0 +1,72 @@
+// Medical Appointment Booking System - Appointment Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package appointments
+
+import (
+	"strings"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/database"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Expansions are the related records ?expand= can embed in appointment responses
+var Expansions = []string{"patient", "employee", "service", "clinic"}
+
+// parseExpand reads the comma-separated expand query parameter, writing a 400 when it names
+// something that cannot be expanded
+func parseExpand(c *gin.Context) (database.Expand, bool) {
+	var expand database.Expand
+	raw := c.Query("expand")
+	if raw == "" {
+		return expand, true
+	}
+	for _, name := range strings.Split(raw, ",") {
+		switch strings.TrimSpace(name) {
+		case "patient":
+			expand.Patient = true
+		case "employee":
+			expand.Employee = true
+		case "service":
+			expand.Service = true
+		case "clinic":
+			expand.Clinic = true
+		default:
+			c.Error(apierr.Validation("expand takes " + strings.Join(Expansions, ", ")))
+			return expand, false
+		}
+	}
+	return expand, true
+}
+
+// recordExpandedAccess logs the reads of medical notes and, when patients were embedded,
+// of patient records
+func recordExpandedAccess(c *gin.Context, expand database.Expand, expanded ...database.ExpandedAppointment) {
+	appointments := make([]models.Appointment, 0, len(expanded))
+	var patientIDs []int
+	for _, a := range expanded {
+		appointments = append(appointments, a.Appointment)
+		if expand.Patient {
+			patientIDs = append(patientIDs, a.PatientID)
+		}
+	}
+	access.MedicalNotes(c, appointments...)
+	access.Patients(c, patientIDs...)
+}
@@ -0,0 +1,56 @@
+// Medical Appointment Booking System - Appointment Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package appointments
+
+import (
+	"net/http"
+	"strconv"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/database"
+	"bookings/invoice"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInvoice renders an appointment's invoice as a PDF, or its receipt once it has been
+// paid. taxRate is the percentage of tax included in the price. The patient's details are
+// on it, so the read is logged.
+func GetInvoice(taxRate float64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid ID"))
+			return
+		}
+		appointment, err := database.GetAppointment(c.Request.Context(), id)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Appointment not found"))
+			return
+		}
+
+		inv, err := invoice.Load(c.Request.Context(), appointment, taxRate)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		access.Patients(c, appointment.PatientID)
+		c.Header("Content-Disposition", `inline; filename="`+inv.Filename()+`"`)
+		c.Data(http.StatusOK, "application/pdf", inv.PDF())
+	}
+}
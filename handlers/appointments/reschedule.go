@@ -0,0 +1,190 @@
+// Medical Appointment Booking System - Appointment Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package appointments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/hl7"
+	"bookings/models"
+	"bookings/notifications"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rescheduleRequest moves an appointment; the length of the booking is kept and employee_id
+// defaults to the current employee
+type rescheduleRequest struct {
+	StartDatetime time.Time `json:"start_datetime" binding:"required"`
+	EmployeeID    *int      `json:"employee_id"`
+	Reason        *string   `json:"reason"`
+	Notify        bool      `json:"notify"`
+}
+
+// RescheduleAppointment moves a scheduled or confirmed appointment to a new start time (and
+// optionally another employee) after the same checks as a new booking. Everything else on the
+// appointment is left as it is, the old slot is kept in the reschedule history, and with
+// notify set the patient is told about the new time.
+func RescheduleAppointment(sender notifications.Sender) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid ID"))
+			return
+		}
+
+		var req rescheduleRequest
+		if !handlers.BindJSON(c, &req) {
+			return
+		}
+
+		existing, err := database.GetAppointment(c.Request.Context(), id)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Appointment not found"))
+			return
+		}
+		if existing.Status != "SCHEDULED" && existing.Status != "CONFIRMED" {
+			c.Error(apierr.Unprocessable("Only scheduled or confirmed appointments can be rescheduled"))
+			return
+		}
+
+		moved := *existing
+		moved.StartDatetime = req.StartDatetime
+		moved.EndDatetime = req.StartDatetime.Add(existing.EndDatetime.Sub(existing.StartDatetime))
+		if req.EmployeeID != nil {
+			moved.EmployeeID = *req.EmployeeID
+		}
+		if !bookingMoved(existing, &moved) {
+			c.Error(apierr.Unprocessable("The appointment is already booked at this time"))
+			return
+		}
+
+		var userID *int
+		if uid, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+			userID = &uid
+		}
+		var employee *models.Employee
+		var updated *models.Appointment
+		// The new time is checked and taken under the employee's schedule lock
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			if err := database.LockEmployeeSchedule(ctx, moved.EmployeeID); err != nil {
+				return err
+			}
+			if !validateBooking(ctx, c, &moved, id, "") {
+				return errResponded
+			}
+
+			var err error
+			if employee, err = database.GetEmployee(ctx, moved.EmployeeID); err != nil {
+				c.Error(apierr.Validation("Employee not found"))
+				return errResponded
+			}
+			working, err := availability.WithinWorkingHours(ctx, employee, moved.StartDatetime, moved.EndDatetime)
+			if err != nil {
+				return err
+			}
+			if !working {
+				c.Error(apierr.Unprocessable("New time is outside the employee's working hours"))
+				return errResponded
+			}
+
+			updated, err = database.RescheduleAppointment(ctx, id, moved.StartDatetime, moved.EndDatetime, moved.EmployeeID, req.Reason, userID)
+			if err != nil {
+				return err
+			}
+			return audit.Record(ctx, audit.EntityAppointments, id, audit.ActionUpdate, updated)
+		})
+		switch {
+		case errors.Is(err, errResponded):
+			return
+		case errors.Is(err, database.ErrAppointmentConflict):
+			writeConflict(c, &moved, id)
+			return
+		case resourceTaken(err):
+			c.Error(resourceConflict(err))
+			return
+		case errors.Is(err, database.ErrNotReschedulable):
+			c.Error(apierr.Unprocessable("Only scheduled or confirmed appointments can be rescheduled"))
+			return
+		case err != nil:
+			c.Error(err)
+			return
+		}
+		hl7.Publish(c.Request.Context(), hl7.TriggerRescheduled, updated)
+
+		if req.Notify {
+			notifyRescheduled(c.Request.Context(), sender, existing, updated, employee.Timezone)
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// notifyRescheduled tells the patient about the new time. Failures are logged rather than
+// returned, since the appointment has already moved.
+func notifyRescheduled(ctx context.Context, sender notifications.Sender, before, after *models.Appointment, timezone string) {
+	patient, err := database.GetPatient(ctx, after.PatientID)
+	if err != nil {
+		slog.ErrorContext(ctx, "reschedule: loading patient", "appointment_id", after.ID, "error", err)
+		return
+	}
+	prefs, err := database.GetNotificationPreferences(ctx, after.PatientID)
+	if err != nil {
+		slog.ErrorContext(ctx, "reschedule: loading notification preferences", "appointment_id", after.ID, "error", err)
+		return
+	}
+	body := fmt.Sprintf("Your appointment at %s has been moved to %s.",
+		timeutil.FormatIn(before.StartDatetime, timezone), timeutil.FormatIn(after.StartDatetime, timezone))
+	for _, msg := range notifications.PatientMessages(ctx, patient, prefs, notifications.TemplateRescheduled, "Appointment rescheduled", body) {
+		msg.AppointmentID = &after.ID
+		if err := sender.Send(ctx, msg); err != nil {
+			slog.ErrorContext(ctx, "reschedule: notifying patient", "patient_id", patient.ID, "error", err)
+		}
+	}
+}
+
+// GetRescheduleHistory lists the times an appointment has been moved, oldest first
+func GetRescheduleHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	if _, err := database.GetAppointment(c.Request.Context(), id); err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	history, err := database.GetAppointmentReschedules(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
@@ -0,0 +1,102 @@
+// Medical Appointment Booking System - Appointment Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package appointments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAppointmentResources lists the rooms and equipment an appointment has been given
+func GetAppointmentResources(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	if _, err := database.GetAppointment(c.Request.Context(), id); err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	resources, err := database.GetAppointmentResources(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resources)
+}
+
+// SetAppointmentResources replaces the resources picked for an appointment with the given
+// ones, e.g. to move it to another room. They must be active, at the appointment's clinic and
+// free for its time, buffers included; an empty list releases them all.
+func SetAppointmentResources(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	var req struct {
+		ResourceIDs []int `json:"resource_ids" binding:"required"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	appointment, err := database.GetAppointment(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	var resources []models.Resource
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.SetAppointmentResources(ctx, appointment, req.ResourceIDs); err != nil {
+			return err
+		}
+		var err error
+		if resources, err = database.GetAppointmentResources(ctx, id); err != nil {
+			return err
+		}
+		resourceIDs := make([]int, 0, len(resources))
+		for _, r := range resources {
+			resourceIDs = append(resourceIDs, r.ID)
+		}
+		return audit.Record(ctx, audit.EntityAppointmentResources, id, audit.ActionUpdate, gin.H{"resource_ids": resourceIDs})
+	})
+	switch {
+	case errors.Is(err, database.ErrResourceNotUsable):
+		c.Error(apierr.Unprocessable("Resources must be active and at the appointment's clinic"))
+		return
+	case resourceTaken(err):
+		c.Error(resourceConflict(err))
+		return
+	case err != nil:
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resources)
+}
@@ -0,0 +1,478 @@
+// Medical Appointment Booking System - Appointment Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package appointments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/hl7"
+	"bookings/models"
+	"bookings/money"
+	"bookings/notifications"
+	"bookings/payments"
+	"bookings/recurrence"
+	"bookings/timeutil"
+	"bookings/waitlist"
+	"bookings/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// seriesRequest books a recurring series. Every occurrence lasts the service's duration and
+// starts at start_datetime's wall-clock time in the employee's timezone; payment_amount, when
+// given, is charged per occurrence.
+type seriesRequest struct {
+	PatientID       int          `json:"patient_id" binding:"required"`
+	EmployeeID      int          `json:"employee_id" binding:"required"`
+	ServiceID       int          `json:"service_id" binding:"required"`
+	ClinicID        int          `json:"clinic_id" binding:"required"`
+	StartDatetime   time.Time    `json:"start_datetime" binding:"required"`
+	Recurrence      string       `json:"recurrence" binding:"required"`
+	AppointmentType *string      `json:"appointment_type" binding:"omitnil,enum=appointment_type"`
+	BookingChannel  *string      `json:"booking_channel" binding:"omitnil,enum=booking_channel"`
+	Notes           *string      `json:"notes"`
+	PaymentAmount   *money.Money `json:"payment_amount" binding:"omitnil"`
+	// SkipConflicts books the occurrences that pass the booking rules and reports the others,
+	// instead of refusing the whole series
+	SkipConflicts bool `json:"skip_conflicts"`
+}
+
+// occurrenceProblem is an occurrence of a series that cannot be booked or moved, and why
+type occurrenceProblem struct {
+	AppointmentID *int      `json:"appointment_id,omitempty"`
+	StartDatetime time.Time `json:"start_datetime"`
+	Message       string    `json:"message"`
+	Details       any       `json:"details,omitempty"`
+
+	status int // of the error the occurrence failed with
+}
+
+// seriesResponse is a series with the occurrences that were left out of it
+type seriesResponse struct {
+	*models.AppointmentSeries
+	Skipped []occurrenceProblem `json:"skipped,omitempty"`
+}
+
+// checkOccurrence applies the booking rules to one occurrence. A broken rule is returned as
+// a problem; only failed lookups are returned as errors.
+func checkOccurrence(ctx context.Context, appointment *models.Appointment, excludeID int) (*occurrenceProblem, error) {
+	err := checkBooking(ctx, appointment, excludeID, "")
+	var apiErr *apierr.Error
+	if !errors.As(err, &apiErr) {
+		return nil, err
+	}
+	problem := &occurrenceProblem{StartDatetime: appointment.StartDatetime, Message: apiErr.Message, Details: apiErr.Details, status: apiErr.Status}
+	if excludeID != 0 {
+		problem.AppointmentID = &excludeID
+	}
+	return problem, nil
+}
+
+// problemsError refuses a series change over its problem occurrences: a 409 when any of them
+// clashes with another booking, else a 422
+func problemsError(problems []occurrenceProblem, total int) *apierr.Error {
+	message := fmt.Sprintf("%d of %d occurrences cannot be booked", len(problems), total)
+	for _, p := range problems {
+		if p.status == http.StatusConflict {
+			return apierr.Conflict(message).WithDetails(gin.H{"occurrences": problems})
+		}
+	}
+	return apierr.Unprocessable(message).WithDetails(gin.H{"occurrences": problems})
+}
+
+// CreateSeries books a recurring series, e.g. weekly physiotherapy for 8 weeks, from an RRULE
+// such as FREQ=WEEKLY;COUNT=8. Every occurrence goes through the same checks as a single
+// booking. When any fails the series is refused with each failing occurrence listed, unless
+// skip_conflicts is set, which books the rest and lists the failures under skipped. The
+// patient is sent one message listing the booked times.
+func CreateSeries(sender notifications.Sender) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req seriesRequest
+		if !handlers.BindJSON(c, &req) {
+			return
+		}
+		rule, err := recurrence.Parse(req.Recurrence)
+		if err != nil {
+			c.Error(apierr.Validation(err.Error()))
+			return
+		}
+
+		service, err := database.GetService(c.Request.Context(), req.ServiceID)
+		if err != nil {
+			c.Error(apierr.Validation("Service not found"))
+			return
+		}
+		employee, err := database.GetEmployee(c.Request.Context(), req.EmployeeID)
+		if err != nil || employee.DeletedAt != nil {
+			c.Error(apierr.Validation("Employee not found"))
+			return
+		}
+		if patient, err := database.GetPatient(c.Request.Context(), req.PatientID); err != nil || patient.DeletedAt != nil {
+			c.Error(apierr.Validation("Patient not found"))
+			return
+		}
+		starts, err := rule.Occurrences(req.StartDatetime, availability.Location(employee))
+		if err != nil {
+			c.Error(apierr.Unprocessable(err.Error()))
+			return
+		}
+
+		series := models.AppointmentSeries{
+			PatientID:     req.PatientID,
+			EmployeeID:    req.EmployeeID,
+			ServiceID:     req.ServiceID,
+			ClinicID:      req.ClinicID,
+			Recurrence:    rule.String(),
+			StartDatetime: starts[0],
+		}
+		var skipped []occurrenceProblem
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			if err := database.LockEmployeeSchedule(ctx, series.EmployeeID); err != nil {
+				return err
+			}
+			if err := database.CreateAppointmentSeries(ctx, &series); err != nil {
+				return err
+			}
+			for _, start := range starts {
+				appointment := models.Appointment{
+					PatientID:       req.PatientID,
+					EmployeeID:      req.EmployeeID,
+					ServiceID:       req.ServiceID,
+					ClinicID:        req.ClinicID,
+					StartDatetime:   start,
+					EndDatetime:     start.Add(time.Duration(service.DurationMinutes) * time.Minute),
+					Status:          "SCHEDULED",
+					AppointmentType: req.AppointmentType,
+					BookingChannel:  req.BookingChannel,
+					Notes:           req.Notes,
+					PaymentStatus:   "PENDING",
+					PaymentAmount:   req.PaymentAmount,
+					SeriesID:        &series.ID,
+				}
+				// Occurrences booked earlier in the transaction are seen by the checks, so
+				// overlapping ones are reported rather than tripping the overlap constraint
+				problem, err := checkOccurrence(ctx, &appointment, 0)
+				if err != nil {
+					return err
+				}
+				if problem != nil {
+					skipped = append(skipped, *problem)
+					continue
+				}
+				if err := database.CreateAppointment(ctx, &appointment); err != nil {
+					return err
+				}
+				if err := audit.Record(ctx, audit.EntityAppointments, appointment.ID, audit.ActionCreate, appointment); err != nil {
+					return err
+				}
+				if err := webhooks.Publish(ctx, webhooks.EventAppointmentCreated, webhooks.AppointmentData(&appointment)); err != nil {
+					return err
+				}
+				series.Occurrences = append(series.Occurrences, appointment)
+			}
+			if len(series.Occurrences) == 0 || (len(skipped) > 0 && !req.SkipConflicts) {
+				c.Error(problemsError(skipped, len(starts)))
+				return errResponded
+			}
+			return recordSeries(ctx, &series, audit.ActionCreate)
+		})
+		switch {
+		case errors.Is(err, errResponded):
+			return
+		case errors.Is(err, database.ErrAppointmentConflict), resourceTaken(err):
+			c.Error(apierr.Conflict("An occurrence was booked by someone else meanwhile; try again"))
+			return
+		case err != nil:
+			c.Error(err)
+			return
+		}
+
+		for i := range series.Occurrences {
+			hl7.Publish(c.Request.Context(), hl7.TriggerBooked, &series.Occurrences[i])
+		}
+		notifySeries(c.Request.Context(), sender, &series, employee.Timezone, "Appointments booked",
+			fmt.Sprintf("Your %d appointments for %s are booked:", len(series.Occurrences), service.Name), series.Occurrences)
+		c.JSON(http.StatusCreated, seriesResponse{AppointmentSeries: &series, Skipped: skipped})
+	}
+}
+
+// GetSeries returns a series with all its occurrences, earliest first
+func GetSeries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("series_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid series ID"))
+		return
+	}
+
+	series, err := database.GetAppointmentSeries(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Series not found"))
+		return
+	}
+	c.JSON(http.StatusOK, series)
+}
+
+// seriesUpdateRequest changes every upcoming occurrence of a series. start_time is the new
+// local wall-clock time in the employee's timezone; each occurrence keeps its date and length.
+type seriesUpdateRequest struct {
+	EmployeeID      *int    `json:"employee_id"`
+	StartTime       *string `json:"start_time" binding:"omitnil,datetime=15:04"`
+	AppointmentType *string `json:"appointment_type" binding:"omitnil,enum=appointment_type"`
+	Notes           *string `json:"notes"`
+	Notify          bool    `json:"notify"`
+}
+
+// UpdateSeries edits the whole series: its scheduled and confirmed occurrences still to come
+// move to another employee or time of day, or get a new appointment type or notes. Moved
+// occurrences go through the booking checks again, and the change is made to all of them or,
+// with the failing occurrences listed, to none. To change one occurrence only, update that
+// appointment. With notify set the patient is sent the new times.
+func UpdateSeries(sender notifications.Sender) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("series_id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid series ID"))
+			return
+		}
+		var req seriesUpdateRequest
+		if !handlers.BindJSON(c, &req) {
+			return
+		}
+
+		var series *models.AppointmentSeries
+		var employee *models.Employee
+		var updated []models.Appointment
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			var err error
+			if series, err = database.GetAppointmentSeries(ctx, id); err != nil {
+				c.Error(apierr.Lookup(err, "Series not found"))
+				return errResponded
+			}
+			if series.Status != "ACTIVE" {
+				c.Error(apierr.Unprocessable("Series is cancelled"))
+				return errResponded
+			}
+			if req.EmployeeID != nil {
+				series.EmployeeID = *req.EmployeeID
+			}
+			if employee, err = database.GetEmployee(ctx, series.EmployeeID); err != nil {
+				c.Error(apierr.Validation("Employee not found"))
+				return errResponded
+			}
+			if err := database.LockEmployeeSchedule(ctx, series.EmployeeID); err != nil {
+				return err
+			}
+			loc := availability.Location(employee)
+
+			var problems []occurrenceProblem
+			upcoming := upcomingOccurrences(series, time.Now())
+			for _, existing := range upcoming {
+				moved := existing
+				moved.EmployeeID = series.EmployeeID
+				if req.StartTime != nil {
+					start, err := timeutil.WallClockString(timeutil.LocalDate(existing.StartDatetime, loc), *req.StartTime, loc)
+					if err != nil {
+						return err
+					}
+					moved.StartDatetime = start
+					moved.EndDatetime = start.Add(existing.EndDatetime.Sub(existing.StartDatetime))
+				}
+				if req.AppointmentType != nil {
+					moved.AppointmentType = req.AppointmentType
+				}
+				if req.Notes != nil {
+					moved.Notes = req.Notes
+				}
+
+				if bookingMoved(&existing, &moved) {
+					problem, err := checkOccurrence(ctx, &moved, existing.ID)
+					if err != nil {
+						return err
+					}
+					if problem != nil {
+						problems = append(problems, *problem)
+						continue
+					}
+				}
+				if err := database.UpdateAppointment(ctx, existing.ID, &moved); err != nil {
+					return err
+				}
+				if err := audit.Record(ctx, audit.EntityAppointments, moved.ID, audit.ActionUpdate, moved); err != nil {
+					return err
+				}
+				updated = append(updated, moved)
+			}
+			if len(problems) > 0 {
+				c.Error(problemsError(problems, len(upcoming)))
+				return errResponded
+			}
+			if err := database.SetAppointmentSeriesEmployee(ctx, id, series.EmployeeID); err != nil {
+				return err
+			}
+			if series, err = database.GetAppointmentSeries(ctx, id); err != nil {
+				return err
+			}
+			return recordSeries(ctx, series, audit.ActionUpdate)
+		})
+		switch {
+		case errors.Is(err, errResponded):
+			return
+		case errors.Is(err, database.ErrAppointmentConflict), resourceTaken(err):
+			c.Error(apierr.Conflict("An occurrence's new time was booked by someone else meanwhile; try again"))
+			return
+		case errors.Is(err, database.ErrStaleVersion):
+			c.Error(apierr.PreconditionFailed("An occurrence was changed by someone else meanwhile; try again"))
+			return
+		case err != nil:
+			c.Error(err)
+			return
+		}
+
+		if req.Notify && len(updated) > 0 {
+			notifySeries(c.Request.Context(), sender, series, employee.Timezone, "Appointments changed",
+				"Your upcoming appointments have changed:", updated)
+		}
+		c.JSON(http.StatusOK, series)
+	}
+}
+
+// CancelSeries cancels the whole series: its scheduled and confirmed occurrences still to
+// come are cancelled with the optional reason, their slots are offered to the waiting list and
+// card payments are refunded as for a single cancellation. Past occurrences are kept. The
+// patient is sent one message listing the cancelled times. To cancel one occurrence only,
+// cancel that appointment.
+func CancelSeries(sender notifications.Sender, stripe *payments.Stripe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("series_id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid series ID"))
+			return
+		}
+		var req struct {
+			Reason *string `json:"reason"`
+		}
+		if c.Request.ContentLength > 0 && !handlers.BindJSON(c, &req) {
+			return
+		}
+
+		series, err := database.GetAppointmentSeries(c.Request.Context(), id)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Series not found"))
+			return
+		}
+		if series.Status != "ACTIVE" {
+			c.Error(apierr.Unprocessable("Series is already cancelled"))
+			return
+		}
+
+		var cancelled []models.Appointment
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			var err error
+			if cancelled, err = database.CancelAppointmentSeries(ctx, id, req.Reason, time.Now()); err != nil {
+				return err
+			}
+			for i := range cancelled {
+				if err := audit.Record(ctx, audit.EntityAppointments, cancelled[i].ID, audit.ActionUpdate, cancelled[i]); err != nil {
+					return err
+				}
+				if err := webhooks.Publish(ctx, webhooks.EventAppointmentCancelled, webhooks.AppointmentData(&cancelled[i])); err != nil {
+					return err
+				}
+			}
+			if series, err = database.GetAppointmentSeries(ctx, id); err != nil {
+				return err
+			}
+			return recordSeries(ctx, series, audit.ActionUpdate)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.Error(apierr.Unprocessable("Series is already cancelled"))
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		for i := range cancelled {
+			hl7.Publish(c.Request.Context(), hl7.TriggerCancelled, &cancelled[i])
+			waitlist.FillAfterCancellation(c.Request.Context(), sender, &cancelled[i])
+			stripe.RefundAfterCancellation(c.Request.Context(), &cancelled[i])
+		}
+		if len(cancelled) > 0 {
+			if employee, err := database.GetEmployee(c.Request.Context(), series.EmployeeID); err == nil {
+				notifySeries(c.Request.Context(), sender, series, employee.Timezone, "Appointments cancelled",
+					"Your upcoming appointments have been cancelled:", cancelled)
+			}
+		}
+		c.JSON(http.StatusOK, series)
+	}
+}
+
+// upcomingOccurrences are a series' scheduled and confirmed occurrences starting after now
+func upcomingOccurrences(series *models.AppointmentSeries, now time.Time) []models.Appointment {
+	var upcoming []models.Appointment
+	for _, a := range series.Occurrences {
+		if (a.Status == "SCHEDULED" || a.Status == "CONFIRMED") && a.StartDatetime.After(now) {
+			upcoming = append(upcoming, a)
+		}
+	}
+	return upcoming
+}
+
+// recordSeries audits a series without its occurrences, which are audited one by one
+func recordSeries(ctx context.Context, series *models.AppointmentSeries, action string) error {
+	snapshot := *series
+	snapshot.Occurrences = nil
+	return audit.Record(ctx, audit.EntityAppointmentSeries, series.ID, action, snapshot)
+}
+
+// notifySeries sends the patient one message listing the given occurrences' times, rather than
+// one per occurrence. Failures are logged rather than returned, since the series has already
+// been saved.
+func notifySeries(ctx context.Context, sender notifications.Sender, series *models.AppointmentSeries, timezone, subject, intro string, occurrences []models.Appointment) {
+	patient, err := database.GetPatient(ctx, series.PatientID)
+	if err != nil {
+		slog.ErrorContext(ctx, "series: loading patient", "series_id", series.ID, "error", err)
+		return
+	}
+	prefs, err := database.GetNotificationPreferences(ctx, series.PatientID)
+	if err != nil {
+		slog.ErrorContext(ctx, "series: loading notification preferences", "series_id", series.ID, "error", err)
+		return
+	}
+	lines := []string{intro}
+	for _, a := range occurrences {
+		lines = append(lines, "- "+timeutil.FormatIn(a.StartDatetime, timezone))
+	}
+	for _, msg := range notifications.PatientMessages(ctx, patient, prefs, notifications.TemplateSeries, subject, strings.Join(lines, "\n")) {
+		if err := sender.Send(ctx, msg); err != nil {
+			slog.ErrorContext(ctx, "series: notifying patient", "series_id", series.ID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Medical Appointment Booking System - Appointment Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package appointments
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers/consents"
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateBooking applies the scheduling rules a new or moved booking must satisfy,
+// writing the error response and returning false when one is broken. Lookups run with ctx,
+// so they see writes made earlier in the caller's transaction. excludeID is the
+// appointment being updated, so it does not conflict with itself (0 for new bookings), and
+// holdToken the slot hold the booking is converting, which does not block it ("" for none).
+func validateBooking(ctx context.Context, c *gin.Context, appointment *models.Appointment, excludeID int, holdToken string) bool {
+	if err := checkBooking(ctx, appointment, excludeID, holdToken); err != nil {
+		c.Error(err)
+		return false
+	}
+	return true
+}
+
+// checkBooking is validateBooking without the response: a broken rule is returned as an
+// *apierr.Error, and a failed lookup as the error itself
+func checkBooking(ctx context.Context, appointment *models.Appointment, excludeID int, holdToken string) error {
+	service, err := database.GetService(ctx, appointment.ServiceID)
+	if err != nil {
+		return apierr.Validation("Service not found")
+	}
+	employee, err := database.GetEmployee(ctx, appointment.EmployeeID)
+	if err != nil || employee.DeletedAt != nil {
+		return apierr.Validation("Employee not found")
+	}
+	patient, err := database.GetPatient(ctx, appointment.PatientID)
+	if err != nil || patient.DeletedAt != nil {
+		return apierr.Validation("Patient not found")
+	}
+	// Consent is asked for new bookings; moving one already made does not need it again
+	if excludeID == 0 {
+		if err := consents.CheckRequired(ctx, appointment.PatientID, service); err != nil {
+			return err
+		}
+	}
+	clinic, err := database.GetClinic(ctx, appointment.ClinicID)
+	if err != nil || clinic.DeletedAt != nil {
+		return apierr.Validation("Clinic not found")
+	}
+
+	offers, err := database.EmployeeOffersService(ctx, employee.ID, service.ID)
+	if err != nil {
+		return err
+	}
+	if !offers {
+		return apierr.Unprocessable("Employee does not offer " + service.Name)
+	}
+
+	loc := availability.Location(employee)
+	if err := availability.CheckSpan(service, appointment.StartDatetime, appointment.EndDatetime, loc); err != nil {
+		return apierr.Unprocessable(err.Error())
+	}
+	if err := availability.CheckBookingWindow(service, clinic, appointment.StartDatetime, time.Now(), loc); err != nil {
+		return apierr.Unprocessable(err.Error())
+	}
+	if err := availability.CheckPatientAge(service, patient.DateOfBirth, appointment.StartDatetime, loc); err != nil {
+		return apierr.Unprocessable(err.Error())
+	}
+
+	// Overlap is checked on the full span, so overnight bookings conflict with anything
+	// on either side of midnight. The booking's buffers must not meet another booking's.
+	before, after := availability.Buffers(service)
+	blocks, err := database.GetEmployeeBlocks(ctx, employee.ID, appointment.StartDatetime.Add(-before), appointment.EndDatetime.Add(after))
+	if err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		switch {
+		case b.Appointment != nil && b.Appointment.ID != excludeID:
+			return conflictError(b.Appointment)
+		case b.Appointment == nil && b.HoldToken != holdToken:
+			return apierr.Conflict("This time is temporarily held for another booking")
+		}
+	}
+	if err := availability.CheckResources(ctx, service, appointment.ClinicID, appointment.StartDatetime, appointment.EndDatetime, excludeID); err != nil {
+		if resourceTaken(err) {
+			return resourceConflict(err)
+		}
+		return err
+	}
+
+	return checkFollowUpReserve(ctx, employee, appointment, excludeID, loc)
+}
+
+// checkFollowUpReserve enforces the employee's follow-up reserve on the local day the booking starts
+func checkFollowUpReserve(ctx context.Context, employee *models.Employee, appointment *models.Appointment, excludeID int, loc *time.Location) error {
+	now := time.Now()
+	if availability.ReserveReleased(employee, appointment.StartDatetime, now, loc) {
+		return nil
+	}
+
+	windows, err := availability.WorkingWindows(ctx, employee, timeutil.LocalDate(appointment.StartDatetime, loc))
+	if err != nil {
+		return err
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+	bookings, err := database.GetEmployeeBookings(ctx, employee.ID, windows[0].Start, windows[len(windows)-1].End)
+	if err != nil {
+		return err
+	}
+	others := bookings[:0]
+	for _, b := range bookings {
+		if b.ID != excludeID {
+			others = append(others, b)
+		}
+	}
+
+	if err := availability.CheckFollowUpReserve(employee, appointment, windows, others, now, loc); err != nil {
+		return apierr.Unprocessable(err.Error())
+	}
+	return nil
+}
+
+// conflictError is the 409 for a double booking, including the appointment in the way when
+// it is known
+func conflictError(conflicting *models.Appointment) *apierr.Error {
+	return apierr.Conflict(database.ErrAppointmentConflict.Error()).
+		WithDetails(gin.H{"conflicting_appointment": conflicting})
+}
+
+// resourceTaken reports whether err is a room or piece of equipment the booking needs being taken
+func resourceTaken(err error) bool {
+	var unavailable *database.ResourceUnavailableError
+	return errors.As(err, &unavailable)
+}
+
+// resourceConflict is the 409 for a *database.ResourceUnavailableError, naming the kind of
+// resource that is taken
+func resourceConflict(err error) *apierr.Error {
+	var unavailable *database.ResourceUnavailableError
+	errors.As(err, &unavailable)
+	return apierr.Conflict(err.Error()).WithDetails(gin.H{"resource_kind": unavailable.Kind})
+}
+
+// respondConflict writes the 409 for a double booking
+func respondConflict(c *gin.Context, conflicting *models.Appointment) {
+	c.Error(conflictError(conflicting))
+}
+
+// writeConflict handles ErrAppointmentConflict raised by the database constraint, which catches
+// bookings that raced past the pre-check. It looks up the clashing appointment for the response.
+func writeConflict(c *gin.Context, appointment *models.Appointment, excludeID int) {
+	bookings, err := database.GetEmployeeBookings(c.Request.Context(), appointment.EmployeeID, appointment.StartDatetime, appointment.EndDatetime)
+	if err == nil {
+		for i := range bookings {
+			if bookings[i].ID != excludeID {
+				respondConflict(c, &bookings[i])
+				return
+			}
+		}
+	}
+	respondConflict(c, nil)
+}
+
+// bookingMoved reports whether an update changes when, with whom or for what the
+// appointment is booked, which means the booking rules must be checked again
+func bookingMoved(existing, updated *models.Appointment) bool {
+	return !existing.StartDatetime.Equal(updated.StartDatetime) ||
+		!existing.EndDatetime.Equal(updated.EndDatetime) ||
+		existing.EmployeeID != updated.EmployeeID ||
+		existing.ServiceID != updated.ServiceID
+}
+
+// checkMedicalNotes writes a 403 and returns false when a caller who is not a clinician
+// tries to change an appointment's medical notes. before is nil for new bookings.
+func checkMedicalNotes(c *gin.Context, before, after *string) bool {
+	unchanged := (before == nil && after == nil) || (before != nil && after != nil && *before == *after)
+	if unchanged || auth.HasRole(c, auth.RoleClinician) {
+		return true
+	}
+	c.Error(apierr.Forbidden("Only clinicians may edit medical notes"))
+	return false
+}
@@ -0,0 +1,149 @@
+// Medical Appointment Booking System - Audit Log Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package auditlog
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the audit log endpoints under /audit and /audit-log
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.GET("/audit", auth.Authorize(auth.AuditLog), GetAuditEntries)
+	group := r.Group("/audit-log", auth.Authorize(auth.AuditLog))
+	{
+		group.GET("/:entity/:id/diff", GetAuditDiff)
+	}
+}
+
+// GetAuditEntries lists recorded mutations, latest first, for compliance review. Entries can
+// be narrowed by entity and id, by the user or API key that made them and by when they were
+// recorded.
+func GetAuditEntries(c *gin.Context) {
+	var filter database.AuditFilter
+	var ok bool
+	if entity := c.Query("entity"); entity != "" {
+		if !slices.Contains(audit.Entities, entity) {
+			c.Error(apierr.Validation("Unknown entity"))
+			return
+		}
+		filter.Entity = &entity
+	}
+	if filter.EntityID, ok = handlers.OptionalIntQuery(c, "id"); !ok {
+		return
+	}
+	if filter.EntityID != nil && filter.Entity == nil {
+		c.Error(apierr.Validation("id requires entity"))
+		return
+	}
+	if filter.ActorUserID, ok = handlers.OptionalIntQuery(c, "actor_user_id"); !ok {
+		return
+	}
+	if filter.ActorAPIKeyID, ok = handlers.OptionalIntQuery(c, "actor_api_key_id"); !ok {
+		return
+	}
+	if filter.From, ok = handlers.OptionalTimeQuery(c, "from"); !ok {
+		return
+	}
+	if filter.To, ok = handlers.OptionalTimeQuery(c, "to"); !ok {
+		return
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		c.Error(apierr.Validation("to must be after from"))
+		return
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := database.GetAuditEntries(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, entries, total, page)
+}
+
+// GetAuditDiff returns the field-level changes to an entity between two points in time.
+// from defaults to before the entity existed and to defaults to now.
+func GetAuditDiff(c *gin.Context) {
+	entity := c.Param("entity")
+	if !slices.Contains(audit.Entities, entity) {
+		c.Error(apierr.Validation("Unknown entity"))
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	from, err := parseTimeQuery(c, "from", time.Time{})
+	if err != nil {
+		c.Error(apierr.Validation("from must be an RFC 3339 timestamp"))
+		return
+	}
+	to, err := parseTimeQuery(c, "to", time.Now())
+	if err != nil {
+		c.Error(apierr.Validation("to must be an RFC 3339 timestamp"))
+		return
+	}
+	if to.Before(from) {
+		c.Error(apierr.Validation("to must not be before from"))
+		return
+	}
+
+	before, err := audit.StateAt(c.Request.Context(), entity, id, from)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	after, err := audit.StateAt(c.Request.Context(), entity, id, to)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entity":         entity,
+		"entity_id":      id,
+		"from":           from.UTC(),
+		"to":             to.UTC(),
+		"existed_before": before != nil,
+		"exists_after":   after != nil,
+		"changes":        audit.Diff(before, after),
+	})
+}
+
+func parseTimeQuery(c *gin.Context, name string, fallback time.Time) (time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
@@ -0,0 +1,271 @@
+// Medical Appointment Booking System - Calendar Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package calendars
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/calendar"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// History is how far back feeds reach; everything from then on is included
+const History = 30 * 24 * time.Hour
+
+// RegisterRoutes mounts the calendar exports and feed URL management for employees and patients
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	employees := r.Group("/employees/:id", auth.Authorize(auth.Scheduling))
+	{
+		employees.GET("/calendar.ics", GetEmployeeCalendar)
+		employees.POST("/calendar-feed", CreateFeed(database.FeedOwnerEmployee))
+		employees.DELETE("/calendar-feed", DeleteFeed(database.FeedOwnerEmployee))
+	}
+	patients := r.Group("/patients/:id", auth.Authorize(auth.Patients))
+	{
+		patients.GET("/calendar.ics", GetPatientCalendar)
+		patients.POST("/calendar-feed", CreateFeed(database.FeedOwnerPatient))
+		patients.DELETE("/calendar-feed", DeleteFeed(database.FeedOwnerPatient))
+	}
+}
+
+// RegisterPublicRoutes mounts the subscription URL calendar apps poll. They cannot log in,
+// so the secret token in the URL is the credential.
+func RegisterPublicRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.GET("/calendar-feeds/:token", GetFeed)
+}
+
+func GetEmployeeCalendar(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	writeCalendar(c, database.FeedOwnerEmployee, id)
+}
+
+func GetPatientCalendar(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	writeCalendar(c, database.FeedOwnerPatient, id)
+}
+
+// GetFeed serves the calendar a feed token belongs to. The token may carry an .ics suffix,
+// which some calendar apps need to recognise the URL.
+func GetFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	ownerType, ownerID, err := database.GetCalendarFeedOwner(c.Request.Context(), calendar.HashFeedToken(token))
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Calendar feed not found"))
+		return
+	}
+	writeCalendar(c, ownerType, ownerID)
+}
+
+// CreateFeed issues a secret subscription URL for the employee's or patient's calendar.
+// Issuing a new one revokes the previous URL; the token is only shown in this response.
+func CreateFeed(ownerType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid ID"))
+			return
+		}
+		if _, _, ok := loadOwner(c, ownerType, id); !ok {
+			return
+		}
+
+		token, hash, err := calendar.NewFeedToken()
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		var feedID int
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			var err error
+			if feedID, err = database.SetCalendarFeed(ctx, ownerType, id, hash); err != nil {
+				return err
+			}
+			return audit.Record(ctx, audit.EntityCalendarFeeds, feedID, audit.ActionCreate, gin.H{"owner_type": ownerType, "owner_id": id})
+		})
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"feed_url": feedURL(c, token)})
+	}
+}
+
+// DeleteFeed revokes the employee's or patient's subscription URL
+func DeleteFeed(ownerType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid ID"))
+			return
+		}
+		var feedID int
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			var err error
+			if feedID, err = database.DeleteCalendarFeed(ctx, ownerType, id); err != nil {
+				return err
+			}
+			return audit.Record(ctx, audit.EntityCalendarFeeds, feedID, audit.ActionDelete, nil)
+		})
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Calendar feed not found"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Calendar feed revoked successfully"})
+	}
+}
+
+// writeCalendar responds with the owner's appointments from History ago onwards as an ICS feed
+func writeCalendar(c *gin.Context, ownerType string, ownerID int) {
+	name, filter, ok := loadOwner(c, ownerType, ownerID)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	from := now.Add(-History)
+	filter.From = &from
+	appointments, _, err := database.GetAppointments(c.Request.Context(), filter, database.Page{})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	events, err := buildEvents(c.Request.Context(), appointments, ownerType)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.Header("Content-Disposition", `inline; filename="calendar.ics"`)
+	c.Data(http.StatusOK, calendar.ContentType, []byte(calendar.Feed("Appointments - "+name, events, now)))
+}
+
+// loadOwner loads the employee or patient a calendar belongs to, writing a 404 when they do
+// not exist, and returns their name and the filter selecting their appointments
+func loadOwner(c *gin.Context, ownerType string, id int) (string, database.AppointmentFilter, bool) {
+	if ownerType == database.FeedOwnerEmployee {
+		employee, err := database.GetEmployee(c.Request.Context(), id)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Employee not found"))
+			return "", database.AppointmentFilter{}, false
+		}
+		return employee.FirstName + " " + employee.LastName, database.AppointmentFilter{EmployeeID: &id}, true
+	}
+	patient, err := database.GetPatient(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return "", database.AppointmentFilter{}, false
+	}
+	return patient.FirstName + " " + patient.LastName, database.AppointmentFilter{PatientID: &id}, true
+}
+
+// buildEvents turns appointments into feed events. Employees see the service and the
+// patient's first name and initial; patients see the service and who they are seeing.
+// Notes and medical notes are never included, since feeds end up on third-party servers.
+func buildEvents(ctx context.Context, appointments []models.Appointment, ownerType string) ([]calendar.Event, error) {
+	services := map[int]*models.Service{}
+	clinics := map[int]*models.Clinic{}
+	people := map[int]string{}
+
+	events := make([]calendar.Event, 0, len(appointments))
+	for i := range appointments {
+		a := &appointments[i]
+		service, ok := services[a.ServiceID]
+		if !ok {
+			var err error
+			if service, err = database.GetService(ctx, a.ServiceID); err != nil {
+				return nil, err
+			}
+			services[a.ServiceID] = service
+		}
+		clinic, ok := clinics[a.ClinicID]
+		if !ok {
+			var err error
+			if clinic, err = database.GetClinic(ctx, a.ClinicID); err != nil {
+				return nil, err
+			}
+			clinics[a.ClinicID] = clinic
+		}
+
+		otherID := a.PatientID
+		if ownerType == database.FeedOwnerPatient {
+			otherID = a.EmployeeID
+		}
+		other, ok := people[otherID]
+		if !ok {
+			if ownerType == database.FeedOwnerPatient {
+				employee, err := database.GetEmployee(ctx, otherID)
+				if err != nil {
+					return nil, err
+				}
+				other = employee.FirstName + " " + employee.LastName
+			} else {
+				patient, err := database.GetPatient(ctx, otherID)
+				if err != nil {
+					return nil, err
+				}
+				other = patient.FirstName
+				if last := []rune(patient.LastName); len(last) > 0 {
+					other += " " + string(last[0]) + "."
+				}
+			}
+			people[otherID] = other
+		}
+
+		location := clinic.Name
+		if clinic.Address != "" {
+			location += ", " + clinic.Address
+		}
+		events = append(events, calendar.Event{
+			UID:         a.PublicID + "@bookings",
+			Start:       a.StartDatetime,
+			End:         a.EndDatetime,
+			Updated:     a.UpdatedAt,
+			Summary:     fmt.Sprintf("%s with %s", service.Name, other),
+			Location:    location,
+			Description: "Status: " + a.Status + "\nReference: " + a.PublicID,
+			Status:      calendar.EventStatus(a.Status),
+		})
+	}
+	return events, nil
+}
+
+// feedURL builds the absolute subscription URL from the request's host
+func feedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/api/v1/calendar-feeds/" + token + ".ics"
+}
@@ -0,0 +1,143 @@
+// Medical Appointment Booking System - Payment Link Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package cardpayments
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/config"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/invoice"
+	"bookings/notifications"
+	"bookings/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBody bounds the webhook payloads read; Stripe's events are a few kilobytes
+const maxWebhookBody = 1 << 20
+
+// notConfigured is the refusal when no Stripe account is configured
+const notConfigured = "Card payments are not configured"
+
+// RegisterRoutes mounts the staff card payment endpoints
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/appointments/:id", auth.Authorize(auth.Payments))
+	{
+		group.POST("/payment-intent", CreatePaymentIntent(deps.Stripe))
+		group.GET("/card-payments", GetCardPayments)
+	}
+}
+
+// RegisterPublicRoutes mounts the Stripe webhook, which Stripe calls without logging in; the
+// payload signature is the credential
+func RegisterPublicRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.POST("/payments/stripe/webhook", Webhook(deps.Stripe, deps.Sender, deps.Invoices))
+}
+
+// CreatePaymentIntent returns the Stripe checkout for an appointment's outstanding amount,
+// e.g. to take the payment at the front desk or send it to the patient
+func CreatePaymentIntent(stripe *payments.Stripe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if stripe == nil {
+			c.Error(apierr.Unavailable(notConfigured))
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid ID"))
+			return
+		}
+		appointment, err := database.GetAppointment(c.Request.Context(), id)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Appointment not found"))
+			return
+		}
+
+		checkout, err := stripe.StartPayment(c.Request.Context(), appointment)
+		if err != nil {
+			if errors.Is(err, payments.ErrNothingToPay) {
+				c.Error(apierr.Unprocessable("Appointment has no outstanding amount to pay"))
+				return
+			}
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, checkout)
+	}
+}
+
+// GetCardPayments lists an appointment's card payments and refunds
+func GetCardPayments(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	if _, err := database.GetAppointment(c.Request.Context(), id); err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+
+	list, err := database.GetCardPayments(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Webhook receives Stripe's payment and refund events, and emails the patient their receipt
+// when a payment completes if receipts are turned on. Anything but a 2xx makes Stripe
+// deliver the event again later.
+func Webhook(stripe *payments.Stripe, sender notifications.Sender, invoices config.Invoices) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if stripe == nil {
+			c.Error(apierr.NotFound("Route not found"))
+			return
+		}
+		payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+		if err != nil {
+			c.Error(apierr.Validation("Could not read the request body"))
+			return
+		}
+		event, err := stripe.VerifyWebhook(payload, c.GetHeader("Stripe-Signature"), time.Now())
+		if err != nil {
+			c.Error(apierr.Validation(err.Error()))
+			return
+		}
+
+		payment, err := stripe.ApplyEvent(c.Request.Context(), event)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		slog.InfoContext(c.Request.Context(), "payments: applied Stripe event", "event_id", event.ID, "type", event.Type)
+		if payment != nil && payment.Status == "SUCCEEDED" && invoices.EmailReceipts {
+			invoice.EmailReceipt(c.Request.Context(), sender, payment.AppointmentID, invoices.TaxRate)
+		}
+		c.JSON(http.StatusOK, gin.H{"received": true})
+	}
+}
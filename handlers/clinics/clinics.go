@@ -0,0 +1,347 @@
+// Medical Appointment Booking System - Clinic Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package clinics
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/geocode"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the clinic endpoints
+type Handler struct {
+	clinics database.ClinicRepo
+	// geocoder fills in clinic coordinates from their addresses; nil leaves them to the client
+	geocoder *geocode.Geocoder
+}
+
+// New returns a Handler that stores clinics in the given repository
+func New(clinics database.ClinicRepo, geocoder *geocode.Geocoder) *Handler {
+	return &Handler{clinics: clinics, geocoder: geocoder}
+}
+
+// RegisterRoutes mounts the clinic endpoints, with opening hours, holidays and the booking
+// widget, under /clinics
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	h := New(deps.Repos.Clinics, deps.Geocoder)
+	group := r.Group("/clinics", auth.Authorize(auth.Clinics))
+	{
+		group.GET("", h.GetClinics)
+		group.GET("/nearby", h.GetNearbyClinics)
+		group.GET("/:id", h.GetClinic)
+		group.POST("", h.CreateClinic)
+		group.PUT("/:id", h.UpdateClinic)
+		group.PATCH("/:id", h.PatchClinic)
+		group.DELETE("/:id", h.DeleteClinic)
+		group.POST("/:id/restore", auth.RequireRole(auth.RoleAdmin), h.RestoreClinic)
+		group.GET("/:id/hours", h.GetClinicHours)
+		group.POST("/:id/hours", h.CreateClinicHours)
+		group.PUT("/:id/hours/:hours_id", h.UpdateClinicHours)
+		group.DELETE("/:id/hours/:hours_id", h.DeleteClinicHours)
+		group.GET("/:id/holidays", h.GetClinicHolidays)
+		group.PUT("/:id/holidays/:date", h.PutClinicHoliday)
+		group.DELETE("/:id/holidays/:date", h.DeleteClinicHoliday)
+		group.GET("/:id/widget", h.GetClinicWidget)
+		group.PUT("/:id/widget", h.PutClinicWidget)
+		group.DELETE("/:id/widget", h.DeleteClinicWidget)
+	}
+}
+
+func (h *Handler) GetClinics(c *gin.Context) {
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	includeDeleted, ok := handlers.IncludeDeleted(c)
+	if !ok {
+		return
+	}
+	clinics, total, err := h.clinics.List(c.Request.Context(), page, includeDeleted, auth.OrganizationID(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, clinics, total, page)
+}
+
+func (h *Handler) GetClinic(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	includeDeleted, ok := handlers.IncludeDeleted(c)
+	if !ok {
+		return
+	}
+
+	clinic, ok := h.clinic(c, id)
+	if !ok {
+		return
+	}
+	if clinic.DeletedAt != nil && !includeDeleted {
+		c.Error(apierr.NotFound("Clinic not found"))
+		return
+	}
+	c.JSON(http.StatusOK, clinic)
+}
+
+func (h *Handler) CreateClinic(c *gin.Context) {
+	var clinic models.Clinic
+	if !handlers.BindJSON(c, &clinic) {
+		return
+	}
+
+	clinic.OrganizationID = auth.OrganizationID(c)
+	if !h.locate(c, nil, &clinic) {
+		return
+	}
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.clinics.Create(ctx, &clinic); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinics, clinic.ID, audit.ActionCreate, clinic)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, clinic)
+}
+
+func (h *Handler) UpdateClinic(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var clinic models.Clinic
+	if !handlers.BindJSON(c, &clinic) {
+		return
+	}
+	existing, ok := h.clinic(c, id)
+	if !ok {
+		return
+	}
+	h.saveClinic(c, id, existing, &clinic)
+}
+
+// PatchClinic updates only the fields given in the body, a JSON merge patch
+func (h *Handler) PatchClinic(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	existing, ok := h.clinic(c, id)
+	if !ok {
+		return
+	}
+	var clinic models.Clinic
+	if !handlers.MergeJSON(c, existing, &clinic) {
+		return
+	}
+	h.saveClinic(c, id, existing, &clinic)
+}
+
+func (h *Handler) saveClinic(c *gin.Context, id int, existing, clinic *models.Clinic) {
+	if !h.locate(c, existing, clinic) {
+		return
+	}
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.clinics.Update(ctx, id, clinic); err != nil {
+			return err
+		}
+		updated, err := h.clinics.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinics, id, audit.ActionUpdate, updated)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Clinic updated successfully"})
+}
+
+// locate checks that coordinates come in pairs and, with a geocoder, fills them in from the
+// address when none are given or the address changed under coordinates left as they were.
+// Geocoding failures are logged; a clinic whose address moved is then left without
+// coordinates rather than keeping the old ones.
+func (h *Handler) locate(c *gin.Context, existing, clinic *models.Clinic) bool {
+	if (clinic.Latitude == nil) != (clinic.Longitude == nil) {
+		c.Error(apierr.Validation("latitude and longitude must be given together"))
+		return false
+	}
+	moved := existing != nil && (existing.Address != clinic.Address || existing.City != clinic.City) &&
+		clinic.Latitude != nil && sameCoordinates(existing, clinic)
+	if h.geocoder == nil || clinic.Address == "" || (clinic.Latitude != nil && !moved) {
+		return true
+	}
+
+	address := clinic.Address
+	if clinic.City != "" {
+		address += ", " + clinic.City
+	}
+	latitude, longitude, found, err := h.geocoder.Geocode(c.Request.Context(), address)
+	switch {
+	case err != nil:
+		slog.WarnContext(c.Request.Context(), "geocoding clinic address", "clinic_id", clinic.ID, "error", err)
+	case !found:
+		slog.WarnContext(c.Request.Context(), "clinic address not found by geocoder", "clinic_id", clinic.ID)
+	default:
+		clinic.Latitude, clinic.Longitude = &latitude, &longitude
+		return true
+	}
+	if moved {
+		clinic.Latitude, clinic.Longitude = nil, nil
+	}
+	return true
+}
+
+// defaultNearbyRadiusKm and maxNearbyRadiusKm bound the nearby clinic search
+const (
+	defaultNearbyRadiusKm = 25
+	maxNearbyRadiusKm     = 500
+)
+
+// sameCoordinates reports whether two clinics have the same coordinates, or both have none
+func sameCoordinates(a, b *models.Clinic) bool {
+	same := func(x, y *float64) bool { return (x == nil && y == nil) || (x != nil && y != nil && *x == *y) }
+	return same(a.Latitude, b.Latitude) && same(a.Longitude, b.Longitude)
+}
+
+// GetNearbyClinics lists the caller's organization's clinics within radius_km (default 25, at
+// most 500) of lat and lng, nearest first, each with its distance_km
+func (h *Handler) GetNearbyClinics(c *gin.Context) {
+	latitude, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		c.Error(apierr.Validation("lat must be a latitude between -90 and 90"))
+		return
+	}
+	longitude, err := strconv.ParseFloat(c.Query("lng"), 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		c.Error(apierr.Validation("lng must be a longitude between -180 and 180"))
+		return
+	}
+	radius := float64(defaultNearbyRadiusKm)
+	if raw := c.Query("radius_km"); raw != "" {
+		radius, err = strconv.ParseFloat(raw, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadiusKm {
+			c.Error(apierr.Validation("radius_km must be greater than 0 and at most " + strconv.Itoa(maxNearbyRadiusKm)))
+			return
+		}
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	clinics, total, err := h.clinics.Nearby(c.Request.Context(), latitude, longitude, radius, auth.OrganizationID(c), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, clinics, total, page)
+}
+
+// clinic loads a clinic of the caller's organization, deleted or not, writing a 404 when
+// there is none; clinics of other organizations are answered as missing
+func (h *Handler) clinic(c *gin.Context, id int) (*models.Clinic, bool) {
+	clinic, err := h.clinics.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Clinic not found"))
+		return nil, false
+	}
+	if clinic.OrganizationID != auth.OrganizationID(c) {
+		c.Error(apierr.NotFound("Clinic not found"))
+		return nil, false
+	}
+	return clinic, true
+}
+
+func (h *Handler) DeleteClinic(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	if _, ok := h.clinic(c, id); !ok {
+		return
+	}
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.clinics.Delete(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinics, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Clinic not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Clinic deleted successfully"})
+}
+
+// RestoreClinic undoes a soft delete
+func (h *Handler) RestoreClinic(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	if _, ok := h.clinic(c, id); !ok {
+		return
+	}
+	var clinic *models.Clinic
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.clinics.Restore(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		if clinic, err = h.clinics.Get(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinics, id, audit.ActionRestore, clinic)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrRestoreConflict) {
+			c.Error(apierr.Conflict(err.Error()))
+			return
+		}
+		c.Error(apierr.Lookup(err, "Deleted clinic not found"))
+		return
+	}
+	c.JSON(http.StatusOK, clinic)
+}
@@ -0,0 +1,238 @@
+// Medical Appointment Booking System - Clinic Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package clinics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultHolidayRange is how many days ahead holidays are listed when no end date is given
+const defaultHolidayRange = 365
+
+// GetClinicHours lists the clinic's weekly opening hours. An empty list means the clinic is
+// open whenever its employees work.
+func (h *Handler) GetClinicHours(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	hours, err := database.ListClinicHours(c.Request.Context(), clinicID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, hours)
+}
+
+// CreateClinicHours adds an opening window to the clinic's week
+func (h *Handler) CreateClinicHours(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	var hours models.ClinicHours
+	if !handlers.BindJSON(c, &hours) {
+		return
+	}
+	hours.ClinicID = clinicID
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateClinicHours(ctx, &hours); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinicHours, hours.ID, audit.ActionCreate, hours)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, hours)
+}
+
+// UpdateClinicHours replaces one of the clinic's opening windows
+func (h *Handler) UpdateClinicHours(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("hours_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid hours ID"))
+		return
+	}
+	var hours models.ClinicHours
+	if !handlers.BindJSON(c, &hours) {
+		return
+	}
+	hours.ID, hours.ClinicID = id, clinicID
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateClinicHours(ctx, &hours); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinicHours, hours.ID, audit.ActionUpdate, hours)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Opening hours not found"))
+		return
+	}
+	c.JSON(http.StatusOK, hours)
+}
+
+// DeleteClinicHours removes one of the clinic's opening windows
+func (h *Handler) DeleteClinicHours(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("hours_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid hours ID"))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.DeleteClinicHours(ctx, clinicID, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinicHours, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Opening hours not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Opening hours deleted successfully"})
+}
+
+// GetClinicHolidays lists the clinic's holidays between from and to (YYYY-MM-DD, inclusive);
+// from defaults to today and to to a year later
+func (h *Handler) GetClinicHolidays(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	var err error
+	from := time.Now().UTC()
+	if raw := c.Query("from"); raw != "" {
+		if from, err = timeutil.ParseDate(raw); err != nil {
+			c.Error(apierr.Validation("from must be given as YYYY-MM-DD"))
+			return
+		}
+	}
+	to := from.AddDate(0, 0, defaultHolidayRange)
+	if raw := c.Query("to"); raw != "" {
+		if to, err = timeutil.ParseDate(raw); err != nil {
+			c.Error(apierr.Validation("to must be given as YYYY-MM-DD"))
+			return
+		}
+	}
+
+	holidays, err := database.ListClinicHolidays(c.Request.Context(), clinicID, from.Format(timeutil.DateLayout), to.Format(timeutil.DateLayout))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, holidays)
+}
+
+// PutClinicHoliday closes the clinic for a date, or renames the holiday already on it
+func (h *Handler) PutClinicHoliday(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	date, err := timeutil.ParseDate(c.Param("date"))
+	if err != nil {
+		c.Error(apierr.Validation("date must be given as YYYY-MM-DD"))
+		return
+	}
+	var holiday models.ClinicHoliday
+	if !handlers.BindJSON(c, &holiday) {
+		return
+	}
+	holiday.ClinicID = clinicID
+	holiday.Date = date.Format(timeutil.DateLayout)
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpsertClinicHoliday(ctx, &holiday); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinicHolidays, holiday.ID, audit.ActionUpdate, holiday)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, holiday)
+}
+
+// DeleteClinicHoliday reopens the clinic on a date
+func (h *Handler) DeleteClinicHoliday(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	date, err := timeutil.ParseDate(c.Param("date"))
+	if err != nil {
+		c.Error(apierr.Validation("date must be given as YYYY-MM-DD"))
+		return
+	}
+
+	var id int
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if id, err = database.DeleteClinicHoliday(ctx, clinicID, date.Format(timeutil.DateLayout)); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinicHolidays, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Holiday not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Holiday deleted successfully"})
+}
+
+// clinicParam parses the clinic id path parameter and checks the clinic exists
+func (h *Handler) clinicParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return 0, false
+	}
+	clinic, ok := h.clinic(c, id)
+	if !ok {
+		return 0, false
+	}
+	if clinic.DeletedAt != nil {
+		c.Error(apierr.NotFound("Clinic not found"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,93 @@
+// Medical Appointment Booking System - Clinic Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package clinics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetClinicWidget returns the settings of the clinic's booking widget
+func (h *Handler) GetClinicWidget(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	widget, err := database.GetClinicWidget(c.Request.Context(), clinicID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Widget not found"))
+		return
+	}
+	c.JSON(http.StatusOK, widget)
+}
+
+// PutClinicWidget sets up or replaces the clinic's booking widget
+func (h *Handler) PutClinicWidget(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	var widget models.ClinicWidget
+	if !handlers.BindJSON(c, &widget) {
+		return
+	}
+	widget.ClinicID = clinicID
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpsertClinicWidget(ctx, &widget); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinicWidgets, clinicID, audit.ActionUpdate, widget)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrWidgetSlugTaken) {
+			c.Error(apierr.Conflict("Another clinic's widget already uses this slug"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, widget)
+}
+
+// DeleteClinicWidget takes the clinic's booking widget down
+func (h *Handler) DeleteClinicWidget(c *gin.Context) {
+	clinicID, ok := h.clinicParam(c)
+	if !ok {
+		return
+	}
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.DeleteClinicWidget(ctx, clinicID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityClinicWidgets, clinicID, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Widget not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Widget deleted"})
+}
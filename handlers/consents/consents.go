@@ -0,0 +1,304 @@
+// Medical Appointment Booking System - Consent Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package consents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the consent document endpoints under /consent-documents, the query
+// for patients without a current consent under /consents and each patient's consents under
+// /patients/:id/consents
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	documents := r.Group("/consent-documents", auth.Authorize(auth.Consents))
+	{
+		documents.GET("", GetConsentDocuments)
+		documents.GET("/:id", GetConsentDocument)
+		documents.POST("", auth.RequireRole(auth.RoleAdmin), CreateConsentDocument)
+	}
+	r.GET("/consents/missing", auth.Authorize(auth.Consents), GetMissingConsents)
+	patients := r.Group("/patients/:id/consents", auth.Authorize(auth.Consents))
+	{
+		patients.GET("", GetPatientConsents)
+		patients.POST("", RecordConsent)
+		patients.POST("/:consent_id/withdraw", WithdrawConsent)
+	}
+}
+
+// GetConsentDocuments lists the consent document versions, latest in effect first, optionally
+// only those of one kind
+func GetConsentDocuments(c *gin.Context) {
+	kind, ok := kindQuery(c)
+	if !ok {
+		return
+	}
+	documents, err := database.GetConsentDocuments(c.Request.Context(), kind)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, documents)
+}
+
+func GetConsentDocument(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	document, err := database.GetConsentDocument(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Consent document not found"))
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+// CreateConsentDocument publishes a new version of a consent document. It becomes the kind's
+// current version at effective_at, now unless given, from when patients who agreed only to
+// earlier versions lack a current consent.
+func CreateConsentDocument(c *gin.Context) {
+	var document models.ConsentDocument
+	if !handlers.BindJSON(c, &document) {
+		return
+	}
+	document.CreatedBy = currentUser(c)
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateConsentDocument(ctx, &document); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityConsentDocuments, document.ID, audit.ActionCreate, document)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrConsentVersionTaken) {
+			c.Error(apierr.Conflict(err.Error()))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, document)
+}
+
+// GetMissingConsents lists the active patients, by name, who have not agreed to the current
+// version of a kind (kind=, required), or to a given version (document_id=), with the latest
+// version of the kind each did agree to. The response is paginated.
+func GetMissingConsents(c *gin.Context) {
+	documentID, ok := handlers.OptionalIntQuery(c, "document_id")
+	if !ok {
+		return
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	var document *models.ConsentDocument
+	if documentID != nil {
+		var err error
+		if document, err = database.GetConsentDocument(c.Request.Context(), *documentID); err != nil {
+			c.Error(apierr.Lookup(err, "Consent document not found"))
+			return
+		}
+	} else {
+		kind := c.Query("kind")
+		if !slices.Contains(models.ConsentKinds, kind) {
+			c.Error(apierr.Validation("Give a kind (PRIVACY_POLICY, TREATMENT or DATA_SHARING) or a document_id"))
+			return
+		}
+		current, err := database.GetCurrentConsentDocuments(c.Request.Context(), []string{kind})
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if len(current) == 0 {
+			c.Error(apierr.NotFound("No version of this consent document is in effect"))
+			return
+		}
+		document = &current[0]
+	}
+
+	patients, total, err := database.GetPatientsMissingConsent(c.Request.Context(), document, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, patients, total, page)
+}
+
+// GetPatientConsents lists a patient's consents, latest first, withdrawn ones included; current
+// marks those in force for the current version of their kind
+func GetPatientConsents(c *gin.Context) {
+	patientID, ok := patientParam(c)
+	if !ok {
+		return
+	}
+	consents, err := database.GetPatientConsents(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, consents)
+}
+
+// RecordConsent records a patient's agreement to a consent document given at the clinic: in
+// person, on paper or verbally
+func RecordConsent(c *gin.Context) {
+	patientID, ok := patientParam(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		ConsentDocumentID int    `json:"consent_document_id" binding:"required"`
+		CaptureMethod     string `json:"capture_method" binding:"required,oneof=IN_PERSON PAPER VERBAL"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	consent := models.PatientConsent{
+		PatientID:         patientID,
+		ConsentDocumentID: req.ConsentDocumentID,
+		CaptureMethod:     req.CaptureMethod,
+		RecordedBy:        currentUser(c),
+	}
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.RecordConsent(ctx, &consent); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatientConsents, consent.ID, audit.ActionCreate, consent)
+	})
+	if err != nil {
+		WriteError(c, err, "Consent document not found")
+		return
+	}
+	c.JSON(http.StatusCreated, consent)
+}
+
+// WithdrawConsent records that a patient withdrew one of their consents. It stays in their
+// history, no longer current.
+func WithdrawConsent(c *gin.Context) {
+	patientID, ok := patientParam(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("consent_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid consent ID"))
+		return
+	}
+
+	var consent *models.PatientConsent
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if consent, err = database.WithdrawConsent(ctx, patientID, id, currentUser(c), time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatientConsents, consent.ID, audit.ActionUpdate, consent)
+	})
+	if err != nil {
+		WriteError(c, err, "Consent not found")
+		return
+	}
+	c.JSON(http.StatusOK, consent)
+}
+
+// WriteError maps the consent errors to their responses, with notFound as the message of a 404
+func WriteError(c *gin.Context, err error, notFound string) {
+	switch {
+	case errors.Is(err, database.ErrConsentSuperseded), errors.Is(err, database.ErrConsentWithdrawn):
+		c.Error(apierr.Conflict(err.Error()))
+	default:
+		c.Error(apierr.Lookup(err, notFound))
+	}
+}
+
+// missingConsent names a consent document a booking is waiting for
+type missingConsent struct {
+	ID      int    `json:"id"`
+	Kind    string `json:"kind"`
+	Version string `json:"version"`
+	Title   string `json:"title"`
+}
+
+// CheckRequired checks that a patient has agreed to the current versions of the consents a
+// service requires. When they have not it returns a 422 naming the documents, which are
+// listed in details.missing_consents; a failed lookup is returned as the error itself.
+func CheckRequired(ctx context.Context, patientID int, service *models.Service) error {
+	missing, err := database.MissingConsents(ctx, patientID, service.RequiredConsents)
+	if err != nil || len(missing) == 0 {
+		return err
+	}
+	names := make([]string, len(missing))
+	details := make([]missingConsent, len(missing))
+	for i, d := range missing {
+		names[i] = fmt.Sprintf("%s (version %s)", d.Title, d.Version)
+		details[i] = missingConsent{ID: d.ID, Kind: d.Kind, Version: d.Version, Title: d.Title}
+	}
+	return apierr.Unprocessable(service.Name + " can only be booked once the patient has agreed to " + strings.Join(names, ", ")).
+		WithDetails(gin.H{"missing_consents": details})
+}
+
+// patientParam reads the patient in the path, writing a 400 or 404 when there is none
+func patientParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return 0, false
+	}
+	if patient, err := database.GetPatient(c.Request.Context(), id); err != nil || patient.DeletedAt != nil {
+		c.Error(apierr.NotFound("Patient not found"))
+		return 0, false
+	}
+	return id, true
+}
+
+// kindQuery reads the optional kind filter, writing a 400 when it is not a consent kind
+func kindQuery(c *gin.Context) (*string, bool) {
+	kind := c.Query("kind")
+	if kind == "" {
+		return nil, true
+	}
+	if !slices.Contains(models.ConsentKinds, kind) {
+		c.Error(apierr.Validation("Invalid kind"))
+		return nil, false
+	}
+	return &kind, true
+}
+
+func currentUser(c *gin.Context) *int {
+	if id, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		return &id
+	}
+	return nil
+}
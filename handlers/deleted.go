@@ -0,0 +1,46 @@
+// Medical Appointment Booking System - Handlers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IncludeDeleted reads the include_deleted query parameter of endpoints over soft-deletable
+// records, writing a 400 when it is not a boolean. Only admins may see deleted records; anyone
+// else asking for them gets a 403.
+func IncludeDeleted(c *gin.Context) (bool, bool) {
+	raw := c.Query("include_deleted")
+	if raw == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(raw)
+	if err != nil {
+		c.Error(apierr.Validation("include_deleted must be true or false"))
+		return false, false
+	}
+	if include && !auth.HasRole(c, auth.RoleAdmin) {
+		c.Error(apierr.Forbidden("Only admins can see deleted records"))
+		return false, false
+	}
+	return include, true
+}
@@ -0,0 +1,56 @@
+// Medical Appointment Booking System - Handlers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"bookings/captcha"
+	"bookings/config"
+	"bookings/database"
+	"bookings/geocode"
+	"bookings/live"
+	"bookings/notifications"
+	"bookings/payments"
+	"bookings/ratelimit"
+	"bookings/storage"
+)
+
+// Deps carries the shared dependencies every route module is registered with.
+// Route modules live in sub-packages (handlers/clinics, handlers/appointments, ...)
+// and each exposes RegisterRoutes(r *gin.RouterGroup, deps Deps).
+type Deps struct {
+	// Sender delivers patient and staff notifications
+	Sender notifications.Sender
+	// Repos stores the core records; route modules build their handlers from them
+	Repos database.Repos
+	// Stripe takes card payments; nil when card payments are not configured
+	Stripe *payments.Stripe
+	// Invoices configures invoice PDFs and receipt emails
+	Invoices config.Invoices
+	// Live hands appointment changes to the live schedule streams
+	Live *live.Hub
+	// Captcha checks public bookings; nil when no CAPTCHA secret is configured
+	Captcha *captcha.Verifier
+	// Geocoder locates clinic addresses; nil when GEOCODER_URL is not set
+	Geocoder *geocode.Geocoder
+	// Storage keeps uploaded patient documents; nil when no S3 bucket is configured
+	Storage *storage.Store
+	// Retention is how long each class of data is kept
+	Retention config.Retention
+	// Holds limits placing slot holds in the online booking flow, per address. It is shared
+	// by every mount of the routes.
+	Holds *ratelimit.Limiter
+}
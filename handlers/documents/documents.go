@@ -0,0 +1,414 @@
+// Medical Appointment Booking System - Time Off Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package documents
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// MaxDocumentBytes bounds an uploaded file
+	MaxDocumentBytes = 20 << 20
+	// DownloadURLTTL is how long a download URL works
+	DownloadURLTTL = 5 * time.Minute
+	// DeleteWindow is how long whoever uploaded a document may delete it; after that, and for
+	// anyone else's, only admins can
+	DeleteWindow = 24 * time.Hour
+	// maxFilenameLength bounds the stored filename, in characters
+	maxFilenameLength = 255
+)
+
+// DocumentTypes are the file types accepted, as detected from the file's content rather than
+// the name or type the client gave it
+var DocumentTypes = []string{"application/pdf", "image/jpeg", "image/png", "image/webp"}
+
+// notConfigured is the refusal when no object store is configured
+const notConfigured = "Document storage is not configured"
+
+// Handler serves the patient document endpoints
+type Handler struct {
+	// store keeps the files; nil turns uploads and downloads off
+	store *storage.Store
+}
+
+// New returns a Handler that keeps files in the given store
+func New(store *storage.Store) *Handler {
+	return &Handler{store: store}
+}
+
+// RegisterRoutes mounts the document endpoints: uploads and listings under a patient, and the
+// documents themselves under /documents
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	h := New(deps.Storage)
+	patient := r.Group("/patients/:id/documents", auth.Authorize(auth.Documents))
+	{
+		patient.GET("", h.GetDocuments)
+		patient.POST("", h.UploadDocument)
+	}
+	group := r.Group("/documents", auth.Authorize(auth.Documents))
+	{
+		group.GET("/:id", h.GetDocument)
+		group.GET("/:id/download", h.DownloadDocument)
+		group.DELETE("/:id", h.DeleteDocument)
+	}
+}
+
+// download is a signed URL a document can be fetched from until it expires
+type download struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// snapshot is what the audit log keeps of a document: its metadata, without the filename and
+// description, which may name the patient
+type snapshot struct {
+	PatientID     int    `json:"patient_id"`
+	AppointmentID *int   `json:"appointment_id"`
+	Category      string `json:"category"`
+	ContentType   string `json:"content_type"`
+	SizeBytes     int64  `json:"size_bytes"`
+	SHA256        string `json:"sha256"`
+}
+
+func newSnapshot(document *models.Document) snapshot {
+	return snapshot{
+		PatientID:     document.PatientID,
+		AppointmentID: document.AppointmentID,
+		Category:      document.Category,
+		ContentType:   document.ContentType,
+		SizeBytes:     document.SizeBytes,
+		SHA256:        document.SHA256,
+	}
+}
+
+// GetDocuments lists a patient's documents, latest first, optionally only those of one
+// appointment_id or category
+func (h *Handler) GetDocuments(c *gin.Context) {
+	patientID, ok := patientParam(c)
+	if !ok {
+		return
+	}
+	var filter database.DocumentFilter
+	if filter.AppointmentID, ok = handlers.OptionalIntQuery(c, "appointment_id"); !ok {
+		return
+	}
+	if raw := c.Query("category"); raw != "" {
+		if !slices.Contains(models.DocumentCategories, raw) {
+			c.Error(apierr.Validation("Invalid category"))
+			return
+		}
+		filter.Category = &raw
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	if _, err := database.GetPatient(c.Request.Context(), patientID); err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+
+	documents, total, err := database.GetDocuments(c.Request.Context(), patientID, filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, documents, total, page)
+}
+
+// UploadDocument stores a file for a patient, sent as the "file" field of a multipart form
+// with its "category" and optionally an "appointment_id" of the patient's and a
+// "description". Files larger than MaxDocumentBytes, empty ones and any whose content is not
+// one of DocumentTypes are refused.
+func (h *Handler) UploadDocument(c *gin.Context) {
+	if h.store == nil {
+		c.Error(apierr.Unavailable(notConfigured))
+		return
+	}
+	patientID, ok := patientParam(c)
+	if !ok {
+		return
+	}
+	// Room for the form's other fields and boundaries on top of the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxDocumentBytes+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.Error(uploadError(err))
+		return
+	}
+	switch {
+	case header.Size > MaxDocumentBytes:
+		c.Error(sizeError())
+		return
+	case header.Size == 0:
+		c.Error(apierr.Validation("file is empty"))
+		return
+	}
+
+	document := models.Document{
+		PatientID: patientID,
+		Category:  c.PostForm("category"),
+		Filename:  cleanFilename(header.Filename),
+		SizeBytes: header.Size,
+	}
+	if !slices.Contains(models.DocumentCategories, document.Category) {
+		c.Error(apierr.Validation("category must be one of " + strings.Join(models.DocumentCategories, ", ")))
+		return
+	}
+	if description := strings.TrimSpace(c.PostForm("description")); description != "" {
+		document.Description = &description
+	}
+	if raw := c.PostForm("appointment_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.Error(apierr.Validation("Invalid appointment_id"))
+			return
+		}
+		document.AppointmentID = &id
+	}
+	if id, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		document.UploadedBy = &id
+	}
+
+	patient, err := database.GetPatient(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	if patient.DeletedAt != nil {
+		c.Error(apierr.NotFound("Patient not found"))
+		return
+	}
+	if document.AppointmentID != nil {
+		appointment, err := database.GetAppointment(c.Request.Context(), *document.AppointmentID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			c.Error(err)
+			return
+		}
+		if appointment == nil || appointment.PatientID != patientID {
+			c.Error(apierr.Validation("Appointment not found for this patient"))
+			return
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	data := make([]byte, header.Size)
+	_, err = io.ReadFull(file, data)
+	file.Close()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !slices.Contains(DocumentTypes, contentType) {
+		c.Error(apierr.Validation("file must be a PDF, JPEG, PNG or WebP image"))
+		return
+	}
+	document.ContentType = contentType
+	sum := sha256.Sum256(data)
+	document.SHA256 = hex.EncodeToString(sum[:])
+	if document.StorageKey, err = newStorageKey(patientID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.store.Put(c.Request.Context(), document.StorageKey, document.ContentType, data); err != nil {
+		c.Error(err)
+		return
+	}
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateDocument(ctx, &document); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityDocuments, document.ID, audit.ActionCreate, newSnapshot(&document))
+	})
+	if err != nil {
+		// Nothing refers to the file; remove it even if the client has gone
+		if err := h.store.Delete(context.WithoutCancel(c.Request.Context()), document.StorageKey); err != nil {
+			slog.WarnContext(c.Request.Context(), "removing unrecorded document file", "key", document.StorageKey, "error", err)
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, document)
+}
+
+func (h *Handler) GetDocument(c *gin.Context) {
+	document, ok := liveDocument(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+// DownloadDocument returns a URL the file can be downloaded from for DownloadURLTTL, and
+// records the download in the access log
+func (h *Handler) DownloadDocument(c *gin.Context) {
+	if h.store == nil {
+		c.Error(apierr.Unavailable(notConfigured))
+		return
+	}
+	document, ok := liveDocument(c)
+	if !ok {
+		return
+	}
+	url := h.store.PresignGet(document.StorageKey, document.Filename, document.ContentType, DownloadURLTTL)
+	access.Documents(c, *document)
+	c.JSON(http.StatusOK, download{URL: url, ExpiresAt: time.Now().Add(DownloadURLTTL).UTC()})
+}
+
+// DeleteDocument removes a document's file and marks the document deleted. Whoever uploaded it
+// may do so within DeleteWindow; otherwise only admins can.
+func (h *Handler) DeleteDocument(c *gin.Context) {
+	if h.store == nil {
+		c.Error(apierr.Unavailable(notConfigured))
+		return
+	}
+	document, ok := liveDocument(c)
+	if !ok {
+		return
+	}
+	userID, isUser := auth.UserIDFromContext(c.Request.Context())
+	ownRecent := isUser && document.UploadedBy != nil && *document.UploadedBy == userID &&
+		time.Since(document.CreatedAt) < DeleteWindow
+	if !ownRecent && !auth.HasRole(c, auth.RoleAdmin) {
+		c.Error(apierr.Forbidden("Only admins can delete documents uploaded by someone else or more than " +
+			strconv.Itoa(int(DeleteWindow.Hours())) + " hours ago"))
+		return
+	}
+	var deletedBy *int
+	if isUser {
+		deletedBy = &userID
+	}
+
+	// The file goes first: if that fails the document is still listed and the delete can be
+	// tried again
+	if err := h.store.Delete(c.Request.Context(), document.StorageKey); err != nil {
+		c.Error(err)
+		return
+	}
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if _, err := database.DeleteDocument(ctx, document.ID, deletedBy); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityDocuments, document.ID, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Document not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Document deleted successfully"})
+}
+
+// patientParam parses the patient id in the path, writing a 400 when it is malformed
+func patientParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid patient ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// liveDocument loads the document in the path, writing a 404 when there is none or it has
+// been deleted
+func liveDocument(c *gin.Context) (*models.Document, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return nil, false
+	}
+	document, err := database.GetDocument(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Document not found"))
+		return nil, false
+	}
+	if document.DeletedAt != nil {
+		c.Error(apierr.NotFound("Document not found"))
+		return nil, false
+	}
+	return document, true
+}
+
+// uploadError reports a failure to read the upload: too large or not a file upload at all
+func uploadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return sizeError()
+	}
+	return apierr.Validation("Upload the document as the file field of a multipart/form-data request")
+}
+
+func sizeError() error {
+	return apierr.Validation("file may be at most " + strconv.Itoa(MaxDocumentBytes>>20) + " MiB")
+}
+
+// cleanFilename keeps a filename printable and bounded, naming it "document" when nothing is left
+func cleanFilename(name string) string {
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name))
+	if runes := []rune(name); len(runes) > maxFilenameLength {
+		name = string(runes[:maxFilenameLength])
+	}
+	if name == "" {
+		return "document"
+	}
+	return name
+}
+
+// newStorageKey names a new file in the object store. The name is random so it tells nothing
+// about the document, and grouped by patient to make a patient's files easy to find.
+func newStorageKey(patientID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "patients/" + strconv.Itoa(patientID) + "/" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,327 @@
+// Medical Appointment Booking System - Employee Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package employees
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the employee endpoints
+type Handler struct {
+	employees database.EmployeeRepo
+	services  database.ServiceRepo
+}
+
+// New returns a Handler that stores employees in the given repository and checks the
+// services assigned to them against services
+func New(employees database.EmployeeRepo, services database.ServiceRepo) *Handler {
+	return &Handler{employees: employees, services: services}
+}
+
+// RegisterRoutes mounts the employee endpoints under /employees
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	h := New(deps.Repos.Employees, deps.Repos.Services)
+	group := r.Group("/employees", auth.Authorize(auth.Employees))
+	{
+		group.GET("", h.GetEmployees)
+		group.GET("/:id", h.GetEmployee)
+		group.POST("", h.CreateEmployee)
+		group.PUT("/:id", h.UpdateEmployee)
+		group.PATCH("/:id", h.PatchEmployee)
+		group.DELETE("/:id", h.DeleteEmployee)
+		group.POST("/:id/restore", auth.RequireRole(auth.RoleAdmin), h.RestoreEmployee)
+		group.GET("/:id/services", h.GetEmployeeServices)
+		group.POST("/:id/services", h.AssignService)
+		group.DELETE("/:id/services/:service_id", h.UnassignService)
+	}
+}
+
+func (h *Handler) GetEmployees(c *gin.Context) {
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	includeDeleted, ok := handlers.IncludeDeleted(c)
+	if !ok {
+		return
+	}
+	employees, total, err := h.employees.List(c.Request.Context(), page, includeDeleted)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, employees, total, page)
+}
+
+func (h *Handler) GetEmployee(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	includeDeleted, ok := handlers.IncludeDeleted(c)
+	if !ok {
+		return
+	}
+
+	employee, err := h.employees.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Employee not found"))
+		return
+	}
+	if employee.DeletedAt != nil && !includeDeleted {
+		c.Error(apierr.NotFound("Employee not found"))
+		return
+	}
+	c.JSON(http.StatusOK, employee)
+}
+
+func (h *Handler) CreateEmployee(c *gin.Context) {
+	var employee models.Employee
+	if !handlers.BindJSON(c, &employee) {
+		return
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.employees.Create(ctx, &employee); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityEmployees, employee.ID, audit.ActionCreate, employee)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, employee)
+}
+
+func (h *Handler) UpdateEmployee(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var employee models.Employee
+	if !handlers.BindJSON(c, &employee) {
+		return
+	}
+	h.saveEmployee(c, id, &employee)
+}
+
+// PatchEmployee updates only the fields given in the body, a JSON merge patch
+func (h *Handler) PatchEmployee(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	existing, err := h.employees.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Employee not found"))
+		return
+	}
+	var employee models.Employee
+	if !handlers.MergeJSON(c, existing, &employee) {
+		return
+	}
+	h.saveEmployee(c, id, &employee)
+}
+
+func (h *Handler) saveEmployee(c *gin.Context, id int, employee *models.Employee) {
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.employees.Update(ctx, id, employee); err != nil {
+			return err
+		}
+		updated, err := h.employees.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityEmployees, id, audit.ActionUpdate, updated)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Employee updated successfully"})
+}
+
+func (h *Handler) DeleteEmployee(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.employees.Delete(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityEmployees, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Employee not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Employee deleted successfully"})
+}
+
+// GetEmployeeServices lists the services assigned to an employee. An employee with no
+// assignments may be booked for any service.
+func (h *Handler) GetEmployeeServices(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	services, err := h.employees.Services(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, services)
+}
+
+func (h *Handler) AssignService(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	var req struct {
+		ServiceID int `json:"service_id" binding:"required"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	if _, err := h.employees.Get(c.Request.Context(), id); err != nil {
+		c.Error(apierr.Lookup(err, "Employee not found"))
+		return
+	}
+	if _, err := h.services.Get(c.Request.Context(), req.ServiceID); err != nil {
+		c.Error(apierr.Validation("Service not found"))
+		return
+	}
+
+	var added bool
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if added, err = h.employees.AssignService(ctx, id, req.ServiceID); err != nil || !added {
+			return err
+		}
+		return h.recordAssignments(ctx, id)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !added {
+		c.JSON(http.StatusOK, gin.H{"message": "Service already assigned"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Service assigned successfully"})
+}
+
+func (h *Handler) UnassignService(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	serviceID, err := strconv.Atoi(c.Param("service_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid service ID"))
+		return
+	}
+
+	var removed bool
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if removed, err = h.employees.UnassignService(ctx, id, serviceID); err != nil || !removed {
+			return err
+		}
+		return h.recordAssignments(ctx, id)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !removed {
+		c.Error(apierr.NotFound("Service is not assigned to this employee"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Service unassigned successfully"})
+}
+
+// recordAssignments audits the services an employee is assigned after a change to them
+func (h *Handler) recordAssignments(ctx context.Context, employeeID int) error {
+	services, err := h.employees.Services(ctx, employeeID)
+	if err != nil {
+		return err
+	}
+	serviceIDs := make([]int, 0, len(services))
+	for _, s := range services {
+		serviceIDs = append(serviceIDs, s.ID)
+	}
+	return audit.Record(ctx, audit.EntityEmployeeServices, employeeID, audit.ActionUpdate, gin.H{"service_ids": serviceIDs})
+}
+
+// RestoreEmployee undoes a soft delete
+func (h *Handler) RestoreEmployee(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var employee *models.Employee
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.employees.Restore(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		if employee, err = h.employees.Get(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityEmployees, id, audit.ActionRestore, employee)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrRestoreConflict) {
+			c.Error(apierr.Conflict(err.Error()))
+			return
+		}
+		c.Error(apierr.Lookup(err, "Deleted employee not found"))
+		return
+	}
+	c.JSON(http.StatusOK, employee)
+}
@@ -0,0 +1,53 @@
+// Medical Appointment Booking System - Handlers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"bookings/apierr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetETag sends a record's version as the response's ETag
+func SetETag(c *gin.Context, version int) {
+	c.Header("ETag", strconv.Quote(strconv.Itoa(version)))
+}
+
+// IfMatch returns the version an update expects to replace, taken from its If-Match header
+// (an ETag from SetETag), writing a 428 when the header is missing and a 412 when it does not
+// name a version
+func IfMatch(c *gin.Context) (int, bool) {
+	raw := strings.TrimSpace(c.GetHeader("If-Match"))
+	if raw == "" {
+		c.Error(apierr.PreconditionRequired("If-Match must give the ETag of the version being updated"))
+		return 0, false
+	}
+	tag, err := strconv.Unquote(strings.TrimPrefix(raw, "W/"))
+	if err != nil {
+		c.Error(apierr.PreconditionFailed("If-Match does not match the current version"))
+		return 0, false
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil {
+		c.Error(apierr.PreconditionFailed("If-Match does not match the current version"))
+		return 0, false
+	}
+	return version, true
+}
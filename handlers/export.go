@@ -0,0 +1,91 @@
+// Medical Appointment Booking System - Handlers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"bookings/apierr"
+	"bookings/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportChunkSize is how many rows an export reads with each query
+const ExportChunkSize = 1000
+
+// ExportChunkTimeout bounds the reading of one chunk of an export
+const ExportChunkTimeout = 30 * time.Second
+
+// Export streams a spreadsheet download in the format named by the format query parameter
+// (csv, the default, or xlsx). header is the first row; next returns the rows after those
+// already written, and none once there are no more.
+//
+// A download can run past REQUEST_TIMEOUT, so Export lifts that deadline and gives each
+// call to next ExportChunkTimeout instead. The first chunk is read before anything is
+// written, so a failure there still gets an error response; a later failure can only cut
+// the download short, and is logged.
+func Export(c *gin.Context, name string, header []string, next func(ctx context.Context) ([][]string, error)) {
+	format := c.DefaultQuery("format", spreadsheet.CSV)
+	if !slices.Contains(spreadsheet.Formats, format) {
+		c.Error(apierr.Validation("format must be one of: " + strings.Join(spreadsheet.Formats, ", ")))
+		return
+	}
+	c.Request = c.Request.WithContext(StreamContext(c))
+	chunk := func() ([][]string, error) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), ExportChunkTimeout)
+		defer cancel()
+		return next(ctx)
+	}
+
+	rows, err := chunk()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format(time.DateOnly), format)
+	c.Header("Content-Type", spreadsheet.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	w, err := spreadsheet.NewWriter(format, c.Writer, name)
+	if err == nil {
+		err = w.WriteRow(header)
+	}
+	for err == nil && len(rows) > 0 {
+		for _, row := range rows {
+			if err = w.WriteRow(row); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			rows, err = chunk()
+		}
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "export cut short", "path", c.Request.URL.Path, "error", err)
+	}
+}
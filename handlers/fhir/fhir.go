@@ -0,0 +1,261 @@
+// Medical Appointment Booking System - FHIR Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fhir
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/notifications"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// ContentType is what every FHIR response is sent as
+const ContentType = "application/fhir+json; charset=utf-8"
+
+// RegisterRoutes mounts the read-only FHIR R4 facade under /fhir. Each resource is guarded by
+// the route group of the records it is mapped from.
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/fhir", outcomes())
+	{
+		patients := group.Group("/Patient", auth.Authorize(auth.Patients))
+		patients.GET("", SearchPatients)
+		patients.GET("/:id", ReadPatient)
+
+		practitioners := group.Group("/Practitioner", auth.Authorize(auth.Employees))
+		practitioners.GET("", SearchPractitioners)
+		practitioners.GET("/:id", ReadPractitioner)
+
+		appointments := group.Group("/Appointment", auth.Authorize(auth.Appointments))
+		appointments.GET("", SearchAppointments)
+		appointments.GET("/:id", ReadAppointment)
+
+		schedules := group.Group("/Schedule", auth.Authorize(auth.Scheduling))
+		schedules.GET("", SearchSchedules)
+		schedules.GET("/:id", ReadSchedule)
+
+		group.GET("/Slot", auth.Authorize(auth.Scheduling), SearchSlots)
+	}
+}
+
+// RegisterPublicRoutes mounts the capability statement, which clients read before signing in
+func RegisterPublicRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.GET("/fhir/metadata", GetCapabilityStatement)
+}
+
+// OperationOutcome reports why a request failed
+type OperationOutcome struct {
+	ResourceType string  `json:"resourceType"`
+	Issue        []Issue `json:"issue"`
+}
+
+// Issue is one problem in an OperationOutcome
+type Issue struct {
+	Severity    string `json:"severity"`
+	Code        string `json:"code"`
+	Diagnostics string `json:"diagnostics,omitempty"`
+}
+
+// issueCodes are the FHIR issue types reported for each error status
+var issueCodes = map[int]string{
+	http.StatusBadRequest:          "invalid",
+	http.StatusUnauthorized:        "login",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not-found",
+	http.StatusGone:                "deleted",
+	http.StatusServiceUnavailable:  "transient",
+	http.StatusInternalServerError: "exception",
+}
+
+// outcomes reports the last error a handler attached as an OperationOutcome rather than the
+// API's error envelope, mapping errors the way apierr.Middleware does
+func outcomes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		var apiErr *apierr.Error
+		switch {
+		case errors.As(err, &apiErr):
+		case errors.Is(err, pgx.ErrNoRows):
+			apiErr = apierr.NotFound("Not found")
+		case errors.Is(err, context.DeadlineExceeded):
+			apiErr = apierr.Unavailable("The request took too long; try again")
+		default:
+			slog.ErrorContext(c.Request.Context(), "request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+			apiErr = &apierr.Error{Status: http.StatusInternalServerError, Message: "Internal server error"}
+		}
+		code, ok := issueCodes[apiErr.Status]
+		if !ok {
+			code = "processing"
+		}
+		respond(c, apiErr.Status, OperationOutcome{
+			ResourceType: "OperationOutcome",
+			Issue:        []Issue{{Severity: "error", Code: code, Diagnostics: apiErr.Message}},
+		})
+	}
+}
+
+// respond writes a FHIR resource
+func respond(c *gin.Context, status int, resource any) {
+	c.Header("Content-Type", ContentType)
+	c.JSON(status, resource)
+}
+
+// respondVersioned writes a single resource with its version as a weak ETag
+func respondVersioned(c *gin.Context, resource any, version int) {
+	c.Header("ETag", "W/"+strconv.Quote(strconv.Itoa(version)))
+	respond(c, http.StatusOK, resource)
+}
+
+// Bundle is a page of search results
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	Type         string        `json:"type"`
+	Total        int           `json:"total"`
+	Link         []BundleLink  `json:"link"`
+	Entry        []BundleEntry `json:"entry"`
+}
+
+// BundleLink points at this page of results or the next
+type BundleLink struct {
+	Relation string `json:"relation"`
+	URL      string `json:"url"`
+}
+
+// BundleEntry is one result
+type BundleEntry struct {
+	FullURL  string       `json:"fullUrl"`
+	Resource any          `json:"resource"`
+	Search   BundleSearch `json:"search"`
+}
+
+// BundleSearch says why an entry is in the results
+type BundleSearch struct {
+	Mode string `json:"mode"`
+}
+
+// searchset starts the bundle for one page of total results, linking to itself and, when
+// more results follow, the next page
+func searchset(c *gin.Context, total int, page database.Page) *Bundle {
+	bundle := &Bundle{ResourceType: "Bundle", Type: "searchset", Total: total, Entry: []BundleEntry{}}
+	self := origin(c) + c.Request.URL.Path
+	query := c.Request.URL.Query()
+	bundle.Link = append(bundle.Link, BundleLink{Relation: "self", URL: withQuery(self, query.Encode())})
+	if page.Offset+page.Limit < total {
+		query.Set("_count", strconv.Itoa(page.Limit))
+		query.Set("_offset", strconv.Itoa(page.Offset+page.Limit))
+		bundle.Link = append(bundle.Link, BundleLink{Relation: "next", URL: withQuery(self, query.Encode())})
+	}
+	return bundle
+}
+
+// add appends a resource to the results
+func (b *Bundle) add(c *gin.Context, resourceType, id string, resource any) {
+	b.Entry = append(b.Entry, BundleEntry{
+		FullURL:  baseURL(c) + "/" + resourceType + "/" + id,
+		Resource: resource,
+		Search:   BundleSearch{Mode: "match"},
+	})
+}
+
+func withQuery(url, query string) string {
+	if query == "" {
+		return url
+	}
+	return url + "?" + query
+}
+
+// baseURL is the facade's base, the URL every resource's is relative to: /fhir under
+// whichever API mount the request came in on
+func baseURL(c *gin.Context) string {
+	path := c.Request.URL.Path
+	return origin(c) + path[:strings.LastIndex(path, "/fhir/")+len("/fhir")]
+}
+
+// origin is PUBLIC_BASE_URL, or the request's own scheme and host when that is not set
+func origin(c *gin.Context) string {
+	if base := notifications.PublicBaseURL(c.Request.Context()); base != "" {
+		return base
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// searchParams are the search parameters each resource type supports
+var searchParams = []struct {
+	resourceType string
+	params       map[string]string
+}{
+	{"Patient", map[string]string{"_id": "token", "name": "string", "family": "string", "given": "string", "identifier": "token"}},
+	{"Practitioner", map[string]string{"_id": "token", "name": "string", "family": "string", "given": "string", "identifier": "token"}},
+	{"Appointment", map[string]string{"patient": "reference", "practitioner": "reference", "date": "date", "status": "token"}},
+	{"Schedule", map[string]string{"actor": "reference"}},
+	{"Slot", map[string]string{"schedule": "reference", "service-type": "token", "start": "date"}},
+}
+
+// GetCapabilityStatement describes the resources the facade serves and how to search them
+func GetCapabilityStatement(c *gin.Context) {
+	resources := make([]gin.H, 0, len(searchParams))
+	for _, resource := range searchParams {
+		params := make([]gin.H, 0, len(resource.params))
+		for _, name := range slices.Sorted(maps.Keys(resource.params)) {
+			params = append(params, gin.H{"name": name, "type": resource.params[name]})
+		}
+		interactions := []gin.H{{"code": "search-type"}}
+		if resource.resourceType != "Slot" {
+			interactions = append(interactions, gin.H{"code": "read"})
+		}
+		resources = append(resources, gin.H{
+			"type":        resource.resourceType,
+			"interaction": interactions,
+			"searchParam": params,
+		})
+	}
+	respond(c, http.StatusOK, gin.H{
+		"resourceType":   "CapabilityStatement",
+		"status":         "active",
+		"date":           "2026-10-16",
+		"kind":           "instance",
+		"fhirVersion":    "4.0.1",
+		"format":         []string{"json"},
+		"implementation": gin.H{"description": "Medical Appointment Booking System", "url": baseURL(c)},
+		"rest": []gin.H{{
+			"mode":     "server",
+			"security": gin.H{"description": "Send the same bearer token as the rest of the API"},
+			"resource": resources,
+		}},
+	})
+}
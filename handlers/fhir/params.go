@@ -0,0 +1,164 @@
+// Medical Appointment Booking System - FHIR Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fhir
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/apierr"
+	"bookings/database"
+	"bookings/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validID matches the public ids Patient and Appointment resources are identified by
+var validID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// parsePage reads the _count and _offset search parameters, writing a 400 when they are
+// invalid. _count defaults to handlers.DefaultPageSize and may not exceed handlers.MaxPageSize.
+func parsePage(c *gin.Context) (database.Page, bool) {
+	page := database.Page{Limit: handlers.DefaultPageSize}
+	if raw := c.Query("_count"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > handlers.MaxPageSize {
+			c.Error(apierr.Validation("_count must be between 1 and " + strconv.Itoa(handlers.MaxPageSize)))
+			return page, false
+		}
+		page.Limit = limit
+	}
+	if raw := c.Query("_offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			c.Error(apierr.Validation("_offset must be a non-negative integer"))
+			return page, false
+		}
+		page.Offset = offset
+	}
+	return page, true
+}
+
+// optionalString reads a string search parameter; nil when it is absent or empty
+func optionalString(c *gin.Context, name string) *string {
+	if value := c.Query(name); value != "" {
+		return &value
+	}
+	return nil
+}
+
+// token reads a token search parameter, dropping the system in "system|code"
+func token(c *gin.Context, name string) *string {
+	value := optionalString(c, name)
+	if value != nil {
+		if _, code, found := strings.Cut(*value, "|"); found {
+			value = &code
+		}
+	}
+	return value
+}
+
+// reference reads a reference search parameter to a resource of the given type, which may
+// be written as the bare id, "Type/id" or an absolute URL ending in "Type/id". It returns
+// "" when the parameter is absent and writes a 400 when it refers to another type.
+func reference(c *gin.Context, name, resourceType string) (string, bool) {
+	value := c.Query(name)
+	if value == "" || !strings.Contains(value, "/") {
+		return value, true
+	}
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || parts[len(parts)-2] != resourceType || parts[len(parts)-1] == "" {
+		c.Error(apierr.Validation(name + " must refer to a " + resourceType))
+		return "", false
+	}
+	return parts[len(parts)-1], true
+}
+
+// idReference reads a reference search parameter to a resource identified by an integer id
+func idReference(c *gin.Context, name, resourceType string) (*int, bool) {
+	value, ok := reference(c, name, resourceType)
+	if !ok || value == "" {
+		return nil, ok
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		c.Error(apierr.Validation(name + " must refer to a " + resourceType + " by its id"))
+		return nil, false
+	}
+	return &id, true
+}
+
+// dateRange reads every value of a date search parameter into the range they all allow,
+// each with a prefix of eq (the default), ge, gt, le or lt. A date without a time covers the
+// whole UTC day; a time must carry its offset. Either end is nil when unbounded.
+func dateRange(c *gin.Context, name string) (from, to *time.Time, ok bool) {
+	for _, raw := range c.QueryArray(name) {
+		prefix, value := "eq", raw
+		if len(raw) > 2 && raw[0] >= 'a' && raw[0] <= 'z' {
+			prefix, value = raw[:2], raw[2:]
+		}
+		start, end, err := instantRange(value)
+		if err != nil {
+			c.Error(apierr.Validation(name + " must be a date (YYYY-MM-DD) or a date and time with an offset"))
+			return nil, nil, false
+		}
+		switch prefix {
+		case "eq":
+			from, to = later(from, start), earlier(to, end)
+		case "ge":
+			from = later(from, start)
+		case "gt":
+			from = later(from, end)
+		case "le":
+			to = earlier(to, end)
+		case "lt":
+			to = earlier(to, start)
+		default:
+			c.Error(apierr.Validation(name + " supports only the eq, ge, gt, le and lt prefixes"))
+			return nil, nil, false
+		}
+	}
+	return from, to, true
+}
+
+// instantRange is the half-open range of instants a date or date and time covers
+func instantRange(value string) (time.Time, time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, date.AddDate(0, 0, 1), nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return at, at.Add(time.Second), nil
+}
+
+func later(current *time.Time, t time.Time) *time.Time {
+	if current != nil && current.After(t) {
+		return current
+	}
+	return &t
+}
+
+func earlier(current *time.Time, t time.Time) *time.Time {
+	if current != nil && current.Before(t) {
+		return current
+	}
+	return &t
+}
@@ -0,0 +1,340 @@
+// Medical Appointment Booking System - FHIR Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fhir
+
+import (
+	"strconv"
+	"time"
+
+	"bookings/availability"
+	"bookings/models"
+)
+
+// Resource types and the parts of them this facade fills in, as defined by FHIR R4
+// (https://hl7.org/fhir/R4/). Every resource is read-only.
+
+// Meta is a resource's version and last change
+type Meta struct {
+	VersionID   string     `json:"versionId,omitempty"`
+	LastUpdated *time.Time `json:"lastUpdated,omitempty"`
+}
+
+// Coding is a code from a code system
+type Coding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+// CodeableConcept is a concept given by codes and/or text
+type CodeableConcept struct {
+	Coding []Coding `json:"coding,omitempty"`
+	Text   string   `json:"text,omitempty"`
+}
+
+// Identifier is a business identifier, e.g. a medical record number
+type Identifier struct {
+	Type   *CodeableConcept `json:"type,omitempty"`
+	System string           `json:"system,omitempty"`
+	Value  string           `json:"value"`
+}
+
+// HumanName is a person's name
+type HumanName struct {
+	Use    string   `json:"use,omitempty"`
+	Text   string   `json:"text,omitempty"`
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+// ContactPoint is a phone number or email address
+type ContactPoint struct {
+	System string `json:"system"`
+	Value  string `json:"value"`
+	Use    string `json:"use,omitempty"`
+}
+
+// Reference points at another resource
+type Reference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+// Period is a time range
+type Period struct {
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// Patient is a patient record, identified by its public id
+type Patient struct {
+	ResourceType string           `json:"resourceType"`
+	ID           string           `json:"id"`
+	Meta         *Meta            `json:"meta,omitempty"`
+	Identifier   []Identifier     `json:"identifier,omitempty"`
+	Active       bool             `json:"active"`
+	Name         []HumanName      `json:"name"`
+	Telecom      []ContactPoint   `json:"telecom,omitempty"`
+	BirthDate    string           `json:"birthDate,omitempty"`
+	Contact      []PatientContact `json:"contact,omitempty"`
+}
+
+// PatientContact is a patient's emergency contact
+type PatientContact struct {
+	Relationship []CodeableConcept `json:"relationship,omitempty"`
+	Name         *HumanName        `json:"name,omitempty"`
+	Telecom      []ContactPoint    `json:"telecom,omitempty"`
+}
+
+// Practitioner is an employee
+type Practitioner struct {
+	ResourceType  string          `json:"resourceType"`
+	ID            string          `json:"id"`
+	Identifier    []Identifier    `json:"identifier,omitempty"`
+	Active        bool            `json:"active"`
+	Name          []HumanName     `json:"name"`
+	Telecom       []ContactPoint  `json:"telecom,omitempty"`
+	Qualification []Qualification `json:"qualification,omitempty"`
+}
+
+// Qualification is a practitioner's license and specialty
+type Qualification struct {
+	Identifier []Identifier    `json:"identifier,omitempty"`
+	Code       CodeableConcept `json:"code"`
+}
+
+// Appointment is a booking, identified by its public id
+type Appointment struct {
+	ResourceType      string                   `json:"resourceType"`
+	ID                string                   `json:"id"`
+	Meta              *Meta                    `json:"meta,omitempty"`
+	Status            string                   `json:"status"`
+	CancelationReason *CodeableConcept         `json:"cancelationReason,omitempty"`
+	ServiceType       []CodeableConcept        `json:"serviceType,omitempty"`
+	AppointmentType   *CodeableConcept         `json:"appointmentType,omitempty"`
+	Start             time.Time                `json:"start"`
+	End               time.Time                `json:"end"`
+	MinutesDuration   int                      `json:"minutesDuration"`
+	Created           *time.Time               `json:"created,omitempty"`
+	Comment           string                   `json:"comment,omitempty"`
+	Participant       []AppointmentParticipant `json:"participant"`
+}
+
+// AppointmentParticipant is the patient or practitioner taking part in an appointment
+type AppointmentParticipant struct {
+	Actor  Reference `json:"actor"`
+	Status string    `json:"status"`
+}
+
+// Schedule is an employee's bookable time, identified by the employee's id
+type Schedule struct {
+	ResourceType    string            `json:"resourceType"`
+	ID              string            `json:"id"`
+	Active          bool              `json:"active"`
+	ServiceType     []CodeableConcept `json:"serviceType,omitempty"`
+	Actor           []Reference       `json:"actor"`
+	PlanningHorizon *Period           `json:"planningHorizon,omitempty"`
+}
+
+// Slot is a free time in a schedule that a service can be booked in
+type Slot struct {
+	ResourceType string            `json:"resourceType"`
+	ID           string            `json:"id"`
+	ServiceType  []CodeableConcept `json:"serviceType,omitempty"`
+	Schedule     Reference         `json:"schedule"`
+	Status       string            `json:"status"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+}
+
+// ServiceSystem identifies service codes: a service's id in this system
+const ServiceSystem = "urn:bookings:service"
+
+// identifierTypes is the HL7 v2 table identifier types are coded from
+const identifierTypes = "http://terminology.hl7.org/CodeSystem/v2-0203"
+
+// appointmentStatuses maps appointment statuses to FHIR's. A checked-in appointment that
+// has not started yet is "checked-in" rather than "booked".
+var appointmentStatuses = map[string]string{
+	"SCHEDULED":   "booked",
+	"CONFIRMED":   "booked",
+	"IN_PROGRESS": "arrived",
+	"COMPLETED":   "fulfilled",
+	"CANCELLED":   "cancelled",
+	"NO_SHOW":     "noshow",
+}
+
+// statusFilter maps a FHIR appointment status searched for to the statuses it covers; nil
+// when none map to it. "checked-in" is not searchable, since it depends on check-in rather
+// than the status.
+func statusFilter(fhirStatus string) []string {
+	var statuses []string
+	for _, status := range models.AppointmentStatuses {
+		if appointmentStatuses[status] == fhirStatus {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+func serviceType(service *models.Service) CodeableConcept {
+	return CodeableConcept{
+		Coding: []Coding{{System: ServiceSystem, Code: strconv.Itoa(service.ID), Display: service.Name}},
+		Text:   service.Name,
+	}
+}
+
+func patientResource(patient *models.Patient) Patient {
+	resource := Patient{
+		ResourceType: "Patient",
+		ID:           patient.PublicID,
+		Meta:         &Meta{VersionID: strconv.Itoa(patient.Version)},
+		Active:       patient.Active,
+		Name: []HumanName{{
+			Use:    "official",
+			Text:   patient.FirstName + " " + patient.LastName,
+			Family: patient.LastName,
+			Given:  []string{patient.FirstName},
+		}},
+	}
+	if patient.MedicalRecordNumber != "" {
+		resource.Identifier = append(resource.Identifier, Identifier{
+			Type:  &CodeableConcept{Coding: []Coding{{System: identifierTypes, Code: "MR", Display: "Medical record number"}}},
+			Value: patient.MedicalRecordNumber,
+		})
+	}
+	if patient.Phone != "" {
+		resource.Telecom = append(resource.Telecom, ContactPoint{System: "phone", Value: patient.Phone, Use: "mobile"})
+	}
+	if patient.Email != "" {
+		resource.Telecom = append(resource.Telecom, ContactPoint{System: "email", Value: patient.Email})
+	}
+	if patient.DateOfBirth != nil {
+		resource.BirthDate = *patient.DateOfBirth
+	}
+	if patient.EmergencyContactName != nil || patient.EmergencyContactPhone != nil {
+		contact := PatientContact{Relationship: []CodeableConcept{{
+			Coding: []Coding{{System: "http://terminology.hl7.org/CodeSystem/v2-0131", Code: "C", Display: "Emergency Contact"}},
+		}}}
+		if patient.EmergencyContactName != nil {
+			contact.Name = &HumanName{Text: *patient.EmergencyContactName}
+		}
+		if patient.EmergencyContactPhone != nil {
+			contact.Telecom = []ContactPoint{{System: "phone", Value: *patient.EmergencyContactPhone}}
+		}
+		resource.Contact = []PatientContact{contact}
+	}
+	return resource
+}
+
+func practitionerResource(employee *models.Employee) Practitioner {
+	resource := Practitioner{
+		ResourceType: "Practitioner",
+		ID:           strconv.Itoa(employee.ID),
+		Active:       employee.Active && employee.DeletedAt == nil,
+		Name: []HumanName{{
+			Use:    "official",
+			Text:   employee.FirstName + " " + employee.LastName,
+			Family: employee.LastName,
+			Given:  []string{employee.FirstName},
+		}},
+	}
+	if employee.Phone != "" {
+		resource.Telecom = append(resource.Telecom, ContactPoint{System: "phone", Value: employee.Phone, Use: "work"})
+	}
+	if employee.Email != "" {
+		resource.Telecom = append(resource.Telecom, ContactPoint{System: "email", Value: employee.Email, Use: "work"})
+	}
+	if employee.LicenseNumber != "" || employee.Specialty != "" {
+		qualification := Qualification{Code: CodeableConcept{Text: employee.Specialty}}
+		if employee.LicenseNumber != "" {
+			qualification.Identifier = []Identifier{{
+				Type:  &CodeableConcept{Coding: []Coding{{System: identifierTypes, Code: "MD", Display: "Medical License number"}}},
+				Value: employee.LicenseNumber,
+			}}
+		}
+		resource.Qualification = []Qualification{qualification}
+	}
+	return resource
+}
+
+// appointmentResource maps an appointment; the patient is referred to by patientPublicID.
+// Medical notes are never included.
+func appointmentResource(appointment *models.Appointment, patientPublicID string, employee *models.Employee, service *models.Service) Appointment {
+	updated := appointment.UpdatedAt.UTC()
+	created := appointment.CreatedAt.UTC()
+	status := appointmentStatuses[appointment.Status]
+	if status == "booked" && appointment.CheckedInAt != nil {
+		status = "checked-in"
+	}
+	resource := Appointment{
+		ResourceType:    "Appointment",
+		ID:              appointment.PublicID,
+		Meta:            &Meta{VersionID: strconv.Itoa(appointment.Version), LastUpdated: &updated},
+		Status:          status,
+		ServiceType:     []CodeableConcept{serviceType(service)},
+		Start:           appointment.StartDatetime.UTC(),
+		End:             appointment.EndDatetime.UTC(),
+		MinutesDuration: int(appointment.EndDatetime.Sub(appointment.StartDatetime) / time.Minute),
+		Created:         &created,
+		Participant: []AppointmentParticipant{
+			{Actor: Reference{Reference: "Patient/" + patientPublicID}, Status: "accepted"},
+			{Actor: Reference{Reference: "Practitioner/" + strconv.Itoa(employee.ID), Display: employee.FirstName + " " + employee.LastName}, Status: "accepted"},
+		},
+	}
+	if appointment.AppointmentType != nil {
+		resource.AppointmentType = &CodeableConcept{Text: *appointment.AppointmentType}
+	}
+	if appointment.Notes != nil {
+		resource.Comment = *appointment.Notes
+	}
+	if appointment.CancellationReason != nil {
+		resource.CancelationReason = &CodeableConcept{Text: *appointment.CancellationReason}
+	}
+	return resource
+}
+
+// scheduleResource maps an employee's schedule, planned availability.SearchDays ahead of now
+func scheduleResource(employee *models.Employee, services []models.Service, now time.Time) Schedule {
+	start := now.UTC()
+	end := start.AddDate(0, 0, availability.SearchDays)
+	resource := Schedule{
+		ResourceType:    "Schedule",
+		ID:              strconv.Itoa(employee.ID),
+		Active:          employee.Active && employee.DeletedAt == nil,
+		Actor:           []Reference{{Reference: "Practitioner/" + strconv.Itoa(employee.ID), Display: employee.FirstName + " " + employee.LastName}},
+		PlanningHorizon: &Period{Start: &start, End: &end},
+	}
+	for i := range services {
+		resource.ServiceType = append(resource.ServiceType, serviceType(&services[i]))
+	}
+	return resource
+}
+
+// slotResource maps a free slot of an employee for a service. Its id names all three, so the
+// same slot always has the same id.
+func slotResource(employee *models.Employee, service *models.Service, slot availability.Interval) Slot {
+	return Slot{
+		ResourceType: "Slot",
+		ID:           strconv.Itoa(employee.ID) + "-" + strconv.Itoa(service.ID) + "-" + strconv.FormatInt(slot.Start.Unix(), 10),
+		ServiceType:  []CodeableConcept{serviceType(service)},
+		Schedule:     Reference{Reference: "Schedule/" + strconv.Itoa(employee.ID)},
+		Status:       "free",
+		Start:        slot.Start.UTC(),
+		End:          slot.End.UTC(),
+	}
+}
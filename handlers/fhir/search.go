@@ -0,0 +1,442 @@
+// Medical Appointment Booking System - FHIR Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package fhir
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Slot searches cover DefaultSlotRange from their start unless they give an end, and at most
+// MaxSlotRange
+const (
+	DefaultSlotRange = 7 * 24 * time.Hour
+	MaxSlotRange     = 31 * 24 * time.Hour
+)
+
+// SearchPatients searches patients by _id, name, family, given and identifier (the medical
+// record number)
+func SearchPatients(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	search := database.PatientSearch{
+		PublicID:            optionalString(c, "_id"),
+		Name:                optionalString(c, "name"),
+		Family:              optionalString(c, "family"),
+		Given:               optionalString(c, "given"),
+		MedicalRecordNumber: token(c, "identifier"),
+	}
+	patients, total, err := database.SearchPatients(c.Request.Context(), search, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	bundle := searchset(c, total, page)
+	ids := make([]int, 0, len(patients))
+	for i := range patients {
+		ids = append(ids, patients[i].ID)
+		bundle.add(c, "Patient", patients[i].PublicID, patientResource(&patients[i]))
+	}
+	access.Patients(c, ids...)
+	respond(c, http.StatusOK, bundle)
+}
+
+// ReadPatient returns a patient by public id; a deleted patient is gone
+func ReadPatient(c *gin.Context) {
+	if !validID.MatchString(c.Param("id")) {
+		c.Error(apierr.NotFound("Patient not found"))
+		return
+	}
+	patient, err := database.GetPatientByPublicID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	if patient.DeletedAt != nil {
+		c.Error(apierr.Gone("Patient has been deleted"))
+		return
+	}
+	access.Patients(c, patient.ID)
+	respondVersioned(c, patientResource(patient), patient.Version)
+}
+
+// SearchPractitioners searches employees by _id, name, family, given and identifier (the
+// license number)
+func SearchPractitioners(c *gin.Context) {
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	search := database.EmployeeSearch{
+		Name:    optionalString(c, "name"),
+		Family:  optionalString(c, "family"),
+		Given:   optionalString(c, "given"),
+		License: token(c, "identifier"),
+	}
+	if raw := c.Query("_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.Error(apierr.Validation("_id must be an integer"))
+			return
+		}
+		search.ID = &id
+	}
+	employees, total, err := database.SearchEmployees(c.Request.Context(), search, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	bundle := searchset(c, total, page)
+	for i := range employees {
+		bundle.add(c, "Practitioner", strconv.Itoa(employees[i].ID), practitionerResource(&employees[i]))
+	}
+	respond(c, http.StatusOK, bundle)
+}
+
+// ReadPractitioner returns an employee by id; a deleted employee is gone
+func ReadPractitioner(c *gin.Context) {
+	employee, ok := activeEmployee(c, "Practitioner not found")
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, practitionerResource(employee))
+}
+
+// SearchAppointments searches appointments by patient, practitioner, date (the appointments
+// overlapping it) and status, a comma-separated list of FHIR statuses
+func SearchAppointments(c *gin.Context) {
+	ctx := c.Request.Context()
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	var filter database.AppointmentFilter
+	if filter.EmployeeID, ok = idReference(c, "practitioner", "Practitioner"); !ok {
+		return
+	}
+	if filter.From, filter.To, ok = dateRange(c, "date"); !ok {
+		return
+	}
+	if raw := c.Query("status"); raw != "" {
+		for _, status := range strings.Split(raw, ",") {
+			statuses := statusFilter(status)
+			if statuses == nil {
+				c.Error(apierr.Validation("Unsupported status " + strconv.Quote(status)))
+				return
+			}
+			filter.Statuses = append(filter.Statuses, statuses...)
+		}
+	}
+	publicID, ok := reference(c, "patient", "Patient")
+	if !ok {
+		return
+	}
+	if publicID != "" {
+		// A patient that does not exist has no appointments
+		if !validID.MatchString(publicID) {
+			respond(c, http.StatusOK, searchset(c, 0, page))
+			return
+		}
+		patient, err := database.GetPatientByPublicID(ctx, publicID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			respond(c, http.StatusOK, searchset(c, 0, page))
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		filter.PatientID = &patient.ID
+	}
+
+	appointments, total, err := database.GetAppointments(ctx, filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	patientIDs := make([]int, 0, len(appointments))
+	for _, appointment := range appointments {
+		patientIDs = append(patientIDs, appointment.PatientID)
+	}
+	publicIDs, err := database.GetPatientPublicIDs(ctx, patientIDs)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	bundle := searchset(c, total, page)
+	records := newRecords()
+	for i := range appointments {
+		appointment := &appointments[i]
+		employee, service, err := records.load(c, appointment)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		bundle.add(c, "Appointment", appointment.PublicID, appointmentResource(appointment, publicIDs[appointment.PatientID], employee, service))
+	}
+	access.Patients(c, uniqueIDs(patientIDs)...)
+	respond(c, http.StatusOK, bundle)
+}
+
+// ReadAppointment returns an appointment by public id
+func ReadAppointment(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !validID.MatchString(c.Param("id")) {
+		c.Error(apierr.NotFound("Appointment not found"))
+		return
+	}
+	appointment, err := database.GetAppointmentByPublicID(ctx, c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	publicIDs, err := database.GetPatientPublicIDs(ctx, []int{appointment.PatientID})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	employee, service, err := newRecords().load(c, appointment)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	access.Patients(c, appointment.PatientID)
+	respondVersioned(c, appointmentResource(appointment, publicIDs[appointment.PatientID], employee, service), appointment.Version)
+}
+
+// SearchSchedules lists employees' schedules, optionally only the one whose actor is the
+// given Practitioner
+func SearchSchedules(c *gin.Context) {
+	ctx := c.Request.Context()
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	var search database.EmployeeSearch
+	if search.ID, ok = idReference(c, "actor", "Practitioner"); !ok {
+		return
+	}
+	employees, total, err := database.SearchEmployees(ctx, search, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	bundle := searchset(c, total, page)
+	now := time.Now()
+	for i := range employees {
+		services, err := database.GetEmployeeServices(ctx, employees[i].ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		bundle.add(c, "Schedule", strconv.Itoa(employees[i].ID), scheduleResource(&employees[i], services, now))
+	}
+	respond(c, http.StatusOK, bundle)
+}
+
+// ReadSchedule returns an employee's schedule by the employee's id
+func ReadSchedule(c *gin.Context) {
+	employee, ok := activeEmployee(c, "Schedule not found")
+	if !ok {
+		return
+	}
+	services, err := database.GetEmployeeServices(c.Request.Context(), employee.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	respond(c, http.StatusOK, scheduleResource(employee, services, time.Now()))
+}
+
+// SearchSlots lists the free slots of one schedule for one service, both required, starting
+// in the start range: from now for DefaultSlotRange unless given, at most MaxSlotRange.
+// Slots are availability.FreeSlots', so they honor every booking rule the booking endpoints do.
+func SearchSlots(c *gin.Context) {
+	ctx := c.Request.Context()
+	page, ok := parsePage(c)
+	if !ok {
+		return
+	}
+	employeeID, ok := idReference(c, "schedule", "Schedule")
+	if !ok {
+		return
+	}
+	serviceCode := token(c, "service-type")
+	if employeeID == nil || serviceCode == nil {
+		c.Error(apierr.Validation("schedule and service-type are required"))
+		return
+	}
+	serviceID, err := strconv.Atoi(*serviceCode)
+	if err != nil {
+		c.Error(apierr.Validation("service-type must be a service id"))
+		return
+	}
+	from, to, ok := dateRange(c, "start")
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if from == nil || from.Before(now) {
+		from = &now
+	}
+	if to == nil {
+		end := from.Add(DefaultSlotRange)
+		to = &end
+	}
+	if to.Sub(*from) > MaxSlotRange {
+		c.Error(apierr.Validation("start may span at most " + strconv.Itoa(int(MaxSlotRange/(24*time.Hour))) + " days"))
+		return
+	}
+
+	// An unknown schedule or service, or one the employee does not offer, has no free slots
+	empty := func() { respond(c, http.StatusOK, searchset(c, 0, page)) }
+	employee, err := database.GetEmployee(ctx, *employeeID)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && employee.DeletedAt != nil) {
+		empty()
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	service, err := database.GetService(ctx, serviceID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		empty()
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	offers, err := database.EmployeeOffersService(ctx, employee.ID, service.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !offers {
+		empty()
+		return
+	}
+
+	var slots []availability.Interval
+	loc := availability.Location(employee)
+	for date := timeutil.LocalDate(*from, loc); ; date = date.AddDate(0, 0, 1) {
+		if start, _ := timeutil.DayBounds(date, loc); !start.Before(*to) {
+			break
+		}
+		free, err := availability.FreeSlots(ctx, employee, service, date, nil, now)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		for _, slot := range free {
+			if !slot.Start.Before(*from) && slot.Start.Before(*to) {
+				slots = append(slots, slot)
+			}
+		}
+	}
+
+	bundle := searchset(c, len(slots), page)
+	for i := page.Offset; i < len(slots) && i < page.Offset+page.Limit; i++ {
+		resource := slotResource(employee, service, slots[i])
+		bundle.add(c, "Slot", resource.ID, resource)
+	}
+	respond(c, http.StatusOK, bundle)
+}
+
+// activeEmployee loads the employee named by the id parameter, writing a 404 when there is
+// none and a 410 when it has been deleted
+func activeEmployee(c *gin.Context, notFound string) (*models.Employee, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.NotFound(notFound))
+		return nil, false
+	}
+	employee, err := database.GetEmployee(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, notFound))
+		return nil, false
+	}
+	if employee.DeletedAt != nil {
+		c.Error(apierr.Gone("Practitioner has been deleted"))
+		return nil, false
+	}
+	return employee, true
+}
+
+// records caches the employees and services appointments refer to, so a page of
+// appointments loads each once
+type records struct {
+	employees map[int]*models.Employee
+	services  map[int]*models.Service
+}
+
+func newRecords() *records {
+	return &records{employees: map[int]*models.Employee{}, services: map[int]*models.Service{}}
+}
+
+// load returns an appointment's employee and service
+func (r *records) load(c *gin.Context, appointment *models.Appointment) (*models.Employee, *models.Service, error) {
+	employee, ok := r.employees[appointment.EmployeeID]
+	if !ok {
+		var err error
+		if employee, err = database.GetEmployee(c.Request.Context(), appointment.EmployeeID); err != nil {
+			return nil, nil, err
+		}
+		r.employees[appointment.EmployeeID] = employee
+	}
+	service, ok := r.services[appointment.ServiceID]
+	if !ok {
+		var err error
+		if service, err = database.GetService(c.Request.Context(), appointment.ServiceID); err != nil {
+			return nil, nil, err
+		}
+		r.services[appointment.ServiceID] = service
+	}
+	return employee, service, nil
+}
+
+func uniqueIDs(ids []int) []int {
+	unique := make([]int, 0, len(ids))
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,208 @@
+// Medical Appointment Booking System - Intake Form Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package forms
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/formschema"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the form template endpoints under /forms and the responses given for
+// an appointment under /appointments/:id/forms
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/forms", auth.Authorize(auth.Forms))
+	{
+		group.GET("", GetFormTemplates)
+		group.GET("/:id", GetFormTemplate)
+		group.POST("", CreateFormTemplate)
+		group.PUT("/:id", UpdateFormTemplate)
+		group.DELETE("/:id", DeleteFormTemplate)
+	}
+	r.GET("/appointments/:id/forms", auth.Authorize(auth.FormResponses), GetAppointmentForms)
+}
+
+// GetFormTemplates lists the form templates by name, optionally only the active (active=true)
+// or inactive ones
+func GetFormTemplates(c *gin.Context) {
+	var active *bool
+	if raw := c.Query("active"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.Error(apierr.Validation("Invalid active; use true or false"))
+			return
+		}
+		active = &value
+	}
+
+	templates, err := database.GetFormTemplates(c.Request.Context(), active)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+func GetFormTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	template, err := database.GetFormTemplate(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Form not found"))
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+func CreateFormTemplate(c *gin.Context) {
+	var template models.FormTemplate
+	if !handlers.BindJSON(c, &template) || !checkTemplate(c, &template) {
+		return
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateFormTemplate(ctx, &template); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityFormTemplates, template.ID, audit.ActionCreate, template)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateFormTemplate replaces a form template. Responses already given keep the questions
+// they answered; deactivating a template stops it being asked before new appointments.
+func UpdateFormTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var template models.FormTemplate
+	if !handlers.BindJSON(c, &template) || !checkTemplate(c, &template) {
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateFormTemplate(ctx, id, &template); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityFormTemplates, id, audit.ActionUpdate, template)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Form not found"))
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteFormTemplate removes a form template no patient has answered; one that has been
+// answered is deactivated instead
+func DeleteFormTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.DeleteFormTemplate(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityFormTemplates, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrFormTemplateAnswered) {
+			c.Error(apierr.Conflict(err.Error()))
+			return
+		}
+		c.Error(apierr.Lookup(err, "Form not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Form deleted successfully"})
+}
+
+// GetAppointmentForms lists the forms of an appointment by name: those asked before it and
+// any others the patient answered for it, each with the patient's response or null. Reading
+// a response is recorded in the access log.
+func GetAppointmentForms(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	appointment, err := database.GetAppointment(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+
+	appointmentForms, err := database.GetAppointmentForms(c.Request.Context(), appointment)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	for _, form := range appointmentForms {
+		if form.Response != nil {
+			access.FormResponses(c, appointment)
+			break
+		}
+	}
+	c.JSON(http.StatusOK, appointmentForms)
+}
+
+// checkTemplate writes a 400 and returns false when the template's schema is not one forms
+// can be answered against, or it names a clinic or service that does not exist
+func checkTemplate(c *gin.Context, template *models.FormTemplate) bool {
+	if _, err := formschema.Parse(template.Schema); err != nil {
+		c.Error(apierr.Validation("Request has invalid fields").WithDetails(gin.H{"fields": map[string]string{"schema": err.Error()}}))
+		return false
+	}
+	if template.ClinicID != nil {
+		if clinic, err := database.GetClinic(c.Request.Context(), *template.ClinicID); err != nil || clinic.DeletedAt != nil {
+			c.Error(apierr.Validation("Clinic not found"))
+			return false
+		}
+	}
+	for _, serviceID := range template.ServiceIDs {
+		if _, err := database.GetService(c.Request.Context(), serviceID); err != nil {
+			c.Error(apierr.Validation("Service not found"))
+			return false
+		}
+	}
+	return true
+}
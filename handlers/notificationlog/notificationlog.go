@@ -0,0 +1,123 @@
+// Medical Appointment Booking System - Notification Log Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notificationlog
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the notification delivery log endpoints under /notifications
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/notifications", auth.Authorize(auth.Notifications))
+	{
+		group.GET("", GetNotifications)
+		group.GET("/:id", GetNotification)
+		group.POST("/:id/retry", RetryNotification)
+	}
+}
+
+// GetNotifications lists the emails and SMS sent, latest first, optionally filtered by
+// appointment_id, patient_id and status
+func GetNotifications(c *gin.Context) {
+	var filter database.NotificationFilter
+	var ok bool
+	if filter.AppointmentID, ok = handlers.OptionalIntQuery(c, "appointment_id"); !ok {
+		return
+	}
+	if filter.PatientID, ok = handlers.OptionalIntQuery(c, "patient_id"); !ok {
+		return
+	}
+	if status := c.Query("status"); status != "" {
+		if !slices.Contains(models.NotificationStatuses, status) {
+			c.Error(apierr.Validation("Invalid status"))
+			return
+		}
+		filter.Status = &status
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	notifications, total, err := database.GetNotifications(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	var patientIDs []int
+	for _, n := range notifications {
+		if n.PatientID != nil && !slices.Contains(patientIDs, *n.PatientID) {
+			patientIDs = append(patientIDs, *n.PatientID)
+		}
+	}
+	access.Patients(c, patientIDs...)
+	handlers.RespondPage(c, notifications, total, page)
+}
+
+// GetNotification returns one entry of the delivery log
+func GetNotification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	notification, err := database.GetNotification(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Notification not found"))
+		return
+	}
+	if notification.PatientID != nil {
+		access.Patients(c, *notification.PatientID)
+	}
+	c.JSON(http.StatusOK, notification)
+}
+
+// RetryNotification queues a FAILED notification for delivery again, with a fresh set of attempts
+func RetryNotification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	retried, err := database.RetryNotification(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !retried {
+		if _, err := database.GetNotification(c.Request.Context(), id); err != nil {
+			c.Error(apierr.Lookup(err, "Notification not found"))
+			return
+		}
+		c.Error(apierr.Unprocessable("Only failed notifications can be retried"))
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Notification queued"})
+}
@@ -0,0 +1,72 @@
+// Medical Appointment Booking System - Handlers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes for list endpoints
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// PageEnvelope wraps one page of a list response with the information needed to fetch the rest
+type PageEnvelope[T any] struct {
+	Data   []T `json:"data"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// ParsePage reads the limit and offset query parameters, writing a 400 when they are invalid.
+// limit defaults to DefaultPageSize and may not exceed MaxPageSize.
+func ParsePage(c *gin.Context) (database.Page, bool) {
+	page := database.Page{Limit: DefaultPageSize}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxPageSize {
+			c.Error(apierr.Validation("limit must be between 1 and " + strconv.Itoa(MaxPageSize)))
+			return page, false
+		}
+		page.Limit = limit
+	}
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			c.Error(apierr.Validation("offset must be a non-negative integer"))
+			return page, false
+		}
+		page.Offset = offset
+	}
+	return page, true
+}
+
+// RespondPage writes a 200 with one page of results in a PageEnvelope
+func RespondPage[T any](c *gin.Context, items []T, total int, page database.Page) {
+	if items == nil {
+		items = []T{}
+	}
+	c.JSON(http.StatusOK, PageEnvelope[T]{Data: items, Total: total, Limit: page.Limit, Offset: page.Offset})
+}
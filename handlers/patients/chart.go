@@ -0,0 +1,346 @@
+// Medical Appointment Booking System - Patient Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package patients
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerChartRoutes mounts the allergies, medications and conditions of the patient chart
+// under /patients/:id
+func registerChartRoutes(r *gin.RouterGroup, h *Handler) {
+	group := r.Group("/patients/:id", auth.Authorize(auth.Chart))
+	{
+		group.GET("/allergies", h.GetAllergies)
+		group.POST("/allergies", h.CreateAllergy)
+		group.PUT("/allergies/:entry_id", h.UpdateAllergy)
+		group.DELETE("/allergies/:entry_id", h.DeleteAllergy)
+		group.GET("/medications", h.GetMedications)
+		group.POST("/medications", h.CreateMedication)
+		group.PUT("/medications/:entry_id", h.UpdateMedication)
+		group.DELETE("/medications/:entry_id", h.DeleteMedication)
+		group.GET("/conditions", h.GetConditions)
+		group.POST("/conditions", h.CreateCondition)
+		group.PUT("/conditions/:entry_id", h.UpdateCondition)
+		group.DELETE("/conditions/:entry_id", h.DeleteCondition)
+	}
+}
+
+// GetAllergies lists the patient's allergies, active and critical ones first
+func (h *Handler) GetAllergies(c *gin.Context) {
+	patientID, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	allergies, err := database.ListAllergies(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	access.Chart(c, patientID)
+	c.JSON(http.StatusOK, allergies)
+}
+
+// CreateAllergy records an allergy on the patient's chart
+func (h *Handler) CreateAllergy(c *gin.Context) {
+	patientID, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	var allergy models.Allergy
+	if !handlers.BindJSON(c, &allergy) {
+		return
+	}
+	allergy.PatientID = patientID
+	allergy.RecordedBy = recorder(c)
+	allergy.Criticality, allergy.Status = orDefault(allergy.Criticality, "UNABLE_TO_ASSESS"), orDefault(allergy.Status, "ACTIVE")
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateAllergy(ctx, &allergy); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAllergies, allergy.ID, audit.ActionCreate, allergy)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, allergy)
+}
+
+// UpdateAllergy replaces an allergy on the patient's chart
+func (h *Handler) UpdateAllergy(c *gin.Context) {
+	patientID, id, ok := h.chartEntryParams(c)
+	if !ok {
+		return
+	}
+	var allergy models.Allergy
+	if !handlers.BindJSON(c, &allergy) {
+		return
+	}
+	allergy.ID, allergy.PatientID = id, patientID
+	allergy.Criticality, allergy.Status = orDefault(allergy.Criticality, "UNABLE_TO_ASSESS"), orDefault(allergy.Status, "ACTIVE")
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateAllergy(ctx, &allergy); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAllergies, allergy.ID, audit.ActionUpdate, allergy)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Allergy not found"))
+		return
+	}
+	c.JSON(http.StatusOK, allergy)
+}
+
+// DeleteAllergy removes an allergy recorded in error; one the patient no longer has is
+// rather marked INACTIVE or RESOLVED
+func (h *Handler) DeleteAllergy(c *gin.Context) {
+	patientID, id, ok := h.chartEntryParams(c)
+	if !ok {
+		return
+	}
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.DeleteAllergy(ctx, patientID, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAllergies, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Allergy not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Allergy deleted successfully"})
+}
+
+// GetMedications lists the patient's medications, active ones first
+func (h *Handler) GetMedications(c *gin.Context) {
+	patientID, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	medications, err := database.ListMedications(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	access.Chart(c, patientID)
+	c.JSON(http.StatusOK, medications)
+}
+
+// CreateMedication records a medication on the patient's chart
+func (h *Handler) CreateMedication(c *gin.Context) {
+	patientID, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	var medication models.Medication
+	if !handlers.BindJSON(c, &medication) {
+		return
+	}
+	medication.PatientID = patientID
+	medication.RecordedBy = recorder(c)
+	medication.Status = orDefault(medication.Status, "ACTIVE")
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateMedication(ctx, &medication); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityMedications, medication.ID, audit.ActionCreate, medication)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, medication)
+}
+
+// UpdateMedication replaces a medication on the patient's chart
+func (h *Handler) UpdateMedication(c *gin.Context) {
+	patientID, id, ok := h.chartEntryParams(c)
+	if !ok {
+		return
+	}
+	var medication models.Medication
+	if !handlers.BindJSON(c, &medication) {
+		return
+	}
+	medication.ID, medication.PatientID = id, patientID
+	medication.Status = orDefault(medication.Status, "ACTIVE")
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateMedication(ctx, &medication); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityMedications, medication.ID, audit.ActionUpdate, medication)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Medication not found"))
+		return
+	}
+	c.JSON(http.StatusOK, medication)
+}
+
+// DeleteMedication removes a medication recorded in error; one the patient no longer takes
+// is rather marked STOPPED
+func (h *Handler) DeleteMedication(c *gin.Context) {
+	patientID, id, ok := h.chartEntryParams(c)
+	if !ok {
+		return
+	}
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.DeleteMedication(ctx, patientID, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityMedications, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Medication not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Medication deleted successfully"})
+}
+
+// GetConditions lists the patient's conditions, active ones first
+func (h *Handler) GetConditions(c *gin.Context) {
+	patientID, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	conditions, err := database.ListConditions(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	access.Chart(c, patientID)
+	c.JSON(http.StatusOK, conditions)
+}
+
+// CreateCondition records a condition on the patient's chart
+func (h *Handler) CreateCondition(c *gin.Context) {
+	patientID, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	var condition models.Condition
+	if !handlers.BindJSON(c, &condition) {
+		return
+	}
+	condition.PatientID = patientID
+	condition.RecordedBy = recorder(c)
+	condition.Status = orDefault(condition.Status, "ACTIVE")
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateCondition(ctx, &condition); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityConditions, condition.ID, audit.ActionCreate, condition)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, condition)
+}
+
+// UpdateCondition replaces a condition on the patient's chart
+func (h *Handler) UpdateCondition(c *gin.Context) {
+	patientID, id, ok := h.chartEntryParams(c)
+	if !ok {
+		return
+	}
+	var condition models.Condition
+	if !handlers.BindJSON(c, &condition) {
+		return
+	}
+	condition.ID, condition.PatientID = id, patientID
+	condition.Status = orDefault(condition.Status, "ACTIVE")
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateCondition(ctx, &condition); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityConditions, condition.ID, audit.ActionUpdate, condition)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Condition not found"))
+		return
+	}
+	c.JSON(http.StatusOK, condition)
+}
+
+// DeleteCondition removes a condition recorded in error; one the patient got over is rather
+// marked RESOLVED
+func (h *Handler) DeleteCondition(c *gin.Context) {
+	patientID, id, ok := h.chartEntryParams(c)
+	if !ok {
+		return
+	}
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.DeleteCondition(ctx, patientID, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityConditions, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Condition not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Condition deleted successfully"})
+}
+
+// chartEntryParams parses the patient and chart entry ids of the path, checking the patient
+// exists and is not deleted
+func (h *Handler) chartEntryParams(c *gin.Context) (patientID, id int, ok bool) {
+	if patientID, ok = h.activePatientID(c); !ok {
+		return 0, 0, false
+	}
+	id, err := strconv.Atoi(c.Param("entry_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid entry ID"))
+		return 0, 0, false
+	}
+	return patientID, id, true
+}
+
+// recorder is the user recording a chart entry, nil for an API key
+func recorder(c *gin.Context) *int {
+	if id, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		return &id
+	}
+	return nil
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,79 @@
+// Medical Appointment Booking System - Patient Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package patients
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"bookings/access"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportColumns heads the columns of a patient export, named as in the patient JSON
+var exportColumns = []string{
+	"id", "public_id", "first_name", "last_name", "email", "phone", "date_of_birth", "medical_record_number",
+	"insurance_provider", "insurance_id", "emergency_contact_name", "emergency_contact_phone",
+	"active", "no_show_count", "created_at", "deleted_at",
+}
+
+// ExportPatients downloads every patient as CSV or XLSX (format), in id order, with
+// soft-deleted ones only when include_deleted is set. Every exported patient is recorded
+// in the access log.
+func ExportPatients(c *gin.Context) {
+	includeDeleted, ok := handlers.IncludeDeleted(c)
+	if !ok {
+		return
+	}
+
+	afterID := 0
+	handlers.Export(c, "patients", exportColumns, func(ctx context.Context) ([][]string, error) {
+		patients, err := database.ExportPatients(ctx, includeDeleted, afterID, handlers.ExportChunkSize)
+		if err != nil || len(patients) == 0 {
+			return nil, err
+		}
+		afterID = patients[len(patients)-1].ID
+
+		rows := make([][]string, 0, len(patients))
+		ids := make([]int, 0, len(patients))
+		for _, patient := range patients {
+			rows = append(rows, exportRow(patient))
+			ids = append(ids, patient.ID)
+		}
+		access.Patients(c, ids...)
+		return rows, nil
+	})
+}
+
+func exportRow(p models.Patient) []string {
+	var deletedAt string
+	if p.DeletedAt != nil {
+		deletedAt = p.DeletedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		strconv.Itoa(p.ID), p.PublicID, p.FirstName, p.LastName, p.Email, p.Phone, spreadsheet.String(p.DateOfBirth), p.MedicalRecordNumber,
+		spreadsheet.String(p.InsuranceProvider), spreadsheet.String(p.InsuranceID),
+		spreadsheet.String(p.EmergencyContactName), spreadsheet.String(p.EmergencyContactPhone),
+		strconv.FormatBool(p.Active), strconv.Itoa(p.NoShowCount), p.CreatedAt.UTC().Format(time.RFC3339), deletedAt,
+	}
+}
@@ -0,0 +1,409 @@
+// Medical Appointment Booking System - Patient Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package patients
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits on a patient import
+const (
+	MaxImportBytes = 10 << 20
+	MaxImportRows  = 10000
+	// ImportBatchSize is how many rows are inserted per round trip
+	ImportBatchSize = 500
+)
+
+// importColumns are the CSV columns an import may have, named as in the patient JSON;
+// first_name and last_name are required
+var importColumns = []string{
+	"first_name", "last_name", "email", "phone", "date_of_birth", "medical_record_number",
+	"insurance_provider", "insurance_id", "emergency_contact_name", "emergency_contact_phone", "active",
+}
+
+// birthDateLayouts are the date_of_birth formats an import accepts; all are read as
+// year, month, day, since day-first and month-first dates cannot be told apart
+var birthDateLayouts = []string{"2006-01-02", "2006/01/02", "20060102"}
+
+// Row outcomes in an import report
+const (
+	RowImported = "imported"
+	RowValid    = "valid"
+	RowInvalid  = "invalid"
+)
+
+// ImportReport is the outcome of a patient import, row by row
+type ImportReport struct {
+	DryRun   bool        `json:"dry_run"`
+	Total    int         `json:"total"`
+	Imported int         `json:"imported"`
+	Invalid  int         `json:"invalid"`
+	Rows     []ImportRow `json:"rows"`
+}
+
+// ImportRow is the outcome of one CSV row. Row is its line in the file, the header being line 1.
+type ImportRow struct {
+	Row       int               `json:"row"`
+	Status    string            `json:"status"`
+	PatientID *int              `json:"patient_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// pendingPatient is a valid row waiting for its batch to be inserted
+type pendingPatient struct {
+	report  int // index in ImportReport.Rows
+	patient models.Patient
+}
+
+// ImportPatients creates patients from a CSV file, sent as the body (text/csv) or as the
+// "file" field of a multipart form. The header row names the columns (importColumns, in
+// any order). Rows are validated as they are read, as POST /patients would, and also
+// refused when their email or medical record number is already taken, by another row or an
+// existing patient. Valid rows are inserted in batches in one transaction, so either all of
+// them are imported or, on an unexpected error, none. With dry_run=true nothing is inserted.
+// Rows without a medical record number get a generated one, with the prefix of the clinic
+// given as clinic_id if it has one. The response reports every row.
+func (h *Handler) ImportPatients(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+	clinicID, ok := handlers.OptionalIntQuery(c, "clinic_id")
+	if !ok {
+		return
+	}
+	if clinicID != nil && !clinicExists(c, *clinicID) {
+		return
+	}
+	body, ok := importBody(c)
+	if !ok {
+		return
+	}
+	defer body.Close()
+
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		c.Error(readError(err, "CSV header row could not be read"))
+		return
+	}
+	columns, ok := importHeader(c, header)
+	if !ok {
+		return
+	}
+
+	report := ImportReport{DryRun: dryRun, Rows: []ImportRow{}}
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		seenEmails, seenMRNs := map[string]bool{}, map[string]bool{}
+		var batch []pendingPatient
+		flush := func() error {
+			patients, err := insertBatch(ctx, &report, batch, dryRun)
+			batch = batch[:0]
+			if err != nil {
+				return err
+			}
+			for i := range patients {
+				if err := audit.Record(ctx, audit.EntityPatients, patients[i].ID, audit.ActionCreate, patients[i]); err != nil {
+					return err
+				}
+				if err := webhooks.Publish(ctx, webhooks.EventPatientCreated, webhooks.PatientData(&patients[i])); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				report.Rows = append(report.Rows, ImportRow{Row: parseErr.StartLine, Status: RowInvalid, Errors: map[string]string{"row": parseErr.Err.Error()}})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if len(report.Rows) == MaxImportRows {
+				return apierr.Validation("CSV may have at most " + strconv.Itoa(MaxImportRows) + " rows")
+			}
+			line, _ := reader.FieldPos(0)
+
+			patient, fieldErrors := importRow(columns, record)
+			if fieldErrors == nil {
+				fieldErrors = duplicateErrors(&patient, seenEmails, seenMRNs)
+			}
+			if fieldErrors != nil {
+				report.Rows = append(report.Rows, ImportRow{Row: line, Status: RowInvalid, Errors: fieldErrors})
+				continue
+			}
+			patient.ClinicID = clinicID
+			report.Rows = append(report.Rows, ImportRow{Row: line})
+			batch = append(batch, pendingPatient{report: len(report.Rows) - 1, patient: patient})
+			if len(batch) == ImportBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return flush()
+	})
+	if err != nil {
+		c.Error(readError(err, "CSV could not be read"))
+		return
+	}
+	if len(report.Rows) == 0 {
+		c.Error(apierr.Validation("CSV has no rows"))
+		return
+	}
+
+	report.Total = len(report.Rows)
+	for _, row := range report.Rows {
+		switch row.Status {
+		case RowImported:
+			report.Imported++
+		case RowInvalid:
+			report.Invalid++
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// importBody returns the uploaded CSV, writing a 400 when there is none
+func importBody(c *gin.Context) (io.ReadCloser, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportBytes)
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	switch mediaType {
+	case "multipart/form-data":
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.Error(readError(err, "Upload the CSV as the file field"))
+			return nil, false
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.Error(err)
+			return nil, false
+		}
+		return file, true
+	case "text/csv", "application/csv":
+		return c.Request.Body, true
+	default:
+		c.Error(apierr.Validation("Send the CSV as text/csv or as the file field of a multipart/form-data upload"))
+		return nil, false
+	}
+}
+
+// readError reports a failure to read the upload: too large, malformed, or (for errors that
+// are not about the upload) unchanged
+func readError(err error, message string) error {
+	var tooLarge *http.MaxBytesError
+	var parseErr *csv.ParseError
+	var apiErr *apierr.Error
+	switch {
+	case errors.As(err, &tooLarge):
+		return apierr.Validation("CSV may be at most " + strconv.Itoa(MaxImportBytes>>20) + " MiB")
+	case errors.As(err, &apiErr):
+		return err
+	case errors.As(err, &parseErr), errors.Is(err, io.EOF), errors.Is(err, http.ErrMissingFile):
+		return apierr.Validation(message)
+	}
+	return err
+}
+
+// importHeader maps each importColumns name to its position in the header, writing a 400
+// when a column is unknown, repeated or a required one is missing
+func importHeader(c *gin.Context, header []string) (map[string]int, bool) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(importColumns, name) {
+			c.Error(apierr.Validation("Unknown CSV column " + strconv.Quote(name) + "; columns are " + strings.Join(importColumns, ", ")))
+			return nil, false
+		}
+		if _, repeated := columns[name]; repeated {
+			c.Error(apierr.Validation("CSV column " + strconv.Quote(name) + " appears twice"))
+			return nil, false
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"first_name", "last_name"} {
+		if _, ok := columns[required]; !ok {
+			c.Error(apierr.Validation("CSV must have a " + required + " column"))
+			return nil, false
+		}
+	}
+	return columns, true
+}
+
+// importRow reads a patient from a CSV row, returning a message per invalid field, or nil.
+// Empty cells are missing values; active defaults to true.
+func importRow(columns map[string]int, record []string) (models.Patient, map[string]string) {
+	cell := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	optional := func(name string) *string {
+		if value := cell(name); value != "" {
+			return &value
+		}
+		return nil
+	}
+	patient := models.Patient{
+		FirstName:            cell("first_name"),
+		LastName:             cell("last_name"),
+		Email:                cell("email"),
+		Phone:                cell("phone"),
+		MedicalRecordNumber:  cell("medical_record_number"),
+		InsuranceProvider:    optional("insurance_provider"),
+		InsuranceID:          optional("insurance_id"),
+		EmergencyContactName: optional("emergency_contact_name"),
+		Active:               true,
+	}
+	patient.EmergencyContactPhone = optional("emergency_contact_phone")
+
+	errs := handlers.FieldErrors(&patient)
+	addError := func(field, message string) {
+		if errs == nil {
+			errs = map[string]string{}
+		}
+		errs[field] = message
+	}
+	if value := cell("date_of_birth"); value != "" {
+		if date, ok := parseBirthDate(value); ok {
+			patient.DateOfBirth = &date
+		} else {
+			addError("date_of_birth", "must be a past date as YYYY-MM-DD, YYYY/MM/DD or YYYYMMDD")
+		}
+	}
+	if value := cell("active"); value != "" {
+		active, err := strconv.ParseBool(value)
+		if err != nil {
+			addError("active", "must be true or false")
+		}
+		patient.Active = active
+	}
+	return patient, errs
+}
+
+// parseBirthDate reads a date of birth in one of birthDateLayouts as YYYY-MM-DD; it must not
+// be in the future
+func parseBirthDate(value string) (string, bool) {
+	for _, layout := range birthDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Format(time.DateOnly), !date.After(time.Now())
+		}
+	}
+	return "", false
+}
+
+// duplicateErrors reports an email or medical record number an earlier row already has,
+// otherwise remembering the patient's
+func duplicateErrors(patient *models.Patient, seenEmails, seenMRNs map[string]bool) map[string]string {
+	errs := map[string]string{}
+	email := strings.ToLower(patient.Email)
+	if email != "" && seenEmails[email] {
+		errs["email"] = "is also on an earlier row"
+	}
+	if patient.MedicalRecordNumber != "" && seenMRNs[patient.MedicalRecordNumber] {
+		errs["medical_record_number"] = "is also on an earlier row"
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if email != "" {
+		seenEmails[email] = true
+	}
+	if patient.MedicalRecordNumber != "" {
+		seenMRNs[patient.MedicalRecordNumber] = true
+	}
+	return nil
+}
+
+// insertBatch refuses the rows of a batch whose email or medical record number an existing
+// patient has, inserts the rest unless dryRun, and fills in their report rows. It returns
+// the patients created.
+func insertBatch(ctx context.Context, report *ImportReport, batch []pendingPatient, dryRun bool) ([]models.Patient, error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	var emails, mrns []string
+	for _, pending := range batch {
+		if pending.patient.Email != "" {
+			emails = append(emails, pending.patient.Email)
+		}
+		if pending.patient.MedicalRecordNumber != "" {
+			mrns = append(mrns, pending.patient.MedicalRecordNumber)
+		}
+	}
+	takenEmails, takenMRNs, err := database.TakenPatientKeys(ctx, emails, mrns)
+	if err != nil {
+		return nil, err
+	}
+
+	var patients []models.Patient
+	var rows []int
+	for _, pending := range batch {
+		row := &report.Rows[pending.report]
+		errs := map[string]string{}
+		if takenEmails[strings.ToLower(pending.patient.Email)] {
+			errs["email"] = "already belongs to a patient"
+		}
+		if takenMRNs[pending.patient.MedicalRecordNumber] {
+			errs["medical_record_number"] = "already belongs to a patient"
+		}
+		if len(errs) > 0 {
+			row.Status, row.Errors = RowInvalid, errs
+			continue
+		}
+		row.Status = RowValid
+		patients = append(patients, pending.patient)
+		rows = append(rows, pending.report)
+	}
+	if dryRun || len(patients) == 0 {
+		return nil, nil
+	}
+
+	if err := database.CreatePatients(ctx, patients); err != nil {
+		return nil, err
+	}
+	for i, index := range rows {
+		row := &report.Rows[index]
+		row.Status, row.PatientID = RowImported, &patients[i].ID
+	}
+	return patients, nil
+}
@@ -0,0 +1,145 @@
+// Medical Appointment Booking System - Patient Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package patients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDuplicates lists pairs of patients who look like the same person, as found by
+// database.FindDuplicatePatients. The response is paginated.
+func (h *Handler) GetDuplicates(c *gin.Context) {
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	duplicates, total, err := database.FindDuplicatePatients(c.Request.Context(), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	ids := make([]int, 0, 2*len(duplicates))
+	for _, d := range duplicates {
+		ids = append(ids, d.Patient.ID, d.Duplicate.ID)
+	}
+	access.Patients(c, ids...)
+	handlers.RespondPage(c, duplicates, total, page)
+}
+
+// MergePatients merges the patient other_id into the patient id, as described on
+// database.MergePatients. Both patients get a MERGE audit entry, and the appointments,
+// waiting list entries and documents that moved an update entry each. It answers the kept
+// patient, the merged one and the ids of the records that moved.
+func (h *Handler) MergePatients(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	otherID, err := strconv.Atoi(c.Param("other_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid other_id"))
+		return
+	}
+	if otherID == id {
+		c.Error(apierr.Validation("A patient cannot be merged into itself"))
+		return
+	}
+
+	var merge *database.PatientMerge
+	var kept, merged *models.Patient
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if merge, err = database.MergePatients(ctx, id, otherID, time.Now()); err != nil {
+			return err
+		}
+		if kept, err = h.patients.Get(ctx, id); err != nil {
+			return err
+		}
+		if merged, err = h.patients.Get(ctx, otherID); err != nil {
+			return err
+		}
+		return recordMerge(ctx, merge, kept, merged)
+	})
+	switch {
+	case errors.Is(err, database.ErrPatientErased):
+		c.Error(apierr.Conflict("Erased patients cannot be merged"))
+		return
+	case errors.Is(err, database.ErrPatientMerged):
+		c.Error(apierr.Conflict("Patient has already been merged into another patient"))
+		return
+	case errors.Is(err, database.ErrMergeIntoDeleted):
+		c.Error(apierr.Unprocessable("Patient is deleted; restore it before merging another patient into it"))
+		return
+	case err != nil:
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"patient": kept, "merged": merged, "moved": merge})
+}
+
+// recordMerge audits both patients of a merge and every record that moved between them
+func recordMerge(ctx context.Context, merge *database.PatientMerge, kept, merged *models.Patient) error {
+	if err := audit.Record(ctx, audit.EntityPatients, merged.ID, audit.ActionMerge, merged); err != nil {
+		return err
+	}
+	if err := audit.Record(ctx, audit.EntityPatients, kept.ID, audit.ActionMerge, kept); err != nil {
+		return err
+	}
+	for _, appointmentID := range merge.AppointmentIDs {
+		appointment, err := database.GetAppointment(ctx, appointmentID)
+		if err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityAppointments, appointmentID, audit.ActionUpdate, appointment); err != nil {
+			return err
+		}
+	}
+	for _, itemID := range merge.WaitingListIDs {
+		item, err := database.GetWaitingListItem(ctx, itemID)
+		if err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityWaitingList, itemID, audit.ActionUpdate, item); err != nil {
+			return err
+		}
+	}
+	for _, documentID := range merge.DocumentIDs {
+		document, err := database.GetDocument(ctx, documentID)
+		if err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityDocuments, documentID, audit.ActionUpdate, document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
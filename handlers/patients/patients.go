@@ -0,0 +1,324 @@
+// Medical Appointment Booking System - Patient Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package patients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the patient endpoints
+type Handler struct {
+	patients database.PatientRepo
+}
+
+// New returns a Handler that stores patients in the given repository
+func New(patients database.PatientRepo) *Handler {
+	return &Handler{patients: patients}
+}
+
+// RegisterRoutes mounts the patient endpoints under /patients
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	h := New(deps.Repos.Patients)
+	group := r.Group("/patients", auth.Authorize(auth.Patients))
+	{
+		group.GET("", h.GetPatients)
+		group.GET("/:id", h.GetPatient)
+		group.POST("", h.CreatePatient)
+		group.GET("/export", auth.RequireRole(auth.RoleAdmin), ExportPatients)
+		group.GET("/duplicates", h.GetDuplicates)
+		group.GET("/mrn/:mrn", h.GetPatientByMRN)
+		group.POST("/import", auth.RequireRole(auth.RoleAdmin), h.ImportPatients)
+		group.PUT("/:id", h.UpdatePatient)
+		group.PATCH("/:id", h.PatchPatient)
+		group.DELETE("/:id", h.DeletePatient)
+		group.POST("/:id/restore", auth.RequireRole(auth.RoleAdmin), h.RestorePatient)
+		group.POST("/:id/erase", auth.RequireRole(auth.RoleAdmin), h.ErasePatient)
+		group.GET("/:id/export", auth.RequireRole(auth.RoleAdmin), ExportPatientData(deps.Storage))
+		group.POST("/:id/merge/:other_id", auth.RequireRole(auth.RoleAdmin), h.MergePatients)
+		group.GET("/:id/relationships", h.GetRelationships)
+		group.POST("/:id/dependents", h.AddDependent)
+		group.DELETE("/:id/dependents/:dependent_id", h.RemoveDependent)
+		group.GET("/:id/notification-preferences", h.GetNotificationPreferences)
+		group.PUT("/:id/notification-preferences", h.UpdateNotificationPreferences)
+	}
+	registerChartRoutes(r, h)
+}
+
+func (h *Handler) GetPatients(c *gin.Context) {
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	includeDeleted, ok := handlers.IncludeDeleted(c)
+	if !ok {
+		return
+	}
+	patients, total, err := h.patients.List(c.Request.Context(), page, includeDeleted)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	ids := make([]int, 0, len(patients))
+	for _, patient := range patients {
+		ids = append(ids, patient.ID)
+	}
+	access.Patients(c, ids...)
+	handlers.RespondPage(c, patients, total, page)
+}
+
+func (h *Handler) GetPatient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	includeDeleted, ok := handlers.IncludeDeleted(c)
+	if !ok {
+		return
+	}
+
+	patient, err := h.patients.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	if patient.DeletedAt != nil && !includeDeleted {
+		c.Error(apierr.NotFound("Patient not found"))
+		return
+	}
+	access.Patients(c, id)
+	handlers.SetETag(c, patient.Version)
+	c.JSON(http.StatusOK, patient)
+}
+
+// GetPatientByMRN looks a patient up by medical record number, as read from a wristband or
+// a referral letter. Soft-deleted patients are only found with include_deleted=true.
+func (h *Handler) GetPatientByMRN(c *gin.Context) {
+	includeDeleted, ok := handlers.IncludeDeleted(c)
+	if !ok {
+		return
+	}
+
+	patient, err := database.GetPatientByMRN(c.Request.Context(), c.Param("mrn"))
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	if patient.DeletedAt != nil && !includeDeleted {
+		c.Error(apierr.NotFound("Patient not found"))
+		return
+	}
+	access.Patients(c, patient.ID)
+	handlers.SetETag(c, patient.Version)
+	c.JSON(http.StatusOK, patient)
+}
+
+// CreatePatient registers a patient. Without a medical record number one is generated, with
+// the prefix of the clinic given as clinic_id if it has one.
+func (h *Handler) CreatePatient(c *gin.Context) {
+	var patient models.Patient
+	if !handlers.BindJSON(c, &patient) {
+		return
+	}
+	if patient.ClinicID != nil && !clinicExists(c, *patient.ClinicID) {
+		return
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.patients.Create(ctx, &patient); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityPatients, patient.ID, audit.ActionCreate, patient); err != nil {
+			return err
+		}
+		return webhooks.Publish(ctx, webhooks.EventPatientCreated, webhooks.PatientData(&patient))
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrMRNTaken) {
+			c.Error(apierr.Conflict("Medical record number already belongs to a patient"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	handlers.SetETag(c, patient.Version)
+	c.JSON(http.StatusCreated, patient)
+}
+
+// UpdatePatient replaces a patient. If-Match must carry the ETag of the version being
+// replaced; a stale one is refused with a 412 so concurrent edits are not lost.
+func (h *Handler) UpdatePatient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	version, ok := handlers.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	var patient models.Patient
+	if !handlers.BindJSON(c, &patient) {
+		return
+	}
+	h.savePatient(c, id, version, &patient)
+}
+
+// PatchPatient updates only the fields given in the body, a JSON merge patch, and otherwise
+// behaves as UpdatePatient
+func (h *Handler) PatchPatient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	version, ok := handlers.IfMatch(c)
+	if !ok {
+		return
+	}
+
+	existing, err := h.patients.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	if existing.Version != version {
+		c.Error(apierr.PreconditionFailed(stalePatient))
+		return
+	}
+	var patient models.Patient
+	if !handlers.MergeJSON(c, existing, &patient) {
+		return
+	}
+	h.savePatient(c, id, version, &patient)
+}
+
+// stalePatient explains a 412 on a patient update
+const stalePatient = "Patient was changed by someone else; reload it and try again"
+
+// savePatient stores an update of the patient read at version and answers with its new ETag
+func (h *Handler) savePatient(c *gin.Context, id, version int, patient *models.Patient) {
+	patient.Version = version
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.patients.Update(ctx, id, patient); err != nil {
+			return err
+		}
+		updated, err := h.patients.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatients, id, audit.ActionUpdate, updated)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrStaleVersion) {
+			c.Error(apierr.PreconditionFailed(stalePatient))
+			return
+		}
+		if errors.Is(err, database.ErrMRNTaken) {
+			c.Error(apierr.Conflict("Medical record number already belongs to a patient"))
+			return
+		}
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	handlers.SetETag(c, patient.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "Patient updated successfully"})
+}
+
+func (h *Handler) DeletePatient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.patients.Delete(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatients, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Patient deleted successfully"})
+}
+
+// RestorePatient undoes a soft delete
+func (h *Handler) RestorePatient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var patient *models.Patient
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.patients.Restore(ctx, id); err != nil {
+			return err
+		}
+		var err error
+		if patient, err = h.patients.Get(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatients, id, audit.ActionRestore, patient)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrRestoreConflict) {
+			c.Error(apierr.Conflict(err.Error()))
+			return
+		}
+		if errors.Is(err, database.ErrPatientErased) {
+			c.Error(apierr.Conflict("An erased patient cannot be restored"))
+			return
+		}
+		if errors.Is(err, database.ErrPatientMerged) {
+			c.Error(apierr.Conflict("A patient merged into another cannot be restored"))
+			return
+		}
+		c.Error(apierr.Lookup(err, "Deleted patient not found"))
+		return
+	}
+	c.JSON(http.StatusOK, patient)
+}
+
+// clinicExists writes a 400 and returns false when the clinic registering a patient does not
+// exist
+func clinicExists(c *gin.Context, clinicID int) bool {
+	if clinic, err := database.GetClinic(c.Request.Context(), clinicID); err != nil || clinic.DeletedAt != nil {
+		c.Error(apierr.Validation("Clinic not found"))
+		return false
+	}
+	return true
+}
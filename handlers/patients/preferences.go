@@ -0,0 +1,91 @@
+// Medical Appointment Booking System - Patient Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package patients
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotificationPreferences returns how the patient wants to be notified; a patient who
+// never set any gets the defaults
+func (h *Handler) GetNotificationPreferences(c *gin.Context) {
+	id, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	prefs, err := database.GetNotificationPreferences(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdateNotificationPreferences replaces the patient's notification preferences. Setting a
+// channel other than NONE clears an earlier unsubscribe.
+func (h *Handler) UpdateNotificationPreferences(c *gin.Context) {
+	id, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	var prefs models.NotificationPreferences
+	if !handlers.BindJSON(c, &prefs) {
+		return
+	}
+
+	prefs.PatientID = id
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.SetNotificationPreferences(ctx, &prefs); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityNotificationPreferences, id, audit.ActionUpdate, prefs)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// activePatientID parses the :id parameter and checks the patient exists and is not deleted
+func (h *Handler) activePatientID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return 0, false
+	}
+	patient, err := h.patients.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return 0, false
+	}
+	if patient.DeletedAt != nil {
+		c.Error(apierr.NotFound("Patient not found"))
+		return 0, false
+	}
+	return id, true
+}
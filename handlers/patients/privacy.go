@@ -0,0 +1,213 @@
+// Medical Appointment Booking System - Patient Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package patients
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// personalFields are the patient fields erasure clears; they are removed from the patient's
+// audit entries too, with the age worked out from the date of birth
+var personalFields = []string{
+	"first_name", "last_name", "email", "phone", "phone_display", "date_of_birth", "age",
+	"medical_record_number",
+	"insurance_provider", "insurance_id", "emergency_contact_name", "emergency_contact_phone",
+}
+
+// documentFetchTimeout bounds fetching one file from the object store into a data export
+const documentFetchTimeout = time.Minute
+
+// ErasePatient erases a patient's personal details on request (GDPR right to erasure). The
+// record of their care is kept under a patient named "Erased Patient", as described on
+// database.ErasePatient, and the erased fields are removed from the audit log. A patient
+// with appointments still to attend answers 422, and one already erased 409.
+func (h *Handler) ErasePatient(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var patient *models.Patient
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		userID, err := database.ErasePatient(ctx, id, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := database.RedactAuditFields(ctx, audit.EntityPatients, id, personalFields); err != nil {
+			return err
+		}
+		if userID != nil {
+			if err := database.RedactAuditFields(ctx, audit.EntityUsers, *userID, []string{"email"}); err != nil {
+				return err
+			}
+		}
+		if patient, err = h.patients.Get(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatients, id, audit.ActionErase, patient)
+	})
+	switch {
+	case errors.Is(err, database.ErrPatientErased):
+		c.Error(apierr.Conflict("Patient has already been erased"))
+		return
+	case errors.Is(err, database.ErrPatientHasUpcoming):
+		c.Error(apierr.Unprocessable("Patient has upcoming appointments; cancel them before erasing the patient"))
+		return
+	case err != nil:
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	c.JSON(http.StatusOK, patient)
+}
+
+// ExportPatientData downloads everything stored about a patient (GDPR right of access):
+// the database.PatientData as JSON (format=json, the default), or a ZIP archive (format=zip)
+// of that JSON as patient.json with the patient's files under documents/. Every record in it
+// is written to the access log.
+func ExportPatientData(store *storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid ID"))
+			return
+		}
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "zip" {
+			c.Error(apierr.Validation("format must be one of: json, zip"))
+			return
+		}
+
+		data, err := database.GetPatientData(c.Request.Context(), id)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Patient not found"))
+			return
+		}
+		logDataAccess(c, data)
+		if err := audit.Record(c.Request.Context(), audit.EntityPatients, id, audit.ActionExport, data.Patient); err != nil {
+			c.Error(err)
+			return
+		}
+
+		filename := fmt.Sprintf("patient-%d-%s.%s", id, time.Now().Format(time.DateOnly), format)
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+		if format == "json" {
+			c.JSON(http.StatusOK, data)
+			return
+		}
+
+		// Files can take longer to fetch than REQUEST_TIMEOUT allows
+		c.Request = c.Request.WithContext(handlers.StreamContext(c))
+		c.Header("Content-Type", "application/zip")
+		c.Status(http.StatusOK)
+		if err := writeDataArchive(c.Request.Context(), c.Writer, store, data); err != nil {
+			slog.ErrorContext(c.Request.Context(), "export cut short", "path", c.Request.URL.Path, "error", err)
+		}
+	}
+}
+
+// writeDataArchive writes a patient's data export as a ZIP archive. Documents are only ever
+// uploaded to a configured store, so without one there are no files to add.
+func writeDataArchive(ctx context.Context, w io.Writer, store *storage.Store, data *database.PatientData) error {
+	archive := zip.NewWriter(w)
+	entry, err := archive.Create("patient.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return err
+	}
+	if store != nil {
+		for _, document := range data.Documents {
+			if err := addDocument(ctx, archive, store, document); err != nil {
+				return err
+			}
+		}
+	}
+	return archive.Close()
+}
+
+// addDocument copies a document from the object store into the archive, as
+// documents/<id>-<filename>
+func addDocument(ctx context.Context, archive *zip.Writer, store *storage.Store, document models.Document) error {
+	ctx, cancel := context.WithTimeout(ctx, documentFetchTimeout)
+	defer cancel()
+	body, err := store.Get(ctx, document.StorageKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(document.Filename)
+	entry, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     fmt.Sprintf("documents/%d-%s", document.ID, name),
+		Method:   zip.Deflate,
+		Modified: document.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, body)
+	return err
+}
+
+// logDataAccess records in the access log every logged record a data export hands over
+func logDataAccess(c *gin.Context, data *database.PatientData) {
+	access.Patients(c, data.Patient.ID)
+	access.MedicalNotes(c, data.Appointments...)
+	access.Chart(c, data.Patient.ID)
+	access.Prescriptions(c, data.Prescriptions...)
+	access.Referrals(c, data.Referrals...)
+	access.Documents(c, data.Documents...)
+	appointments := make(map[int]*models.Appointment, len(data.Appointments))
+	for i := range data.Appointments {
+		appointments[data.Appointments[i].ID] = &data.Appointments[i]
+	}
+	for _, note := range data.VisitNotes {
+		if appointment, ok := appointments[note.AppointmentID]; ok {
+			access.VisitNote(c, appointment)
+		}
+	}
+	answered := map[int]bool{}
+	for _, response := range data.FormResponses {
+		if appointment, ok := appointments[response.AppointmentID]; ok && !answered[response.AppointmentID] {
+			access.FormResponses(c, appointment)
+			answered[response.AppointmentID] = true
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Medical Appointment Booking System - Patient Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package patients
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRelationships lists the patient's guardians and dependents
+func (h *Handler) GetRelationships(c *gin.Context) {
+	patientID, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	relationships, err := database.GetPatientRelationships(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, relationships)
+}
+
+// AddDependent links a dependent to the patient as their guardian, so the patient can book
+// for them in the portal and online. Any relationship may be recorded whatever the
+// dependent's age; parents and legal guardians act for them only until they turn 18.
+func (h *Handler) AddDependent(c *gin.Context) {
+	guardianID, ok := h.activePatientID(c)
+	if !ok {
+		return
+	}
+	var relationship models.PatientRelationship
+	if !handlers.BindJSON(c, &relationship) {
+		return
+	}
+	if relationship.DependentID == guardianID {
+		c.Error(apierr.Validation("A patient cannot be their own dependent"))
+		return
+	}
+	if dependent, err := h.patients.Get(c.Request.Context(), relationship.DependentID); err != nil || dependent.DeletedAt != nil {
+		c.Error(apierr.Validation("Dependent not found"))
+		return
+	}
+	relationship.GuardianID = guardianID
+	relationship.CreatedBy = recorder(c)
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateRelationship(ctx, &relationship); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatientRelationships, relationship.ID, audit.ActionCreate, relationship)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRelationshipExists):
+			c.Error(apierr.Conflict("The dependent is already linked to this patient"))
+		case errors.Is(err, database.ErrRelationshipCycle):
+			c.Error(apierr.Unprocessable("The dependent is this patient's guardian"))
+		default:
+			c.Error(err)
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, relationship)
+}
+
+// RemoveDependent unlinks a dependent from the patient; both patient records stay
+func (h *Handler) RemoveDependent(c *gin.Context) {
+	guardianID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	dependentID, err := strconv.Atoi(c.Param("dependent_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid dependent ID"))
+		return
+	}
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		id, err := database.DeleteRelationship(ctx, guardianID, dependentID)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatientRelationships, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Dependent not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dependent removed successfully"})
+}
@@ -0,0 +1,211 @@
+// Medical Appointment Booking System - Payment Link Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package paymentlinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/money"
+	"bookings/notifications"
+	"bookings/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the staff payment link endpoints
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.POST("/patients/:id/payment-link", auth.Authorize(auth.PaymentLinks), CreatePaymentLink(deps.Sender))
+}
+
+// RegisterPublicRoutes mounts the endpoints addressed by link token, which the patient and the
+// checkout page call without logging in; the token itself is the credential. Links are only
+// settled by the signed Stripe webhook for their payment, never by these endpoints.
+func RegisterPublicRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/payment-links")
+	{
+		group.GET("/:token", GetPaymentLink)
+		group.POST("/:token/payment-intent", CreateLinkPaymentIntent(deps.Stripe))
+	}
+}
+
+// CreatePaymentLink creates a link for the patient's outstanding balance and, when notify is
+// set, sends it to the patient
+func CreatePaymentLink(sender notifications.Sender) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		patientID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid ID"))
+			return
+		}
+
+		// The body is optional: without an appointment_id the link covers the full outstanding balance
+		var req struct {
+			AppointmentID *int `json:"appointment_id"`
+			Notify        bool `json:"notify"`
+		}
+		if c.Request.ContentLength > 0 && !handlers.BindJSON(c, &req) {
+			return
+		}
+
+		patient, err := database.GetPatient(c.Request.Context(), patientID)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Patient not found"))
+			return
+		}
+
+		token, err := payments.NewLinkToken()
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		url, err := payments.CheckoutURL(token)
+		if err != nil {
+			c.Error(apierr.Unavailable(err.Error()))
+			return
+		}
+
+		link := models.PaymentLink{
+			PatientID:     patientID,
+			AppointmentID: req.AppointmentID,
+			Token:         token,
+			URL:           url,
+			ExpiresAt:     time.Now().Add(payments.LinkTTL),
+		}
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			if err := database.CreatePaymentLink(ctx, &link); err != nil {
+				return err
+			}
+			return audit.Record(ctx, audit.EntityPaymentLinks, link.ID, audit.ActionCreate, link)
+		})
+		if err != nil {
+			if errors.Is(err, database.ErrNothingOutstanding) {
+				c.Error(apierr.Unprocessable("No outstanding balance to collect"))
+				return
+			}
+			if errors.Is(err, database.ErrMixedCurrencies) {
+				c.Error(apierr.Unprocessable("Outstanding appointments are in different currencies; create a link per appointment"))
+				return
+			}
+			c.Error(err)
+			return
+		}
+		if req.Notify {
+			sendLink(c.Request.Context(), sender, &link, patient)
+		}
+		c.JSON(http.StatusCreated, link)
+	}
+}
+
+// publicLink is what the patient-facing checkout sees: opaque identifiers only, no integer ids
+type publicLink struct {
+	PatientID     string      `json:"patient_id"`
+	AppointmentID *string     `json:"appointment_id"`
+	Token         string      `json:"token"`
+	URL           string      `json:"url"`
+	Amount        money.Money `json:"amount"`
+	Status        string      `json:"status"`
+	ExpiresAt     time.Time   `json:"expires_at"`
+	PaidAt        *time.Time  `json:"paid_at"`
+}
+
+// publicView is the patient-facing view of a link; a pending link past its expiry shows as EXPIRED
+func publicView(link *models.PaymentLink) publicLink {
+	status := link.Status
+	if status == "PENDING" && !time.Now().Before(link.ExpiresAt) {
+		status = "EXPIRED"
+	}
+	return publicLink{
+		PatientID:     link.PatientPublicID,
+		AppointmentID: link.AppointmentPublicID,
+		Token:         link.Token,
+		URL:           link.URL,
+		Amount:        link.Amount,
+		Status:        status,
+		ExpiresAt:     link.ExpiresAt,
+		PaidAt:        link.PaidAt,
+	}
+}
+
+// GetPaymentLink shows a link to whoever holds its token, the patient and the hosted checkout
+// page, without signing in. Until the signed Stripe webhook settles the payment the link is
+// PENDING (EXPIRED once past expires_at) with no paid_at, even after the patient has paid;
+// once settled it is PAID with paid_at.
+func GetPaymentLink(c *gin.Context) {
+	link, err := database.GetPaymentLinkByToken(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Payment link not found"))
+		return
+	}
+	c.JSON(http.StatusOK, publicView(link))
+}
+
+// CreateLinkPaymentIntent returns the Stripe checkout the hosted checkout page pays a link
+// with. The signed Stripe webhook settles the link once the payment goes through.
+func CreateLinkPaymentIntent(stripe *payments.Stripe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if stripe == nil {
+			c.Error(apierr.Unavailable("Card payments are not configured"))
+			return
+		}
+		link, err := database.GetPaymentLinkByToken(c.Request.Context(), c.Param("token"))
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Payment link not found"))
+			return
+		}
+
+		checkout, err := stripe.StartLinkPayment(c.Request.Context(), link)
+		if err != nil {
+			if errors.Is(err, database.ErrPaymentLinkNotPayable) {
+				c.Error(apierr.Conflict("Payment link is already paid, withdrawn or expired"))
+				return
+			}
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, checkout)
+	}
+}
+
+// sendLink sends a new payment link to the patient on every channel their preferences allow.
+// Failures are logged rather than returned, since the link has already been created.
+func sendLink(ctx context.Context, sender notifications.Sender, link *models.PaymentLink, patient *models.Patient) {
+	prefs, err := database.GetNotificationPreferences(ctx, patient.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "payment link: loading notification preferences", "payment_link_id", link.ID, "error", err)
+		return
+	}
+	body := fmt.Sprintf("Your balance of %s is ready to pay at %s. The link expires after %d hours.",
+		link.Amount, link.URL, int(payments.LinkTTL.Hours()))
+	for _, msg := range notifications.PatientMessages(ctx, patient, prefs, notifications.TemplatePaymentLink, "Payment link", body) {
+		msg.AppointmentID = link.AppointmentID
+		if err := sender.Send(ctx, msg); err != nil {
+			slog.ErrorContext(ctx, "payment link: notifying patient", "payment_link_id", link.ID, "error", err)
+		}
+	}
+}
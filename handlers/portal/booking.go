@@ -0,0 +1,303 @@
+// Medical Appointment Booking System - Patient Portal Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package portal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/handlers/consents"
+	"bookings/hl7"
+	"bookings/models"
+	"bookings/money"
+	"bookings/notifications"
+	"bookings/payments"
+	"bookings/timeutil"
+	"bookings/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serviceView is a bookable service as listed to patients
+type serviceView struct {
+	ID              int         `json:"id"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description"`
+	DurationMinutes int         `json:"duration_minutes"`
+	Price           money.Money `json:"price"`
+	MinAgeYears     *int        `json:"min_age_years"`
+	MaxAgeYears     *int        `json:"max_age_years"`
+}
+
+// employeeView is a practitioner as listed to patients
+type employeeView struct {
+	ID        int    `json:"id"`
+	ClinicID  int    `json:"clinic_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Specialty string `json:"specialty"`
+}
+
+// GetServices lists the active services patients can book
+func GetServices(c *gin.Context) {
+	services, _, err := database.GetServices(c.Request.Context(), database.Page{})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	views := []serviceView{}
+	for _, s := range services {
+		if s.Active {
+			views = append(views, serviceView{ID: s.ID, Name: s.Name, Description: s.Description, DurationMinutes: s.DurationMinutes, Price: s.Price,
+				MinAgeYears: s.MinAgeYears, MaxAgeYears: s.MaxAgeYears})
+		}
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// GetServiceEmployees lists the active employees who can be booked for a service
+func GetServiceEmployees(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	if _, ok := bookableService(c, id); !ok {
+		return
+	}
+
+	employees, err := database.GetBookableEmployees(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	views := make([]employeeView, 0, len(employees))
+	for _, e := range employees {
+		views = append(views, employeeView{ID: e.ID, ClinicID: e.ClinicID, FirstName: e.FirstName, LastName: e.LastName, Specialty: e.Specialty})
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// GetAvailability lists the slots the patient can book with an employee for a service on a date
+func GetAvailability(c *gin.Context) {
+	employeeID, err := strconv.Atoi(c.Query("employee_id"))
+	if err != nil {
+		c.Error(apierr.Validation("employee_id is required"))
+		return
+	}
+	serviceID, err := strconv.Atoi(c.Query("service_id"))
+	if err != nil {
+		c.Error(apierr.Validation("service_id is required"))
+		return
+	}
+	date, err := timeutil.ParseDate(c.Query("date"))
+	if err != nil {
+		c.Error(apierr.Validation("date must be given as YYYY-MM-DD"))
+		return
+	}
+	appointmentType, ok := optionalAppointmentType(c, c.Query("appointment_type"))
+	if !ok {
+		return
+	}
+
+	employee, service, ok := bookablePair(c, employeeID, serviceID)
+	if !ok {
+		return
+	}
+	slots, err := availability.FreeSlots(c.Request.Context(), employee, service, date, appointmentType, time.Now())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"employee_id":      employee.ID,
+		"service_id":       service.ID,
+		"date":             date.Format(timeutil.DateLayout),
+		"timezone":         availability.Location(employee).String(),
+		"duration_minutes": service.DurationMinutes,
+		"slots":            slots,
+	})
+}
+
+// BookAppointment books one of the slots GetAvailability offers for the calling patient. The
+// start must match an available slot exactly; the end, clinic and price follow from the
+// employee and service. A priced booking comes with its Stripe checkout when card payments
+// are configured. With ?dependent_id= the booking is for one of the patient's dependents.
+func BookAppointment(sender notifications.Sender, stripe *payments.Stripe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		patientID, ok := subjectPatientID(c)
+		if !ok {
+			return
+		}
+		var req struct {
+			EmployeeID      int       `json:"employee_id" binding:"required"`
+			ServiceID       int       `json:"service_id" binding:"required"`
+			StartDatetime   time.Time `json:"start_datetime" binding:"required"`
+			AppointmentType string    `json:"appointment_type" binding:"omitempty,enum=appointment_type"`
+			Notes           *string   `json:"notes"`
+		}
+		if !handlers.BindJSON(c, &req) {
+			return
+		}
+		appointmentType, ok := optionalAppointmentType(c, req.AppointmentType)
+		if !ok {
+			return
+		}
+
+		employee, service, ok := bookablePair(c, req.EmployeeID, req.ServiceID)
+		if !ok {
+			return
+		}
+		loc := availability.Location(employee)
+		// Explain a broken lead time or advance window rather than just offering other slots
+		clinic, err := database.GetClinic(c.Request.Context(), employee.ClinicID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := availability.CheckBookingWindow(service, clinic, req.StartDatetime, time.Now(), loc); err != nil {
+			c.Error(apierr.Unprocessable(err.Error()))
+			return
+		}
+		patient, err := database.GetPatient(c.Request.Context(), patientID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := availability.CheckPatientAge(service, patient.DateOfBirth, req.StartDatetime, loc); err != nil {
+			c.Error(apierr.Unprocessable(err.Error()))
+			return
+		}
+		if err := consents.CheckRequired(c.Request.Context(), patientID, service); err != nil {
+			c.Error(err)
+			return
+		}
+		channel := "PORTAL"
+		price := service.Price
+		appointment := models.Appointment{
+			PatientID:       patientID,
+			EmployeeID:      employee.ID,
+			ServiceID:       service.ID,
+			ClinicID:        employee.ClinicID,
+			StartDatetime:   req.StartDatetime,
+			EndDatetime:     req.StartDatetime.Add(time.Duration(service.DurationMinutes) * time.Minute),
+			Status:          "SCHEDULED",
+			AppointmentType: appointmentType,
+			BookingChannel:  &channel,
+			Notes:           req.Notes,
+			PaymentStatus:   "PENDING",
+			PaymentAmount:   &price,
+		}
+		// The slot is checked and booked under the employee's schedule lock, so two patients
+		// choosing the same slot cannot both get it
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			if err := database.LockEmployeeSchedule(ctx, employee.ID); err != nil {
+				return err
+			}
+			slots, err := availability.FreeSlots(ctx, employee, service, timeutil.LocalDate(req.StartDatetime, loc), appointmentType, time.Now())
+			if err != nil {
+				return err
+			}
+			if !slices.ContainsFunc(slots, func(slot availability.Interval) bool { return slot.Start.Equal(req.StartDatetime) }) {
+				return errSlotUnavailable
+			}
+			if err := database.CreateAppointment(ctx, &appointment); err != nil {
+				return err
+			}
+			if err := audit.Record(ctx, audit.EntityAppointments, appointment.ID, audit.ActionCreate, appointment); err != nil {
+				return err
+			}
+			return webhooks.Publish(ctx, webhooks.EventAppointmentCreated, webhooks.AppointmentData(&appointment))
+		})
+		if err != nil {
+			var unavailable *database.ResourceUnavailableError
+			if errors.Is(err, errSlotUnavailable) || errors.Is(err, database.ErrAppointmentConflict) || errors.As(err, &unavailable) {
+				c.Error(apierr.Conflict("That time is not available; choose one of the offered slots"))
+				return
+			}
+			c.Error(err)
+			return
+		}
+		hl7.Publish(c.Request.Context(), hl7.TriggerBooked, &appointment)
+		notifications.SendAppointmentEmails(c.Request.Context(), sender, notifications.EventBooked, &appointment)
+
+		view, err := newViewBuilder(time.Now()).build(c.Request.Context(), &appointment)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		view.Payment = stripe.CheckoutForBooking(c.Request.Context(), &appointment)
+		c.JSON(http.StatusCreated, view)
+	}
+}
+
+// errSlotUnavailable is returned when the chosen start is not one of the free slots
+var errSlotUnavailable = errors.New("slot is not available")
+
+// bookableService loads an active service, writing a 404 when there is none
+func bookableService(c *gin.Context, id int) (*models.Service, bool) {
+	service, err := database.GetService(c.Request.Context(), id)
+	if err != nil || !service.Active {
+		c.Error(apierr.NotFound("Service not found"))
+		return nil, false
+	}
+	return service, true
+}
+
+// bookablePair loads an active employee and service and checks the employee offers the service
+func bookablePair(c *gin.Context, employeeID, serviceID int) (*models.Employee, *models.Service, bool) {
+	service, ok := bookableService(c, serviceID)
+	if !ok {
+		return nil, nil, false
+	}
+	employee, err := database.GetEmployee(c.Request.Context(), employeeID)
+	if err != nil || !employee.Active || employee.DeletedAt != nil {
+		c.Error(apierr.NotFound("Employee not found"))
+		return nil, nil, false
+	}
+	offers, err := database.EmployeeOffersService(c.Request.Context(), employee.ID, service.ID)
+	if err != nil {
+		c.Error(err)
+		return nil, nil, false
+	}
+	if !offers {
+		c.Error(apierr.Unprocessable("Employee does not offer " + service.Name))
+		return nil, nil, false
+	}
+	return employee, service, true
+}
+
+func optionalAppointmentType(c *gin.Context, value string) (*string, bool) {
+	if value == "" {
+		return nil, true
+	}
+	if !slices.Contains(models.AppointmentTypes, value) {
+		c.Error(apierr.Validation("Invalid appointment_type"))
+		return nil, false
+	}
+	return &value, true
+}
@@ -0,0 +1,135 @@
+// Medical Appointment Booking System - Patient Portal Consents
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package portal
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/handlers/consents"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// consentView is a current consent document with the patient's agreement to it, or null
+type consentView struct {
+	models.ConsentDocument
+	Consent *models.PatientConsent `json:"consent"`
+}
+
+// GetConsents lists the current version of each consent document, each with the patient's
+// agreement to it or null, so the patient can review and agree to what they have not. Like
+// the other consent endpoints it takes ?dependent_id= to act for a dependent.
+func GetConsents(c *gin.Context) {
+	patientID, ok := subjectPatientID(c)
+	if !ok {
+		return
+	}
+	documents, err := database.GetCurrentConsentDocuments(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	given, err := database.GetPatientConsents(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	views := make([]consentView, 0, len(documents))
+	for _, document := range documents {
+		view := consentView{ConsentDocument: document}
+		for i := range given {
+			if given[i].ConsentDocumentID == document.ID && given[i].WithdrawnAt == nil {
+				view.Consent = &given[i]
+				break
+			}
+		}
+		views = append(views, view)
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// GiveConsent records the patient's agreement to a consent document, captured in the portal
+func GiveConsent(c *gin.Context) {
+	patientID, ok := subjectPatientID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		ConsentDocumentID int `json:"consent_document_id" binding:"required"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	consent := models.PatientConsent{PatientID: patientID, ConsentDocumentID: req.ConsentDocumentID, CaptureMethod: "PORTAL"}
+	if id, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		consent.RecordedBy = &id
+	}
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.RecordConsent(ctx, &consent); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatientConsents, consent.ID, audit.ActionCreate, consent)
+	})
+	if err != nil {
+		consents.WriteError(c, err, "Consent document not found")
+		return
+	}
+	c.JSON(http.StatusCreated, consent)
+}
+
+// WithdrawConsent withdraws one of the patient's consents. Bookings of services that need it
+// are refused until they agree again.
+func WithdrawConsent(c *gin.Context) {
+	patientID, ok := subjectPatientID(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var by *int
+	if userID, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		by = &userID
+	}
+	var consent *models.PatientConsent
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if consent, err = database.WithdrawConsent(ctx, patientID, id, by, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatientConsents, consent.ID, audit.ActionUpdate, consent)
+	})
+	if err != nil {
+		consents.WriteError(c, err, "Consent not found")
+		return
+	}
+	c.JSON(http.StatusOK, consent)
+}
@@ -0,0 +1,230 @@
+// Medical Appointment Booking System - Patient Portal Dependents
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package portal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errNotDependent is returned for a dependent the guardian can no longer act for
+var errNotDependent = errors.New("guardian no longer acts for the dependent")
+
+// dependentView is a dependent as their guardian sees them
+type dependentView struct {
+	profileView
+	Relationship string `json:"relationship"`
+}
+
+// actsFor reports whether a guardian may still act for a dependent: caregivers always, and
+// parents and legal guardians while the dependent is a minor. A dependent whose date of
+// birth is unknown is taken to be a minor.
+func actsFor(relationship *models.PatientRelationship, dependent *models.Patient, now time.Time) bool {
+	if dependent.DeletedAt != nil {
+		return false
+	}
+	if relationship.Relationship == "CAREGIVER" || dependent.DateOfBirth == nil {
+		return true
+	}
+	age, err := timeutil.AgeOn(*dependent.DateOfBirth, now)
+	return err == nil && age < availability.AdultAge
+}
+
+// dependentOf returns a dependent the guardian may act for with their relationship, or
+// pgx.ErrNoRows when there is none
+func dependentOf(ctx context.Context, guardianID, dependentID int) (*models.Patient, *models.PatientRelationship, error) {
+	relationship, err := database.GetRelationship(ctx, guardianID, dependentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	dependent, err := database.GetPatient(ctx, dependentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !actsFor(relationship, dependent, time.Now()) {
+		return nil, nil, errNotDependent
+	}
+	return dependent, relationship, nil
+}
+
+// subjectPatientID returns the patient an endpoint acts on: the caller's own, or with
+// ?dependent_id= (a public patient id) one of their dependents. It writes a 403 or 404 when
+// there is none.
+func subjectPatientID(c *gin.Context) (int, bool) {
+	patientID, ok := currentPatientID(c)
+	if !ok {
+		return 0, false
+	}
+	publicID := c.Query("dependent_id")
+	if publicID == "" {
+		return patientID, true
+	}
+	dependent, err := database.GetPatientByPublicID(c.Request.Context(), publicID)
+	if err == nil {
+		_, _, err = dependentOf(c.Request.Context(), patientID, dependent.ID)
+	}
+	if err != nil {
+		c.Error(apierr.NotFound("Dependent not found"))
+		return 0, false
+	}
+	return dependent.ID, true
+}
+
+// ownAppointment loads the booking in the path when it is the caller's or one of their
+// dependents', writing a 404 otherwise
+func ownAppointment(c *gin.Context, patientID int) (*models.Appointment, bool) {
+	appointment, err := database.GetAppointmentByPublicID(c.Request.Context(), c.Param("public_id"))
+	if err == nil && appointment.PatientID != patientID {
+		_, _, err = dependentOf(c.Request.Context(), patientID, appointment.PatientID)
+	}
+	if err != nil {
+		c.Error(apierr.NotFound("Appointment not found"))
+		return nil, false
+	}
+	return appointment, true
+}
+
+// GetDependents lists the dependents the patient may act for; id is the dependent_id other
+// portal endpoints take
+func GetDependents(c *gin.Context) {
+	patientID, ok := currentPatientID(c)
+	if !ok {
+		return
+	}
+	relationships, err := database.GetDependents(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	now := time.Now()
+	views := []dependentView{}
+	var ids []int
+	for i := range relationships {
+		dependent, err := database.GetPatient(c.Request.Context(), relationships[i].DependentID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if actsFor(&relationships[i], dependent, now) {
+			views = append(views, dependentView{profileView: newProfileView(dependent), Relationship: relationships[i].Relationship})
+			ids = append(ids, dependent.ID)
+		}
+	}
+	access.Patients(c, ids...)
+	c.JSON(http.StatusOK, views)
+}
+
+// AddDependent registers a child under 18 as a new patient and links them to the caller as
+// their parent or legal guardian. The child has no email of their own and takes the
+// caller's phone, so text reminders reach the caller. Adults who need a caregiver are linked
+// by the clinic.
+func AddDependent(c *gin.Context) {
+	patientID, ok := currentPatientID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		FirstName    string `json:"first_name" binding:"required"`
+		LastName     string `json:"last_name" binding:"required"`
+		DateOfBirth  string `json:"date_of_birth" binding:"required,datetime=2006-01-02,pastdate"`
+		Relationship string `json:"relationship" binding:"required,oneof=PARENT LEGAL_GUARDIAN"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+	now := time.Now()
+	if age, err := timeutil.AgeOn(req.DateOfBirth, now); err != nil || age < 0 || age >= availability.AdultAge {
+		c.Error(apierr.Unprocessable("Only children under 18 can be added; please contact the clinic to link an adult"))
+		return
+	}
+	guardian, err := database.GetPatient(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+
+	dependent := models.Patient{
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Phone:       guardian.Phone,
+		DateOfBirth: &req.DateOfBirth,
+		Active:      true,
+	}
+	relationship := models.PatientRelationship{GuardianID: patientID, Relationship: req.Relationship}
+	if id, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		relationship.CreatedBy = &id
+	}
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreatePatient(ctx, &dependent); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityPatients, dependent.ID, audit.ActionCreate, dependent); err != nil {
+			return err
+		}
+		relationship.DependentID = dependent.ID
+		if err := database.CreateRelationship(ctx, &relationship); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatientRelationships, relationship.ID, audit.ActionCreate, relationship)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, dependentView{profileView: newProfileView(&dependent), Relationship: relationship.Relationship})
+}
+
+// RemoveDependent unlinks one of the caller's dependents; the dependent's record stays with
+// the clinic
+func RemoveDependent(c *gin.Context) {
+	patientID, ok := currentPatientID(c)
+	if !ok {
+		return
+	}
+	dependent, err := database.GetPatientByPublicID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.Error(apierr.NotFound("Dependent not found"))
+		return
+	}
+	var id int
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if id, err = database.DeleteRelationship(ctx, patientID, dependent.ID); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatientRelationships, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Dependent not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dependent removed successfully"})
+}
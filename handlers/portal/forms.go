@@ -0,0 +1,182 @@
+// Medical Appointment Booking System - Patient Portal Intake Forms
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package portal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/formschema"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// formsClosed is the refusal for answering forms of a booking that has started or is no
+// longer scheduled or confirmed
+const formsClosed = "Forms can only be completed before a scheduled or confirmed appointment starts"
+
+// formView is an intake form as the patient sees it: the questions, and their answers once given
+type formView struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Description *string         `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+	Answers     json.RawMessage `json:"answers"`
+	SubmittedAt *time.Time      `json:"submitted_at"`
+	// Editable reports whether the patient may still answer or change their answers
+	Editable bool `json:"editable"`
+}
+
+// formsOpen reports whether the patient may still answer an appointment's forms
+func formsOpen(appointment *models.Appointment, now time.Time) bool {
+	return (appointment.Status == "SCHEDULED" || appointment.Status == "CONFIRMED") && appointment.StartDatetime.After(now)
+}
+
+// GetAppointmentForms lists the intake forms of one of the patient's bookings by name: those
+// to complete before it and any already answered, which show the questions as they were
+// answered
+func GetAppointmentForms(c *gin.Context) {
+	patientID, ok := currentPatientID(c)
+	if !ok {
+		return
+	}
+	appointment, ok := ownAppointment(c, patientID)
+	if !ok {
+		return
+	}
+
+	appointmentForms, err := database.GetAppointmentForms(c.Request.Context(), appointment)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	open := formsOpen(appointment, time.Now())
+	views := make([]formView, 0, len(appointmentForms))
+	answered := false
+	for _, form := range appointmentForms {
+		view := formView{
+			ID:          form.Template.ID,
+			Name:        form.Template.Name,
+			Description: form.Template.Description,
+			Schema:      form.Template.Schema,
+			Editable:    open && form.Asked,
+		}
+		if form.Response != nil {
+			view.Schema, view.Answers, view.SubmittedAt = form.Response.Schema, form.Response.Answers, &form.Response.SubmittedAt
+			answered = true
+		}
+		views = append(views, view)
+	}
+	if answered {
+		access.FormResponses(c, appointment)
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// SubmitAppointmentForm stores the patient's answers to one of a booking's forms, replacing
+// any they gave before. Answers are checked against the form's questions and accepted until
+// the appointment starts.
+func SubmitAppointmentForm(c *gin.Context) {
+	patientID, ok := currentPatientID(c)
+	if !ok {
+		return
+	}
+	formID, err := strconv.Atoi(c.Param("form_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid form ID"))
+		return
+	}
+	var req struct {
+		Answers json.RawMessage `json:"answers" binding:"required"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+	appointment, ok := ownAppointment(c, patientID)
+	if !ok {
+		return
+	}
+	if !formsOpen(appointment, time.Now()) {
+		c.Error(apierr.Unprocessable(formsClosed))
+		return
+	}
+
+	appointmentForms, err := database.GetAppointmentForms(c.Request.Context(), appointment)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	var form *database.AppointmentForm
+	for i := range appointmentForms {
+		if appointmentForms[i].Template.ID == formID && appointmentForms[i].Asked {
+			form = &appointmentForms[i]
+		}
+	}
+	if form == nil {
+		c.Error(apierr.NotFound("Form not found"))
+		return
+	}
+	schema, err := formschema.Parse(form.Template.Schema)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if problems := schema.Validate(req.Answers); problems != nil {
+		c.Error(apierr.Validation("Answers are invalid").WithDetails(gin.H{"fields": problems}))
+		return
+	}
+
+	response := models.FormResponse{
+		FormTemplateID: formID,
+		AppointmentID:  appointment.ID,
+		PatientID:      appointment.PatientID,
+		Schema:         form.Template.Schema,
+		Answers:        req.Answers,
+	}
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.SaveFormResponse(ctx, &response); err != nil {
+			return err
+		}
+		action := audit.ActionUpdate
+		if form.Response == nil {
+			action = audit.ActionCreate
+		}
+		return audit.Record(ctx, audit.EntityFormResponses, response.ID, action, response)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, formView{
+		ID:          form.Template.ID,
+		Name:        form.Template.Name,
+		Description: form.Template.Description,
+		Schema:      response.Schema,
+		Answers:     response.Answers,
+		SubmittedAt: &response.SubmittedAt,
+		Editable:    true,
+	})
+}
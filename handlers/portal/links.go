@@ -0,0 +1,173 @@
+// Medical Appointment Booking System - Patient Portal Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package portal
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"time"
+
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/notifications"
+	"bookings/payments"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LinkCancellationReason is recorded on appointments the patient cancels from a reminder link
+const LinkCancellationReason = "Cancelled by patient via reminder link"
+
+// RegisterPublicRoutes mounts the confirm and cancel links sent in reminders, the
+// unsubscribe link sent with patient notifications and the webhook SMS replies arrive on.
+// Patients open the links in a browser without logging in; the signed token is the
+// credential, so they answer with a page rather than JSON. The webhook is called by the SMS
+// provider and checks its signature.
+func RegisterPublicRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.GET("/appointments/confirm/:token", ConfirmByLink)
+	r.GET("/appointments/cancel/:token", CancelByLink(deps.Sender, deps.Stripe))
+	r.GET("/unsubscribe/:token", Unsubscribe)
+	r.POST("/unsubscribe/:token", Unsubscribe)
+	r.POST("/sms/inbound", InboundSMS(deps.Sender, deps.Stripe))
+}
+
+var linkPage = template.Must(template.New("link").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Title}}</title></head>
+<body style="font-family: Arial, sans-serif; color: #222;">
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+// renderLink answers a link with a page
+func renderLink(c *gin.Context, status int, title, message string) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	linkPage.Execute(c.Writer, gin.H{"Title": title, "Message": message})
+}
+
+// linkAppointment loads the appointment a link token was signed for, answering with an
+// error page and returning nil when the token is not valid
+func linkAppointment(c *gin.Context, purpose string) *models.Appointment {
+	publicID, err := auth.ParseSignedToken(purpose, c.Param("token"))
+	if err == nil {
+		var appointment *models.Appointment
+		if appointment, err = database.GetAppointmentByPublicID(c.Request.Context(), publicID); err == nil {
+			return appointment
+		}
+	}
+	renderLink(c, http.StatusNotFound, "Link not valid", "This link is not valid. Please contact the clinic.")
+	return nil
+}
+
+// describeAppointment names an appointment in answers to the patient, e.g. "with Asha
+// Perera on Fri 14 Mar 2025 09:45 IST"
+func describeAppointment(ctx context.Context, appointment *models.Appointment) (string, error) {
+	employee, err := database.GetEmployee(ctx, appointment.EmployeeID)
+	if err != nil {
+		return "", err
+	}
+	return "with " + employee.FirstName + " " + employee.LastName + " on " +
+		timeutil.FormatIn(appointment.StartDatetime, employee.Timezone), nil
+}
+
+// confirmAppointment confirms a scheduled appointment and audits the change in one
+// transaction. It returns the confirmed appointment, or nil when it was no longer scheduled.
+func confirmAppointment(ctx context.Context, id int) (*models.Appointment, error) {
+	var updated *models.Appointment
+	err := database.WithTx(ctx, func(ctx context.Context) error {
+		confirmed, err := database.ConfirmAppointment(ctx, id)
+		if err != nil || !confirmed {
+			return err
+		}
+		if updated, err = database.GetAppointment(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityAppointments, id, audit.ActionUpdate, updated)
+	})
+	return updated, err
+}
+
+// ConfirmByLink confirms a scheduled appointment from the link in its reminder. Following
+// the link again once confirmed answers the same.
+func ConfirmByLink(c *gin.Context) {
+	appointment := linkAppointment(c, auth.PurposeConfirm)
+	if appointment == nil {
+		return
+	}
+	description, err := describeAppointment(c.Request.Context(), appointment)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if appointment.Status == "SCHEDULED" {
+		updated, err := confirmAppointment(c.Request.Context(), appointment.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if updated != nil {
+			appointment = updated
+		}
+	}
+	if appointment.Status != "CONFIRMED" {
+		renderLink(c, http.StatusUnprocessableEntity, "Appointment not confirmed",
+			"Your appointment "+description+" can no longer be confirmed. Please contact the clinic.")
+		return
+	}
+	renderLink(c, http.StatusOK, "Appointment confirmed", "Your appointment "+description+" is confirmed. We look forward to seeing you.")
+}
+
+// CancelByLink cancels an appointment from the link in its reminder, under the same policy
+// as cancelling in the portal, and offers the slot to the waiting list. Following the link
+// again once cancelled answers the same.
+func CancelByLink(sender notifications.Sender, stripe *payments.Stripe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appointment := linkAppointment(c, auth.PurposeCancel)
+		if appointment == nil {
+			return
+		}
+		description, err := describeAppointment(c.Request.Context(), appointment)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if appointment.Status != "CANCELLED" {
+			if refusal := cancellationRefusal(appointment, time.Now()); refusal != "" {
+				renderLink(c, http.StatusUnprocessableEntity, "Appointment not cancelled", refusal+".")
+				return
+			}
+			cancelled, err := cancelForPatient(c.Request.Context(), sender, stripe, appointment, LinkCancellationReason)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			if !cancelled {
+				renderLink(c, http.StatusUnprocessableEntity, "Appointment not cancelled", notCancellable+".")
+				return
+			}
+		}
+		renderLink(c, http.StatusOK, "Appointment cancelled", "Your appointment "+description+" is cancelled.")
+	}
+}
@@ -0,0 +1,391 @@
+// Medical Appointment Booking System - Patient Portal Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package portal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/hl7"
+	"bookings/models"
+	"bookings/money"
+	"bookings/notifications"
+	"bookings/payments"
+	"bookings/timeutil"
+	"bookings/waitlist"
+	"bookings/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCancellationNotice is how long before the start patients may still cancel online when
+// PORTAL_CANCELLATION_NOTICE is not set
+const DefaultCancellationNotice = 24 * time.Hour
+
+// PortalCancellationReason is recorded on appointments the patient cancels themselves
+const PortalCancellationReason = "Cancelled by patient via portal"
+
+// notCancellable is the refusal for bookings that are no longer scheduled or confirmed
+const notCancellable = "Only scheduled or confirmed appointments can be cancelled"
+
+// RegisterRoutes mounts the patient self-service endpoints under /portal. Every endpoint acts
+// on the patient linked to the caller's account; other patients' records are never reachable.
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/portal", auth.RequireRole(auth.RolePatient))
+	{
+		group.GET("/profile", GetProfile)
+		group.PUT("/profile", UpdateProfile)
+		group.GET("/appointments", GetAppointments)
+		group.POST("/appointments", BookAppointment(deps.Sender, deps.Stripe))
+		group.POST("/appointments/:public_id/cancel", CancelAppointment(deps.Sender, deps.Stripe))
+		group.POST("/appointments/:public_id/payment", PayAppointment(deps.Stripe))
+		group.GET("/appointments/:public_id/forms", GetAppointmentForms)
+		group.PUT("/appointments/:public_id/forms/:form_id", SubmitAppointmentForm)
+		group.GET("/consents", GetConsents)
+		group.POST("/consents", GiveConsent)
+		group.POST("/consents/:id/withdraw", WithdrawConsent)
+		group.GET("/dependents", GetDependents)
+		group.POST("/dependents", AddDependent)
+		group.DELETE("/dependents/:id", RemoveDependent)
+		group.GET("/services", GetServices)
+		group.GET("/services/:id/employees", GetServiceEmployees)
+		group.GET("/availability", GetAvailability)
+	}
+}
+
+// CancellationNotice reads the online cancellation cutoff from PORTAL_CANCELLATION_NOTICE (e.g. "12h")
+func CancellationNotice() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("PORTAL_CANCELLATION_NOTICE")); err == nil && d >= 0 {
+		return d
+	}
+	return DefaultCancellationNotice
+}
+
+// currentPatientID returns the patient linked to the caller, writing a 403 when there is none
+func currentPatientID(c *gin.Context) (int, bool) {
+	claims, ok := auth.CurrentUser(c)
+	if !ok || claims.PatientID == nil {
+		c.Error(apierr.Forbidden("Your account is not linked to a patient record"))
+		return 0, false
+	}
+	return *claims.PatientID, true
+}
+
+// profileView is the part of the patient record shown to the patient
+type profileView struct {
+	ID                    string  `json:"id"`
+	FirstName             string  `json:"first_name"`
+	LastName              string  `json:"last_name"`
+	Email                 string  `json:"email"`
+	Phone                 string  `json:"phone"`
+	DateOfBirth           *string `json:"date_of_birth"`
+	EmergencyContactName  *string `json:"emergency_contact_name"`
+	EmergencyContactPhone *string `json:"emergency_contact_phone"`
+}
+
+func newProfileView(patient *models.Patient) profileView {
+	return profileView{
+		ID:                    patient.PublicID,
+		FirstName:             patient.FirstName,
+		LastName:              patient.LastName,
+		Email:                 patient.Email,
+		Phone:                 patient.Phone,
+		DateOfBirth:           patient.DateOfBirth,
+		EmergencyContactName:  patient.EmergencyContactName,
+		EmergencyContactPhone: patient.EmergencyContactPhone,
+	}
+}
+
+func GetProfile(c *gin.Context) {
+	patientID, ok := currentPatientID(c)
+	if !ok {
+		return
+	}
+	patient, err := database.GetPatient(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	access.Patients(c, patientID)
+	c.JSON(http.StatusOK, newProfileView(patient))
+}
+
+// UpdateProfile lets the patient change their contact details. Identity, insurance and
+// medical record fields stay with the clinic.
+func UpdateProfile(c *gin.Context) {
+	patientID, ok := currentPatientID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Email                 string  `json:"email" binding:"required,email"`
+		Phone                 string  `json:"phone" binding:"required,phone"`
+		EmergencyContactName  *string `json:"emergency_contact_name"`
+		EmergencyContactPhone *string `json:"emergency_contact_phone" binding:"omitnil,phone"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	patient, err := database.GetPatient(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	patient.Email, patient.Phone = req.Email, req.Phone
+	patient.EmergencyContactName, patient.EmergencyContactPhone = req.EmergencyContactName, req.EmergencyContactPhone
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdatePatient(ctx, patientID, patient); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPatients, patientID, audit.ActionUpdate, patient)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrStaleVersion) {
+			c.Error(apierr.Conflict("Your profile was changed while saving; try again"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, newProfileView(patient))
+}
+
+// appointmentView is an appointment as the patient sees it: addressed by public_id, in the
+// employee's timezone, without staff notes
+type appointmentView struct {
+	ID              string       `json:"id"`
+	StartDatetime   time.Time    `json:"start_datetime"`
+	EndDatetime     time.Time    `json:"end_datetime"`
+	Timezone        string       `json:"timezone"`
+	Status          string       `json:"status"`
+	AppointmentType *string      `json:"appointment_type"`
+	ClinicName      string       `json:"clinic_name"`
+	ServiceName     string       `json:"service_name"`
+	EmployeeName    string       `json:"employee_name"`
+	PaymentStatus   string       `json:"payment_status"`
+	PaymentAmount   *money.Money `json:"payment_amount"`
+	Cancellable     bool         `json:"cancellable"`
+	// Payment is the Stripe checkout, given only when a booking is made
+	Payment *payments.Checkout `json:"payment,omitempty"`
+}
+
+// viewBuilder renders appointment views, caching the clinics, services and employees they name
+type viewBuilder struct {
+	clinics   map[int]*models.Clinic
+	services  map[int]*models.Service
+	employees map[int]*models.Employee
+	now       time.Time
+}
+
+func newViewBuilder(now time.Time) *viewBuilder {
+	return &viewBuilder{
+		clinics:   map[int]*models.Clinic{},
+		services:  map[int]*models.Service{},
+		employees: map[int]*models.Employee{},
+		now:       now,
+	}
+}
+
+func (b *viewBuilder) build(ctx context.Context, appointment *models.Appointment) (appointmentView, error) {
+	clinic, ok := b.clinics[appointment.ClinicID]
+	if !ok {
+		var err error
+		if clinic, err = database.GetClinic(ctx, appointment.ClinicID); err != nil {
+			return appointmentView{}, err
+		}
+		b.clinics[appointment.ClinicID] = clinic
+	}
+	service, ok := b.services[appointment.ServiceID]
+	if !ok {
+		var err error
+		if service, err = database.GetService(ctx, appointment.ServiceID); err != nil {
+			return appointmentView{}, err
+		}
+		b.services[appointment.ServiceID] = service
+	}
+	employee, ok := b.employees[appointment.EmployeeID]
+	if !ok {
+		var err error
+		if employee, err = database.GetEmployee(ctx, appointment.EmployeeID); err != nil {
+			return appointmentView{}, err
+		}
+		b.employees[appointment.EmployeeID] = employee
+	}
+
+	loc := timeutil.LoadLocation(employee.Timezone)
+	return appointmentView{
+		ID:              appointment.PublicID,
+		StartDatetime:   appointment.StartDatetime.In(loc),
+		EndDatetime:     appointment.EndDatetime.In(loc),
+		Timezone:        loc.String(),
+		Status:          appointment.Status,
+		AppointmentType: appointment.AppointmentType,
+		ClinicName:      clinic.Name,
+		ServiceName:     service.Name,
+		EmployeeName:    employee.FirstName + " " + employee.LastName,
+		PaymentStatus:   appointment.PaymentStatus,
+		PaymentAmount:   appointment.PaymentAmount,
+		Cancellable:     cancellationRefusal(appointment, b.now) == "",
+	}, nil
+}
+
+// cancellationRefusal applies the online cancellation policy: only scheduled or confirmed
+// bookings, and only up to CancellationNotice before they start. It returns why the booking
+// cannot be cancelled, or "" when it can.
+func cancellationRefusal(appointment *models.Appointment, now time.Time) string {
+	if appointment.Status != "SCHEDULED" && appointment.Status != "CONFIRMED" {
+		return notCancellable
+	}
+	if notice := CancellationNotice(); appointment.StartDatetime.Sub(now) < notice {
+		return fmt.Sprintf("Appointments can only be cancelled online up to %s before they start; please contact the clinic", availability.FormatLead(notice))
+	}
+	return ""
+}
+
+// GetAppointments lists the patient's appointments, or with ?dependent_id= a dependent's,
+// split into upcoming (soonest first) and past (latest first)
+func GetAppointments(c *gin.Context) {
+	patientID, ok := subjectPatientID(c)
+	if !ok {
+		return
+	}
+	appointments, err := database.GetPatientAppointments(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	now := time.Now()
+	builder := newViewBuilder(now)
+	upcoming, past := []appointmentView{}, []appointmentView{}
+	for i := range appointments {
+		view, err := builder.build(c.Request.Context(), &appointments[i])
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if appointments[i].EndDatetime.After(now) {
+			upcoming = append(upcoming, view)
+		} else {
+			past = append(past, view)
+		}
+	}
+	slices.Reverse(upcoming)
+	c.JSON(http.StatusOK, gin.H{"upcoming": upcoming, "past": past})
+}
+
+// CancelAppointment cancels one of the patient's own bookings, subject to the cancellation
+// policy, offers the freed slot to the waiting list and refunds the card payment if it was
+// cancelled in time
+func CancelAppointment(sender notifications.Sender, stripe *payments.Stripe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		patientID, ok := currentPatientID(c)
+		if !ok {
+			return
+		}
+		appointment, ok := ownAppointment(c, patientID)
+		if !ok {
+			return
+		}
+		if refusal := cancellationRefusal(appointment, time.Now()); refusal != "" {
+			c.Error(apierr.Unprocessable(refusal))
+			return
+		}
+
+		cancelled, err := cancelForPatient(c.Request.Context(), sender, stripe, appointment, PortalCancellationReason)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if !cancelled {
+			c.Error(apierr.Unprocessable(notCancellable))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Appointment cancelled successfully"})
+	}
+}
+
+// cancelForPatient cancels a booking the patient asked to cancel and follows up as for any
+// cancellation: the patient and employee are emailed, the slot is offered to the waiting list
+// and a card payment is refunded if it was cancelled in time. It reports false when the
+// booking was no longer scheduled or confirmed.
+func cancelForPatient(ctx context.Context, sender notifications.Sender, stripe *payments.Stripe, appointment *models.Appointment, reason string) (bool, error) {
+	var updated *models.Appointment
+	err := database.WithTx(ctx, func(ctx context.Context) error {
+		cancelled, err := database.CancelAppointment(ctx, appointment.ID, reason)
+		if err != nil || !cancelled {
+			return err
+		}
+		if updated, err = database.GetAppointment(ctx, appointment.ID); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityAppointments, appointment.ID, audit.ActionUpdate, updated); err != nil {
+			return err
+		}
+		return webhooks.Publish(ctx, webhooks.EventAppointmentCancelled, webhooks.AppointmentData(updated))
+	})
+	if err != nil || updated == nil {
+		return false, err
+	}
+	hl7.Publish(ctx, hl7.TriggerCancelled, updated)
+	notifications.SendAppointmentEmails(ctx, sender, notifications.EventCancelled, updated)
+	waitlist.FillAfterCancellation(ctx, sender, appointment)
+	stripe.RefundAfterCancellation(ctx, appointment)
+	return true, nil
+}
+
+// PayAppointment returns the Stripe checkout for one of the patient's own unpaid bookings
+func PayAppointment(stripe *payments.Stripe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		patientID, ok := currentPatientID(c)
+		if !ok {
+			return
+		}
+		if stripe == nil {
+			c.Error(apierr.Unavailable("Online payment is not available"))
+			return
+		}
+		appointment, ok := ownAppointment(c, patientID)
+		if !ok {
+			return
+		}
+
+		checkout, err := stripe.StartPayment(c.Request.Context(), appointment)
+		if err != nil {
+			if errors.Is(err, payments.ErrNothingToPay) {
+				c.Error(apierr.Unprocessable("Nothing is due for this appointment"))
+				return
+			}
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, checkout)
+	}
+}
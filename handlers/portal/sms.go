@@ -0,0 +1,142 @@
+// Medical Appointment Booking System - Patient Portal Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package portal
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"bookings/apierr"
+	"bookings/database"
+	"bookings/notifications"
+	"bookings/payments"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// SMSCancellationReason is recorded on appointments the patient cancels by replying to an SMS
+const SMSCancellationReason = "Cancelled by patient via SMS reply"
+
+// Replies the inbound SMS webhook understands, after trimming, upper-casing and dropping
+// trailing punctuation
+var (
+	confirmReplies = []string{"YES", "Y", "CONFIRM"}
+	cancelReplies  = []string{"CANCEL", "NO", "N"}
+)
+
+// replyHelp answers a message the webhook does not understand
+const replyHelp = "Reply YES to confirm or CANCEL to cancel your appointment."
+
+// twiml is a Twilio messaging response that replies to the patient with one SMS
+type twiml struct {
+	XMLName xml.Name `xml:"Response"`
+	Message string   `xml:"Message"`
+}
+
+// InboundSMS receives patients' replies to SMS reminders as a Twilio-compatible webhook
+// (form-encoded From and Body, signed with X-Twilio-Signature). YES confirms and CANCEL
+// cancels the soonest upcoming scheduled or confirmed appointment of the patient with the
+// sending number, under the portal's cancellation policy; the patient is answered by SMS
+// through the TwiML response. A request without a valid signature answers 403. It answers 404
+// when SMS is not sent through a provider that accepts replies.
+func InboundSMS(sender notifications.Sender, stripe *payments.Stripe) gin.HandlerFunc {
+	verifier := notifications.InboundSMS(sender)
+	return func(c *gin.Context) {
+		if verifier == nil {
+			c.Error(apierr.NotFound("Route not found"))
+			return
+		}
+		if err := c.Request.ParseForm(); err != nil {
+			c.Error(apierr.Validation("Could not read the request body"))
+			return
+		}
+		if !verifier.VerifyInbound(inboundURL(c), c.Request.PostForm, c.GetHeader("X-Twilio-Signature")) {
+			c.Error(apierr.Forbidden("Invalid signature"))
+			return
+		}
+
+		reply, err := answerSMS(c.Request.Context(), sender, stripe, c.Request.PostForm.Get("From"), c.Request.PostForm.Get("Body"))
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.XML(http.StatusOK, twiml{Message: reply})
+	}
+}
+
+// inboundURL is the URL the provider called, which its signature covers: the request's
+// path under PUBLIC_BASE_URL, or on the request's own host when that is not set
+func inboundURL(c *gin.Context) string {
+	if base := notifications.PublicBaseURL(c.Request.Context()); base != "" {
+		return base + c.Request.URL.RequestURI()
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + c.Request.URL.RequestURI()
+}
+
+// answerSMS acts on a reply from phone and returns the SMS to answer it with
+func answerSMS(ctx context.Context, sender notifications.Sender, stripe *payments.Stripe, phone, body string) (string, error) {
+	command := strings.ToUpper(strings.TrimRight(strings.TrimSpace(body), ".!"))
+	confirm := slices.Contains(confirmReplies, command)
+	if !confirm && !slices.Contains(cancelReplies, command) {
+		return replyHelp, nil
+	}
+
+	appointment, err := database.GetPendingAppointmentByPhone(ctx, phone, time.Now())
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "We could not find an upcoming appointment for this number. Please contact the clinic.", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	description, err := describeAppointment(ctx, appointment)
+	if err != nil {
+		return "", err
+	}
+	slog.InfoContext(ctx, "portal: SMS reply", "appointment_id", appointment.ID, "command", command)
+
+	if confirm {
+		if appointment.Status == "SCHEDULED" {
+			if _, err := confirmAppointment(ctx, appointment.ID); err != nil {
+				return "", err
+			}
+		}
+		return "Thank you. Your appointment " + description + " is confirmed.", nil
+	}
+
+	if refusal := cancellationRefusal(appointment, time.Now()); refusal != "" {
+		return refusal + ".", nil
+	}
+	cancelled, err := cancelForPatient(ctx, sender, stripe, appointment, SMSCancellationReason)
+	if err != nil {
+		return "", err
+	}
+	if !cancelled {
+		return notCancellable + ".", nil
+	}
+	return "Your appointment " + description + " is cancelled.", nil
+}
@@ -0,0 +1,67 @@
+// Medical Appointment Booking System - Patient Portal Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package portal
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Unsubscribe turns off every notification to the patient a signed unsubscribe link was
+// issued for. GET serves the link the patient opens; POST is the one-click unsubscribe mail
+// clients send from List-Unsubscribe. Unsubscribing again answers the same. Staff can turn
+// notifications back on through the patient's notification preferences.
+func Unsubscribe(c *gin.Context) {
+	publicID, err := auth.ParseSignedToken(auth.PurposeUnsubscribe, c.Param("token"))
+	if err != nil {
+		renderLink(c, http.StatusNotFound, "Link not valid", "This link is not valid. Please contact the clinic.")
+		return
+	}
+	patient, err := database.GetPatientByPublicID(c.Request.Context(), publicID)
+	if err != nil {
+		renderLink(c, http.StatusNotFound, "Link not valid", "This link is not valid. Please contact the clinic.")
+		return
+	}
+
+	prefs, err := database.GetNotificationPreferences(c.Request.Context(), patient.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if prefs.UnsubscribedAt == nil || prefs.Channel != "NONE" {
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			var err error
+			if prefs, err = database.UnsubscribePatient(ctx, patient.ID, time.Now()); err != nil {
+				return err
+			}
+			return audit.Record(ctx, audit.EntityNotificationPreferences, patient.ID, audit.ActionUpdate, prefs)
+		})
+		if err != nil {
+			c.Error(err)
+			return
+		}
+	}
+	renderLink(c, http.StatusOK, "Unsubscribed",
+		"You will no longer receive appointment notifications from us. Contact the clinic if you want them again.")
+}
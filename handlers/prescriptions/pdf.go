@@ -0,0 +1,171 @@
+// Medical Appointment Booking System - Time Off Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package prescriptions
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+
+	"bookings/database"
+	"bookings/models"
+	"bookings/pdf"
+)
+
+// document is a prescription with the records its printout shows
+type document struct {
+	Prescription *models.Prescription
+	Appointment  *models.Appointment
+	Patient      *models.Patient
+	Prescriber   *models.Employee
+	Clinic       *models.Clinic
+}
+
+// load gathers the records a prescription's printout shows; the clinic is the appointment's
+func load(ctx context.Context, prescription *models.Prescription) (*document, error) {
+	appointment, err := database.GetAppointment(ctx, prescription.AppointmentID)
+	if err != nil {
+		return nil, err
+	}
+	patient, err := database.GetPatient(ctx, prescription.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	prescriber, err := database.GetEmployee(ctx, prescription.PrescriberID)
+	if err != nil {
+		return nil, err
+	}
+	clinic, err := database.GetClinic(ctx, appointment.ClinicID)
+	if err != nil {
+		return nil, err
+	}
+	return &document{Prescription: prescription, Appointment: appointment, Patient: patient, Prescriber: prescriber, Clinic: clinic}, nil
+}
+
+// number identifies the prescription on paper
+func (d *document) number() string {
+	return fmt.Sprintf("RX-%06d", d.Prescription.ID)
+}
+
+func (d *document) filename() string {
+	return d.number() + ".pdf"
+}
+
+// statusNotices are printed across prescriptions that can no longer be dispensed
+var statusNotices = map[string]string{
+	"EXPIRED":   "EXPIRED - NOT VALID FOR DISPENSING",
+	"CANCELLED": "CANCELLED - NOT VALID FOR DISPENSING",
+}
+
+// pdf renders the prescription on one A4 page: the clinic letterhead, the patient, the
+// medication with its dosage, duration and refills, the instructions, the validity and the
+// prescriber with a line to sign
+func (d *document) pdf() []byte {
+	const left, right = 56.0, pdf.PageWidth - 56.0
+	rx := d.Prescription
+	var p pdf.Page
+
+	// Letterhead
+	y := float64(pdf.PageHeight - 72)
+	p.Text(left, y, 18, true, d.Clinic.Name)
+	for _, line := range []string{d.Clinic.Address, d.Clinic.Phone, d.Clinic.Email} {
+		if line != "" {
+			y -= 14
+			p.Text(left, y, 10, false, line)
+		}
+	}
+	p.Text(right-150, pdf.PageHeight-72, 20, true, "PRESCRIPTION")
+	p.Text(right-150, pdf.PageHeight-90, 10, false, "No. "+d.number())
+	p.Text(right-150, pdf.PageHeight-104, 10, false, "Date "+rx.StartsOn)
+	y = math.Min(y, pdf.PageHeight-104) - 24
+	p.Rule(left, right, y)
+
+	// Patient
+	y -= 24
+	p.Text(left, y, 10, true, "Patient")
+	y -= 14
+	p.Text(left, y, 10, false, d.Patient.FirstName+" "+d.Patient.LastName)
+	if d.Patient.DateOfBirth != nil {
+		y -= 14
+		p.Text(left, y, 10, false, "Date of birth "+*d.Patient.DateOfBirth)
+	}
+	if d.Patient.MedicalRecordNumber != "" {
+		y -= 14
+		p.Text(left, y, 10, false, "MRN "+d.Patient.MedicalRecordNumber)
+	}
+
+	// Medication
+	y -= 22
+	for _, line := range pdf.Wrap(rx.Medication, 14, right-left) {
+		y -= 14
+		p.Text(left, y, 14, true, line)
+	}
+	y -= 4
+	for _, line := range pdf.Wrap(rx.Dosage, 10, right-left) {
+		y -= 14
+		p.Text(left, y, 10, false, line)
+	}
+	y -= 14
+	p.Text(left, y, 10, false, fmt.Sprintf("For %d days, %s", rx.DurationDays, refills(rx.Refills)))
+	if rx.Instructions != nil && *rx.Instructions != "" {
+		y -= 24
+		p.Text(left, y, 10, true, "Instructions")
+		for _, line := range pdf.Wrap(*rx.Instructions, 10, right-left) {
+			y -= 14
+			p.Text(left, y, 10, false, line)
+		}
+	}
+	y -= 24
+	p.Text(left, y, 10, false, "Valid from "+rx.StartsOn+" until "+rx.ExpiresOn)
+	if notice, ok := statusNotices[rx.Status]; ok {
+		y -= 24
+		p.Text(left, y, 12, true, notice)
+	}
+
+	// Prescriber
+	y -= 60
+	p.Rule(left, left+220, y)
+	y -= 14
+	p.Text(left, y, 10, true, d.Prescriber.FirstName+" "+d.Prescriber.LastName)
+	for _, line := range []string{d.Prescriber.Specialty, license(d.Prescriber.LicenseNumber)} {
+		if line != "" {
+			y -= 14
+			p.Text(left, y, 10, false, line)
+		}
+	}
+
+	p.Text(left, 56, 8, false, "Appointment reference "+d.Appointment.PublicID)
+	return p.Bytes()
+}
+
+func refills(n int) string {
+	switch n {
+	case 0:
+		return "no refills"
+	case 1:
+		return "1 refill"
+	}
+	return strconv.Itoa(n) + " refills"
+}
+
+func license(number string) string {
+	if number == "" {
+		return ""
+	}
+	return "License " + number
+}
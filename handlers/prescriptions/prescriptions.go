@@ -0,0 +1,248 @@
+// Medical Appointment Booking System - Time Off Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package prescriptions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// RegisterRoutes mounts the prescription endpoints under /appointments/:id/prescriptions,
+// /patients/:id/prescriptions and /prescriptions
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	appointments := r.Group("/appointments/:id/prescriptions", auth.Authorize(auth.Prescriptions))
+	{
+		appointments.GET("", GetAppointmentPrescriptions)
+		appointments.POST("", CreatePrescription)
+	}
+	r.GET("/patients/:id/prescriptions", auth.Authorize(auth.Prescriptions), GetPatientPrescriptions)
+	group := r.Group("/prescriptions", auth.Authorize(auth.Prescriptions))
+	{
+		group.GET("/:id", GetPrescription)
+		group.GET("/:id/pdf", GetPrescriptionPDF)
+		group.POST("/:id/cancel", CancelPrescription)
+	}
+}
+
+// cancelRequest is the body of a cancellation
+type cancelRequest struct {
+	Reason string `json:"reason" binding:"required,max=2000"`
+}
+
+// GetAppointmentPrescriptions lists the prescriptions written at an appointment, latest first
+func GetAppointmentPrescriptions(c *gin.Context) {
+	appointment, ok := appointmentParam(c)
+	if !ok {
+		return
+	}
+	list(c, database.PrescriptionFilter{AppointmentID: &appointment.ID})
+}
+
+// GetPatientPrescriptions lists a patient's prescriptions, latest first, optionally only those
+// with a given status
+func GetPatientPrescriptions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	if _, err := database.GetPatient(c.Request.Context(), id); err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	list(c, database.PrescriptionFilter{PatientID: &id})
+}
+
+// list responds with a page of the prescriptions matching filter and the status query
+// parameter, recording the read in the access log
+func list(c *gin.Context, filter database.PrescriptionFilter) {
+	if raw := c.Query("status"); raw != "" {
+		if !slices.Contains(models.PrescriptionStatuses, raw) {
+			c.Error(apierr.Validation("Invalid status"))
+			return
+		}
+		filter.Status = &raw
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	prescriptions, total, err := database.GetPrescriptions(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	access.Prescriptions(c, prescriptions...)
+	handlers.RespondPage(c, prescriptions, total, page)
+}
+
+// GetPrescription returns a prescription, recording the read in the access log
+func GetPrescription(c *gin.Context) {
+	prescription, ok := prescriptionParam(c)
+	if !ok {
+		return
+	}
+	access.Prescriptions(c, *prescription)
+	c.JSON(http.StatusOK, prescription)
+}
+
+// CreatePrescription prescribes a medication at a completed appointment. The appointment's
+// employee is the prescriber, and the prescription starts on the appointment's date in their
+// timezone unless starts_on is given.
+func CreatePrescription(c *gin.Context) {
+	appointment, ok := appointmentParam(c)
+	if !ok {
+		return
+	}
+	var prescription models.Prescription
+	if !handlers.BindJSON(c, &prescription) {
+		return
+	}
+	if appointment.Status != "COMPLETED" {
+		c.Error(apierr.Conflict("Prescriptions are written at completed appointments"))
+		return
+	}
+
+	prescription.AppointmentID = appointment.ID
+	prescription.PatientID = appointment.PatientID
+	prescription.PrescriberID = appointment.EmployeeID
+	prescription.CreatedBy = nil
+	if id, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		prescription.CreatedBy = &id
+	}
+	if prescription.StartsOn == "" {
+		employee, err := database.GetEmployee(c.Request.Context(), appointment.EmployeeID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		prescription.StartsOn = appointment.StartDatetime.In(timeutil.LoadLocation(employee.Timezone)).Format(timeutil.DateLayout)
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreatePrescription(ctx, &prescription); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPrescriptions, prescription.ID, audit.ActionCreate, prescription)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, prescription)
+}
+
+// CancelPrescription cancels an active prescription, giving the reason
+func CancelPrescription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	var req cancelRequest
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	var prescription *models.Prescription
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if prescription, err = database.CancelPrescription(ctx, id, req.Reason); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityPrescriptions, prescription.ID, audit.ActionUpdate, prescription)
+	})
+	if err != nil {
+		c.Error(prescriptionError(err))
+		return
+	}
+	c.JSON(http.StatusOK, prescription)
+}
+
+// GetPrescriptionPDF renders a prescription as a printable PDF. The patient's details are on
+// it, so the read is logged.
+func GetPrescriptionPDF(c *gin.Context) {
+	prescription, ok := prescriptionParam(c)
+	if !ok {
+		return
+	}
+	doc, err := load(c.Request.Context(), prescription)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	access.Prescriptions(c, *prescription)
+	c.Header("Content-Disposition", `inline; filename="`+doc.filename()+`"`)
+	c.Data(http.StatusOK, "application/pdf", doc.pdf())
+}
+
+// appointmentParam loads the appointment in the path, writing a 400 or 404 when there is none
+func appointmentParam(c *gin.Context) (*models.Appointment, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return nil, false
+	}
+	appointment, err := database.GetAppointment(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return nil, false
+	}
+	return appointment, true
+}
+
+// prescriptionParam loads the prescription in the path, writing a 400 or 404 when there is none
+func prescriptionParam(c *gin.Context) (*models.Prescription, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return nil, false
+	}
+	prescription, err := database.GetPrescription(c.Request.Context(), id)
+	if err != nil {
+		c.Error(prescriptionError(err))
+		return nil, false
+	}
+	return prescription, true
+}
+
+// prescriptionError maps the prescription errors to responses
+func prescriptionError(err error) error {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return apierr.NotFound("Prescription not found")
+	case errors.Is(err, database.ErrPrescriptionNotActive):
+		return apierr.Conflict(err.Error())
+	}
+	return err
+}
@@ -0,0 +1,667 @@
+// Medical Appointment Booking System - Public Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package public
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/availability"
+	"bookings/captcha"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/handlers/consents"
+	"bookings/handlers/slotholds"
+	"bookings/hl7"
+	"bookings/models"
+	"bookings/money"
+	"bookings/notifications"
+	"bookings/payments"
+	"bookings/timeutil"
+	"bookings/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// holdNotFound is the answer for a hold token that is unknown, used or expired
+const holdNotFound = "Slot hold not found or expired"
+
+// clinicView is a clinic as listed to the public
+type clinicView struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	City    string `json:"city"`
+	Phone   string `json:"phone"`
+	Email   string `json:"email"`
+}
+
+// serviceView is a bookable service as listed to the public, with the consent documents the
+// person booking must agree to
+type serviceView struct {
+	ID               int                      `json:"id"`
+	Name             string                   `json:"name"`
+	Description      string                   `json:"description"`
+	DurationMinutes  int                      `json:"duration_minutes"`
+	Price            money.Money              `json:"price"`
+	MinAgeYears      *int                     `json:"min_age_years"`
+	MaxAgeYears      *int                     `json:"max_age_years"`
+	RequiredConsents []models.ConsentDocument `json:"required_consents"`
+}
+
+// employeeSlots are the free slots of one practitioner
+type employeeSlots struct {
+	EmployeeID int                     `json:"employee_id"`
+	FirstName  string                  `json:"first_name"`
+	LastName   string                  `json:"last_name"`
+	Specialty  string                  `json:"specialty"`
+	Timezone   string                  `json:"timezone"`
+	Slots      []availability.Interval `json:"slots"`
+}
+
+// holdView is a slot hold as shown to the person booking. The token is the only way back to
+// the hold, so it is never logged.
+type holdView struct {
+	HoldToken      string    `json:"hold_token"`
+	ClinicID       int       `json:"clinic_id"`
+	EmployeeID     int       `json:"employee_id"`
+	ServiceID      int       `json:"service_id"`
+	StartDatetime  time.Time `json:"start_datetime"`
+	EndDatetime    time.Time `json:"end_datetime"`
+	Timezone       string    `json:"timezone"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	PatientDetails bool      `json:"patient_details"`
+}
+
+// GetClinics lists the clinics of the organization whose subdomain is called that take online
+// bookings
+func GetClinics(c *gin.Context) {
+	clinics, err := database.GetBookableClinics(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	views := make([]clinicView, 0, len(clinics))
+	for _, clinic := range clinics {
+		views = append(views, newClinicView(&clinic))
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// GetClinicServices lists the services that can be booked online at a clinic
+func GetClinicServices(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	if _, ok := bookableClinic(c, id); !ok {
+		return
+	}
+	services, err := database.GetBookableServices(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	documents, err := database.GetCurrentConsentDocuments(c.Request.Context(), nil)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	views := make([]serviceView, 0, len(services))
+	for _, s := range services {
+		required := []models.ConsentDocument{}
+		for _, document := range documents {
+			if slices.Contains(s.RequiredConsents, document.Kind) {
+				required = append(required, document)
+			}
+		}
+		views = append(views, serviceView{ID: s.ID, Name: s.Name, Description: s.Description, DurationMinutes: s.DurationMinutes, Price: s.Price,
+			MinAgeYears: s.MinAgeYears, MaxAgeYears: s.MaxAgeYears, RequiredConsents: required})
+	}
+	c.JSON(http.StatusOK, views)
+}
+
+// GetSlots lists the free slots for a service at a clinic on a date, per practitioner.
+// employee_id narrows the search to one of them.
+func GetSlots(c *gin.Context) {
+	clinicID, err := strconv.Atoi(c.Query("clinic_id"))
+	if err != nil {
+		c.Error(apierr.Validation("clinic_id is required"))
+		return
+	}
+	serviceID, err := strconv.Atoi(c.Query("service_id"))
+	if err != nil {
+		c.Error(apierr.Validation("service_id is required"))
+		return
+	}
+	date, err := timeutil.ParseDate(c.Query("date"))
+	if err != nil {
+		c.Error(apierr.Validation("date must be given as YYYY-MM-DD"))
+		return
+	}
+	employeeID, ok := handlers.OptionalIntQuery(c, "employee_id")
+	if !ok {
+		return
+	}
+
+	if _, ok := bookableClinic(c, clinicID); !ok {
+		return
+	}
+	service, ok := bookableService(c, serviceID)
+	if !ok {
+		return
+	}
+	employees, err := database.GetBookableEmployees(c.Request.Context(), service.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	result := []employeeSlots{}
+	now := time.Now()
+	for i := range employees {
+		employee := &employees[i]
+		if employee.ClinicID != clinicID || (employeeID != nil && employee.ID != *employeeID) {
+			continue
+		}
+		slots, err := availability.FreeSlots(c.Request.Context(), employee, service, date, nil, now)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if len(slots) == 0 {
+			continue
+		}
+		result = append(result, employeeSlots{
+			EmployeeID: employee.ID,
+			FirstName:  employee.FirstName,
+			LastName:   employee.LastName,
+			Specialty:  employee.Specialty,
+			Timezone:   availability.Location(employee).String(),
+			Slots:      slots,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"clinic_id":        clinicID,
+		"service_id":       service.ID,
+		"date":             date.Format(timeutil.DateLayout),
+		"duration_minutes": service.DurationMinutes,
+		"employees":        result,
+	})
+}
+
+// CreateHold holds one of the slots GetSlots offers while the patient gives their details.
+// The request must carry a CAPTCHA answer when CAPTCHA_SECRET is set. The hold lasts as long
+// as staff holds (SLOT_HOLD_TTL) and is addressed by its token from then on.
+func CreateHold(verifier *captcha.Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			EmployeeID    int       `json:"employee_id" binding:"required"`
+			ServiceID     int       `json:"service_id" binding:"required"`
+			StartDatetime time.Time `json:"start_datetime" binding:"required"`
+			CaptchaToken  string    `json:"captcha_token"`
+		}
+		if !handlers.BindJSON(c, &req) {
+			return
+		}
+		passed, err := verifier.Verify(c.Request.Context(), req.CaptchaToken, c.ClientIP())
+		if err != nil {
+			c.Error(apierr.Unavailable("The CAPTCHA could not be checked; try again"))
+			return
+		}
+		if !passed {
+			c.Error(apierr.Forbidden("CAPTCHA check failed"))
+			return
+		}
+
+		service, ok := bookableService(c, req.ServiceID)
+		if !ok {
+			return
+		}
+		employee, ok := bookableEmployee(c, req.EmployeeID, service)
+		if !ok {
+			return
+		}
+		if _, ok := bookableClinic(c, employee.ClinicID); !ok {
+			return
+		}
+		loc := availability.Location(employee)
+		slots, err := availability.FreeSlots(c.Request.Context(), employee, service, timeutil.LocalDate(req.StartDatetime, loc), nil, time.Now())
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		i := slices.IndexFunc(slots, func(slot availability.Interval) bool { return slot.Start.Equal(req.StartDatetime) })
+		if i < 0 {
+			c.Error(apierr.Conflict("That time is not available; choose one of the offered slots"))
+			return
+		}
+
+		token, err := newHoldToken()
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		hold := models.SlotHold{
+			EmployeeID:    employee.ID,
+			ServiceID:     service.ID,
+			StartDatetime: slots[i].Start,
+			EndDatetime:   slots[i].End,
+			HoldToken:     token,
+			ExpiresAt:     time.Now().Add(slotholds.HoldTTL()),
+		}
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			if err := database.CreateSlotHold(ctx, &hold); err != nil {
+				return err
+			}
+			snapshot := hold
+			snapshot.HoldToken = ""
+			return audit.Record(ctx, audit.EntitySlotHolds, hold.ID, audit.ActionCreate, snapshot)
+		})
+		if err != nil {
+			if errors.Is(err, database.ErrSlotTaken) {
+				c.Error(apierr.Conflict("That time is not available; choose one of the offered slots"))
+				return
+			}
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusCreated, newHoldView(&hold, employee))
+	}
+}
+
+// ReleaseHold gives a held slot back, e.g. when the patient goes back to choose another
+func ReleaseHold(c *gin.Context) {
+	var id int
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if id, err = database.ReleaseSlotHold(ctx, c.Param("token")); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntitySlotHolds, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrHoldNotFound) {
+			c.Error(apierr.NotFound(holdNotFound))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Slot hold released"})
+}
+
+// SetHoldPatient records who a hold is for. A patient already registered with the email is
+// booked when the last name and date of birth match theirs; otherwise a new patient is
+// created. Details that contradict the registered patient are refused rather than letting
+// anyone who knows an email book in that patient's name or see their record.
+//
+// A parent or legal guardian booking for a child under 18 gives their own details and the
+// child's as dependent. The hold is then for the child: one of the guardian's dependents with
+// the same name and date of birth, or a new patient linked to them, who takes the guardian's
+// phone for reminders.
+func SetHoldPatient(c *gin.Context) {
+	var req struct {
+		FirstName   string `json:"first_name" binding:"required"`
+		LastName    string `json:"last_name" binding:"required"`
+		Email       string `json:"email" binding:"required,email"`
+		Phone       string `json:"phone" binding:"omitempty,phone"`
+		DateOfBirth string `json:"date_of_birth" binding:"required,datetime=2006-01-02,pastdate"`
+		Dependent   *struct {
+			FirstName    string `json:"first_name" binding:"required"`
+			LastName     string `json:"last_name" binding:"required"`
+			DateOfBirth  string `json:"date_of_birth" binding:"required,datetime=2006-01-02,pastdate"`
+			Relationship string `json:"relationship" binding:"required,oneof=PARENT LEGAL_GUARDIAN"`
+		} `json:"dependent"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+	token := c.Param("token")
+	hold, err := database.GetLiveSlotHold(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, database.ErrHoldNotFound) {
+			c.Error(apierr.NotFound(holdNotFound))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	if hold.PatientID != nil {
+		c.Error(apierr.Conflict("Patient details were already given for this hold"))
+		return
+	}
+	employee, err := database.GetEmployee(c.Request.Context(), hold.EmployeeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	service, err := database.GetService(c.Request.Context(), hold.ServiceID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	dateOfBirth := req.DateOfBirth
+	if req.Dependent != nil {
+		if age, err := timeutil.AgeOn(req.Dependent.DateOfBirth, time.Now()); err != nil || age < 0 || age >= availability.AdultAge {
+			c.Error(apierr.Unprocessable("Only children under 18 can be booked for online; please contact the clinic"))
+			return
+		}
+		dateOfBirth = req.Dependent.DateOfBirth
+	}
+	if err := availability.CheckPatientAge(service, &dateOfBirth, hold.StartDatetime, availability.Location(employee)); err != nil {
+		c.Error(apierr.Unprocessable(err.Error()))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		patient, err := database.GetPatientByEmail(ctx, req.Email)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			patient = &models.Patient{
+				FirstName:   req.FirstName,
+				LastName:    req.LastName,
+				Email:       req.Email,
+				Phone:       req.Phone,
+				DateOfBirth: &req.DateOfBirth,
+				Active:      true,
+				ClinicID:    &employee.ClinicID,
+			}
+			if err := database.CreatePatient(ctx, patient); err != nil {
+				return err
+			}
+			if err := audit.Record(ctx, audit.EntityPatients, patient.ID, audit.ActionCreate, patient); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case patient.DeletedAt != nil || !patient.Active || patient.DateOfBirth == nil ||
+			*patient.DateOfBirth != req.DateOfBirth || !sameName(patient.LastName, req.LastName):
+			return apierr.Conflict("These details do not match our records; please contact the clinic to book")
+		}
+		if req.Dependent == nil {
+			return database.SetSlotHoldPatient(ctx, token, patient.ID)
+		}
+
+		dependent, err := guardiansDependent(ctx, patient.ID, req.Dependent.FirstName, req.Dependent.LastName, req.Dependent.DateOfBirth)
+		if err != nil {
+			return err
+		}
+		if dependent == nil {
+			dependent = &models.Patient{
+				FirstName:   req.Dependent.FirstName,
+				LastName:    req.Dependent.LastName,
+				Phone:       patient.Phone,
+				DateOfBirth: &req.Dependent.DateOfBirth,
+				Active:      true,
+				ClinicID:    &employee.ClinicID,
+			}
+			if err := database.CreatePatient(ctx, dependent); err != nil {
+				return err
+			}
+			if err := audit.Record(ctx, audit.EntityPatients, dependent.ID, audit.ActionCreate, dependent); err != nil {
+				return err
+			}
+			linked := models.PatientRelationship{GuardianID: patient.ID, DependentID: dependent.ID, Relationship: req.Dependent.Relationship}
+			if err := database.CreateRelationship(ctx, &linked); err != nil {
+				return err
+			}
+			if err := audit.Record(ctx, audit.EntityPatientRelationships, linked.ID, audit.ActionCreate, linked); err != nil {
+				return err
+			}
+		} else if dependent.DeletedAt != nil || !dependent.Active {
+			return apierr.Conflict("These details do not match our records; please contact the clinic to book")
+		}
+		return database.SetSlotHoldPatient(ctx, token, dependent.ID)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrHoldNotFound) {
+			c.Error(apierr.NotFound(holdNotFound))
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	view := newHoldView(hold, employee)
+	view.PatientDetails = true
+	c.JSON(http.StatusOK, view)
+}
+
+// ConfirmHold books the held slot for the patient given with SetHoldPatient. The consent
+// documents listed in consent_document_ids are recorded as agreed to online first, and the
+// booking is refused while any the service requires are missing. A priced booking comes
+// with its Stripe checkout when card payments are configured, as in the portal.
+func ConfirmHold(sender notifications.Sender, stripe *payments.Stripe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Notes              *string `json:"notes"`
+			ConsentDocumentIDs []int   `json:"consent_document_ids"`
+		}
+		if c.Request.ContentLength > 0 && !handlers.BindJSON(c, &req) {
+			return
+		}
+		token := c.Param("token")
+		hold, err := database.GetLiveSlotHold(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, database.ErrHoldNotFound) {
+				c.Error(apierr.NotFound(holdNotFound))
+				return
+			}
+			c.Error(err)
+			return
+		}
+		if hold.PatientID == nil {
+			c.Error(apierr.Unprocessable("Give the patient's details before confirming"))
+			return
+		}
+		employee, err := database.GetEmployee(c.Request.Context(), hold.EmployeeID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		service, err := database.GetService(c.Request.Context(), hold.ServiceID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if !recordConsents(c, *hold.PatientID, req.ConsentDocumentIDs) {
+			return
+		}
+		if err := consents.CheckRequired(c.Request.Context(), *hold.PatientID, service); err != nil {
+			c.Error(err)
+			return
+		}
+
+		channel := "WEB"
+		price := service.Price
+		appointment := models.Appointment{
+			PatientID:      *hold.PatientID,
+			ClinicID:       employee.ClinicID,
+			Status:         "SCHEDULED",
+			BookingChannel: &channel,
+			Notes:          req.Notes,
+			PaymentStatus:  "PENDING",
+			PaymentAmount:  &price,
+		}
+		err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			if err := database.CreateAppointmentFromHold(ctx, &appointment, token); err != nil {
+				return err
+			}
+			if err := audit.Record(ctx, audit.EntityAppointments, appointment.ID, audit.ActionCreate, appointment); err != nil {
+				return err
+			}
+			return webhooks.Publish(ctx, webhooks.EventAppointmentCreated, webhooks.AppointmentData(&appointment))
+		})
+		if err != nil {
+			var unavailable *database.ResourceUnavailableError
+			switch {
+			case errors.Is(err, database.ErrHoldNotFound):
+				c.Error(apierr.NotFound(holdNotFound))
+			case errors.Is(err, database.ErrAppointmentConflict) || errors.As(err, &unavailable):
+				c.Error(apierr.Conflict("That time is no longer available; choose another slot"))
+			default:
+				c.Error(err)
+			}
+			return
+		}
+		hl7.Publish(c.Request.Context(), hl7.TriggerBooked, &appointment)
+		notifications.SendAppointmentEmails(c.Request.Context(), sender, notifications.EventBooked, &appointment)
+
+		view, err := newAppointmentView(c.Request.Context(), &appointment)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		view.Payment = stripe.CheckoutForBooking(c.Request.Context(), &appointment)
+		c.JSON(http.StatusCreated, view)
+	}
+}
+
+// recordConsents records the patient's agreement, given while booking online, to each of the
+// documents not already in force, writing the error response and returning false when one
+// cannot be
+func recordConsents(c *gin.Context, patientID int, documentIDs []int) bool {
+	if len(documentIDs) == 0 {
+		return true
+	}
+	given, err := database.GetPatientConsents(c.Request.Context(), patientID)
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	for _, documentID := range documentIDs {
+		if slices.ContainsFunc(given, func(g models.PatientConsent) bool { return g.ConsentDocumentID == documentID && g.WithdrawnAt == nil }) {
+			continue
+		}
+		consent := models.PatientConsent{PatientID: patientID, ConsentDocumentID: documentID, CaptureMethod: "ONLINE"}
+		err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			if err := database.RecordConsent(ctx, &consent); err != nil {
+				return err
+			}
+			return audit.Record(ctx, audit.EntityPatientConsents, consent.ID, audit.ActionCreate, consent)
+		})
+		if err != nil {
+			consents.WriteError(c, err, "Consent document not found")
+			return false
+		}
+		given = append(given, consent)
+	}
+	return true
+}
+
+func newClinicView(clinic *models.Clinic) clinicView {
+	return clinicView{ID: clinic.ID, Name: clinic.Name, Address: clinic.Address, City: clinic.City, Phone: clinic.Phone, Email: clinic.Email}
+}
+
+func newHoldView(hold *models.SlotHold, employee *models.Employee) holdView {
+	loc := availability.Location(employee)
+	return holdView{
+		HoldToken:      hold.HoldToken,
+		ClinicID:       employee.ClinicID,
+		EmployeeID:     hold.EmployeeID,
+		ServiceID:      hold.ServiceID,
+		StartDatetime:  hold.StartDatetime.In(loc),
+		EndDatetime:    hold.EndDatetime.In(loc),
+		Timezone:       loc.String(),
+		ExpiresAt:      hold.ExpiresAt,
+		PatientDetails: hold.PatientID != nil,
+	}
+}
+
+// bookableClinic loads an active clinic, writing a 404 when there is none
+func bookableClinic(c *gin.Context, id int) (*models.Clinic, bool) {
+	clinic, err := database.GetClinic(c.Request.Context(), id)
+	if err != nil || !clinic.Active || clinic.DeletedAt != nil {
+		c.Error(apierr.NotFound("Clinic not found"))
+		return nil, false
+	}
+	return clinic, true
+}
+
+// bookableService loads an active service, writing a 404 when there is none
+func bookableService(c *gin.Context, id int) (*models.Service, bool) {
+	service, err := database.GetService(c.Request.Context(), id)
+	if err != nil || !service.Active {
+		c.Error(apierr.NotFound("Service not found"))
+		return nil, false
+	}
+	return service, true
+}
+
+// bookableEmployee loads an active employee who offers the service
+func bookableEmployee(c *gin.Context, id int, service *models.Service) (*models.Employee, bool) {
+	employee, err := database.GetEmployee(c.Request.Context(), id)
+	if err != nil || !employee.Active || employee.DeletedAt != nil {
+		c.Error(apierr.NotFound("Employee not found"))
+		return nil, false
+	}
+	offers, err := database.EmployeeOffersService(c.Request.Context(), employee.ID, service.ID)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	if !offers {
+		c.Error(apierr.Unprocessable("Employee does not offer " + service.Name))
+		return nil, false
+	}
+	return employee, true
+}
+
+func newHoldToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sameName compares names as patients type them, ignoring case and surrounding spaces
+func sameName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// guardiansDependent finds the guardian's dependent with the given name and date of birth,
+// or returns nil when they have none
+func guardiansDependent(ctx context.Context, guardianID int, firstName, lastName, dateOfBirth string) (*models.Patient, error) {
+	relationships, err := database.GetDependents(ctx, guardianID)
+	if err != nil {
+		return nil, err
+	}
+	for _, relationship := range relationships {
+		dependent, err := database.GetPatient(ctx, relationship.DependentID)
+		if err != nil {
+			return nil, err
+		}
+		if dependent.DateOfBirth != nil && *dependent.DateOfBirth == dateOfBirth &&
+			sameName(dependent.FirstName, firstName) && sameName(dependent.LastName, lastName) {
+			return dependent, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,99 @@
+// Medical Appointment Booking System - Public Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package public
+
+import (
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// directoryEntry is a practitioner as listed in the provider directory
+type directoryEntry struct {
+	ID                int        `json:"id"`
+	FirstName         string     `json:"first_name"`
+	LastName          string     `json:"last_name"`
+	Specialty         string     `json:"specialty"`
+	Bio               string     `json:"bio"`
+	PhotoURL          *string    `json:"photo_url"`
+	Languages         []string   `json:"languages"`
+	AcceptedInsurance []string   `json:"accepted_insurance"`
+	Timezone          string     `json:"timezone"`
+	Clinic            clinicView `json:"clinic"`
+}
+
+// GetProviders searches the provider directory, so patients can find the right clinician
+// before booking. specialty, city (of the clinic), service_id, language and insurance narrow
+// the list; results are paginated and ordered by name.
+func GetProviders(c *gin.Context) {
+	var filter database.ProviderFilter
+	if specialty := c.Query("specialty"); specialty != "" {
+		filter.Specialty = &specialty
+	}
+	if city := c.Query("city"); city != "" {
+		filter.City = &city
+	}
+	if language := c.Query("language"); language != "" {
+		filter.Language = &language
+	}
+	if insurance := c.Query("insurance"); insurance != "" {
+		filter.Insurance = &insurance
+	}
+	var ok bool
+	if filter.ServiceID, ok = handlers.OptionalIntQuery(c, "service_id"); !ok {
+		return
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	employees, total, err := database.GetProviders(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	clinics := map[int]*models.Clinic{}
+	entries := make([]directoryEntry, 0, len(employees))
+	for i := range employees {
+		e := &employees[i]
+		clinic, loaded := clinics[e.ClinicID]
+		if !loaded {
+			if clinic, err = database.GetClinic(c.Request.Context(), e.ClinicID); err != nil {
+				c.Error(err)
+				return
+			}
+			clinics[e.ClinicID] = clinic
+		}
+		entries = append(entries, directoryEntry{
+			ID:                e.ID,
+			FirstName:         e.FirstName,
+			LastName:          e.LastName,
+			Specialty:         e.Specialty,
+			Bio:               e.Bio,
+			PhotoURL:          e.PhotoURL,
+			Languages:         e.Languages,
+			AcceptedInsurance: e.AcceptedInsurance,
+			Timezone:          availability.Location(e).String(),
+			Clinic:            newClinicView(clinic),
+		})
+	}
+	handlers.RespondPage(c, entries, total, page)
+}
@@ -0,0 +1,120 @@
+// Medical Appointment Booking System - Public Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package public
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"bookings/apierr"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/payments"
+	"bookings/ratelimit"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the patient-facing endpoints under /public. Patients and appointments
+// are addressed by opaque public identifiers; integer ids never leave the staff API. Clinics,
+// services and employees are listed by id for the online booking flow under /public/booking.
+// Placing a hold is limited by deps.Holds on top of the limit every open endpoint has.
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/public")
+	{
+		group.GET("/appointments/:public_id", GetAppointment)
+		group.GET("/clinics/:slug/config", GetWidgetConfig(deps.Captcha, deps.Stripe))
+		group.GET("/providers", GetProviders)
+	}
+	booking := group.Group("/booking")
+	{
+		booking.GET("/clinics", GetClinics)
+		booking.GET("/clinics/:id/services", GetClinicServices)
+		booking.GET("/slots", GetSlots)
+		booking.POST("/holds", deps.Holds.Middleware(ratelimit.ByIP), CreateHold(deps.Captcha))
+		booking.DELETE("/holds/:token", ReleaseHold)
+		booking.PUT("/holds/:token/patient", SetHoldPatient)
+		booking.POST("/holds/:token/confirm", ConfirmHold(deps.Sender, deps.Stripe))
+	}
+}
+
+// appointmentView is the limited appointment detail shown to patients
+type appointmentView struct {
+	ID            string    `json:"id"`
+	PatientID     string    `json:"patient_id"`
+	StartDatetime time.Time `json:"start_datetime"`
+	EndDatetime   time.Time `json:"end_datetime"`
+	Timezone      string    `json:"timezone"`
+	Status        string    `json:"status"`
+	ClinicName    string    `json:"clinic_name"`
+	ServiceName   string    `json:"service_name"`
+	EmployeeName  string    `json:"employee_name"`
+	PaymentStatus string    `json:"payment_status"`
+	// Payment is the Stripe checkout, given only when a booking is made
+	Payment *payments.Checkout `json:"payment,omitempty"`
+}
+
+func GetAppointment(c *gin.Context) {
+	appointment, err := database.GetAppointmentByPublicID(c.Request.Context(), c.Param("public_id"))
+	if err != nil {
+		c.Error(apierr.NotFound("Appointment not found"))
+		return
+	}
+	view, err := newAppointmentView(c.Request.Context(), appointment)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+// newAppointmentView loads the records an appointment refers to and builds its patient view
+func newAppointmentView(ctx context.Context, appointment *models.Appointment) (*appointmentView, error) {
+	patient, err := database.GetPatient(ctx, appointment.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	clinic, err := database.GetClinic(ctx, appointment.ClinicID)
+	if err != nil {
+		return nil, err
+	}
+	service, err := database.GetService(ctx, appointment.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	employee, err := database.GetEmployee(ctx, appointment.EmployeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := timeutil.LoadLocation(employee.Timezone)
+	return &appointmentView{
+		ID:            appointment.PublicID,
+		PatientID:     patient.PublicID,
+		StartDatetime: appointment.StartDatetime.In(loc),
+		EndDatetime:   appointment.EndDatetime.In(loc),
+		Timezone:      loc.String(),
+		Status:        appointment.Status,
+		ClinicName:    clinic.Name,
+		ServiceName:   service.Name,
+		EmployeeName:  employee.FirstName + " " + employee.LastName,
+		PaymentStatus: appointment.PaymentStatus,
+	}, nil
+}
@@ -0,0 +1,136 @@
+// Medical Appointment Booking System - Public Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package public
+
+import (
+	"net/http"
+	"slices"
+
+	"bookings/apierr"
+	"bookings/availability"
+	"bookings/captcha"
+	"bookings/database"
+	"bookings/handlers/slotholds"
+	"bookings/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+// brandingView is how a clinic's booking widget looks
+type brandingView struct {
+	LogoURL        *string `json:"logo_url"`
+	PrimaryColor   *string `json:"primary_color"`
+	WelcomeMessage *string `json:"welcome_message"`
+}
+
+// providerView is a practitioner the widget offers, with the services they can be booked for
+type providerView struct {
+	ID         int    `json:"id"`
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	Specialty  string `json:"specialty"`
+	Timezone   string `json:"timezone"`
+	ServiceIDs []int  `json:"service_ids"`
+}
+
+// policiesView are the booking rules the widget explains before the patient books
+type policiesView struct {
+	MinLeadMinutes      int  `json:"min_lead_minutes"`
+	MaxAdvanceDays      *int `json:"max_advance_days"`
+	HoldMinutes         int  `json:"hold_minutes"`
+	CaptchaRequired     bool `json:"captcha_required"`
+	CardPayments        bool `json:"card_payments"`
+	RefundNoticeMinutes *int `json:"refund_notice_minutes,omitempty"`
+}
+
+// GetWidgetConfig returns what a clinic's embedded booking widget needs to start: the clinic,
+// its branding, the services and practitioners that can be booked and the booking policies.
+// A widget that lists allowed origins only answers pages on those sites.
+func GetWidgetConfig(verifier *captcha.Verifier, stripe *payments.Stripe) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Origin")
+		widget, err := database.GetClinicWidgetBySlug(c.Request.Context(), c.Param("slug"))
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Clinic not found"))
+			return
+		}
+		if !widget.Enabled {
+			c.Error(apierr.NotFound("Clinic not found"))
+			return
+		}
+		if len(widget.AllowedOrigins) > 0 && !slices.Contains(widget.AllowedOrigins, c.GetHeader("Origin")) {
+			c.Error(apierr.Forbidden("This site may not load the clinic's booking widget"))
+			return
+		}
+		clinic, ok := bookableClinic(c, widget.ClinicID)
+		if !ok {
+			return
+		}
+
+		services, err := database.GetBookableServices(c.Request.Context(), clinic.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		serviceViews := make([]serviceView, 0, len(services))
+		providers := []providerView{}
+		byID := map[int]int{}
+		for _, s := range services {
+			serviceViews = append(serviceViews, serviceView{ID: s.ID, Name: s.Name, Description: s.Description, DurationMinutes: s.DurationMinutes, Price: s.Price,
+				MinAgeYears: s.MinAgeYears, MaxAgeYears: s.MaxAgeYears})
+			employees, err := database.GetBookableEmployees(c.Request.Context(), s.ID)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			for _, e := range employees {
+				if e.ClinicID != clinic.ID {
+					continue
+				}
+				i, seen := byID[e.ID]
+				if !seen {
+					i = len(providers)
+					byID[e.ID] = i
+					providers = append(providers, providerView{ID: e.ID, FirstName: e.FirstName, LastName: e.LastName, Specialty: e.Specialty, Timezone: availability.Location(&e).String()})
+				}
+				providers[i].ServiceIDs = append(providers[i].ServiceIDs, s.ID)
+			}
+		}
+		slices.SortFunc(providers, func(a, b providerView) int { return a.ID - b.ID })
+
+		policies := policiesView{
+			MinLeadMinutes:  clinic.MinLeadMinutes,
+			MaxAdvanceDays:  clinic.MaxAdvanceDays,
+			HoldMinutes:     int(slotholds.HoldTTL().Minutes()),
+			CaptchaRequired: verifier != nil,
+			CardPayments:    stripe != nil,
+		}
+		if stripe != nil {
+			notice := int(stripe.RefundNotice.Minutes())
+			policies.RefundNoticeMinutes = &notice
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"slug":      widget.Slug,
+			"clinic":    newClinicView(clinic),
+			"branding":  brandingView{LogoURL: widget.LogoURL, PrimaryColor: widget.PrimaryColor, WelcomeMessage: widget.WelcomeMessage},
+			"services":  serviceViews,
+			"providers": providers,
+			"policies":  policies,
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Medical Appointment Booking System - Handlers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OptionalIntQuery parses an optional integer query parameter, writing a 400 when it is malformed
+func OptionalIntQuery(c *gin.Context, name string) (*int, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		c.Error(apierr.Validation("Invalid " + name))
+		return nil, false
+	}
+	return &value, true
+}
+
+// OptionalTimeQuery parses an optional RFC 3339 query parameter, writing a 400 when it is malformed
+func OptionalTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		c.Error(apierr.Validation(name + " must be an RFC 3339 timestamp"))
+		return nil, false
+	}
+	return &value, true
+}
+
+// OptionalLocationQuery parses an optional IANA timezone query parameter such as
+// Europe/London, writing a 400 when it is unknown. The server's own "Local" is refused.
+func OptionalLocationQuery(c *gin.Context, name string) (*time.Location, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	loc, err := time.LoadLocation(raw)
+	if err != nil || raw == "Local" {
+		c.Error(apierr.Validation("Invalid " + name))
+		return nil, false
+	}
+	return loc, true
+}
@@ -0,0 +1,312 @@
+// Medical Appointment Booking System - Time Off Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package referrals
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// RegisterRoutes mounts the referral endpoints under /referrals
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/referrals", auth.Authorize(auth.Referrals))
+	{
+		group.GET("", GetReferrals)
+		group.POST("", CreateReferral)
+		group.GET("/:id", GetReferral)
+		group.POST("/:id/accept", AcceptReferral)
+		group.POST("/:id/decline", DeclineReferral)
+		group.POST("/:id/book", BookReferral)
+	}
+}
+
+// acceptRequest is the body of an acceptance: the service the patient waits for
+type acceptRequest struct {
+	ServiceID     int     `json:"service_id" binding:"required"`
+	RequestedDate *string `json:"requested_date" binding:"omitnil,datetime=2006-01-02"`
+}
+
+// declineRequest is the body of a decline
+type declineRequest struct {
+	Reason string `json:"reason" binding:"required,max=2000"`
+}
+
+// bookRequest is the body of a booking: the appointment made for the referral
+type bookRequest struct {
+	AppointmentID int `json:"appointment_id" binding:"required"`
+}
+
+// GetReferrals lists referrals, latest first, filtered by patient_id, source_employee_id,
+// target_employee_id, target_clinic_id and status, recording the read in the access log
+func GetReferrals(c *gin.Context) {
+	var filter database.ReferralFilter
+	var ok bool
+	if filter.PatientID, ok = handlers.OptionalIntQuery(c, "patient_id"); !ok {
+		return
+	}
+	if filter.SourceEmployeeID, ok = handlers.OptionalIntQuery(c, "source_employee_id"); !ok {
+		return
+	}
+	if filter.TargetEmployeeID, ok = handlers.OptionalIntQuery(c, "target_employee_id"); !ok {
+		return
+	}
+	if filter.TargetClinicID, ok = handlers.OptionalIntQuery(c, "target_clinic_id"); !ok {
+		return
+	}
+	if raw := c.Query("status"); raw != "" {
+		if !slices.Contains(models.ReferralStatuses, raw) {
+			c.Error(apierr.Validation("Invalid status"))
+			return
+		}
+		filter.Status = &raw
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	referrals, total, err := database.GetReferrals(c.Request.Context(), filter, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	access.Referrals(c, referrals...)
+	handlers.RespondPage(c, referrals, total, page)
+}
+
+// GetReferral returns a referral, recording the read in the access log
+func GetReferral(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	referral, err := database.GetReferral(c.Request.Context(), id)
+	if err != nil {
+		c.Error(referralError(err))
+		return
+	}
+	access.Referrals(c, *referral)
+	c.JSON(http.StatusOK, referral)
+}
+
+// CreateReferral sends a referral from one employee to a target employee, a target specialty
+// or both. Without a target_clinic_id, a referral to an employee goes to their clinic.
+func CreateReferral(c *gin.Context) {
+	var referral models.Referral
+	if !handlers.BindJSON(c, &referral) {
+		return
+	}
+	if referral.TargetEmployeeID == nil && (referral.TargetSpecialty == nil || *referral.TargetSpecialty == "") {
+		c.Error(apierr.Validation("Give a target_employee_id or a target_specialty"))
+		return
+	}
+	if referral.TargetEmployeeID != nil && *referral.TargetEmployeeID == referral.SourceEmployeeID {
+		c.Error(apierr.Validation("An employee cannot refer a patient to themselves"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := database.GetPatient(ctx, referral.PatientID); err != nil {
+		c.Error(apierr.Lookup(err, "Patient not found"))
+		return
+	}
+	if _, err := database.GetEmployee(ctx, referral.SourceEmployeeID); err != nil {
+		c.Error(apierr.Lookup(err, "Source employee not found"))
+		return
+	}
+	if referral.TargetEmployeeID != nil {
+		target, err := database.GetEmployee(ctx, *referral.TargetEmployeeID)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Target employee not found"))
+			return
+		}
+		if referral.TargetClinicID == nil {
+			referral.TargetClinicID = &target.ClinicID
+		}
+	}
+	if referral.TargetClinicID != nil {
+		if _, err := database.GetClinic(ctx, *referral.TargetClinicID); err != nil {
+			c.Error(apierr.Lookup(err, "Target clinic not found"))
+			return
+		}
+	}
+
+	referral.CreatedBy = nil
+	if id, ok := auth.UserIDFromContext(ctx); ok {
+		referral.CreatedBy = &id
+	}
+	err := database.WithTx(ctx, func(ctx context.Context) error {
+		if err := database.CreateReferral(ctx, &referral); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityReferrals, referral.ID, audit.ActionCreate, referral)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, referral)
+}
+
+// AcceptReferral accepts a sent referral and puts its patient on the waiting list for a
+// service, with the referral's urgency and its target employee as the preferred one
+func AcceptReferral(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req acceptRequest
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+	if _, err := database.GetService(c.Request.Context(), req.ServiceID); err != nil {
+		c.Error(apierr.Lookup(err, "Service not found"))
+		return
+	}
+
+	notes := fmt.Sprintf("Referral #%d", id)
+	entry := models.WaitingList{ServiceID: req.ServiceID, RequestedDate: req.RequestedDate, Notes: &notes}
+	var referral *models.Referral
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if referral, err = database.AcceptReferral(ctx, id, &entry, time.Now()); err != nil {
+			return err
+		}
+		if err := audit.Record(ctx, audit.EntityWaitingList, entry.ID, audit.ActionCreate, entry); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityReferrals, referral.ID, audit.ActionUpdate, referral)
+	})
+	if err != nil {
+		c.Error(referralError(err))
+		return
+	}
+	c.JSON(http.StatusOK, referral)
+}
+
+// DeclineReferral declines a sent referral, giving the reason
+func DeclineReferral(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req declineRequest
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	var referral *models.Referral
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if referral, err = database.DeclineReferral(ctx, id, req.Reason, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityReferrals, referral.ID, audit.ActionUpdate, referral)
+	})
+	if err != nil {
+		c.Error(referralError(err))
+		return
+	}
+	c.JSON(http.StatusOK, referral)
+}
+
+// BookReferral records the appointment made for an accepted referral, which takes its
+// patient off the waiting list
+func BookReferral(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+	var req bookRequest
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+	existing, err := database.GetReferral(c.Request.Context(), id)
+	if err != nil {
+		c.Error(referralError(err))
+		return
+	}
+	appointment, err := database.GetAppointment(c.Request.Context(), req.AppointmentID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return
+	}
+	if appointment.PatientID != existing.PatientID {
+		c.Error(apierr.Validation("The appointment is for another patient"))
+		return
+	}
+	if appointment.Status == "CANCELLED" {
+		c.Error(apierr.Validation("The appointment is cancelled"))
+		return
+	}
+
+	var referral *models.Referral
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var scheduled *models.WaitingList
+		var err error
+		if referral, scheduled, err = database.BookReferral(ctx, id, appointment.ID, time.Now()); err != nil {
+			return err
+		}
+		if scheduled != nil {
+			if err := audit.Record(ctx, audit.EntityWaitingList, scheduled.ID, audit.ActionUpdate, scheduled); err != nil {
+				return err
+			}
+		}
+		return audit.Record(ctx, audit.EntityReferrals, referral.ID, audit.ActionUpdate, referral)
+	})
+	if err != nil {
+		c.Error(referralError(err))
+		return
+	}
+	c.JSON(http.StatusOK, referral)
+}
+
+func idParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// referralError maps the referral errors to responses
+func referralError(err error) error {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return apierr.NotFound("Referral not found")
+	case errors.Is(err, database.ErrReferralNotSent), errors.Is(err, database.ErrReferralNotAccepted):
+		return apierr.Conflict(err.Error())
+	}
+	return err
+}
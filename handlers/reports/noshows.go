@@ -0,0 +1,186 @@
+// Medical Appointment Booking System - Access Log Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package reports
+
+import (
+	"cmp"
+	"math"
+	"net/http"
+	"slices"
+	"time"
+
+	"bookings/apierr"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultLateNotice is how close to the start a cancellation counts as late when
+// late_notice is not given
+const DefaultLateNotice = 24 * time.Hour
+
+// NoShows is a no-show and late cancellation report, overall and sliced four ways
+type NoShows struct {
+	From             string                `json:"from"`
+	To               string                `json:"to"`
+	Timezone         string                `json:"timezone"`
+	LateNoticeHours  float64               `json:"late_notice_hours"`
+	Overall          AttendanceStats       `json:"overall"`
+	ByService        []ServiceStats        `json:"by_service"`
+	ByWeekday        []WeekdayStats        `json:"by_weekday"`
+	ByEmployee       []EmployeeStats       `json:"by_employee"`
+	ByPatientSegment []PatientSegmentStats `json:"by_patient_segment"`
+}
+
+// AttendanceStats counts a group of appointments by outcome. The percentages are of all
+// the group's appointments, cancelled ones included.
+type AttendanceStats struct {
+	Appointments            int     `json:"appointments"`
+	NoShows                 int     `json:"no_shows"`
+	Cancellations           int     `json:"cancellations"`
+	LateCancellations       int     `json:"late_cancellations"`
+	NoShowPercent           float64 `json:"no_show_percent"`
+	LateCancellationPercent float64 `json:"late_cancellation_percent"`
+}
+
+// ServiceStats is the AttendanceStats of one service
+type ServiceStats struct {
+	ServiceID   int    `json:"service_id"`
+	ServiceName string `json:"service_name"`
+	AttendanceStats
+}
+
+// WeekdayStats is the AttendanceStats of one weekday (ISO, 1 = Monday)
+type WeekdayStats struct {
+	Weekday int `json:"weekday"`
+	AttendanceStats
+}
+
+// EmployeeStats is the AttendanceStats of one employee
+type EmployeeStats struct {
+	EmployeeID   int    `json:"employee_id"`
+	EmployeeName string `json:"employee_name"`
+	AttendanceStats
+}
+
+// PatientSegmentStats is the AttendanceStats of one patient segment
+type PatientSegmentStats struct {
+	Segment string `json:"segment"`
+	AttendanceStats
+}
+
+func (s *AttendanceStats) add(row database.AttendanceRow) {
+	s.Appointments += row.Appointments
+	s.NoShows += row.NoShows
+	s.Cancellations += row.Cancellations
+	s.LateCancellations += row.LateCancellations
+	s.NoShowPercent = percent(s.NoShows, s.Appointments)
+	s.LateCancellationPercent = percent(s.LateCancellations, s.Appointments)
+}
+
+// percent is part of whole in percent, to one decimal
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*1000) / 10
+}
+
+// GetNoShows reports no-show and late cancellation rates of the appointments starting
+// between from and to (YYYY-MM-DD, inclusive, in tz, else the timezone of clinic_id, else
+// UTC; the current month by default), overall and by service, weekday, employee and
+// patient segment. Only appointments that have started count, since until then the
+// outcome is open. A cancellation is late when it came less than late_notice (e.g. "48h",
+// default 24h) before the start. clinic_id narrows it down.
+func GetNoShows(c *gin.Context) {
+	from, to, ok := dateRange(c, MaxReportDays)
+	if !ok {
+		return
+	}
+	lateNotice := DefaultLateNotice
+	if raw := c.Query("late_notice"); raw != "" {
+		var err error
+		if lateNotice, err = time.ParseDuration(raw); err != nil || lateNotice < 0 {
+			c.Error(apierr.Validation("late_notice must be a duration such as 24h"))
+			return
+		}
+	}
+	var filter database.AttendanceFilter
+	if filter.ClinicID, ok = handlers.OptionalIntQuery(c, "clinic_id"); !ok {
+		return
+	}
+	loc, ok := location(c, filter.ClinicID)
+	if !ok {
+		return
+	}
+
+	start, _ := timeutil.DayBounds(from, loc)
+	_, end := timeutil.DayBounds(to, loc)
+	rows, err := database.GetAttendance(c.Request.Context(), start, end, time.Now(), lateNotice, loc, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	report := NoShows{
+		From: from.Format(timeutil.DateLayout), To: to.Format(timeutil.DateLayout), Timezone: loc.String(),
+		LateNoticeHours: lateNotice.Hours(),
+	}
+	services := map[int]*ServiceStats{}
+	weekdays := map[int]*WeekdayStats{}
+	employees := map[int]*EmployeeStats{}
+	segments := map[string]*PatientSegmentStats{}
+	for _, row := range rows {
+		report.Overall.add(row)
+		if services[row.ServiceID] == nil {
+			services[row.ServiceID] = &ServiceStats{ServiceID: row.ServiceID, ServiceName: row.ServiceName}
+		}
+		services[row.ServiceID].add(row)
+		if weekdays[row.Weekday] == nil {
+			weekdays[row.Weekday] = &WeekdayStats{Weekday: row.Weekday}
+		}
+		weekdays[row.Weekday].add(row)
+		if employees[row.EmployeeID] == nil {
+			employees[row.EmployeeID] = &EmployeeStats{EmployeeID: row.EmployeeID, EmployeeName: row.EmployeeName}
+		}
+		employees[row.EmployeeID].add(row)
+		if segments[row.Segment] == nil {
+			segments[row.Segment] = &PatientSegmentStats{Segment: row.Segment}
+		}
+		segments[row.Segment].add(row)
+	}
+	report.ByService = sorted(services, func(a, b ServiceStats) int { return cmp.Compare(a.ServiceName, b.ServiceName) })
+	report.ByWeekday = sorted(weekdays, func(a, b WeekdayStats) int { return cmp.Compare(a.Weekday, b.Weekday) })
+	report.ByEmployee = sorted(employees, func(a, b EmployeeStats) int { return cmp.Compare(a.EmployeeName, b.EmployeeName) })
+	order := []string{database.SegmentNew, database.SegmentReturning, database.SegmentPriorNoShow}
+	report.ByPatientSegment = sorted(segments, func(a, b PatientSegmentStats) int {
+		return cmp.Compare(slices.Index(order, a.Segment), slices.Index(order, b.Segment))
+	})
+	c.JSON(http.StatusOK, report)
+}
+
+// sorted returns the map's values in the order of compare
+func sorted[K comparable, V any](m map[K]*V, compare func(a, b V) int) []V {
+	values := make([]V, 0, len(m))
+	for _, v := range m {
+		values = append(values, *v)
+	}
+	slices.SortFunc(values, compare)
+	return values
+}
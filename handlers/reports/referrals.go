@@ -0,0 +1,173 @@
+// Medical Appointment Booking System - Access Log Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package reports
+
+import (
+	"cmp"
+	"math"
+	"net/http"
+	"slices"
+
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Referrals is a referral turnaround report, overall and by urgency and target specialty
+type Referrals struct {
+	From        string                   `json:"from"`
+	To          string                   `json:"to"`
+	Timezone    string                   `json:"timezone"`
+	Overall     ReferralStats            `json:"overall"`
+	ByUrgency   []UrgencyReferralStats   `json:"by_urgency"`
+	BySpecialty []SpecialtyReferralStats `json:"by_specialty"`
+}
+
+// ReferralStats counts a group of referrals by outcome, with how many hours they took to be
+// answered (accepted or declined) and booked. The times are nil when no referral of the
+// group got that far.
+type ReferralStats struct {
+	Sent                int      `json:"sent"`
+	Pending             int      `json:"pending"`
+	Accepted            int      `json:"accepted"` // booked ones included
+	Booked              int      `json:"booked"`
+	Declined            int      `json:"declined"`
+	AcceptedPercent     float64  `json:"accepted_percent"` // of those answered
+	AvgResponseHours    *float64 `json:"avg_response_hours"`
+	MedianResponseHours *float64 `json:"median_response_hours"`
+	AvgBookingHours     *float64 `json:"avg_booking_hours"`
+	MedianBookingHours  *float64 `json:"median_booking_hours"`
+
+	responseHours, bookingHours []float64
+}
+
+// UrgencyReferralStats is the ReferralStats of one urgency level
+type UrgencyReferralStats struct {
+	UrgencyLevel string `json:"urgency_level"`
+	ReferralStats
+}
+
+// SpecialtyReferralStats is the ReferralStats of one target specialty
+type SpecialtyReferralStats struct {
+	Specialty string `json:"specialty"`
+	ReferralStats
+}
+
+func (s *ReferralStats) add(row database.ReferralTurnaroundRow) {
+	s.Sent++
+	switch row.Status {
+	case "SENT":
+		s.Pending++
+	case "ACCEPTED":
+		s.Accepted++
+	case "BOOKED":
+		s.Accepted++
+		s.Booked++
+	case "DECLINED":
+		s.Declined++
+	}
+	if row.ResponseHours != nil {
+		s.responseHours = append(s.responseHours, *row.ResponseHours)
+	}
+	if row.BookingHours != nil {
+		s.bookingHours = append(s.bookingHours, *row.BookingHours)
+	}
+}
+
+// finish works out the percentage and the times once every row has been added
+func (s *ReferralStats) finish() {
+	s.AcceptedPercent = percent(s.Accepted, s.Accepted+s.Declined)
+	s.AvgResponseHours, s.MedianResponseHours = averages(s.responseHours)
+	s.AvgBookingHours, s.MedianBookingHours = averages(s.bookingHours)
+}
+
+// averages returns the mean and median of hours, to one decimal, or nils when there are none
+func averages(hours []float64) (mean, median *float64) {
+	if len(hours) == 0 {
+		return nil, nil
+	}
+	sum := 0.0
+	for _, h := range hours {
+		sum += h
+	}
+	sorted := slices.Sorted(slices.Values(hours))
+	mid := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		mid = (sorted[len(sorted)/2-1] + mid) / 2
+	}
+	avg := math.Round(sum/float64(len(hours))*10) / 10
+	mid = math.Round(mid*10) / 10
+	return &avg, &mid
+}
+
+// GetReferralTurnaround reports the referrals sent between from and to (YYYY-MM-DD,
+// inclusive, in tz, else the timezone of target_clinic_id, else UTC; the current month by
+// default): how many were accepted, booked, declined or are still waiting for an answer,
+// and how many hours they took to be answered and booked, overall and by urgency and
+// target specialty. target_clinic_id narrows it down.
+func GetReferralTurnaround(c *gin.Context) {
+	from, to, ok := dateRange(c, MaxReportDays)
+	if !ok {
+		return
+	}
+	var filter database.ReferralTurnaroundFilter
+	if filter.TargetClinicID, ok = handlers.OptionalIntQuery(c, "target_clinic_id"); !ok {
+		return
+	}
+	loc, ok := location(c, filter.TargetClinicID)
+	if !ok {
+		return
+	}
+
+	start, _ := timeutil.DayBounds(from, loc)
+	_, end := timeutil.DayBounds(to, loc)
+	rows, err := database.GetReferralTurnaround(c.Request.Context(), start, end, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	report := Referrals{From: from.Format(timeutil.DateLayout), To: to.Format(timeutil.DateLayout), Timezone: loc.String()}
+	urgencies := map[string]*UrgencyReferralStats{}
+	specialties := map[string]*SpecialtyReferralStats{}
+	for _, row := range rows {
+		report.Overall.add(row)
+		if urgencies[row.UrgencyLevel] == nil {
+			urgencies[row.UrgencyLevel] = &UrgencyReferralStats{UrgencyLevel: row.UrgencyLevel}
+		}
+		urgencies[row.UrgencyLevel].add(row)
+		if specialties[row.Specialty] == nil {
+			specialties[row.Specialty] = &SpecialtyReferralStats{Specialty: row.Specialty}
+		}
+		specialties[row.Specialty].add(row)
+	}
+	report.Overall.finish()
+	for _, stats := range urgencies {
+		stats.finish()
+	}
+	for _, stats := range specialties {
+		stats.finish()
+	}
+	report.ByUrgency = sorted(urgencies, func(a, b UrgencyReferralStats) int {
+		return cmp.Compare(slices.Index(models.UrgencyLevels, a.UrgencyLevel), slices.Index(models.UrgencyLevels, b.UrgencyLevel))
+	})
+	report.BySpecialty = sorted(specialties, func(a, b SpecialtyReferralStats) int { return cmp.Compare(a.Specialty, b.Specialty) })
+	c.JSON(http.StatusOK, report)
+}
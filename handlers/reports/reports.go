@@ -0,0 +1,167 @@
+// Medical Appointment Booking System - Access Log Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package reports
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxReportDays is the longest range the revenue and no-show reports may cover
+const MaxReportDays = 366
+
+// RegisterRoutes mounts the report endpoints under /reports
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/reports", auth.Authorize(auth.Reports))
+	{
+		group.GET("/daily-schedule", GetDailySchedule)
+		group.GET("/utilization", auth.RequireRole(auth.RoleAdmin), GetUtilization)
+		group.GET("/revenue", auth.RequireRole(auth.RoleAdmin), GetRevenue)
+		group.GET("/no-shows", auth.RequireRole(auth.RoleAdmin), GetNoShows)
+		group.GET("/referrals", auth.RequireRole(auth.RoleAdmin), GetReferralTurnaround)
+	}
+}
+
+// DailySchedule is a clinic's appointments for one day, grouped by employee
+type DailySchedule struct {
+	ClinicID  int                `json:"clinic_id"`
+	Date      string             `json:"date"`
+	Timezone  string             `json:"timezone"`
+	Employees []EmployeeSchedule `json:"employees"`
+}
+
+// EmployeeSchedule is one employee's part of a DailySchedule
+type EmployeeSchedule struct {
+	EmployeeID   int                           `json:"employee_id"`
+	EmployeeName string                        `json:"employee_name"`
+	Appointments []database.DailyScheduleEntry `json:"appointments"`
+}
+
+// GetDailySchedule reports a clinic's appointments for a date (YYYY-MM-DD, default today),
+// grouped by employee, with patient names, services, rooms and statuses: the front desk's
+// morning printout. The day is taken in tz, else the clinic's timezone, else that of most
+// of its employees, else UTC; times are given in it.
+func GetDailySchedule(c *gin.Context) {
+	clinicID, ok := handlers.OptionalIntQuery(c, "clinic_id")
+	if !ok {
+		return
+	}
+	if clinicID == nil {
+		c.Error(apierr.Validation("clinic_id is required"))
+		return
+	}
+	if _, err := database.GetClinic(c.Request.Context(), *clinicID); err != nil {
+		c.Error(apierr.Lookup(err, "Clinic not found"))
+		return
+	}
+
+	loc, ok := location(c, clinicID)
+	if !ok {
+		return
+	}
+
+	day := timeutil.LocalDate(time.Now(), loc)
+	if raw := c.Query("date"); raw != "" {
+		var err error
+		if day, err = timeutil.ParseDate(raw); err != nil {
+			c.Error(apierr.Validation("date must be given as YYYY-MM-DD"))
+			return
+		}
+	}
+	dayStart, dayEnd := timeutil.DayBounds(day, loc)
+
+	entries, err := database.GetDailySchedule(c.Request.Context(), *clinicID, dayStart, dayEnd)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	report := DailySchedule{ClinicID: *clinicID, Date: day.Format(timeutil.DateLayout), Timezone: loc.String(), Employees: []EmployeeSchedule{}}
+	var patientIDs []int
+	for _, entry := range entries {
+		entry.Start, entry.End = entry.Start.In(loc), entry.End.In(loc)
+		if n := len(report.Employees); n == 0 || report.Employees[n-1].EmployeeID != entry.EmployeeID {
+			report.Employees = append(report.Employees, EmployeeSchedule{EmployeeID: entry.EmployeeID, EmployeeName: entry.EmployeeName})
+		}
+		employee := &report.Employees[len(report.Employees)-1]
+		employee.Appointments = append(employee.Appointments, entry)
+		patientIDs = append(patientIDs, entry.PatientID)
+	}
+	access.Patients(c, patientIDs...)
+	c.JSON(http.StatusOK, report)
+}
+
+// location reads the tz query parameter, which defaults to the timezone of the clinic
+// reported on, when there is one (see database.GetClinicTimezone), else UTC
+func location(c *gin.Context, clinicID *int) (*time.Location, bool) {
+	loc, ok := handlers.OptionalLocationQuery(c, "tz")
+	if !ok {
+		return nil, false
+	}
+	if loc != nil {
+		return loc, true
+	}
+	if clinicID == nil {
+		return time.UTC, true
+	}
+	timezone, err := database.GetClinicTimezone(c.Request.Context(), *clinicID)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	return timeutil.LoadLocation(timezone), true
+}
+
+// dateRange parses the from and to query parameters (YYYY-MM-DD, inclusive), which
+// default to the current month and may cover at most maxDays days
+func dateRange(c *gin.Context, maxDays int) (from, to time.Time, ok bool) {
+	now := time.Now().UTC()
+	from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to = from.AddDate(0, 1, -1)
+	var err error
+	if raw := c.Query("from"); raw != "" {
+		if from, err = timeutil.ParseDate(raw); err != nil {
+			c.Error(apierr.Validation("from must be given as YYYY-MM-DD"))
+			return from, to, false
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if to, err = timeutil.ParseDate(raw); err != nil {
+			c.Error(apierr.Validation("to must be given as YYYY-MM-DD"))
+			return from, to, false
+		}
+	}
+	if to.Before(from) {
+		c.Error(apierr.Validation("to must not be before from"))
+		return from, to, false
+	}
+	if to.After(from.AddDate(0, 0, maxDays-1)) {
+		c.Error(apierr.Validation("The range may cover at most " + strconv.Itoa(maxDays) + " days"))
+		return from, to, false
+	}
+	return from, to, true
+}
@@ -0,0 +1,138 @@
+// Medical Appointment Booking System - Access Log Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package reports
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"bookings/apierr"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/money"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RevenueIntervals are the periods a revenue report can be broken down by; weeks start on Monday
+var RevenueIntervals = []string{"day", "week", "month"}
+
+// Revenue is the payment amounts of a date range per period, clinic, service and payment
+// status, with totals per currency
+type Revenue struct {
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Interval string          `json:"interval"`
+	Timezone string          `json:"timezone"`
+	Rows     []RevenueLine   `json:"rows"`
+	Totals   []RevenueTotals `json:"totals"`
+}
+
+// RevenueLine is one row of a Revenue report
+type RevenueLine struct {
+	// Period is the first day of the day, week or month, which may lie before From
+	Period        string      `json:"period"`
+	ClinicID      int         `json:"clinic_id"`
+	ClinicName    string      `json:"clinic_name"`
+	ServiceID     int         `json:"service_id"`
+	ServiceName   string      `json:"service_name"`
+	PaymentStatus string      `json:"payment_status"`
+	Amount        money.Money `json:"amount"`
+	Appointments  int         `json:"appointments"`
+}
+
+// RevenueTotals adds up a Revenue report's rows in one currency by payment status
+type RevenueTotals struct {
+	Currency     string      `json:"currency"`
+	Paid         money.Money `json:"paid"`
+	Pending      money.Money `json:"pending"`
+	Refunded     money.Money `json:"refunded"`
+	Appointments int         `json:"appointments"`
+}
+
+// GetRevenue reports the payment amounts of the appointments starting between from and to
+// (YYYY-MM-DD, inclusive, in tz, else the timezone of clinic_id, else UTC; the current
+// month by default), broken down by interval (day, week or month; default day), clinic,
+// service and payment status, with paid, pending and refunded totals per currency.
+// clinic_id and service_id narrow it down.
+func GetRevenue(c *gin.Context) {
+	from, to, ok := dateRange(c, MaxReportDays)
+	if !ok {
+		return
+	}
+	interval := c.DefaultQuery("interval", "day")
+	if !slices.Contains(RevenueIntervals, interval) {
+		c.Error(apierr.Validation("interval must be one of: " + strings.Join(RevenueIntervals, ", ")))
+		return
+	}
+	var filter database.RevenueFilter
+	if filter.ClinicID, ok = handlers.OptionalIntQuery(c, "clinic_id"); !ok {
+		return
+	}
+	if filter.ServiceID, ok = handlers.OptionalIntQuery(c, "service_id"); !ok {
+		return
+	}
+	loc, ok := location(c, filter.ClinicID)
+	if !ok {
+		return
+	}
+
+	start, _ := timeutil.DayBounds(from, loc)
+	_, end := timeutil.DayBounds(to, loc)
+	rows, err := database.GetRevenue(c.Request.Context(), start, end, interval, loc, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	report := Revenue{
+		From: from.Format(timeutil.DateLayout), To: to.Format(timeutil.DateLayout), Interval: interval, Timezone: loc.String(),
+		Rows: make([]RevenueLine, 0, len(rows)), Totals: []RevenueTotals{},
+	}
+	totals := map[string]*RevenueTotals{}
+	for _, row := range rows {
+		amount := money.New(row.AmountMinor, row.Currency)
+		report.Rows = append(report.Rows, RevenueLine{
+			Period:   row.Period.Format(timeutil.DateLayout),
+			ClinicID: row.ClinicID, ClinicName: row.ClinicName, ServiceID: row.ServiceID, ServiceName: row.ServiceName,
+			PaymentStatus: row.PaymentStatus, Amount: amount, Appointments: row.Appointments,
+		})
+
+		total, seen := totals[amount.Currency]
+		if !seen {
+			zero := money.New(0, amount.Currency)
+			total = &RevenueTotals{Currency: amount.Currency, Paid: zero, Pending: zero, Refunded: zero}
+			totals[amount.Currency] = total
+		}
+		switch row.PaymentStatus {
+		case "PAID":
+			total.Paid.Amount += amount.Amount
+		case "PENDING":
+			total.Pending.Amount += amount.Amount
+		case "REFUNDED":
+			total.Refunded.Amount += amount.Amount
+		}
+		total.Appointments += row.Appointments
+	}
+	for _, total := range totals {
+		report.Totals = append(report.Totals, *total)
+	}
+	slices.SortFunc(report.Totals, func(a, b RevenueTotals) int { return strings.Compare(a.Currency, b.Currency) })
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,130 @@
+// Medical Appointment Booking System - Access Log Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package reports
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxUtilizationDays is the longest range a utilization report may cover
+const MaxUtilizationDays = 31
+
+// Utilization compares the hours employees were available with the hours they were booked
+type Utilization struct {
+	From      string                `json:"from"`
+	To        string                `json:"to"`
+	Employees []EmployeeUtilization `json:"employees"`
+}
+
+// EmployeeUtilization is one employee's line of a Utilization report
+type EmployeeUtilization struct {
+	EmployeeID     int     `json:"employee_id"`
+	EmployeeName   string  `json:"employee_name"`
+	ClinicID       int     `json:"clinic_id"`
+	AvailableHours float64 `json:"available_hours"`
+	BookedHours    float64 `json:"booked_hours"`
+	CompletedHours float64 `json:"completed_hours"`
+	// BookedPercent is booked hours as a share of available hours, above 100 when the
+	// employee is booked outside their hours; nil when they had no hours
+	BookedPercent *float64 `json:"booked_percent"`
+}
+
+// GetUtilization reports, for each active employee (optionally of clinic_id, or just
+// employee_id), the hours available from their working hours minus time off, the hours
+// booked and the hours completed between from and to (YYYY-MM-DD, inclusive, in each
+// employee's timezone). The range defaults to the current month.
+func GetUtilization(c *gin.Context) {
+	from, to, ok := dateRange(c, MaxUtilizationDays)
+	if !ok {
+		return
+	}
+	clinicID, ok := handlers.OptionalIntQuery(c, "clinic_id")
+	if !ok {
+		return
+	}
+	employeeID, ok := handlers.OptionalIntQuery(c, "employee_id")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	employees, err := database.GetActiveEmployees(ctx, clinicID, employeeID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	ranges := make([]database.EmployeeRange, len(employees))
+	available := make([]time.Duration, len(employees))
+	for i := range employees {
+		loc := availability.Location(&employees[i])
+		start, _ := timeutil.DayBounds(from, loc)
+		_, end := timeutil.DayBounds(to, loc)
+		ranges[i] = database.EmployeeRange{EmployeeID: employees[i].ID, Start: start, End: end}
+
+		// The night before from is included for the part of its shifts that runs past midnight
+		var windows []availability.Interval
+		for date := from.AddDate(0, 0, -1); !date.After(to); date = date.AddDate(0, 0, 1) {
+			day, err := availability.WorkingWindows(ctx, &employees[i], date)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			windows = append(windows, day...)
+		}
+		in := []availability.Interval{{Start: start, End: end}}
+		for _, w := range availability.Intersect(windows, in) {
+			available[i] += w.Duration()
+		}
+	}
+	booked, err := database.GetBookedTime(ctx, ranges)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	report := Utilization{From: from.Format(timeutil.DateLayout), To: to.Format(timeutil.DateLayout), Employees: []EmployeeUtilization{}}
+	for i, employee := range employees {
+		line := EmployeeUtilization{
+			EmployeeID:     employee.ID,
+			EmployeeName:   employee.FirstName + " " + employee.LastName,
+			ClinicID:       employee.ClinicID,
+			AvailableHours: hours(available[i]),
+			BookedHours:    hours(booked[employee.ID].Booked),
+			CompletedHours: hours(booked[employee.ID].Completed),
+		}
+		if available[i] > 0 {
+			percent := math.Round(float64(booked[employee.ID].Booked)/float64(available[i])*1000) / 10
+			line.BookedPercent = &percent
+		}
+		report.Employees = append(report.Employees, line)
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// hours rounds a duration to hundredths of an hour
+func hours(d time.Duration) float64 {
+	return math.Round(d.Hours()*100) / 100
+}
@@ -0,0 +1,161 @@
+// Medical Appointment Booking System - Resource Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package resources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the room and equipment endpoints under /resources
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/resources", auth.Authorize(auth.Resources))
+	{
+		group.GET("", GetResources)
+		group.GET("/:id", GetResource)
+		group.POST("", CreateResource)
+		group.PUT("/:id", UpdateResource)
+		group.DELETE("/:id", DeleteResource)
+	}
+}
+
+// GetResources lists resources, optionally only those at clinic_id
+func GetResources(c *gin.Context) {
+	clinicID, ok := handlers.OptionalIntQuery(c, "clinic_id")
+	if !ok {
+		return
+	}
+
+	resources, err := database.GetResources(c.Request.Context(), clinicID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resources)
+}
+
+func GetResource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	resource, err := database.GetResource(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Resource not found"))
+		return
+	}
+	c.JSON(http.StatusOK, resource)
+}
+
+func CreateResource(c *gin.Context) {
+	var resource models.Resource
+	if !handlers.BindJSON(c, &resource) || !clinicExists(c, resource.ClinicID) {
+		return
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateResource(ctx, &resource); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityResources, resource.ID, audit.ActionCreate, resource)
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resource)
+}
+
+// UpdateResource replaces a resource. Deactivating one keeps the bookings it already has but
+// stops it being picked for new ones.
+func UpdateResource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var resource models.Resource
+	if !handlers.BindJSON(c, &resource) || !clinicExists(c, resource.ClinicID) {
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateResource(ctx, id, &resource); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityResources, id, audit.ActionUpdate, resource)
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resource)
+}
+
+// DeleteResource removes a resource that has never been booked; one that has is deactivated instead
+func DeleteResource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.DeleteResource(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityResources, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Resource deleted successfully"})
+}
+
+// clinicExists writes a 400 and returns false when the resource's clinic does not exist
+func clinicExists(c *gin.Context, clinicID int) bool {
+	if clinic, err := database.GetClinic(c.Request.Context(), clinicID); err != nil || clinic.DeletedAt != nil {
+		c.Error(apierr.Validation("Clinic not found"))
+		return false
+	}
+	return true
+}
+
+// writeError maps the resource write errors to their responses
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrResourceNameTaken), errors.Is(err, database.ErrResourceInUse):
+		c.Error(apierr.Conflict(err.Error()))
+	default:
+		c.Error(apierr.Lookup(err, "Resource not found"))
+	}
+}
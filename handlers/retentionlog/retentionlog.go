@@ -0,0 +1,100 @@
+// Medical Appointment Booking System - Data Retention Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package retentionlog
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/config"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/retention"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the data retention endpoints under /retention
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/retention", auth.Authorize(auth.Retention))
+	{
+		group.GET("/policies", GetPolicies(deps.Retention))
+		group.GET("/runs", GetRuns)
+	}
+}
+
+// policyView is a class's retention policy with what it covers now
+type policyView struct {
+	DataClass string `json:"data_class"`
+	// Days is null for a class kept forever
+	Days   *int   `json:"days"`
+	Action string `json:"action"`
+	// Cutoff is when rows expire if they are older; null for a class kept forever
+	Cutoff *time.Time `json:"cutoff"`
+	// Expired counts the rows the next sweep would handle; null for a class kept forever
+	Expired *int `json:"expired"`
+}
+
+// GetPolicies lists the retention policy of each class of data, with the rows past it that
+// the next sweep will archive, move or purge
+func GetPolicies(policies config.Retention) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		configured := policies.Policies()
+		now := time.Now()
+		views := make([]policyView, 0, len(config.RetentionClasses))
+		for _, class := range config.RetentionClasses {
+			policy := configured[class]
+			view := policyView{DataClass: class, Action: policy.Action}
+			if cutoff, ok := retention.Cutoff(policy, now); ok {
+				expired, err := database.CountExpired(c.Request.Context(), class, cutoff)
+				if err != nil {
+					c.Error(err)
+					return
+				}
+				view.Days, view.Cutoff, view.Expired = &policy.Days, &cutoff, &expired
+			}
+			views = append(views, view)
+		}
+		c.JSON(http.StatusOK, views)
+	}
+}
+
+// GetRuns lists the retention runs that archived, moved or purged rows, latest first,
+// optionally of one data_class. The response is paginated.
+func GetRuns(c *gin.Context) {
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	var class *string
+	if raw := c.Query("data_class"); raw != "" {
+		if !slices.Contains(config.RetentionClasses, raw) {
+			c.Error(apierr.Validation("Invalid data_class"))
+			return
+		}
+		class = &raw
+	}
+	runs, total, err := database.GetRetentionRuns(c.Request.Context(), class, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, runs, total, page)
+}
@@ -0,0 +1,210 @@
+// Medical Appointment Booking System - Scheduling Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package scheduling
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/notifications"
+	"bookings/timeutil"
+	"bookings/waitlist"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultOverrideRange is how far ahead overrides are listed when no end date is given
+const defaultOverrideRange = 30
+
+// GetDayOverrides lists an employee's day overrides between from and to (YYYY-MM-DD,
+// inclusive); from defaults to today and to to 30 days later
+func GetDayOverrides(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	from := time.Now().UTC()
+	if raw := c.Query("from"); raw != "" {
+		if from, err = timeutil.ParseDate(raw); err != nil {
+			c.Error(apierr.Validation("from must be given as YYYY-MM-DD"))
+			return
+		}
+	}
+	to := from.AddDate(0, 0, defaultOverrideRange)
+	if raw := c.Query("to"); raw != "" {
+		if to, err = timeutil.ParseDate(raw); err != nil {
+			c.Error(apierr.Validation("to must be given as YYYY-MM-DD"))
+			return
+		}
+	}
+
+	overrides, err := database.ListDayOverrides(c.Request.Context(), id, from.Format(timeutil.DateLayout), to.Format(timeutil.DateLayout))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, overrides)
+}
+
+// PutDayOverride closes a day or sets its hours (shorter or longer than the weekly template)
+// for one employee and date. Appointments that would fall outside the new hours are returned
+// with a 409 unless force=true, in which case the override is saved and they are reported.
+// Free time on a day that stays open is offered to the waiting list.
+func PutDayOverride(sender notifications.Sender) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		employee, date, ok := overrideTarget(c)
+		if !ok {
+			return
+		}
+
+		var override models.DayOverride
+		if !handlers.BindJSON(c, &override) {
+			return
+		}
+		override.EmployeeID = employee.ID
+		override.Date = date.Format(timeutil.DateLayout)
+		if override.IsClosed {
+			override.StartTime, override.EndTime = nil, nil
+		} else if override.StartTime == nil || override.EndTime == nil {
+			c.Error(apierr.Validation("start_time and end_time are required unless is_closed is set"))
+			return
+		}
+
+		conflicts, ok := overrideConflicts(c, employee, date, &override)
+		if !ok {
+			return
+		}
+		if len(conflicts) > 0 && c.Query("force") != "true" {
+			c.Error(apierr.Conflict("Appointments fall outside the new hours").
+				WithDetails(gin.H{"conflicting_appointments": conflicts}))
+			return
+		}
+
+		err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			if err := database.UpsertDayOverride(ctx, &override); err != nil {
+				return err
+			}
+			return audit.Record(ctx, audit.EntityDayOverrides, override.ID, audit.ActionUpdate, override)
+		})
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if !override.IsClosed {
+			waitlist.FillDay(c.Request.Context(), sender, employee, date)
+		}
+		c.JSON(http.StatusOK, gin.H{"override": override, "conflicting_appointments": conflicts})
+	}
+}
+
+// DeleteDayOverride reverts a date to the weekly template, with the same conflict check
+// and waiting list offers as PutDayOverride
+func DeleteDayOverride(sender notifications.Sender) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		employee, date, ok := overrideTarget(c)
+		if !ok {
+			return
+		}
+
+		conflicts, ok := overrideConflicts(c, employee, date, nil)
+		if !ok {
+			return
+		}
+		if len(conflicts) > 0 && c.Query("force") != "true" {
+			c.Error(apierr.Conflict("Appointments fall outside the template hours").
+				WithDetails(gin.H{"conflicting_appointments": conflicts}))
+			return
+		}
+
+		var overrideID int
+		err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+			var err error
+			if overrideID, err = database.DeleteDayOverride(ctx, employee.ID, date.Format(timeutil.DateLayout)); err != nil {
+				return err
+			}
+			return audit.Record(ctx, audit.EntityDayOverrides, overrideID, audit.ActionDelete, nil)
+		})
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Day override not found"))
+			return
+		}
+		waitlist.FillDay(c.Request.Context(), sender, employee, date)
+		c.JSON(http.StatusOK, gin.H{"message": "Day override deleted successfully", "conflicting_appointments": conflicts})
+	}
+}
+
+// overrideTarget parses the employee id and date path parameters
+func overrideTarget(c *gin.Context) (*models.Employee, time.Time, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return nil, time.Time{}, false
+	}
+	date, err := timeutil.ParseDate(c.Param("date"))
+	if err != nil {
+		c.Error(apierr.Validation("date must be given as YYYY-MM-DD"))
+		return nil, time.Time{}, false
+	}
+	employee, err := database.GetEmployee(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Employee not found"))
+		return nil, time.Time{}, false
+	}
+	return employee, date, true
+}
+
+// overrideConflicts returns the active appointments starting on the date that would not fit the
+// working hours if override (nil for the weekly template) applied
+func overrideConflicts(c *gin.Context, employee *models.Employee, date time.Time, override *models.DayOverride) ([]models.Appointment, bool) {
+	windows, err := availability.WindowsWithOverride(c.Request.Context(), employee, date, override)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+
+	dayStart, dayEnd := timeutil.DayBounds(date, availability.Location(employee))
+	bookings, err := database.GetEmployeeBookings(c.Request.Context(), employee.ID, dayStart, dayEnd)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	// Bookings carried over from the previous night belong to that day's hours
+	var today []models.Appointment
+	for _, b := range bookings {
+		if !b.StartDatetime.Before(dayStart) {
+			today = append(today, b)
+		}
+	}
+
+	conflicts := availability.Outside(today, windows)
+	if conflicts == nil {
+		conflicts = []models.Appointment{}
+	}
+	// Every caller returns the conflicts, medical notes included
+	access.MedicalNotes(c, conflicts...)
+	return conflicts, true
+}
@@ -0,0 +1,165 @@
+// Medical Appointment Booking System - Scheduling Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package scheduling
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the employee scheduling endpoints and the availability search
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.GET("/availability/search", auth.Authorize(auth.Scheduling), SearchAvailability)
+	group := r.Group("/employees/:id", auth.Authorize(auth.Scheduling))
+	{
+		group.GET("/gaps", GetScheduleGaps)
+		group.GET("/availability", GetAvailability)
+		group.GET("/overrides", GetDayOverrides)
+		group.PUT("/overrides/:date", PutDayOverride(deps.Sender))
+		group.DELETE("/overrides/:date", DeleteDayOverride(deps.Sender))
+	}
+}
+
+// GetAvailability lists the free slots an employee has on a date for a service
+func GetAvailability(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	date, err := timeutil.ParseDate(c.Query("date"))
+	if err != nil {
+		c.Error(apierr.Validation("date must be given as YYYY-MM-DD"))
+		return
+	}
+	serviceID, err := strconv.Atoi(c.Query("service_id"))
+	if err != nil {
+		c.Error(apierr.Validation("service_id is required"))
+		return
+	}
+	var appointmentType *string
+	if t := c.Query("appointment_type"); t != "" {
+		if !slices.Contains(models.AppointmentTypes, t) {
+			c.Error(apierr.Validation("Invalid appointment_type"))
+			return
+		}
+		appointmentType = &t
+	}
+
+	employee, err := database.GetEmployee(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Employee not found"))
+		return
+	}
+	service, err := database.GetService(c.Request.Context(), serviceID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Service not found"))
+		return
+	}
+
+	offers, err := database.EmployeeOffersService(c.Request.Context(), employee.ID, service.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !offers {
+		c.Error(apierr.Unprocessable("Employee does not offer " + service.Name))
+		return
+	}
+
+	slots, err := availability.FreeSlots(c.Request.Context(), employee, service, date, appointmentType, time.Now())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"employee_id":      employee.ID,
+		"service_id":       service.ID,
+		"date":             date.Format(timeutil.DateLayout),
+		"timezone":         availability.Location(employee).String(),
+		"duration_minutes": service.DurationMinutes,
+		"slots":            slots,
+	})
+}
+
+// GetScheduleGaps lists free gaps in an employee's day that are too short for a
+// standard service, with suggestions for filling them
+func GetScheduleGaps(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	date, err := timeutil.ParseDate(c.Query("date"))
+	if err != nil {
+		c.Error(apierr.Validation("date must be given as YYYY-MM-DD"))
+		return
+	}
+
+	employee, err := database.GetEmployee(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Employee not found"))
+		return
+	}
+
+	windows, err := availability.WorkingWindows(c.Request.Context(), employee, date)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	durations, err := database.GetOfferedServiceDurations(c.Request.Context(), employee.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	standard := availability.StandardDuration(durations)
+
+	gaps := []availability.Gap{}
+	if len(windows) > 0 {
+		bookings, err := database.GetEmployeeBookings(c.Request.Context(), employee.ID, windows[0].Start, windows[len(windows)-1].End)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		candidates, err := database.GetWaitingListCandidates(c.Request.Context(), employee.ID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		gaps = availability.FindGaps(windows, bookings, standard, candidates)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"employee_id":      employee.ID,
+		"date":             date.Format(timeutil.DateLayout),
+		"standard_minutes": int(standard.Minutes()),
+		"gaps":             gaps,
+	})
+}
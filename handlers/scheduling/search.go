@@ -0,0 +1,106 @@
+// Medical Appointment Booking System - Scheduling Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package scheduling
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits on the number of slots SearchAvailability returns
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// SearchAvailability answers "when's the soonest appointment?": the earliest free slots for a
+// service across every employee who offers it, optionally only those at clinic_id, starting
+// no earlier than from (RFC 3339, default now). limit defaults to 10 and may be at most 50;
+// appointment_type applies the follow-up reserve as for a single employee's availability.
+func SearchAvailability(c *gin.Context) {
+	serviceID, err := strconv.Atoi(c.Query("service_id"))
+	if err != nil {
+		c.Error(apierr.Validation("service_id is required"))
+		return
+	}
+	clinicID, ok := handlers.OptionalIntQuery(c, "clinic_id")
+	if !ok {
+		return
+	}
+	from, ok := handlers.OptionalTimeQuery(c, "from")
+	if !ok {
+		return
+	}
+	limit := defaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxSearchLimit {
+			c.Error(apierr.Validation("limit must be between 1 and " + strconv.Itoa(maxSearchLimit)))
+			return
+		}
+	}
+	var appointmentType *string
+	if t := c.Query("appointment_type"); t != "" {
+		if !slices.Contains(models.AppointmentTypes, t) {
+			c.Error(apierr.Validation("Invalid appointment_type"))
+			return
+		}
+		appointmentType = &t
+	}
+
+	service, err := database.GetService(c.Request.Context(), serviceID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Service not found"))
+		return
+	}
+	employees, err := database.GetBookableEmployees(c.Request.Context(), service.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if clinicID != nil {
+		employees = slices.DeleteFunc(employees, func(e models.Employee) bool { return e.ClinicID != *clinicID })
+	}
+
+	now := time.Now()
+	start := now
+	if from != nil && from.After(now) {
+		start = *from
+	}
+	openings, err := availability.Search(c.Request.Context(), employees, service, start, limit, appointmentType, now)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"service_id":       service.ID,
+		"clinic_id":        clinicID,
+		"from":             start,
+		"duration_minutes": service.DurationMinutes,
+		"slots":            openings,
+	})
+}
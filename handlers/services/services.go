@@ -0,0 +1,287 @@
+// Medical Appointment Booking System - Service Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package services
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler serves the service endpoints
+type Handler struct {
+	services database.ServiceRepo
+}
+
+// New returns a Handler that stores services in the given repository
+func New(services database.ServiceRepo) *Handler {
+	return &Handler{services: services}
+}
+
+// RegisterRoutes mounts the service endpoints under /services
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	h := New(deps.Repos.Services)
+	group := r.Group("/services", auth.Authorize(auth.Services))
+	{
+		group.GET("", h.GetServices)
+		group.GET("/:id", h.GetService)
+		group.POST("", h.CreateService)
+		group.PUT("/:id", h.UpdateService)
+		group.DELETE("/:id", h.DeleteService)
+		group.GET("/:id/employees", h.GetServiceEmployees)
+		group.GET("/:id/resources", h.GetServiceResources)
+		group.POST("/:id/resources", h.AssignResource)
+		group.DELETE("/:id/resources/:resource_id", h.UnassignResource)
+	}
+}
+
+func (h *Handler) GetServices(c *gin.Context) {
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	services, total, err := h.services.List(c.Request.Context(), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, services, total, page)
+}
+
+func (h *Handler) GetService(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	service, err := h.services.Get(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Service not found"))
+		return
+	}
+	c.JSON(http.StatusOK, service)
+}
+
+func (h *Handler) CreateService(c *gin.Context) {
+	var service models.Service
+	if !handlers.BindJSON(c, &service) || !validAgeRange(c, &service) {
+		return
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.services.Create(ctx, &service); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityServices, service.ID, audit.ActionCreate, service)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, service)
+}
+
+func (h *Handler) UpdateService(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var service models.Service
+	if !handlers.BindJSON(c, &service) || !validAgeRange(c, &service) {
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.services.Update(ctx, id, &service); err != nil {
+			return err
+		}
+		updated, err := h.services.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityServices, id, audit.ActionUpdate, updated)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Service updated successfully"})
+}
+
+func (h *Handler) DeleteService(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := h.services.Delete(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityServices, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Service deleted successfully"})
+}
+
+// GetServiceEmployees lists the employees assigned to a service
+func (h *Handler) GetServiceEmployees(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	employees, err := h.services.Employees(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, employees)
+}
+
+// GetServiceResources lists the rooms and equipment a service needs. A booking takes one
+// free resource of each kind listed, at the appointment's clinic.
+func (h *Handler) GetServiceResources(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	if _, err := h.services.Get(c.Request.Context(), id); err != nil {
+		c.Error(apierr.Lookup(err, "Service not found"))
+		return
+	}
+	resources, err := h.services.Resources(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, resources)
+}
+
+func (h *Handler) AssignResource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	var req struct {
+		ResourceID int `json:"resource_id" binding:"required"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	if _, err := h.services.Get(c.Request.Context(), id); err != nil {
+		c.Error(apierr.Lookup(err, "Service not found"))
+		return
+	}
+	if _, err := database.GetResource(c.Request.Context(), req.ResourceID); err != nil {
+		c.Error(apierr.Validation("Resource not found"))
+		return
+	}
+
+	var added bool
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if added, err = h.services.AssignResource(ctx, id, req.ResourceID); err != nil || !added {
+			return err
+		}
+		return h.recordResources(ctx, id)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !added {
+		c.JSON(http.StatusOK, gin.H{"message": "Resource already assigned"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Resource assigned successfully"})
+}
+
+func (h *Handler) UnassignResource(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	resourceID, err := strconv.Atoi(c.Param("resource_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid resource ID"))
+		return
+	}
+
+	var removed bool
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if removed, err = h.services.UnassignResource(ctx, id, resourceID); err != nil || !removed {
+			return err
+		}
+		return h.recordResources(ctx, id)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !removed {
+		c.Error(apierr.NotFound("Resource is not assigned to this service"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Resource unassigned successfully"})
+}
+
+// recordResources audits the resources a service needs after a change to them
+func (h *Handler) recordResources(ctx context.Context, serviceID int) error {
+	resources, err := h.services.Resources(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+	resourceIDs := make([]int, 0, len(resources))
+	for _, r := range resources {
+		resourceIDs = append(resourceIDs, r.ID)
+	}
+	return audit.Record(ctx, audit.EntityServiceResources, serviceID, audit.ActionUpdate, gin.H{"resource_ids": resourceIDs})
+}
+
+// validAgeRange checks a service's age limits do not exclude everyone, writing a 400 when they do
+func validAgeRange(c *gin.Context, service *models.Service) bool {
+	if service.MinAgeYears != nil && service.MaxAgeYears != nil && *service.MaxAgeYears < *service.MinAgeYears {
+		c.Error(apierr.Validation("Request has invalid fields").
+			WithDetails(gin.H{"fields": map[string]string{"max_age_years": "must be at least min_age_years"}}))
+		return false
+	}
+	return true
+}
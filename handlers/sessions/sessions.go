@@ -0,0 +1,136 @@
+// Medical Appointment Booking System - Session Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the login and token refresh endpoints under /auth. They are the only
+// staff endpoints reachable without an access token.
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/auth")
+	{
+		group.POST("/login", Login)
+		group.POST("/refresh", Refresh)
+	}
+}
+
+// tokenResponse is returned by both login and refresh
+type tokenResponse struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// dummyHash is compared against when the email is unknown, so a failed login takes as long
+// whether or not the account exists
+var dummyHash = sync.OnceValue(func() string {
+	hash, _ := auth.HashPassword("not a real password")
+	return hash
+})
+
+func Login(c *gin.Context) {
+	var req struct {
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	user, err := database.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		auth.CheckPassword(dummyHash(), req.Password)
+		c.Error(apierr.Unauthorized("Invalid email or password"))
+		return
+	}
+	if !auth.CheckPassword(user.PasswordHash, req.Password) || !user.Active {
+		c.Error(apierr.Unauthorized("Invalid email or password"))
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	now := time.Now()
+	refreshExpiresAt := now.Add(auth.RefreshTokenTTL)
+	if err := database.StoreRefreshToken(c.Request.Context(), user.ID, refreshHash, refreshExpiresAt); err != nil {
+		c.Error(err)
+		return
+	}
+	respondWithTokens(c, user, refreshToken, refreshExpiresAt, now)
+}
+
+// Refresh exchanges a refresh token for a new access token. The refresh token is single use:
+// it is revoked and a replacement is returned alongside the access token.
+func Refresh(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	now := time.Now()
+	refreshExpiresAt := now.Add(auth.RefreshTokenTTL)
+	user, err := database.RotateRefreshToken(c.Request.Context(), auth.HashRefreshToken(req.RefreshToken), refreshHash, refreshExpiresAt)
+	if errors.Is(err, database.ErrRefreshTokenInvalid) {
+		c.Error(apierr.Unauthorized("Invalid or expired refresh token"))
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	respondWithTokens(c, user, refreshToken, refreshExpiresAt, now)
+}
+
+func respondWithTokens(c *gin.Context, user *models.User, refreshToken string, refreshExpiresAt, now time.Time) {
+	accessToken, expiresAt, err := auth.IssueAccessToken(user, now)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	})
+}
@@ -0,0 +1,189 @@
+// Medical Appointment Booking System - Slot Hold Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package slotholds
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultHoldTTL is how long a hold lasts when SLOT_HOLD_TTL is not set
+const DefaultHoldTTL = 10 * time.Minute
+
+// RegisterRoutes mounts the slot hold endpoints under /slot-holds
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/slot-holds", auth.Authorize(auth.SlotHolds))
+	{
+		group.POST("", CreateSlotHold)
+		group.DELETE("/:token", ReleaseSlotHold)
+	}
+}
+
+// HoldTTL reads the hold lifetime from the SLOT_HOLD_TTL environment variable (e.g. "5m")
+func HoldTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SLOT_HOLD_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultHoldTTL
+}
+
+// CreateSlotHold reserves a slot for a service while the patient finishes booking. The hold
+// ends after the service's duration and expires after HoldTTL unless converted by
+// POST /api/appointments with its hold_token.
+func CreateSlotHold(c *gin.Context) {
+	var req struct {
+		EmployeeID    int       `json:"employee_id" binding:"required"`
+		ServiceID     int       `json:"service_id" binding:"required"`
+		StartDatetime time.Time `json:"start_datetime" binding:"required"`
+		PatientID     *int      `json:"patient_id"`
+	}
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+
+	employee, err := database.GetEmployee(c.Request.Context(), req.EmployeeID)
+	if err != nil || employee.DeletedAt != nil {
+		c.Error(apierr.Validation("Employee not found"))
+		return
+	}
+	service, err := database.GetService(c.Request.Context(), req.ServiceID)
+	if err != nil {
+		c.Error(apierr.Validation("Service not found"))
+		return
+	}
+
+	offers, err := database.EmployeeOffersService(c.Request.Context(), employee.ID, service.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !offers {
+		c.Error(apierr.Unprocessable("Employee does not offer " + service.Name))
+		return
+	}
+
+	start := req.StartDatetime
+	end := start.Add(time.Duration(service.DurationMinutes) * time.Minute)
+	loc := availability.Location(employee)
+	if err := availability.CheckSpan(service, start, end, loc); err != nil {
+		c.Error(apierr.Unprocessable(err.Error()))
+		return
+	}
+	clinic, err := database.GetClinic(c.Request.Context(), employee.ClinicID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := availability.CheckBookingWindow(service, clinic, start, time.Now(), loc); err != nil {
+		c.Error(apierr.Unprocessable(err.Error()))
+		return
+	}
+	working, err := availability.WithinWorkingHours(c.Request.Context(), employee, start, end)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !working {
+		c.Error(apierr.Unprocessable("Slot is outside the employee's working hours"))
+		return
+	}
+	if err := availability.CheckResources(c.Request.Context(), service, employee.ClinicID, start, end, 0); err != nil {
+		var unavailable *database.ResourceUnavailableError
+		if errors.As(err, &unavailable) {
+			c.Error(apierr.Conflict(err.Error()).WithDetails(gin.H{"resource_kind": unavailable.Kind}))
+			return
+		}
+		c.Error(err)
+		return
+	}
+
+	token, err := newHoldToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	hold := models.SlotHold{
+		EmployeeID:    employee.ID,
+		ServiceID:     service.ID,
+		StartDatetime: start,
+		EndDatetime:   end,
+		PatientID:     req.PatientID,
+		HoldToken:     token,
+		ExpiresAt:     time.Now().Add(HoldTTL()),
+	}
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateSlotHold(ctx, &hold); err != nil {
+			return err
+		}
+		// The token lets its bearer book the slot, so it is kept out of the audit log
+		snapshot := hold
+		snapshot.HoldToken = ""
+		return audit.Record(ctx, audit.EntitySlotHolds, hold.ID, audit.ActionCreate, snapshot)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrSlotTaken) {
+			c.Error(apierr.Conflict(err.Error()))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, hold)
+}
+
+func ReleaseSlotHold(c *gin.Context) {
+	var id int
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if id, err = database.ReleaseSlotHold(ctx, c.Param("token")); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntitySlotHolds, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		if errors.Is(err, database.ErrHoldNotFound) {
+			c.Error(apierr.NotFound("Slot hold not found"))
+			return
+		}
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Slot hold released"})
+}
+
+func newHoldToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
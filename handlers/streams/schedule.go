@@ -0,0 +1,127 @@
+// Medical Appointment Booking System - Stream Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package streams pushes live updates to long-lived connections, so dashboards and
+// displays do not need to poll
+package streams
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"bookings/apierr"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/live"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// HeartbeatInterval is how often an idle stream is sent a heartbeat, which keeps proxies
+// from closing it and finds clients that have gone away
+const HeartbeatInterval = 30 * time.Second
+
+// writeTimeout bounds sending one message to a client
+const writeTimeout = 10 * time.Second
+
+// RegisterRoutes mounts the live schedule WebSocket under /ws and the management of the
+// clinics' waiting room display URLs
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/ws", auth.Authorize(auth.Appointments))
+	{
+		group.GET("/schedule", Schedule(deps.Live))
+	}
+	clinics := r.Group("/clinics", auth.Authorize(auth.Clinics))
+	{
+		clinics.POST("/:id/waiting-room-display", CreateDisplay)
+		clinics.DELETE("/:id/waiting-room-display", DeleteDisplay)
+	}
+}
+
+// Schedule upgrades to a WebSocket that receives a JSON message for every appointment
+// created, updated, cancelled or deleted at clinic_id or with employee_id (at least one
+// is required). A "resync" message, or the socket closing, means changes may have been
+// missed and the schedule should be loaded again.
+func Schedule(hub *live.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clinicID, ok := handlers.OptionalIntQuery(c, "clinic_id")
+		if !ok {
+			return
+		}
+		employeeID, ok := handlers.OptionalIntQuery(c, "employee_id")
+		if !ok {
+			return
+		}
+		if clinicID == nil && employeeID == nil {
+			c.Error(apierr.Validation("clinic_id or employee_id is required"))
+			return
+		}
+		if hub == nil {
+			c.Error(apierr.Unavailable("Live updates are not available"))
+			return
+		}
+
+		changes, unsubscribe := hub.Subscribe(live.Filter{OrganizationID: database.OrganizationFromContext(c.Request.Context()),
+			ClinicID: clinicID, EmployeeID: employeeID})
+		server := websocket.Server{
+			// Access tokens, not cookies, authenticate the socket, so any origin may open one
+			Handshake: func(*websocket.Config, *http.Request) error { return nil },
+			Handler: func(ws *websocket.Conn) {
+				defer unsubscribe()
+				pushSchedule(ws, changes)
+			},
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// pushSchedule writes changes to the socket until the subscription ends or the client goes
+// away. Anything the client sends is ignored.
+func pushSchedule(ws *websocket.Conn, changes <-chan live.Change) {
+	defer ws.Close()
+	// The request's own context ends with REQUEST_TIMEOUT, which does not apply to the socket
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		io.Copy(io.Discard, ws)
+		cancel()
+	}()
+
+	heartbeat := time.NewTicker(HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		var message any
+		select {
+		case <-ctx.Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			message = change
+		case <-heartbeat.C:
+			message = gin.H{"type": "heartbeat"}
+		}
+		ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := websocket.JSON.Send(ws, message); err != nil {
+			return
+		}
+	}
+}
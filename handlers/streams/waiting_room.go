@@ -0,0 +1,202 @@
+// Medical Appointment Booking System - Stream Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package streams
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/live"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BoardRefreshInterval is how often a waiting room board is sent again with nothing changed,
+// so a display that missed an update catches up
+const BoardRefreshInterval = time.Minute
+
+// boardDebounce gathers a burst of changes, such as a series being cancelled, into one update
+const boardDebounce = time.Second
+
+// boardQueryTimeout bounds building one board, since a stream has no request deadline
+const boardQueryTimeout = 10 * time.Second
+
+// RegisterPublicRoutes mounts the waiting room stream, addressed by a secret display token
+// rather than an access token so a TV can open it without logging in
+func RegisterPublicRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	r.GET("/waiting-room/:token", WaitingRoom(deps.Live))
+}
+
+// CreateDisplay issues a secret URL for the clinic's waiting room display. Issuing a new one
+// revokes the previous URL; the token is only shown in this response.
+func CreateDisplay(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	if clinic, err := database.GetClinic(c.Request.Context(), id); err != nil || clinic.DeletedAt != nil {
+		c.Error(apierr.NotFound("Clinic not found"))
+		return
+	}
+
+	token, hash, err := live.NewDisplayToken()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	var displayID int
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if displayID, err = database.SetWaitingRoomDisplay(ctx, id, hash); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityWaitingRoomDisplays, displayID, audit.ActionCreate, gin.H{"clinic_id": id})
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"display_url": displayURL(c, token)})
+}
+
+// DeleteDisplay revokes the clinic's waiting room display URL; open displays are cut off at
+// their next update
+func DeleteDisplay(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	var displayID int
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if displayID, err = database.DeleteWaitingRoomDisplay(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityWaitingRoomDisplays, displayID, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Waiting room display not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Waiting room display revoked successfully"})
+}
+
+// WaitingRoom streams the clinic's waiting room board as Server-Sent Events: a "board"
+// event with the whole board on connecting, after every change to the clinic's
+// appointments and every BoardRefreshInterval, and a comment as a heartbeat in between.
+func WaitingRoom(hub *live.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := live.HashDisplayToken(c.Param("token"))
+		clinicID, err := database.GetWaitingRoomDisplayClinic(c.Request.Context(), hash)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Waiting room display not found"))
+			return
+		}
+		if hub == nil {
+			c.Error(apierr.Unavailable("Live updates are not available"))
+			return
+		}
+		ctx := handlers.StreamContext(c)
+		changes, unsubscribe := hub.Subscribe(live.Filter{OrganizationID: database.OrganizationFromContext(ctx), ClinicID: &clinicID})
+		defer unsubscribe()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		// EventSource reconnects on its own; ask it to wait a few seconds first
+		fmt.Fprint(c.Writer, "retry: 5000\n\n")
+
+		// sendBoard writes the current board, reporting false once the display is revoked
+		// or the client is gone
+		sendBoard := func() bool {
+			boardCtx, cancel := context.WithTimeout(ctx, boardQueryTimeout)
+			defer cancel()
+			if current, err := database.GetWaitingRoomDisplayClinic(boardCtx, hash); err != nil || current != clinicID {
+				return false
+			}
+			board, err := live.BuildBoard(boardCtx, clinicID, time.Now())
+			if err != nil {
+				return false
+			}
+			data, err := json.Marshal(board)
+			if err != nil {
+				return false
+			}
+			if _, err := fmt.Fprintf(c.Writer, "event: board\ndata: %s\n\n", data); err != nil {
+				return false
+			}
+			c.Writer.Flush()
+			return true
+		}
+		if !sendBoard() {
+			return
+		}
+
+		refresh := time.NewTicker(BoardRefreshInterval)
+		defer refresh.Stop()
+		heartbeat := time.NewTicker(HeartbeatInterval)
+		defer heartbeat.Stop()
+		var pending <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-changes:
+				if !ok {
+					return
+				}
+				if pending == nil {
+					pending = time.After(boardDebounce)
+				}
+			case <-pending:
+				pending = nil
+				if !sendBoard() {
+					return
+				}
+			case <-refresh.C:
+				if !sendBoard() {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// displayURL builds the absolute display URL from the request's host
+func displayURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/api/v1/waiting-room/" + token
+}
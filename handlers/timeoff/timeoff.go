@@ -0,0 +1,191 @@
+// Medical Appointment Booking System - Time Off Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package timeoff
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// RegisterRoutes mounts the time off endpoints under /time-off
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/time-off", auth.Authorize(auth.TimeOff))
+	{
+		group.GET("", GetTimeOffRequests)
+		group.GET("/:id", GetTimeOffRequest)
+		group.POST("", RequestTimeOff)
+	}
+
+	// Decisions are an admin action on top of the group's matrix entry
+	decisions := group.Group("", auth.RequireRole(auth.RoleAdmin))
+	{
+		decisions.POST("/:id/approve", ApproveTimeOff)
+		decisions.POST("/:id/reject", RejectTimeOff)
+	}
+}
+
+// timeOffDetail is a request together with the active appointments inside its window
+type timeOffDetail struct {
+	models.TimeOff
+	AffectedAppointments []models.Appointment `json:"affected_appointments"`
+}
+
+func GetTimeOffRequests(c *gin.Context) {
+	var employeeID *int
+	if raw := c.Query("employee_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.Error(apierr.Validation("Invalid employee_id"))
+			return
+		}
+		employeeID = &id
+	}
+	var status *string
+	if raw := c.Query("status"); raw != "" {
+		if !slices.Contains(models.TimeOffStatuses, raw) {
+			c.Error(apierr.Validation("Invalid status"))
+			return
+		}
+		status = &raw
+	}
+
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	requests, total, err := database.ListTimeOff(c.Request.Context(), employeeID, status, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, requests, total, page)
+}
+
+func GetTimeOffRequest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	request, err := database.GetTimeOff(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Time off request not found"))
+		return
+	}
+	respondWithAffected(c, http.StatusOK, request)
+}
+
+// RequestTimeOff files a PENDING request; the response lists the bookings it would affect
+func RequestTimeOff(c *gin.Context) {
+	var request models.TimeOff
+	if !handlers.BindJSON(c, &request) {
+		return
+	}
+	if _, err := database.GetEmployee(c.Request.Context(), request.EmployeeID); err != nil {
+		c.Error(apierr.Validation("Employee not found"))
+		return
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateTimeOff(ctx, &request); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityTimeOff, request.ID, audit.ActionCreate, request)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	respondWithAffected(c, http.StatusCreated, &request)
+}
+
+// ApproveTimeOff approves a pending request. The time is removed from availability straight
+// away; appointments already booked inside it are returned so they can be moved.
+func ApproveTimeOff(c *gin.Context) {
+	decide(c, "APPROVED")
+}
+
+func RejectTimeOff(c *gin.Context) {
+	decide(c, "REJECTED")
+}
+
+func decide(c *gin.Context, status string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+	// The body is optional and only carries a note for the employee
+	var req struct {
+		Note *string `json:"note"`
+	}
+	if c.Request.ContentLength > 0 {
+		if !handlers.BindJSON(c, &req) {
+			return
+		}
+	}
+
+	var request *models.TimeOff
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if request, err = database.DecideTimeOff(ctx, id, status, req.Note); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityTimeOff, request.ID, audit.ActionUpdate, request)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			c.Error(apierr.NotFound("Time off request not found"))
+		case errors.Is(err, database.ErrTimeOffNotPending):
+			c.Error(apierr.Conflict(err.Error()))
+		default:
+			c.Error(err)
+		}
+		return
+	}
+	respondWithAffected(c, http.StatusOK, request)
+}
+
+func respondWithAffected(c *gin.Context, status int, request *models.TimeOff) {
+	affected, err := database.GetEmployeeBookings(c.Request.Context(), request.EmployeeID, request.StartDatetime, request.EndDatetime)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if affected == nil {
+		affected = []models.Appointment{}
+	}
+	access.MedicalNotes(c, affected...)
+	c.JSON(status, timeOffDetail{TimeOff: *request, AffectedAppointments: affected})
+}
@@ -0,0 +1,71 @@
+// Medical Appointment Booking System - Handlers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"bookings/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultRequestTimeout bounds a request when REQUEST_TIMEOUT is not set
+const DefaultRequestTimeout = 30 * time.Second
+
+// RequestTimeout reads the per-request limit from REQUEST_TIMEOUT (e.g. "10s")
+func RequestTimeout() (time.Duration, error) {
+	raw := os.Getenv("REQUEST_TIMEOUT")
+	if raw == "" {
+		return DefaultRequestTimeout, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid REQUEST_TIMEOUT %q", raw)
+	}
+	return d, nil
+}
+
+// streamContextKey is where Timeout keeps the request's context from before the deadline
+const streamContextKey = "handlers.streamContext"
+
+// Timeout gives each request's context a deadline, so database calls made with
+// c.Request.Context() are cancelled once the request has run for d, and are cancelled
+// straight away when the client disconnects
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(streamContextKey, c.Request.Context())
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// StreamContext returns the request's context without the REQUEST_TIMEOUT deadline, for
+// responses that stream until the client disconnects. Each database call made while
+// streaming should get a deadline of its own. It works for the same organization as the
+// request.
+func StreamContext(c *gin.Context) context.Context {
+	if value, ok := c.Get(streamContextKey); ok {
+		return database.WithOrganization(value.(context.Context), database.OrganizationFromContext(c.Request.Context()))
+	}
+	return c.Request.Context()
+}
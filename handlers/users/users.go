@@ -0,0 +1,215 @@
+// Medical Appointment Booking System - User Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package users
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// RegisterRoutes mounts the user management endpoints under /users
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/users", auth.Authorize(auth.Users))
+	{
+		group.GET("", GetUsers)
+		group.GET("/:id", GetUser)
+		group.POST("", CreateUser)
+		group.PUT("/:id", UpdateUser)
+	}
+}
+
+// userRequest is the body of create and update. Password may be left out on update to keep
+// the current one. PATIENT users must be linked to their patient record with patient_id.
+type userRequest struct {
+	Email     string `json:"email" binding:"required,email"`
+	Password  string `json:"password"`
+	Role      string `json:"role" binding:"required,enum=user_role"`
+	PatientID *int   `json:"patient_id"`
+	Active    *bool  `json:"active"`
+}
+
+func GetUsers(c *gin.Context) {
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	users, total, err := database.GetUsers(c.Request.Context(), page, auth.OrganizationID(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, users, total, page)
+}
+
+func GetUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	user, ok := organizationUser(c, id)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// organizationUser loads a user of the caller's organization, writing a 404 when there is
+// none; users of other organizations are answered as missing
+func organizationUser(c *gin.Context, id int) (*models.User, bool) {
+	user, err := database.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "User not found"))
+		return nil, false
+	}
+	if user.OrganizationID != auth.OrganizationID(c) {
+		c.Error(apierr.NotFound("User not found"))
+		return nil, false
+	}
+	return user, true
+}
+
+func CreateUser(c *gin.Context) {
+	var req userRequest
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+	if !validRole(c, &req) {
+		return
+	}
+	if req.Password == "" {
+		c.Error(apierr.Validation("password is required"))
+		return
+	}
+	if !emailAvailable(c, req.Email, 0) {
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	user := models.User{Email: req.Email, PasswordHash: hash, Role: req.Role, PatientID: req.PatientID, Active: req.Active == nil || *req.Active,
+		OrganizationID: auth.OrganizationID(c)}
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateUser(ctx, &user); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityUsers, user.ID, audit.ActionCreate, user)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// UpdateUser changes a user's email, role, active flag and optionally password. Admins cannot
+// demote or deactivate themselves, so there is always someone left who can manage users.
+func UpdateUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var req userRequest
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+	if !validRole(c, &req) {
+		return
+	}
+
+	user, ok := organizationUser(c, id)
+	if !ok {
+		return
+	}
+	if !emailAvailable(c, req.Email, id) {
+		return
+	}
+
+	user.Email, user.Role, user.PatientID = req.Email, req.Role, req.PatientID
+	if req.Active != nil {
+		user.Active = *req.Active
+	}
+	if claims, ok := auth.CurrentUser(c); ok && claims.UserID() == id && (user.Role != auth.RoleAdmin || !user.Active) {
+		c.Error(apierr.Unprocessable("You cannot remove your own admin access"))
+		return
+	}
+	if req.Password != "" {
+		if user.PasswordHash, err = auth.HashPassword(req.Password); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateUser(ctx, id, user); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityUsers, id, audit.ActionUpdate, user)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+}
+
+// validRole checks that patient_id is given exactly when the role is PATIENT
+func validRole(c *gin.Context, req *userRequest) bool {
+	if (req.Role == auth.RolePatient) != (req.PatientID != nil) {
+		c.Error(apierr.Validation("patient_id is required for PATIENT users and not allowed for other roles"))
+		return false
+	}
+	if req.PatientID != nil {
+		if _, err := database.GetPatient(c.Request.Context(), *req.PatientID); err != nil {
+			c.Error(apierr.Validation("Patient not found"))
+			return false
+		}
+	}
+	return true
+}
+
+// emailAvailable writes a 409 and returns false when another user already has the email
+func emailAvailable(c *gin.Context, email string, userID int) bool {
+	existing, err := database.GetUserByEmail(c.Request.Context(), email)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && existing.ID == userID) {
+		return true
+	}
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	c.Error(apierr.Conflict("Email already in use"))
+	return false
+}
@@ -0,0 +1,292 @@
+// Medical Appointment Booking System - Handlers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"bookings/apierr"
+	"bookings/database"
+	"bookings/models"
+	"bookings/phone"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// enums lists the values accepted by the `enum=<name>` binding tag
+var enums = map[string][]string{
+	"appointment_status":   models.AppointmentStatuses,
+	"appointment_type":     models.AppointmentTypes,
+	"payment_status":       models.PaymentStatuses,
+	"urgency_level":        models.UrgencyLevels,
+	"waiting_list_status":  models.WaitingListStatuses,
+	"booking_channel":      models.BookingChannels,
+	"time_off_status":      models.TimeOffStatuses,
+	"user_role":            models.UserRoles,
+	"resource_kind":        models.ResourceKinds,
+	"webhook_event":        models.WebhookEvents,
+	"notification_channel": models.NotificationChannels,
+	"code_system":          models.CodeSystems,
+	"allergy_criticality":  models.AllergyCriticalities,
+	"allergy_status":       models.AllergyStatuses,
+	"medication_status":    models.MedicationStatuses,
+	"condition_status":     models.ConditionStatuses,
+	"consent_kind":         models.ConsentKinds,
+	"relationship":         models.RelationshipTypes,
+}
+
+// slugPattern is what the `slug` binding tag accepts: lower-case words of letters and digits
+// joined by single hyphens, at most 63 characters
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+var registerOnce sync.Once
+
+// registerValidators adds the enum, slug, origin, pastdate, phone and mrn_prefix tags to Gin's validator and makes it name
+// fields by their JSON keys, so errors point at the field the client sent
+func registerValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	_ = v.RegisterValidation("enum", func(fl validator.FieldLevel) bool {
+		return slices.Contains(enums[fl.Param()], fl.Field().String())
+	})
+	_ = v.RegisterValidation("slug", func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		return len(s) <= 63 && slugPattern.MatchString(s)
+	})
+	// origin accepts a web origin as browsers send it: http or https, a host and an optional
+	// port, with no path, query or trailing slash
+	_ = v.RegisterValidation("origin", func(fl validator.FieldLevel) bool {
+		u, err := url.Parse(fl.Field().String())
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+			u.User == nil && u.Path == "" && u.RawQuery == "" && u.Fragment == "" && !u.ForceQuery
+	})
+	// pastdate accepts a YYYY-MM-DD date that is not in the future, such as a date of birth
+	_ = v.RegisterValidation("pastdate", func(fl validator.FieldLevel) bool {
+		date, err := time.Parse(time.DateOnly, fl.Field().String())
+		return err == nil && !date.After(time.Now())
+	})
+	// phone accepts a number that can be written in E.164, international or in the default
+	// region; the database layer stores it normalized
+	_ = v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return phone.Valid(fl.Field().String())
+	})
+	_ = v.RegisterValidation("mrn_prefix", func(fl validator.FieldLevel) bool {
+		return database.ValidMRNPrefix(fl.Field().String())
+	})
+}
+
+// BindJSON decodes the request body into obj and checks its binding tags, writing a 400 that
+// lists each invalid field when either fails
+func BindJSON(c *gin.Context, obj any) bool {
+	registerOnce.Do(registerValidators)
+	if err := c.ShouldBindJSON(obj); err != nil {
+		c.Error(bindError(err))
+		return false
+	}
+	return true
+}
+
+// DecodeJSON decodes the request body into obj without checking its binding tags, for payloads
+// that are completed before Validate runs
+func DecodeJSON(c *gin.Context, obj any) bool {
+	if c.Request.Body == nil {
+		c.Error(apierr.Validation("Request body is required"))
+		return false
+	}
+	if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil {
+		c.Error(bindError(err))
+		return false
+	}
+	return true
+}
+
+// MergeJSON applies the request body as a JSON merge patch (RFC 7396) to current, the stored
+// record, leaving the result in obj: fields the body leaves out keep their stored values and
+// null clears a nullable field. obj starts from a copy of current, so the two never
+// share values. The result's binding tags are checked as with BindJSON.
+func MergeJSON(c *gin.Context, current, obj any) bool {
+	stored, err := json.Marshal(current)
+	if err == nil {
+		err = json.Unmarshal(stored, obj)
+	}
+	if err != nil {
+		c.Error(err)
+		return false
+	}
+	return DecodeJSON(c, obj) && Validate(c, obj)
+}
+
+// Validate checks the binding tags of a payload that was completed after decoding, such as a
+// booking filled in from a slot hold, writing the same 400 as BindJSON
+func Validate(c *gin.Context, obj any) bool {
+	registerOnce.Do(registerValidators)
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		c.Error(bindError(err))
+		return false
+	}
+	return true
+}
+
+// FieldErrors checks the binding tags of a payload that did not come from a request body,
+// such as a row of an uploaded file, returning a message per invalid field or nil when it is valid
+func FieldErrors(obj any) map[string]string {
+	registerOnce.Do(registerValidators)
+	var invalid validator.ValidationErrors
+	if err := binding.Validator.ValidateStruct(obj); !errors.As(err, &invalid) {
+		return nil
+	}
+	fields := make(map[string]string, len(invalid))
+	for _, fe := range invalid {
+		fields[fe.Field()] = fieldMessage(fe)
+	}
+	return fields
+}
+
+// bindError turns a decoding or validation failure into a 400, with a message per field in
+// details.fields where the field is known
+func bindError(err error) *apierr.Error {
+	var invalid validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &invalid):
+		fields := make(map[string]string, len(invalid))
+		for _, fe := range invalid {
+			fields[fe.Field()] = fieldMessage(fe)
+		}
+		return apierr.Validation("Request has invalid fields").WithDetails(gin.H{"fields": fields})
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return apierr.Validation("Request has invalid fields").
+			WithDetails(gin.H{"fields": map[string]string{typeErr.Field: "must be " + jsonKind(typeErr.Type)}})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return apierr.Validation("Request body is not valid JSON")
+	case errors.Is(err, io.EOF):
+		return apierr.Validation("Request body is required")
+	}
+	return apierr.Validation(err.Error())
+}
+
+// fieldMessage describes a failed binding tag in words
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "phone":
+		return "must be a phone number with its country code, e.g. +1 415 555 2671"
+	case "enum":
+		return "must be one of " + strings.Join(enums[fe.Param()], ", ")
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lte":
+		return "must be at most " + fe.Param()
+	case "gtfield":
+		return "must be after " + snakeCase(fe.Param())
+	case "timezone":
+		return "must be an IANA time zone such as Europe/London"
+	case "iso4217":
+		return "must be an ISO 4217 currency code such as EUR"
+	case "slug":
+		return "must be lower-case letters and digits joined by hyphens, e.g. city-clinic"
+	case "origin":
+		return "must be a site origin such as https://clinic.example, without a path"
+	case "url":
+		return "must be a full URL"
+	case "latitude":
+		return "must be a latitude between -90 and 90"
+	case "longitude":
+		return "must be a longitude between -180 and 180"
+	case "hexcolor":
+		return "must be a hex colour such as #0a7cff"
+	case "required_with":
+		return "is required with " + snakeCase(fe.Param())
+	case "max":
+		return "must be at most " + fe.Param() + " characters"
+	case "pastdate":
+		return "must not be in the future"
+	case "mrn_prefix":
+		return "must be up to 10 upper-case letters and digits starting with a letter, e.g. CITY"
+	case "datetime":
+		switch fe.Param() {
+		case "2006-01-02":
+			return "must be a date as YYYY-MM-DD"
+		case "15:04":
+			return "must be a time as HH:MM"
+		}
+		return "must match the layout " + fe.Param()
+	}
+	return "failed the " + fe.Tag() + " check"
+}
+
+// jsonKind names the JSON value a Go type is decoded from
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
+
+// snakeCase turns a Go field name such as StartDatetime into its JSON key, start_datetime
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
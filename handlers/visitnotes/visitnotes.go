@@ -0,0 +1,259 @@
+// Medical Appointment Booking System - Time Off Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package visitnotes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/access"
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// RegisterRoutes mounts the visit note endpoints under /appointments/:id/visit-note
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/appointments/:id/visit-note", auth.Authorize(auth.VisitNotes))
+	{
+		group.GET("", GetVisitNote)
+		group.PUT("", SaveVisitNote)
+		group.POST("/sign", SignVisitNote)
+		group.GET("/amendments", GetAmendments)
+		group.POST("/amendments", AmendVisitNote)
+	}
+}
+
+// amendmentRequest is the body of an amendment: the sections to replace and why
+type amendmentRequest struct {
+	models.SOAPSections
+	Reason string `json:"reason" binding:"required,max=2000"`
+}
+
+// GetVisitNote returns an appointment's visit note, recording the read in the access log
+func GetVisitNote(c *gin.Context) {
+	appointment, ok := appointmentParam(c)
+	if !ok {
+		return
+	}
+	note, err := database.GetVisitNote(c.Request.Context(), appointment.ID)
+	if err != nil {
+		c.Error(noteError(err))
+		return
+	}
+	access.VisitNote(c, appointment)
+	c.JSON(http.StatusOK, note)
+}
+
+// SaveVisitNote writes the caller's draft note of a completed appointment: created the first
+// time, its sections replaced after that. Only the draft's author may change it, and only
+// until it is signed.
+func SaveVisitNote(c *gin.Context) {
+	appointment, ok := appointmentParam(c)
+	if !ok {
+		return
+	}
+	var sections models.SOAPSections
+	if !handlers.BindJSON(c, &sections) {
+		return
+	}
+	if appointment.Status != "COMPLETED" {
+		c.Error(apierr.Conflict("Visit notes are recorded against completed appointments"))
+		return
+	}
+	authorID, ok := author(c)
+	if !ok {
+		return
+	}
+
+	_, err := database.GetVisitNote(c.Request.Context(), appointment.ID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		c.Error(err)
+		return
+	}
+	created := err != nil
+	note := models.VisitNote{AppointmentID: appointment.ID, SOAPSections: sections, AuthorID: authorID}
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.SaveVisitNoteDraft(ctx, &note); err != nil {
+			return err
+		}
+		action := audit.ActionUpdate
+		if created {
+			action = audit.ActionCreate
+		}
+		return audit.Record(ctx, audit.EntityVisitNotes, note.ID, action, note)
+	})
+	if err != nil {
+		c.Error(noteError(err))
+		return
+	}
+	if created {
+		c.JSON(http.StatusCreated, note)
+		return
+	}
+	c.JSON(http.StatusOK, note)
+}
+
+// SignVisitNote signs the caller's draft, locking it; later changes are amendments
+func SignVisitNote(c *gin.Context) {
+	appointment, ok := appointmentParam(c)
+	if !ok {
+		return
+	}
+	authorID, ok := author(c)
+	if !ok {
+		return
+	}
+	draft, err := database.GetVisitNote(c.Request.Context(), appointment.ID)
+	if err != nil {
+		c.Error(noteError(err))
+		return
+	}
+	if empty(draft.SOAPSections) {
+		c.Error(apierr.Validation("An empty visit note cannot be signed"))
+		return
+	}
+
+	var note *models.VisitNote
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if note, err = database.SignVisitNote(ctx, appointment.ID, authorID, time.Now()); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityVisitNotes, note.ID, audit.ActionUpdate, note)
+	})
+	if err != nil {
+		c.Error(noteError(err))
+		return
+	}
+	c.JSON(http.StatusOK, note)
+}
+
+// GetAmendments lists the amendments of an appointment's note, oldest first, each with the
+// sections it replaced
+func GetAmendments(c *gin.Context) {
+	appointment, ok := appointmentParam(c)
+	if !ok {
+		return
+	}
+	note, err := database.GetVisitNote(c.Request.Context(), appointment.ID)
+	if err != nil {
+		c.Error(noteError(err))
+		return
+	}
+	amendments, err := database.GetVisitNoteAmendments(c.Request.Context(), note.ID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	access.VisitNote(c, appointment)
+	c.JSON(http.StatusOK, amendments)
+}
+
+// AmendVisitNote replaces the given sections of a signed note. Any clinician may amend; the
+// amendment keeps the sections as they were, the reason and the amending clinician.
+func AmendVisitNote(c *gin.Context) {
+	appointment, ok := appointmentParam(c)
+	if !ok {
+		return
+	}
+	var req amendmentRequest
+	if !handlers.BindJSON(c, &req) {
+		return
+	}
+	if empty(req.SOAPSections) {
+		c.Error(apierr.Validation("Give at least one section to amend"))
+		return
+	}
+	authorID, ok := author(c)
+	if !ok {
+		return
+	}
+
+	amendment := models.VisitNoteAmendment{Reason: req.Reason, AuthorID: authorID}
+	var note *models.VisitNote
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		if note, err = database.AmendVisitNote(ctx, appointment.ID, req.SOAPSections, &amendment); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityVisitNotes, note.ID, audit.ActionUpdate, note)
+	})
+	if err != nil {
+		c.Error(noteError(err))
+		return
+	}
+	c.JSON(http.StatusCreated, amendment)
+}
+
+// appointmentParam loads the appointment in the path, writing a 400 or 404 when there is none
+func appointmentParam(c *gin.Context) (*models.Appointment, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return nil, false
+	}
+	appointment, err := database.GetAppointment(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Appointment not found"))
+		return nil, false
+	}
+	return appointment, true
+}
+
+// author is the clinician making the request. The route group is closed to API keys, so
+// there always is one.
+func author(c *gin.Context) (*int, bool) {
+	id, ok := auth.UserIDFromContext(c.Request.Context())
+	if !ok {
+		c.Error(apierr.Forbidden("Visit notes are written by clinicians"))
+		return nil, false
+	}
+	return &id, true
+}
+
+// empty reports whether no section has any text
+func empty(sections models.SOAPSections) bool {
+	for _, section := range []*string{sections.Subjective, sections.Objective, sections.Assessment, sections.Plan} {
+		if section != nil && *section != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// noteError maps the visit note errors to responses
+func noteError(err error) error {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return apierr.NotFound("Visit note not found")
+	case errors.Is(err, database.ErrVisitNoteSigned), errors.Is(err, database.ErrVisitNoteNotSigned):
+		return apierr.Conflict(err.Error())
+	case errors.Is(err, database.ErrVisitNoteNotAuthor):
+		return apierr.Forbidden(err.Error())
+	}
+	return err
+}
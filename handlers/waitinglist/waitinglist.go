@@ -0,0 +1,212 @@
+// Medical Appointment Booking System - Waiting List Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package waitinglist
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+	"bookings/notifications"
+	"bookings/waitlist"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the waiting list endpoints under /waiting-list
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/waiting-list", auth.Authorize(auth.WaitingList))
+	{
+		group.GET("", GetWaitingList)
+		group.GET("/sla", GetSLAStats)
+		group.GET("/:id", GetWaitingListItem)
+		group.POST("", CreateWaitingListItem)
+		group.PUT("/:id", UpdateWaitingListItem)
+		group.DELETE("/:id", DeleteWaitingListItem)
+		group.POST("/:id/offer", OfferSlot(deps.Sender))
+	}
+}
+
+func GetWaitingList(c *gin.Context) {
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+	waitingList, total, err := database.GetWaitingList(c.Request.Context(), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, waitingList, total, page)
+}
+
+// slaReport is the URGENT waiting list SLA statistics with the SLA they were measured against
+type slaReport struct {
+	SLAMinutes int `json:"sla_minutes"`
+	database.WaitingListSLAStats
+	// BreachRate is Breached as a share of Urgent, 0 when there were none
+	BreachRate float64 `json:"breach_rate"`
+}
+
+// GetSLAStats reports how URGENT entries added between the optional from and to (RFC 3339)
+// fared against the WAITING_LIST_URGENT_SLA
+func GetSLAStats(c *gin.Context) {
+	from, ok := handlers.OptionalTimeQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := handlers.OptionalTimeQuery(c, "to")
+	if !ok {
+		return
+	}
+	if from != nil && to != nil && !to.After(*from) {
+		c.Error(apierr.Validation("to must be after from"))
+		return
+	}
+
+	sla := waitlist.UrgentSLA()
+	stats, err := database.GetWaitingListSLAStats(c.Request.Context(), from, to, time.Now().Add(-sla))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	report := slaReport{SLAMinutes: int(sla / time.Minute), WaitingListSLAStats: *stats}
+	if stats.Urgent > 0 {
+		report.BreachRate = float64(stats.Breached) / float64(stats.Urgent)
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func GetWaitingListItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	item, err := database.GetWaitingListItem(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Waiting list item not found"))
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+func CreateWaitingListItem(c *gin.Context) {
+	var item models.WaitingList
+	if !handlers.BindJSON(c, &item) {
+		return
+	}
+
+	err := database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateWaitingListItem(ctx, &item); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityWaitingList, item.ID, audit.ActionCreate, item)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+func UpdateWaitingListItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	var item models.WaitingList
+	if !handlers.BindJSON(c, &item) {
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateWaitingListItem(ctx, id, &item); err != nil {
+			return err
+		}
+		updated, err := database.GetWaitingListItem(ctx, id)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityWaitingList, id, audit.ActionUpdate, updated)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Waiting list item updated successfully"})
+}
+
+func DeleteWaitingListItem(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.DeleteWaitingListItem(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityWaitingList, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Waiting list item deleted successfully"})
+}
+
+// OfferSlot holds the earliest free slot matching an entry for the patient, marks the entry
+// CONTACTED and notifies them; the same as happens automatically when a slot opens up
+func OfferSlot(sender notifications.Sender) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.Error(apierr.Validation("Invalid ID"))
+			return
+		}
+
+		item, err := database.GetWaitingListItem(c.Request.Context(), id)
+		if err != nil {
+			c.Error(apierr.Lookup(err, "Waiting list item not found"))
+			return
+		}
+
+		offer, err := waitlist.OfferEntry(c.Request.Context(), sender, item)
+		if errors.Is(err, waitlist.ErrNotOfferable) || errors.Is(err, waitlist.ErrNoSlot) {
+			c.Error(apierr.Unprocessable(err.Error()))
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusCreated, offer)
+	}
+}
@@ -0,0 +1,287 @@
+// Medical Appointment Booking System - Webhook Handlers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"bookings/apierr"
+	"bookings/audit"
+	"bookings/auth"
+	"bookings/database"
+	"bookings/handlers"
+	"bookings/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts the webhook subscription endpoints and their delivery logs under /webhooks
+func RegisterRoutes(r *gin.RouterGroup, deps handlers.Deps) {
+	group := r.Group("/webhooks", auth.Authorize(auth.Webhooks))
+	{
+		group.GET("", GetSubscriptions)
+		group.GET("/:id", GetSubscription)
+		group.POST("", CreateSubscription)
+		group.PUT("/:id", UpdateSubscription)
+		group.DELETE("/:id", DeleteSubscription)
+		group.GET("/:id/deliveries", GetDeliveries)
+		group.GET("/:id/deliveries/:delivery_id", GetDelivery)
+		group.POST("/:id/deliveries/:delivery_id/retry", RetryDelivery)
+	}
+}
+
+// subscriptionRequest is the body of a subscription create or update
+type subscriptionRequest struct {
+	URL         string   `json:"url" binding:"required,url,max=2000"`
+	Events      []string `json:"events" binding:"required,min=1,dive,enum=webhook_event"`
+	Description *string  `json:"description" binding:"omitempty,max=200"`
+	// Active defaults to true on create and to the current value on update
+	Active *bool `json:"active"`
+}
+
+// bind reads a subscriptionRequest into s, writing a 400 and returning false when it is invalid
+func (req *subscriptionRequest) bind(c *gin.Context, s *models.WebhookSubscription) bool {
+	if !handlers.BindJSON(c, req) {
+		return false
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		c.Error(apierr.Validation("url must be an http or https URL"))
+		return false
+	}
+	s.URL = req.URL
+	slices.Sort(req.Events)
+	s.Events = slices.Compact(req.Events)
+	s.Description = req.Description
+	if req.Active != nil {
+		s.Active = *req.Active
+	}
+	return true
+}
+
+func GetSubscriptions(c *gin.Context) {
+	subscriptions, err := database.GetWebhookSubscriptions(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func GetSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	subscription, err := database.GetWebhookSubscription(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Webhook subscription not found"))
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// CreateSubscription adds a subscription with a new signing secret. The secret is only ever
+// returned here; a subscriber that loses it deletes the subscription and creates another.
+func CreateSubscription(c *gin.Context) {
+	subscription := models.WebhookSubscription{Active: true}
+	var req subscriptionRequest
+	if !req.bind(c, &subscription) {
+		return
+	}
+	secret, err := newSecret()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	subscription.Secret = secret
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.CreateWebhookSubscription(ctx, &subscription); err != nil {
+			return err
+		}
+		snapshot := subscription
+		snapshot.Secret = ""
+		return audit.Record(ctx, audit.EntityWebhooks, subscription.ID, audit.ActionCreate, snapshot)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// UpdateSubscription replaces a subscription's URL, events and description; its secret and
+// delivery log are kept. Deactivating it stops new events being queued for it and holds back
+// the deliveries already queued until it is active again.
+func UpdateSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	subscription, err := database.GetWebhookSubscription(c.Request.Context(), id)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Webhook subscription not found"))
+		return
+	}
+	var req subscriptionRequest
+	if !req.bind(c, subscription) {
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.UpdateWebhookSubscription(ctx, id, subscription); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityWebhooks, id, audit.ActionUpdate, subscription)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Webhook subscription not found"))
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+// DeleteSubscription removes a subscription along with its queued deliveries and delivery log
+func DeleteSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return
+	}
+
+	err = database.WithTx(c.Request.Context(), func(ctx context.Context) error {
+		if err := database.DeleteWebhookSubscription(ctx, id); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityWebhooks, id, audit.ActionDelete, nil)
+	})
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Webhook subscription not found"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook subscription deleted successfully"})
+}
+
+// GetDeliveries lists a subscription's deliveries, newest first, optionally only those with
+// the given status (PENDING, DELIVERED or FAILED)
+func GetDeliveries(c *gin.Context) {
+	id, ok := subscriptionID(c)
+	if !ok {
+		return
+	}
+	var status *string
+	if raw := c.Query("status"); raw != "" {
+		if !slices.Contains(models.WebhookStatuses, raw) {
+			c.Error(apierr.Validation("Invalid status"))
+			return
+		}
+		status = &raw
+	}
+	page, ok := handlers.ParsePage(c)
+	if !ok {
+		return
+	}
+
+	deliveries, total, err := database.GetWebhookDeliveries(c.Request.Context(), id, status, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	handlers.RespondPage(c, deliveries, total, page)
+}
+
+// GetDelivery returns a delivery with the log of every attempt to send it
+func GetDelivery(c *gin.Context) {
+	id, ok := subscriptionID(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(c.Param("delivery_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid delivery ID"))
+		return
+	}
+
+	delivery, err := database.GetWebhookDelivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		c.Error(apierr.Lookup(err, "Webhook delivery not found"))
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// RetryDelivery queues a FAILED delivery again, with a full set of attempts
+func RetryDelivery(c *gin.Context) {
+	id, ok := subscriptionID(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(c.Param("delivery_id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid delivery ID"))
+		return
+	}
+
+	retried, err := database.RetryWebhookDelivery(c.Request.Context(), id, deliveryID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if !retried {
+		if _, err := database.GetWebhookDelivery(c.Request.Context(), id, deliveryID); err != nil {
+			c.Error(apierr.Lookup(err, "Webhook delivery not found"))
+			return
+		}
+		c.Error(apierr.Unprocessable("Only failed deliveries can be retried"))
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Webhook delivery queued"})
+}
+
+// subscriptionID parses the :id parameter and checks the subscription exists, writing the
+// error and returning false when it does not
+func subscriptionID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(apierr.Validation("Invalid ID"))
+		return 0, false
+	}
+	if _, err := database.GetWebhookSubscription(c.Request.Context(), id); err != nil {
+		c.Error(apierr.Lookup(err, "Webhook subscription not found"))
+		return 0, false
+	}
+	return id, true
+}
+
+// newSecret returns a random signing secret for a new subscription
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,91 @@
+// Medical Appointment Booking System - Health Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package health serves the liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkTimeout bounds each dependency check, so a hung dependency cannot stall the probe
+const checkTimeout = 3 * time.Second
+
+// Check probes one dependency the server needs to serve requests
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// result is one dependency's entry in the readiness report
+type result struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Live reports that the process is up and serving HTTP; it checks no dependencies, so a
+// database outage does not get the server restarted
+func Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// Ready runs every check concurrently and reports each dependency as up or down with how
+// long it took to answer. It responds 503 when any is down, so load balancers stop routing
+// to this instance until they recover. Failure details go to the log, not the response.
+func Ready(checks []Check) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		results := make(map[string]result, len(checks))
+		ready := true
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, check := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+				defer cancel()
+				start := time.Now()
+				err := check.Run(checkCtx)
+				r := result{Status: "up", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+				if err != nil {
+					r.Status = "down"
+					slog.WarnContext(ctx, "readiness check failed", "dependency", check.Name, "error", err)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				results[check.Name] = r
+				if err != nil {
+					ready = false
+				}
+			}()
+		}
+		wg.Wait()
+
+		if !ready {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not_ready", "checks": results})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": results})
+	}
+}
@@ -0,0 +1,221 @@
+// Medical Appointment Booking System - HL7 Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package hl7
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"bookings/database"
+)
+
+// MLLP frames each message between a start block and an end block followed by a carriage return
+const (
+	startBlock = '\x0b'
+	endBlock   = '\x1c'
+)
+
+// ContentType is what messages are posted as over HTTP
+const ContentType = "x-application/hl7-v2+er7; charset=utf-8"
+
+// maxErrorLength bounds the error kept for a failed attempt
+const maxErrorLength = 500
+
+// MaxAttempts is how many times a message is tried before it is marked FAILED
+const MaxAttempts = 8
+
+// Timeout is how long the receiver has to acknowledge a message
+const Timeout = 10 * time.Second
+
+// RetryDelay is how long to wait after the given failed attempt (1 for the first) before the
+// next: a minute, doubling each time
+func RetryDelay(attempt int) time.Duration {
+	return time.Minute << (attempt - 1)
+}
+
+// Rejection is a receiver's refusal of a message as invalid (an AE or CE acknowledgement, or
+// a 4xx answer over HTTP). Sending the same message again would fail the same way, so it is
+// not retried.
+type Rejection struct {
+	Reason string
+}
+
+func (r *Rejection) Error() string {
+	return "rejected: " + r.Reason
+}
+
+// Dispatcher sends due messages to the configured receiver
+type Dispatcher struct {
+	Config *Config
+	Client *http.Client
+}
+
+// NewDispatcher returns a Dispatcher for config whose HTTP requests time out after Timeout
+// and do not follow redirects
+func NewDispatcher(config *Config) *Dispatcher {
+	return &Dispatcher{Config: config, Client: &http.Client{
+		Timeout: Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Dispatch claims up to limit due messages and sends each once, in order, recording the
+// attempt and scheduling a retry or giving up as needed. It returns how many were sent.
+func (d *Dispatcher) Dispatch(ctx context.Context, now time.Time, limit int) (int, error) {
+	// The lease covers every claimed message timing out in turn
+	due, err := database.ClaimHL7Messages(ctx, now, now.Add(time.Duration(limit+1)*Timeout), limit)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for i := range due {
+		attemptedAt := time.Now()
+		var errMessage *string
+		var retryAt *time.Time
+		if err := d.Send(ctx, due[i].Message); err != nil {
+			message := err.Error()
+			if len(message) > maxErrorLength {
+				message = message[:maxErrorLength]
+			}
+			errMessage = &message
+			var rejection *Rejection
+			if !errors.As(err, &rejection) && due[i].Attempts+1 < MaxAttempts {
+				next := attemptedAt.Add(RetryDelay(due[i].Attempts + 1))
+				retryAt = &next
+			}
+		}
+		if err := database.RecordHL7Attempt(ctx, due[i].ID, attemptedAt, errMessage, retryAt); err != nil {
+			return sent, err
+		}
+		if errMessage == nil {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// Send delivers one message and checks its acknowledgement
+func (d *Dispatcher) Send(ctx context.Context, message string) error {
+	if d.Config.URL.Scheme == "mllp" || d.Config.URL.Scheme == "mllps" {
+		return d.sendMLLP(ctx, message)
+	}
+	return d.sendHTTP(ctx, message)
+}
+
+// sendMLLP writes the framed message on a new connection and reads back the framed
+// acknowledgement
+func (d *Dispatcher) sendMLLP(ctx context.Context, message string) error {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", d.Config.URL.Host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if d.Config.URL.Scheme == "mllps" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.Config.URL.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		conn = tlsConn
+	}
+
+	frame := make([]byte, 0, len(message)+3)
+	frame = append(frame, startBlock)
+	frame = append(frame, message...)
+	frame = append(frame, endBlock, '\r')
+	if _, err := conn.Write(frame); err != nil {
+		return err
+	}
+	ack, err := bufio.NewReader(io.LimitReader(conn, 64<<10)).ReadString(endBlock)
+	if err != nil {
+		return fmt.Errorf("reading acknowledgement: %w", err)
+	}
+	return checkAck(strings.TrimSuffix(strings.TrimPrefix(ack, string(startBlock)), string(endBlock)))
+}
+
+// sendHTTP posts the message. A 2xx answer is a success unless its body is a negative
+// acknowledgement; other 4xx answers but 408 and 429 are rejections.
+func (d *Dispatcher) sendHTTP(ctx context.Context, message string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Config.URL.String(), bytes.NewReader([]byte(message)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", "bookings-hl7/1")
+	if d.Config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+d.Config.Token)
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		if strings.Contains(string(body), "MSA|") {
+			return checkAck(string(body))
+		}
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return &Rejection{Reason: fmt.Sprintf("receiver answered %d", resp.StatusCode)}
+	default:
+		return fmt.Errorf("receiver answered %d", resp.StatusCode)
+	}
+}
+
+// checkAck reads the acknowledgement code in an ACK's MSA segment. AA and CA accept the
+// message; AE and CE reject it as invalid; AR and CR mean the receiver could not take it
+// now, so it is tried again.
+func checkAck(ack string) error {
+	for _, segment := range strings.FieldsFunc(ack, func(r rune) bool { return r == '\r' || r == '\n' }) {
+		fields := strings.Split(segment, "|")
+		if fields[0] != "MSA" || len(fields) < 2 {
+			continue
+		}
+		var text string
+		if len(fields) > 3 {
+			text = fields[3]
+		}
+		switch fields[1] {
+		case "AA", "CA":
+			return nil
+		case "AE", "CE":
+			return &Rejection{Reason: strings.TrimSpace(fields[1] + " " + text)}
+		default:
+			return fmt.Errorf("receiver answered %s %s", fields[1], text)
+		}
+	}
+	return errors.New("acknowledgement has no MSA segment")
+}
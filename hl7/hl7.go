@@ -0,0 +1,149 @@
+// Medical Appointment Booking System - HL7 Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package hl7 exports scheduling events to legacy hospital information systems as HL7v2 SIU
+// messages: S12 when an appointment is booked, S13 when it is rescheduled and S15 when it is
+// cancelled. Publish queues a message in the database outbox; the dispatcher sends it over
+// MLLP or HTTP and retries it with backoff until the receiver acknowledges it.
+package hl7
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"bookings/database"
+	"bookings/models"
+)
+
+// Trigger events, one per kind of scheduling change
+const (
+	TriggerBooked      = "S12"
+	TriggerRescheduled = "S13"
+	TriggerCancelled   = "S15"
+)
+
+// DefaultApplication is the sending application named in MSH-3 when HL7_SENDING_APPLICATION
+// is not set
+const DefaultApplication = "BOOKINGS"
+
+// Config is where messages go and how their header names both ends
+type Config struct {
+	// URL is mllp://host:port, mllps://host:port (MLLP over TLS) or an http(s) URL
+	URL *url.URL
+	// Token, when set, is sent as a bearer token with HTTP deliveries
+	Token                string
+	SendingApplication   string
+	SendingFacility      string
+	ReceivingApplication string
+	ReceivingFacility    string
+}
+
+// FromEnv reads HL7_EXPORT_URL, HL7_EXPORT_TOKEN, HL7_SENDING_APPLICATION,
+// HL7_SENDING_FACILITY, HL7_RECEIVING_APPLICATION and HL7_RECEIVING_FACILITY. It returns nil
+// when HL7_EXPORT_URL is not set, which leaves the export off.
+func FromEnv() (*Config, error) {
+	raw := os.Getenv("HL7_EXPORT_URL")
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid HL7_EXPORT_URL %q: want mllp://host:port, mllps://host:port or an http(s) URL", raw)
+	}
+	switch u.Scheme {
+	case "mllp", "mllps":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("invalid HL7_EXPORT_URL %q: MLLP needs a port", raw)
+		}
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("invalid HL7_EXPORT_URL %q: want mllp://host:port, mllps://host:port or an http(s) URL", raw)
+	}
+	config := &Config{
+		URL:                  u,
+		Token:                os.Getenv("HL7_EXPORT_TOKEN"),
+		SendingApplication:   os.Getenv("HL7_SENDING_APPLICATION"),
+		SendingFacility:      os.Getenv("HL7_SENDING_FACILITY"),
+		ReceivingApplication: os.Getenv("HL7_RECEIVING_APPLICATION"),
+		ReceivingFacility:    os.Getenv("HL7_RECEIVING_FACILITY"),
+	}
+	if config.SendingApplication == "" {
+		config.SendingApplication = DefaultApplication
+	}
+	return config, nil
+}
+
+// enabled holds the configuration Enable was given; nil while the export is off
+var enabled atomic.Pointer[Config]
+
+// Enable turns the export on, so Publish queues messages. It is only called when an
+// endpoint is configured, so nothing queues messages nobody sends.
+func Enable(config *Config) {
+	enabled.Store(config)
+}
+
+// Publish queues the message for a scheduling change to an appointment, as it stands after
+// the change. Failures are logged rather than returned, so an export problem never fails
+// the change it reports.
+func Publish(ctx context.Context, triggerEvent string, appointment *models.Appointment) {
+	config := enabled.Load()
+	if config == nil {
+		return
+	}
+	records, err := loadRecords(ctx, appointment)
+	if err != nil {
+		slog.ErrorContext(ctx, "hl7: loading appointment records", "appointment_id", appointment.ID, "trigger_event", triggerEvent, "error", err)
+		return
+	}
+	err = database.EnqueueHL7Message(ctx, appointment.ID, triggerEvent, func(controlID string) (string, error) {
+		return SIU(config, triggerEvent, controlID, time.Now(), records), nil
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "hl7: queueing message", "appointment_id", appointment.ID, "trigger_event", triggerEvent, "error", err)
+	}
+}
+
+// Records are what an SIU message describes: the appointment and who and what it is for
+type Records struct {
+	Appointment *models.Appointment
+	Patient     *models.Patient
+	Employee    *models.Employee
+	Service     *models.Service
+	Clinic      *models.Clinic
+}
+
+func loadRecords(ctx context.Context, appointment *models.Appointment) (*Records, error) {
+	records := &Records{Appointment: appointment}
+	var err error
+	if records.Patient, err = database.GetPatient(ctx, appointment.PatientID); err != nil {
+		return nil, err
+	}
+	if records.Employee, err = database.GetEmployee(ctx, appointment.EmployeeID); err != nil {
+		return nil, err
+	}
+	if records.Service, err = database.GetService(ctx, appointment.ServiceID); err != nil {
+		return nil, err
+	}
+	if records.Clinic, err = database.GetClinic(ctx, appointment.ClinicID); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
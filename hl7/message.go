@@ -0,0 +1,122 @@
+// Medical Appointment Booking System - HL7 Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package hl7
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/models"
+)
+
+// Version is the HL7 version messages declare in MSH-12
+const Version = "2.5.1"
+
+// timestampLayout formats HL7 DTM values; every time is sent in UTC
+const timestampLayout = "20060102150405-0700"
+
+// fillerStatuses maps appointment statuses to HL7 table 0278 filler status codes
+var fillerStatuses = map[string]string{
+	"SCHEDULED":   "Booked",
+	"CONFIRMED":   "Booked",
+	"IN_PROGRESS": "Started",
+	"COMPLETED":   "Complete",
+	"CANCELLED":   "Cancelled",
+	"NO_SHOW":     "Noshow",
+}
+
+// escaper escapes the delimiters declared in MSH-2 inside field values, and turns line
+// breaks, which would end the segment, into spaces
+var escaper = strings.NewReplacer(`\`, `\E\`, "|", `\F\`, "^", `\S\`, "~", `\R\`, "&", `\T\`, "\r", " ", "\n", " ")
+
+// SIU builds the SIU message for a scheduling change (trigger S12, S13 or S15) with the
+// given message control id, created at now. Segments are MSH, SCH, PID and a resource group
+// with the service (AIS), the employee (AIP) and the clinic (AIL); notes and medical notes
+// are never included.
+func SIU(config *Config, triggerEvent, controlID string, now time.Time, r *Records) string {
+	a := r.Appointment
+	start := timestamp(a.StartDatetime)
+	duration := strconv.Itoa(int(a.EndDatetime.Sub(a.StartDatetime) / time.Minute))
+	status := fillerStatuses[a.Status]
+	employee := strconv.Itoa(r.Employee.ID) + "^" + escape(r.Employee.LastName) + "^" + escape(r.Employee.FirstName)
+
+	eventReason := map[string]string{
+		TriggerBooked:      "^Booked",
+		TriggerRescheduled: "^Rescheduled",
+		TriggerCancelled:   "^Cancelled",
+	}[triggerEvent]
+	if triggerEvent == TriggerCancelled && a.CancellationReason != nil {
+		eventReason = "^" + escape(*a.CancellationReason)
+	}
+	var appointmentType string
+	if a.AppointmentType != nil {
+		appointmentType = "^" + escape(*a.AppointmentType)
+	}
+
+	segments := [][]string{
+		{"MSH", `^~\&`, escape(config.SendingApplication), escape(config.SendingFacility),
+			escape(config.ReceivingApplication), escape(config.ReceivingFacility), timestamp(now), "",
+			"SIU^" + triggerEvent + "^SIU_S12", controlID, "P", Version},
+		{"SCH", escape(a.PublicID) + "^" + escape(config.SendingApplication), strconv.Itoa(a.ID) + "^" + escape(config.SendingApplication),
+			"", "", "", eventReason, strconv.Itoa(r.Service.ID) + "^" + escape(r.Service.Name), appointmentType,
+			duration, "MIN", "^^" + duration + "^" + start + "^" + timestamp(a.EndDatetime),
+			"", "", "", "", employee, "", "", "", "", "", "", "", "", status},
+		patientSegment(config, r.Patient),
+		{"RGS", "1"},
+		{"AIS", "1", "", strconv.Itoa(r.Service.ID) + "^" + escape(r.Service.Name), start, "", "", duration, "MIN", "", status},
+		{"AIP", "1", "", employee, "^" + escape(r.Employee.Specialty), "", start, "", "", duration, "MIN", "", status},
+		{"AIL", "1", "", "^^^" + escape(r.Clinic.Name), "", "", start, "", "", duration, "MIN", "", status},
+	}
+
+	var b strings.Builder
+	for _, fields := range segments {
+		b.WriteString(strings.Join(fields, "|"))
+		b.WriteByte('\r')
+	}
+	return b.String()
+}
+
+// patientSegment is the PID segment: the medical record number and public id, name, date
+// of birth and contact details
+func patientSegment(config *Config, p *models.Patient) []string {
+	identifiers := escape(p.PublicID) + "^^^" + escape(config.SendingApplication) + "^PI"
+	if p.MedicalRecordNumber != "" {
+		identifiers = escape(p.MedicalRecordNumber) + "^^^" + escape(config.SendingFacility) + "^MR~" + identifiers
+	}
+	var birthDate string
+	if p.DateOfBirth != nil {
+		birthDate = strings.ReplaceAll(*p.DateOfBirth, "-", "")
+	}
+	var telecom []string
+	if p.Phone != "" {
+		telecom = append(telecom, escape(p.Phone)+"^PRN^PH")
+	}
+	if p.Email != "" {
+		telecom = append(telecom, "^NET^Internet^"+escape(p.Email))
+	}
+	return []string{"PID", "1", "", identifiers, "", escape(p.LastName) + "^" + escape(p.FirstName), "", birthDate,
+		"", "", "", "", "", strings.Join(telecom, "~")}
+}
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
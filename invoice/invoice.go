@@ -0,0 +1,179 @@
+// Medical Appointment Booking System - Invoice Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package invoice renders appointment invoices and receipts as PDF.
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"bookings/database"
+	"bookings/models"
+	"bookings/money"
+	"bookings/pdf"
+	"bookings/timeutil"
+)
+
+// Invoice is an appointment's invoice, or its receipt once paid. The payment_amount is the
+// total charged; TaxRate is the percentage of tax included in it.
+type Invoice struct {
+	Appointment *models.Appointment
+	Patient     *models.Patient
+	Employee    *models.Employee
+	Service     *models.Service
+	Clinic      *models.Clinic
+	TaxRate     float64
+}
+
+// Load gathers the records an appointment's invoice shows
+func Load(ctx context.Context, appointment *models.Appointment, taxRate float64) (*Invoice, error) {
+	patient, err := database.GetPatient(ctx, appointment.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	employee, err := database.GetEmployee(ctx, appointment.EmployeeID)
+	if err != nil {
+		return nil, err
+	}
+	service, err := database.GetService(ctx, appointment.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	clinic, err := database.GetClinic(ctx, appointment.ClinicID)
+	if err != nil {
+		return nil, err
+	}
+	return &Invoice{Appointment: appointment, Patient: patient, Employee: employee, Service: service, Clinic: clinic, TaxRate: taxRate}, nil
+}
+
+// Number identifies the invoice; it follows from the appointment, so it is the same every time
+func (inv *Invoice) Number() string {
+	return fmt.Sprintf("INV-%06d", inv.Appointment.ID)
+}
+
+// Filename is the name the PDF is downloaded or attached under
+func (inv *Invoice) Filename() string {
+	return inv.Number() + ".pdf"
+}
+
+// IsReceipt reports whether the appointment has been paid, which makes the document a receipt
+func (inv *Invoice) IsReceipt() bool {
+	return inv.Appointment.PaymentStatus == "PAID" || inv.Appointment.PaymentStatus == "REFUNDED"
+}
+
+// Total is the amount charged, tax included; nothing, in the service's currency, when the
+// appointment has no payment_amount
+func (inv *Invoice) Total() money.Money {
+	if inv.Appointment.PaymentAmount == nil {
+		return money.Money{Currency: inv.Service.Price.Currency}
+	}
+	return *inv.Appointment.PaymentAmount
+}
+
+// Tax is the tax included in Total, rounded to the minor unit
+func (inv *Invoice) Tax() money.Money {
+	return inv.Total().Share(inv.TaxRate)
+}
+
+// paymentStatusLabels describe each payment status on the document
+var paymentStatusLabels = map[string]string{
+	"PENDING":  "Payment due",
+	"PAID":     "Paid",
+	"REFUNDED": "Refunded",
+}
+
+// PDF renders the invoice on one A4 page: the clinic letterhead, who is billed, the service
+// with its date, the amounts and the payment status
+func (inv *Invoice) PDF() []byte {
+	const left, right = 56.0, pdf.PageWidth - 56.0
+	var p pdf.Page
+
+	// Letterhead
+	y := float64(pdf.PageHeight - 72)
+	p.Text(left, y, 18, true, inv.Clinic.Name)
+	for _, line := range []string{inv.Clinic.Address, inv.Clinic.Phone, inv.Clinic.Email} {
+		if line != "" {
+			y -= 14
+			p.Text(left, y, 10, false, line)
+		}
+	}
+	title := "INVOICE"
+	if inv.IsReceipt() {
+		title = "RECEIPT"
+	}
+	p.Text(right-150, pdf.PageHeight-72, 20, true, title)
+	p.Text(right-150, pdf.PageHeight-90, 10, false, "No. "+inv.Number())
+	p.Text(right-150, pdf.PageHeight-104, 10, false, "Date "+inv.Appointment.CreatedAt.UTC().Format(timeutil.DateLayout))
+	y = math.Min(y, pdf.PageHeight-104) - 24
+	p.Rule(left, right, y)
+
+	// Billed to
+	y -= 24
+	p.Text(left, y, 10, true, "Billed to")
+	y -= 14
+	p.Text(left, y, 10, false, inv.Patient.FirstName+" "+inv.Patient.LastName)
+	for _, line := range []string{inv.Patient.Email, inv.Patient.Phone} {
+		if line != "" {
+			y -= 14
+			p.Text(left, y, 10, false, line)
+		}
+	}
+
+	// Line item
+	y -= 36
+	p.Text(left, y, 10, true, "Description")
+	p.Text(left+280, y, 10, true, "Date")
+	p.Text(right-38, y, 10, true, "Amount") // its Helvetica-Bold width, so it ends flush with the amounts
+	y -= 6
+	p.Rule(left, right, y)
+	y -= 16
+	start := inv.Appointment.StartDatetime.In(timeutil.LoadLocation(inv.Employee.Timezone))
+	p.Text(left, y, 10, false, inv.Service.Name)
+	p.Text(left+280, y, 10, false, start.Format("2 Jan 2006 15:04 MST"))
+	p.TextRight(right, y, 10, false, inv.Total().Decimal())
+	y -= 14
+	p.Text(left, y, 9, false, "with "+inv.Employee.FirstName+" "+inv.Employee.LastName)
+	y -= 10
+	p.Rule(left, right, y)
+
+	// Totals
+	total, tax := inv.Total(), inv.Tax()
+	for _, row := range []struct {
+		label string
+		value int64
+		bold  bool
+	}{
+		{"Subtotal", total.Amount - tax.Amount, false},
+		{fmt.Sprintf("Tax (%g%% included)", inv.TaxRate), tax.Amount, false},
+		{"Total " + total.Currency, total.Amount, true},
+	} {
+		y -= 16
+		p.Text(right-200, y, 10, row.bold, row.label)
+		p.TextRight(right, y, 10, row.bold, money.Money{Amount: row.value, Currency: total.Currency}.Decimal())
+	}
+
+	y -= 36
+	status, ok := paymentStatusLabels[inv.Appointment.PaymentStatus]
+	if !ok {
+		status = inv.Appointment.PaymentStatus
+	}
+	p.Text(left, y, 11, true, "Payment status: "+status)
+
+	p.Text(left, 56, 8, false, "Appointment reference "+inv.Appointment.PublicID)
+	return p.Bytes()
+}
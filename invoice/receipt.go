@@ -0,0 +1,66 @@
+// Medical Appointment Booking System - Invoice Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"bookings/database"
+	"bookings/notifications"
+)
+
+// EmailReceipt emails the patient the receipt of a paid appointment as a PDF attachment.
+// Appointments that are not paid, e.g. refunded again already, and patients without an
+// email address are skipped. Failures are logged rather than returned, since the payment
+// has already been recorded.
+func EmailReceipt(ctx context.Context, sender notifications.Sender, appointmentID int, taxRate float64) {
+	appointment, err := database.GetAppointment(ctx, appointmentID)
+	if err != nil {
+		slog.ErrorContext(ctx, "receipt email: loading appointment", "appointment_id", appointmentID, "error", err)
+		return
+	}
+	if appointment.PaymentStatus != "PAID" {
+		return
+	}
+	inv, err := Load(ctx, appointment, taxRate)
+	if err != nil {
+		slog.ErrorContext(ctx, "receipt email: loading invoice", "appointment_id", appointmentID, "error", err)
+		return
+	}
+	if inv.Patient.Email == "" {
+		return
+	}
+
+	msg := notifications.Message{
+		Channel:   notifications.ChannelEmail,
+		Recipient: inv.Patient.Email,
+		Subject:   "Receipt for your appointment",
+		Body: fmt.Sprintf("Thank you for your payment of %s for %s at %s. Your receipt %s is attached.",
+			inv.Total(), inv.Service.Name, inv.Clinic.Name, inv.Number()),
+		Attachments: []notifications.Attachment{
+			{Filename: inv.Filename(), ContentType: "application/pdf", Data: inv.PDF()},
+		},
+		Template:      notifications.TemplateReceipt,
+		AppointmentID: &appointmentID,
+		PatientID:     &inv.Patient.ID,
+	}
+	if err := sender.Send(ctx, msg); err != nil {
+		slog.ErrorContext(ctx, "receipt email: sending", "appointment_id", appointmentID, "error", err)
+	}
+}
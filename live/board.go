@@ -0,0 +1,109 @@
+// Medical Appointment Booking System - Live Updates Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bookings/database"
+)
+
+// BoardEntry is one patient on a waiting room display, shown by initials only
+type BoardEntry struct {
+	Initials      string    `json:"initials"`
+	StartDatetime time.Time `json:"start_datetime"`
+	EmployeeName  string    `json:"employee_name"`
+	// Position is the place in the queue, 1 for next; 0 for patients being seen
+	Position int `json:"position,omitempty"`
+}
+
+// Board is what a clinic's waiting room display shows: who is being seen now and the queue
+// of patients who have checked in, in the order they will be seen
+type Board struct {
+	ClinicID int `json:"clinic_id"`
+	// NowServing are the patients being seen (IN_PROGRESS)
+	NowServing []BoardEntry `json:"now_serving"`
+	// Queue are the checked-in patients waiting, in order of appointment time and then of
+	// arrival
+	Queue []BoardEntry `json:"queue"`
+	// Expected counts the day's SCHEDULED and CONFIRMED appointments not checked in yet
+	Expected int `json:"expected"`
+	// ServedToday counts the day's COMPLETED appointments
+	ServedToday int       `json:"served_today"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// BuildBoard returns the clinic's waiting room board as of now
+func BuildBoard(ctx context.Context, clinicID int, now time.Time) (*Board, error) {
+	appointments, err := database.GetWaitingRoomAppointments(ctx, clinicID, now)
+	if err != nil {
+		return nil, err
+	}
+	board := &Board{ClinicID: clinicID, NowServing: []BoardEntry{}, Queue: []BoardEntry{}, UpdatedAt: now.UTC()}
+	for i := range appointments {
+		a := &appointments[i]
+		entry := BoardEntry{Initials: initials(a.PatientFirstName, a.PatientLastName), StartDatetime: a.StartDatetime.UTC(), EmployeeName: a.EmployeeName}
+		switch a.Status {
+		case "IN_PROGRESS":
+			board.NowServing = append(board.NowServing, entry)
+		case "COMPLETED":
+			board.ServedToday++
+		default:
+			if a.CheckedInAt == nil {
+				board.Expected++
+				continue
+			}
+			entry.Position = len(board.Queue) + 1
+			board.Queue = append(board.Queue, entry)
+		}
+	}
+	return board, nil
+}
+
+// initials shortens a name to "J. D."
+func initials(first, last string) string {
+	var parts []string
+	for _, name := range []string{first, last} {
+		if r, _ := utf8.DecodeRuneInString(strings.TrimSpace(name)); r != utf8.RuneError {
+			parts = append(parts, strings.ToUpper(string(r))+".")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// NewDisplayToken generates the secret part of a waiting room display URL and the hash
+// stored for it
+func NewDisplayToken() (token, hash string, err error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, HashDisplayToken(token), nil
+}
+
+// HashDisplayToken returns the stored form of a display token
+func HashDisplayToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
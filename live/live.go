@@ -0,0 +1,179 @@
+// Medical Appointment Booking System - Live Updates Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package live fans appointment changes out to connected dashboards. Changes arrive from
+// PostgreSQL (LISTEN on database.ScheduleChannel), so every server instance sees every
+// change, and are handed to the subscribers whose filter they match.
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"bookings/database"
+)
+
+// Change types
+const (
+	ChangeCreated   = "appointment.created"
+	ChangeUpdated   = "appointment.updated"
+	ChangeCancelled = "appointment.cancelled"
+	ChangeDeleted   = "appointment.deleted"
+	// ChangeResync tells subscribers changes may have been missed, so they reload the schedule
+	ChangeResync = "resync"
+)
+
+// subscriberBuffer is how many changes a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// reconnectDelay is the wait before listening again after the connection failed
+const reconnectDelay = 5 * time.Second
+
+// Change is one appointment change. It carries no patient details; a dashboard that needs
+// them loads the appointment.
+type Change struct {
+	Type           string    `json:"type"`
+	OrganizationID int       `json:"organization_id,omitempty"`
+	AppointmentID  int       `json:"appointment_id,omitempty"`
+	ClinicID       int       `json:"clinic_id,omitempty"`
+	EmployeeID     int       `json:"employee_id,omitempty"`
+	PatientID      int       `json:"patient_id,omitempty"`
+	Status         string    `json:"status,omitempty"`
+	StartDatetime  time.Time `json:"start_datetime,omitzero"`
+	EndDatetime    time.Time `json:"end_datetime,omitzero"`
+}
+
+// Filter picks the changes a subscriber gets: those of its organization, and of the clinic
+// and employee when set
+type Filter struct {
+	OrganizationID int
+	ClinicID       *int
+	EmployeeID     *int
+}
+
+func (f Filter) matches(c *Change) bool {
+	if c.Type == ChangeResync {
+		return true
+	}
+	return f.OrganizationID == c.OrganizationID &&
+		(f.ClinicID == nil || *f.ClinicID == c.ClinicID) && (f.EmployeeID == nil || *f.EmployeeID == c.EmployeeID)
+}
+
+type subscriber struct {
+	filter  Filter
+	changes chan Change
+}
+
+// Hub hands changes to subscribers
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	stopped     bool
+}
+
+// NewHub returns a hub with no subscribers; Run feeds it
+func NewHub() *Hub {
+	return &Hub{subscribers: map[*subscriber]struct{}{}}
+}
+
+// Subscribe returns a channel receiving the changes matching filter, and a function ending
+// the subscription. The channel is closed when the hub stops, or when the subscriber falls
+// more than a few dozen changes behind, after which it should reload and subscribe again.
+func (h *Hub) Subscribe(filter Filter) (<-chan Change, func()) {
+	s := &subscriber{filter: filter, changes: make(chan Change, subscriberBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		close(s.changes)
+		return s.changes, func() {}
+	}
+	h.subscribers[s] = struct{}{}
+	return s.changes, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.remove(s)
+	}
+}
+
+// Broadcast hands a change to every subscriber it matches, without waiting on any
+func (h *Hub) Broadcast(c Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		if !s.filter.matches(&c) {
+			continue
+		}
+		select {
+		case s.changes <- c:
+		default:
+			h.remove(s)
+		}
+	}
+}
+
+// remove ends a subscription; h.mu must be held
+func (h *Hub) remove(s *subscriber) {
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.changes)
+	}
+}
+
+// Run listens for appointment changes and broadcasts them until ctx is done, then ends
+// every subscription. When the database connection drops it listens again after a short
+// wait and sends ChangeResync, since changes made in between were not seen.
+func (h *Hub) Run(ctx context.Context) {
+	defer h.stop()
+	listened := false
+	ready := func() {
+		if listened {
+			h.Broadcast(Change{Type: ChangeResync})
+		}
+		listened = true
+	}
+	handle := func(payload []byte) {
+		var c Change
+		if err := json.Unmarshal(payload, &c); err != nil {
+			slog.ErrorContext(ctx, "live updates: decoding change", "error", err)
+			return
+		}
+		h.Broadcast(c)
+	}
+	for {
+		err := database.ListenScheduleChanges(ctx, ready, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.WarnContext(ctx, "live updates: listening for schedule changes failed", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectDelay):
+		}
+	}
+}
+
+func (h *Hub) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopped = true
+	for s := range h.subscribers {
+		h.remove(s)
+	}
+}
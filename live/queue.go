@@ -0,0 +1,149 @@
+// Medical Appointment Booking System - Live Updates Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package live
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"bookings/database"
+)
+
+// QueueEntry is one patient in a clinic's queue
+type QueueEntry struct {
+	AppointmentID int        `json:"appointment_id"`
+	PatientID     int        `json:"patient_id"`
+	PatientName   string     `json:"patient_name"`
+	EmployeeID    int        `json:"employee_id"`
+	EmployeeName  string     `json:"employee_name"`
+	StartDatetime time.Time  `json:"start_datetime"`
+	CheckedInAt   *time.Time `json:"checked_in_at"`
+	StartedAt     *time.Time `json:"started_at"`
+	// Position is the place in the queue, 1 for next; 0 for patients being seen
+	Position int `json:"position,omitempty"`
+	// WaitMinutes is how long the patient has waited so far, or waited before being taken in
+	WaitMinutes int `json:"wait_minutes"`
+}
+
+// QueueMetrics summarizes a clinic's day. Waits count from the later of arrival and the
+// appointment's start, so patients who come early are not counted as kept waiting.
+type QueueMetrics struct {
+	// Expected counts SCHEDULED and CONFIRMED appointments not checked in yet
+	Expected   int `json:"expected"`
+	Waiting    int `json:"waiting"`
+	InProgress int `json:"in_progress"`
+	Completed  int `json:"completed"`
+	// AverageWaitMinutes is over the checked-in patients taken in so far, null before the first
+	AverageWaitMinutes *float64 `json:"average_wait_minutes"`
+	// LongestWaitMinutes is the longest wait of the patients still waiting
+	LongestWaitMinutes int `json:"longest_wait_minutes"`
+	// AverageVisitMinutes is from being taken in to check-out over the completed
+	// appointments, null before the first
+	AverageVisitMinutes *float64 `json:"average_visit_minutes"`
+}
+
+// Queue is a clinic's live queue for the current day
+type Queue struct {
+	ClinicID int `json:"clinic_id"`
+	// Waiting are the checked-in patients not taken in yet, in order of appointment time
+	// and then of arrival
+	Waiting []QueueEntry `json:"waiting"`
+	// InProgress are the patients being seen
+	InProgress []QueueEntry `json:"in_progress"`
+	Metrics    QueueMetrics `json:"metrics"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// BuildQueue returns the clinic's queue as of now
+func BuildQueue(ctx context.Context, clinicID int, now time.Time) (*Queue, error) {
+	appointments, err := database.GetWaitingRoomAppointments(ctx, clinicID, now)
+	if err != nil {
+		return nil, err
+	}
+	queue := &Queue{ClinicID: clinicID, Waiting: []QueueEntry{}, InProgress: []QueueEntry{}, UpdatedAt: now.UTC()}
+	var waited, visited []time.Duration
+	for i := range appointments {
+		a := &appointments[i]
+		entry := QueueEntry{
+			AppointmentID: a.ID,
+			PatientID:     a.PatientID,
+			PatientName:   a.PatientFirstName + " " + a.PatientLastName,
+			EmployeeID:    a.EmployeeID,
+			EmployeeName:  a.EmployeeName,
+			StartDatetime: a.StartDatetime.UTC(),
+			CheckedInAt:   a.CheckedInAt,
+			StartedAt:     a.StartedAt,
+		}
+		if a.CheckedInAt != nil && a.StartedAt != nil {
+			waited = append(waited, waitBetween(a, *a.StartedAt))
+		}
+		switch a.Status {
+		case "IN_PROGRESS":
+			if a.CheckedInAt != nil && a.StartedAt != nil {
+				entry.WaitMinutes = minutes(waitBetween(a, *a.StartedAt))
+			}
+			queue.InProgress = append(queue.InProgress, entry)
+		case "COMPLETED":
+			queue.Metrics.Completed++
+			if a.StartedAt != nil && a.CheckedOutAt != nil {
+				visited = append(visited, a.CheckedOutAt.Sub(*a.StartedAt))
+			}
+		default:
+			if a.CheckedInAt == nil {
+				queue.Metrics.Expected++
+				continue
+			}
+			entry.WaitMinutes = minutes(waitBetween(a, now))
+			queue.Metrics.LongestWaitMinutes = max(queue.Metrics.LongestWaitMinutes, entry.WaitMinutes)
+			entry.Position = len(queue.Waiting) + 1
+			queue.Waiting = append(queue.Waiting, entry)
+		}
+	}
+	queue.Metrics.Waiting = len(queue.Waiting)
+	queue.Metrics.InProgress = len(queue.InProgress)
+	queue.Metrics.AverageWaitMinutes = averageMinutes(waited)
+	queue.Metrics.AverageVisitMinutes = averageMinutes(visited)
+	return queue, nil
+}
+
+// waitBetween is how long a checked-in patient waited until end, counted from the later
+// of arrival and the appointment's start
+func waitBetween(a *database.WaitingRoomAppointment, end time.Time) time.Duration {
+	from := *a.CheckedInAt
+	if a.StartDatetime.After(from) {
+		from = a.StartDatetime
+	}
+	return max(end.Sub(from), 0)
+}
+
+func minutes(d time.Duration) int {
+	return int(d / time.Minute)
+}
+
+// averageMinutes is the mean of durations in minutes to one decimal, nil when there are none
+func averageMinutes(durations []time.Duration) *float64 {
+	if len(durations) == 0 {
+		return nil
+	}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	average := math.Round(total.Minutes()/float64(len(durations))*10) / 10
+	return &average
+}
@@ -17,98 +17,309 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"syscall"
+	"time"
 
+	"bookings/apierr"
+	"bookings/apiversion"
+	"bookings/auth"
+	"bookings/captcha"
+	"bookings/config"
 	"bookings/database"
+	"bookings/eventbus"
+	"bookings/geocode"
 	"bookings/handlers"
+	"bookings/handlers/accesslog"
+	"bookings/handlers/apikeys"
+	"bookings/handlers/appointments"
+	"bookings/handlers/auditlog"
+	"bookings/handlers/calendars"
+	"bookings/handlers/cardpayments"
+	"bookings/handlers/clinics"
+	"bookings/handlers/consents"
+	"bookings/handlers/documents"
+	"bookings/handlers/employees"
+	"bookings/handlers/fhir"
+	"bookings/handlers/forms"
+	"bookings/handlers/notificationlog"
+	"bookings/handlers/patients"
+	"bookings/handlers/paymentlinks"
+	"bookings/handlers/portal"
+	"bookings/handlers/prescriptions"
+	"bookings/handlers/public"
+	"bookings/handlers/referrals"
+	"bookings/handlers/reports"
+	"bookings/handlers/resources"
+	"bookings/handlers/retentionlog"
+	"bookings/handlers/scheduling"
+	"bookings/handlers/services"
+	"bookings/handlers/sessions"
+	"bookings/handlers/slotholds"
+	"bookings/handlers/streams"
+	"bookings/handlers/timeoff"
+	"bookings/handlers/users"
+	"bookings/handlers/visitnotes"
+	"bookings/handlers/waitinglist"
+	"bookings/handlers/webhooks"
+	"bookings/health"
+	"bookings/hl7"
+	"bookings/live"
+	"bookings/logging"
+	"bookings/notifications"
+	"bookings/payments"
+	"bookings/phi"
+	"bookings/ratelimit"
+	"bookings/retention"
+	"bookings/selftest"
+	"bookings/storage"
+	"bookings/tenant"
+	"bookings/waitlist"
+	"bookings/workers"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout bounds how long in-flight requests and job runs get to finish on shutdown
+const shutdownTimeout = 15 * time.Second
+
+// publicPath matches the patient-facing /public endpoints of every API version and the
+// unversioned alias
+var publicPath = regexp.MustCompile(`^/api/(v[0-9]+/)?public/`)
+
+// legacyAPIDeprecated is when the unversioned /api paths were superseded by /api/v1
+var legacyAPIDeprecated = time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+
 func main() {
-	// Initialize database connection
-	database.InitDB()
-	defer database.CloseDB()
+	selftestMode := flag.Bool("selftest", false, "run an end-to-end check against the configured database and exit")
+	migrateMode := flag.Bool("migrate", false, "apply pending database migrations and exit")
+	encryptMode := flag.Bool("encrypt-phi", false, "encrypt patient data stored in plaintext or under a retired key and exit")
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path of the YAML config file")
+	flag.Parse()
 
-	// Create database tables if they don't exist
-	if err := database.CreateTables(); err != nil {
-		log.Fatalf("Failed to create tables: %v", err)
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		logging.Fatal("invalid config", "error", err)
+	}
+	if err := logging.Setup(cfg.Log); err != nil {
+		logging.Fatal("invalid logging config", "error", err)
 	}
-	r := gin.Default()
 
-	// Configure CORS
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"*"} // In production, specify your frontend URL
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
-	r.Use(cors.New(config))
-
-	// API Routes
-	api := r.Group("/api")
-	{
-		// Clinic routes
-		clinics := api.Group("/clinics")
-		{
-			clinics.GET("", handlers.GetClinics)
-			clinics.GET("/:id", handlers.GetClinic)
-			clinics.POST("", handlers.CreateClinic)
-			clinics.PUT("/:id", handlers.UpdateClinic)
-			clinics.DELETE("/:id", handlers.DeleteClinic)
+	if !*selftestMode && !*migrateMode && !*encryptMode {
+		if err := auth.CheckConfig(); err != nil {
+			logging.Fatal("invalid auth config", "error", err)
 		}
-
-		// Patient routes
-		patients := api.Group("/patients")
-		{
-			patients.GET("", handlers.GetPatients)
-			patients.GET("/:id", handlers.GetPatient)
-			patients.POST("", handlers.CreatePatient)
-			patients.PUT("/:id", handlers.UpdatePatient)
-			patients.DELETE("/:id", handlers.DeletePatient)
+	}
+	if !*migrateMode {
+		if err := phi.Load(); err != nil {
+			logging.Fatal("invalid PHI encryption config", "error", err)
 		}
+	}
 
-		// Employee routes
-		employees := api.Group("/employees")
-		{
-			employees.GET("", handlers.GetEmployees)
-			employees.GET("/:id", handlers.GetEmployee)
-			employees.POST("", handlers.CreateEmployee)
-			employees.PUT("/:id", handlers.UpdateEmployee)
-			employees.DELETE("/:id", handlers.DeleteEmployee)
-		}
+	// Startup work, the background jobs and the server all stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		// Service routes
-		services := api.Group("/services")
-		{
-			services.GET("", handlers.GetServices)
-			services.GET("/:id", handlers.GetService)
-			services.POST("", handlers.CreateService)
-			services.PUT("/:id", handlers.UpdateService)
-			services.DELETE("/:id", handlers.DeleteService)
-		}
+	// Initialize database connection
+	if err := database.InitDB(cfg.Database); err != nil {
+		logging.Fatal("database connection failed", "error", err)
+	}
+	defer database.CloseDB()
 
-		// Appointment routes
-		appointments := api.Group("/appointments")
-		{
-			appointments.GET("", handlers.GetAppointments)
-			appointments.GET("/:id", handlers.GetAppointment)
-			appointments.POST("", handlers.CreateAppointment)
-			appointments.PUT("/:id", handlers.UpdateAppointment)
-			appointments.DELETE("/:id", handlers.DeleteAppointment)
+	if *selftestMode {
+		if !selftest.Run(ctx) {
+			database.CloseDB()
+			os.Exit(1)
 		}
-
-		// Waiting list routes
-		waitingList := api.Group("/waiting-list")
-		{
-			waitingList.GET("", handlers.GetWaitingList)
-			waitingList.GET("/:id", handlers.GetWaitingListItem)
-			waitingList.POST("", handlers.CreateWaitingListItem)
-			waitingList.PUT("/:id", handlers.UpdateWaitingListItem)
-			waitingList.DELETE("/:id", handlers.DeleteWaitingListItem)
+		return
+	}
+	if *migrateMode {
+		if err := database.Migrate(ctx); err != nil {
+			logging.Fatal("migration failed", "error", err)
+		}
+		return
+	}
+	if *encryptMode {
+		// Row-level security only shows one organization's records at a time
+		ids, err := database.GetOrganizationIDs(ctx)
+		if err != nil {
+			logging.Fatal("failed to list organizations", "error", err)
+		}
+		var totalPatients, totalAppointments int
+		for _, id := range ids {
+			patients, appointments, err := database.EncryptStoredPHI(database.WithOrganization(ctx, id))
+			totalPatients += patients
+			totalAppointments += appointments
+			if err != nil {
+				logging.Fatal("encrypting PHI failed", "organization_id", id, "patients", patients, "appointments", appointments, "error", err)
+			}
+			slog.Info("encrypted PHI", "organization_id", id, "patients", patients, "appointments", appointments)
 		}
+		slog.Info("encrypted PHI in every organization", "organizations", len(ids), "patients", totalPatients, "appointments", totalAppointments)
+		return
+	}
+
+	// The server never changes the schema itself; refuse to start against an outdated one
+	pending, err := database.PendingMigrations(ctx)
+	if err != nil {
+		logging.Fatal("failed to check migrations", "error", err)
+	}
+	if len(pending) > 0 {
+		logging.Fatal("database schema is out of date; run with -migrate first",
+			"pending", len(pending), "next_version", pending[0].Version, "next_name", pending[0].Name)
+	}
+	if err := auth.Bootstrap(ctx); err != nil {
+		logging.Fatal("failed to create bootstrap user", "error", err)
+	}
+
+	sender, err := notifications.NewSender()
+	if err != nil {
+		logging.Fatal("invalid notification config", "error", err)
+	}
+	stripe, err := payments.StripeFromEnv()
+	if err != nil {
+		logging.Fatal("invalid payment config", "error", err)
+	}
+	eventBus, err := eventbus.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid event bus config", "error", err)
+	}
+	hl7Export, err := hl7.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid HL7 export config", "error", err)
+	}
+	captchaVerifier := captcha.FromEnv()
+	if captchaVerifier == nil && cfg.Features.PublicBooking {
+		slog.Warn("public booking is on without a CAPTCHA; set CAPTCHA_SECRET")
+	}
+	geocoder := geocode.FromEnv()
+	documentStore, err := storage.FromEnv()
+	if err != nil {
+		logging.Fatal("invalid document storage config", "error", err)
+	}
+	if err := retention.Check(cfg.Retention.Policies(), documentStore); err != nil {
+		logging.Fatal("invalid config", "error", err)
+	}
+	if _, err := database.MRNPrefix(); err != nil {
+		logging.Fatal("invalid config", "error", err)
+	}
+
+	// Background jobs; they stop with the server
+	unpaidInterval, err := workers.UnpaidSweepInterval()
+	if err != nil {
+		logging.Fatal("invalid config", "error", err)
+	}
+	reminderInterval, err := workers.ReminderSweepInterval()
+	if err != nil {
+		logging.Fatal("invalid config", "error", err)
 	}
+	noShowGrace, err := workers.NoShowGrace()
+	if err != nil {
+		logging.Fatal("invalid config", "error", err)
+	}
+	legacySunset, err := apiversion.LegacySunset()
+	if err != nil {
+		logging.Fatal("invalid config", "error", err)
+	}
+	requestTimeout, err := handlers.RequestTimeout()
+	if err != nil {
+		logging.Fatal("invalid config", "error", err)
+	}
+	var jobs workers.Scheduler
+	jobs.Register(workers.UnpaidCancellationJob(unpaidInterval, sender))
+	jobs.Register(workers.HoldCleanupJob())
+	jobs.Register(workers.ReminderJob(reminderInterval, sender))
+	jobs.Register(workers.WaitingListExpiryJob(waitlist.MaxAge()))
+	jobs.Register(workers.WaitingListEscalationJob(waitlist.UrgentSLA(), sender))
+	jobs.Register(workers.NoShowJob(noShowGrace))
+	jobs.Register(workers.PrescriptionExpiryJob())
+	jobs.Register(workers.WebhookDispatchJob())
+	jobs.Register(workers.NotificationRetryJob(sender))
+	jobs.Register(workers.RetentionJob(cfg.Retention.Policies(), documentStore))
+	if eventBus != nil {
+		database.EnableEventOutbox()
+		jobs.Register(workers.EventRelayJob(eventBus))
+	}
+	if hl7Export != nil {
+		hl7.Enable(hl7Export)
+		jobs.Register(workers.HL7DispatchJob(hl7Export))
+	}
+	jobs.Start(ctx)
+
+	// Live schedule updates, fed by PostgreSQL notifications until shutdown
+	liveHub := live.NewHub()
+	go liveHub.Run(ctx)
+
+	// Requests are logged through slog; the access log wraps apierr so it sees the request id
+	// and the final status
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logging.Fatal("invalid trusted proxies", "error", err)
+	}
+	r.Use(gin.Recovery(), logging.Middleware())
+	r.Use(apierr.Middleware())
+	r.Use(handlers.Timeout(requestTimeout))
+
+	// Configure CORS
+	if slices.Contains(cfg.Server.CORSOrigins, "*") {
+		slog.Warn("CORS allows any origin; set CORS_ALLOWED_ORIGINS in production")
+	}
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.Server.CORSOrigins
+	// Clinics embed online booking on their own sites. The public endpoints take no
+	// credentials, so any site may call them; a booking widget checks its clinic's origins.
+	corsConfig.AllowOriginWithContextFunc = func(c *gin.Context, origin string) bool {
+		return publicPath.MatchString(c.Request.URL.Path)
+	}
+	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "If-Match", apierr.RequestIDHeader}
+	corsConfig.ExposeHeaders = []string{"ETag", apierr.RequestIDHeader, apiversion.VersionHeader,
+		apiversion.DeprecationHeader, apiversion.SunsetHeader, apiversion.LinkHeader,
+		ratelimit.LimitHeader, ratelimit.RemainingHeader, ratelimit.ResetHeader, ratelimit.RetryAfterHeader}
+	r.Use(cors.New(corsConfig))
 
-	// Health check endpoint
+	// API Routes: each version is mounted under /api/<version>. The unversioned /api paths
+	// predate versioning and stay as a deprecated alias of v1 for existing clients. Every API
+	// request works for the organization of its subdomain, or of the caller's token.
+	api := r.Group("/api", tenant.Middleware())
+	deps := handlers.Deps{Sender: sender, Repos: database.NewRepos(), Stripe: stripe, Invoices: cfg.Invoices, Live: liveHub, Captcha: captchaVerifier, Geocoder: geocoder, Storage: documentStore,
+		Retention: cfg.Retention, Holds: ratelimit.New(cfg.RateLimit.HoldsPerMinute, cfg.RateLimit.HoldsBurst)}
+	// One set of limiters for every mount, so the legacy alias does not double a client's allowance
+	limits := rateLimits{
+		public:  ratelimit.New(cfg.RateLimit.PublicPerMinute, cfg.RateLimit.PublicBurst),
+		callers: ratelimit.New(cfg.RateLimit.PerMinute, cfg.RateLimit.Burst),
+	}
+	v1 := apiversion.Version{Name: "v1", Register: func(g *gin.RouterGroup) { registerV1(g, deps, cfg.Features, limits) }}
+	apiversion.Mount(api, v1)
+	if cfg.Features.LegacyAPI {
+		apiversion.MountLegacy(api, v1, legacyAPIDeprecated, legacySunset)
+	}
+
+	r.NoRoute(func(c *gin.Context) {
+		c.Error(apierr.NotFound("Route not found"))
+	})
+
+	// Health checks: /health/live for liveness probes, /health/ready for readiness probes
+	// (the database, every configured delivery service and Stripe). /health predates them
+	// and, like /health/live, checks nothing.
+	checks := []health.Check{{Name: "database", Run: database.Ping}}
+	for channel, pinger := range notifications.Pingers(sender) {
+		checks = append(checks, health.Check{Name: channel, Run: pinger.Ping})
+	}
+	if stripe != nil {
+		checks = append(checks, health.Check{Name: "stripe", Run: stripe.Ping})
+	}
+	r.GET("/health/live", health.Live)
+	r.GET("/health/ready", health.Ready(checks))
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
 			"status":  "OK",
@@ -116,6 +327,97 @@ func main() {
 		})
 	})
 
-	log.Println("Server starting on port 8080...")
-	log.Fatal(r.Run(":8080"))
+	server := &http.Server{Addr: cfg.Server.Addr, Handler: r}
+	go func() {
+		slog.Info("server starting", "addr", server.Addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("server failed", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("server shutdown", "error", err)
+	}
+	if !jobs.Stop(shutdownTimeout) {
+		slog.Warn("background jobs did not finish in time")
+	}
+}
+
+// rateLimits are the request limits for the open and the authenticated routes
+type rateLimits struct {
+	public  *ratelimit.Limiter
+	callers *ratelimit.Limiter
+}
+
+// registerV1 mounts the v1 routes: every domain module adds its own endpoints to the group.
+// Login, the patient-facing pages and token-addressed links are open and limited per
+// address; everything else needs an access token or API key and is limited per caller.
+// Modules behind a feature toggle are left out when it is off.
+func registerV1(api *gin.RouterGroup, deps handlers.Deps, features config.Features, limits rateLimits) {
+	openModules := []func(*gin.RouterGroup, handlers.Deps){
+		sessions.RegisterRoutes,
+		paymentlinks.RegisterPublicRoutes,
+		cardpayments.RegisterPublicRoutes,
+		streams.RegisterPublicRoutes,
+		appointments.RegisterPublicRoutes,
+		portal.RegisterPublicRoutes,
+		fhir.RegisterPublicRoutes,
+	}
+	if features.PublicBooking {
+		openModules = append(openModules, public.RegisterRoutes)
+	}
+	if features.CalendarFeeds {
+		openModules = append(openModules, calendars.RegisterPublicRoutes)
+	}
+	open := api.Group("")
+	open.Use(limits.public.Middleware(ratelimit.ByIP))
+	for _, register := range openModules {
+		register(open, deps)
+	}
+
+	protected := api.Group("")
+	protected.Use(auth.Middleware(), limits.callers.Middleware(ratelimit.ByCaller))
+	modules := []func(*gin.RouterGroup, handlers.Deps){
+		clinics.RegisterRoutes,
+		patients.RegisterRoutes,
+		documents.RegisterRoutes,
+		employees.RegisterRoutes,
+		services.RegisterRoutes,
+		resources.RegisterRoutes,
+		appointments.RegisterRoutes,
+		visitnotes.RegisterRoutes,
+		prescriptions.RegisterRoutes,
+		referrals.RegisterRoutes,
+		forms.RegisterRoutes,
+		consents.RegisterRoutes,
+		retentionlog.RegisterRoutes,
+		waitinglist.RegisterRoutes,
+		paymentlinks.RegisterRoutes,
+		cardpayments.RegisterRoutes,
+		scheduling.RegisterRoutes,
+		slotholds.RegisterRoutes,
+		timeoff.RegisterRoutes,
+		auditlog.RegisterRoutes,
+		accesslog.RegisterRoutes,
+		notificationlog.RegisterRoutes,
+		users.RegisterRoutes,
+		webhooks.RegisterRoutes,
+		streams.RegisterRoutes,
+		fhir.RegisterRoutes,
+		reports.RegisterRoutes,
+		apikeys.RegisterRoutes,
+	}
+	if features.PatientPortal {
+		modules = append(modules, portal.RegisterRoutes)
+	}
+	if features.CalendarFeeds {
+		modules = append(modules, calendars.RegisterRoutes)
+	}
+	for _, register := range modules {
+		register(protected, deps)
+	}
 }
@@ -16,90 +16,791 @@
 
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"bookings/money"
+)
+
+// Enum values mirrored from the PostgreSQL enum types created in the database package
+var (
+	AppointmentStatuses = []string{"SCHEDULED", "CONFIRMED", "IN_PROGRESS", "COMPLETED", "CANCELLED", "NO_SHOW"}
+	AppointmentTypes    = []string{"INITIAL_CONSULTATION", "FOLLOW_UP", "PROCEDURE", "EMERGENCY"}
+	PaymentStatuses     = []string{"PENDING", "PAID", "REFUNDED"}
+	UrgencyLevels       = []string{"LOW", "MEDIUM", "HIGH", "URGENT"}
+	WaitingListStatuses = []string{"ACTIVE", "CONTACTED", "SCHEDULED", "EXPIRED"}
+	PaymentLinkStatuses = []string{"PENDING", "PAID", "EXPIRED", "CANCELLED"}
+	CardPaymentStatuses = []string{"PENDING", "SUCCEEDED", "FAILED", "REFUND_PENDING", "REFUNDED"}
+	BookingChannels     = []string{"PHONE", "WALK_IN", "WEB", "PORTAL"}
+	TimeOffStatuses     = []string{"PENDING", "APPROVED", "REJECTED"}
+	UserRoles           = []string{"ADMIN", "CLINICIAN", "RECEPTIONIST", "PATIENT"}
+	ResourceKinds       = []string{"ROOM", "EQUIPMENT"}
+	WebhookEvents       = []string{"appointment.created", "appointment.cancelled", "patient.created", "waitlist.matched"}
+	WebhookStatuses     = []string{"PENDING", "DELIVERED", "FAILED"}
+	// NotificationChannels are the choices of NotificationPreferences.Channel
+	NotificationChannels = []string{"ALL", "EMAIL", "SMS", "NONE"}
+	NotificationStatuses = []string{"PENDING", "SENT", "FAILED"}
+	DocumentCategories   = []string{"REFERRAL", "LAB_RESULT", "IMAGING", "OTHER"}
+	PrescriptionStatuses = []string{"ACTIVE", "EXPIRED", "CANCELLED"}
+	ReferralStatuses     = []string{"SENT", "ACCEPTED", "BOOKED", "DECLINED"}
+	CodeSystems          = []string{"SNOMED_CT", "ICD_10", "RXNORM"}
+	AllergyCriticalities = []string{"LOW", "HIGH", "UNABLE_TO_ASSESS"}
+	AllergyStatuses      = []string{"ACTIVE", "INACTIVE", "RESOLVED"}
+	MedicationStatuses   = []string{"ACTIVE", "STOPPED"}
+	ConditionStatuses    = []string{"ACTIVE", "RESOLVED"}
+	ConsentKinds         = []string{"PRIVACY_POLICY", "TREATMENT", "DATA_SHARING"}
+	// ConsentCaptureMethods are how consent was given: in the portal, while booking online,
+	// in person, on paper or verbally
+	ConsentCaptureMethods = []string{"PORTAL", "ONLINE", "IN_PERSON", "PAPER", "VERBAL"}
+	// RelationshipTypes are how a guardian is related to their dependent
+	RelationshipTypes = []string{"PARENT", "LEGAL_GUARDIAN", "CAREGIVER"}
+)
+
+// Organization is a tenant of a shared deployment: a clinic group that owns its records.
+// Slug names its subdomain.
+type Organization struct {
+	ID        int       `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Slug      string    `json:"slug" db:"slug"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
 
 // Clinic represents a medical clinic
 type Clinic struct {
-	ID      int    `json:"id" db:"id"`
-	Name    string `json:"name" db:"name"`
-	Address string `json:"address" db:"address"`
-	Phone   string `json:"phone" db:"phone"`
-	Email   string `json:"email" db:"email"`
-	Active  bool   `json:"active" db:"active"`
+	ID                     int      `json:"id" db:"id"`
+	Name                   string   `json:"name" db:"name" binding:"required"`
+	Address                string   `json:"address" db:"address"`
+	City                   string   `json:"city" db:"city"`
+	Latitude               *float64 `json:"latitude" db:"latitude" binding:"omitnil,latitude"`
+	Longitude              *float64 `json:"longitude" db:"longitude" binding:"omitnil,longitude"`
+	Phone                  string   `json:"phone" db:"phone" binding:"omitempty,phone"`
+	Email                  string   `json:"email" db:"email" binding:"omitempty,email"`
+	Active                 bool     `json:"active" db:"active"`
+	SMSRemindersEnabled    *bool    `json:"sms_reminders_enabled" db:"sms_reminders_enabled"`
+	EmailRemindersEnabled  *bool    `json:"email_reminders_enabled" db:"email_reminders_enabled"`
+	HighRiskExtraReminders bool     `json:"high_risk_extra_reminders" db:"high_risk_extra_reminders"`
+	MinLeadMinutes         int      `json:"min_lead_minutes" db:"min_lead_minutes" binding:"gte=0"`
+	MaxAdvanceDays         *int     `json:"max_advance_days" db:"max_advance_days" binding:"omitnil,gt=0"`
+	// OrganizationID is the tenant the clinic belongs to, always the creating user's
+	OrganizationID int `json:"organization_id" db:"organization_id"`
+	// DeletedAt is set while the clinic is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// PhoneDisplay is the phone number as it was entered, kept while it is still the number
+	// in Phone, which is stored in E.164
+	PhoneDisplay *string `json:"phone_display" db:"phone_display"`
+	// MRNPrefix starts the medical record numbers generated for patients registered at the
+	// clinic; without one MRN_PREFIX is used
+	MRNPrefix *string `json:"mrn_prefix" db:"mrn_prefix" binding:"omitnil,mrn_prefix"`
+	// Timezone is the IANA timezone the clinic's days are counted in and that employees
+	// added without one work in
+	Timezone *string `json:"timezone" db:"timezone" binding:"omitnil,timezone"`
 }
 
 // Patient represents a patient
 type Patient struct {
 	ID                    int       `json:"id" db:"id"`
-	FirstName             string    `json:"first_name" db:"first_name"`
-	LastName              string    `json:"last_name" db:"last_name"`
-	Email                 string    `json:"email" db:"email"`
-	Phone                 string    `json:"phone" db:"phone"`
-	DateOfBirth           *string   `json:"date_of_birth" db:"date_of_birth"`
+	PublicID              string    `json:"public_id" db:"public_id"`
+	FirstName             string    `json:"first_name" db:"first_name" binding:"required"`
+	LastName              string    `json:"last_name" db:"last_name" binding:"required"`
+	Email                 string    `json:"email" db:"email" binding:"omitempty,email"`
+	Phone                 string    `json:"phone" db:"phone" binding:"omitempty,phone"`
+	DateOfBirth           *string   `json:"date_of_birth" db:"date_of_birth" binding:"omitnil,datetime=2006-01-02,pastdate"`
 	MedicalRecordNumber   string    `json:"medical_record_number" db:"medical_record_number"`
 	InsuranceProvider     *string   `json:"insurance_provider" db:"insurance_provider"`
 	InsuranceID           *string   `json:"insurance_id" db:"insurance_id"`
 	EmergencyContactName  *string   `json:"emergency_contact_name" db:"emergency_contact_name"`
-	EmergencyContactPhone *string   `json:"emergency_contact_phone" db:"emergency_contact_phone"`
+	EmergencyContactPhone *string   `json:"emergency_contact_phone" db:"emergency_contact_phone" binding:"omitnil,phone"`
 	Active                bool      `json:"active" db:"active"`
 	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	// DeletedAt is set while the patient is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// NoShowCount is how many of the patient's appointments are NO_SHOW. It is kept up to date
+	// by the server and ignored on writes.
+	NoShowCount int `json:"no_show_count" db:"no_show_count"`
+	// Version goes up with every change; it is sent as the ETag and expected back in If-Match
+	Version int `json:"version" db:"version"`
+	// ErasedAt is set once the patient's personal details have been erased on request
+	ErasedAt *time.Time `json:"erased_at,omitempty" db:"erased_at"`
+	// MergedIntoID is the patient this one was merged into as a duplicate
+	MergedIntoID *int `json:"merged_into_id,omitempty" db:"merged_into_id"`
+	// PhoneDisplay is the phone number as it was entered, kept while it is still the number
+	// in Phone, which is stored in E.164
+	PhoneDisplay *string `json:"phone_display" db:"phone_display"`
+	// Age is the patient's age in whole years today, worked out from DateOfBirth when read;
+	// it is ignored on writes
+	Age *int `json:"age" db:"-"`
+	// ClinicID is the clinic registering a new patient, whose prefix a generated medical
+	// record number takes. It is only read when the patient is created and is not stored.
+	ClinicID *int `json:"clinic_id,omitempty" db:"-"`
+}
+
+// NotificationPreferences is how a patient wants to be notified. Channel ALL uses every
+// channel the patient has contact details for and NONE sends nothing. Quiet hours are
+// HH:MM local times in the appointment employee's timezone and may run past midnight.
+type NotificationPreferences struct {
+	PatientID           int     `json:"patient_id" db:"patient_id"`
+	Channel             string  `json:"channel" db:"channel" binding:"required,enum=notification_channel"`
+	PreferredLanguage   *string `json:"preferred_language" db:"preferred_language" binding:"omitnil,bcp47_language_tag"`
+	QuietHoursStart     *string `json:"quiet_hours_start" db:"quiet_hours_start" binding:"required_with=QuietHoursEnd,omitnil,datetime=15:04"`
+	QuietHoursEnd       *string `json:"quiet_hours_end" db:"quiet_hours_end" binding:"required_with=QuietHoursStart,omitnil,datetime=15:04"`
+	ReminderLeadMinutes *int    `json:"reminder_lead_minutes" db:"reminder_lead_minutes" binding:"omitnil,gt=0,lte=20160"`
+	// UnsubscribedAt is when the patient last followed an unsubscribe link, which sets the
+	// channel to NONE. It is set by the server and ignored on writes.
+	UnsubscribedAt *time.Time `json:"unsubscribed_at" db:"unsubscribed_at"`
+	UpdatedAt      *time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // Employee represents a medical employee/doctor
 type Employee struct {
-	ID            int       `json:"id" db:"id"`
-	ClinicID      int       `json:"clinic_id" db:"clinic_id"`
-	FirstName     string    `json:"first_name" db:"first_name"`
-	LastName      string    `json:"last_name" db:"last_name"`
-	Email         string    `json:"email" db:"email"`
-	Phone         string    `json:"phone" db:"phone"`
-	LicenseNumber string    `json:"license_number" db:"license_number"`
-	Specialty     string    `json:"specialty" db:"specialty"`
-	Timezone      string    `json:"timezone" db:"timezone"`
-	Active        bool      `json:"active" db:"active"`
-	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	ID                     int       `json:"id" db:"id"`
+	ClinicID               int       `json:"clinic_id" db:"clinic_id" binding:"required"`
+	FirstName              string    `json:"first_name" db:"first_name" binding:"required"`
+	LastName               string    `json:"last_name" db:"last_name" binding:"required"`
+	Email                  string    `json:"email" db:"email" binding:"omitempty,email"`
+	Phone                  string    `json:"phone" db:"phone" binding:"omitempty,phone"`
+	LicenseNumber          string    `json:"license_number" db:"license_number"`
+	Specialty              string    `json:"specialty" db:"specialty"`
+	Timezone               string    `json:"timezone" db:"timezone" binding:"omitempty,timezone"`
+	FollowUpReservePercent int       `json:"follow_up_reserve_percent" db:"follow_up_reserve_percent" binding:"gte=0,lte=100"`
+	FollowUpReleaseDays    int       `json:"follow_up_release_days" db:"follow_up_release_days" binding:"gte=0"`
+	Active                 bool      `json:"active" db:"active"`
+	CreatedAt              time.Time `json:"created_at" db:"created_at"`
+	// Bio, PhotoURL, Languages (BCP 47 tags) and AcceptedInsurance are shown in the public
+	// provider directory
+	Bio               string   `json:"bio" db:"bio" binding:"max=2000"`
+	PhotoURL          *string  `json:"photo_url" db:"photo_url" binding:"omitnil,url"`
+	Languages         []string `json:"languages" db:"languages" binding:"dive,bcp47_language_tag"`
+	AcceptedInsurance []string `json:"accepted_insurance" db:"accepted_insurance" binding:"dive,required"`
+	// DeletedAt is set while the employee is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// PhoneDisplay is the phone number as it was entered, kept while it is still the number
+	// in Phone, which is stored in E.164
+	PhoneDisplay *string `json:"phone_display" db:"phone_display"`
 }
 
 // Service represents a medical service
 type Service struct {
-	ID                int     `json:"id" db:"id"`
-	Name              string  `json:"name" db:"name"`
-	Description       string  `json:"description" db:"description"`
-	DurationMinutes   int     `json:"duration_minutes" db:"duration_minutes"`
-	Price             float64 `json:"price" db:"price"`
-	SpecialtyRequired string  `json:"specialty_required" db:"specialty_required"`
-	Active            bool    `json:"active" db:"active"`
+	ID                      int         `json:"id" db:"id"`
+	Name                    string      `json:"name" db:"name" binding:"required"`
+	Description             string      `json:"description" db:"description"`
+	DurationMinutes         int         `json:"duration_minutes" db:"duration_minutes" binding:"required,gt=0"`
+	Price                   money.Money `json:"price" db:"price_minor"`
+	SpecialtyRequired       string      `json:"specialty_required" db:"specialty_required"`
+	MinLeadMinutes          int         `json:"min_lead_minutes" db:"min_lead_minutes" binding:"gte=0"`
+	SameDayCutoffHour       *int        `json:"same_day_cutoff_hour" db:"same_day_cutoff_hour" binding:"omitnil,gte=0,lte=24"`
+	MaxAdvanceDays          *int        `json:"max_advance_days" db:"max_advance_days" binding:"omitnil,gt=0"`
+	AllowsMultiDay          bool        `json:"allows_multi_day" db:"allows_multi_day"`
+	PrepaymentWindowMinutes *int        `json:"prepayment_window_minutes" db:"prepayment_window_minutes" binding:"omitnil,gt=0"`
+	BufferBeforeMinutes     int         `json:"buffer_before_minutes" db:"buffer_before_minutes" binding:"gte=0"`
+	BufferAfterMinutes      int         `json:"buffer_after_minutes" db:"buffer_after_minutes" binding:"gte=0"`
+	// RequiredConsents are the consent kinds whose current version the patient must have
+	// agreed to before the service is booked
+	RequiredConsents []string `json:"required_consents" db:"required_consents" binding:"dive,enum=consent_kind"`
+	// MinAgeYears and MaxAgeYears limit the service to patients of those ages on the day of
+	// the appointment, e.g. a max of 17 for a pediatric service
+	MinAgeYears *int `json:"min_age_years" db:"min_age_years" binding:"omitnil,gte=0,lte=150"`
+	MaxAgeYears *int `json:"max_age_years" db:"max_age_years" binding:"omitnil,gte=0,lte=150"`
+	Active      bool `json:"active" db:"active"`
 }
 
 // Appointment represents a medical appointment
 type Appointment struct {
-	ID                 int       `json:"id" db:"id"`
-	PatientID          int       `json:"patient_id" db:"patient_id"`
-	EmployeeID         int       `json:"employee_id" db:"employee_id"`
-	ServiceID          int       `json:"service_id" db:"service_id"`
-	ClinicID           int       `json:"clinic_id" db:"clinic_id"`
-	StartDatetime      time.Time `json:"start_datetime" db:"start_datetime"`
-	EndDatetime        time.Time `json:"end_datetime" db:"end_datetime"`
-	Status             string    `json:"status" db:"status"`
-	AppointmentType    *string   `json:"appointment_type" db:"appointment_type"`
-	Notes              *string   `json:"notes" db:"notes"`
-	MedicalNotes       *string   `json:"medical_notes" db:"medical_notes"`
-	CancellationReason *string   `json:"cancellation_reason" db:"cancellation_reason"`
-	PaymentStatus      string    `json:"payment_status" db:"payment_status"`
-	PaymentAmount      *float64  `json:"payment_amount" db:"payment_amount"`
-	CreatedAt          time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
+	ID                 int          `json:"id" db:"id"`
+	PublicID           string       `json:"public_id" db:"public_id"`
+	PatientID          int          `json:"patient_id" db:"patient_id" binding:"required"`
+	EmployeeID         int          `json:"employee_id" db:"employee_id" binding:"required"`
+	ServiceID          int          `json:"service_id" db:"service_id" binding:"required"`
+	ClinicID           int          `json:"clinic_id" db:"clinic_id" binding:"required"`
+	StartDatetime      time.Time    `json:"start_datetime" db:"start_datetime" binding:"required"`
+	EndDatetime        time.Time    `json:"end_datetime" db:"end_datetime" binding:"required,gtfield=StartDatetime"`
+	Status             string       `json:"status" db:"status" binding:"required,enum=appointment_status"`
+	AppointmentType    *string      `json:"appointment_type" db:"appointment_type" binding:"omitnil,enum=appointment_type"`
+	BookingChannel     *string      `json:"booking_channel" db:"booking_channel" binding:"omitnil,enum=booking_channel"`
+	Notes              *string      `json:"notes" db:"notes"`
+	MedicalNotes       *string      `json:"medical_notes" db:"medical_notes"`
+	CancellationReason *string      `json:"cancellation_reason" db:"cancellation_reason"`
+	PaymentStatus      string       `json:"payment_status" db:"payment_status" binding:"required,enum=payment_status"`
+	PaymentAmount      *money.Money `json:"payment_amount" db:"payment_amount_minor" binding:"omitnil"`
+	SeriesID           *int         `json:"series_id" db:"series_id"`
+	CreatedAt          time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at" db:"updated_at"`
+	// Version goes up with every change; it is sent as the ETag and expected back in If-Match
+	Version int `json:"version" db:"version"`
+	// CheckedInAt, StartedAt and CheckedOutAt are when the patient arrived, was taken in
+	// (IN_PROGRESS) and was checked out. They are set by the server and ignored on writes.
+	CheckedInAt  *time.Time `json:"checked_in_at" db:"checked_in_at"`
+	StartedAt    *time.Time `json:"started_at" db:"started_at"`
+	CheckedOutAt *time.Time `json:"checked_out_at" db:"checked_out_at"`
+	// CancelledAt is when the appointment was cancelled, while it is; set by the server
+	CancelledAt *time.Time `json:"cancelled_at" db:"cancelled_at"`
+}
+
+// PatientSummary, EmployeeSummary, ServiceSummary and ClinicSummary are the parts of the
+// records an appointment refers to that are embedded in it with ?expand=
+type PatientSummary struct {
+	ID        int    `json:"id"`
+	PublicID  string `json:"public_id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+}
+
+type EmployeeSummary struct {
+	ID        int    `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Specialty string `json:"specialty"`
+}
+
+type ServiceSummary struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	DurationMinutes int    `json:"duration_minutes"`
+}
+
+type ClinicSummary struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
 }
 
 // WaitingList represents a waiting list entry
 type WaitingList struct {
 	ID                  int       `json:"id" db:"id"`
-	PatientID           int       `json:"patient_id" db:"patient_id"`
-	ServiceID           int       `json:"service_id" db:"service_id"`
+	PatientID           int       `json:"patient_id" db:"patient_id" binding:"required"`
+	ServiceID           int       `json:"service_id" db:"service_id" binding:"required"`
 	PreferredEmployeeID *int      `json:"preferred_employee_id" db:"preferred_employee_id"`
-	RequestedDate       *string   `json:"requested_date" db:"requested_date"`
-	UrgencyLevel        string    `json:"urgency_level" db:"urgency_level"`
+	RequestedDate       *string   `json:"requested_date" db:"requested_date" binding:"omitnil,datetime=2006-01-02"`
+	UrgencyLevel        string    `json:"urgency_level" db:"urgency_level" binding:"required,enum=urgency_level"`
 	Notes               *string   `json:"notes" db:"notes"`
-	Status              string    `json:"status" db:"status"`
+	Status              string    `json:"status" db:"status" binding:"required,enum=waiting_list_status"`
 	CreatedAt           time.Time `json:"created_at" db:"created_at"`
+	// EscalatedAt is when an URGENT entry that had waited past the SLA was escalated to
+	// staff. It is set by the server and ignored on writes.
+	EscalatedAt *time.Time `json:"escalated_at" db:"escalated_at"`
+}
+
+// PaymentLink represents a hosted-checkout link for a patient's outstanding balance
+type PaymentLink struct {
+	ID            int         `json:"id" db:"id"`
+	PatientID     int         `json:"patient_id" db:"patient_id"`
+	AppointmentID *int        `json:"appointment_id" db:"appointment_id"`
+	Token         string      `json:"token" db:"token"`
+	URL           string      `json:"url" db:"url"`
+	Amount        money.Money `json:"amount" db:"amount_minor"`
+	Status        string      `json:"status" db:"status"`
+	ExpiresAt     time.Time   `json:"expires_at" db:"expires_at"`
+	PaidAt        *time.Time  `json:"paid_at" db:"paid_at"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+	// PaymentIntentID is the Stripe PaymentIntent the patient pays the link with, once the
+	// checkout has started
+	PaymentIntentID *string `json:"payment_intent_id" db:"payment_intent_id"`
+
+	// Opaque identifiers shown on the hosted checkout page instead of the integer ids
+	PatientPublicID     string  `json:"patient_public_id"`
+	AppointmentPublicID *string `json:"appointment_public_id"`
+}
+
+// CardPayment is a card payment for an appointment, taken through a Stripe PaymentIntent
+type CardPayment struct {
+	ID              int         `json:"id" db:"id"`
+	AppointmentID   int         `json:"appointment_id" db:"appointment_id"`
+	PaymentIntentID string      `json:"payment_intent_id" db:"payment_intent_id"`
+	Amount          money.Money `json:"amount" db:"amount_minor"`
+	Status          string      `json:"status" db:"status"`
+	RefundID        *string     `json:"refund_id" db:"refund_id"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// WorkTemplate represents one weekday of an employee's recurring work schedule.
+// Weekday follows ISO numbering (1 = Monday ... 7 = Sunday); times are "HH:MM" in the employee's timezone.
+type WorkTemplate struct {
+	ID                     int     `json:"id" db:"id"`
+	EmployeeID             int     `json:"employee_id" db:"employee_id"`
+	Weekday                int     `json:"weekday" db:"weekday"`
+	StartTime              *string `json:"start_time" db:"start_time"`
+	EndTime                *string `json:"end_time" db:"end_time"`
+	SlotGranularityMinutes int     `json:"slot_granularity_minutes" db:"slot_granularity_minutes"`
+	IsActive               bool    `json:"is_active" db:"is_active"`
+}
+
+// DayOverride replaces an employee's template hours on a specific date
+type DayOverride struct {
+	ID         int     `json:"id" db:"id"`
+	EmployeeID int     `json:"employee_id" db:"employee_id"`
+	Date       string  `json:"date" db:"date"`
+	IsClosed   bool    `json:"is_closed" db:"is_closed"`
+	StartTime  *string `json:"start_time" db:"start_time" binding:"omitnil,datetime=15:04"`
+	EndTime    *string `json:"end_time" db:"end_time" binding:"omitnil,datetime=15:04"`
+	Reason     *string `json:"reason" db:"reason"`
+}
+
+// Resource is a room or piece of equipment at a clinic that some services need
+type Resource struct {
+	ID        int       `json:"id" db:"id"`
+	ClinicID  int       `json:"clinic_id" db:"clinic_id" binding:"required"`
+	Name      string    `json:"name" db:"name" binding:"required,max=100"`
+	Kind      string    `json:"kind" db:"kind" binding:"required,enum=resource_kind"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ClinicHours is one opening window in a clinic's week. Weekday follows ISO numbering; times are
+// "HH:MM" wall-clock times, read in each employee's timezone. A close time at or before the open
+// time runs past midnight.
+type ClinicHours struct {
+	ID        int    `json:"id" db:"id"`
+	ClinicID  int    `json:"clinic_id" db:"clinic_id"`
+	Weekday   int    `json:"weekday" db:"weekday" binding:"required,min=1,max=7"`
+	OpenTime  string `json:"open_time" db:"open_time" binding:"required,datetime=15:04"`
+	CloseTime string `json:"close_time" db:"close_time" binding:"required,datetime=15:04"`
+}
+
+// ClinicHoliday closes a clinic for a whole date, such as a public holiday or maintenance day
+type ClinicHoliday struct {
+	ID       int    `json:"id" db:"id"`
+	ClinicID int    `json:"clinic_id" db:"clinic_id"`
+	Date     string `json:"date" db:"date"`
+	Name     string `json:"name" db:"name" binding:"required"`
+}
+
+// ClinicWidget configures the booking widget a clinic embeds on its website. The slug names the
+// clinic in the widget's public URL. AllowedOrigins are the sites (scheme, host and port, e.g.
+// "https://clinic.example") that may load it; when empty any site may.
+type ClinicWidget struct {
+	ClinicID       int       `json:"clinic_id" db:"clinic_id"`
+	Slug           string    `json:"slug" db:"slug" binding:"required,slug"`
+	Enabled        bool      `json:"enabled" db:"enabled"`
+	LogoURL        *string   `json:"logo_url" db:"logo_url" binding:"omitnil,url"`
+	PrimaryColor   *string   `json:"primary_color" db:"primary_color" binding:"omitnil,hexcolor"`
+	WelcomeMessage *string   `json:"welcome_message" db:"welcome_message" binding:"omitnil,max=500"`
+	AllowedOrigins []string  `json:"allowed_origins" db:"allowed_origins" binding:"dive,origin"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// TimeOff represents a period an employee is away
+type TimeOff struct {
+	ID            int        `json:"id" db:"id"`
+	EmployeeID    int        `json:"employee_id" db:"employee_id" binding:"required"`
+	StartDatetime time.Time  `json:"start_datetime" db:"start_datetime" binding:"required"`
+	EndDatetime   time.Time  `json:"end_datetime" db:"end_datetime" binding:"required,gtfield=StartDatetime"`
+	Reason        *string    `json:"reason" db:"reason"`
+	Status        string     `json:"status" db:"status"`
+	DecidedAt     *time.Time `json:"decided_at" db:"decided_at"`
+	DecisionNote  *string    `json:"decision_note" db:"decision_note"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+// SlotHold is a short-lived reservation of a time range while a patient completes a booking
+type SlotHold struct {
+	ID            int       `json:"id" db:"id"`
+	EmployeeID    int       `json:"employee_id" db:"employee_id"`
+	ServiceID     int       `json:"service_id" db:"service_id"`
+	StartDatetime time.Time `json:"start_datetime" db:"start_datetime"`
+	EndDatetime   time.Time `json:"end_datetime" db:"end_datetime"`
+	PatientID     *int      `json:"patient_id" db:"patient_id"`
+	HoldToken     string    `json:"hold_token" db:"hold_token"`
+	ExpiresAt     time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// WaitingListCandidate is an active waiting list entry together with the length of the service it needs
+type WaitingListCandidate struct {
+	WaitingListID   int     `json:"waiting_list_id"`
+	PatientID       int     `json:"patient_id"`
+	ServiceID       int     `json:"service_id"`
+	DurationMinutes int     `json:"duration_minutes"`
+	UrgencyLevel    string  `json:"urgency_level"`
+	RequestedDate   *string `json:"requested_date"`
+}
+
+// User is a login account for the API
+type User struct {
+	ID           int       `json:"id" db:"id"`
+	Email        string    `json:"email" db:"email"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	Role         string    `json:"role" db:"role"`
+	PatientID    *int      `json:"patient_id" db:"patient_id"`
+	Active       bool      `json:"active" db:"active"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	// OrganizationID is the tenant the user belongs to and may see the data of
+	OrganizationID int `json:"organization_id" db:"organization_id"`
+}
+
+// APIKey lets a third-party integration call the API without a user account, limited to its
+// scopes: route group to "read" or "write". Only a hash of the key is stored; Key is set
+// just in the response that issues or rotates it.
+type APIKey struct {
+	ID             int               `json:"id" db:"id"`
+	Name           string            `json:"name" db:"name"`
+	Prefix         string            `json:"prefix" db:"prefix"`
+	Scopes         map[string]string `json:"scopes" db:"scopes"`
+	OrganizationID int               `json:"organization_id" db:"organization_id"`
+	CreatedBy      *int              `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	ExpiresAt      *time.Time        `json:"expires_at" db:"expires_at"`
+	LastUsedAt     *time.Time        `json:"last_used_at" db:"last_used_at"`
+	RotatedAt      *time.Time        `json:"rotated_at" db:"rotated_at"`
+	RevokedAt      *time.Time        `json:"revoked_at" db:"revoked_at"`
+	Key            string            `json:"key,omitempty" db:"-"`
+}
+
+// Document is a file attached to a patient, such as a referral letter, lab result or scan,
+// and optionally to one of their appointments. The file is kept in the object store under
+// StorageKey and downloaded through a short-lived signed URL.
+type Document struct {
+	ID            int        `json:"id" db:"id"`
+	PatientID     int        `json:"patient_id" db:"patient_id"`
+	AppointmentID *int       `json:"appointment_id" db:"appointment_id"`
+	Category      string     `json:"category" db:"category"`
+	Filename      string     `json:"filename" db:"filename"`
+	ContentType   string     `json:"content_type" db:"content_type"`
+	SizeBytes     int64      `json:"size_bytes" db:"size_bytes"`
+	SHA256        string     `json:"sha256" db:"sha256"`
+	Description   *string    `json:"description" db:"description"`
+	StorageKey    string     `json:"-" db:"storage_key"`
+	UploadedBy    *int       `json:"uploaded_by" db:"uploaded_by"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	DeletedBy     *int       `json:"deleted_by,omitempty" db:"deleted_by"`
+}
+
+// SOAPSections are the four sections of a visit note
+type SOAPSections struct {
+	Subjective *string `json:"subjective" db:"subjective" binding:"omitnil,max=20000"`
+	Objective  *string `json:"objective" db:"objective" binding:"omitnil,max=20000"`
+	Assessment *string `json:"assessment" db:"assessment" binding:"omitnil,max=20000"`
+	Plan       *string `json:"plan" db:"plan" binding:"omitnil,max=20000"`
+}
+
+// VisitNote is a clinician's structured note of a completed appointment. Its author may edit
+// it until they sign it; once SignedAt is set it is locked and only changed by amendments.
+type VisitNote struct {
+	ID            int `json:"id" db:"id"`
+	AppointmentID int `json:"appointment_id" db:"appointment_id"`
+	SOAPSections
+	AuthorID  *int       `json:"author_id" db:"author_id"`
+	SignedAt  *time.Time `json:"signed_at" db:"signed_at"`
+	AmendedAt *time.Time `json:"amended_at" db:"amended_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// VisitNoteAmendment is a change to a signed visit note: who made it and why, with the note's
+// sections as they were before it
+type VisitNoteAmendment struct {
+	ID          int    `json:"id" db:"id"`
+	VisitNoteID int    `json:"visit_note_id" db:"visit_note_id"`
+	Reason      string `json:"reason" db:"reason"`
+	SOAPSections
+	AuthorID  *int      `json:"author_id" db:"author_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Prescription is a medication prescribed at a completed appointment by the appointment's
+// employee. Each of the 1 + Refills fills lasts DurationDays, so it is valid from StartsOn
+// (the appointment's date unless given) until ExpiresOn, StartsOn plus
+// DurationDays × (1 + Refills).
+type Prescription struct {
+	ID                 int        `json:"id" db:"id"`
+	AppointmentID      int        `json:"appointment_id" db:"appointment_id"`
+	PatientID          int        `json:"patient_id" db:"patient_id"`
+	PrescriberID       int        `json:"prescriber_id" db:"prescriber_id"`
+	Medication         string     `json:"medication" db:"medication" binding:"required,max=200"`
+	Dosage             string     `json:"dosage" db:"dosage" binding:"required,max=200"`
+	DurationDays       int        `json:"duration_days" db:"duration_days" binding:"required,gt=0,lte=365"`
+	Refills            int        `json:"refills" db:"refills" binding:"gte=0,lte=12"`
+	Instructions       *string    `json:"instructions" db:"instructions" binding:"omitnil,max=2000"`
+	Status             string     `json:"status" db:"status"`
+	StartsOn           string     `json:"starts_on" db:"starts_on" binding:"omitempty,datetime=2006-01-02"`
+	ExpiresOn          string     `json:"expires_on" db:"expires_on"`
+	CancelledAt        *time.Time `json:"cancelled_at" db:"cancelled_at"`
+	CancellationReason *string    `json:"cancellation_reason" db:"cancellation_reason"`
+	CreatedBy          *int       `json:"created_by" db:"created_by"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Referral sends a patient from one of the clinics' employees to another employee, clinic or
+// specialty; it names a target employee, a target specialty or both. A referral is SENT,
+// then ACCEPTED, which puts the patient on the waiting list, or DECLINED, and an accepted
+// one is BOOKED once its appointment is. Only the fields bound on create are taken from
+// requests.
+type Referral struct {
+	ID               int        `json:"id" db:"id"`
+	PatientID        int        `json:"patient_id" db:"patient_id" binding:"required"`
+	SourceEmployeeID int        `json:"source_employee_id" db:"source_employee_id" binding:"required"`
+	TargetClinicID   *int       `json:"target_clinic_id" db:"target_clinic_id"`
+	TargetEmployeeID *int       `json:"target_employee_id" db:"target_employee_id"`
+	TargetSpecialty  *string    `json:"target_specialty" db:"target_specialty" binding:"omitnil,max=100"`
+	ReferralReason   string     `json:"referral_reason" db:"referral_reason" binding:"required,max=5000"`
+	UrgencyLevel     string     `json:"urgency_level" db:"urgency_level" binding:"required,enum=urgency_level"`
+	Status           string     `json:"status" db:"status"`
+	DeclineReason    *string    `json:"decline_reason" db:"decline_reason"`
+	WaitingListID    *int       `json:"waiting_list_id" db:"waiting_list_id"`
+	AppointmentID    *int       `json:"appointment_id" db:"appointment_id"`
+	CreatedBy        *int       `json:"created_by" db:"created_by"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	RespondedAt      *time.Time `json:"responded_at" db:"responded_at"`
+	BookedAt         *time.Time `json:"booked_at" db:"booked_at"`
+}
+
+// Coding identifies a chart entry in a terminology: SNOMED CT, ICD-10 or RxNorm. Entries are
+// coded where possible; the text alone is accepted.
+type Coding struct {
+	CodeSystem *string `json:"code_system" db:"code_system" binding:"required_with=Code,omitnil,enum=code_system"`
+	Code       *string `json:"code" db:"code" binding:"required_with=CodeSystem,omitnil,max=50"`
+}
+
+// Allergy is an allergy or intolerance on a patient's chart. ACTIVE ones of HIGH criticality
+// are shown on the patient's appointments. Criticality defaults to UNABLE_TO_ASSESS and
+// status to ACTIVE.
+type Allergy struct {
+	ID        int    `json:"id" db:"id"`
+	PatientID int    `json:"patient_id" db:"patient_id"`
+	Substance string `json:"substance" db:"substance" binding:"required,max=200"`
+	Coding
+	Reaction    *string   `json:"reaction" db:"reaction" binding:"omitnil,max=500"`
+	Criticality string    `json:"criticality" db:"criticality" binding:"omitempty,enum=allergy_criticality"`
+	Status      string    `json:"status" db:"status" binding:"omitempty,enum=allergy_status"`
+	OnsetDate   *string   `json:"onset_date" db:"onset_date" binding:"omitnil,datetime=2006-01-02"`
+	RecordedBy  *int      `json:"recorded_by" db:"recorded_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Medication is a medication a patient takes, on their chart, whoever prescribed it. Status
+// defaults to ACTIVE.
+type Medication struct {
+	ID        int    `json:"id" db:"id"`
+	PatientID int    `json:"patient_id" db:"patient_id"`
+	Name      string `json:"name" db:"name" binding:"required,max=200"`
+	Coding
+	Dosage     *string   `json:"dosage" db:"dosage" binding:"omitnil,max=200"`
+	Status     string    `json:"status" db:"status" binding:"omitempty,enum=medication_status"`
+	StartedOn  *string   `json:"started_on" db:"started_on" binding:"omitnil,datetime=2006-01-02"`
+	StoppedOn  *string   `json:"stopped_on" db:"stopped_on" binding:"omitnil,datetime=2006-01-02"`
+	RecordedBy *int      `json:"recorded_by" db:"recorded_by"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Condition is a diagnosis or problem on a patient's chart. Status defaults to ACTIVE.
+type Condition struct {
+	ID        int    `json:"id" db:"id"`
+	PatientID int    `json:"patient_id" db:"patient_id"`
+	Name      string `json:"name" db:"name" binding:"required,max=200"`
+	Coding
+	Status     string    `json:"status" db:"status" binding:"omitempty,enum=condition_status"`
+	OnsetDate  *string   `json:"onset_date" db:"onset_date" binding:"omitnil,datetime=2006-01-02"`
+	ResolvedOn *string   `json:"resolved_on" db:"resolved_on" binding:"omitnil,datetime=2006-01-02"`
+	RecordedBy *int      `json:"recorded_by" db:"recorded_by"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ConsentDocument is one version of a privacy policy, treatment consent or data-sharing
+// agreement. Documents are never changed; a new version is published instead and becomes the
+// kind's current version at EffectiveAt.
+type ConsentDocument struct {
+	ID          int       `json:"id" db:"id"`
+	Kind        string    `json:"kind" db:"kind" binding:"required,enum=consent_kind"`
+	Version     string    `json:"version" db:"version" binding:"required,max=50"`
+	Title       string    `json:"title" db:"title" binding:"required,max=200"`
+	Body        string    `json:"body" db:"body" binding:"required"`
+	EffectiveAt time.Time `json:"effective_at" db:"effective_at"`
+	CreatedBy   *int      `json:"created_by" db:"created_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// PatientConsent is a patient's agreement to one consent document version: when it was
+// given, how, and when it was withdrawn. Current reports whether it is in force for the
+// kind's current version.
+type PatientConsent struct {
+	ID                int        `json:"id" db:"id"`
+	PatientID         int        `json:"patient_id" db:"patient_id"`
+	ConsentDocumentID int        `json:"consent_document_id" db:"consent_document_id"`
+	Kind              string     `json:"kind" db:"kind"`
+	Version           string     `json:"version" db:"version"`
+	CaptureMethod     string     `json:"capture_method" db:"capture_method"`
+	CapturedAt        time.Time  `json:"captured_at" db:"captured_at"`
+	RecordedBy        *int       `json:"recorded_by" db:"recorded_by"`
+	WithdrawnAt       *time.Time `json:"withdrawn_at" db:"withdrawn_at"`
+	WithdrawnBy       *int       `json:"withdrawn_by" db:"withdrawn_by"`
+	Current           bool       `json:"current" db:"current"`
+}
+
+// PatientRelationship links a guardian patient to a dependent they may book for. The names
+// are filled in when relationships are listed.
+type PatientRelationship struct {
+	ID            int       `json:"id" db:"id"`
+	GuardianID    int       `json:"guardian_id" db:"guardian_id"`
+	GuardianName  string    `json:"guardian_name" db:"guardian_name"`
+	DependentID   int       `json:"dependent_id" db:"dependent_id" binding:"required"`
+	DependentName string    `json:"dependent_name" db:"dependent_name"`
+	Relationship  string    `json:"relationship" db:"relationship" binding:"required,enum=relationship"`
+	CreatedBy     *int      `json:"created_by" db:"created_by"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// FormTemplate is an intake form: a JSON Schema patients answer in the portal before
+// appointments of the listed services and appointment types, at ClinicID or, when that is
+// nil, at every clinic
+type FormTemplate struct {
+	ID               int             `json:"id" db:"id"`
+	Name             string          `json:"name" db:"name" binding:"required,max=200"`
+	Description      *string         `json:"description" db:"description" binding:"omitnil,max=2000"`
+	Schema           json.RawMessage `json:"schema" db:"schema" binding:"required"`
+	ClinicID         *int            `json:"clinic_id" db:"clinic_id"`
+	ServiceIDs       []int           `json:"service_ids" db:"service_ids" binding:"dive,gt=0"`
+	AppointmentTypes []string        `json:"appointment_types" db:"appointment_types" binding:"dive,enum=appointment_type"`
+	Active           bool            `json:"active" db:"active"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// FormResponse is a patient's answers to an intake form for one appointment, with the
+// schema as it was when they answered
+type FormResponse struct {
+	ID             int             `json:"id" db:"id"`
+	FormTemplateID int             `json:"form_template_id" db:"form_template_id"`
+	AppointmentID  int             `json:"appointment_id" db:"appointment_id"`
+	PatientID      int             `json:"patient_id" db:"patient_id"`
+	Schema         json.RawMessage `json:"schema" db:"schema"`
+	Answers        json.RawMessage `json:"answers" db:"answers"`
+	SubmittedAt    time.Time       `json:"submitted_at" db:"submitted_at"`
+}
+
+// WebhookSubscription is an endpoint that receives the events it lists as signed POSTs
+type WebhookSubscription struct {
+	ID          int      `json:"id" db:"id"`
+	URL         string   `json:"url" db:"url"`
+	Events      []string `json:"events" db:"events"`
+	Description *string  `json:"description" db:"description"`
+	Active      bool     `json:"active" db:"active"`
+	// Secret signs the deliveries. It is generated by the server and only returned when the
+	// subscription is created.
+	Secret    string    `json:"secret,omitempty" db:"secret"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// WebhookDelivery is one event queued for one subscription, with the outcome of its latest attempt
+type WebhookDelivery struct {
+	ID             int             `json:"id" db:"id"`
+	SubscriptionID int             `json:"subscription_id" db:"subscription_id"`
+	EventID        int             `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code" db:"last_status_code"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	// AttemptLog is only filled in when a single delivery is fetched
+	AttemptLog []WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookAttempt is one POST of a delivery: the response status, or the error when there was none
+type WebhookAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at" db:"attempted_at"`
+	StatusCode  *int      `json:"status_code" db:"status_code"`
+	Error       *string   `json:"error" db:"error"`
+	DurationMS  int       `json:"duration_ms" db:"duration_ms"`
+}
+
+// Notification is one email or SMS in the delivery log, with the outcome of its latest attempt
+type Notification struct {
+	ID                int        `json:"id" db:"id"`
+	AppointmentID     *int       `json:"appointment_id" db:"appointment_id"`
+	PatientID         *int       `json:"patient_id" db:"patient_id"`
+	Channel           string     `json:"channel" db:"channel"`
+	Recipient         string     `json:"recipient" db:"recipient"`
+	Template          string     `json:"template" db:"template"`
+	Subject           string     `json:"subject" db:"subject"`
+	Status            string     `json:"status" db:"status"`
+	Attempts          int        `json:"attempts" db:"attempts"`
+	NextAttemptAt     *time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	ProviderMessageID *string    `json:"provider_message_id" db:"provider_message_id"`
+	LastError         *string    `json:"last_error" db:"last_error"`
+	SentAt            *time.Time `json:"sent_at" db:"sent_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+}
+
+// AppointmentReschedule records one move of an appointment to a new time or employee
+type AppointmentReschedule struct {
+	ID               int       `json:"id" db:"id"`
+	AppointmentID    int       `json:"appointment_id" db:"appointment_id"`
+	OldStartDatetime time.Time `json:"old_start_datetime" db:"old_start_datetime"`
+	OldEndDatetime   time.Time `json:"old_end_datetime" db:"old_end_datetime"`
+	OldEmployeeID    int       `json:"old_employee_id" db:"old_employee_id"`
+	NewStartDatetime time.Time `json:"new_start_datetime" db:"new_start_datetime"`
+	NewEndDatetime   time.Time `json:"new_end_datetime" db:"new_end_datetime"`
+	NewEmployeeID    int       `json:"new_employee_id" db:"new_employee_id"`
+	Reason           *string   `json:"reason" db:"reason"`
+	RescheduledBy    *int      `json:"rescheduled_by" db:"rescheduled_by"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// AppointmentSeries is a repeating booking, e.g. weekly physiotherapy for 8 weeks. Its
+// occurrences are ordinary appointments that carry the series_id.
+type AppointmentSeries struct {
+	ID         int `json:"id" db:"id"`
+	PatientID  int `json:"patient_id" db:"patient_id"`
+	EmployeeID int `json:"employee_id" db:"employee_id"`
+	ServiceID  int `json:"service_id" db:"service_id"`
+	ClinicID   int `json:"clinic_id" db:"clinic_id"`
+	// Recurrence is the RRULE the occurrences were generated from, e.g. FREQ=WEEKLY;COUNT=8
+	Recurrence    string    `json:"recurrence" db:"recurrence"`
+	StartDatetime time.Time `json:"start_datetime" db:"start_datetime"`
+	Status        string    `json:"status" db:"status"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+
+	Occurrences []Appointment `json:"occurrences"`
+}
+
+// AuditEntry is one recorded mutation: who made it, the entity's state afterwards and the
+// fields it changed
+type AuditEntry struct {
+	ID            int64           `json:"id" db:"id"`
+	Entity        string          `json:"entity" db:"entity"`
+	EntityID      int             `json:"entity_id" db:"entity_id"`
+	Action        string          `json:"action" db:"action"`
+	ActorUserID   *int            `json:"actor_user_id" db:"actor_user_id"`
+	ActorAPIKeyID *int            `json:"actor_api_key_id" db:"actor_api_key_id"`
+	Snapshot      json.RawMessage `json:"snapshot" db:"snapshot"`
+	Changes       json.RawMessage `json:"changes" db:"changes"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AccessEntry is one read of a patient record or of an appointment's medical notes
+type AccessEntry struct {
+	ID            int64     `json:"id" db:"id"`
+	ActorUserID   *int      `json:"actor_user_id" db:"actor_user_id"`
+	ActorAPIKeyID *int      `json:"actor_api_key_id" db:"actor_api_key_id"`
+	PatientID     int       `json:"patient_id" db:"patient_id"`
+	AppointmentID *int      `json:"appointment_id" db:"appointment_id"`
+	Resource      string    `json:"resource" db:"resource"`
+	Endpoint      string    `json:"endpoint" db:"endpoint"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// RetentionRun reports what one retention sweep did with the rows of a class past its
+// retention period: how many it archived, moved to cold storage or purged, the range of
+// their ids and, for cold storage, the object store keys they were written to
+type RetentionRun struct {
+	ID        int       `json:"id" db:"id"`
+	DataClass string    `json:"data_class" db:"data_class"`
+	Action    string    `json:"action" db:"action"`
+	Cutoff    time.Time `json:"cutoff" db:"cutoff"`
+	RowCount  int       `json:"row_count" db:"row_count"`
+	FirstID   int64     `json:"first_id" db:"first_id"`
+	LastID    int64     `json:"last_id" db:"last_id"`
+	Locations []string  `json:"locations" db:"locations"`
+	RanAt     time.Time `json:"ran_at" db:"ran_at"`
 }
@@ -0,0 +1,95 @@
+// Medical Appointment Booking System - Money Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package money represents amounts as whole minor units of a currency, e.g. cents, so
+// prices, payments and totals add up exactly.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrCurrencyMismatch is returned when adding amounts in different currencies
+var ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+
+// Money is an amount in a currency. It is written to JSON as
+// {"amount": 12050, "currency": "EUR"}, with the amount in minor units.
+type Money struct {
+	// Amount is in the currency's minor units, e.g. cents
+	Amount int64 `json:"amount" binding:"gte=0"`
+	// Currency is the ISO 4217 code, e.g. EUR
+	Currency string `json:"currency" binding:"required,iso4217"`
+}
+
+// New returns amount minor units of currency
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// Add returns m + other, which must be in the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Share returns the part of m that makes up percent of a total which already includes it,
+// e.g. the tax in a tax-inclusive price, rounded to the nearest minor unit
+func (m Money) Share(percent float64) Money {
+	net := math.Round(float64(m.Amount) / (1 + percent/100))
+	return Money{Amount: m.Amount - int64(net), Currency: m.Currency}
+}
+
+// Decimal formats the amount in major units with the currency's number of decimals, e.g.
+// "120.50" for 12050 EUR or "1200" for 1200 JPY
+func (m Money) Decimal() string {
+	exp := Exponent(m.Currency)
+	sign, amount := "", m.Amount
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	if exp == 0 {
+		return sign + strconv.FormatInt(amount, 10)
+	}
+	unit := int64(math.Pow10(exp))
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/unit, exp, amount%unit)
+}
+
+// String formats the amount with its currency code, e.g. "120.50 EUR"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// exponents lists the currencies whose minor unit is not a hundredth of the major unit
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Exponent is the number of decimals of a currency's major unit: how many digits of an
+// amount in minor units come after the decimal point
+func Exponent(currency string) int {
+	if exp, ok := exponents[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+	return 2
+}
@@ -0,0 +1,98 @@
+// Medical Appointment Booking System - No-Show Risk Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package noshow
+
+import (
+	"math"
+	"time"
+)
+
+// Risk levels
+const (
+	LevelLow    = "LOW"
+	LevelMedium = "MEDIUM"
+	LevelHigh   = "HIGH"
+)
+
+// Input is the booking history and context the score is computed from
+type Input struct {
+	PriorAttended  int
+	PriorNoShows   int
+	LeadTime       time.Duration // time between booking and appointment start
+	LocalHour      int           // appointment start hour in the employee's timezone
+	BookingChannel string
+}
+
+// Risk is the scored no-show likelihood for one appointment
+type Risk struct {
+	Score   float64  `json:"score"`
+	Level   string   `json:"level"`
+	Factors []string `json:"factors"`
+}
+
+// Patient history is blended with priorWeight "virtual" appointments at baseRate, so a
+// single missed visit doesn't mark a new patient as high risk
+const (
+	baseRate    = 0.08
+	priorWeight = 5.0
+)
+
+// Score computes a simple, explainable no-show risk between 0 and 1
+func Score(in Input) Risk {
+	risk := Risk{Factors: []string{}}
+
+	past := float64(in.PriorAttended + in.PriorNoShows)
+	score := (float64(in.PriorNoShows) + baseRate*priorWeight) / (past + priorWeight)
+	if in.PriorNoShows > 0 {
+		risk.Factors = append(risk.Factors, "previous no-shows")
+	}
+
+	switch {
+	case in.LeadTime > 14*24*time.Hour:
+		score += 0.10
+		risk.Factors = append(risk.Factors, "booked more than two weeks ahead")
+	case in.LeadTime > 7*24*time.Hour:
+		score += 0.05
+		risk.Factors = append(risk.Factors, "booked more than a week ahead")
+	case in.LeadTime < 24*time.Hour:
+		score -= 0.03
+	}
+
+	if in.LocalHour < 9 || in.LocalHour >= 17 {
+		score += 0.04
+		risk.Factors = append(risk.Factors, "early or late appointment hour")
+	}
+
+	switch in.BookingChannel {
+	case "WEB", "PORTAL":
+		score += 0.03
+		risk.Factors = append(risk.Factors, "self-booked online")
+	case "WALK_IN":
+		score -= 0.05
+	}
+
+	risk.Score = math.Round(math.Min(math.Max(score, 0), 1)*100) / 100
+	switch {
+	case risk.Score >= 0.30:
+		risk.Level = LevelHigh
+	case risk.Score >= 0.15:
+		risk.Level = LevelMedium
+	default:
+		risk.Level = LevelLow
+	}
+	return risk
+}
@@ -0,0 +1,182 @@
+// Medical Appointment Booking System - Notifications Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notifications
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"strings"
+
+	"bookings/auth"
+	"bookings/database"
+	"bookings/models"
+	"bookings/timeutil"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Appointment events that send confirmation emails; each has a template of the same name
+const (
+	EventBooked    = "booked"
+	EventUpdated   = "updated"
+	EventCancelled = "cancelled"
+)
+
+var eventSubjects = map[string]string{
+	EventBooked:    "Appointment confirmed",
+	EventUpdated:   "Appointment changed",
+	EventCancelled: "Appointment cancelled",
+}
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var emailTemplates = template.Must(template.ParseFS(templateFiles, "templates/*.html"))
+
+// confirmationData fills the email templates
+type confirmationData struct {
+	RecipientName string
+	ForEmployee   bool
+	PatientName   string
+	Service       string
+	Employee      string
+	Clinic        string
+	Start         string
+	End           string
+	Reference     string
+	Reason        string
+	// CheckInCode is set when the email carries the kiosk check-in QR code
+	CheckInCode bool
+}
+
+// checkInQRSize is the width and height of the check-in QR code image, in pixels
+const checkInQRSize = 256
+
+// AppointmentEmails builds the confirmation emails for an appointment event: one to the
+// patient and one to the assigned employee, skipping either without an email address and
+// the patient's when their notification preferences rule out email. Times are shown in the
+// employee's timezone. While the appointment is scheduled or confirmed, the patient's email
+// has the signed QR code for the check-in kiosk attached.
+func AppointmentEmails(ctx context.Context, event string, appointment *models.Appointment, patient *models.Patient, prefs *models.NotificationPreferences, employee *models.Employee, service *models.Service, clinic *models.Clinic) ([]Message, error) {
+	subject, ok := eventSubjects[event]
+	if !ok {
+		return nil, fmt.Errorf("unknown appointment event %q", event)
+	}
+
+	data := confirmationData{
+		PatientName: patient.FirstName + " " + patient.LastName,
+		Service:     service.Name,
+		Employee:    employee.FirstName + " " + employee.LastName,
+		Clinic:      clinic.Name,
+		Start:       timeutil.FormatIn(appointment.StartDatetime, employee.Timezone),
+		End:         timeutil.FormatIn(appointment.EndDatetime, employee.Timezone),
+		Reference:   appointment.PublicID,
+	}
+	if appointment.CancellationReason != nil {
+		data.Reason = *appointment.CancellationReason
+	}
+	text := fmt.Sprintf("%s: %s with %s at %s, %s (reference %s).",
+		subject, data.Service, data.Employee, data.Clinic, data.Start, data.Reference)
+
+	patientEmail := patient.Email
+	if !Allows(prefs, ChannelEmail) {
+		patientEmail = ""
+	}
+
+	var checkIn *Attachment
+	if event != EventCancelled && (appointment.Status == "SCHEDULED" || appointment.Status == "CONFIRMED") && patientEmail != "" {
+		png, err := qrcode.Encode(auth.SignedToken(auth.PurposeCheckIn, appointment.PublicID), qrcode.Medium, checkInQRSize)
+		if err != nil {
+			return nil, err
+		}
+		checkIn = &Attachment{Filename: "check-in.png", ContentType: "image/png", Data: png}
+	}
+
+	var msgs []Message
+	recipients := []struct {
+		email, name string
+		employee    bool
+	}{
+		{patientEmail, data.PatientName, false},
+		{employee.Email, data.Employee, true},
+	}
+	for _, r := range recipients {
+		if r.email == "" {
+			continue
+		}
+		data.RecipientName, data.ForEmployee = r.name, r.employee
+		data.CheckInCode = !r.employee && checkIn != nil
+		var html strings.Builder
+		if err := emailTemplates.ExecuteTemplate(&html, event, data); err != nil {
+			return nil, err
+		}
+		msg := Message{Channel: ChannelEmail, Recipient: r.email, Subject: subject, Body: text, HTML: html.String(),
+			Template: "appointment_" + event, AppointmentID: &appointment.ID}
+		if !r.employee {
+			msg = ForPatient(ctx, msg, patient, prefs)
+		}
+		if data.CheckInCode {
+			msg.Body += " Scan the attached QR code at the check-in kiosk when you arrive."
+			msg.Attachments = []Attachment{*checkIn}
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// SendAppointmentEmails loads the records an appointment refers to and sends the event's
+// confirmation emails. Failures are logged rather than returned, since the change to the
+// appointment has already been saved.
+func SendAppointmentEmails(ctx context.Context, sender Sender, event string, appointment *models.Appointment) {
+	msgs, err := loadAppointmentEmails(ctx, event, appointment)
+	if err != nil {
+		slog.ErrorContext(ctx, "confirmation email: loading recipients", "appointment_id", appointment.ID, "error", err)
+		return
+	}
+	for _, msg := range msgs {
+		if err := sender.Send(ctx, msg); err != nil {
+			slog.ErrorContext(ctx, "confirmation email: sending", "appointment_id", appointment.ID, "recipient", msg.Recipient, "error", err)
+		}
+	}
+}
+
+func loadAppointmentEmails(ctx context.Context, event string, appointment *models.Appointment) ([]Message, error) {
+	patient, err := database.GetPatient(ctx, appointment.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	employee, err := database.GetEmployee(ctx, appointment.EmployeeID)
+	if err != nil {
+		return nil, err
+	}
+	service, err := database.GetService(ctx, appointment.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+	clinic, err := database.GetClinic(ctx, appointment.ClinicID)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := database.GetNotificationPreferences(ctx, appointment.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	return AppointmentEmails(ctx, event, appointment, patient, prefs, employee, service, clinic)
+}
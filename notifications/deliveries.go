@@ -0,0 +1,194 @@
+// Medical Appointment Booking System - Notifications Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/textproto"
+	"time"
+
+	"bookings/database"
+	"bookings/models"
+)
+
+// Templates name the kinds of message in the delivery log. Confirmation emails use
+// "appointment_" followed by their event.
+const (
+	TemplateReminder           = "reminder"
+	TemplateEscalation         = "appointment_escalation"
+	TemplateRescheduled        = "appointment_rescheduled"
+	TemplateSeries             = "appointment_series"
+	TemplateWaitlistOffer      = "waitlist_offer"
+	TemplateWaitlistEscalation = "waitlist_escalation"
+	TemplateUnpaidCancellation = "unpaid_cancellation"
+	TemplateReceipt            = "receipt"
+	TemplatePaymentLink        = "payment_link"
+)
+
+// MaxAttempts is how many times a message is tried before it is marked FAILED
+const MaxAttempts = 5
+
+// AttemptLease is how long a message being sent is kept from the retry job. A message whose
+// sender crashed mid-attempt is picked up again once it runs out.
+const AttemptLease = time.Minute
+
+// maxErrorLength bounds the error kept for a failed attempt
+const maxErrorLength = 500
+
+// RetryDelay is how long to wait after the given failed attempt (1 for the first) before the
+// next: a minute, doubling each time
+func RetryDelay(attempt int) time.Duration {
+	return time.Minute << (attempt - 1)
+}
+
+// ProviderError is a delivery refused by a provider's API, with the HTTP status it answered
+type ProviderError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ProviderError) Error() string {
+	return e.Message
+}
+
+// Transient reports whether a failed delivery is worth retrying: network errors, temporary
+// (4xx) SMTP replies, and provider answers of 429 or 5xx. Anything else, such as an invalid
+// recipient, fails the same way every time.
+func Transient(err error) bool {
+	var provider *ProviderError
+	if errors.As(err, &provider) {
+		return provider.StatusCode == http.StatusTooManyRequests || provider.StatusCode >= 500
+	}
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code >= 400 && reply.Code < 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// DeliveryLog records every message in the notifications table as it is sent through Sender,
+// with the provider's message id or the error. A transient failure is kept for the retry job
+// (see RetryDue) and reported to the caller as sent, since it will be delivered later; only a
+// permanent one is returned. When the log cannot be written the message is still sent.
+type DeliveryLog struct {
+	Sender Sender
+}
+
+func (l *DeliveryLog) Send(ctx context.Context, msg Message) error {
+	entry := models.Notification{
+		AppointmentID: msg.AppointmentID,
+		PatientID:     msg.PatientID,
+		Channel:       msg.Channel,
+		Recipient:     msg.Recipient,
+		Template:      msg.Template,
+		Subject:       msg.Subject,
+	}
+	if entry.Template == "" {
+		entry.Template = "general"
+	}
+	payload, err := json.Marshal(msg)
+	if err == nil {
+		err = database.CreateNotification(ctx, &entry, string(payload), time.Now().Add(AttemptLease))
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "delivery log: recording notification", "channel", msg.Channel, "template", entry.Template, "error", err)
+		return l.Sender.Send(ctx, msg)
+	}
+	_, err = l.attempt(ctx, entry.ID, 0, msg)
+	return err
+}
+
+// attempt delivers a logged message after the given number of earlier attempts and records
+// the outcome. It reports whether the message was delivered; the error is only returned when
+// it will not be retried.
+func (l *DeliveryLog) attempt(ctx context.Context, id, attempts int, msg Message) (bool, error) {
+	attemptedAt := time.Now()
+	providerMessageID, err := deliver(ctx, l.Sender, msg)
+
+	var messageID, errMessage *string
+	var retryAt *time.Time
+	if err == nil {
+		if providerMessageID != "" {
+			messageID = &providerMessageID
+		}
+	} else {
+		message := err.Error()
+		if len(message) > maxErrorLength {
+			message = message[:maxErrorLength]
+		}
+		errMessage = &message
+		if Transient(err) && attempts+1 < MaxAttempts {
+			next := attemptedAt.Add(RetryDelay(attempts + 1))
+			retryAt = &next
+		}
+	}
+	if recErr := database.RecordNotificationAttempt(ctx, id, attemptedAt, messageID, errMessage, retryAt); recErr != nil {
+		slog.ErrorContext(ctx, "delivery log: recording attempt", "notification_id", id, "error", recErr)
+	}
+	if retryAt != nil {
+		slog.WarnContext(ctx, "delivery log: delivery failed, will retry", "notification_id", id, "retry_at", retryAt, "error", err)
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RetryDue claims up to limit logged messages whose retry is due and attempts each once more.
+// It returns how many were delivered. Senders without a delivery log have nothing to retry.
+func RetryDue(ctx context.Context, s Sender, now time.Time, limit int) (int, error) {
+	l, ok := s.(*DeliveryLog)
+	if !ok {
+		return 0, nil
+	}
+	// The lease covers every claimed message taking its full lease in turn
+	due, err := database.ClaimNotifications(ctx, now, now.Add(time.Duration(limit+1)*AttemptLease), limit)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, d := range due {
+		var msg Message
+		if err := json.Unmarshal([]byte(d.Payload), &msg); err != nil {
+			message := "unreadable message: " + err.Error()
+			if err := database.RecordNotificationAttempt(ctx, d.ID, now, nil, &message, nil); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		sent, err := l.attempt(ctx, d.ID, d.Attempts, msg)
+		if err != nil {
+			slog.ErrorContext(ctx, "delivery log: retry failed", "notification_id", d.ID, "error", err)
+		}
+		if sent {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// unwrap returns the sender a delivery log sends through, or s itself
+func unwrap(s Sender) Sender {
+	if l, ok := s.(*DeliveryLog); ok {
+		return l.Sender
+	}
+	return s
+}
@@ -0,0 +1,188 @@
+// Medical Appointment Booking System - Notifications Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notifications
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSMTPPort is used when SMTP_PORT is not set
+const DefaultSMTPPort = 587
+
+// SMTPConfig is the mail server emails are delivered through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// EmailEnabled reports whether emails are delivered. EMAIL_NOTIFICATIONS_ENABLED turns
+// delivery on or off per environment; when it is not set, emails are delivered whenever
+// SMTP_HOST is configured.
+func EmailEnabled() (bool, error) {
+	raw := os.Getenv("EMAIL_NOTIFICATIONS_ENABLED")
+	if raw == "" {
+		return os.Getenv("SMTP_HOST") != "", nil
+	}
+	enabled, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid EMAIL_NOTIFICATIONS_ENABLED %q", raw)
+	}
+	return enabled, nil
+}
+
+// SMTPConfigFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM
+func SMTPConfigFromEnv() (SMTPConfig, error) {
+	config := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     DefaultSMTPPort,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+	if config.Host == "" {
+		return config, errors.New("SMTP_HOST environment variable is not set")
+	}
+	if config.From == "" {
+		return config, errors.New("SMTP_FROM environment variable is not set")
+	}
+	if raw := os.Getenv("SMTP_PORT"); raw != "" {
+		port, err := strconv.Atoi(raw)
+		if err != nil || port <= 0 {
+			return config, fmt.Errorf("invalid SMTP_PORT %q", raw)
+		}
+		config.Port = port
+	}
+	return config, nil
+}
+
+// EmailSender delivers EMAIL messages over SMTP, as HTML when the message has an HTML body
+type EmailSender struct {
+	Config SMTPConfig
+}
+
+// Ping checks the SMTP server accepts connections
+func (s EmailSender) Ping(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.Config.Host, strconv.Itoa(s.Config.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// writeMultipart writes a multipart/mixed message body: the text part, then each attachment
+// base64-encoded
+func writeMultipart(b *strings.Builder, contentType, body string, attachments []Attachment) {
+	boundary := "bookings-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	fmt.Fprintf(b, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(b, "--%s\r\nContent-Type: %s; charset=UTF-8\r\n\r\n%s\r\n", boundary, contentType, body)
+	for _, a := range attachments {
+		fmt.Fprintf(b, "--%s\r\n", boundary)
+		fmt.Fprintf(b, "Content-Type: %s\r\n", a.ContentType)
+		b.WriteString("Content-Transfer-Encoding: base64\r\n")
+		fmt.Fprintf(b, "Content-Disposition: %s\r\n\r\n", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			b.WriteString(encoded[:76] + "\r\n")
+			encoded = encoded[76:]
+		}
+		b.WriteString(encoded + "\r\n")
+	}
+	fmt.Fprintf(b, "--%s--\r\n", boundary)
+}
+
+func (s EmailSender) Send(ctx context.Context, msg Message) error {
+	_, err := s.Deliver(ctx, msg)
+	return err
+}
+
+// Deliver returns the Message-ID the email was sent with, since SMTP servers do not report one
+func (s EmailSender) Deliver(ctx context.Context, msg Message) (string, error) {
+	if msg.Channel != ChannelEmail {
+		return "", fmt.Errorf("email sender cannot deliver %s messages", msg.Channel)
+	}
+	messageID, err := s.messageID()
+	if err != nil {
+		return "", err
+	}
+	contentType, body := "text/plain", msg.Body
+	if msg.HTML != "" {
+		contentType, body = "text/html", msg.HTML
+	}
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.Config.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.Recipient)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Message-ID: %s\r\n", messageID)
+	if msg.Language != "" {
+		fmt.Fprintf(&b, "Content-Language: %s\r\n", msg.Language)
+	}
+	if msg.Unsubscribe != "" {
+		fmt.Fprintf(&b, "List-Unsubscribe: <%s>\r\n", msg.Unsubscribe)
+		b.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if len(msg.Attachments) == 0 {
+		fmt.Fprintf(&b, "Content-Type: %s; charset=UTF-8\r\n\r\n", contentType)
+		b.WriteString(body)
+	} else {
+		writeMultipart(&b, contentType, body, msg.Attachments)
+	}
+
+	var auth smtp.Auth
+	if s.Config.Username != "" {
+		auth = smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)
+	}
+	addr := net.JoinHostPort(s.Config.Host, strconv.Itoa(s.Config.Port))
+	if err := smtp.SendMail(addr, auth, s.Config.From, []string{msg.Recipient}, []byte(b.String())); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
+
+// messageID generates a unique Message-ID in the domain of the from address
+func (s EmailSender) messageID() (string, error) {
+	domain := s.Config.Host
+	if addr, err := mail.ParseAddress(s.Config.From); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">", nil
+}
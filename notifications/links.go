@@ -0,0 +1,44 @@
+// Medical Appointment Booking System - Notifications Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notifications
+
+import (
+	"context"
+
+	"bookings/auth"
+	"bookings/tenant"
+)
+
+// PublicBaseURL is the address the organization ctx works for is reachable at from outside,
+// e.g. https://bookings.example.com, or its subdomain of it for organizations other than the
+// default; "" when PUBLIC_BASE_URL is not set
+func PublicBaseURL(ctx context.Context) string {
+	return tenant.URL(ctx)
+}
+
+// AppointmentLinks builds the signed URLs a patient follows to confirm or cancel an
+// appointment with one click. They are rooted at PublicBaseURL; without it there are no
+// links and both are "".
+func AppointmentLinks(ctx context.Context, publicID string) (confirmURL, cancelURL string) {
+	base := PublicBaseURL(ctx)
+	if base == "" {
+		return "", ""
+	}
+	base += "/api/v1/appointments/"
+	return base + "confirm/" + auth.SignedToken(auth.PurposeConfirm, publicID),
+		base + "cancel/" + auth.SignedToken(auth.PurposeCancel, publicID)
+}
@@ -0,0 +1,185 @@
+// Medical Appointment Booking System - Notifications Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notifications
+
+import (
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"bookings/models"
+	"bookings/timeutil"
+)
+
+// Notification kinds
+const (
+	KindReminder   = "REMINDER"
+	KindEscalation = "ESCALATION"
+)
+
+// Delivery channels
+const (
+	ChannelEmail = "EMAIL"
+	ChannelSMS   = "SMS"
+)
+
+// Planned notification statuses
+const (
+	StatusScheduled = "SCHEDULED"
+	StatusSkipped   = "SKIPPED"
+)
+
+// DefaultReminderLeadTimes is used when REMINDER_LEAD_TIMES is not set
+var DefaultReminderLeadTimes = []time.Duration{24 * time.Hour, 2 * time.Hour}
+
+// HighRiskReminderLeadTime is the extra reminder sent for high no-show risk bookings
+// when the clinic has enabled targeted reminders
+const HighRiskReminderLeadTime = 48 * time.Hour
+
+// EscalationLeadTime is how long before the start an appointment that is still
+// unconfirmed gets escalated to the clinic's staff
+const EscalationLeadTime = 12 * time.Hour
+
+// PlannedNotification is a single message the notification subsystem intends to send
+type PlannedNotification struct {
+	Kind        string    `json:"kind"`
+	Channel     string    `json:"channel"`
+	Recipient   string    `json:"recipient"`
+	ScheduledAt time.Time `json:"scheduled_at"`
+	Status      string    `json:"status"`
+	Reason      string    `json:"reason,omitempty"`
+}
+
+// Plan lists every reminder and escalation for an appointment, including the ones
+// that will not be sent and why
+type Plan struct {
+	AppointmentID int                   `json:"appointment_id"`
+	GeneratedAt   time.Time             `json:"generated_at"`
+	Notifications []PlannedNotification `json:"notifications"`
+}
+
+// ReminderLeadTimes returns the configured reminder lead times, read from the
+// comma-separated REMINDER_LEAD_TIMES environment variable (e.g. "24h,2h")
+func ReminderLeadTimes() []time.Duration {
+	raw := os.Getenv("REMINDER_LEAD_TIMES")
+	if raw == "" {
+		return DefaultReminderLeadTimes
+	}
+
+	var leadTimes []time.Duration
+	for _, part := range strings.Split(raw, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || d <= 0 {
+			continue
+		}
+		leadTimes = append(leadTimes, d)
+	}
+	if len(leadTimes) == 0 {
+		return DefaultReminderLeadTimes
+	}
+	return leadTimes
+}
+
+// BuildPlan works out which notifications an appointment will receive given the
+// clinic's settings, the patient's contact details and notification preferences, and
+// whether the booking has a high no-show risk. A patient's reminder lead time replaces the
+// configured ones, and reminders due in their quiet hours (in timezone, the employee's) are
+// sent when the quiet hours begin instead.
+func BuildPlan(appointment *models.Appointment, patient *models.Patient, prefs *models.NotificationPreferences, clinic *models.Clinic, timezone string, highRisk bool, now time.Time) Plan {
+	plan := Plan{
+		AppointmentID: appointment.ID,
+		GeneratedAt:   now.UTC(),
+		Notifications: []PlannedNotification{},
+	}
+
+	leadTimes := ReminderLeadTimes()
+	if prefs != nil && prefs.ReminderLeadMinutes != nil {
+		leadTimes = []time.Duration{time.Duration(*prefs.ReminderLeadMinutes) * time.Minute}
+	}
+	if highRisk && clinic.HighRiskExtraReminders && !slices.Contains(leadTimes, HighRiskReminderLeadTime) {
+		leadTimes = append(leadTimes, HighRiskReminderLeadTime)
+	}
+
+	loc := timeutil.LoadLocation(timezone)
+	for _, lead := range leadTimes {
+		at := outsideQuietHours(appointment.StartDatetime.Add(-lead).UTC(), prefs, loc)
+		plan.Notifications = append(plan.Notifications,
+			reminder(appointment, ChannelEmail, patient.Email, enabled(clinic.EmailRemindersEnabled), prefs, at, now),
+			reminder(appointment, ChannelSMS, patient.Phone, enabled(clinic.SMSRemindersEnabled), prefs, at, now))
+	}
+
+	escalation := PlannedNotification{
+		Kind:        KindEscalation,
+		Channel:     ChannelEmail,
+		Recipient:   clinic.Email,
+		ScheduledAt: appointment.StartDatetime.Add(-EscalationLeadTime).UTC(),
+		Status:      StatusScheduled,
+	}
+	switch {
+	case appointment.Status != "SCHEDULED":
+		escalation.Status, escalation.Reason = StatusSkipped, "only unconfirmed appointments are escalated"
+	case clinic.Email == "":
+		escalation.Status, escalation.Reason = StatusSkipped, "clinic has no email address"
+	case escalation.ScheduledAt.Before(now):
+		escalation.Status, escalation.Reason = StatusSkipped, "send time has already passed"
+	}
+	plan.Notifications = append(plan.Notifications, escalation)
+
+	sort.SliceStable(plan.Notifications, func(i, j int) bool {
+		return plan.Notifications[i].ScheduledAt.Before(plan.Notifications[j].ScheduledAt)
+	})
+	return plan
+}
+
+func reminder(appointment *models.Appointment, channel, recipient string, channelEnabled bool, prefs *models.NotificationPreferences, at, now time.Time) PlannedNotification {
+	n := PlannedNotification{
+		Kind:        KindReminder,
+		Channel:     channel,
+		Recipient:   recipient,
+		ScheduledAt: at,
+		Status:      StatusScheduled,
+	}
+	switch {
+	case appointment.Status != "SCHEDULED" && appointment.Status != "CONFIRMED":
+		n.Status, n.Reason = StatusSkipped, "appointment is "+appointment.Status
+	case !channelEnabled:
+		n.Status, n.Reason = StatusSkipped, strings.ToLower(channel)+" reminders are disabled for this clinic"
+	case prefs != nil && prefs.Channel == PreferNone:
+		n.Status, n.Reason = StatusSkipped, "patient has opted out of notifications"
+	case !Allows(prefs, channel):
+		n.Status, n.Reason = StatusSkipped, "patient prefers "+strings.ToLower(prefs.Channel)+" only"
+	case recipient == "":
+		n.Status, n.Reason = StatusSkipped, "patient has no "+contactField(channel)
+	case at.Before(now):
+		n.Status, n.Reason = StatusSkipped, "send time has already passed"
+	}
+	return n
+}
+
+func contactField(channel string) string {
+	if channel == ChannelSMS {
+		return "phone number"
+	}
+	return "email address"
+}
+
+// enabled treats an unset clinic setting as on, matching the column default
+func enabled(setting *bool) bool {
+	return setting == nil || *setting
+}
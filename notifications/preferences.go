@@ -0,0 +1,100 @@
+// Medical Appointment Booking System - Notifications Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notifications
+
+import (
+	"context"
+	"time"
+
+	"bookings/auth"
+	"bookings/models"
+	"bookings/timeutil"
+)
+
+// Notification preference channels
+const (
+	PreferAll   = "ALL"
+	PreferEmail = "EMAIL"
+	PreferSMS   = "SMS"
+	PreferNone  = "NONE"
+)
+
+// MaxReminderLead is the longest reminder lead time a patient may ask for
+const MaxReminderLead = 14 * 24 * time.Hour
+
+// Allows reports whether a patient's preferences let them be notified on channel; without
+// stored preferences every channel is allowed
+func Allows(prefs *models.NotificationPreferences, channel string) bool {
+	if prefs == nil {
+		return true
+	}
+	return prefs.Channel == PreferAll || prefs.Channel == channel
+}
+
+// ForPatient personalizes a message to a patient: it carries their preferred language and,
+// when PUBLIC_BASE_URL is set, their unsubscribe link, and is logged against them
+func ForPatient(ctx context.Context, msg Message, patient *models.Patient, prefs *models.NotificationPreferences) Message {
+	msg.PatientID = &patient.ID
+	if prefs != nil && prefs.PreferredLanguage != nil {
+		msg.Language = *prefs.PreferredLanguage
+	}
+	msg.Unsubscribe = UnsubscribeURL(ctx, patient.PublicID)
+	return msg
+}
+
+// UnsubscribeURL builds the signed URL a patient follows to turn off their notifications;
+// "" without PUBLIC_BASE_URL
+func UnsubscribeURL(ctx context.Context, patientPublicID string) string {
+	base := PublicBaseURL(ctx)
+	if base == "" || patientPublicID == "" {
+		return ""
+	}
+	return base + "/api/v1/unsubscribe/" + auth.SignedToken(auth.PurposeUnsubscribe, patientPublicID)
+}
+
+// outsideQuietHours moves a send time that falls in the patient's quiet hours back to when
+// they begin, so the message goes out before rather than during them. Quiet hours are wall
+// clock times in loc; an end at or before the start runs past midnight.
+func outsideQuietHours(at time.Time, prefs *models.NotificationPreferences, loc *time.Location) time.Time {
+	if prefs == nil || prefs.QuietHoursStart == nil || prefs.QuietHoursEnd == nil {
+		return at
+	}
+	startHour, startMinute, err := timeutil.ParseClock(*prefs.QuietHoursStart)
+	if err != nil {
+		return at
+	}
+	endHour, endMinute, err := timeutil.ParseClock(*prefs.QuietHoursEnd)
+	if err != nil {
+		return at
+	}
+	wraps := endHour*60+endMinute <= startHour*60+startMinute
+
+	// A window that runs past midnight may have begun the local day before
+	date := timeutil.LocalDate(at, loc)
+	for _, day := range []time.Time{date.AddDate(0, 0, -1), date} {
+		start := timeutil.WallClock(day, startHour, startMinute, loc)
+		endDay := day
+		if wraps {
+			endDay = day.AddDate(0, 0, 1)
+		}
+		end := timeutil.WallClock(endDay, endHour, endMinute, loc)
+		if !at.Before(start) && at.Before(end) {
+			return start.UTC()
+		}
+	}
+	return at
+}
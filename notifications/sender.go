@@ -0,0 +1,158 @@
+// Medical Appointment Booking System - Notifications Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notifications
+
+import (
+	"context"
+	"log/slog"
+
+	"bookings/models"
+)
+
+// Message is a single notification ready for delivery. HTML is an optional rich version
+// of Body for channels that can show it; attachments are only sent by email.
+type Message struct {
+	Channel     string
+	Recipient   string
+	Subject     string
+	Body        string
+	HTML        string
+	Attachments []Attachment
+	// Language is the recipient's preferred language (a BCP 47 tag), when known
+	Language string
+	// Unsubscribe is the URL that turns off the recipient's notifications; email sends it
+	// as List-Unsubscribe
+	Unsubscribe string
+	// Template names the kind of message in the delivery log, e.g. "reminder"
+	Template string
+	// AppointmentID and PatientID link the message to the records it is about in the
+	// delivery log, when it is about one
+	AppointmentID *int
+	PatientID     *int
+}
+
+// Attachment is a file sent with an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Sender delivers messages over a channel provider
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Deliverer is implemented by senders that report the provider's id for each message they
+// deliver
+type Deliverer interface {
+	Deliver(ctx context.Context, msg Message) (providerMessageID string, err error)
+}
+
+// deliver sends msg, returning the provider's message id when the sender reports one
+func deliver(ctx context.Context, s Sender, msg Message) (string, error) {
+	if d, ok := s.(Deliverer); ok {
+		return d.Deliver(ctx, msg)
+	}
+	return "", s.Send(ctx, msg)
+}
+
+// LogSender writes messages to the server log instead of delivering them. It is the
+// default until a delivery provider is configured.
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "notification", "channel", msg.Channel, "recipient", msg.Recipient, "subject", msg.Subject, "body", msg.Body,
+		"attachments", len(msg.Attachments))
+	return nil
+}
+
+// Router delivers each message with the sender configured for its channel and logs
+// messages for channels that have none
+type Router map[string]Sender
+
+func (r Router) Send(ctx context.Context, msg Message) error {
+	_, err := r.Deliver(ctx, msg)
+	return err
+}
+
+func (r Router) Deliver(ctx context.Context, msg Message) (string, error) {
+	if sender, ok := r[msg.Channel]; ok {
+		return deliver(ctx, sender, msg)
+	}
+	return "", LogSender{}.Send(ctx, msg)
+}
+
+// Pinger is implemented by senders and providers that can check their service is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Pingers returns the reachability checks of the delivery services behind a sender, keyed
+// by channel. Channels that only log their messages have none.
+func Pingers(s Sender) map[string]Pinger {
+	pingers := map[string]Pinger{}
+	router, ok := unwrap(s).(Router)
+	if !ok {
+		return pingers
+	}
+	for channel, sender := range router {
+		if p, ok := sender.(Pinger); ok {
+			pingers[channel] = p
+		}
+	}
+	return pingers
+}
+
+// NewSender builds the sender the server delivers notifications with from the environment.
+// Channels that are not configured or are disabled are logged instead. Every message goes
+// through the delivery log.
+func NewSender() (Sender, error) {
+	router := Router{}
+	emailEnabled, err := EmailEnabled()
+	if err != nil {
+		return nil, err
+	}
+	if emailEnabled {
+		config, err := SMTPConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		router[ChannelEmail] = EmailSender{Config: config}
+	}
+	provider, err := SMSProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if provider != nil {
+		router[ChannelSMS] = SMSSender{Provider: provider}
+	}
+	return &DeliveryLog{Sender: router}, nil
+}
+
+// PatientMessages addresses the same notification to every contact channel the patient has
+// and their preferences allow
+func PatientMessages(ctx context.Context, patient *models.Patient, prefs *models.NotificationPreferences, template, subject, body string) []Message {
+	var msgs []Message
+	if patient.Email != "" && Allows(prefs, ChannelEmail) {
+		msgs = append(msgs, ForPatient(ctx, Message{Channel: ChannelEmail, Recipient: patient.Email, Subject: subject, Body: body, Template: template}, patient, prefs))
+	}
+	if patient.Phone != "" && Allows(prefs, ChannelSMS) {
+		msgs = append(msgs, ForPatient(ctx, Message{Channel: ChannelSMS, Recipient: patient.Phone, Subject: subject, Body: body, Template: template}, patient, prefs))
+	}
+	return msgs
+}
@@ -0,0 +1,201 @@
+// Medical Appointment Booking System - Notifications Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package notifications
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SMSProvider sends a text message to a phone number, returning the provider's id for the
+// message. Providers are chosen with SMS_PROVIDER.
+type SMSProvider interface {
+	SendSMS(ctx context.Context, to, body string) (string, error)
+}
+
+// SMSSender delivers SMS messages through a provider
+type SMSSender struct {
+	Provider SMSProvider
+}
+
+func (s SMSSender) Send(ctx context.Context, msg Message) error {
+	_, err := s.Deliver(ctx, msg)
+	return err
+}
+
+func (s SMSSender) Deliver(ctx context.Context, msg Message) (string, error) {
+	if msg.Channel != ChannelSMS {
+		return "", fmt.Errorf("SMS sender cannot deliver %s messages", msg.Channel)
+	}
+	return s.Provider.SendSMS(ctx, msg.Recipient, msg.Body)
+}
+
+// Ping checks the provider is reachable, when it supports a check
+func (s SMSSender) Ping(ctx context.Context) error {
+	if p, ok := s.Provider.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// InboundVerifier is implemented by SMS providers that post patients' replies to the
+// inbound SMS webhook
+type InboundVerifier interface {
+	// VerifyInbound reports whether a webhook request to requestURL carrying form was
+	// signed by the provider
+	VerifyInbound(requestURL string, form url.Values, signature string) bool
+}
+
+// InboundSMS returns the verifier of the provider a sender delivers SMS through, or nil
+// when SMS is not sent through a provider that accepts replies
+func InboundSMS(s Sender) InboundVerifier {
+	router, ok := unwrap(s).(Router)
+	if !ok {
+		return nil
+	}
+	sms, ok := router[ChannelSMS].(SMSSender)
+	if !ok {
+		return nil
+	}
+	verifier, _ := sms.Provider.(InboundVerifier)
+	return verifier
+}
+
+// SMSProviderFromEnv returns the provider named by SMS_PROVIDER, or nil when it is not set
+func SMSProviderFromEnv() (SMSProvider, error) {
+	switch name := os.Getenv("SMS_PROVIDER"); name {
+	case "":
+		return nil, nil
+	case "twilio":
+		return TwilioFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown SMS_PROVIDER %q", name)
+	}
+}
+
+// twilioAPI is the base URL of Twilio's REST API
+const twilioAPI = "https://api.twilio.com/2010-04-01"
+
+// Twilio sends SMS through Twilio's Messages API
+type Twilio struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	Client     *http.Client
+}
+
+// TwilioFromEnv reads TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER
+func TwilioFromEnv() (*Twilio, error) {
+	t := &Twilio{
+		AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+		AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+		From:       os.Getenv("TWILIO_FROM_NUMBER"),
+		Client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	if t.AccountSID == "" || t.AuthToken == "" || t.From == "" {
+		return nil, errors.New("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER must be set for SMS_PROVIDER=twilio")
+	}
+	return t, nil
+}
+
+// Ping fetches the account, which checks both that Twilio is reachable and that the
+// credentials are accepted
+func (t *Twilio) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		twilioAPI+"/Accounts/"+url.PathEscape(t.AccountSID)+".json", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("twilio: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// VerifyInbound checks the X-Twilio-Signature of a webhook request: the base64 HMAC-SHA1,
+// keyed with the auth token, of the full URL Twilio called followed by every POST
+// parameter's name and value in order of name
+func (t *Twilio) VerifyInbound(requestURL string, form url.Values, signature string) bool {
+	var data strings.Builder
+	data.WriteString(requestURL)
+	for _, key := range slices.Sorted(maps.Keys(form)) {
+		values := slices.Clone(form[key])
+		slices.Sort(values)
+		for _, value := range values {
+			data.WriteString(key + value)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(t.AuthToken))
+	mac.Write([]byte(data.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// SendSMS returns the message's SID. Rate limiting and server errors are reported as a
+// ProviderError with the HTTP status, so they can be retried.
+func (t *Twilio) SendSMS(ctx context.Context, to, body string) (string, error) {
+	form := url.Values{"To": {to}, "From": {t.From}, "Body": {body}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		twilioAPI+"/Accounts/"+url.PathEscape(t.AccountSID)+"/Messages.json", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Message != "" {
+			return "", &ProviderError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("twilio: %s (code %d)", apiErr.Message, apiErr.Code)}
+		}
+		return "", &ProviderError{StatusCode: resp.StatusCode, Message: "twilio: unexpected status " + resp.Status}
+	}
+	var message struct {
+		SID string `json:"sid"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		return "", fmt.Errorf("twilio: reading response: %w", err)
+	}
+	return message.SID, nil
+}
@@ -0,0 +1,317 @@
+// Medical Appointment Booking System - Payments Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/audit"
+	"bookings/database"
+	"bookings/models"
+	"bookings/money"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNothingToPay is returned when an appointment has no outstanding amount to charge
+var ErrNothingToPay = errors.New("appointment has no outstanding amount")
+
+// Checkout is what the patient's browser needs to pay with Stripe.js
+type Checkout struct {
+	PaymentIntentID string      `json:"payment_intent_id"`
+	ClientSecret    string      `json:"client_secret"`
+	Amount          money.Money `json:"amount"`
+}
+
+// StartPayment returns the checkout for an appointment's outstanding payment_amount. The
+// appointment's open PaymentIntent is handed out again while it is still payable, so asking
+// twice does not charge twice.
+func (s *Stripe) StartPayment(ctx context.Context, appointment *models.Appointment) (*Checkout, error) {
+	if appointment.Status == "CANCELLED" || appointment.PaymentStatus != "PENDING" ||
+		appointment.PaymentAmount == nil || appointment.PaymentAmount.Amount <= 0 {
+		return nil, ErrNothingToPay
+	}
+	amount := *appointment.PaymentAmount
+
+	open, err := database.GetLatestCardPayment(ctx, appointment.ID, "PENDING", "FAILED")
+	switch {
+	case err == nil && open.Amount == amount:
+		intent, err := s.GetPaymentIntent(ctx, open.PaymentIntentID)
+		if err != nil {
+			return nil, err
+		}
+		if intent.Status != "canceled" && intent.Status != "succeeded" {
+			return &Checkout{PaymentIntentID: intent.ID, ClientSecret: intent.ClientSecret, Amount: open.Amount}, nil
+		}
+	case err != nil && !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	// The key changes with every edit of the appointment, so a changed amount gets a new intent
+	key := fmt.Sprintf("appointment-%d-v%d", appointment.ID, appointment.Version)
+	intent, err := s.CreatePaymentIntent(ctx, amount, appointment.ID, key)
+	if err != nil {
+		return nil, err
+	}
+	payment := models.CardPayment{AppointmentID: appointment.ID, PaymentIntentID: intent.ID, Amount: amount}
+	err = database.WithTx(ctx, func(ctx context.Context) error {
+		if err := database.CreateCardPayment(ctx, &payment); err != nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityCardPayments, payment.ID, audit.ActionCreate, payment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Checkout{PaymentIntentID: intent.ID, ClientSecret: intent.ClientSecret, Amount: amount}, nil
+}
+
+// StartLinkPayment returns the checkout for a payment link. The link's open PaymentIntent is
+// handed out again while it is still payable. A link that is paid, withdrawn or expired, or
+// whose payment has gone through but not been settled yet, returns
+// database.ErrPaymentLinkNotPayable.
+func (s *Stripe) StartLinkPayment(ctx context.Context, link *models.PaymentLink) (*Checkout, error) {
+	if link.Status != "PENDING" || !time.Now().Before(link.ExpiresAt) {
+		return nil, database.ErrPaymentLinkNotPayable
+	}
+
+	key := fmt.Sprintf("payment-link-%d", link.ID)
+	if link.PaymentIntentID != nil {
+		intent, err := s.GetPaymentIntent(ctx, *link.PaymentIntentID)
+		if err != nil {
+			return nil, err
+		}
+		switch intent.Status {
+		case "succeeded":
+			return nil, database.ErrPaymentLinkNotPayable
+		case "canceled":
+			key += "-after-" + intent.ID
+		default:
+			return &Checkout{PaymentIntentID: intent.ID, ClientSecret: intent.ClientSecret, Amount: link.Amount}, nil
+		}
+	}
+
+	intent, err := s.CreateLinkPaymentIntent(ctx, link.Amount, link.ID, key)
+	if err != nil {
+		return nil, err
+	}
+	err = database.WithTx(ctx, func(ctx context.Context) error {
+		if err := database.SetPaymentLinkIntent(ctx, link.ID, intent.ID); err != nil {
+			return err
+		}
+		link.PaymentIntentID = &intent.ID
+		return audit.Record(ctx, audit.EntityPaymentLinks, link.ID, audit.ActionUpdate, link)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Checkout{PaymentIntentID: intent.ID, ClientSecret: intent.ClientSecret, Amount: link.Amount}, nil
+}
+
+// CheckoutForBooking starts the payment of a new booking, returning nil when there is nothing
+// to pay. A nil Stripe does nothing. Failures are logged rather than returned: the booking
+// stands and the payment can be started again later.
+func (s *Stripe) CheckoutForBooking(ctx context.Context, appointment *models.Appointment) *Checkout {
+	if s == nil {
+		return nil
+	}
+	checkout, err := s.StartPayment(ctx, appointment)
+	if err != nil && !errors.Is(err, ErrNothingToPay) {
+		slog.ErrorContext(ctx, "payments: starting payment for new booking", "appointment_id", appointment.ID, "error", err)
+	}
+	return checkout
+}
+
+// RefundAfterCancellation refunds the card payment of an appointment cancelled at least
+// RefundNotice before its start; later cancellations keep the payment. appointment is the
+// booking as it was before the cancellation. A nil Stripe does nothing, and failures are
+// logged rather than returned.
+func (s *Stripe) RefundAfterCancellation(ctx context.Context, appointment *models.Appointment) {
+	if s == nil || time.Until(appointment.StartDatetime) < s.RefundNotice {
+		return
+	}
+	payment, err := database.GetLatestCardPayment(ctx, appointment.ID, "SUCCEEDED")
+	if errors.Is(err, pgx.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "payments: loading payment to refund", "appointment_id", appointment.ID, "error", err)
+		return
+	}
+	s.refund(ctx, payment)
+}
+
+// refund refunds a succeeded payment in full and records the refund, logging failures
+func (s *Stripe) refund(ctx context.Context, payment *models.CardPayment) {
+	refund, err := s.RefundPayment(ctx, payment.PaymentIntentID, "refund-"+payment.PaymentIntentID)
+	if err != nil {
+		slog.ErrorContext(ctx, "payments: refunding appointment", "appointment_id", payment.AppointmentID,
+			"payment_intent_id", payment.PaymentIntentID, "error", err)
+		return
+	}
+	// Most card refunds complete at once; the others are settled by the charge.refunded webhook
+	err = database.WithTx(ctx, func(ctx context.Context) error {
+		if refund.Status == "succeeded" {
+			refunded, err := database.MarkCardPaymentRefunded(ctx, payment.PaymentIntentID, &refund.ID)
+			if err != nil {
+				return err
+			}
+			payment = refunded
+		} else {
+			if err := database.SetCardPaymentRefundPending(ctx, payment.ID, refund.ID); err != nil {
+				return err
+			}
+			payment.Status, payment.RefundID = "REFUND_PENDING", &refund.ID
+		}
+		return audit.Record(ctx, audit.EntityCardPayments, payment.ID, audit.ActionUpdate, payment)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "payments: recording refund", "appointment_id", payment.AppointmentID, "refund_id", refund.ID, "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "payments: refunded appointment", "appointment_id", payment.AppointmentID, "refund_id", refund.ID)
+}
+
+// ApplyEvent applies a verified webhook event: a succeeded PaymentIntent marks its
+// appointment PAID, or settles the payment link it paid, a failed one is recorded, and a
+// fully refunded charge marks the appointment REFUNDED. A payment that arrives after its
+// appointment was cancelled, or that cannot settle its link, is refunded straight away.
+// Events are applied once however often Stripe delivers them; events about payments the
+// server did not start are ignored. It returns the card payment the event settled or
+// refunded, if any.
+func (s *Stripe) ApplyEvent(ctx context.Context, event *Event) (*models.CardPayment, error) {
+	// One webhook endpoint receives every organization's events; the payment's own metadata
+	// says whose it is. Payments started before organizations were stamped on them have none
+	// and belong to the default organization.
+	ctx = database.WithOrganization(ctx, eventOrganization(event))
+	var changed *models.CardPayment
+	var unsettled *PaymentIntent
+	succeeded := false
+	err := database.WithTx(ctx, func(ctx context.Context) error {
+		isNew, err := database.RecordStripeEvent(ctx, event.ID, event.Type)
+		if err != nil || !isNew {
+			return err
+		}
+
+		switch event.Type {
+		case "payment_intent.succeeded":
+			var intent PaymentIntent
+			if err := json.Unmarshal(event.Data.Object, &intent); err != nil {
+				return err
+			}
+			changed, err = database.MarkCardPaymentSucceeded(ctx, intent.ID)
+			succeeded = err == nil
+			if errors.Is(err, pgx.ErrNoRows) {
+				// Not an appointment's payment; it may have paid a payment link
+				err = settleLink(ctx, &intent, time.Unix(event.Created, 0))
+				if errors.Is(err, database.ErrPaymentLinkNotPayable) {
+					unsettled, err = &intent, nil
+				}
+			}
+		case "payment_intent.payment_failed":
+			var intent PaymentIntent
+			if err := json.Unmarshal(event.Data.Object, &intent); err != nil {
+				return err
+			}
+			err = database.MarkCardPaymentFailed(ctx, intent.ID)
+		case "charge.refunded":
+			var charge struct {
+				PaymentIntent string `json:"payment_intent"`
+				Refunded      bool   `json:"refunded"`
+			}
+			if err := json.Unmarshal(event.Data.Object, &charge); err != nil {
+				return err
+			}
+			// Partial refunds are issued by hand in the Stripe dashboard and leave the booking paid
+			if charge.Refunded {
+				changed, err = database.MarkCardPaymentRefunded(ctx, charge.PaymentIntent, nil)
+			}
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			changed, succeeded = nil, false
+			return nil
+		}
+		if err != nil || changed == nil {
+			return err
+		}
+		return audit.Record(ctx, audit.EntityCardPayments, changed.ID, audit.ActionUpdate, changed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if unsettled != nil {
+		s.refundLinkPayment(ctx, unsettled)
+	}
+	if changed == nil {
+		return nil, nil
+	}
+	if succeeded {
+		appointment, err := database.GetAppointment(ctx, changed.AppointmentID)
+		if err != nil {
+			slog.ErrorContext(ctx, "payments: loading paid appointment", "appointment_id", changed.AppointmentID, "error", err)
+		} else if appointment.Status == "CANCELLED" {
+			s.refund(ctx, changed)
+		}
+	}
+	return changed, nil
+}
+
+// eventOrganization returns the organization in the metadata of the PaymentIntent or charge
+// an event is about, or 0 when it names none. Stripe copies a PaymentIntent's metadata onto
+// its charges.
+func eventOrganization(event *Event) int {
+	var object struct {
+		Metadata struct {
+			OrganizationID string `json:"organization_id"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(event.Data.Object, &object); err != nil {
+		return 0
+	}
+	id, _ := strconv.Atoi(object.Metadata.OrganizationID)
+	return id
+}
+
+// settleLink marks the payment link a succeeded PaymentIntent paid as PAID, along with every
+// appointment it covers. The link is only settled when the payment was made before it
+// expired and for exactly its amount; an intent that paid no link returns pgx.ErrNoRows.
+func settleLink(ctx context.Context, intent *PaymentIntent, paidAt time.Time) error {
+	paid := money.Money{Amount: intent.Amount, Currency: strings.ToUpper(intent.Currency)}
+	link, err := database.MarkPaymentLinkPaid(ctx, intent.ID, paid, paidAt)
+	if err != nil {
+		return err
+	}
+	return audit.Record(ctx, audit.EntityPaymentLinks, link.ID, audit.ActionUpdate, link)
+}
+
+// refundLinkPayment refunds a payment that could not settle its payment link, so the patient
+// is not charged for a balance that stays open. Failures are logged rather than returned.
+func (s *Stripe) refundLinkPayment(ctx context.Context, intent *PaymentIntent) {
+	refund, err := s.RefundPayment(ctx, intent.ID, "refund-"+intent.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "payments: refunding unsettled payment link payment", "payment_intent_id", intent.ID, "error", err)
+		return
+	}
+	slog.WarnContext(ctx, "payments: refunded payment that could not settle its payment link", "payment_intent_id", intent.ID, "refund_id", refund.ID)
+}
@@ -0,0 +1,50 @@
+// Medical Appointment Booking System - Payments Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package payments
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// LinkTTL is how long a payment link stays payable after it is issued
+const LinkTTL = 72 * time.Hour
+
+// ErrCheckoutNotConfigured is returned when no hosted checkout page is configured
+var ErrCheckoutNotConfigured = errors.New("PAYMENT_CHECKOUT_URL environment variable is not set")
+
+// NewLinkToken generates an unguessable token identifying a payment link
+func NewLinkToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CheckoutURL builds the hosted-checkout URL the patient follows to pay
+func CheckoutURL(token string) (string, error) {
+	base := os.Getenv("PAYMENT_CHECKOUT_URL")
+	if base == "" {
+		return "", ErrCheckoutNotConfigured
+	}
+	return strings.TrimRight(base, "/") + "/" + token, nil
+}
@@ -0,0 +1,250 @@
+// Medical Appointment Booking System - Payments Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/database"
+	"bookings/money"
+)
+
+// stripeAPI is the base URL of Stripe's REST API
+const stripeAPI = "https://api.stripe.com/v1"
+
+// webhookTolerance is how old a webhook signature may be before the event is refused as a replay
+const webhookTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned for webhook payloads whose Stripe-Signature does not verify
+var ErrInvalidSignature = errors.New("invalid Stripe webhook signature")
+
+// Stripe takes card payments through Stripe's PaymentIntents and Refunds APIs
+type Stripe struct {
+	SecretKey     string
+	WebhookSecret string
+	// RefundNotice is how long before the start a paid appointment must be cancelled for its
+	// payment to be refunded
+	RefundNotice time.Duration
+	Client       *http.Client
+}
+
+// DefaultRefundNotice is the refund cutoff used when STRIPE_REFUND_NOTICE is not set
+const DefaultRefundNotice = 24 * time.Hour
+
+// StripeFromEnv reads STRIPE_SECRET_KEY, STRIPE_WEBHOOK_SECRET and STRIPE_REFUND_NOTICE. It returns nil when no secret key is set, which leaves card
+// payments off.
+func StripeFromEnv() (*Stripe, error) {
+	s := &Stripe{
+		SecretKey:     os.Getenv("STRIPE_SECRET_KEY"),
+		WebhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+		RefundNotice:  DefaultRefundNotice,
+		Client:        &http.Client{Timeout: 10 * time.Second},
+	}
+	if s.SecretKey == "" {
+		return nil, nil
+	}
+	if s.WebhookSecret == "" {
+		return nil, errors.New("STRIPE_WEBHOOK_SECRET must be set with STRIPE_SECRET_KEY")
+	}
+	if raw := os.Getenv("STRIPE_REFUND_NOTICE"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid STRIPE_REFUND_NOTICE %q", raw)
+		}
+		s.RefundNotice = d
+	}
+	return s, nil
+}
+
+// PaymentIntent is the part of a Stripe PaymentIntent the server uses. The client secret
+// lets the patient's browser confirm the payment with Stripe.js.
+type PaymentIntent struct {
+	ID           string `json:"id"`
+	Amount       int64  `json:"amount"`
+	Currency     string `json:"currency"`
+	Status       string `json:"status"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// Refund is the part of a Stripe Refund the server uses
+type Refund struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// CreatePaymentIntent starts a payment of amount for an appointment. Stripe takes amounts in
+// the same minor units as money.Money. idempotencyKey makes a retried request return the
+// intent the first one created.
+func (s *Stripe) CreatePaymentIntent(ctx context.Context, amount money.Money, appointmentID int, idempotencyKey string) (*PaymentIntent, error) {
+	return s.createPaymentIntent(ctx, amount, "appointment_id", appointmentID, idempotencyKey)
+}
+
+// CreateLinkPaymentIntent starts the payment of a payment link's amount
+func (s *Stripe) CreateLinkPaymentIntent(ctx context.Context, amount money.Money, linkID int, idempotencyKey string) (*PaymentIntent, error) {
+	return s.createPaymentIntent(ctx, amount, "payment_link_id", linkID, idempotencyKey)
+}
+
+// createPaymentIntent creates a PaymentIntent with the id of the record it pays, and the
+// organization it belongs to, in its metadata
+func (s *Stripe) createPaymentIntent(ctx context.Context, amount money.Money, metadataKey string, id int, idempotencyKey string) (*PaymentIntent, error) {
+	form := url.Values{
+		"amount":                             {strconv.FormatInt(amount.Amount, 10)},
+		"currency":                           {strings.ToLower(amount.Currency)},
+		"automatic_payment_methods[enabled]": {"true"},
+		"metadata[" + metadataKey + "]":      {strconv.Itoa(id)},
+		"metadata[organization_id]":          {strconv.Itoa(database.OrganizationFromContext(ctx))},
+	}
+	var intent PaymentIntent
+	if err := s.do(ctx, http.MethodPost, "/payment_intents", form, idempotencyKey, &intent); err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// GetPaymentIntent fetches a PaymentIntent, e.g. to hand its client secret out again
+func (s *Stripe) GetPaymentIntent(ctx context.Context, id string) (*PaymentIntent, error) {
+	var intent PaymentIntent
+	if err := s.do(ctx, http.MethodGet, "/payment_intents/"+url.PathEscape(id), nil, "", &intent); err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+// RefundPayment refunds a PaymentIntent in full
+func (s *Stripe) RefundPayment(ctx context.Context, paymentIntentID, idempotencyKey string) (*Refund, error) {
+	var refund Refund
+	err := s.do(ctx, http.MethodPost, "/refunds", url.Values{"payment_intent": {paymentIntentID}}, idempotencyKey, &refund)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// Ping reads the account balance, which checks both that Stripe is reachable and that the
+// secret key is accepted
+func (s *Stripe) Ping(ctx context.Context) error {
+	return s.do(ctx, http.MethodGet, "/balance", nil, "", nil)
+}
+
+// do calls the Stripe API and decodes the response into out when it is not nil
+func (s *Stripe) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out any) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, stripeAPI+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.SecretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error.Message != "" {
+			return fmt.Errorf("stripe: %s (%s)", apiErr.Error.Message, apiErr.Error.Type)
+		}
+		return fmt.Errorf("stripe: unexpected status %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Event is a Stripe webhook event. Object holds the PaymentIntent or Charge it is about, and
+// Created is when it happened, in Unix seconds.
+type Event struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// VerifyWebhook checks the Stripe-Signature header of a webhook payload against the webhook
+// secret and decodes the event. Signatures older than five minutes are refused.
+func (s *Stripe) VerifyWebhook(payload []byte, header string, now time.Time) (*Event, error) {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > webhookTolerance || age < -webhookTolerance {
+		return nil, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	verified := false
+	for _, sig := range signatures {
+		if got, err := hex.DecodeString(sig); err == nil && hmac.Equal(got, expected) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("decoding Stripe event: %w", err)
+	}
+	return &event, nil
+}
@@ -0,0 +1,156 @@
+// Medical Appointment Booking System - PDF Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package pdf writes simple one-page PDF documents such as invoices and prescriptions.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size in points
+const (
+	PageWidth  = 595
+	PageHeight = 842
+)
+
+// Page is a single-page PDF of text and rules, positioned in points from the bottom-left
+// corner. It uses the standard Helvetica fonts, which every reader has, so nothing is
+// embedded; text is limited to the Windows-1252 character set.
+type Page struct {
+	content bytes.Buffer
+}
+
+// Text draws s with its baseline starting at x, y
+func (p *Page) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, encode(s))
+}
+
+// TextRight draws s ending at right, for columns of amounts; it measures with glyphWidths,
+// so other text is placed only approximately
+func (p *Page) TextRight(right, y, size float64, bold bool, s string) {
+	p.Text(right-textWidth(s, size), y, size, bold, s)
+}
+
+// Rule draws a thin horizontal line
+func (p *Page) Rule(x1, x2, y float64) {
+	fmt.Fprintf(&p.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y, x2, y)
+}
+
+// Bytes assembles the PDF file: catalog, page tree, page, the two fonts and the content stream
+func (p *Page) Bytes() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", PageWidth, PageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// winAnsi maps the characters outside Latin-1 that Windows-1252 has
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94,
+	'•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// encode converts s to a Windows-1252 PDF string body, escaping the delimiters and replacing
+// characters the fonts cannot show with '?'
+func encode(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			b.WriteByte(byte(r))
+		default:
+			if c, ok := winAnsi[r]; ok {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('?')
+			}
+		}
+	}
+	return b.String()
+}
+
+// glyphWidths are Helvetica's advance widths, in thousandths of the font size, for the
+// characters amounts are written with; regular and bold agree on all of them
+var glyphWidths = map[rune]float64{
+	'0': 556, '1': 556, '2': 556, '3': 556, '4': 556, '5': 556, '6': 556, '7': 556, '8': 556, '9': 556,
+	'.': 278, ',': 278, ' ': 278, '-': 333, '%': 889, '(': 333, ')': 333,
+}
+
+// textWidth measures s in points, approximating characters outside glyphWidths
+func textWidth(s string, size float64) float64 {
+	var width float64
+	for _, r := range s {
+		w, ok := glyphWidths[r]
+		if !ok {
+			w = 556
+		}
+		width += w
+	}
+	return width * size / 1000
+}
+
+// Wrap breaks s into lines no wider than width points at size, at spaces where it can. The
+// widths are approximate, so leave some margin.
+func Wrap(s string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			switch {
+			case line == "":
+				line = word
+			case textWidth(line+" "+word, size) <= width:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,91 @@
+// Medical Appointment Booking System - PHI Encryption Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package phi
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LocalKeys wraps data keys with AES-256-GCM master keys held in process memory. The first
+// key wraps new data keys; the others only unwrap, so a retired key can stay listed until
+// every value sealed under it has been rewritten.
+type LocalKeys struct {
+	active string
+	keys   map[string][]byte
+}
+
+// ParseLocalKeys reads master keys given as comma-separated "id:base64key" pairs, the active
+// key first, e.g. "2:<new key>,1:<old key>"
+func ParseLocalKeys(raw string) (*LocalKeys, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, errors.New("PHI_ENCRYPTION_KEYS is not set")
+	}
+	keys := &LocalKeys{keys: map[string][]byte{}}
+	for _, pair := range strings.Split(raw, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("PHI_ENCRYPTION_KEYS entry %q must be id:base64key", pair)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("PHI_ENCRYPTION_KEYS key %q must be 32 base64-encoded bytes", id)
+		}
+		if _, dup := keys.keys[id]; dup {
+			return nil, fmt.Errorf("PHI_ENCRYPTION_KEYS lists key %q twice", id)
+		}
+		if keys.active == "" {
+			keys.active = id
+		}
+		keys.keys[id] = key
+	}
+	return keys, nil
+}
+
+func (k *LocalKeys) KeyID() string {
+	return k.active
+}
+
+func (k *LocalKeys) Wrap(dataKey []byte) ([]byte, error) {
+	aead, err := newAEAD(k.keys[k.active])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+func (k *LocalKeys) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown PHI master key %q", keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("malformed wrapped data key")
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+}
@@ -0,0 +1,243 @@
+// Medical Appointment Booking System - PHI Encryption Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package phi
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Prefix marks an encrypted value. Values without it were written before encryption was
+// enabled and are read back as they are.
+const Prefix = "enc:v1:"
+
+// Fields are the JSON names of the columns encrypted at rest
+var Fields = []string{"date_of_birth", "medical_record_number", "insurance_id", "medical_notes",
+	"subjective", "objective", "assessment", "plan", "referral_reason", "answers"}
+
+// ErrNotConfigured is returned when PHI is read or written before Configure or Load
+var ErrNotConfigured = errors.New("PHI encryption is not configured")
+
+// KeyWrapper protects data keys with a master key that never leaves it. LocalKeys keeps the
+// master keys in the environment; a KMS client can be plugged in through Configure instead.
+type KeyWrapper interface {
+	// KeyID names the master key Wrap uses
+	KeyID() string
+	Wrap(dataKey []byte) ([]byte, error)
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// envelope is the configured state: the data key new values are sealed with, wrapped by the
+// master key, and a cache of data keys already unwrapped for reading
+type envelope struct {
+	wrapper  KeyWrapper
+	indexKey []byte
+	aead     cipher.AEAD
+	header   string // Prefix + key id + wrapped data key, shared by every value sealed this run
+	opened   sync.Map
+}
+
+var (
+	mu      sync.RWMutex
+	current *envelope
+)
+
+// Configure sets the master key wrapper and the blind index key. A fresh data key is
+// generated and wrapped once; every value encrypted afterwards uses it.
+func Configure(wrapper KeyWrapper, indexKey []byte) error {
+	if len(indexKey) < 32 {
+		return errors.New("PHI index key must be at least 32 bytes")
+	}
+	if strings.Contains(wrapper.KeyID(), ":") {
+		return errors.New("PHI key ids must not contain ':'")
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+	wrapped, err := wrapper.Wrap(dataKey)
+	if err != nil {
+		return fmt.Errorf("wrap PHI data key: %w", err)
+	}
+
+	e := &envelope{
+		wrapper:  wrapper,
+		indexKey: indexKey,
+		aead:     aead,
+		header:   Prefix + wrapper.KeyID() + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":",
+	}
+	e.opened.Store(e.header, aead)
+	mu.Lock()
+	current = e
+	mu.Unlock()
+	return nil
+}
+
+// Load configures encryption from PHI_ENCRYPTION_KEYS and PHI_INDEX_KEY
+func Load() error {
+	keys, err := ParseLocalKeys(os.Getenv("PHI_ENCRYPTION_KEYS"))
+	if err != nil {
+		return err
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(os.Getenv("PHI_INDEX_KEY"))
+	if err != nil || len(indexKey) < 32 {
+		return errors.New("PHI_INDEX_KEY must be at least 32 base64-encoded bytes")
+	}
+	return Configure(keys, indexKey)
+}
+
+func loaded() (*envelope, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return nil, ErrNotConfigured
+	}
+	return current, nil
+}
+
+// Encrypt seals a value for storage. The empty string is stored as it is.
+func Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	e, err := loaded()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := e.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return e.header + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a stored value, passing through values written before encryption was enabled
+func Decrypt(stored string) (string, error) {
+	if !strings.HasPrefix(stored, Prefix) {
+		return stored, nil
+	}
+	e, err := loaded()
+	if err != nil {
+		return "", err
+	}
+	cut := strings.LastIndexByte(stored, ':') + 1
+	header, body := stored[:cut], stored[cut:]
+	aead, err := e.open(header)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(body)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted PHI value")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt PHI value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// EncryptPtr is Encrypt for nullable columns
+func EncryptPtr(plaintext *string) (*string, error) {
+	if plaintext == nil {
+		return nil, nil
+	}
+	stored, err := Encrypt(*plaintext)
+	return &stored, err
+}
+
+// DecryptPtr is Decrypt for nullable columns
+func DecryptPtr(stored *string) (*string, error) {
+	if stored == nil {
+		return nil, nil
+	}
+	plaintext, err := Decrypt(*stored)
+	return &plaintext, err
+}
+
+// Current reports whether a stored value is empty or encrypted under the active master key;
+// anything else is plaintext or sealed under a retired key and should be rewritten
+func Current(stored string) bool {
+	if stored == "" {
+		return true
+	}
+	e, err := loaded()
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(stored, Prefix+e.wrapper.KeyID()+":")
+}
+
+// Index returns a keyed hash of a value, so encrypted columns can still be checked for
+// uniqueness or compared without decrypting them
+func Index(value string) (string, error) {
+	e, err := loaded()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, e.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// open returns the cipher for a value's header, unwrapping its data key on first use
+func (e *envelope) open(header string) (cipher.AEAD, error) {
+	if aead, ok := e.opened.Load(header); ok {
+		return aead.(cipher.AEAD), nil
+	}
+	keyID, wrappedText, ok := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(header, Prefix), ":"), ":")
+	if !ok {
+		return nil, errors.New("malformed encrypted PHI value")
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(wrappedText)
+	if err != nil {
+		return nil, errors.New("malformed encrypted PHI value")
+	}
+	dataKey, err := e.wrapper.Unwrap(keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap PHI data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	e.opened.Store(header, aead)
+	return aead, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
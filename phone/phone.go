@@ -0,0 +1,118 @@
+// Medical Appointment Booking System - Phone Numbers
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package phone checks phone numbers and normalizes them to E.164, e.g. +14155552671, the
+// form SMS providers expect and duplicate matching compares.
+//
+// It is not a numbering plan library. A number is only checked for the E.164 shape (a country
+// code and at most 15 digits in all) and, in the North American plan, for having 10 digits
+// after the country code; whether the country code is assigned, the number has the right
+// length for its country or is in service is not checked. National numbers, written without
+// a country code, can only be read for the countries in regions, whose trunk and international
+// prefixes are listed by hand; any other PHONE_DEFAULT_REGION accepts international numbers only.
+package phone
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalid is returned for a number that cannot be read as a phone number
+var ErrInvalid = errors.New("not a valid phone number")
+
+// e164Pattern is a number in E.164: a plus, a country code not starting with 0 and at most
+// 15 digits in all
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// region is how numbers are written nationally in a country
+type region struct {
+	// code is the country calling code
+	code string
+	// trunk is the prefix national numbers start with that is dropped after the country code
+	trunk string
+	// exit is the prefix dialled before a country code to call abroad
+	exit string
+}
+
+// regions maps ISO 3166 country codes to how their numbers are written
+var regions = map[string]region{
+	"US": {"1", "1", "011"}, "CA": {"1", "1", "011"},
+	"GB": {"44", "0", "00"}, "IE": {"353", "0", "00"},
+	"DE": {"49", "0", "00"}, "AT": {"43", "0", "00"}, "CH": {"41", "0", "00"},
+	"FR": {"33", "0", "00"}, "BE": {"32", "0", "00"}, "NL": {"31", "0", "00"}, "LU": {"352", "", "00"},
+	"ES": {"34", "", "00"}, "PT": {"351", "", "00"}, "IT": {"39", "", "00"},
+	"PL": {"48", "", "00"}, "CZ": {"420", "", "00"},
+	"SE": {"46", "0", "00"}, "NO": {"47", "", "00"}, "DK": {"45", "", "00"}, "FI": {"358", "0", "00"},
+	"AU": {"61", "0", "0011"}, "NZ": {"64", "0", "00"},
+	"IN": {"91", "0", "00"}, "JP": {"81", "0", "010"}, "SG": {"65", "", "000"},
+	"AE": {"971", "0", "00"}, "IL": {"972", "0", "00"}, "ZA": {"27", "0", "00"},
+	"BR": {"55", "0", "00"}, "MX": {"52", "", "00"},
+}
+
+// DefaultRegion reads the country numbers without a country code belong to from
+// PHONE_DEFAULT_REGION, e.g. "GB". Without it only international numbers are accepted.
+func DefaultRegion() string {
+	return strings.ToUpper(strings.TrimSpace(os.Getenv("PHONE_DEFAULT_REGION")))
+}
+
+// Normalize returns number in E.164. It may be written with spaces, dashes, dots, slashes and
+// parentheses. A leading + or the international prefix (00, or the region's own) starts the
+// country code; anything else is read as a national number of regionCode.
+func Normalize(number, regionCode string) (string, error) {
+	number = strings.TrimSpace(number)
+	international := strings.HasPrefix(number, "+")
+	var digits strings.Builder
+	for i, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+		case strings.ContainsRune(" -./()", r):
+		default:
+			return "", ErrInvalid
+		}
+	}
+
+	national, known := regions[strings.ToUpper(regionCode)]
+	e164 := digits.String()
+	switch {
+	case international:
+	case known && national.exit != "" && strings.HasPrefix(e164, national.exit):
+		e164 = strings.TrimPrefix(e164, national.exit)
+	case strings.HasPrefix(e164, "00"):
+		e164 = strings.TrimPrefix(e164, "00")
+	case known:
+		if national.trunk != "" {
+			e164 = strings.TrimPrefix(e164, national.trunk)
+		}
+		e164 = national.code + e164
+	default:
+		return "", ErrInvalid
+	}
+	// The North American plan is the one country code whose numbers all have the same length
+	if !e164Pattern.MatchString("+"+e164) || (strings.HasPrefix(e164, "1") && len(e164) != 11) {
+		return "", ErrInvalid
+	}
+	return "+" + e164, nil
+}
+
+// Valid reports whether number can be normalized in the default region
+func Valid(number string) bool {
+	_, err := Normalize(number, DefaultRegion())
+	return err == nil
+}
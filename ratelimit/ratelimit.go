@@ -0,0 +1,152 @@
+// Medical Appointment Booking System - Rate Limit Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package ratelimit limits how fast each client may call the API with a token bucket per
+// client: a client may send Burst requests at once and then PerMinute a minute.
+package ratelimit
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"bookings/apierr"
+	"bookings/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Standard rate limit response headers (IETF draft "RateLimit header fields for HTTP")
+const (
+	LimitHeader      = "RateLimit-Limit"
+	RemainingHeader  = "RateLimit-Remaining"
+	ResetHeader      = "RateLimit-Reset"
+	RetryAfterHeader = "Retry-After"
+)
+
+// sweepInterval is how often buckets that have refilled, and so hold nothing worth keeping,
+// are dropped
+const sweepInterval = time.Minute
+
+// Limiter is a set of token buckets, one per client key
+type Limiter struct {
+	perSecond float64
+	burst     float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// New returns a limiter allowing each client burst requests at once and perMinute a minute,
+// or nil, which allows everything, when perMinute is zero
+func New(perMinute, burst int) *Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &Limiter{perSecond: float64(perMinute) / 60, burst: float64(burst), buckets: map[string]*bucket{}}
+}
+
+// Allow takes a token from the client's bucket at now. It reports whether there was one, how
+// many are left and how long until the bucket is full again, or, when refused, until the
+// next token.
+func (l *Limiter) Allow(key string, now time.Time) (allowed bool, remaining int, wait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, at: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.at).Seconds()*l.perSecond)
+	b.at = now
+	if b.tokens < 1 {
+		return false, 0, l.duration(1 - b.tokens)
+	}
+	b.tokens--
+	return true, int(b.tokens), l.duration(l.burst - b.tokens)
+}
+
+// duration is how long the bucket takes to gain tokens
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.perSecond * float64(time.Second))
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.at).Seconds()*l.perSecond >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Middleware limits requests per client as keyed by key, answering 429 when the client's
+// bucket is empty. Every response carries the RateLimit headers. A nil limiter lets every
+// request through.
+func (l *Limiter) Middleware(key func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+		allowed, remaining, wait := l.Allow(key(c), time.Now())
+		c.Header(LimitHeader, strconv.Itoa(int(l.burst)))
+		c.Header(RemainingHeader, strconv.Itoa(remaining))
+		c.Header(ResetHeader, strconv.Itoa(seconds(wait)))
+		if !allowed {
+			c.Header(RetryAfterHeader, strconv.Itoa(seconds(wait)))
+			apierr.Abort(c, apierr.TooManyRequests("Too many requests; slow down and try again later"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds rounds d up to whole seconds, as the headers are given in
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ByIP keys clients by their address, for requests that are not authenticated
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByCaller keys clients by the API key or user they authenticated as, falling back to their
+// address. It must run after auth.Middleware.
+func ByCaller(c *gin.Context) string {
+	if id, ok := auth.APIKeyIDFromContext(c.Request.Context()); ok {
+		return "key:" + strconv.Itoa(id)
+	}
+	if id, ok := auth.UserIDFromContext(c.Request.Context()); ok {
+		return "user:" + strconv.Itoa(id)
+	}
+	return ByIP(c)
+}
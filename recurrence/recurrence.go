@@ -0,0 +1,225 @@
+// Medical Appointment Booking System - Recurrence Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package recurrence expands the RRULE subset (RFC 5545) used for appointment series, such
+// as "FREQ=WEEKLY;COUNT=8" or "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;UNTIL=20260630", into the
+// start times of the occurrences.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookings/timeutil"
+)
+
+// MaxOccurrences caps how many appointments one series may book
+const MaxOccurrences = 52
+
+// Frequencies a rule may repeat at
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+)
+
+// untilLayout is the RFC 5545 DATE form UNTIL is written in
+const untilLayout = "20060102"
+
+// weekdayCodes are the BYDAY values in RFC 5545 order
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Rule is a parsed recurrence. A series ends after Count occurrences or on the Until date,
+// whichever is given.
+type Rule struct {
+	Freq     string
+	Interval int
+	Count    int
+	// Until is the last local date an occurrence may fall on, as a UTC midnight
+	Until time.Time
+	// ByDay lists the weekdays a weekly rule books on; empty means the start's weekday
+	ByDay []time.Weekday
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;COUNT=8". An "RRULE:" prefix is accepted. The
+// rule must end, through COUNT or UNTIL; UNTIL may be a date or a UTC date-time, of which
+// only the date is used.
+func Parse(s string) (Rule, error) {
+	rule := Rule{Interval: 1}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return Rule{}, errors.New("recurrence is empty")
+	}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("invalid recurrence part %q", part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+				return Rule{}, fmt.Errorf("unsupported FREQ %q; use DAILY, WEEKLY or MONTHLY", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Rule{}, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Rule{}, fmt.Errorf("invalid COUNT %q", value)
+			}
+			rule.Count = n
+		case "UNTIL":
+			date, _, _ := strings.Cut(value, "T")
+			until, err := time.Parse(untilLayout, date)
+			if err != nil {
+				return Rule{}, fmt.Errorf("invalid UNTIL %q; use YYYYMMDD", value)
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, code := range strings.Split(strings.ToUpper(value), ",") {
+				day, ok := weekdayCodes[code]
+				if !ok {
+					return Rule{}, fmt.Errorf("invalid BYDAY %q", code)
+				}
+				if !slices.Contains(rule.ByDay, day) {
+					rule.ByDay = append(rule.ByDay, day)
+				}
+			}
+		default:
+			return Rule{}, fmt.Errorf("unsupported recurrence part %q", key)
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return Rule{}, errors.New("recurrence needs a FREQ")
+	case rule.Count == 0 && rule.Until.IsZero():
+		return Rule{}, errors.New("recurrence needs a COUNT or UNTIL")
+	case rule.Count > 0 && !rule.Until.IsZero():
+		return Rule{}, errors.New("recurrence takes COUNT or UNTIL, not both")
+	case rule.Count > MaxOccurrences:
+		return Rule{}, fmt.Errorf("COUNT may be at most %d", MaxOccurrences)
+	case len(rule.ByDay) > 0 && rule.Freq != Weekly:
+		return Rule{}, errors.New("BYDAY is only supported with FREQ=WEEKLY")
+	}
+	// Mondays first, as weeks are walked from their Monday
+	slices.SortFunc(rule.ByDay, func(a, b time.Weekday) int { return mondayOffset(a) - mondayOffset(b) })
+	return rule, nil
+}
+
+// String writes the rule back in its canonical form
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		codes := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			codes[i] = strings.ToUpper(day.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	} else {
+		parts = append(parts, "UNTIL="+r.Until.Format(untilLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences returns the start times of the series beginning at start, keeping its wall-clock
+// time in loc across DST changes. The first is start itself unless BYDAY leaves out its
+// weekday. Monthly rules skip months without the start's day, e.g. the 31st. It fails when
+// the rule yields no occurrence or more than MaxOccurrences.
+func (r Rule) Occurrences(start time.Time, loc *time.Location) ([]time.Time, error) {
+	local := start.In(loc)
+	first := timeutil.LocalDate(start, loc)
+
+	var dates []time.Time
+	add := func(date time.Time) bool {
+		if !r.Until.IsZero() && date.After(r.Until) {
+			return false
+		}
+		dates = append(dates, date)
+		return (r.Count == 0 || len(dates) < r.Count) && len(dates) <= MaxOccurrences
+	}
+
+	switch {
+	case r.Freq == Weekly && len(r.ByDay) > 0:
+		monday := first.AddDate(0, 0, -mondayOffset(first.Weekday()))
+	weeks:
+		for week := monday; ; week = week.AddDate(0, 0, 7*r.Interval) {
+			for _, day := range r.ByDay {
+				date := week.AddDate(0, 0, mondayOffset(day))
+				if date.Before(first) {
+					continue
+				}
+				if !add(date) {
+					break weeks
+				}
+			}
+		}
+	case r.Freq == Daily || r.Freq == Weekly:
+		step := r.Interval
+		if r.Freq == Weekly {
+			step *= 7
+		}
+		for date := first; add(date); {
+			date = date.AddDate(0, 0, step)
+		}
+	case r.Freq == Monthly:
+		y, m, d := first.Date()
+		for i := 0; ; i += r.Interval {
+			date := time.Date(y, m+time.Month(i), d, 0, 0, 0, 0, time.UTC)
+			if date.Day() != d {
+				continue // the month is too short
+			}
+			if !add(date) {
+				break
+			}
+		}
+	}
+
+	if len(dates) == 0 {
+		return nil, errors.New("recurrence yields no occurrences")
+	}
+	if len(dates) > MaxOccurrences {
+		return nil, fmt.Errorf("recurrence yields more than %d occurrences", MaxOccurrences)
+	}
+	starts := make([]time.Time, len(dates))
+	for i, date := range dates {
+		starts[i] = timeutil.WallClock(date, local.Hour(), local.Minute(), loc)
+	}
+	return starts, nil
+}
+
+// mondayOffset is the number of days from Monday to day
+func mondayOffset(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
@@ -0,0 +1,146 @@
+// Medical Appointment Booking System - Data Retention Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package retention applies the data retention policies: rows of each class of data older
+// than its configured period are archived, moved to the object store or purged, in batches,
+// and every run that handled rows is reported in retention_runs.
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"bookings/config"
+	"bookings/database"
+	"bookings/models"
+	"bookings/storage"
+)
+
+// BatchSize is how many rows one statement archives, moves or purges
+const BatchSize = 1000
+
+// MaxBatches bounds the batches of each class in one run, so a first run over years of data
+// is spread over several sweeps rather than holding the database for long
+const MaxBatches = 50
+
+// Check reports a policy that cannot be applied: moving rows to cold storage needs an object store
+func Check(policies map[string]config.RetentionPolicy, store *storage.Store) error {
+	for _, class := range config.RetentionClasses {
+		if policy := policies[class]; policy.Days > 0 && policy.Action == config.RetentionCold && store == nil {
+			return fmt.Errorf("retention of %s moves rows to cold storage, which needs S3_BUCKET", class)
+		}
+	}
+	return nil
+}
+
+// Cutoff returns the time before which rows of a class with the policy have expired at now,
+// and false for a policy that keeps them forever
+func Cutoff(policy config.RetentionPolicy, now time.Time) (time.Time, bool) {
+	if policy.Days <= 0 {
+		return time.Time{}, false
+	}
+	return now.AddDate(0, 0, -policy.Days), true
+}
+
+// Run applies every policy once and returns the report of each class it handled rows of.
+// A failing class does not stop the others; their errors are returned together.
+func Run(ctx context.Context, policies map[string]config.RetentionPolicy, store *storage.Store, now time.Time) ([]models.RetentionRun, error) {
+	var runs []models.RetentionRun
+	var errs []error
+	for _, class := range config.RetentionClasses {
+		policy := policies[class]
+		cutoff, ok := Cutoff(policy, now)
+		if !ok {
+			continue
+		}
+		run, err := apply(ctx, class, policy.Action, cutoff, store, now)
+		if run.RowCount > 0 {
+			if err := database.RecordRetentionRun(ctx, &run); err != nil {
+				errs = append(errs, err)
+			}
+			slog.InfoContext(ctx, "retention: expired rows", "class", class, "action", run.Action, "rows", run.RowCount,
+				"first_id", run.FirstID, "last_id", run.LastID)
+			runs = append(runs, run)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("retention of %s: %w", class, err))
+		}
+	}
+	return runs, errors.Join(errs...)
+}
+
+// apply handles the expired rows of one class batch by batch, until none are left or
+// MaxBatches is reached. The report covers the batches done before any error.
+func apply(ctx context.Context, class, action string, cutoff time.Time, store *storage.Store, now time.Time) (models.RetentionRun, error) {
+	run := models.RetentionRun{DataClass: class, Action: action, Cutoff: cutoff, Locations: []string{}}
+	for range MaxBatches {
+		var batch database.ExpiredBatch
+		var err error
+		switch action {
+		case config.RetentionArchive:
+			batch, err = database.ArchiveExpired(ctx, class, cutoff, BatchSize, now)
+		case config.RetentionPurge:
+			batch, err = database.PurgeExpired(ctx, class, cutoff, BatchSize)
+		case config.RetentionCold:
+			batch, err = database.MoveExpired(ctx, class, cutoff, BatchSize,
+				func(ctx context.Context, rows []json.RawMessage, batch database.ExpiredBatch) error {
+					key, err := upload(ctx, store, class, rows, batch, now)
+					if err == nil {
+						run.Locations = append(run.Locations, key)
+					}
+					return err
+				})
+		default:
+			err = fmt.Errorf("unknown retention action %q", action)
+		}
+		if err != nil {
+			return run, err
+		}
+		if batch.Rows > 0 {
+			if run.RowCount == 0 || batch.FirstID < run.FirstID {
+				run.FirstID = batch.FirstID
+			}
+			run.LastID = max(run.LastID, batch.LastID)
+			run.RowCount += batch.Rows
+		}
+		if batch.Rows < BatchSize {
+			break
+		}
+	}
+	return run, nil
+}
+
+// upload writes a batch of rows to the object store as gzipped JSON Lines, under
+// retention/<class>/<date>/<first id>-<last id>.jsonl.gz, and returns the key
+func upload(ctx context.Context, store *storage.Store, class string, rows []json.RawMessage, batch database.ExpiredBatch, now time.Time) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, row := range rows {
+		zw.Write(row)
+		zw.Write([]byte("\n"))
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("retention/%s/%s/%d-%d.jsonl.gz", class, now.UTC().Format(time.DateOnly), batch.FirstID, batch.LastID)
+	return key, store.Put(ctx, key, "application/gzip", buf.Bytes())
+}
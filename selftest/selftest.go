@@ -0,0 +1,306 @@
+// Medical Appointment Booking System - Self-Test Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package selftest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"bookings/auth"
+	"bookings/database"
+	"bookings/eventbus"
+	"bookings/models"
+	"bookings/notifications"
+	"bookings/workers"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// expectedTables lists every table the application needs to run
+var expectedTables = []string{
+	"clinics", "patients", "employees", "services", "employee_services",
+	"work_templates", "day_overrides", "time_off", "slot_holds",
+	"appointments", "waiting_list", "payment_links", "payment_link_items", "audit_log",
+	"users", "refresh_tokens", "appointment_reschedules", "sent_reminders", "calendar_feeds",
+	"access_log", "card_payments", "stripe_events", "appointment_series", "clinic_hours", "clinic_holidays",
+	"resources", "service_resources", "appointment_resources",
+	"webhook_subscriptions", "webhook_events", "webhook_deliveries", "webhook_delivery_attempts",
+	"event_outbox", "waiting_room_displays", "notification_preferences", "notifications", "hl7_messages",
+	"organizations", "api_keys", "clinic_widgets", "patient_documents", "visit_notes", "visit_note_amendments",
+	"prescriptions", "referrals", "patient_allergies", "patient_medications", "patient_conditions",
+	"form_templates", "form_responses", "consent_documents", "patient_consents",
+	"appointments_archive", "notifications_archive", "audit_log_archive", "retention_runs", "patient_relationships",
+}
+
+// expectedEnums maps each PostgreSQL enum type to the values the Go code relies on
+var expectedEnums = map[string][]string{
+	"appointment_status":  models.AppointmentStatuses,
+	"appointment_type":    models.AppointmentTypes,
+	"payment_status":      models.PaymentStatuses,
+	"urgency_level":       models.UrgencyLevels,
+	"waiting_list_status": models.WaitingListStatuses,
+	"payment_link_status": models.PaymentLinkStatuses,
+	"booking_channel":     models.BookingChannels,
+	"time_off_status":     models.TimeOffStatuses,
+	"user_role":           models.UserRoles,
+}
+
+// errSkipped marks a check that does not apply to this deployment
+var errSkipped = errors.New("skipped")
+
+// errDryRun ends the transaction checkWorkers runs jobs in, so nothing they change is kept
+var errDryRun = errors.New("dry run")
+
+type check struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+var checks = []check{
+	{"database reachable", checkPing},
+	{"migrations applied", checkMigrations},
+	{"schema present", checkTables},
+	{"transaction write/read", checkTransaction},
+	{"enum values consistent", checkEnums},
+	{"double-booking constraint", checkOverlapConstraint},
+	{"organization isolation", checkOrganizationIsolation},
+	{"background workers", checkWorkers},
+	{"authentication config", checkAuth},
+	{"notification config", checkNotifications},
+	{"event bus config", checkEventBus},
+}
+
+// Run executes every check against the configured database, printing one line per
+// check, and reports whether all of them passed
+func Run(ctx context.Context) bool {
+	fmt.Println("=== Running startup self-test ===")
+	ok := true
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := c.run(checkCtx)
+		cancel()
+		switch {
+		case err == nil:
+			fmt.Printf("✅ %s\n", c.name)
+		case errors.Is(err, errSkipped):
+			fmt.Printf("⏭️  %s: %v\n", c.name, err)
+		default:
+			fmt.Printf("❌ %s: %v\n", c.name, err)
+			ok = false
+		}
+	}
+	if ok {
+		fmt.Println("=== Self-test passed ===")
+	} else {
+		fmt.Println("=== Self-test FAILED ===")
+	}
+	return ok
+}
+
+func checkPing(ctx context.Context) error {
+	return database.DB.Ping(ctx)
+}
+
+// checkMigrations fails when the deployed schema is behind the migrations built into the binary
+func checkMigrations(ctx context.Context) error {
+	pending, err := database.PendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		names := make([]string, len(pending))
+		for i, m := range pending {
+			names[i] = fmt.Sprintf("%04d_%s", m.Version, m.Name)
+		}
+		return fmt.Errorf("pending migrations: %s (run with -migrate)", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func checkTables(ctx context.Context) error {
+	var missing []string
+	for _, table := range expectedTables {
+		var exists bool
+		if err := database.DB.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkOverlapConstraint makes sure the exclusion constraint that stops an employee being
+// double-booked is installed; without it concurrent bookings can overlap
+func checkOverlapConstraint(ctx context.Context) error {
+	var exists bool
+	err := database.DB.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'appointments_no_overlap' AND contype = 'x')").Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("appointments_no_overlap exclusion constraint is missing")
+	}
+	return nil
+}
+
+// checkOrganizationIsolation makes sure row-level security keeps organizations apart: every
+// table but organizations has an organization_id and a forced policy, and, once there is
+// more than one organization, the server's role does not skip policies
+func checkOrganizationIsolation(ctx context.Context) error {
+	tables := slices.DeleteFunc(slices.Clone(expectedTables), func(t string) bool { return t == "organizations" })
+	rows, err := database.DB.Query(ctx,
+		`SELECT t FROM unnest($1::text[]) t WHERE NOT EXISTS (
+			SELECT 1 FROM pg_class c
+			JOIN pg_attribute a ON a.attrelid = c.oid AND a.attname = 'organization_id' AND NOT a.attisdropped
+			WHERE c.oid = to_regclass(t) AND c.relrowsecurity AND c.relforcerowsecurity)
+		ORDER BY t`, tables)
+	if err != nil {
+		return err
+	}
+	unscoped, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	if len(unscoped) > 0 {
+		return fmt.Errorf("not scoped to an organization: %s", strings.Join(unscoped, ", "))
+	}
+
+	var bypass bool
+	var organizations int
+	err = database.DB.QueryRow(ctx,
+		"SELECT (SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user), (SELECT COUNT(*) FROM organizations)").
+		Scan(&bypass, &organizations)
+	if err != nil {
+		return err
+	}
+	if bypass && organizations > 1 {
+		return errors.New("the database role skips row-level security, so organizations are not isolated; connect as a role without SUPERUSER or BYPASSRLS")
+	}
+	return nil
+}
+
+// checkTransaction writes a throwaway clinic inside a transaction, reads it back and
+// rolls back so the database is left untouched
+func checkTransaction(ctx context.Context) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	name := fmt.Sprintf("selftest-%d", time.Now().UnixNano())
+	var id int
+	if err := tx.QueryRow(ctx, "INSERT INTO clinics (name) VALUES ($1) RETURNING id", name).Scan(&id); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	var readBack string
+	if err := tx.QueryRow(ctx, "SELECT name FROM clinics WHERE id = $1", id).Scan(&readBack); err != nil {
+		return fmt.Errorf("read failed: %w", err)
+	}
+	if readBack != name {
+		return fmt.Errorf("read back %q, wrote %q", readBack, name)
+	}
+	return nil
+}
+
+func checkEnums(ctx context.Context) error {
+	var problems []string
+	for typeName, want := range expectedEnums {
+		rows, err := database.DB.Query(ctx,
+			"SELECT e.enumlabel FROM pg_enum e JOIN pg_type t ON t.oid = e.enumtypid WHERE t.typname = $1 ORDER BY e.enumsortorder", typeName)
+		if err != nil {
+			return err
+		}
+		got, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return err
+		}
+		if !slices.Equal(got, want) {
+			problems = append(problems, fmt.Sprintf("%s has %v, expected %v", typeName, got, want))
+		}
+	}
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkWorkers validates the configuration of the background workers main starts, then runs
+// the sweeps that only touch the database inside a transaction that is rolled back, so their
+// queries are exercised against the real schema without changing anything
+func checkWorkers(ctx context.Context) error {
+	if _, err := workers.UnpaidSweepInterval(); err != nil {
+		return fmt.Errorf("unpaid booking sweep: %w", err)
+	}
+	if _, err := workers.ReminderSweepInterval(); err != nil {
+		return fmt.Errorf("reminder sweep: %w", err)
+	}
+	grace, err := workers.NoShowGrace()
+	if err != nil {
+		return fmt.Errorf("no-show marking: %w", err)
+	}
+
+	jobs := []workers.Job{workers.HoldCleanupJob(), workers.NoShowJob(grace), workers.PrescriptionExpiryJob()}
+	err = database.WithTx(ctx, func(ctx context.Context) error {
+		now := time.Now()
+		for _, job := range jobs {
+			if err := job.Run(ctx, now); err != nil {
+				return fmt.Errorf("%s: %w", job.Name, err)
+			}
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+// checkAuth makes sure access tokens can be signed
+func checkAuth(ctx context.Context) error {
+	return auth.CheckConfig()
+}
+
+// checkNotifications makes sure every enabled delivery channel is fully configured
+func checkNotifications(ctx context.Context) error {
+	_, err := notifications.NewSender()
+	return err
+}
+
+// checkEventBus validates EVENT_BUS and its settings when an event bus is configured
+func checkEventBus(ctx context.Context) error {
+	publisher, err := eventbus.FromEnv()
+	if err != nil {
+		return err
+	}
+	if publisher == nil {
+		return fmt.Errorf("%w: EVENT_BUS not set", errSkipped)
+	}
+	return nil
+}
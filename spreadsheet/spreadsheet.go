@@ -0,0 +1,107 @@
+// Medical Appointment Booking System - Spreadsheet Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package spreadsheet writes tabular exports as CSV or as a single-sheet Excel workbook
+// (XLSX), row by row, so large exports stream to the client instead of being built in memory.
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+// Formats
+const (
+	CSV  = "csv"
+	XLSX = "xlsx"
+)
+
+// Formats lists the supported formats
+var Formats = []string{CSV, XLSX}
+
+// contentTypes are the media types of each format
+var contentTypes = map[string]string{
+	CSV:  "text/csv; charset=utf-8",
+	XLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ContentType returns the media type of a format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Writer writes rows of cells. Close must be called after the last row; for XLSX it
+// writes the end of the workbook.
+type Writer interface {
+	WriteRow(cells []string) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format writing to w. sheet names the XLSX worksheet.
+func NewWriter(format string, w io.Writer, sheet string) (Writer, error) {
+	switch format {
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case XLSX:
+		return newXLSXWriter(w, sheet)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+// WriteRow writes the cells, quoting any a spreadsheet would run as a formula
+func (c *csvWriter) WriteRow(cells []string) error {
+	safe := make([]string, len(cells))
+	for i, cell := range cells {
+		safe[i] = cell
+		if formula(cell) {
+			safe[i] = "'" + cell
+		}
+	}
+	return c.w.Write(safe)
+}
+
+// formula reports whether a spreadsheet opening a CSV would take cell for a formula. A
+// leading + or - followed by a digit, as in a phone number, is left alone.
+func formula(cell string) bool {
+	if cell == "" {
+		return false
+	}
+	switch cell[0] {
+	case '=', '@', '\t', '\r':
+		return true
+	case '+', '-':
+		return len(cell) > 1 && (cell[1] < '0' || cell[1] > '9')
+	}
+	return false
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// String returns the cell for an optional value, empty when it is nil
+func String(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,133 @@
+// Medical Appointment Booking System - Storage Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4, as S3 and its look-alikes check it
+const (
+	algorithm     = "AWS4-HMAC-SHA256"
+	service       = "s3"
+	amzDateLayout = "20060102T150405Z"
+	// emptyPayloadHash is the SHA-256 of an empty body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// unsignedPayload lets a presigned URL be used without knowing the body in advance
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// sign adds the date, payload hash and Authorization headers to a request about to be sent
+func (s *Store) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format(amzDateLayout)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), canonicalQuery(req.URL.Query()), canonicalHeaders, signedHeaders, payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", algorithm+" Credential="+s.AccessKey+"/"+s.scope(now)+
+		", SignedHeaders="+signedHeaders+", Signature="+s.signature(now, canonicalRequest))
+}
+
+// presign returns the object URL with the signature in its query, valid for ttl from now
+func (s *Store) presign(method, key string, query url.Values, ttl time.Duration, now time.Time) string {
+	u, _ := url.Parse(s.objectURL(key))
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", s.AccessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.UTC().Format(amzDateLayout))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	encoded := canonicalQuery(query)
+	canonicalRequest := strings.Join([]string{
+		method, u.EscapedPath(), encoded, "host:" + u.Host + "\n", "host", unsignedPayload,
+	}, "\n")
+	u.RawQuery = encoded + "&X-Amz-Signature=" + s.signature(now, canonicalRequest)
+	return u.String()
+}
+
+// scope is the credential scope of a signature made at now
+func (s *Store) scope(now time.Time) string {
+	return now.UTC().Format("20060102") + "/" + s.Region + "/" + service + "/aws4_request"
+}
+
+// signature signs a canonical request with a key derived from the secret for the day
+func (s *Store) signature(now time.Time, canonicalRequest string) string {
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := algorithm + "\n" + now.UTC().Format(amzDateLayout) + "\n" + s.scope(now) + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), now.UTC().Format("20060102"))
+	for _, part := range []string{s.Region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes a query with its keys, and each key's values, sorted
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := slices.Clone(query[key])
+		slices.Sort(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, false)+"="+uriEncode(value, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but the unreserved characters, and slashes when
+// keepSlash is set, as Signature Version 4 requires
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+	return b.String()
+}
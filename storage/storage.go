@@ -0,0 +1,175 @@
+// Medical Appointment Booking System - Storage Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package storage keeps uploaded files in an S3-compatible object store: AWS S3, MinIO, Ceph
+// and the like. Requests are signed with AWS Signature Version 4, and files are served to
+// clients through presigned URLs rather than through the server.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultRegion is the signing region used when S3_REGION is not set; MinIO accepts it
+// whatever its own region setting
+const DefaultRegion = "us-east-1"
+
+// Store is a bucket of an S3-compatible object store
+type Store struct {
+	// Endpoint is the base URL of the bucket's objects: virtual-hosted on AWS
+	// (https://bucket.s3.region.amazonaws.com), path-style elsewhere (https://minio:9000/bucket)
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// FromEnv reads S3_BUCKET, S3_ENDPOINT, S3_REGION, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY.
+// It returns nil when no bucket is set, which leaves file uploads off. Without S3_ENDPOINT
+// the bucket is on AWS.
+func FromEnv() (*Store, error) {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil, nil
+	}
+	s := &Store{
+		Region:    os.Getenv("S3_REGION"),
+		AccessKey: os.Getenv("S3_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		Client:    &http.Client{Timeout: time.Minute},
+	}
+	if s.Region == "" {
+		s.Region = DefaultRegion
+	}
+	if s.AccessKey == "" || s.SecretKey == "" {
+		return nil, errors.New("S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY must be set with S3_BUCKET")
+	}
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		s.Endpoint = "https://" + bucket + ".s3." + s.Region + ".amazonaws.com"
+		return s, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+		return nil, fmt.Errorf("invalid S3_ENDPOINT %q", endpoint)
+	}
+	s.Endpoint = strings.TrimSuffix(endpoint, "/") + "/" + bucket
+	return s, nil
+}
+
+// Put stores data under key, replacing any object already there
+func (s *Store) Put(ctx context.Context, key, contentType string, data []byte) error {
+	sum := sha256.Sum256(data)
+	req, err := s.request(ctx, http.MethodPut, key, bytes.NewReader(data), hex.EncodeToString(sum[:]))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	req.Header.Set("Content-Type", contentType)
+	return s.do(req, "storing "+key)
+}
+
+// Delete removes the object under key. An object that is already gone is not an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	return s.do(req, "deleting "+key)
+}
+
+// Get opens the object under key; the caller reads and closes it
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, statusError(resp, "fetching "+key)
+	}
+	return resp.Body, nil
+}
+
+// PresignGet returns a URL that downloads the object under key for ttl, without further
+// credentials. The download is served as an attachment named filename, of contentType.
+func (s *Store) PresignGet(key, filename, contentType string, ttl time.Duration) string {
+	query := url.Values{
+		"response-content-disposition": {contentDisposition(filename)},
+		"response-content-type":        {contentType},
+	}
+	return s.presign(http.MethodGet, key, query, ttl, time.Now())
+}
+
+func (s *Store) request(ctx context.Context, method, key string, body io.Reader, payloadHash string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, payloadHash, time.Now())
+	return req, nil
+}
+
+func (s *Store) do(req *http.Request, what string) error {
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 && !(req.Method == http.MethodDelete && resp.StatusCode == http.StatusNotFound) {
+		return statusError(resp, what)
+	}
+	return nil
+}
+
+// statusError reports a failed request, with the short XML document S3 explains errors in
+func statusError(resp *http.Response, what string) error {
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("object store %s: %s: %s", what, resp.Status, strings.TrimSpace(string(detail)))
+}
+
+// objectURL is the URL of the object under key
+func (s *Store) objectURL(key string) string {
+	return s.Endpoint + "/" + uriEncode(key, true)
+}
+
+// contentDisposition names a download: a plain ASCII filename for every client, and the
+// exact one for those that read RFC 6266's filename*
+func contentDisposition(filename string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, filename)
+	return `attachment; filename="` + fallback + `"; filename*=UTF-8''` + uriEncode(filename, false)
+}
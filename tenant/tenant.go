@@ -0,0 +1,111 @@
+// Medical Appointment Booking System - Tenant Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package tenant works out which organization a request is for. Each organization is
+// reachable at its own subdomain of PUBLIC_BASE_URL, e.g. https://acme.bookings.example.com
+// for the organization with the slug "acme"; the bare address is the default organization's.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"bookings/apierr"
+	"bookings/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+type subdomainContextKey struct{}
+
+// PublicBaseURL reads PUBLIC_BASE_URL, the address the API is reachable at from outside
+// (e.g. https://bookings.example.com), without a trailing slash; "" when it is not set
+func PublicBaseURL() string {
+	return strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+}
+
+// Middleware resolves the organization a request is for from the subdomain of PUBLIC_BASE_URL
+// it was made to, and makes the request's database calls work for it. Requests to the bare
+// address, or to any other host, are for the default organization until an access token says
+// otherwise. An unknown subdomain answers 404.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		slug, ok := subdomain(c.Request.Host)
+		if !ok {
+			c.Next()
+			return
+		}
+		org, err := database.GetOrganizationBySlug(c.Request.Context(), slug)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apierr.Abort(c, apierr.NotFound("Organization not found"))
+			return
+		}
+		if err != nil {
+			apierr.Abort(c, err)
+			return
+		}
+		ctx := database.WithOrganization(c.Request.Context(), org.ID)
+		c.Request = c.Request.WithContext(context.WithValue(ctx, subdomainContextKey{}, true))
+		c.Next()
+	}
+}
+
+// FromSubdomain reports whether the organization of a request context was resolved from the
+// subdomain the request was made to
+func FromSubdomain(ctx context.Context) bool {
+	resolved, _ := ctx.Value(subdomainContextKey{}).(bool)
+	return resolved
+}
+
+// subdomain returns the label host has in front of PUBLIC_BASE_URL's host, if any
+func subdomain(host string) (string, bool) {
+	base, err := url.Parse(PublicBaseURL())
+	if err != nil || base.Hostname() == "" {
+		return "", false
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(base.Hostname()))
+}
+
+// URL returns the address the organization ctx works for is reachable at: PUBLIC_BASE_URL
+// for the default organization and its subdomain of it for the others. It is "" when
+// PUBLIC_BASE_URL is not set or the organization cannot be loaded.
+func URL(ctx context.Context) string {
+	base := PublicBaseURL()
+	id := database.OrganizationFromContext(ctx)
+	if base == "" || id == database.DefaultOrganizationID {
+		return base
+	}
+	org, err := database.GetOrganization(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "tenant: loading organization", "organization_id", id, "error", err)
+		return ""
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	u.Host = org.Slug + "." + u.Host
+	return u.String()
+}
@@ -17,78 +17,114 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"bookings/apierr"
+	"bookings/availability"
+	"bookings/config"
 	"bookings/database"
+	"bookings/handlers/appointments"
 	"bookings/models"
+	"bookings/money"
+	"bookings/notifications"
+	"bookings/phi"
+	"bookings/timeutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
 func stringPtr(s string) *string {
 	return &s
 }
 
-func float64Ptr(f float64) *float64 {
-	return &f
-}
-
 func testDB() {
 	fmt.Println("=== Starting Database and API Tests ===")
+	ctx := context.Background()
 
 	// Initialize database connection
-	database.InitDB()
+	if err := database.InitDB(config.Default().Database); err != nil {
+		log.Fatal(err)
+	}
 	defer database.CloseDB()
 
 	fmt.Println("✅ Database connection initialized")
 
-	// Create tables
-	if err := database.CreateTables(); err != nil {
-		log.Fatalf("❌ Failed to create tables: %v", err)
+	// Bring the schema up to date
+	if err := database.Migrate(ctx); err != nil {
+		log.Fatalf("❌ Failed to apply migrations: %v", err)
+	}
+	fmt.Println("✅ Database migrations applied successfully")
+
+	// Patient data is encrypted with the keys from PHI_ENCRYPTION_KEYS and PHI_INDEX_KEY
+	if err := phi.Load(); err != nil {
+		log.Fatalf("❌ Failed to load PHI encryption keys: %v", err)
 	}
-	fmt.Println("✅ Database tables created successfully")
 
 	// Test Clinic CRUD
-	testClinicCRUD()
+	testClinicCRUD(ctx)
 
 	// Test Patient CRUD
-	testPatientCRUD()
+	testPatientCRUD(ctx)
 
 	// Test Employee CRUD
-	testEmployeeCRUD()
+	testEmployeeCRUD(ctx)
 
 	// Test Service CRUD
-	testServiceCRUD()
+	testServiceCRUD(ctx)
 
 	// Test Appointment CRUD
-	testAppointmentCRUD()
+	testAppointmentCRUD(ctx)
+
+	// Test concurrent bookings for the same slot
+	testConcurrentBooking(ctx)
+
+	// Test that one organization cannot read another's rows
+	testOrganizationIsolation(ctx)
 
 	// Test Waiting List CRUD
-	testWaitingListCRUD()
+	testWaitingListCRUD(ctx)
+
+	// Test UTC/DST conversions
+	testTimeConversions()
+	testTemplateExpansion()
+	testOvernightBookings()
+	testFollowUpReserve()
+	testSlotSlicing()
 
 	fmt.Println("=== All Tests Completed Successfully! ===")
 }
 
-func testClinicCRUD() {
+func testClinicCRUD(ctx context.Context) {
 	fmt.Println("\n--- Testing Clinic CRUD ---")
 
 	// Create clinic
 	clinic := &models.Clinic{
 		Name:    "Test Clinic",
 		Address: "123 Test Street",
-		Phone:   "+1234567890",
+		Phone:   "+14155550100",
 		Email:   "test@clinic.com",
 		Active:  true,
 	}
 
-	if err := database.CreateClinic(clinic); err != nil {
+	if err := database.CreateClinic(ctx, clinic); err != nil {
 		log.Printf("❌ Failed to create clinic: %v", err)
 		return
 	}
 	fmt.Printf("✅ Created clinic with ID: %d\n", clinic.ID)
 
 	// Get clinic
-	retrievedClinic, err := database.GetClinic(clinic.ID)
+	retrievedClinic, err := database.GetClinic(ctx, clinic.ID)
 	if err != nil {
 		log.Printf("❌ Failed to get clinic: %v", err)
 		return
@@ -97,14 +133,14 @@ func testClinicCRUD() {
 
 	// Update clinic
 	clinic.Address = "456 Updated Street"
-	if err := database.UpdateClinic(clinic.ID, clinic); err != nil {
+	if err := database.UpdateClinic(ctx, clinic.ID, clinic); err != nil {
 		log.Printf("❌ Failed to update clinic: %v", err)
 		return
 	}
 	fmt.Println("✅ Updated clinic successfully")
 
 	// Get all clinics
-	clinics, err := database.GetClinics()
+	clinics, _, err := database.GetClinics(ctx, database.Page{}, false, database.DefaultOrganizationID)
 	if err != nil {
 		log.Printf("❌ Failed to get clinics: %v", err)
 		return
@@ -112,14 +148,14 @@ func testClinicCRUD() {
 	fmt.Printf("✅ Found %d clinics\n", len(clinics))
 
 	// Delete clinic
-	if err := database.DeleteClinic(clinic.ID); err != nil {
+	if err := database.DeleteClinic(ctx, clinic.ID); err != nil {
 		log.Printf("❌ Failed to delete clinic: %v", err)
 		return
 	}
 	fmt.Println("✅ Deleted clinic successfully")
 }
 
-func testPatientCRUD() {
+func testPatientCRUD(ctx context.Context) {
 	fmt.Println("\n--- Testing Patient CRUD ---")
 
 	// Create patient
@@ -127,12 +163,12 @@ func testPatientCRUD() {
 	insuranceProvider := "Test Insurance"
 	insuranceID := "INS123456"
 	emergencyName := "Jane Doe"
-	emergencyPhone := "+0987654321"
+	emergencyPhone := "+14155550199"
 	patient := &models.Patient{
 		FirstName:             "John",
 		LastName:              "Doe",
 		Email:                 "john.doe@example.com",
-		Phone:                 "+1234567890",
+		Phone:                 "+14155550100",
 		DateOfBirth:           &dateOfBirth,
 		MedicalRecordNumber:   "MRN123456",
 		InsuranceProvider:     &insuranceProvider,
@@ -142,14 +178,14 @@ func testPatientCRUD() {
 		Active:                true,
 	}
 
-	if err := database.CreatePatient(patient); err != nil {
+	if err := database.CreatePatient(ctx, patient); err != nil {
 		log.Printf("❌ Failed to create patient: %v", err)
 		return
 	}
 	fmt.Printf("✅ Created patient with ID: %d\n", patient.ID)
 
 	// Get patient
-	retrievedPatient, err := database.GetPatient(patient.ID)
+	retrievedPatient, err := database.GetPatient(ctx, patient.ID)
 	if err != nil {
 		log.Printf("❌ Failed to get patient: %v", err)
 		return
@@ -157,15 +193,15 @@ func testPatientCRUD() {
 	fmt.Printf("✅ Retrieved patient: %s %s\n", retrievedPatient.FirstName, retrievedPatient.LastName)
 
 	// Update patient
-	patient.Phone = "+1111111111"
-	if err := database.UpdatePatient(patient.ID, patient); err != nil {
+	patient.Phone = "+14155550111"
+	if err := database.UpdatePatient(ctx, patient.ID, patient); err != nil {
 		log.Printf("❌ Failed to update patient: %v", err)
 		return
 	}
 	fmt.Println("✅ Updated patient successfully")
 
 	// Get all patients
-	patients, err := database.GetPatients()
+	patients, _, err := database.GetPatients(ctx, database.Page{}, false)
 	if err != nil {
 		log.Printf("❌ Failed to get patients: %v", err)
 		return
@@ -173,25 +209,25 @@ func testPatientCRUD() {
 	fmt.Printf("✅ Found %d patients\n", len(patients))
 
 	// Delete patient
-	if err := database.DeletePatient(patient.ID); err != nil {
+	if err := database.DeletePatient(ctx, patient.ID); err != nil {
 		log.Printf("❌ Failed to delete patient: %v", err)
 		return
 	}
 	fmt.Println("✅ Deleted patient successfully")
 }
 
-func testEmployeeCRUD() {
+func testEmployeeCRUD(ctx context.Context) {
 	fmt.Println("\n--- Testing Employee CRUD ---")
 
 	// First create a clinic for the employee
 	clinic := &models.Clinic{
 		Name:    "Employee Test Clinic",
 		Address: "789 Employee St",
-		Phone:   "+1234567890",
+		Phone:   "+14155550100",
 		Email:   "employee@clinic.com",
 		Active:  true,
 	}
-	if err := database.CreateClinic(clinic); err != nil {
+	if err := database.CreateClinic(ctx, clinic); err != nil {
 		log.Printf("❌ Failed to create clinic for employee: %v", err)
 		return
 	}
@@ -202,21 +238,21 @@ func testEmployeeCRUD() {
 		FirstName:     "Dr. Jane",
 		LastName:      "Smith",
 		Email:         "jane.smith@clinic.com",
-		Phone:         "+1234567890",
+		Phone:         "+14155550100",
 		LicenseNumber: "LIC123456",
 		Specialty:     "Cardiology",
 		Timezone:      "Asia/Colombo",
 		Active:        true,
 	}
 
-	if err := database.CreateEmployee(employee); err != nil {
+	if err := database.CreateEmployee(ctx, employee); err != nil {
 		log.Printf("❌ Failed to create employee: %v", err)
 		return
 	}
 	fmt.Printf("✅ Created employee with ID: %d\n", employee.ID)
 
 	// Get employee
-	retrievedEmployee, err := database.GetEmployee(employee.ID)
+	retrievedEmployee, err := database.GetEmployee(ctx, employee.ID)
 	if err != nil {
 		log.Printf("❌ Failed to get employee: %v", err)
 		return
@@ -224,15 +260,15 @@ func testEmployeeCRUD() {
 	fmt.Printf("✅ Retrieved employee: %s %s\n", retrievedEmployee.FirstName, retrievedEmployee.LastName)
 
 	// Update employee
-	employee.Phone = "+2222222222"
-	if err := database.UpdateEmployee(employee.ID, employee); err != nil {
+	employee.Phone = "+442079460222"
+	if err := database.UpdateEmployee(ctx, employee.ID, employee); err != nil {
 		log.Printf("❌ Failed to update employee: %v", err)
 		return
 	}
 	fmt.Println("✅ Updated employee successfully")
 
 	// Get all employees
-	employees, err := database.GetEmployees()
+	employees, _, err := database.GetEmployees(ctx, database.Page{}, false)
 	if err != nil {
 		log.Printf("❌ Failed to get employees: %v", err)
 		return
@@ -240,17 +276,17 @@ func testEmployeeCRUD() {
 	fmt.Printf("✅ Found %d employees\n", len(employees))
 
 	// Delete employee
-	if err := database.DeleteEmployee(employee.ID); err != nil {
+	if err := database.DeleteEmployee(ctx, employee.ID); err != nil {
 		log.Printf("❌ Failed to delete employee: %v", err)
 		return
 	}
 	fmt.Println("✅ Deleted employee successfully")
 
 	// Clean up clinic
-	database.DeleteClinic(clinic.ID)
+	database.DeleteClinic(ctx, clinic.ID)
 }
 
-func testServiceCRUD() {
+func testServiceCRUD(ctx context.Context) {
 	fmt.Println("\n--- Testing Service CRUD ---")
 
 	// Create service
@@ -258,19 +294,19 @@ func testServiceCRUD() {
 		Name:              "General Consultation",
 		Description:       "General medical consultation",
 		DurationMinutes:   30,
-		Price:             100.00,
+		Price:             money.New(10000, "USD"),
 		SpecialtyRequired: "General Medicine",
 		Active:            true,
 	}
 
-	if err := database.CreateService(service); err != nil {
+	if err := database.CreateService(ctx, service); err != nil {
 		log.Printf("❌ Failed to create service: %v", err)
 		return
 	}
 	fmt.Printf("✅ Created service with ID: %d\n", service.ID)
 
 	// Get service
-	retrievedService, err := database.GetService(service.ID)
+	retrievedService, err := database.GetService(ctx, service.ID)
 	if err != nil {
 		log.Printf("❌ Failed to get service: %v", err)
 		return
@@ -278,15 +314,15 @@ func testServiceCRUD() {
 	fmt.Printf("✅ Retrieved service: %s\n", retrievedService.Name)
 
 	// Update service
-	service.Price = 120.00
-	if err := database.UpdateService(service.ID, service); err != nil {
+	service.Price = money.New(12000, "USD")
+	if err := database.UpdateService(ctx, service.ID, service); err != nil {
 		log.Printf("❌ Failed to update service: %v", err)
 		return
 	}
 	fmt.Println("✅ Updated service successfully")
 
 	// Get all services
-	services, err := database.GetServices()
+	services, _, err := database.GetServices(ctx, database.Page{})
 	if err != nil {
 		log.Printf("❌ Failed to get services: %v", err)
 		return
@@ -294,28 +330,28 @@ func testServiceCRUD() {
 	fmt.Printf("✅ Found %d services\n", len(services))
 
 	// Delete service
-	if err := database.DeleteService(service.ID); err != nil {
+	if err := database.DeleteService(ctx, service.ID); err != nil {
 		log.Printf("❌ Failed to delete service: %v", err)
 		return
 	}
 	fmt.Println("✅ Deleted service successfully")
 }
 
-func testAppointmentCRUD() {
+func testAppointmentCRUD(ctx context.Context) {
 	fmt.Println("\n--- Testing Appointment CRUD ---")
 
 	// Create required entities first
-	clinic := &models.Clinic{Name: "Appointment Clinic", Address: "123 Appt St", Phone: "+1234567890", Email: "appt@clinic.com", Active: true}
-	database.CreateClinic(clinic)
+	clinic := &models.Clinic{Name: "Appointment Clinic", Address: "123 Appt St", Phone: "+14155550100", Email: "appt@clinic.com", Active: true}
+	database.CreateClinic(ctx, clinic)
 
-	patient := &models.Patient{FirstName: "Test", LastName: "Patient", Email: "test@patient.com", Phone: "+1234567890", DateOfBirth: stringPtr("1990-01-01"), MedicalRecordNumber: "MRN999", Active: true}
-	database.CreatePatient(patient)
+	patient := &models.Patient{FirstName: "Test", LastName: "Patient", Email: "test@patient.com", Phone: "+14155550100", DateOfBirth: stringPtr("1990-01-01"), MedicalRecordNumber: "MRN999", Active: true}
+	database.CreatePatient(ctx, patient)
 
-	employee := &models.Employee{ClinicID: clinic.ID, FirstName: "Dr. Test", LastName: "Doctor", Email: "test@doctor.com", Phone: "+1234567890", LicenseNumber: "LIC999", Specialty: "General", Timezone: "Asia/Colombo", Active: true}
-	database.CreateEmployee(employee)
+	employee := &models.Employee{ClinicID: clinic.ID, FirstName: "Dr. Test", LastName: "Doctor", Email: "test@doctor.com", Phone: "+14155550100", LicenseNumber: "LIC999", Specialty: "General", Timezone: "Asia/Colombo", Active: true}
+	database.CreateEmployee(ctx, employee)
 
-	service := &models.Service{Name: "Test Service", Description: "Test service", DurationMinutes: 30, Price: 50.00, SpecialtyRequired: "General", Active: true}
-	database.CreateService(service)
+	service := &models.Service{Name: "Test Service", Description: "Test service", DurationMinutes: 30, Price: money.New(5000, "USD"), SpecialtyRequired: "General", Active: true}
+	database.CreateService(ctx, service)
 
 	// Create appointment
 	startTime := time.Now().Add(24 * time.Hour).UTC() // Tomorrow
@@ -332,17 +368,17 @@ func testAppointmentCRUD() {
 		AppointmentType: stringPtr("INITIAL_CONSULTATION"),
 		Notes:           stringPtr("Test appointment"),
 		PaymentStatus:   "PENDING",
-		PaymentAmount:   float64Ptr(50.00),
+		PaymentAmount:   &money.Money{Amount: 5000, Currency: "USD"},
 	}
 
-	if err := database.CreateAppointment(appointment); err != nil {
+	if err := database.CreateAppointment(ctx, appointment); err != nil {
 		log.Printf("❌ Failed to create appointment: %v", err)
 		return
 	}
 	fmt.Printf("✅ Created appointment with ID: %d\n", appointment.ID)
 
 	// Get appointment
-	retrievedAppointment, err := database.GetAppointment(appointment.ID)
+	retrievedAppointment, err := database.GetAppointment(ctx, appointment.ID)
 	if err != nil {
 		log.Printf("❌ Failed to get appointment: %v", err)
 		return
@@ -351,14 +387,14 @@ func testAppointmentCRUD() {
 
 	// Update appointment
 	appointment.Notes = stringPtr("Updated test appointment")
-	if err := database.UpdateAppointment(appointment.ID, appointment); err != nil {
+	if err := database.UpdateAppointment(ctx, appointment.ID, appointment); err != nil {
 		log.Printf("❌ Failed to update appointment: %v", err)
 		return
 	}
 	fmt.Println("✅ Updated appointment successfully")
 
 	// Get all appointments
-	appointments, err := database.GetAppointments()
+	appointments, _, err := database.GetAppointments(ctx, database.AppointmentFilter{}, database.Page{})
 	if err != nil {
 		log.Printf("❌ Failed to get appointments: %v", err)
 		return
@@ -366,31 +402,180 @@ func testAppointmentCRUD() {
 	fmt.Printf("✅ Found %d appointments\n", len(appointments))
 
 	// Delete appointment
-	if err := database.DeleteAppointment(appointment.ID); err != nil {
+	if err := database.DeleteAppointment(ctx, appointment.ID); err != nil {
 		log.Printf("❌ Failed to delete appointment: %v", err)
 		return
 	}
 	fmt.Println("✅ Deleted appointment successfully")
 
 	// Clean up
-	database.DeleteService(service.ID)
-	database.DeleteEmployee(employee.ID)
-	database.DeletePatient(patient.ID)
-	database.DeleteClinic(clinic.ID)
+	database.DeleteService(ctx, service.ID)
+	database.DeleteEmployee(ctx, employee.ID)
+	database.DeletePatient(ctx, patient.ID)
+	database.DeleteClinic(ctx, clinic.ID)
+}
+
+// testConcurrentBooking races two bookings for the same employee slot the way the booking handlers
+// do: lock the employee's schedule, check for overlapping blocks, then insert. Exactly one must win.
+func testConcurrentBooking(ctx context.Context) {
+	fmt.Println("\n--- Testing Concurrent Booking ---")
+
+	clinic := &models.Clinic{Name: "Race Clinic", Address: "1 Race St", Phone: "+14155550100", Email: "race@clinic.com", Active: true}
+	database.CreateClinic(ctx, clinic)
+
+	patient := &models.Patient{FirstName: "Race", LastName: "Patient", Email: "race@patient.com", Phone: "+14155550100", DateOfBirth: stringPtr("1990-01-01"), MedicalRecordNumber: "MRN998", Active: true}
+	database.CreatePatient(ctx, patient)
+
+	employee := &models.Employee{ClinicID: clinic.ID, FirstName: "Dr. Race", LastName: "Doctor", Email: "race@doctor.com", Phone: "+14155550100", LicenseNumber: "LIC998", Specialty: "General", Timezone: "UTC", Active: true}
+	database.CreateEmployee(ctx, employee)
+
+	// The 15 minute clean-up after each booking is what makes back-to-back slots clash; the
+	// slots themselves do not overlap, so the database constraint cannot tell them apart
+	service := &models.Service{Name: "Race Service", Description: "Race service", DurationMinutes: 30, BufferAfterMinutes: 15, Price: money.New(5000, "USD"), SpecialtyRequired: "General", Active: true}
+	database.CreateService(ctx, service)
+
+	sender, err := notifications.NewSender()
+	if err != nil {
+		log.Printf("❌ Failed to create notification sender: %v", err)
+		return
+	}
+	h := appointments.New(database.NewRepos(), sender, nil)
+
+	// Two bookings through the same handler POST /appointments runs
+	first := time.Now().UTC().Truncate(24 * time.Hour).Add(48*time.Hour + 10*time.Hour)
+	book := func(start time.Time) *gin.Context {
+		body, _ := json.Marshal(models.Appointment{
+			PatientID:     patient.ID,
+			EmployeeID:    employee.ID,
+			ServiceID:     service.ID,
+			ClinicID:      clinic.ID,
+			StartDatetime: start,
+			EndDatetime:   start.Add(30 * time.Minute),
+			Status:        "SCHEDULED",
+			PaymentStatus: "PENDING",
+		})
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/appointments", bytes.NewReader(body)).WithContext(ctx)
+		h.CreateAppointment(c)
+		return c
+	}
+
+	var (
+		wg       sync.WaitGroup
+		finished atomic.Int32
+		results  [2]*gin.Context
+	)
+	// Holding the employee's schedule lock makes both bookings queue behind it; neither may
+	// get past it before it is released
+	err = database.WithTx(ctx, func(ctx context.Context) error {
+		if err := database.LockEmployeeSchedule(ctx, employee.ID); err != nil {
+			return err
+		}
+		for i, start := range []time.Time{first, first.Add(30 * time.Minute)} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = book(start)
+				finished.Add(1)
+			}()
+		}
+		time.Sleep(200 * time.Millisecond)
+		if n := finished.Load(); n != 0 {
+			log.Printf("❌ %d booking(s) finished while the employee's schedule was locked", n)
+		} else {
+			fmt.Println("✅ Bookings wait for the employee's schedule lock")
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Failed to lock the employee's schedule: %v", err)
+	}
+	wg.Wait()
+
+	var booked, rejected int
+	for _, c := range results {
+		var apiErr *apierr.Error
+		switch {
+		case len(c.Errors) == 0 && c.Writer.Status() == http.StatusCreated:
+			booked++
+		case errors.As(c.Errors.Last(), &apiErr) && apiErr.Status == http.StatusConflict && apiErr.Message == database.ErrAppointmentConflict.Error():
+			rejected++
+		default:
+			log.Printf("❌ Unexpected booking result: %d %v", c.Writer.Status(), c.Errors.Last())
+		}
+	}
+	if booked == 1 && rejected == 1 {
+		fmt.Println("✅ Of two back-to-back bookings that break the buffer, the booking check rejected the second")
+	} else {
+		log.Printf("❌ Back-to-back bookings: %d succeeded, %d rejected; want 1 and 1", booked, rejected)
+	}
+
+	// Clean up
+	bookings, _ := database.GetEmployeeBookings(ctx, employee.ID, first, first.Add(time.Hour))
+	for _, a := range bookings {
+		database.DeleteAppointment(ctx, a.ID)
+	}
+	database.DeleteService(ctx, service.ID)
+	database.DeleteEmployee(ctx, employee.ID)
+	database.DeletePatient(ctx, patient.ID)
+	database.DeleteClinic(ctx, clinic.ID)
 }
 
-func testWaitingListCRUD() {
+func testOrganizationIsolation(ctx context.Context) {
+	fmt.Println("\n--- Testing Organization Isolation ---")
+
+	// Superusers and BYPASSRLS roles skip row-level security, so there is nothing to test
+	var bypass bool
+	if err := database.DB.QueryRow(ctx, "SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&bypass); err != nil {
+		log.Printf("❌ Failed to read the database role: %v", err)
+		return
+	}
+	if bypass {
+		fmt.Println("⚠️  Skipped: the database role bypasses row-level security")
+		return
+	}
+
+	var orgID int
+	if err := database.DB.QueryRow(ctx, "INSERT INTO organizations (name, slug) VALUES ('Isolation Test', 'isolation-test') RETURNING id").Scan(&orgID); err != nil {
+		log.Printf("❌ Failed to create organization: %v", err)
+		return
+	}
+	orgCtx := database.WithOrganization(ctx, orgID)
+
+	patient := &models.Patient{FirstName: "Other", LastName: "Tenant", Email: "other@tenant.com", Phone: "+14155550100", DateOfBirth: stringPtr("1990-01-01"), MedicalRecordNumber: "MRN997", Active: true}
+	if err := database.CreatePatient(orgCtx, patient); err != nil {
+		log.Printf("❌ Failed to create patient in organization %d: %v", orgID, err)
+	} else {
+		if _, err := database.GetPatient(orgCtx, patient.ID); err != nil {
+			log.Printf("❌ Organization cannot read its own patient: %v", err)
+		} else {
+			fmt.Println("✅ Organization reads its own patient")
+		}
+		if _, err := database.GetPatient(ctx, patient.ID); errors.Is(err, pgx.ErrNoRows) {
+			fmt.Println("✅ Default organization cannot read another organization's patient")
+		} else {
+			log.Printf("❌ Default organization read another organization's patient: %v", err)
+		}
+		// Removed outright rather than soft-deleted so the organization can go too
+		database.DB.Exec(orgCtx, "DELETE FROM patients WHERE id = $1", patient.ID)
+	}
+
+	// Clean up
+	database.DB.Exec(ctx, "DELETE FROM organizations WHERE id = $1", orgID)
+}
+
+func testWaitingListCRUD(ctx context.Context) {
 	fmt.Println("\n--- Testing Waiting List CRUD ---")
 
 	// Create required entities
-	clinic := &models.Clinic{Name: "Waiting Clinic", Address: "456 Wait St", Phone: "+1234567890", Email: "wait@clinic.com", Active: true}
-	database.CreateClinic(clinic)
+	clinic := &models.Clinic{Name: "Waiting Clinic", Address: "456 Wait St", Phone: "+14155550100", Email: "wait@clinic.com", Active: true}
+	database.CreateClinic(ctx, clinic)
 
-	patient := &models.Patient{FirstName: "Wait", LastName: "Patient", Email: "wait@patient.com", Phone: "+1234567890", DateOfBirth: stringPtr("1990-01-01"), MedicalRecordNumber: "MRN888", Active: true}
-	database.CreatePatient(patient)
+	patient := &models.Patient{FirstName: "Wait", LastName: "Patient", Email: "wait@patient.com", Phone: "+14155550100", DateOfBirth: stringPtr("1990-01-01"), MedicalRecordNumber: "MRN888", Active: true}
+	database.CreatePatient(ctx, patient)
 
-	service := &models.Service{Name: "Wait Service", Description: "Waiting service", DurationMinutes: 45, Price: 75.00, SpecialtyRequired: "General", Active: true}
-	database.CreateService(service)
+	service := &models.Service{Name: "Wait Service", Description: "Waiting service", DurationMinutes: 45, Price: money.New(7500, "USD"), SpecialtyRequired: "General", Active: true}
+	database.CreateService(ctx, service)
 
 	// Create waiting list item
 	waitingItem := &models.WaitingList{
@@ -402,14 +587,14 @@ func testWaitingListCRUD() {
 		Status:        "ACTIVE",
 	}
 
-	if err := database.CreateWaitingListItem(waitingItem); err != nil {
+	if err := database.CreateWaitingListItem(ctx, waitingItem); err != nil {
 		log.Printf("❌ Failed to create waiting list item: %v", err)
 		return
 	}
 	fmt.Printf("✅ Created waiting list item with ID: %d\n", waitingItem.ID)
 
 	// Get waiting list item
-	retrievedItem, err := database.GetWaitingListItem(waitingItem.ID)
+	retrievedItem, err := database.GetWaitingListItem(ctx, waitingItem.ID)
 	if err != nil {
 		log.Printf("❌ Failed to get waiting list item: %v", err)
 		return
@@ -418,14 +603,14 @@ func testWaitingListCRUD() {
 
 	// Update waiting list item
 	waitingItem.Notes = stringPtr("Updated urgent notes")
-	if err := database.UpdateWaitingListItem(waitingItem.ID, waitingItem); err != nil {
+	if err := database.UpdateWaitingListItem(ctx, waitingItem.ID, waitingItem); err != nil {
 		log.Printf("❌ Failed to update waiting list item: %v", err)
 		return
 	}
 	fmt.Println("✅ Updated waiting list item successfully")
 
 	// Get all waiting list items
-	waitingList, err := database.GetWaitingList()
+	waitingList, _, err := database.GetWaitingList(ctx, database.Page{})
 	if err != nil {
 		log.Printf("❌ Failed to get waiting list: %v", err)
 		return
@@ -433,16 +618,238 @@ func testWaitingListCRUD() {
 	fmt.Printf("✅ Found %d items in waiting list\n", len(waitingList))
 
 	// Delete waiting list item
-	if err := database.DeleteWaitingListItem(waitingItem.ID); err != nil {
+	if err := database.DeleteWaitingListItem(ctx, waitingItem.ID); err != nil {
 		log.Printf("❌ Failed to delete waiting list item: %v", err)
 		return
 	}
 	fmt.Println("✅ Deleted waiting list item successfully")
 
 	// Clean up
-	database.DeleteService(service.ID)
-	database.DeletePatient(patient.ID)
-	database.DeleteClinic(clinic.ID)
+	database.DeleteService(ctx, service.ID)
+	database.DeletePatient(ctx, patient.ID)
+	database.DeleteClinic(ctx, clinic.ID)
+}
+
+func testTimeConversions() {
+	fmt.Println("\n--- Testing UTC/DST Conversions ---")
+
+	// Wall-clock times around DST transitions in zones with northern, southern and no DST
+	cases := []struct {
+		name     string
+		timezone string
+		date     string
+		clock    string
+		wantUTC  string
+		dayHours float64
+	}{
+		{"New York regular day", "America/New_York", "2025-06-10", "09:00", "2025-06-10T13:00:00Z", 24},
+		{"New York spring-forward gap", "America/New_York", "2025-03-09", "02:30", "2025-03-09T07:30:00Z", 23},
+		{"New York fall-back repeat", "America/New_York", "2025-11-02", "01:30", "2025-11-02T05:30:00Z", 25},
+		{"London spring-forward gap", "Europe/London", "2025-03-30", "01:30", "2025-03-30T01:30:00Z", 23},
+		{"London fall-back repeat", "Europe/London", "2025-10-26", "01:30", "2025-10-26T00:30:00Z", 25},
+		{"Sydney fall-back repeat", "Australia/Sydney", "2025-04-06", "02:30", "2025-04-05T15:30:00Z", 25},
+		{"Sydney spring-forward gap", "Australia/Sydney", "2025-10-05", "02:30", "2025-10-04T16:30:00Z", 23},
+		{"Colombo (no DST)", "Asia/Colombo", "2025-03-09", "09:00", "2025-03-09T03:30:00Z", 24},
+	}
+
+	for _, tc := range cases {
+		loc := timeutil.LoadLocation(tc.timezone)
+		date, err := timeutil.ParseDate(tc.date)
+		if err != nil {
+			log.Printf("❌ %s: %v", tc.name, err)
+			continue
+		}
+		got, err := timeutil.WallClockString(date, tc.clock, loc)
+		if err != nil {
+			log.Printf("❌ %s: %v", tc.name, err)
+			continue
+		}
+		if utc := timeutil.ToUTC(got).Format(time.RFC3339); utc != tc.wantUTC {
+			log.Printf("❌ %s: %s %s converted to %s, want %s", tc.name, tc.date, tc.clock, utc, tc.wantUTC)
+			continue
+		}
+		start, end := timeutil.DayBounds(date, loc)
+		if hours := end.Sub(start).Hours(); hours != tc.dayHours {
+			log.Printf("❌ %s: day is %.0f hours long, want %.0f", tc.name, hours, tc.dayHours)
+			continue
+		}
+		if back := timeutil.LocalDate(got, loc).Format(timeutil.DateLayout); back != tc.date {
+			log.Printf("❌ %s: converted back to date %s, want %s", tc.name, back, tc.date)
+			continue
+		}
+		fmt.Printf("✅ %s\n", tc.name)
+	}
+}
+
+func testTemplateExpansion() {
+	fmt.Println("\n--- Testing Work Template Expansion Across DST ---")
+
+	// Each template is expanded on the days around a transition: day shifts keep their local
+	// hours on both sides, and windows running through the change lose or gain its length
+	cases := []struct {
+		name      string
+		timezone  string
+		date      string
+		start     string
+		end       string
+		wantStart string
+		wantEnd   string
+		hours     float64
+	}{
+		{"New York day shift before spring-forward", "America/New_York", "2025-03-08", "09:00", "17:00", "2025-03-08T14:00:00Z", "2025-03-08T22:00:00Z", 8},
+		{"New York day shift after spring-forward", "America/New_York", "2025-03-10", "09:00", "17:00", "2025-03-10T13:00:00Z", "2025-03-10T21:00:00Z", 8},
+		{"New York night shift over spring-forward", "America/New_York", "2025-03-08", "22:00", "06:00", "2025-03-09T03:00:00Z", "2025-03-09T10:00:00Z", 7},
+		{"New York window spanning the gap", "America/New_York", "2025-03-09", "01:00", "03:00", "2025-03-09T06:00:00Z", "2025-03-09T07:00:00Z", 1},
+		{"New York night shift over fall-back", "America/New_York", "2025-11-01", "22:00", "06:00", "2025-11-02T02:00:00Z", "2025-11-02T11:00:00Z", 9},
+		{"New York day shift after fall-back", "America/New_York", "2025-11-03", "09:00", "17:00", "2025-11-03T14:00:00Z", "2025-11-03T22:00:00Z", 8},
+		{"London day shift before spring-forward", "Europe/London", "2025-03-29", "09:00", "17:00", "2025-03-29T09:00:00Z", "2025-03-29T17:00:00Z", 8},
+		{"London day shift after spring-forward", "Europe/London", "2025-03-31", "09:00", "17:00", "2025-03-31T08:00:00Z", "2025-03-31T16:00:00Z", 8},
+		{"London night shift over fall-back", "Europe/London", "2025-10-25", "22:00", "06:00", "2025-10-25T21:00:00Z", "2025-10-26T06:00:00Z", 9},
+		{"Sydney night shift over fall-back", "Australia/Sydney", "2025-04-05", "22:00", "06:00", "2025-04-05T11:00:00Z", "2025-04-05T20:00:00Z", 9},
+		{"Sydney day shift after spring-forward", "Australia/Sydney", "2025-10-06", "09:00", "17:00", "2025-10-05T22:00:00Z", "2025-10-06T06:00:00Z", 8},
+		{"Santiago day shift before spring-forward", "America/Santiago", "2025-09-06", "09:00", "17:00", "2025-09-06T13:00:00Z", "2025-09-06T21:00:00Z", 8},
+		{"Santiago shift from skipped midnight", "America/Santiago", "2025-09-07", "00:00", "08:00", "2025-09-07T04:00:00Z", "2025-09-07T11:00:00Z", 7},
+		{"Santiago day shift after spring-forward", "America/Santiago", "2025-09-08", "09:00", "17:00", "2025-09-08T12:00:00Z", "2025-09-08T20:00:00Z", 8},
+		{"Lord Howe half-hour spring-forward", "Australia/Lord_Howe", "2025-10-05", "01:00", "03:00", "2025-10-04T14:30:00Z", "2025-10-04T16:00:00Z", 1.5},
+		{"Colombo (no DST)", "Asia/Colombo", "2025-03-09", "09:00", "17:00", "2025-03-09T03:30:00Z", "2025-03-09T11:30:00Z", 8},
+	}
+
+	for _, tc := range cases {
+		loc := timeutil.LoadLocation(tc.timezone)
+		date, err := timeutil.ParseDate(tc.date)
+		if err != nil {
+			log.Printf("❌ %s: %v", tc.name, err)
+			continue
+		}
+		templates := []models.WorkTemplate{
+			{Weekday: timeutil.ISOWeekday(date), StartTime: stringPtr(tc.start), EndTime: stringPtr(tc.end), IsActive: true},
+			{Weekday: timeutil.ISOWeekday(date.AddDate(0, 0, 1)), StartTime: stringPtr(tc.start), EndTime: stringPtr(tc.end), IsActive: true},
+		}
+		windows, err := availability.TemplateWindows(templates, date, loc)
+		if err != nil {
+			log.Printf("❌ %s: %v", tc.name, err)
+			continue
+		}
+		if len(windows) != 1 {
+			log.Printf("❌ %s: expanded to %d windows, want 1", tc.name, len(windows))
+			continue
+		}
+		w := windows[0]
+		if start, end := w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339); start != tc.wantStart || end != tc.wantEnd {
+			log.Printf("❌ %s: expanded to %s-%s, want %s-%s", tc.name, start, end, tc.wantStart, tc.wantEnd)
+			continue
+		}
+		if hours := w.Duration().Hours(); hours != tc.hours {
+			log.Printf("❌ %s: window is %.1f hours long, want %.1f", tc.name, hours, tc.hours)
+			continue
+		}
+		fmt.Printf("✅ %s\n", tc.name)
+	}
+}
+
+func testOvernightBookings() {
+	fmt.Println("\n--- Testing Overnight Booking Rules ---")
+
+	loc := timeutil.LoadLocation("America/New_York")
+	at := func(value string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02 15:04", value, loc)
+		return t
+	}
+	consult := &models.Service{Name: "Consultation"}
+	sleepStudy := &models.Service{Name: "Sleep Study", AllowsMultiDay: true}
+
+	cases := []struct {
+		name    string
+		service *models.Service
+		start   string
+		end     string
+		allowed bool
+	}{
+		{"same-day consultation", consult, "2025-06-10 09:00", "2025-06-10 09:30", true},
+		{"consultation ending at midnight", consult, "2025-06-10 23:30", "2025-06-11 00:00", true},
+		{"consultation past midnight", consult, "2025-06-10 23:30", "2025-06-11 00:15", false},
+		{"overnight sleep study", sleepStudy, "2025-06-10 21:00", "2025-06-11 07:00", true},
+		{"sleep study across fall-back", sleepStudy, "2025-11-01 21:00", "2025-11-02 07:00", true},
+		{"end before start", sleepStudy, "2025-06-11 07:00", "2025-06-10 21:00", false},
+		{"longer than the maximum span", sleepStudy, "2025-06-01 09:00", "2025-06-09 09:00", false},
+	}
+
+	for _, tc := range cases {
+		err := availability.CheckSpan(tc.service, at(tc.start), at(tc.end), loc)
+		if (err == nil) != tc.allowed {
+			log.Printf("❌ %s: allowed=%v, want %v (%v)", tc.name, err == nil, tc.allowed, err)
+			continue
+		}
+		fmt.Printf("✅ %s\n", tc.name)
+	}
+}
+
+func testFollowUpReserve() {
+	fmt.Println("\n--- Testing Follow-up Reserve ---")
+
+	loc := time.UTC
+	now := time.Date(2025, 6, 2, 9, 0, 0, 0, loc)
+	day := time.Date(2025, 6, 20, 0, 0, 0, 0, loc)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	// A 09:00-13:00 clinic with a quarter of the time held for follow-ups: 3 hours of general capacity
+	windows := []availability.Interval{{Start: at(9, 0), End: at(13, 0)}}
+	employee := &models.Employee{FollowUpReservePercent: 25, FollowUpReleaseDays: 7}
+	booked := []models.Appointment{
+		{StartDatetime: at(9, 0), EndDatetime: at(11, 0), AppointmentType: stringPtr("INITIAL_CONSULTATION")},
+		{StartDatetime: at(11, 0), EndDatetime: at(11, 30), AppointmentType: stringPtr("FOLLOW_UP")},
+	}
+	booking := func(start time.Time, minutes int, kind string) *models.Appointment {
+		return &models.Appointment{StartDatetime: start, EndDatetime: start.Add(time.Duration(minutes) * time.Minute), AppointmentType: stringPtr(kind)}
+	}
+
+	cases := []struct {
+		name    string
+		booking *models.Appointment
+		now     time.Time
+		allowed bool
+	}{
+		{"new patient within general capacity", booking(at(11, 30), 60, "INITIAL_CONSULTATION"), now, true},
+		{"new patient eating into the reserve", booking(at(11, 30), 90, "INITIAL_CONSULTATION"), now, false},
+		{"follow-up using the reserve", booking(at(11, 30), 90, "FOLLOW_UP"), now, true},
+		{"reserve released close to the day", booking(at(11, 30), 90, "INITIAL_CONSULTATION"), day.AddDate(0, 0, -7), true},
+	}
+
+	for _, tc := range cases {
+		err := availability.CheckFollowUpReserve(employee, tc.booking, windows, booked, tc.now, loc)
+		if (err == nil) != tc.allowed {
+			log.Printf("❌ %s: allowed=%v, want %v (%v)", tc.name, err == nil, tc.allowed, err)
+			continue
+		}
+		fmt.Printf("✅ %s\n", tc.name)
+	}
+}
+
+func testSlotSlicing() {
+	fmt.Println("\n--- Testing Slot Slicing ---")
+
+	day := time.Date(2025, 6, 20, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	// 09:00-12:00 with a 10:00-10:45 booking and a held 11:30-12:00 slot
+	windows := []availability.Interval{{Start: at(9, 0), End: at(12, 0)}}
+	busy := []availability.Interval{{Start: at(10, 0), End: at(10, 45)}, {Start: at(11, 30), End: at(12, 0)}}
+	slots := availability.Slice(availability.Subtract(windows, busy), 30*time.Minute)
+
+	want := []string{"09:00", "09:30", "10:45"}
+	if len(slots) != len(want) {
+		log.Printf("❌ Expected %d slots, got %d", len(want), len(slots))
+		return
+	}
+	for i, slot := range slots {
+		if got := slot.Start.Format("15:04"); got != want[i] {
+			log.Printf("❌ Slot %d starts at %s, want %s", i, got, want[i])
+			return
+		}
+	}
+	fmt.Println("✅ Free time sliced into 30-minute slots around bookings and holds")
 }
 
 // To run the tests, call testDB() from your main application or create a separate test binary
@@ -0,0 +1,148 @@
+// Medical Appointment Booking System - Time Utilities Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package timeutil holds the explicit conversions between UTC storage and the local
+// wall-clock times employees and patients work with. All timestamps are stored in
+// UTC; local times only exist at the edges (templates, rendering, day boundaries).
+package timeutil
+
+import (
+	"fmt"
+	"time"
+
+	// Embed the IANA timezone database so conversions work on hosts without
+	// zoneinfo installed (e.g. Windows servers)
+	_ "time/tzdata"
+)
+
+// DateLayout is the calendar date format used throughout the API
+const DateLayout = "2006-01-02"
+
+// ClockLayout is the wall-clock format used by work templates and day overrides
+const ClockLayout = "15:04"
+
+// LoadLocation returns the named IANA timezone, falling back to UTC when the name is
+// empty or unknown
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ToUTC normalizes a timestamp for storage
+func ToUTC(t time.Time) time.Time {
+	return t.UTC()
+}
+
+// ParseDate parses a "YYYY-MM-DD" calendar date
+func ParseDate(date string) (time.Time, error) {
+	return time.Parse(DateLayout, date)
+}
+
+// AgeOn returns how old, in whole years, someone born on dateOfBirth ("YYYY-MM-DD") is on
+// day. A birthday on 29 February is taken to pass on 1 March in other years.
+func AgeOn(dateOfBirth string, day time.Time) (int, error) {
+	born, err := ParseDate(dateOfBirth)
+	if err != nil {
+		return 0, err
+	}
+	age := day.Year() - born.Year()
+	if day.Month() < born.Month() || (day.Month() == born.Month() && day.Day() < born.Day()) {
+		age--
+	}
+	return age, nil
+}
+
+// ParseClock parses an "HH:MM" wall-clock time into hours and minutes
+func ParseClock(clock string) (hour, minute int, err error) {
+	t, err := time.Parse(ClockLayout, clock)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q: %w", clock, err)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// WallClock converts a local calendar date and wall-clock time in loc to an instant,
+// resolving DST transitions deterministically:
+//   - a time skipped by spring-forward is moved later by the length of the gap
+//     (02:30 on a 02:00→03:00 night becomes 03:30), so shifts keep their length
+//   - a time repeated by fall-back resolves to its first occurrence
+func WallClock(date time.Time, hour, minute int, loc *time.Location) time.Time {
+	y, m, d := date.Date()
+	naive := time.Date(y, m, d, hour, minute, 0, 0, time.UTC)
+
+	// The offsets in force shortly before and after the wall-clock time cover both
+	// sides of any transition on that day
+	_, before := naive.Add(-12 * time.Hour).In(loc).Zone()
+	_, after := naive.Add(12 * time.Hour).In(loc).Zone()
+
+	var match time.Time
+	for _, offset := range []int{before, after} {
+		candidate := naive.Add(-time.Duration(offset) * time.Second)
+		local := candidate.In(loc)
+		ly, lm, ld := local.Date()
+		if local.Hour() == hour && local.Minute() == minute && ly == y && lm == m && ld == d {
+			if match.IsZero() || candidate.Before(match) {
+				match = candidate
+			}
+		}
+	}
+	if match.IsZero() {
+		// Skipped by spring-forward: interpret with the pre-transition offset
+		match = naive.Add(-time.Duration(before) * time.Second)
+	}
+	return match.In(loc)
+}
+
+// WallClockString is WallClock for an "HH:MM" time
+func WallClockString(date time.Time, clock string, loc *time.Location) (time.Time, error) {
+	hour, minute, err := ParseClock(clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return WallClock(date, hour, minute, loc), nil
+}
+
+// DayBounds returns the UTC instants of local midnight starting the date and the
+// following local midnight; the day is 23 or 25 hours long across DST changes
+func DayBounds(date time.Time, loc *time.Location) (start, end time.Time) {
+	start = WallClock(date, 0, 0, loc)
+	end = WallClock(date.AddDate(0, 0, 1), 0, 0, loc)
+	return start.UTC(), end.UTC()
+}
+
+// LocalDate returns the calendar date of an instant as seen in loc
+func LocalDate(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// ISOWeekday maps a date to ISO weekday numbering (1 = Monday ... 7 = Sunday), as
+// used by work_templates
+func ISOWeekday(date time.Time) int {
+	return (int(date.Weekday())+6)%7 + 1
+}
+
+// FormatIn renders an instant as RFC 3339 in the named timezone, e.g. for showing a
+// patient their appointment time in their own zone
+func FormatIn(t time.Time, timezone string) string {
+	return t.In(LoadLocation(timezone)).Format(time.RFC3339)
+}
@@ -0,0 +1,94 @@
+// Medical Appointment Booking System - Waiting List Matching Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package waitlist
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"bookings/database"
+	"bookings/models"
+	"bookings/notifications"
+)
+
+// DefaultUrgentSLA is how long an URGENT entry may wait before it is escalated to staff when
+// WAITING_LIST_URGENT_SLA is not set
+const DefaultUrgentSLA = 24 * time.Hour
+
+// DefaultMaxAge is how long an entry may wait before it expires when WAITING_LIST_MAX_AGE is not set
+const DefaultMaxAge = 90 * 24 * time.Hour
+
+// UrgentSLA reads how long URGENT entries may wait from the WAITING_LIST_URGENT_SLA
+// environment variable (e.g. "8h")
+func UrgentSLA() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("WAITING_LIST_URGENT_SLA")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultUrgentSLA
+}
+
+// MaxAge reads how long entries may wait before they expire from the WAITING_LIST_MAX_AGE
+// environment variable (e.g. "720h"); "0" keeps them until their requested date passes
+func MaxAge() time.Duration {
+	raw := os.Getenv("WAITING_LIST_MAX_AGE")
+	if raw == "0" {
+		return 0
+	}
+	if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+		return d
+	}
+	return DefaultMaxAge
+}
+
+// NotifyEscalation emails staff that an URGENT entry has waited longer than sla: the clinic
+// of the entry's preferred employee and WAITING_LIST_ESCALATION_EMAIL, whichever are set.
+// Failures are logged, not returned, so one entry cannot hold up the others.
+func NotifyEscalation(ctx context.Context, sender notifications.Sender, item *models.WaitingList, sla time.Duration) {
+	var recipients []string
+	if item.PreferredEmployeeID != nil {
+		if employee, err := database.GetEmployee(ctx, *item.PreferredEmployeeID); err == nil {
+			if clinic, err := database.GetClinic(ctx, employee.ClinicID); err == nil && clinic.Email != "" {
+				recipients = append(recipients, clinic.Email)
+			}
+		}
+	}
+	if email := os.Getenv("WAITING_LIST_ESCALATION_EMAIL"); email != "" && !slices.Contains(recipients, email) {
+		recipients = append(recipients, email)
+	}
+	if len(recipients) == 0 {
+		slog.WarnContext(ctx, "waiting list: no staff address for escalation", "waiting_list_id", item.ID)
+		return
+	}
+
+	serviceName := fmt.Sprintf("service #%d", item.ServiceID)
+	if service, err := database.GetService(ctx, item.ServiceID); err == nil {
+		serviceName = service.Name
+	}
+	subject := fmt.Sprintf("Urgent waiting list entry #%d is overdue", item.ID)
+	body := fmt.Sprintf("Waiting list entry #%d (patient #%d, %s) was added %s and is still %s, past the %s allowed for URGENT entries. Please offer the patient a slot.",
+		item.ID, item.PatientID, serviceName, item.CreatedAt.UTC().Format(time.RFC3339), item.Status, sla)
+	for _, recipient := range recipients {
+		msg := notifications.Message{Channel: notifications.ChannelEmail, Recipient: recipient, Subject: subject, Body: body, Template: notifications.TemplateWaitlistEscalation}
+		if err := sender.Send(ctx, msg); err != nil {
+			slog.ErrorContext(ctx, "waiting list: notifying staff of escalation", "waiting_list_id", item.ID, "error", err)
+		}
+	}
+}
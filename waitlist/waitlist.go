@@ -0,0 +1,300 @@
+// Medical Appointment Booking System - Waiting List Matching Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package waitlist offers freed or newly opened slots to patients on the waiting list. An
+// offer is a slot hold in the patient's name, so nobody else can take the time while the
+// clinic confirms it, and the entry moves to CONTACTED.
+package waitlist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"bookings/audit"
+	"bookings/availability"
+	"bookings/database"
+	"bookings/models"
+	"bookings/notifications"
+	"bookings/timeutil"
+	"bookings/webhooks"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultOfferTTL is how long an offered slot is held when WAITING_LIST_OFFER_TTL is not set
+const DefaultOfferTTL = 2 * time.Hour
+
+// SearchDays is how far ahead a manual offer looks for a free slot when the entry has no
+// requested date
+const SearchDays = 14
+
+// ErrNotOfferable is returned for entries that are no longer waiting (SCHEDULED or EXPIRED)
+var ErrNotOfferable = errors.New("only active or contacted waiting list entries can be offered a slot")
+
+// ErrNoSlot is returned when no free slot matches the entry
+var ErrNoSlot = errors.New("no free slot matches this waiting list entry")
+
+// Offer is a slot held for a waiting list entry
+type Offer struct {
+	WaitingListID int             `json:"waiting_list_id"`
+	PatientID     int             `json:"patient_id"`
+	Hold          models.SlotHold `json:"hold"`
+}
+
+// OfferTTL reads the offer hold lifetime from the WAITING_LIST_OFFER_TTL environment variable (e.g. "4h")
+func OfferTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("WAITING_LIST_OFFER_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultOfferTTL
+}
+
+// FillOpening offers slots overlapping [from, to) with the employee to the active waiting
+// list entries they can serve, most urgent first. Each entry gets at most one offer and
+// entries with a requested date only match slots on that date.
+func FillOpening(ctx context.Context, sender notifications.Sender, employeeID int, from, to time.Time) ([]Offer, error) {
+	employee, err := database.GetEmployee(ctx, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := database.GetWaitingListCandidates(ctx, employee.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	loc := availability.Location(employee)
+	services := map[int]*models.Service{}
+	offers := []Offer{}
+	for _, candidate := range candidates {
+		service, ok := services[candidate.ServiceID]
+		if !ok {
+			if service, err = database.GetService(ctx, candidate.ServiceID); err != nil {
+				return offers, err
+			}
+			services[candidate.ServiceID] = service
+		}
+
+		last := timeutil.LocalDate(to.Add(-time.Nanosecond), loc)
+		for date := timeutil.LocalDate(from, loc); !date.After(last); date = date.AddDate(0, 0, 1) {
+			if !requestedOn(candidate.RequestedDate, date) {
+				continue
+			}
+			slots, err := availability.FreeSlots(ctx, employee, service, date, nil, now)
+			if err != nil {
+				return offers, err
+			}
+			offer, err := offerFirst(ctx, sender, employee, service, candidate.WaitingListID, candidate.PatientID, slots, from, to, now)
+			if err != nil {
+				return offers, err
+			}
+			if offer != nil {
+				offers = append(offers, *offer)
+				break
+			}
+		}
+	}
+	return offers, nil
+}
+
+// FillAfterCancellation offers the slot a cancelled appointment freed. Failures are logged,
+// since the cancellation itself has already succeeded.
+func FillAfterCancellation(ctx context.Context, sender notifications.Sender, appointment *models.Appointment) {
+	offers, err := FillOpening(ctx, sender, appointment.EmployeeID, appointment.StartDatetime, appointment.EndDatetime)
+	if err != nil {
+		slog.ErrorContext(ctx, "waiting list: filling slot of cancelled appointment", "appointment_id", appointment.ID, "error", err)
+	}
+	if len(offers) > 0 {
+		slog.InfoContext(ctx, "waiting list: offered slots freed by cancellation", "count", len(offers), "appointment_id", appointment.ID)
+	}
+}
+
+// FillDay offers an employee's free time on a local date, e.g. after the day's hours were
+// extended. Failures are logged rather than returned.
+func FillDay(ctx context.Context, sender notifications.Sender, employee *models.Employee, date time.Time) {
+	windows, err := availability.WorkingWindows(ctx, employee, date)
+	if err == nil && len(windows) > 0 {
+		var offers []Offer
+		offers, err = FillOpening(ctx, sender, employee.ID, windows[0].Start, windows[len(windows)-1].End)
+		if len(offers) > 0 {
+			slog.InfoContext(ctx, "waiting list: offered slots", "count", len(offers), "employee_id", employee.ID, "date", date.Format(timeutil.DateLayout))
+		}
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "waiting list: filling day", "employee_id", employee.ID, "date", date.Format(timeutil.DateLayout), "error", err)
+	}
+}
+
+// OfferEntry finds the earliest free slot for one waiting list entry, with its preferred
+// employee or anyone offering the service, on its requested date or within SearchDays,
+// and offers it
+func OfferEntry(ctx context.Context, sender notifications.Sender, entry *models.WaitingList) (*Offer, error) {
+	if entry.Status != "ACTIVE" && entry.Status != "CONTACTED" {
+		return nil, ErrNotOfferable
+	}
+	service, err := database.GetService(ctx, entry.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var employees []models.Employee
+	if entry.PreferredEmployeeID != nil {
+		employee, err := database.GetEmployee(ctx, *entry.PreferredEmployeeID)
+		if err != nil {
+			return nil, err
+		}
+		employees = []models.Employee{*employee}
+	} else if employees, err = database.GetBookableEmployees(ctx, service.ID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var best *availability.Interval
+	var bestEmployee *models.Employee
+	for i := range employees {
+		employee := &employees[i]
+		loc := availability.Location(employee)
+		first, last := timeutil.LocalDate(now, loc), timeutil.LocalDate(now, loc).AddDate(0, 0, SearchDays)
+		if requested, ok := parseRequested(entry.RequestedDate); ok {
+			first, last = requested, requested
+		}
+		for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+			slots, err := availability.FreeSlots(ctx, employee, service, date, nil, now)
+			if err != nil {
+				return nil, err
+			}
+			if len(slots) > 0 {
+				if best == nil || slots[0].Start.Before(best.Start) {
+					best, bestEmployee = &slots[0], employee
+				}
+				break
+			}
+		}
+	}
+	if best == nil {
+		return nil, ErrNoSlot
+	}
+
+	offer, err := offerFirst(ctx, sender, bestEmployee, service, entry.ID, entry.PatientID, []availability.Interval{*best}, best.Start, best.End, now)
+	if err != nil {
+		return nil, err
+	}
+	if offer == nil {
+		return nil, ErrNoSlot
+	}
+	return offer, nil
+}
+
+// offerFirst holds the first slot overlapping [from, to) for the entry, marks it CONTACTED
+// and tells the patient. It returns nil when every such slot was taken in the meantime.
+func offerFirst(ctx context.Context, sender notifications.Sender, employee *models.Employee, service *models.Service, waitingListID, patientID int, slots []availability.Interval, from, to, now time.Time) (*Offer, error) {
+	for _, slot := range slots {
+		if !slot.Start.Before(to) || !slot.End.After(from) {
+			continue
+		}
+		token, err := newHoldToken()
+		if err != nil {
+			return nil, err
+		}
+		hold := models.SlotHold{
+			EmployeeID:    employee.ID,
+			ServiceID:     service.ID,
+			StartDatetime: slot.Start,
+			EndDatetime:   slot.End,
+			PatientID:     &patientID,
+			HoldToken:     token,
+			ExpiresAt:     now.Add(OfferTTL()),
+		}
+		// The hold only stands if the entry is still waiting to be marked contacted
+		var entry *models.WaitingList
+		err = database.WithTx(ctx, func(ctx context.Context) error {
+			if err := database.CreateSlotHold(ctx, &hold); err != nil {
+				return err
+			}
+			var err error
+			if entry, err = database.MarkWaitingListContacted(ctx, waitingListID); err != nil {
+				return err
+			}
+			if err := audit.Record(ctx, audit.EntityWaitingList, entry.ID, audit.ActionUpdate, entry); err != nil {
+				return err
+			}
+			return webhooks.Publish(ctx, webhooks.EventWaitlistMatched, webhooks.MatchData(entry, &hold))
+		})
+		if errors.Is(err, database.ErrSlotTaken) {
+			continue
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The entry was scheduled or expired while the slot was being found
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		notifyOffer(ctx, sender, employee, service, &hold)
+		return &Offer{WaitingListID: waitingListID, PatientID: patientID, Hold: hold}, nil
+	}
+	return nil, nil
+}
+
+// notifyOffer tells the patient which slot is being held for them and until when
+func notifyOffer(ctx context.Context, sender notifications.Sender, employee *models.Employee, service *models.Service, hold *models.SlotHold) {
+	patient, err := database.GetPatient(ctx, *hold.PatientID)
+	if err != nil {
+		slog.ErrorContext(ctx, "waiting list: loading patient for offer", "hold_id", hold.ID, "error", err)
+		return
+	}
+	prefs, err := database.GetNotificationPreferences(ctx, patient.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "waiting list: loading notification preferences for offer", "hold_id", hold.ID, "error", err)
+		return
+	}
+	body := fmt.Sprintf("A slot for %s at %s has opened up and is being held for you until %s. Please contact the clinic to confirm it.",
+		service.Name, timeutil.FormatIn(hold.StartDatetime, employee.Timezone), timeutil.FormatIn(hold.ExpiresAt, employee.Timezone))
+	for _, msg := range notifications.PatientMessages(ctx, patient, prefs, notifications.TemplateWaitlistOffer, "Appointment slot available", body) {
+		if err := sender.Send(ctx, msg); err != nil {
+			slog.ErrorContext(ctx, "waiting list: notifying patient", "patient_id", patient.ID, "error", err)
+		}
+	}
+}
+
+// requestedOn reports whether an entry's requested date, if any, is the given local date.
+// Entries without one match any date.
+func requestedOn(requestedDate *string, date time.Time) bool {
+	requested, ok := parseRequested(requestedDate)
+	return !ok || requested.Equal(date)
+}
+
+func parseRequested(requestedDate *string) (time.Time, bool) {
+	if requestedDate == nil {
+		return time.Time{}, false
+	}
+	date, err := timeutil.ParseDate(*requestedDate)
+	return date, err == nil
+}
+
+func newHoldToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
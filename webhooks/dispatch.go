@@ -0,0 +1,163 @@
+// Medical Appointment Booking System - Webhooks Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"bookings/database"
+	"bookings/models"
+)
+
+// Delivery headers. The signature is "t=<unix seconds>,v1=<hex HMAC-SHA256>" over
+// "<unix seconds>.<body>", keyed with the subscription's secret.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	EventIDHeader   = "X-Webhook-Event-ID"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// maxErrorLength bounds the error kept for a failed attempt
+const maxErrorLength = 500
+
+// MaxAttempts is how many times a delivery is tried before it is marked FAILED
+const MaxAttempts = 8
+
+// Timeout is how long a subscriber has to answer a delivery
+const Timeout = 10 * time.Second
+
+// Sign returns the signature header value for a body sent at t
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// RetryDelay is how long to wait after the given failed attempt (1 for the first) before the
+// next: a minute, doubling each time
+func RetryDelay(attempt int) time.Duration {
+	return time.Minute << (attempt - 1)
+}
+
+// envelope is the body posted for every event
+type envelope struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher posts due deliveries to their subscribers
+type Dispatcher struct {
+	Client *http.Client
+}
+
+// NewDispatcher returns a Dispatcher whose requests time out after Timeout and do not follow
+// redirects, so a delivery only ever goes to the subscribed URL
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{Client: &http.Client{
+		Timeout: Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Dispatch claims up to limit due deliveries and attempts each once, logging the attempt and
+// scheduling a retry or giving up as needed. It returns how many were delivered.
+func (d *Dispatcher) Dispatch(ctx context.Context, now time.Time, limit int) (int, error) {
+	// The lease covers every claimed delivery timing out in turn
+	due, err := database.ClaimWebhookDeliveries(ctx, now, now.Add(time.Duration(limit+1)*Timeout), limit)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for i := range due {
+		attempt := d.send(ctx, &due[i])
+		succeeded := attempt.Error == nil
+		var retryAt *time.Time
+		if !succeeded && due[i].Attempts+1 < MaxAttempts {
+			next := attempt.AttemptedAt.Add(RetryDelay(due[i].Attempts + 1))
+			retryAt = &next
+		}
+		if err := database.RecordWebhookAttempt(ctx, due[i].ID, attempt, succeeded, retryAt); err != nil {
+			return delivered, err
+		}
+		if succeeded {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// send posts one delivery. Any 2xx answer is a success; the error of a failed attempt is set.
+func (d *Dispatcher) send(ctx context.Context, delivery *database.DueWebhookDelivery) models.WebhookAttempt {
+	started := time.Now()
+	attempt := models.WebhookAttempt{AttemptedAt: started}
+	fail := func(err error) models.WebhookAttempt {
+		message := err.Error()
+		if len(message) > maxErrorLength {
+			message = message[:maxErrorLength]
+		}
+		attempt.Error = &message
+		attempt.DurationMS = int(time.Since(started) / time.Millisecond)
+		return attempt
+	}
+
+	body, err := json.Marshal(envelope{ID: delivery.EventID, Type: delivery.EventType, CreatedAt: delivery.EventCreatedAt.UTC(), Data: delivery.Payload})
+	if err != nil {
+		return fail(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bookings-webhooks/1")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(EventIDHeader, strconv.Itoa(delivery.EventID))
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, started, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = &resp.StatusCode
+	attempt.DurationMS = int(time.Since(started) / time.Millisecond)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := fmt.Sprintf("subscriber answered %d", resp.StatusCode)
+		attempt.Error = &message
+	}
+	return attempt
+}
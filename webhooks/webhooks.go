@@ -0,0 +1,115 @@
+// Medical Appointment Booking System - Webhooks Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package webhooks tells subscribed systems about appointment, patient and waiting list
+// events. Publish queues an event in the database outbox; the dispatcher posts it, signed
+// with each subscription's secret, and retries failed deliveries with backoff.
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"bookings/database"
+	"bookings/models"
+)
+
+// Event types, as listed in models.WebhookEvents
+const (
+	EventAppointmentCreated   = "appointment.created"
+	EventAppointmentCancelled = "appointment.cancelled"
+	EventPatientCreated       = "patient.created"
+	EventWaitlistMatched      = "waitlist.matched"
+)
+
+// Publish queues an event of the given type for every active subscription to it. Like
+// audit.Record, call it with the context of the database.WithTx that makes the change and
+// return its error from there, so the event is queued if and only if the change commits.
+func Publish(ctx context.Context, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("webhooks: encoding %s event: %w", eventType, err)
+	}
+	if _, err := database.EnqueueWebhookEvent(ctx, eventType, payload); err != nil {
+		return fmt.Errorf("webhooks: queueing %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// Event data leaves out notes, medical notes and contact details; subscribers look the
+// records up through the API, which checks their access and logs reads of patient data.
+
+// Appointment is the data of the appointment events
+type Appointment struct {
+	ID                 int       `json:"id"`
+	PublicID           string    `json:"public_id"`
+	PatientID          int       `json:"patient_id"`
+	EmployeeID         int       `json:"employee_id"`
+	ServiceID          int       `json:"service_id"`
+	ClinicID           int       `json:"clinic_id"`
+	StartDatetime      time.Time `json:"start_datetime"`
+	EndDatetime        time.Time `json:"end_datetime"`
+	Status             string    `json:"status"`
+	AppointmentType    *string   `json:"appointment_type"`
+	BookingChannel     *string   `json:"booking_channel"`
+	CancellationReason *string   `json:"cancellation_reason"`
+	SeriesID           *int      `json:"series_id"`
+}
+
+// AppointmentData is the event data for an appointment
+func AppointmentData(a *models.Appointment) Appointment {
+	return Appointment{
+		ID: a.ID, PublicID: a.PublicID, PatientID: a.PatientID, EmployeeID: a.EmployeeID,
+		ServiceID: a.ServiceID, ClinicID: a.ClinicID, StartDatetime: a.StartDatetime.UTC(), EndDatetime: a.EndDatetime.UTC(),
+		Status: a.Status, AppointmentType: a.AppointmentType, BookingChannel: a.BookingChannel,
+		CancellationReason: a.CancellationReason, SeriesID: a.SeriesID,
+	}
+}
+
+// Patient is the data of patient.created
+type Patient struct {
+	ID        int       `json:"id"`
+	PublicID  string    `json:"public_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PatientData is the event data for a patient
+func PatientData(p *models.Patient) Patient {
+	return Patient{ID: p.ID, PublicID: p.PublicID, CreatedAt: p.CreatedAt.UTC()}
+}
+
+// Match is the data of waitlist.matched: a slot held for a waiting list entry. The hold
+// token is left out, since it lets its bearer book the slot.
+type Match struct {
+	WaitingListID int       `json:"waiting_list_id"`
+	PatientID     int       `json:"patient_id"`
+	ServiceID     int       `json:"service_id"`
+	EmployeeID    int       `json:"employee_id"`
+	HoldID        int       `json:"hold_id"`
+	StartDatetime time.Time `json:"start_datetime"`
+	EndDatetime   time.Time `json:"end_datetime"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// MatchData is the event data for a slot held for a waiting list entry
+func MatchData(entry *models.WaitingList, hold *models.SlotHold) Match {
+	return Match{
+		WaitingListID: entry.ID, PatientID: entry.PatientID, ServiceID: hold.ServiceID, EmployeeID: hold.EmployeeID,
+		HoldID: hold.ID, StartDatetime: hold.StartDatetime.UTC(), EndDatetime: hold.EndDatetime.UTC(), ExpiresAt: hold.ExpiresAt.UTC(),
+	}
+}
@@ -0,0 +1,65 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"bookings/database"
+	"bookings/eventbus"
+	"bookings/models"
+)
+
+// EventRelayInterval is how often the event outbox is relayed to the event bus
+const EventRelayInterval = 5 * time.Second
+
+// EventRelayBatch is the most events published per request to the broker
+const EventRelayBatch = 100
+
+// EventRelayJob publishes the domain events queued in the outbox, batch by batch until it
+// is empty. A batch the broker does not accept stays queued, and holds back the events
+// after it, until a later run gets it through.
+func EventRelayJob(publisher eventbus.Publisher) Job {
+	publish := func(ctx context.Context, entries []models.AuditEntry) error {
+		events := make([]eventbus.Event, len(entries))
+		for i := range entries {
+			events[i] = eventbus.NewEvent(&entries[i])
+		}
+		return publisher.Publish(ctx, events)
+	}
+	return Job{
+		Name:     "event relay",
+		Interval: EventRelayInterval,
+		Run: func(ctx context.Context, now time.Time) error {
+			total := 0
+			defer func() {
+				if total > 0 {
+					slog.InfoContext(ctx, "event relay: published events", "count", total)
+				}
+			}()
+			for {
+				n, err := database.RelayEventOutbox(ctx, EventRelayBatch, publish)
+				total += n
+				if err != nil || n < EventRelayBatch {
+					return err
+				}
+			}
+		},
+	}
+}
@@ -0,0 +1,49 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"bookings/hl7"
+)
+
+// HL7DispatchInterval is how often queued HL7 messages are sent
+const HL7DispatchInterval = 30 * time.Second
+
+// HL7DispatchBatch is the most messages sent per run; the rest wait for the next one
+const HL7DispatchBatch = 50
+
+// HL7DispatchJob sends due HL7 SIU messages to the configured receiver. A message that is
+// not acknowledged is retried with backoff by later runs until hl7.MaxAttempts, then marked
+// FAILED; one the receiver rejects is marked FAILED at once.
+func HL7DispatchJob(config *hl7.Config) Job {
+	dispatcher := hl7.NewDispatcher(config)
+	return Job{
+		Name:     "hl7 dispatch",
+		Interval: HL7DispatchInterval,
+		Run: func(ctx context.Context, now time.Time) error {
+			sent, err := dispatcher.Dispatch(ctx, now, HL7DispatchBatch)
+			if sent > 0 {
+				slog.InfoContext(ctx, "hl7 dispatch: sent messages", "count", sent)
+			}
+			return err
+		},
+	}
+}
@@ -0,0 +1,45 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"bookings/database"
+)
+
+// HoldCleanupInterval is how often expired slot holds are deleted. Expired holds are already
+// ignored by availability and booking checks; the sweep only keeps the table small.
+const HoldCleanupInterval = 5 * time.Minute
+
+// HoldCleanupJob deletes expired slot holds every HoldCleanupInterval. A failed sweep is
+// simply picked up by the next one.
+func HoldCleanupJob() Job {
+	return Job{
+		Name:     "slot hold cleanup",
+		Interval: HoldCleanupInterval,
+		Run: func(ctx context.Context, now time.Time) error {
+			n, err := database.DeleteExpiredSlotHolds(ctx, now)
+			if n > 0 {
+				slog.InfoContext(ctx, "slot hold cleanup: removed expired holds", "count", n)
+			}
+			return err
+		},
+	}
+}
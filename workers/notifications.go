@@ -0,0 +1,47 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"bookings/notifications"
+)
+
+// NotificationRetryInterval is how often failed notifications due a retry are sent again
+const NotificationRetryInterval = 30 * time.Second
+
+// NotificationRetryBatch is the most notifications retried per run; the rest wait for the next one
+const NotificationRetryBatch = 50
+
+// NotificationRetryJob sends again the emails and SMS the delivery log holds after a transient
+// failure, with backoff, until notifications.MaxAttempts, then marks them FAILED
+func NotificationRetryJob(sender notifications.Sender) Job {
+	return Job{
+		Name:     "notification retry",
+		Interval: NotificationRetryInterval,
+		Run: func(ctx context.Context, now time.Time) error {
+			delivered, err := notifications.RetryDue(ctx, sender, now, NotificationRetryBatch)
+			if delivered > 0 {
+				slog.InfoContext(ctx, "notification retry: delivered notifications", "count", delivered)
+			}
+			return err
+		},
+	}
+}
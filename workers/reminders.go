@@ -0,0 +1,229 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"bookings/database"
+	"bookings/models"
+	"bookings/noshow"
+	"bookings/notifications"
+	"bookings/timeutil"
+)
+
+// DefaultReminderSweepInterval is how often due reminders are sent when
+// REMINDER_SWEEP_INTERVAL is not set
+const DefaultReminderSweepInterval = time.Minute
+
+// ReminderCatchUp is how late a reminder may still go out, e.g. after a restart. Reminders
+// whose send time is further in the past are dropped, as BuildPlan reports them.
+const ReminderCatchUp = 15 * time.Minute
+
+// ReminderSweepInterval reads the REMINDER_SWEEP_INTERVAL environment variable (e.g. "30s")
+func ReminderSweepInterval() (time.Duration, error) {
+	raw := os.Getenv("REMINDER_SWEEP_INTERVAL")
+	if raw == "" {
+		return DefaultReminderSweepInterval, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid REMINDER_SWEEP_INTERVAL %q", raw)
+	}
+	return d, nil
+}
+
+// ReminderJob sends due appointment reminders and escalations every interval
+func ReminderJob(interval time.Duration, sender notifications.Sender) Job {
+	return Job{
+		Name:     "reminder sweep",
+		Interval: interval,
+		Retries:  DefaultRetries,
+		Run: func(ctx context.Context, now time.Time) error {
+			return SendDueReminders(ctx, sender, now)
+		},
+	}
+}
+
+// SendDueReminders runs one sweep: every reminder and escalation the notification plan
+// schedules at or before now (SMS and email reminders at REMINDER_LEAD_TIMES plus the
+// high-risk extra, and the clinic's email about an appointment still unconfirmed
+// EscalationLeadTime before it) is sent once. Transient delivery failures are retried by the
+// delivery log; a notification whose delivery fails outright is tried again on the next sweep
+// until ReminderCatchUp passes.
+// SMS reminders invite a reply when the SMS provider accepts replies.
+func SendDueReminders(ctx context.Context, sender notifications.Sender, now time.Time) error {
+	replies := notifications.InboundSMS(sender) != nil
+	// Patients may choose a longer lead time, and quiet hours can bring a reminder up to a
+	// day earlier still
+	horizon := max(slices.Max(notifications.ReminderLeadTimes()), notifications.HighRiskReminderLeadTime,
+		notifications.MaxReminderLead) + 24*time.Hour
+	appointments, err := database.GetUpcomingActiveAppointments(ctx, now, now.Add(horizon))
+	if err != nil {
+		return err
+	}
+
+	clinics := map[int]*models.Clinic{}
+	employees := map[int]*models.Employee{}
+	sent, escalated := 0, 0
+	for i := range appointments {
+		appointment := &appointments[i]
+		clinic, ok := clinics[appointment.ClinicID]
+		if !ok {
+			if clinic, err = database.GetClinic(ctx, appointment.ClinicID); err != nil {
+				return err
+			}
+			clinics[appointment.ClinicID] = clinic
+		}
+		employee, ok := employees[appointment.EmployeeID]
+		if !ok {
+			if employee, err = database.GetEmployee(ctx, appointment.EmployeeID); err != nil {
+				return err
+			}
+			employees[appointment.EmployeeID] = employee
+		}
+		patient, err := database.GetPatient(ctx, appointment.PatientID)
+		if err != nil {
+			return err
+		}
+		prefs, err := database.GetNotificationPreferences(ctx, appointment.PatientID)
+		if err != nil {
+			return err
+		}
+		highRisk := false
+		if clinic.HighRiskExtraReminders {
+			if highRisk, err = isHighRisk(ctx, appointment, employee); err != nil {
+				return err
+			}
+		}
+
+		// Planning as of the start of the catch-up window keeps reminders that fell due
+		// since then and drops ones that were already too late
+		plan := notifications.BuildPlan(appointment, patient, prefs, clinic, employee.Timezone, highRisk, now.Add(-ReminderCatchUp))
+		for _, n := range plan.Notifications {
+			if n.Status != notifications.StatusScheduled || n.ScheduledAt.After(now) {
+				continue
+			}
+			claimed, err := database.ClaimReminder(ctx, appointment.ID, n.Kind, n.Channel, n.ScheduledAt)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+			var msg notifications.Message
+			if n.Kind == notifications.KindEscalation {
+				msg = escalationMessage(n.Recipient, appointment, patient, employee)
+			} else {
+				msg = notifications.ForPatient(ctx, notifications.Message{
+					Channel:   n.Channel,
+					Recipient: n.Recipient,
+					Subject:   "Appointment reminder",
+					Body:      reminderBody(ctx, appointment, employee, clinic),
+					Template:  notifications.TemplateReminder,
+				}, patient, prefs)
+				if n.Channel == notifications.ChannelSMS && replies {
+					msg.Body += " " + replyPrompt(appointment)
+				}
+			}
+			msg.AppointmentID = &appointment.ID
+			if err := sender.Send(ctx, msg); err != nil {
+				slog.ErrorContext(ctx, "reminder sweep: sending", "appointment_id", appointment.ID, "kind", n.Kind, "channel", n.Channel, "error", err)
+				if err := database.ReleaseReminder(ctx, appointment.ID, n.Kind, n.Channel, n.ScheduledAt); err != nil {
+					return err
+				}
+				continue
+			}
+			if n.Kind == notifications.KindEscalation {
+				escalated++
+			} else {
+				sent++
+			}
+		}
+	}
+	if sent > 0 {
+		slog.InfoContext(ctx, "reminder sweep: sent reminders", "count", sent)
+	}
+	if escalated > 0 {
+		slog.InfoContext(ctx, "reminder sweep: escalated unconfirmed appointments", "count", escalated)
+	}
+	return nil
+}
+
+// escalationMessage tells the clinic that an appointment is still unconfirmed, so staff can
+// call the patient before the slot is lost
+func escalationMessage(recipient string, appointment *models.Appointment, patient *models.Patient, employee *models.Employee) notifications.Message {
+	return notifications.Message{
+		Channel:   notifications.ChannelEmail,
+		Recipient: recipient,
+		Subject:   fmt.Sprintf("Appointment %s is still unconfirmed", appointment.PublicID),
+		Body: fmt.Sprintf("The appointment of patient #%d with %s %s at %s (reference %s) has not been confirmed. Please contact the patient.",
+			patient.ID, employee.FirstName, employee.LastName,
+			timeutil.FormatIn(appointment.StartDatetime, employee.Timezone), appointment.PublicID),
+		Template:  notifications.TemplateEscalation,
+		PatientID: &patient.ID,
+	}
+}
+
+// reminderBody is the reminder text. When PUBLIC_BASE_URL is set it ends with the links to
+// confirm (while the appointment is still unconfirmed) or cancel it.
+func reminderBody(ctx context.Context, appointment *models.Appointment, employee *models.Employee, clinic *models.Clinic) string {
+	body := fmt.Sprintf("Reminder: your appointment with %s %s at %s is at %s. Reference %s.",
+		employee.FirstName, employee.LastName, clinic.Name,
+		timeutil.FormatIn(appointment.StartDatetime, employee.Timezone), appointment.PublicID)
+	confirmURL, cancelURL := notifications.AppointmentLinks(ctx, appointment.PublicID)
+	if confirmURL == "" {
+		return body
+	}
+	if appointment.Status == "SCHEDULED" {
+		body += " Confirm: " + confirmURL
+	}
+	return body + " Cancel: " + cancelURL
+}
+
+// replyPrompt tells the patient which SMS replies the inbound webhook acts on
+func replyPrompt(appointment *models.Appointment) string {
+	if appointment.Status == "SCHEDULED" {
+		return "Reply YES to confirm or CANCEL to cancel."
+	}
+	return "Reply CANCEL to cancel."
+}
+
+// isHighRisk scores the appointment's no-show risk the same way the day schedule does
+func isHighRisk(ctx context.Context, appointment *models.Appointment, employee *models.Employee) (bool, error) {
+	attended, noShows, err := database.GetPatientAttendance(ctx, appointment.PatientID, appointment.StartDatetime)
+	if err != nil {
+		return false, err
+	}
+	channel := ""
+	if appointment.BookingChannel != nil {
+		channel = *appointment.BookingChannel
+	}
+	risk := noshow.Score(noshow.Input{
+		PriorAttended:  attended,
+		PriorNoShows:   noShows,
+		LeadTime:       appointment.StartDatetime.Sub(appointment.CreatedAt),
+		LocalHour:      appointment.StartDatetime.In(timeutil.LoadLocation(employee.Timezone)).Hour(),
+		BookingChannel: channel,
+	})
+	return risk.Level == noshow.LevelHigh, nil
+}
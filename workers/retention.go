@@ -0,0 +1,42 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"time"
+
+	"bookings/config"
+	"bookings/retention"
+	"bookings/storage"
+)
+
+// RetentionSweepInterval is how often rows past their retention period are archived or purged
+const RetentionSweepInterval = time.Hour
+
+// RetentionJob applies the data retention policies, moving rows to cold storage in store
+func RetentionJob(policies map[string]config.RetentionPolicy, store *storage.Store) Job {
+	return Job{
+		Name:     "data retention",
+		Interval: RetentionSweepInterval,
+		Retries:  DefaultRetries,
+		Run: func(ctx context.Context, now time.Time) error {
+			_, err := retention.Run(ctx, policies, store, now)
+			return err
+		},
+	}
+}
@@ -0,0 +1,197 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"bookings/audit"
+	"bookings/database"
+	"bookings/models"
+	"bookings/notifications"
+	"bookings/timeutil"
+	"bookings/waitlist"
+)
+
+// DefaultNoShowGrace is how long after an appointment ends it is marked NO_SHOW when
+// NO_SHOW_GRACE is not set
+const DefaultNoShowGrace = 2 * time.Hour
+
+// NoShowSweepInterval is how often ended appointments are checked for no-shows
+const NoShowSweepInterval = 15 * time.Minute
+
+// WaitingListExpiryInterval is how often waiting list entries are checked for expiry
+const WaitingListExpiryInterval = time.Hour
+
+// PrescriptionExpiryInterval is how often active prescriptions are checked for expiry
+const PrescriptionExpiryInterval = time.Hour
+
+// WaitingListEscalationInterval is how often URGENT waiting list entries are checked against the SLA
+const WaitingListEscalationInterval = 15 * time.Minute
+
+// NoShowGrace reads the NO_SHOW_GRACE environment variable (e.g. "1h"); "0" marks
+// appointments as soon as they end
+func NoShowGrace() (time.Duration, error) {
+	raw := os.Getenv("NO_SHOW_GRACE")
+	if raw == "" {
+		return DefaultNoShowGrace, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid NO_SHOW_GRACE %q", raw)
+	}
+	return d, nil
+}
+
+// NoShowJob marks appointments that ended more than grace ago and were never checked in
+// or completed as NO_SHOW
+func NoShowJob(grace time.Duration) Job {
+	return Job{
+		Name:     "no-show marking",
+		Interval: NoShowSweepInterval,
+		Retries:  DefaultRetries,
+		Run: func(ctx context.Context, now time.Time) error {
+			var marked []models.Appointment
+			err := database.WithTx(ctx, func(ctx context.Context) error {
+				var err error
+				if marked, err = database.MarkNoShows(ctx, now.Add(-grace)); err != nil {
+					return err
+				}
+				for i := range marked {
+					if err := audit.Record(ctx, audit.EntityAppointments, marked[i].ID, audit.ActionUpdate, marked[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(marked) > 0 {
+				slog.InfoContext(ctx, "no-show marking: marked appointments", "count", len(marked))
+			}
+			return nil
+		},
+	}
+}
+
+// WaitingListExpiryJob expires waiting list entries whose requested date has passed or that
+// have waited longer than maxAge (0 for no limit)
+func WaitingListExpiryJob(maxAge time.Duration) Job {
+	return Job{
+		Name:     "waiting list expiry",
+		Interval: WaitingListExpiryInterval,
+		Retries:  DefaultRetries,
+		Run: func(ctx context.Context, now time.Time) error {
+			var createdBefore *time.Time
+			if maxAge > 0 {
+				cutoff := now.Add(-maxAge)
+				createdBefore = &cutoff
+			}
+			var expired []models.WaitingList
+			err := database.WithTx(ctx, func(ctx context.Context) error {
+				var err error
+				if expired, err = database.ExpireWaitingList(ctx, now.UTC().Format(timeutil.DateLayout), createdBefore); err != nil {
+					return err
+				}
+				for i := range expired {
+					if err := audit.Record(ctx, audit.EntityWaitingList, expired[i].ID, audit.ActionUpdate, expired[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(expired) > 0 {
+				slog.InfoContext(ctx, "waiting list expiry: expired entries", "count", len(expired))
+			}
+			return nil
+		},
+	}
+}
+
+// WaitingListEscalationJob escalates URGENT waiting list entries still waiting sla after they
+// were added, emailing staff once per entry
+func WaitingListEscalationJob(sla time.Duration, sender notifications.Sender) Job {
+	return Job{
+		Name:     "waiting list escalation",
+		Interval: WaitingListEscalationInterval,
+		Retries:  DefaultRetries,
+		Run: func(ctx context.Context, now time.Time) error {
+			var escalated []models.WaitingList
+			err := database.WithTx(ctx, func(ctx context.Context) error {
+				var err error
+				if escalated, err = database.EscalateWaitingList(ctx, now.Add(-sla), now); err != nil {
+					return err
+				}
+				for i := range escalated {
+					if err := audit.Record(ctx, audit.EntityWaitingList, escalated[i].ID, audit.ActionUpdate, escalated[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for i := range escalated {
+				waitlist.NotifyEscalation(ctx, sender, &escalated[i], sla)
+			}
+			if len(escalated) > 0 {
+				slog.WarnContext(ctx, "waiting list escalation: entries past the urgent SLA", "count", len(escalated))
+			}
+			return nil
+		},
+	}
+}
+
+// PrescriptionExpiryJob marks active prescriptions whose expiry date has passed as EXPIRED
+func PrescriptionExpiryJob() Job {
+	return Job{
+		Name:     "prescription expiry",
+		Interval: PrescriptionExpiryInterval,
+		Retries:  DefaultRetries,
+		Run: func(ctx context.Context, now time.Time) error {
+			var expired []models.Prescription
+			err := database.WithTx(ctx, func(ctx context.Context) error {
+				var err error
+				if expired, err = database.ExpirePrescriptions(ctx, now.UTC().Format(timeutil.DateLayout)); err != nil {
+					return err
+				}
+				for i := range expired {
+					if err := audit.Record(ctx, audit.EntityPrescriptions, expired[i].ID, audit.ActionUpdate, expired[i]); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(expired) > 0 {
+				slog.InfoContext(ctx, "prescription expiry: expired prescriptions", "count", len(expired))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,121 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"bookings/audit"
+	"bookings/database"
+	"bookings/hl7"
+	"bookings/models"
+	"bookings/notifications"
+	"bookings/timeutil"
+	"bookings/waitlist"
+	"bookings/webhooks"
+)
+
+// DefaultUnpaidSweepInterval is how often unpaid bookings are checked when
+// UNPAID_SWEEP_INTERVAL is not set
+const DefaultUnpaidSweepInterval = time.Minute
+
+// UnpaidSweepInterval reads the UNPAID_SWEEP_INTERVAL environment variable (e.g. "30s")
+func UnpaidSweepInterval() (time.Duration, error) {
+	raw := os.Getenv("UNPAID_SWEEP_INTERVAL")
+	if raw == "" {
+		return DefaultUnpaidSweepInterval, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid UNPAID_SWEEP_INTERVAL %q", raw)
+	}
+	return d, nil
+}
+
+// UnpaidCancellationJob cancels overdue unpaid bookings every interval
+func UnpaidCancellationJob(interval time.Duration, sender notifications.Sender) Job {
+	return Job{
+		Name:     "unpaid booking sweep",
+		Interval: interval,
+		Retries:  DefaultRetries,
+		Run: func(ctx context.Context, now time.Time) error {
+			return CancelUnpaid(ctx, sender, now)
+		},
+	}
+}
+
+// CancelUnpaid runs one sweep: bookings for prepaid services whose payment window has
+// passed are cancelled, which frees their slot, and the patient is told why
+func CancelUnpaid(ctx context.Context, sender notifications.Sender, now time.Time) error {
+	var cancelled []models.Appointment
+	err := database.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if cancelled, err = database.CancelUnpaidAppointments(ctx, now); err != nil {
+			return err
+		}
+		for i := range cancelled {
+			if err := audit.Record(ctx, audit.EntityAppointments, cancelled[i].ID, audit.ActionUpdate, cancelled[i]); err != nil {
+				return err
+			}
+			if err := webhooks.Publish(ctx, webhooks.EventAppointmentCancelled, webhooks.AppointmentData(&cancelled[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range cancelled {
+		appointment := &cancelled[i]
+		hl7.Publish(ctx, hl7.TriggerCancelled, appointment)
+		waitlist.FillAfterCancellation(ctx, sender, appointment)
+
+		patient, err := database.GetPatient(ctx, appointment.PatientID)
+		if err != nil {
+			slog.ErrorContext(ctx, "unpaid booking sweep: loading patient", "appointment_id", appointment.ID, "error", err)
+			continue
+		}
+		prefs, err := database.GetNotificationPreferences(ctx, appointment.PatientID)
+		if err != nil {
+			slog.ErrorContext(ctx, "unpaid booking sweep: loading notification preferences", "appointment_id", appointment.ID, "error", err)
+			continue
+		}
+		// Times are shown in the employee's timezone, or UTC if that cannot be loaded
+		timezone := ""
+		if employee, err := database.GetEmployee(ctx, appointment.EmployeeID); err == nil {
+			timezone = employee.Timezone
+		}
+		body := fmt.Sprintf("Your appointment at %s was cancelled because payment was not received in time. Please book again if you still need it.",
+			timeutil.FormatIn(appointment.StartDatetime, timezone))
+		for _, msg := range notifications.PatientMessages(ctx, patient, prefs, notifications.TemplateUnpaidCancellation, "Appointment cancelled", body) {
+			msg.AppointmentID = &appointment.ID
+			if err := sender.Send(ctx, msg); err != nil {
+				slog.ErrorContext(ctx, "unpaid booking sweep: notifying patient", "patient_id", patient.ID, "error", err)
+			}
+		}
+	}
+	if len(cancelled) > 0 {
+		slog.InfoContext(ctx, "unpaid booking sweep: cancelled appointments", "count", len(cancelled))
+	}
+	return nil
+}
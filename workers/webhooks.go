@@ -0,0 +1,48 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"bookings/webhooks"
+)
+
+// WebhookDispatchInterval is how often queued webhook deliveries are sent
+const WebhookDispatchInterval = 30 * time.Second
+
+// WebhookDispatchBatch is the most deliveries sent per run; the rest wait for the next one
+const WebhookDispatchBatch = 50
+
+// WebhookDispatchJob posts due webhook deliveries to their subscribers. A failed delivery is
+// retried with backoff by later runs until webhooks.MaxAttempts, then marked FAILED.
+func WebhookDispatchJob() Job {
+	dispatcher := webhooks.NewDispatcher()
+	return Job{
+		Name:     "webhook dispatch",
+		Interval: WebhookDispatchInterval,
+		Run: func(ctx context.Context, now time.Time) error {
+			delivered, err := dispatcher.Dispatch(ctx, now, WebhookDispatchBatch)
+			if delivered > 0 {
+				slog.InfoContext(ctx, "webhook dispatch: delivered events", "count", delivered)
+			}
+			return err
+		},
+	}
+}
@@ -0,0 +1,149 @@
+// Medical Appointment Booking System - Workers Package
+// Copyright (C) 2025
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"bookings/database"
+)
+
+// DefaultRetries is how many times a failed job run is retried before waiting for the next tick
+const DefaultRetries = 2
+
+// DefaultRetryDelay is the wait before the first retry; it doubles for each further retry
+const DefaultRetryDelay = 5 * time.Second
+
+// Job is a recurring background task. Run is called immediately on start and then every
+// Interval, once for each organization with a context working for it; a failing run is
+// retried Retries times with backoff before waiting for the next tick.
+type Job struct {
+	Name       string
+	Interval   time.Duration
+	Run        func(ctx context.Context, now time.Time) error
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// Scheduler runs registered jobs, one goroutine each, and stops them gracefully
+type Scheduler struct {
+	jobs   []Job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Register adds a job; jobs must be registered before Start. A job that sets no Retries or
+// RetryDelay gets DefaultRetries and DefaultRetryDelay.
+func (s *Scheduler) Register(job Job) {
+	if job.Retries <= 0 {
+		job.Retries = DefaultRetries
+	}
+	if job.RetryDelay <= 0 {
+		job.RetryDelay = DefaultRetryDelay
+	}
+	s.jobs = append(s.jobs, job)
+}
+
+// Start launches every registered job. They run until ctx is done or Stop is called.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+	slog.InfoContext(ctx, "background jobs started", "count", len(s.jobs))
+}
+
+// Stop signals every job to finish and waits for runs in progress, up to timeout. It
+// reports false when some job was still running when the timeout passed.
+func (s *Scheduler) Stop(timeout time.Duration) bool {
+	if s.cancel == nil {
+		return true
+	}
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// loop runs the job immediately and then on each tick until ctx is done
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		runForEachOrganization(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runForEachOrganization runs the job for every organization in turn, so each run only sees
+// and changes the records of one
+func runForEachOrganization(ctx context.Context, job Job) {
+	ids, err := database.GetOrganizationIDs(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "job failed", "job", job.Name, "error", err)
+		return
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		runWithRetries(database.WithOrganization(ctx, id), job)
+	}
+}
+
+// runWithRetries runs the job once, retrying failures with a doubling delay and logging
+// the final error. Retries stop early when ctx is done.
+func runWithRetries(ctx context.Context, job Job) {
+	delay := job.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := job.Run(ctx, time.Now())
+		if err == nil {
+			return
+		}
+		if attempt >= job.Retries || ctx.Err() != nil {
+			slog.ErrorContext(ctx, "job failed", "job", job.Name, "organization_id", database.OrganizationFromContext(ctx), "error", err)
+			return
+		}
+		slog.WarnContext(ctx, "job failed, retrying", "job", job.Name, "organization_id", database.OrganizationFromContext(ctx),
+			"retry_in", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}